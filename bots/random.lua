@@ -0,0 +1,43 @@
+-- random.lua: wanders the stick and taps the button at random, enough to
+-- keep a filled slot moving while a single visitor plays.
+--
+-- hub bot -slot p4 -script bots/random.lua
+--
+-- See internal/bot for the API: set input.axes.x/y (-1..1) and
+-- input.btn.a in tick(t); the bot sends them like the WebUI controller.
+
+interval = 100 -- ms between tick calls
+
+local next_move = 0
+local release_at = 0
+local playing = true
+
+function tick(t)
+  if not playing then
+    input.axes.x, input.axes.y, input.btn.a = 0, 0, false
+    return
+  end
+  if t >= next_move then
+    -- Hold a direction, or stand still, for 0.3 to 1.5 seconds.
+    input.axes.x = math.random(-1, 1)
+    input.axes.y = math.random(-1, 1)
+    next_move = t + math.random(300, 1500)
+  end
+  if input.btn.a and t >= release_at then
+    input.btn.a = false
+  elseif not input.btn.a and math.random() < 0.05 then
+    input.btn.a = true
+    release_at = t + 150
+  end
+end
+
+-- The hub tells controllers about matches; sit still once one is over
+-- until the next starts.
+function on_message(msg)
+  if msg.type == "match_start" then
+    log("match starting in", msg.countdown, "s")
+    playing = true
+  elseif msg.type == "match_state" then
+    playing = msg.state ~= "finished"
+  end
+end
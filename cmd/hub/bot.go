@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/bot"
+)
+
+const botUsage = `usage: hub bot -slot SLOT -script FILE [-url URL] [-token TOKEN]
+
+Plays a controller slot from a Lua script, so a single visitor can play
+against filled slots during quiet periods. The script moves the stick and
+button; see bots/random.lua for the API. The bot reconnects whenever the
+connection drops, until it is interrupted or kicked.
+
+The hub is found via -url, HUB_WS_URL or ADDR. Hubs requiring controller
+tokens need -token, e.g. from "hub ctl token issue p4 bot".
+`
+
+var errBotUsage = errors.New("invalid hub bot usage")
+
+const (
+	// botResend matches the WebUI controller, which sends its state again
+	// every 2.5s even when nothing changed.
+	botResend = 2500 * time.Millisecond
+	// botReconnect is the pause before dialling again.
+	botReconnect   = 3 * time.Second
+	botDialTimeout = 5 * time.Second
+)
+
+// botTerminalCodes end the bot instead of reconnecting, as they end the
+// WebUI controller: the token expired, or the slot was taken over, kicked or
+// banned. The bot cannot renew its -token, so reconnecting with it after
+// 4001 would only be rejected again.
+var botTerminalCodes = map[websocket.StatusCode]bool{4001: true, 4004: true, 4006: true, 4007: true}
+
+type botRunner struct {
+	url    string
+	slot   string
+	token  string
+	script *bot.Script
+	out    io.Writer
+
+	seq   uint64
+	epoch uint64
+}
+
+func runBot(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("hub bot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	wsURL := fs.String("url", "", "hub WebSocket URL (HUB_WS_URL)")
+	slot := fs.String("slot", "", "controller slot the bot plays, e.g. p4")
+	scriptPath := fs.String("script", "", "Lua script driving the bot")
+	token := fs.String("token", "", "controller token, for hubs requiring one (HUB_CONTROLLER_TOKEN)")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || *slot == "" || *scriptPath == "" {
+		fmt.Fprint(os.Stderr, botUsage)
+		return errBotUsage
+	}
+
+	script, err := bot.Load(*scriptPath, *slot, func(line string) {
+		fmt.Fprintf(out, "bot %s: %s\n", *slot, line)
+	})
+	if err != nil {
+		return err
+	}
+	defer script.Close()
+
+	r := &botRunner{
+		url:    conformanceURL(*wsURL),
+		slot:   *slot,
+		token:  firstNonEmptyString(*token, os.Getenv("HUB_CONTROLLER_TOKEN")),
+		script: script,
+		out:    out,
+	}
+	for {
+		err := r.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var closeErr websocket.CloseError
+		if errors.As(err, &closeErr) && botTerminalCodes[closeErr.Code] {
+			return fmt.Errorf("bot %s: closed by hub: %d %s", r.slot, closeErr.Code, closeErr.Reason)
+		}
+		var scriptErr botScriptError
+		if errors.As(err, &scriptErr) {
+			return scriptErr.err
+		}
+		fmt.Fprintf(out, "bot %s: disconnected: %v; reconnecting in %s\n", r.slot, err, botReconnect)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(botReconnect):
+		}
+	}
+}
+
+// botScriptError is a failure of the script rather than the connection,
+// which reconnecting would not fix.
+type botScriptError struct {
+	err error
+}
+
+func (e botScriptError) Error() string {
+	return e.err.Error()
+}
+
+// session plays one connection until it drops or ctx is done.
+func (r *botRunner) session(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, botDialTimeout)
+	ws, _, err := websocket.Dial(dialCtx, r.url, nil)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer ws.CloseNow()
+
+	register := map[string]any{"role": "controller", "id": r.slot, "protocolVersion": 1}
+	if r.token != "" {
+		register = map[string]any{"role": "controller", "token": r.token, "protocolVersion": 1}
+	}
+	if err := r.write(ctx, ws, register); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "bot %s: connected to %s\n", r.slot, r.url)
+	r.epoch = 0
+
+	// Reads get their own context: a cancelled read closes the connection,
+	// and the bot still has to say goodbye once ctx is done.
+	readCtx, cancelRead := context.WithCancel(context.Background())
+	defer cancelRead()
+	messages := make(chan []byte, 64)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := ws.Read(readCtx)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case messages <- data:
+			case <-readCtx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(r.script.Interval())
+	defer ticker.Stop()
+	var last bot.State
+	var lastSent time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			// Leave the slot like the WebUI does, released.
+			_ = r.send(context.Background(), ws, bot.State{})
+			_ = r.write(context.Background(), ws, map[string]any{"type": "unregister", "id": r.slot})
+			ws.Close(websocket.StatusNormalClosure, "")
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case data := <-messages:
+			var notice struct {
+				Type  string `json:"type"`
+				Epoch uint64 `json:"epoch"`
+			}
+			if json.Unmarshal(data, &notice) == nil && notice.Type == "epoch" {
+				r.epoch = notice.Epoch
+			}
+			if err := r.script.Message(data); err != nil {
+				return botScriptError{err: err}
+			}
+		case now := <-ticker.C:
+			st, err := r.script.Tick(now)
+			if err != nil {
+				return botScriptError{err: err}
+			}
+			if st == last && now.Sub(lastSent) < botResend {
+				continue
+			}
+			if err := r.send(ctx, ws, st); err != nil {
+				return err
+			}
+			last, lastSent = st, now
+		}
+	}
+}
+
+// send writes the state frame the WebUI controller sends.
+func (r *botRunner) send(ctx context.Context, ws *websocket.Conn, st bot.State) error {
+	r.seq++
+	frame := map[string]any{
+		"type": "state",
+		"id":   r.slot,
+		"axes": map[string]float64{"x": st.X, "y": st.Y},
+		"btn":  map[string]bool{"a": st.A},
+		"t":    time.Now().UnixMilli(),
+		"seq":  r.seq,
+	}
+	if r.epoch > 0 {
+		frame["epoch"] = r.epoch
+	}
+	return r.write(ctx, ws, frame)
+}
+
+func (r *botRunner) write(ctx context.Context, ws *websocket.Conn, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.Write(ctx, websocket.MessageText, data)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+const conformanceUsage = `usage: hub conformance [-url URL] [-vectors FILE] [-run SUBSTR] [-game-token TOKEN] [-dump]
+
+Runs the WebSocket protocol test vectors against a live hub and reports
+PASS/FAIL per vector. -dump prints the built-in vectors instead so client
+authors can run them with their own tooling.
+
+The hub is found via -url, HUB_WS_URL or ADDR. Run it against a dedicated
+hub: the vectors take over the game role and slots p1 and p2.
+`
+
+//go:embed conformance/vectors.json
+var conformanceVectors []byte
+
+var (
+	errConformanceUsage  = errors.New("invalid hub conformance usage")
+	errConformanceFailed = errors.New("conformance vectors failed")
+)
+
+// conformanceMatchAny in a match pattern accepts any value for the field as
+// long as it is present.
+const conformanceMatchAny = "*"
+
+type conformanceSuite struct {
+	Version int                 `json:"version"`
+	Vectors []conformanceVector `json:"vectors"`
+}
+
+type conformanceVector struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Steps       []conformanceStep `json:"steps"`
+}
+
+type conformanceStep struct {
+	Op     string          `json:"op"`
+	Conn   string          `json:"conn"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Text   string          `json:"text,omitempty"`
+	Binary bool            `json:"binary,omitempty"`
+	Match  map[string]any  `json:"match,omitempty"`
+	Code   int             `json:"code,omitempty"`
+	Reason string          `json:"reason,omitempty"`
+	MS     int             `json:"ms,omitempty"`
+}
+
+func runConformance(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("hub conformance", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	wsURL := fs.String("url", "", "hub WebSocket URL (HUB_WS_URL)")
+	vectorsFile := fs.String("vectors", "", "run vectors from this file instead of the built-in set")
+	filter := fs.String("run", "", "only run vectors whose name contains this")
+	gameToken := fs.String("game-token", "", "token added to game register messages (GAME_TOKEN)")
+	timeout := fs.Duration("timeout", 2*time.Second, "how long expect steps wait for a message")
+	dump := fs.Bool("dump", false, "print the built-in vectors and exit")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, conformanceUsage)
+		return errConformanceUsage
+	}
+
+	if *dump {
+		_, err := out.Write(conformanceVectors)
+		return err
+	}
+
+	raw := conformanceVectors
+	if *vectorsFile != "" {
+		data, err := os.ReadFile(*vectorsFile)
+		if err != nil {
+			return fmt.Errorf("read vectors: %w", err)
+		}
+		raw = data
+	}
+	var suite conformanceSuite
+	if err := json.Unmarshal(raw, &suite); err != nil {
+		return fmt.Errorf("parse vectors: %w", err)
+	}
+
+	runner := &conformanceRunner{
+		url:       conformanceURL(*wsURL),
+		gameToken: firstNonEmptyString(*gameToken, os.Getenv("GAME_TOKEN")),
+		timeout:   *timeout,
+	}
+	passed, failed := 0, 0
+	for _, vector := range suite.Vectors {
+		if !strings.Contains(vector.Name, *filter) {
+			continue
+		}
+		if err := runner.run(ctx, vector); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", vector.Name, err)
+			continue
+		}
+		passed++
+		fmt.Fprintf(out, "PASS %s\n", vector.Name)
+	}
+	fmt.Fprintf(out, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return errConformanceFailed
+	}
+	return nil
+}
+
+func conformanceURL(flagValue string) string {
+	if raw := firstNonEmptyString(flagValue, os.Getenv("HUB_WS_URL")); raw != "" {
+		return raw
+	}
+	addr := firstNonEmptyString(os.Getenv("ADDR"), ":8765")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "ws://" + addr + "/ws"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "ws://" + net.JoinHostPort(host, port) + "/ws"
+}
+
+type conformanceRunner struct {
+	url       string
+	gameToken string
+	timeout   time.Duration
+}
+
+// conformanceConn reads frames in the background so expect steps can wait on
+// a channel. closeErr holds the read error once done is closed.
+type conformanceConn struct {
+	ws       *websocket.Conn
+	messages chan []byte
+	done     chan struct{}
+	closeErr error
+}
+
+func (r *conformanceRunner) run(ctx context.Context, vector conformanceVector) error {
+	conns := make(map[string]*conformanceConn)
+	defer func() {
+		for _, c := range conns {
+			_ = c.ws.Close(websocket.StatusNormalClosure, "")
+		}
+	}()
+
+	for i, step := range vector.Steps {
+		if err := r.step(ctx, conns, step); err != nil {
+			return fmt.Errorf("step %d (%s %s): %w", i+1, step.Op, step.Conn, err)
+		}
+	}
+	return nil
+}
+
+func (r *conformanceRunner) step(ctx context.Context, conns map[string]*conformanceConn, step conformanceStep) error {
+	if step.Op == "open" {
+		if _, exists := conns[step.Conn]; exists {
+			return errors.New("connection already open")
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+		ws, _, err := websocket.Dial(dialCtx, r.url, nil)
+		if err != nil {
+			return err
+		}
+		c := &conformanceConn{ws: ws, messages: make(chan []byte, 64), done: make(chan struct{})}
+		go c.read(ctx)
+		conns[step.Conn] = c
+		return nil
+	}
+
+	c := conns[step.Conn]
+	if c == nil {
+		return errors.New("connection not open")
+	}
+	switch step.Op {
+	case "send":
+		return r.send(ctx, c, step)
+	case "expect":
+		return c.expect(ctx, step.Match, r.timeout)
+	case "expectNone":
+		return c.expectNone(ctx, step.Match, time.Duration(step.MS)*time.Millisecond)
+	case "expectClose":
+		return c.expectClose(ctx, step.Code, step.Reason, r.timeout)
+	case "close":
+		return c.ws.Close(websocket.StatusNormalClosure, "")
+	default:
+		return fmt.Errorf("unknown op %q", step.Op)
+	}
+}
+
+func (r *conformanceRunner) send(ctx context.Context, c *conformanceConn, step conformanceStep) error {
+	payload := []byte(step.Text)
+	if len(step.JSON) > 0 {
+		payload = r.withGameToken(step.JSON)
+	}
+	msgType := websocket.MessageText
+	if step.Binary {
+		msgType = websocket.MessageBinary
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return c.ws.Write(writeCtx, msgType, payload)
+}
+
+// withGameToken adds the configured game token to a game register message
+// so the vectors also run against a hub with GAME_TOKEN set.
+func (r *conformanceRunner) withGameToken(raw json.RawMessage) []byte {
+	if r.gameToken == "" {
+		return raw
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil || fields["role"] != "game" || fields["token"] != nil {
+		return raw
+	}
+	fields["token"] = r.gameToken
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+func (c *conformanceConn) read(ctx context.Context) {
+	defer close(c.done)
+	for {
+		_, data, err := c.ws.Read(ctx)
+		if err != nil {
+			c.closeErr = err
+			return
+		}
+		select {
+		case c.messages <- data:
+		default:
+			// Nobody is waiting for this much traffic; keep the newest. This
+			// goroutine is the only sender, so the send below cannot block.
+			select {
+			case <-c.messages:
+			default:
+			}
+			c.messages <- data
+		}
+	}
+}
+
+// next returns the next message, or nil once the connection is closed and
+// drained.
+func (c *conformanceConn) next(ctx context.Context, deadline <-chan time.Time) ([]byte, bool, error) {
+	select {
+	case data := <-c.messages:
+		return data, true, nil
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case data := <-c.messages:
+		return data, true, nil
+	case <-c.done:
+		select {
+		case data := <-c.messages:
+			return data, true, nil
+		default:
+			return nil, false, nil
+		}
+	case <-deadline:
+		return nil, false, errTimeout
+	}
+}
+
+var errTimeout = errors.New("timed out")
+
+func (c *conformanceConn) expect(ctx context.Context, match map[string]any, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	var last []byte
+	for {
+		data, ok, err := c.next(ctx, deadline)
+		if err != nil {
+			return fmt.Errorf("no message matching %s (last seen %s): %w", encodeMatch(match), last, err)
+		}
+		if !ok {
+			return fmt.Errorf("connection closed before a message matching %s: %v", encodeMatch(match), c.closeErr)
+		}
+		if messageMatches(data, match) {
+			return nil
+		}
+		last = data
+	}
+}
+
+func (c *conformanceConn) expectNone(ctx context.Context, match map[string]any, window time.Duration) error {
+	deadline := time.After(window)
+	for {
+		data, ok, err := c.next(ctx, deadline)
+		if errors.Is(err, errTimeout) || (err == nil && !ok) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if messageMatches(data, match) {
+			return fmt.Errorf("unexpected message %s", data)
+		}
+	}
+}
+
+func (c *conformanceConn) expectClose(ctx context.Context, code int, reason string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		_, ok, err := c.next(ctx, deadline)
+		if err != nil {
+			return fmt.Errorf("connection still open: %w", err)
+		}
+		if ok {
+			continue
+		}
+		var closeErr websocket.CloseError
+		if !errors.As(c.closeErr, &closeErr) {
+			return fmt.Errorf("connection ended without a close frame: %v", c.closeErr)
+		}
+		if int(closeErr.Code) != code || (reason != "" && closeErr.Reason != reason) {
+			return fmt.Errorf("closed with %d %q, want %d %q", closeErr.Code, closeErr.Reason, code, reason)
+		}
+		return nil
+	}
+}
+
+func messageMatches(data []byte, match map[string]any) bool {
+	var message map[string]any
+	if err := json.Unmarshal(data, &message); err != nil {
+		return false
+	}
+	return containsFields(message, match)
+}
+
+// containsFields reports whether got has every field of want. Nested objects
+// are compared the same way; other values must be equal unless want holds
+// conformanceMatchAny.
+func containsFields(got, want map[string]any) bool {
+	for key, wantValue := range want {
+		gotValue, ok := got[key]
+		if !ok {
+			return false
+		}
+		if wantValue == conformanceMatchAny {
+			continue
+		}
+		wantObject, wantIsObject := wantValue.(map[string]any)
+		gotObject, gotIsObject := gotValue.(map[string]any)
+		if wantIsObject && gotIsObject {
+			if !containsFields(gotObject, wantObject) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeMatch(match map[string]any) string {
+	data, err := json.Marshal(match)
+	if err != nil {
+		return fmt.Sprint(match)
+	}
+	return string(data)
+}
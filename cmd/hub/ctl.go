@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const ctlUsage = `usage: hub ctl [-url URL] <command> [args]
+
+commands:
+  sessions list                          list connected game and controllers
+  kick <slot> [reason...]                disconnect a controller
+  ban <ip|userId> <duration> [reason...] refuse a subject for a duration
+  token issue <slot> <userId> [name]     issue a controller token (-ttl, -cohort)
+  token revoke <slot>                    withdraw the controller token of a slot
+  accepting [on|off]                     show or switch whether new controllers may join
+  room list                              show the room served by this hub
+  config get [key]                       print the effective configuration
+  config set <key> <value>               change a runtime setting
+  recording list|stop|start [label]      manage controller input recordings
+  replay <name>|stop                     replay a recording to the game (-speed)
+
+The admin API is found via -url, HUB_ADMIN_URL, ADMIN_ADDR or ADDR. The
+bearer token comes from -token, HUB_ADMIN_TOKEN, ADMIN_TOKEN or the first
+of API_KEYS.
+`
+
+var errCtlUsage = errors.New("invalid hub ctl usage")
+
+// firstAPIKey returns the first of API_KEYS, which also opens the admin API
+// when ADMIN_TOKEN is not set.
+func firstAPIKey() string {
+	key, _, _ := strings.Cut(os.Getenv("API_KEYS"), ",")
+	return strings.TrimSpace(key)
+}
+
+type ctlClient struct {
+	base  string
+	token string
+	http  *http.Client
+	out   io.Writer
+}
+
+func runCtl(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("hub ctl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	baseURL := fs.String("url", "", "admin API base URL (HUB_ADMIN_URL)")
+	token := fs.String("token", "", "admin bearer token (HUB_ADMIN_TOKEN)")
+	ttl := fs.Duration("ttl", 0, "token lifetime for token issue")
+	cohort := fs.String("cohort", "", "experiment cohort for token issue")
+	speed := fs.Float64("speed", 1, "playback speed for replay")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return errCtlUsage
+	}
+
+	client := &ctlClient{
+		base:  ctlBaseURL(*baseURL),
+		token: firstNonEmptyString(*token, os.Getenv("HUB_ADMIN_TOKEN"), os.Getenv("ADMIN_TOKEN"), firstAPIKey()),
+		http:  &http.Client{Timeout: 10 * time.Second},
+		out:   out,
+	}
+
+	if len(rest) == 0 {
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return errCtlUsage
+	}
+
+	switch cmd := strings.Join(rest[:min(2, len(rest))], " "); {
+	case cmd == "sessions list":
+		return client.sessionsList(ctx)
+	case rest[0] == "kick" && len(rest) >= 2:
+		return client.post(ctx, "/api/admin/kick", map[string]string{
+			"slotId": rest[1],
+			"reason": strings.Join(rest[2:], " "),
+		})
+	case rest[0] == "ban" && len(rest) >= 3:
+		return client.post(ctx, "/api/admin/ban", map[string]string{
+			"subject":  rest[1],
+			"duration": rest[2],
+			"reason":   strings.Join(rest[3:], " "),
+		})
+	case cmd == "token issue" && len(rest) >= 4:
+		body := map[string]string{
+			"slotId": rest[2],
+			"userId": rest[3],
+			"cohort": *cohort,
+		}
+		if len(rest) >= 5 {
+			body["name"] = strings.Join(rest[4:], " ")
+		}
+		if *ttl > 0 {
+			body["ttl"] = ttl.String()
+		}
+		return client.post(ctx, "/api/admin/tokens", body)
+	case cmd == "token revoke" && len(rest) == 3:
+		return client.do(ctx, http.MethodDelete, "/api/admin/tokens?slotId="+url.QueryEscape(rest[2]), nil, nil)
+	case rest[0] == "accepting" && len(rest) == 1:
+		return client.do(ctx, http.MethodGet, "/api/admin/accepting", nil, nil)
+	case cmd == "accepting on" || cmd == "accepting off":
+		return client.do(ctx, http.MethodPut, "/api/admin/accepting", map[string]bool{
+			"accepting": rest[1] == "on",
+		}, nil)
+	case cmd == "room list":
+		return client.roomList(ctx)
+	case cmd == "config get":
+		return client.configGet(ctx, rest[2:])
+	case cmd == "config set" && len(rest) == 4:
+		return client.do(ctx, http.MethodPatch, "/api/admin/config", map[string]string{
+			"key":   rest[2],
+			"value": rest[3],
+		}, nil)
+	case cmd == "recording list":
+		return client.do(ctx, http.MethodGet, "/api/admin/recording", nil, nil)
+	case cmd == "recording start" || cmd == "recording stop":
+		return client.post(ctx, "/api/admin/recording", map[string]string{
+			"action": rest[1],
+			"label":  strings.Join(rest[2:], "-"),
+		})
+	case cmd == "replay stop":
+		return client.do(ctx, http.MethodDelete, "/api/admin/replay", nil, nil)
+	case rest[0] == "replay" && len(rest) == 2:
+		return client.post(ctx, "/api/admin/replay", map[string]any{
+			"name":  rest[1],
+			"speed": *speed,
+		})
+	default:
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return errCtlUsage
+	}
+}
+
+// parseInterspersed lets flags follow positional arguments, as in
+// "hub ctl token issue p1 u1 -ttl 5m".
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// ctlBaseURL picks the admin API address. Listener addresses such as ":9090"
+// are assumed to be reachable on loopback.
+func ctlBaseURL(flagValue string) string {
+	if raw := firstNonEmptyString(flagValue, os.Getenv("HUB_ADMIN_URL")); raw != "" {
+		return strings.TrimRight(raw, "/")
+	}
+	addr := firstNonEmptyString(os.Getenv("ADMIN_ADDR"), os.Getenv("ADDR"), ":8765")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c *ctlClient) post(ctx context.Context, path string, body any) error {
+	return c.do(ctx, http.MethodPost, path, body, nil)
+}
+
+// do calls the admin API. When into is nil the response is pretty printed.
+func (c *ctlClient) do(ctx context.Context, method, path string, body any, into any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s (status %d)", path, apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("%s: status %d", path, resp.StatusCode)
+	}
+
+	if into != nil {
+		return json.Unmarshal(raw, into)
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		fmt.Fprintln(c.out, "ok")
+		return nil
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, raw, "", "  "); err != nil {
+		_, err = c.out.Write(raw)
+		return err
+	}
+	fmt.Fprintln(c.out, strings.TrimSpace(pretty.String()))
+	return nil
+}
+
+func (c *ctlClient) sessionsList(ctx context.Context) error {
+	var resp struct {
+		Sessions []struct {
+			Role        string  `json:"role"`
+			ID          string  `json:"id"`
+			RemoteIP    string  `json:"remoteIp"`
+			UserID      string  `json:"userId"`
+			Cohort      string  `json:"cohort"`
+			ConnectedAt string  `json:"connectedAt"`
+			LastSeen    string  `json:"lastSeen"`
+			RTTMs       float64 `json:"rttMs"`
+			Telemetry   *struct {
+				Battery    *float64 `json:"battery"`
+				Charging   bool     `json:"charging"`
+				Visibility string   `json:"visibility"`
+				Network    string   `json:"network"`
+			} `json:"telemetry"`
+		} `json:"sessions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/sessions", nil, &resp); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROLE\tID\tREMOTE\tUSER\tCOHORT\tCONNECTED\tLAST SEEN\tRTT\tBATTERY\tSCREEN\tNETWORK")
+	for _, s := range resp.Sessions {
+		rtt, battery, screen, network := "-", "-", "-", "-"
+		if s.RTTMs > 0 {
+			rtt = fmt.Sprintf("%.1fms", s.RTTMs)
+		}
+		if t := s.Telemetry; t != nil {
+			if t.Battery != nil {
+				battery = fmt.Sprintf("%.0f%%", *t.Battery*100)
+				if t.Charging {
+					battery += "+"
+				}
+			}
+			screen, network = dash(t.Visibility), dash(t.Network)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Role, dash(s.ID), s.RemoteIP, dash(s.UserID), dash(s.Cohort), s.ConnectedAt, dash(s.LastSeen),
+			rtt, battery, screen, network)
+	}
+	return tw.Flush()
+}
+
+func (c *ctlClient) roomList(ctx context.Context) error {
+	var resp struct {
+		Rooms []struct {
+			ID             string `json:"id"`
+			GameConnected  bool   `json:"gameConnected"`
+			Controllers    int    `json:"controllers"`
+			MaxControllers int    `json:"maxControllers"`
+			Epoch          uint64 `json:"epoch"`
+			Mirrors        int    `json:"mirrors"`
+			MaxGames       int    `json:"maxGames"`
+		} `json:"rooms"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/rooms", nil, &resp); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROOM\tGAME\tMIRRORS\tCONTROLLERS\tEPOCH")
+	for _, r := range resp.Rooms {
+		game := "disconnected"
+		if r.GameConnected {
+			game = "connected"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%d/%d\t%d\n", r.ID, game, r.Mirrors, max(r.MaxGames-1, 0), r.Controllers, r.MaxControllers, r.Epoch)
+	}
+	return tw.Flush()
+}
+
+func (c *ctlClient) configGet(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return c.do(ctx, http.MethodGet, "/api/admin/config", nil, nil)
+	}
+
+	var resp struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/config", nil, &resp); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, ok := resp.Config[key]
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			fmt.Fprintln(c.out, s)
+			continue
+		}
+		fmt.Fprintln(c.out, string(value))
+	}
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/app"
+)
+
+// Exit classes. Each maps to a process exit status so a supervisor can pick
+// a restart policy per cause, e.g. systemd's RestartPreventExitStatus= for
+// configuration errors that a restart cannot fix.
+const (
+	exitFatal    = "fatal"
+	exitConfig   = "config"
+	exitWatchdog = "watchdog"
+	exitBind     = "bind"
+	exitPersona  = "persona"
+)
+
+// defaultExitCodes keeps 1 for unclassified failures. Configuration errors
+// use sysexits' EX_CONFIG, 78: 2 is what the Go runtime exits with on an
+// unrecovered panic, which must not read as a config mistake a restart
+// cannot fix.
+var defaultExitCodes = map[string]int{
+	exitFatal:    1,
+	exitConfig:   78,
+	exitWatchdog: 3,
+	exitBind:     4,
+	exitPersona:  5,
+}
+
+// exitClass sorts a fatal run error into an exit class.
+func exitClass(err error) string {
+	switch {
+	case errors.Is(err, app.ErrWatchdog):
+		return exitWatchdog
+	case errors.Is(err, app.ErrListen):
+		return exitBind
+	case errors.Is(err, app.ErrPersona):
+		return exitPersona
+	default:
+		return exitFatal
+	}
+}
+
+// parseExitCodes applies EXIT_CODES overrides such as "bind=71,persona=75"
+// to the defaults. On error the defaults are returned unchanged.
+func parseExitCodes(raw string) (map[string]int, error) {
+	codes := make(map[string]int, len(defaultExitCodes))
+	for class, code := range defaultExitCodes {
+		codes[class] = code
+	}
+	if strings.TrimSpace(raw) == "" {
+		return codes, nil
+	}
+
+	overrides := make(map[string]int)
+	for _, part := range strings.Split(raw, ",") {
+		class, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		class = strings.ToLower(strings.TrimSpace(class))
+		if !ok {
+			return codes, fmt.Errorf("%q is not class=code", part)
+		}
+		if _, known := defaultExitCodes[class]; !known {
+			return codes, fmt.Errorf("unknown exit class %q", class)
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || code < 1 || code > 125 {
+			return codes, fmt.Errorf("exit code for %s must be 1-125", class)
+		}
+		overrides[class] = code
+	}
+	for class, code := range overrides {
+		codes[class] = code
+	}
+	return codes, nil
+}
@@ -6,6 +6,7 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
@@ -15,7 +16,9 @@ import (
 	"syscall"
 
 	"github.com/aritumn2025/cgb-io-hub/internal/app"
+	"github.com/aritumn2025/cgb-io-hub/internal/buildinfo"
 	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
 )
 
 //go:embed static
@@ -32,14 +35,19 @@ func main() {
 			return
 		}
 
+		codes, codesErr := parseExitCodes(os.Getenv("EXIT_CODES"))
+		if codesErr != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring EXIT_CODES: %v\n", codesErr)
+		}
+
 		var cfgErr configError
 		if errors.As(err, &cfgErr) {
 			fmt.Fprintf(os.Stderr, "config_error: %v\n", cfgErr.Unwrap())
-			os.Exit(2)
+			os.Exit(codes[exitConfig])
 		}
 
 		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
-		os.Exit(1)
+		os.Exit(codes[exitClass(err)])
 	}
 }
 
@@ -56,28 +64,62 @@ func (e configError) Unwrap() error {
 }
 
 func run(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "version" {
+		_, err := fmt.Fprintln(os.Stdout, "hub", buildinfo.Get())
+		return err
+	}
+	if len(args) > 0 && args[0] == "ctl" {
+		return runCtl(ctx, args[1:], os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "conformance" {
+		return runConformance(ctx, args[1:], os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "bot" {
+		return runBot(ctx, args[1:], os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "report" {
+		return runReport(ctx, args[1:], os.Stdout)
+	}
+
 	cfg, err := config.Load(args)
 	if err != nil {
 		return configError{err: err}
 	}
+	if cfg.SmokeTest {
+		return runSmokeTest(ctx, cfg)
+	}
 
-	logger := newLogger()
+	levels := new(slog.LevelVar)
+	_ = levels.UnmarshalText([]byte(cfg.LogLevel))
+	ring := crash.NewRing(os.Stdout, crash.DefaultRingLines)
+	logger := newLogger(ring, levels)
+
+	reporter := crash.NewReporter(cfg.CrashDir, ring)
+	if err := reporter.CaptureRuntime(); err != nil {
+		logger.Warn("crash_capture_failed", "dir", cfg.CrashDir, "err", err.Error())
+	}
+	crash.Install(reporter, logger)
+	defer crash.Recover()
 
 	assets, err := staticAssets()
 	if err != nil {
 		logger.Error("static_embed_error", "err", err.Error())
+		writeCrashReport(logger, reporter, "load static assets: "+err.Error(), nil)
 		return fmt.Errorf("load static assets: %w", err)
 	}
 
-	application, err := app.New(cfg, assets, logger)
+	application, err := app.New(cfg, assets, logger, levels)
 	if err != nil {
 		logger.Error("app_initialise_error", "err", err.Error())
+		writeCrashReport(logger, reporter, "initialise app: "+err.Error(), nil)
 		return fmt.Errorf("initialise app: %w", err)
 	}
+	reporter.SetConfig(application.EffectiveConfig)
 
 	if err := application.Run(ctx); err != nil {
 		if !errors.Is(err, context.Canceled) {
 			logger.Error("application_run_error", "err", err.Error())
+			writeCrashReport(logger, reporter, "run: "+err.Error(), nil)
 		}
 		return err
 	}
@@ -85,8 +127,20 @@ func run(ctx context.Context, args []string) error {
 	return nil
 }
 
-func newLogger() *slog.Logger {
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+func newLogger(out io.Writer, levels *slog.LevelVar) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: levels}))
+}
+
+// writeCrashReport saves a crash report when CRASH_DIR is set. It runs on
+// the way out, so failures are only logged.
+func writeCrashReport(logger *slog.Logger, reporter *crash.Reporter, reason string, stack []byte) {
+	path, err := reporter.Write(reason, stack)
+	switch {
+	case err != nil:
+		logger.Error("crash_report_failed", "err", err.Error())
+	case path != "":
+		logger.Error("crash_report_written", "path", path)
+	}
 }
 
 func staticAssets() (http.FileSystem, error) {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+)
+
+const reportUsage = `usage: hub report [-dir DIR] [-date today|yesterday|YYYY-MM-DD] [-format json|html] [-game-id ID]
+
+Summarises a day of the activity journal for the event wrap-up: matches
+played, unique players, average and top scores, dropped controller sessions
+and PersonaGo failures. The journal is read from -dir or ACTIVITY_DIR and
+the report is written to stdout.
+`
+
+var errReportUsage = errors.New("invalid hub report usage")
+
+func runReport(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("hub report", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dir := fs.String("dir", "", "activity journal directory (ACTIVITY_DIR)")
+	date := fs.String("date", "today", "day to report: today, yesterday or YYYY-MM-DD")
+	format := fs.String("format", "json", "output format: json or html")
+	gameID := fs.String("game-id", "", "game id shown in the report (GAME_ID)")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || (*format != "json" && *format != "html") {
+		fmt.Fprint(os.Stderr, reportUsage)
+		return errReportUsage
+	}
+
+	journalDir := firstNonEmptyString(*dir, os.Getenv("ACTIVITY_DIR"))
+	if journalDir == "" {
+		fmt.Fprint(os.Stderr, reportUsage)
+		return errReportUsage
+	}
+	day, err := activity.ParseDay(*date, time.Now())
+	if err != nil {
+		return err
+	}
+	report, err := activity.Summarize(journalDir, day)
+	if err != nil {
+		return err
+	}
+	report.GameID = firstNonEmptyString(*gameID, os.Getenv("GAME_ID"))
+
+	if *format == "html" {
+		return report.WriteHTML(out)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/app"
+	"github.com/aritumn2025/cgb-io-hub/internal/config"
+)
+
+// runSmokeTest boots the full app for -smoke-test and runs its self-test.
+// It binds an ephemeral loopback port and leaves persisted state, recordings
+// and outbound webhooks alone, so it is safe next to a running hub. The
+// report goes to stdout and logs to stderr.
+func runSmokeTest(ctx context.Context, cfg config.Config) error {
+	cfg.Addr = "127.0.0.1:0"
+	cfg.AdminAddr = ""
+	cfg.StateFile = ""
+	cfg.RecordDir = ""
+	cfg.StorageURL = ""
+	cfg.ActivityDir = ""
+	cfg.CrashDir = ""
+	cfg.AssignmentsWebhookURL = ""
+	cfg.LifecycleWebhookURLs = nil
+	cfg.AlertWebhookURL = ""
+
+	levels := new(slog.LevelVar)
+	_ = levels.UnmarshalText([]byte(cfg.LogLevel))
+	logger := newLogger(os.Stderr, levels)
+
+	assets, err := staticAssets()
+	if err != nil {
+		return fmt.Errorf("load static assets: %w", err)
+	}
+	application, err := app.New(cfg, assets, logger, levels)
+	if err != nil {
+		return fmt.Errorf("initialise app: %w", err)
+	}
+	return application.SmokeTest(ctx, os.Stdout)
+}
@@ -0,0 +1,448 @@
+const tokenStorageKey = "hub.adminToken";
+const overviewPollMs = 5000;
+const refreshDebounceMs = 300;
+const maxEvents = 100;
+const streamRetryMs = 3000;
+
+const elements = {
+  tokenForm: document.querySelector("[data-token-form]"),
+  tokenInput: document.querySelector("[data-token-input]"),
+  stream: document.querySelector("[data-stream]"),
+  game: document.querySelector("[data-game]"),
+  persona: document.querySelector("[data-persona]"),
+  accepting: document.querySelector("[data-accepting]"),
+  match: document.querySelector("[data-match]"),
+  slots: document.querySelector("[data-slots]"),
+  events: document.querySelector("[data-events]"),
+  status: document.querySelector("[data-status]"),
+  output: document.querySelector("[data-output]"),
+  toggleAccepting: document.querySelector("[data-action='toggle-accepting']"),
+  reconcileDryRun: document.querySelector("[data-action='reconcile-dry-run']"),
+  reconcile: document.querySelector("[data-action='reconcile']"),
+};
+
+let adminToken = sessionStorage.getItem(tokenStorageKey) || "";
+let accepting = true;
+let streamController = null;
+let refreshTimer = null;
+
+function setStatus(message, variant = "info") {
+  if (!elements.status) {
+    return;
+  }
+  elements.status.textContent = message;
+  elements.status.classList.remove("is-success", "is-error");
+  if (variant === "success") {
+    elements.status.classList.add("is-success");
+  } else if (variant === "error") {
+    elements.status.classList.add("is-error");
+  }
+}
+
+function showOutput(payload) {
+  if (!elements.output) {
+    return;
+  }
+  elements.output.textContent =
+    payload == null ? "--" : JSON.stringify(payload, null, 2);
+}
+
+function setBadge(element, text, variant) {
+  if (!element) {
+    return;
+  }
+  element.textContent = text;
+  element.classList.remove("is-ok", "is-warn", "is-error");
+  if (variant) {
+    element.classList.add(`is-${variant}`);
+  }
+}
+
+function authHeaders() {
+  return adminToken ? { Authorization: `Bearer ${adminToken}` } : {};
+}
+
+async function sendJSON(url, { method = "GET", body } = {}) {
+  const options = {
+    method,
+    headers: authHeaders(),
+    credentials: "same-origin",
+  };
+  if (body !== undefined) {
+    options.headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(body);
+  }
+  const response = await fetch(url, options);
+  const text = await response.text();
+  let parsed = null;
+  if (text) {
+    try {
+      parsed = JSON.parse(text);
+    } catch {
+      parsed = text;
+    }
+  }
+  if (!response.ok) {
+    const detail =
+      (parsed && parsed.error) || response.statusText || "Unknown error";
+    const error = new Error(detail);
+    error.payload = parsed;
+    error.status = response.status;
+    throw error;
+  }
+  return parsed;
+}
+
+function formatElapsed(ms) {
+  const totalSeconds = Math.floor(ms / 1000);
+  const minutes = Math.floor(totalSeconds / 60);
+  const seconds = totalSeconds % 60;
+  return `${minutes}:${String(seconds).padStart(2, "0")}`;
+}
+
+function formatTime(value) {
+  if (!value) {
+    return "-";
+  }
+  return new Date(value).toLocaleTimeString("ja-JP", { hour12: false });
+}
+
+function formatTelemetry(telemetry) {
+  if (!telemetry) {
+    return "-";
+  }
+  const parts = [];
+  if (typeof telemetry.battery === "number") {
+    const level = `${Math.round(telemetry.battery * 100)}%`;
+    parts.push(telemetry.charging ? `${level}（充電中）` : level);
+  }
+  if (telemetry.network) {
+    parts.push(telemetry.network);
+  }
+  if (telemetry.visibility === "hidden") {
+    parts.push("非表示");
+  }
+  return parts.length > 0 ? parts.join(" / ") : "-";
+}
+
+function slotButton(label, variant, handler) {
+  const button = document.createElement("button");
+  button.type = "button";
+  button.className = variant ? `button ${variant}` : "button";
+  button.textContent = label;
+  button.addEventListener("click", handler);
+  return button;
+}
+
+function renderSlots(slots) {
+  if (!elements.slots) {
+    return;
+  }
+  const rows = (slots || []).map((slot) => {
+    const row = document.createElement("tr");
+    const cells = [
+      slot.slotId.toUpperCase(),
+      slot.userId ? `${slot.name || "-"} (${slot.userId})` : "-",
+      null,
+      typeof slot.rttMs === "number" ? `${slot.rttMs.toFixed(1)} ms` : "-",
+      formatTelemetry(slot.telemetry),
+      formatTime(slot.lastSeen),
+    ];
+    cells.forEach((text, index) => {
+      const cell = document.createElement(index === 0 ? "th" : "td");
+      if (index === 2) {
+        const badge = document.createElement("span");
+        badge.className = "badge";
+        if (!slot.connected) {
+          setBadge(badge, slot.tokenExpiresAt ? "待機中" : "空き");
+        } else if (slot.stale) {
+          setBadge(badge, "応答なし", "warn");
+        } else if (slot.tutorial === "tutorial") {
+          setBadge(badge, "チュートリアル中");
+        } else if (slot.tutorial === "calibrating") {
+          setBadge(badge, "キャリブレーション中");
+        } else if (slot.tutorial === "ready") {
+          setBadge(badge, "準備完了", "ok");
+        } else {
+          setBadge(badge, "接続中", "ok");
+        }
+        cell.append(badge);
+      } else {
+        cell.textContent = text;
+      }
+      row.append(cell);
+    });
+
+    const actions = document.createElement("td");
+    const group = document.createElement("div");
+    group.className = "slot-actions";
+    if (slot.connected) {
+      group.append(slotButton("切断", "danger", () => kickSlot(slot.slotId)));
+    }
+    if (slot.tokenExpiresAt) {
+      group.append(
+        slotButton("トークン失効", "", () => revokeToken(slot.slotId))
+      );
+    }
+    group.append(
+      slotButton("参加コード", "primary", () => issueJoinCode(slot))
+    );
+    actions.append(group);
+    row.append(actions);
+    return row;
+  });
+  elements.slots.replaceChildren(...rows);
+}
+
+function renderOverview(data) {
+  const game = data.game || {};
+  setBadge(
+    elements.game,
+    game.connected ? `接続中 ${game.remoteIp || ""}`.trim() : "未接続",
+    game.connected ? "ok" : "error"
+  );
+
+  const persona = data.persona || {};
+  if (!persona.enabled) {
+    setBadge(elements.persona, "無効");
+  } else if (persona.ok) {
+    setBadge(elements.persona, `正常 ${persona.latencyMs} ms`, "ok");
+  } else {
+    setBadge(elements.persona, `異常: ${persona.error || "-"}`, "error");
+  }
+
+  accepting = Boolean(data.accepting);
+  if (data.shedding) {
+    setBadge(elements.accepting, "負荷制御中", "warn");
+  } else {
+    setBadge(
+      elements.accepting,
+      accepting ? "受付中" : "停止中",
+      accepting ? "ok" : "warn"
+    );
+  }
+  if (elements.toggleAccepting) {
+    elements.toggleAccepting.textContent = accepting
+      ? "受付を停止"
+      : "受付を再開";
+  }
+
+  const match = data.match || {};
+  if (!match.running) {
+    setBadge(elements.match, "待機");
+  } else {
+    setBadge(
+      elements.match,
+      `進行中 ${formatElapsed(match.elapsedMs || 0)}`,
+      match.resultOverdue ? "warn" : "ok"
+    );
+  }
+
+  renderSlots(data.slots);
+}
+
+async function refreshOverview() {
+  try {
+    renderOverview(await sendJSON("/api/admin/overview"));
+  } catch (error) {
+    if (error.status === 401) {
+      setStatus("管理トークンが必要です。", "error");
+      stopStream();
+    }
+  }
+}
+
+function scheduleRefresh() {
+  clearTimeout(refreshTimer);
+  refreshTimer = setTimeout(refreshOverview, refreshDebounceMs);
+}
+
+function appendEvent(event) {
+  if (!elements.events) {
+    return;
+  }
+  const item = document.createElement("li");
+  const who = [event.role, event.id].filter(Boolean).join(":");
+  const fields = event.fields ? JSON.stringify(event.fields) : "";
+  item.textContent = `${formatTime(event.time)} ${event.type} ${who} ${fields}`;
+  elements.events.prepend(item);
+  while (elements.events.childElementCount > maxEvents) {
+    elements.events.lastElementChild.remove();
+  }
+}
+
+function stopStream() {
+  if (streamController) {
+    streamController.abort();
+    streamController = null;
+  }
+  setBadge(elements.stream, "未接続", "error");
+}
+
+// EventSource cannot send an Authorization header, so the NDJSON tail is
+// read through fetch and split into lines by hand.
+async function startStream() {
+  stopStream();
+  const controller = new AbortController();
+  streamController = controller;
+  try {
+    const response = await fetch("/api/admin/events/tail", {
+      headers: authHeaders(),
+      credentials: "same-origin",
+      signal: controller.signal,
+    });
+    if (response.status === 401) {
+      setStatus("管理トークンが必要です。", "error");
+      stopStream();
+      return;
+    }
+    if (!response.ok || !response.body) {
+      throw new Error(response.statusText);
+    }
+    setBadge(elements.stream, "受信中", "ok");
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += value;
+      let newline = buffer.indexOf("\n");
+      while (newline >= 0) {
+        const line = buffer.slice(0, newline).trim();
+        buffer = buffer.slice(newline + 1);
+        newline = buffer.indexOf("\n");
+        if (!line) {
+          continue;
+        }
+        const event = JSON.parse(line);
+        if (event.type === "keepalive") {
+          continue;
+        }
+        appendEvent(event);
+        scheduleRefresh();
+      }
+    }
+  } catch (error) {
+    if (controller.signal.aborted) {
+      return;
+    }
+  }
+  if (streamController === controller) {
+    setBadge(elements.stream, "再接続中", "warn");
+    setTimeout(() => {
+      if (streamController === controller) {
+        startStream();
+      }
+    }, streamRetryMs);
+  }
+}
+
+async function runAction(label, request) {
+  setStatus(`${label}を実行しています…`);
+  try {
+    const data = await request();
+    showOutput(data);
+    setStatus(`${label}を実行しました。`, "success");
+    scheduleRefresh();
+    return data;
+  } catch (error) {
+    showOutput(error.payload || { error: error.message });
+    setStatus(`${label}に失敗しました: ${error.message}`, "error");
+    return null;
+  }
+}
+
+function kickSlot(slotId) {
+  if (!window.confirm(`${slotId.toUpperCase()} を切断します。よろしいですか？`)) {
+    return;
+  }
+  runAction("切断", () =>
+    sendJSON("/api/admin/kick", {
+      method: "POST",
+      body: { slotId, reason: "dashboard" },
+    })
+  );
+}
+
+function revokeToken(slotId) {
+  if (
+    !window.confirm(`${slotId.toUpperCase()} のトークンを失効させます。よろしいですか？`)
+  ) {
+    return;
+  }
+  runAction("トークン失効", () =>
+    sendJSON(`/api/admin/tokens?slotId=${encodeURIComponent(slotId)}`, {
+      method: "DELETE",
+    })
+  );
+}
+
+async function issueJoinCode(slot) {
+  const userId = window.prompt("ユーザー ID", slot.userId || "");
+  if (!userId) {
+    return;
+  }
+  const data = await runAction("参加コード発行", () =>
+    sendJSON("/api/admin/tokens", {
+      method: "POST",
+      body: { slotId: slot.slotId, userId: userId.trim(), joinCode: true },
+    })
+  );
+  if (data && data.joinCode) {
+    setStatus(
+      `参加コード ${data.joinCode}（${location.origin}${data.joinPath}）`,
+      "success"
+    );
+  }
+}
+
+if (elements.toggleAccepting) {
+  elements.toggleAccepting.addEventListener("click", () => {
+    runAction(accepting ? "受付停止" : "受付再開", () =>
+      sendJSON("/api/admin/accepting", {
+        method: "PUT",
+        body: { accepting: !accepting },
+      })
+    );
+  });
+}
+
+if (elements.reconcileDryRun) {
+  elements.reconcileDryRun.addEventListener("click", () => {
+    runAction("ロビー照合", () =>
+      sendJSON("/api/admin/lobby/reconcile?dryRun=true", { method: "POST" })
+    );
+  });
+}
+
+if (elements.reconcile) {
+  elements.reconcile.addEventListener("click", () => {
+    runAction("ロビー修正", () =>
+      sendJSON("/api/admin/lobby/reconcile", { method: "POST" })
+    );
+  });
+}
+
+if (elements.tokenForm) {
+  if (elements.tokenInput) {
+    elements.tokenInput.value = adminToken;
+  }
+  elements.tokenForm.addEventListener("submit", (event) => {
+    event.preventDefault();
+    adminToken = elements.tokenInput ? elements.tokenInput.value.trim() : "";
+    if (adminToken) {
+      sessionStorage.setItem(tokenStorageKey, adminToken);
+    } else {
+      sessionStorage.removeItem(tokenStorageKey);
+    }
+    setStatus("接続しています…");
+    refreshOverview();
+    startStream();
+  });
+}
+
+refreshOverview();
+startStream();
+setInterval(refreshOverview, overviewPollMs);
@@ -0,0 +1,372 @@
+const specUrl = "/api/openapi.json";
+const tokenStorageKey = "hub.apiDocsToken";
+const maxSchemaDepth = 4;
+const streamPreviewMs = 3000;
+const streamPreviewBytes = 64 * 1024;
+
+const elements = {
+  title: document.querySelector("[data-title]"),
+  description: document.querySelector("[data-description]"),
+  tokenForm: document.querySelector("[data-token-form]"),
+  tokenInput: document.querySelector("[data-token-input]"),
+  filter: document.querySelector("[data-filter]"),
+  operations: document.querySelector("[data-operations]"),
+};
+
+const methodOrder = ["get", "post", "put", "patch", "delete"];
+
+let spec = null;
+let token = sessionStorage.getItem(tokenStorageKey) || "";
+
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs)) {
+    if (key === "class") {
+      node.className = value;
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    if (child == null) {
+      continue;
+    }
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+function refName(ref) {
+  return ref.slice(ref.lastIndexOf("/") + 1);
+}
+
+function resolve(schema) {
+  if (schema && schema.$ref) {
+    return spec.components.schemas[refName(schema.$ref)] || {};
+  }
+  return schema || {};
+}
+
+// describeSchema renders a schema as an indented, TypeScript-like outline.
+function describeSchema(schema, depth = 0, seen = new Set()) {
+  if (!schema) {
+    return "any";
+  }
+  const pad = "  ".repeat(depth);
+  if (schema.$ref) {
+    const name = refName(schema.$ref);
+    if (seen.has(name) || depth >= maxSchemaDepth) {
+      return name;
+    }
+    const next = new Set(seen).add(name);
+    return `${name} ${describeSchema(resolve(schema), depth, next)}`;
+  }
+  if (schema.allOf) {
+    const inner = schema.allOf.map((s) => describeSchema(s, depth, seen)).join(" & ");
+    return schema.nullable ? `${inner} | null` : inner;
+  }
+  let text;
+  switch (schema.type) {
+    case "object": {
+      const props = Object.entries(schema.properties || {});
+      if (props.length === 0) {
+        text = schema.additionalProperties
+          ? `{ [key]: ${describeSchema(schema.additionalProperties, depth + 1, seen)} }`
+          : "object";
+        break;
+      }
+      const required = new Set(schema.required || []);
+      const lines = props.map(
+        ([name, prop]) =>
+          `${pad}  ${name}${required.has(name) ? "" : "?"}: ${describeSchema(prop, depth + 1, seen)}`,
+      );
+      text = `{\n${lines.join("\n")}\n${pad}}`;
+      break;
+    }
+    case "array":
+      text = `${describeSchema(schema.items, depth, seen)}[]`;
+      break;
+    case undefined:
+      text = "any";
+      break;
+    default:
+      text = schema.format ? `${schema.type} (${schema.format})` : schema.type;
+  }
+  return schema.nullable ? `${text} | null` : text;
+}
+
+// exampleOf builds a placeholder value for a request body.
+function exampleOf(schema, depth = 0) {
+  schema = resolve(schema);
+  if (schema.allOf) {
+    return exampleOf(schema.allOf[0], depth);
+  }
+  if (depth > maxSchemaDepth) {
+    return null;
+  }
+  switch (schema.type) {
+    case "object": {
+      const out = {};
+      for (const [name, prop] of Object.entries(schema.properties || {})) {
+        out[name] = exampleOf(prop, depth + 1);
+      }
+      return out;
+    }
+    case "array":
+      return [];
+    case "boolean":
+      return false;
+    case "integer":
+    case "number":
+      return 0;
+    case "string":
+      return "";
+    default:
+      return null;
+  }
+}
+
+function securityLabel(security) {
+  if (!security || security.length === 0) {
+    return "不要";
+  }
+  return security
+    .map((req) => Object.keys(req).join(" + ") || "なし")
+    .join(" / ");
+}
+
+function renderParams(params) {
+  if (!params || params.length === 0) {
+    return null;
+  }
+  const rows = params.map((p) =>
+    el(
+      "tr",
+      {},
+      el("td", {}, el("code", {}, p.name), p.required ? " *" : ""),
+      el("td", {}, p.in),
+      el("td", {}, (p.schema && p.schema.type) || ""),
+      el("td", {}, p.description || ""),
+    ),
+  );
+  return el(
+    "div",
+    { class: "table-wrapper" },
+    el(
+      "table",
+      { class: "docs-table" },
+      el("thead", {}, el("tr", {}, el("th", {}, "名前"), el("th", {}, "場所"), el("th", {}, "型"), el("th", {}, "説明"))),
+      el("tbody", {}, ...rows),
+    ),
+  );
+}
+
+function renderResponses(responses) {
+  const list = el("dl", { class: "docs-responses" });
+  for (const [status, response] of Object.entries(responses || {}).sort()) {
+    list.append(el("dt", {}, `${status} ${response.description || ""}`));
+    for (const [media, content] of Object.entries(response.content || {})) {
+      const schema =
+        media === "application/json" ? describeSchema(content.schema) : "";
+      list.append(el("dd", {}, el("span", { class: "docs-media" }, media), schema ? el("pre", {}, schema) : null));
+    }
+  }
+  return list;
+}
+
+function isStream(op) {
+  const ok = op.responses && op.responses["200"];
+  const media = Object.keys((ok && ok.content) || {});
+  return media.includes("text/event-stream") || media.includes("application/x-ndjson");
+}
+
+async function readPreview(response) {
+  const reader = response.body.getReader();
+  const decoder = new TextDecoder();
+  let text = "";
+  const deadline = Date.now() + streamPreviewMs;
+  while (Date.now() < deadline && text.length < streamPreviewBytes) {
+    const timeout = new Promise((resolveTimeout) =>
+      setTimeout(() => resolveTimeout({ done: true, timedOut: true }), deadline - Date.now()),
+    );
+    const chunk = await Promise.race([reader.read(), timeout]);
+    if (chunk.done) {
+      break;
+    }
+    text += decoder.decode(chunk.value, { stream: true });
+  }
+  reader.cancel().catch(() => {});
+  return `${text}\n…（${streamPreviewMs / 1000} 秒で打ち切り）`;
+}
+
+function renderTry(method, path, op) {
+  const form = el("form", { class: "docs-try" });
+  const inputs = [];
+  for (const p of op.parameters || []) {
+    const input = el("input", { class: "token-input", name: p.name, placeholder: p.description || p.name });
+    inputs.push({ param: p, input });
+    form.append(el("label", {}, el("span", {}, `${p.name} (${p.in})`), input));
+  }
+  let body = null;
+  if (op.requestBody) {
+    const content = op.requestBody.content["application/json"];
+    body = el("textarea", { class: "docs-body", rows: "6", spellcheck: "false" });
+    body.value = JSON.stringify(exampleOf(content && content.schema), null, 2);
+    form.append(el("label", {}, el("span", {}, "body (JSON)"), body));
+  }
+  const output = el("pre", { class: "output" }, "--");
+  form.append(el("div", { class: "actions" }, el("button", { type: "submit", class: "button primary" }, "実行")), output);
+
+  form.addEventListener("submit", async (event) => {
+    event.preventDefault();
+    let url = path;
+    const query = new URLSearchParams();
+    for (const { param, input } of inputs) {
+      const value = input.value.trim();
+      if (value === "") {
+        continue;
+      }
+      if (param.in === "path") {
+        url = url.replace(`{${param.name}}`, encodeURIComponent(value));
+      } else if (param.in === "query") {
+        query.set(param.name, value);
+      }
+    }
+    if (query.toString()) {
+      url += `?${query}`;
+    }
+    const headers = {};
+    if (token) {
+      headers.Authorization = `Bearer ${token}`;
+    }
+    const init = { method: method.toUpperCase(), headers };
+    if (body && body.value.trim() !== "") {
+      headers["Content-Type"] = "application/json";
+      init.body = body.value;
+    }
+    output.textContent = `${init.method} ${url} …`;
+    try {
+      const response = await fetch(url, init);
+      const type = response.headers.get("Content-Type") || "";
+      let text;
+      if (isStream(op) && response.ok) {
+        text = await readPreview(response);
+      } else if (type.includes("application/json")) {
+        text = JSON.stringify(await response.json(), null, 2);
+      } else if (type.startsWith("image/")) {
+        text = `${type}, ${(await response.blob()).size} bytes`;
+      } else {
+        text = await response.text();
+      }
+      output.textContent = `${response.status} ${response.statusText}\n${text}`;
+    } catch (err) {
+      output.textContent = `request failed: ${err.message}`;
+    }
+  });
+  return form;
+}
+
+function renderOperation(path, method, op) {
+  const details = el(
+    "details",
+    { class: "docs-op", "data-search": `${method} ${path} ${op.summary || ""}`.toLowerCase() },
+    el(
+      "summary",
+      {},
+      el("span", { class: `docs-method docs-method-${method}` }, method.toUpperCase()),
+      el("code", {}, path),
+      el("span", { class: "docs-summary" }, op.summary || ""),
+    ),
+  );
+  details.append(
+    el("p", { class: "panel-description" }, `operationId: ${op.operationId || "-"} ・ 認証: ${securityLabel(op.security)}`),
+  );
+  const params = renderParams(op.parameters);
+  if (params) {
+    details.append(params);
+  }
+  if (op.requestBody) {
+    const content = op.requestBody.content["application/json"];
+    details.append(
+      el("h4", {}, op.requestBody.required ? "リクエスト" : "リクエスト（省略可）"),
+      el("pre", {}, describeSchema(content && content.schema)),
+    );
+  }
+  details.append(el("h4", {}, "レスポンス"), renderResponses(op.responses), renderTry(method, path, op));
+  return details;
+}
+
+function render() {
+  elements.title.textContent = spec.info.title;
+  document.title = spec.info.title;
+  elements.description.textContent = spec.info.description || "";
+
+  const byTag = new Map((spec.tags || []).map((tag) => [tag.name, { tag, ops: [] }]));
+  for (const [path, item] of Object.entries(spec.paths).sort()) {
+    for (const method of methodOrder) {
+      const op = item[method];
+      if (!op) {
+        continue;
+      }
+      const name = (op.tags && op.tags[0]) || "other";
+      if (!byTag.has(name)) {
+        byTag.set(name, { tag: { name }, ops: [] });
+      }
+      byTag.get(name).ops.push(renderOperation(path, method, op));
+    }
+  }
+
+  elements.operations.replaceChildren();
+  for (const { tag, ops } of byTag.values()) {
+    if (ops.length === 0) {
+      continue;
+    }
+    elements.operations.append(
+      el(
+        "section",
+        { class: "panel docs-tag" },
+        el("h2", {}, tag.name),
+        tag.description ? el("p", { class: "panel-description" }, tag.description) : null,
+        ...ops,
+      ),
+    );
+  }
+}
+
+function applyFilter() {
+  const needle = elements.filter.value.trim().toLowerCase();
+  for (const op of elements.operations.querySelectorAll(".docs-op")) {
+    op.hidden = needle !== "" && !op.dataset.search.includes(needle);
+  }
+}
+
+elements.tokenInput.value = token;
+elements.tokenForm.addEventListener("submit", (event) => {
+  event.preventDefault();
+  token = elements.tokenInput.value.trim();
+  if (token) {
+    sessionStorage.setItem(tokenStorageKey, token);
+  } else {
+    sessionStorage.removeItem(tokenStorageKey);
+  }
+});
+elements.filter.addEventListener("input", applyFilter);
+
+fetch(specUrl)
+  .then((response) => {
+    if (!response.ok) {
+      throw new Error(`${response.status} ${response.statusText}`);
+    }
+    return response.json();
+  })
+  .then((doc) => {
+    spec = doc;
+    render();
+  })
+  .catch((err) => {
+    elements.description.replaceChildren(
+      `API 定義を読み込めませんでした（${err.message}）。`,
+      el("a", { href: specUrl }, specUrl),
+      " を直接参照してください。",
+    );
+  });
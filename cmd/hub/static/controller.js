@@ -1,7 +1,10 @@
 const THEME_STORAGE_KEY = "stg48:theme";
 const INPUT_MODE_STORAGE_KEY = "stg48:input-mode";
 const SESSION_STORAGE_KEY = "stg48:controller-session";
+const TUTORIAL_STORAGE_KEY = "stg48:tutorial-done";
+const ORIENTATION_SAMPLE_TIMEOUT_MS = 3000;
 const TOKEN_REFRESH_MARGIN_MS = 10000;
+const TOKEN_REFRESH_REPLY_TIMEOUT_MS = 5000;
 const INPUT_MODES = {
   STICK: "stick",
   DPAD: "dpad",
@@ -46,6 +49,7 @@ document.addEventListener("DOMContentLoaded", () => {
   const themeToggle = document.querySelector("[data-theme-toggle]");
   const controlToggle = document.querySelector("[data-control-toggle]");
   const slotHelperContainer = document.querySelector("[data-slot-helper]");
+  const countdownEl = document.querySelector("[data-countdown]");
   const slotHelperNote = document.querySelector("[data-slot-note]");
   const slotHelperRefresh = document.querySelector("[data-slot-refresh]");
 
@@ -67,6 +71,11 @@ document.addEventListener("DOMContentLoaded", () => {
 
   const status = createStatusManager(statusEl, lampEl);
 
+  const joinedSession = readJoinSession();
+  if (joinedSession) {
+    persistSession(joinedSession);
+  }
+
   let activeSession = readStoredSession();
   if (activeSession && isSessionExpired(activeSession)) {
     activeSession = null;
@@ -78,6 +87,7 @@ document.addEventListener("DOMContentLoaded", () => {
     ? activeSession.slotId
     : fallbackControllerId || null;
   let refreshTimer = null;
+  let slotMeta = {};
 
   const updateCenterCursorButtonState = () => {
     if (!centerCursorButton) {
@@ -90,10 +100,28 @@ document.addEventListener("DOMContentLoaded", () => {
     getSession: () => activeSession,
     getControllerId: () => controllerId,
     updateStatus: status.set,
+    // 4001: the token ran out, e.g. while the phone slept through the
+    // scheduled refresh. Renew it over HTTP; applySession reconnects.
+    renewToken: async () => {
+      if (!activeSession || !activeSession.userId) {
+        return false;
+      }
+      try {
+        const next = await requestControllerSession(activeSession.userId);
+        applySession(next, { persist: true, announce: false });
+        return true;
+      } catch (error) {
+        console.warn("[controller] failed to renew expired token:", error);
+        return false;
+      }
+    },
   });
   const state = createInputState(() => controllerId, connection);
 
   connection.onOpen(() => state.send(true));
+  initTelemetry(connection);
+  const tutorial = initTutorial(connection, state);
+  initCalibration(connection, state, tutorial);
 
   const stickControls = initStick(stick, thumb, state);
   const dpadControls = initDpad(dpad, state);
@@ -134,7 +162,10 @@ document.addEventListener("DOMContentLoaded", () => {
     const displaySource =
       activeSession || (controllerId ? { userId: controllerId } : null);
     if (userDisplayEl) {
-      userDisplayEl.textContent = formatUserDisplay(displaySource);
+      const station = formatSlotMeta(slotMeta);
+      userDisplayEl.textContent = station
+        ? `${formatUserDisplay(displaySource)} ・ ${station}`
+        : formatUserDisplay(displaySource);
     }
   };
 
@@ -152,20 +183,106 @@ document.addEventListener("DOMContentLoaded", () => {
         ? dueTime
         : activeSession.expiresAt - TOKEN_REFRESH_MARGIN_MS;
     const delay = Math.max(targetTime - now, 1000);
-    refreshTimer = window.setTimeout(async () => {
+    refreshTimer = window.setTimeout(() => {
       if (!activeSession || !activeSession.userId) {
         return;
       }
-      try {
-        const next = await requestControllerSession(activeSession.userId);
-        applySession(next, { persist: true, announce: false });
-      } catch (error) {
-        console.warn("[controller] failed to refresh session token:", error);
-        scheduleRefresh(Date.now() + 15000);
+      // Prefer renewing over the open socket; it keeps the connection up.
+      if (connection.send(JSON.stringify({ type: "refresh" }))) {
+        refreshTimer = window.setTimeout(
+          refreshOverHTTP,
+          TOKEN_REFRESH_REPLY_TIMEOUT_MS
+        );
+        return;
       }
+      refreshOverHTTP();
     }, delay);
   };
 
+  const refreshOverHTTP = async () => {
+    refreshTimer = null;
+    if (!activeSession || !activeSession.userId) {
+      return;
+    }
+    try {
+      const next = await requestControllerSession(activeSession.userId);
+      applySession(next, { persist: true, announce: false });
+    } catch (error) {
+      console.warn("[controller] failed to refresh session token:", error);
+      scheduleRefresh(Date.now() + 15000);
+    }
+  };
+
+  connection.onMessage((message) => {
+    if (!activeSession) {
+      return;
+    }
+    if (message.type === "token" && typeof message.token === "string") {
+      const expiresAt = Date.parse(message.expiresAt);
+      activeSession = {
+        ...activeSession,
+        token: message.token,
+        expiresAt: Number.isNaN(expiresAt) ? activeSession.expiresAt : expiresAt,
+      };
+      persistSession(activeSession);
+      scheduleRefresh();
+    } else if (message.type === "refresh_failed") {
+      console.warn("[controller] socket token refresh failed:", message.reason);
+      if (refreshTimer) {
+        window.clearTimeout(refreshTimer);
+      }
+      refreshOverHTTP();
+    }
+  });
+
+  // Operator-set slot metadata: tint the controller with the slot colour and
+  // name the station so the player matches what the game shows.
+  connection.onMessage((message) => {
+    if (message.type !== "registered" && message.type !== "slot_meta") {
+      return;
+    }
+    slotMeta =
+      message.meta && typeof message.meta === "object" ? message.meta : {};
+    const color = typeof slotMeta.color === "string" ? slotMeta.color : "";
+    if (color && CSS.supports("color", color)) {
+      document.documentElement.style.setProperty("--slot-color", color);
+    } else {
+      document.documentElement.style.removeProperty("--slot-color");
+    }
+    updateInfoPanel();
+  });
+
+  // match_start from /api/game/start: count down to startAt together with
+  // the game screen. startAt is in hub time, so it is measured against the
+  // frame's own timestamp rather than this phone's clock.
+  let countdownTimer = null;
+  connection.onMessage((message) => {
+    if (message.type !== "match_start" || !countdownEl) {
+      return;
+    }
+    const remaining = Number(message.startAt) - Number(message.timestamp);
+    if (!Number.isFinite(remaining) || remaining <= 0) {
+      return;
+    }
+    const endsAt = Date.now() + remaining;
+    window.clearInterval(countdownTimer);
+    const tick = () => {
+      const left = endsAt - Date.now();
+      if (left > 0) {
+        countdownEl.textContent = String(Math.ceil(left / 1000));
+        return;
+      }
+      countdownEl.textContent = "START!";
+      window.clearInterval(countdownTimer);
+      countdownTimer = window.setTimeout(() => {
+        countdownEl.hidden = true;
+      }, 800);
+    };
+    countdownEl.hidden = false;
+    tick();
+    countdownTimer = window.setInterval(tick, 100);
+  });
+
   const applySession = (session, { persist = true, announce = true } = {}) => {
     activeSession = session;
     controllerId = session ? session.slotId : fallbackControllerId || null;
@@ -192,6 +309,10 @@ document.addEventListener("DOMContentLoaded", () => {
   };
 
   const resetSession = ({ showForm = true } = {}) => {
+    if (controllerId) {
+      // Release the slot right away instead of waiting for the hub to notice.
+      connection.send(JSON.stringify({ type: "unregister", id: controllerId }));
+    }
     if (refreshTimer) {
       window.clearTimeout(refreshTimer);
       refreshTimer = null;
@@ -283,6 +404,161 @@ function initInfoMenu(menu, toggle) {
   });
 }
 
+// 初めての端末ではスティック（十字キー）と A ボタンを一度ずつ操作した時点で
+// チュートリアル完了としてハブへ通知する。完了済みの端末は接続のたびに skip を送る。
+function initTutorial(connection, state) {
+  let done = readTutorialDone();
+  let moved = false;
+  let pressed = false;
+
+  const step = (name) => {
+    connection.send(JSON.stringify({ type: "tutorial", step: name }));
+  };
+
+  const finish = () => {
+    if (done) {
+      return;
+    }
+    done = true;
+    persistTutorialDone();
+    step("done");
+  };
+
+  connection.onOpen(() => step(done ? "skip" : "start"));
+
+  state.onChange(() => {
+    if (done) {
+      return;
+    }
+    moved = moved || state.axes.x !== 0 || state.axes.y !== 0;
+    pressed = pressed || state.btn.a;
+    if (moved && pressed) {
+      finish();
+    }
+  });
+
+  return { step, finish, isDone: () => done };
+}
+
+// Game からの calibrate 要求に答える。stick は入力の中心と遊びを、gyro は
+// 端末の傾きの現在値を基準として返し、未対応の種類は ok:false で返す。
+// チュートリアル中ならキャリブレーションの成功をもって完了とする。
+function initCalibration(connection, state, tutorial) {
+  const measure = {
+    stick: async () => ({
+      center: { x: state.axes.x, y: state.axes.y },
+      deadzone: DEADZONE,
+    }),
+    gyro: (timeoutMs) => readOrientation(timeoutMs),
+  };
+
+  const reply = (calibrationId, result) => {
+    connection.send(
+      JSON.stringify({ type: "calibration", calibrationId, ...result })
+    );
+  };
+
+  connection.onMessage(async (message) => {
+    if (
+      message.type !== "calibrate" ||
+      typeof message.calibrationId !== "string"
+    ) {
+      return;
+    }
+    const run = measure[message.kind];
+    if (!run) {
+      reply(message.calibrationId, { ok: false, error: "unsupported_kind" });
+      return;
+    }
+    const inTutorial = !tutorial.isDone();
+    if (inTutorial) {
+      tutorial.step("calibrate");
+    }
+    const timeoutMs =
+      Number(message.timeoutMs) > 0
+        ? Math.min(Number(message.timeoutMs), ORIENTATION_SAMPLE_TIMEOUT_MS)
+        : ORIENTATION_SAMPLE_TIMEOUT_MS;
+    try {
+      const data = await run(timeoutMs);
+      reply(message.calibrationId, { ok: true, data });
+      if (inTutorial) {
+        tutorial.finish();
+      }
+    } catch (error) {
+      reply(message.calibrationId, {
+        ok: false,
+        error: error instanceof Error ? error.message : "failed",
+      });
+    }
+  });
+}
+
+// deviceorientation の最初の値を待って返す。非対応や許可のない端末では
+// timeoutMs 以内に値が来ないため失敗にする。
+function readOrientation(timeoutMs) {
+  return new Promise((resolve, reject) => {
+    if (typeof window.DeviceOrientationEvent === "undefined") {
+      reject(new Error("unsupported"));
+      return;
+    }
+    let timer = null;
+    const handler = (event) => {
+      if (event.beta === null || event.gamma === null) {
+        return;
+      }
+      window.clearTimeout(timer);
+      window.removeEventListener("deviceorientation", handler);
+      resolve({ offset: [event.alpha || 0, event.beta, event.gamma] });
+    };
+    timer = window.setTimeout(() => {
+      window.removeEventListener("deviceorientation", handler);
+      reject(new Error("no_reading"));
+    }, timeoutMs);
+    window.addEventListener("deviceorientation", handler);
+  });
+}
+
+// 端末状態（バッテリー残量・画面表示状態・回線種別）をハブへ通知する。
+// 非対応ブラウザでは取得できた項目だけを送る。
+function initTelemetry(connection) {
+  let battery = null;
+  const network = navigator.connection || null;
+
+  const report = () => {
+    const message = {
+      type: "telemetry",
+      visibility: document.visibilityState,
+    };
+    if (battery) {
+      message.battery = Math.round(battery.level * 100) / 100;
+      message.charging = battery.charging;
+    }
+    if (network && typeof network.effectiveType === "string") {
+      message.network = network.effectiveType;
+    }
+    connection.send(JSON.stringify(message));
+  };
+
+  connection.onOpen(report);
+  document.addEventListener("visibilitychange", report);
+  if (network && typeof network.addEventListener === "function") {
+    network.addEventListener("change", report);
+  }
+  if (typeof navigator.getBattery === "function") {
+    navigator
+      .getBattery()
+      .then((manager) => {
+        battery = manager;
+        manager.addEventListener("levelchange", report);
+        manager.addEventListener("chargingchange", report);
+        report();
+      })
+      .catch(() => {
+        // バッテリー情報は任意
+      });
+  }
+}
+
 function createStatusManager(statusEl, lampEl) {
   const set = (text) => {
     statusEl.textContent = text;
@@ -301,16 +577,52 @@ function createStatusManager(statusEl, lampEl) {
   return { set };
 }
 
-function createConnection({ getSession, getControllerId, updateStatus }) {
+// Hub close codes after which reconnecting would only repeat the rejection:
+// 4004 replaced (another tab took the slot), 4006 kicked, 4007 banned.
+const terminalCloseCodes = new Map([
+  [4004, "別の端末で接続されました"],
+  [4006, "運営により切断されました"],
+  [4007, "接続が制限されています"],
+]);
+
+// 4001 token_expired is retried only with a renewed token, and at most once
+// per this interval, so a token the hub keeps rejecting does not loop.
+const tokenExpiredCloseCode = 4001;
+const tokenRenewInterval = 30000;
+
+// WebSocket が一度も開かずにこの回数失敗したら、ロングポーリングへ切り替える
+// （キャプティブポータル等で WebSocket が通らない会場向け）。
+const wsFailuresBeforePoll = 2;
+
+function createConnection({
+  getSession,
+  getControllerId,
+  updateStatus,
+  renewToken,
+}) {
   let ws = null;
+  let poll = null;
   let backoff = 800;
   let reconnectTimer = null;
   const openCallbacks = new Set();
+  const messageCallbacks = new Set();
   let manualClose = false;
+  let epoch = 0;
+  let wsFailures = 0;
+  let lastRenewal = 0;
+  let transport =
+    new URLSearchParams(window.location.search).get("transport") === "poll"
+      ? "poll"
+      : "ws";
+
+  const cohortQuery = () => {
+    const cohort = new URLSearchParams(window.location.search).get("cohort");
+    return cohort ? `?cohort=${encodeURIComponent(cohort)}` : "";
+  };
 
   const connectionURL = () => {
     const proto = window.location.protocol === "https:" ? "wss" : "ws";
-    return `${proto}://${window.location.host}/ws`;
+    return `${proto}://${window.location.host}/ws${cohortQuery()}`;
   };
 
   const shouldConnect = () => {
@@ -319,6 +631,17 @@ function createConnection({ getSession, getControllerId, updateStatus }) {
     return Boolean((session && session.token) || id);
   };
 
+  const registerPayload = () => {
+    const session = typeof getSession === "function" ? getSession() : null;
+    const controllerId =
+      typeof getControllerId === "function" ? getControllerId() : null;
+    return session && session.token
+      ? { role: "controller", token: session.token, protocolVersion: 1 }
+      : controllerId
+      ? { role: "controller", id: controllerId, protocolVersion: 1 }
+      : null;
+  };
+
   const scheduleReconnect = () => {
     if (reconnectTimer) {
       window.clearTimeout(reconnectTimer);
@@ -334,68 +657,260 @@ function createConnection({ getSession, getControllerId, updateStatus }) {
     }, wait);
   };
 
-  const connect = () => {
-    if (ws) {
+  const handleOpen = () => {
+    backoff = 800;
+    if (reconnectTimer) {
+      window.clearTimeout(reconnectTimer);
+      reconnectTimer = null;
+    }
+    updateStatus("接続済み");
+    openCallbacks.forEach((callback) => callback());
+  };
+
+  const handleMessage = (message) => {
+    if (!message || typeof message.type !== "string") {
+      return;
+    }
+    if (message.type === "epoch" && Number.isInteger(message.epoch)) {
+      epoch = message.epoch;
+    }
+    messageCallbacks.forEach((callback) => callback(message));
+  };
+
+  const handleClose = (code, reason) => {
+    if (manualClose) {
+      manualClose = false;
+      updateStatus("未接続");
+      return;
+    }
+    const terminal = terminalCloseCodes.get(code);
+    if (terminal) {
+      console.warn("[controller] closed by hub:", code, reason);
+      updateStatus(`未接続（${terminal}）`);
+      return;
+    }
+    if (code === tokenExpiredCloseCode) {
+      const expired = "未接続（トークンの有効期限が切れました）";
+      if (
+        typeof renewToken !== "function" ||
+        Date.now() - lastRenewal < tokenRenewInterval
+      ) {
+        console.warn("[controller] closed by hub:", code, reason);
+        updateStatus(expired);
+        return;
+      }
+      lastRenewal = Date.now();
+      updateStatus("未接続（再認証中）");
+      renewToken().then((renewed) => {
+        if (!renewed) {
+          updateStatus(expired);
+        }
+      });
+      return;
+    }
+    updateStatus("未接続（再試行中）");
+    scheduleReconnect();
+  };
+
+  const connectWebSocket = () => {
+    const socket = new WebSocket(connectionURL());
+    let opened = false;
+    ws = socket;
+
+    socket.onopen = () => {
+      opened = true;
+      wsFailures = 0;
+      const payload = registerPayload();
+      if (!payload) {
+        updateStatus("未接続");
+        return;
+      }
+      socket.send(JSON.stringify(payload));
+      handleOpen();
+    };
+
+    socket.onmessage = (event) => {
+      let message = null;
       try {
-        ws.close();
+        message = JSON.parse(event.data);
+      } catch (_) {
+        return;
+      }
+      handleMessage(message);
+    };
+
+    socket.onclose = (event) => {
+      if (!opened && !manualClose) {
+        wsFailures += 1;
+        if (wsFailures >= wsFailuresBeforePoll) {
+          console.warn("[controller] WebSocket unavailable, using long-poll");
+          transport = "poll";
+        }
+      }
+      handleClose(event.code, event.reason);
+    };
+
+    socket.onerror = () => {
+      try {
+        socket.close();
       } catch (_) {
         // noop
       }
-    }
+    };
+  };
 
-    if (!shouldConnect()) {
+  // ロングポーリング: POST /ws/poll で登録し、GET で受信、POST で送信する。
+  const connectPoll = () => {
+    const payload = registerPayload();
+    if (!payload) {
       updateStatus("未接続");
       return;
     }
-    updateStatus("接続中…");
-    ws = new WebSocket(connectionURL());
+    const headers = { "Content-Type": "application/json" };
+    if (payload.token) {
+      headers.Authorization = `Bearer ${payload.token}`;
+    }
+    const session = { sid: null, closed: false, outbox: [], sending: false };
+    poll = session;
 
-    ws.onopen = () => {
-      backoff = 800;
-      if (reconnectTimer) {
-        window.clearTimeout(reconnectTimer);
-        reconnectTimer = null;
-      }
-      const session = typeof getSession === "function" ? getSession() : null;
-      const controllerId =
-        typeof getControllerId === "function" ? getControllerId() : null;
-      const payload =
-        session && session.token
-          ? { role: "controller", token: session.token }
-          : controllerId
-          ? { role: "controller", id: controllerId }
-          : null;
+    const sessionURL = () => `/ws/poll?sid=${encodeURIComponent(session.sid)}`;
 
-      if (!payload) {
-        updateStatus("未接続");
+    const finish = (code, reason) => {
+      if (session.closed) {
         return;
       }
+      session.closed = true;
+      if (poll === session) {
+        poll = null;
+      }
+      handleClose(code, reason);
+    };
 
-      updateStatus("接続済み");
-      ws.send(JSON.stringify(payload));
-      openCallbacks.forEach((callback) => callback());
+    // 受信したフレームを配り、切断通知があれば true を返す。
+    const deliver = (body) => {
+      const messages = body && Array.isArray(body.messages) ? body.messages : [];
+      messages.forEach(handleMessage);
+      if (body && body.closed) {
+        finish(body.closed.code, body.closed.reason);
+        return true;
+      }
+      return false;
     };
 
-    ws.onclose = () => {
-      if (manualClose) {
-        manualClose = false;
-        updateStatus("未接続");
+    const receive = async () => {
+      while (!session.closed) {
+        let body = null;
+        try {
+          const res = await fetch(sessionURL(), { headers, cache: "no-store" });
+          body = await res.json();
+        } catch (_) {
+          finish(1006, "");
+          return;
+        }
+        if (deliver(body)) {
+          return;
+        }
+      }
+    };
+
+    session.flush = async () => {
+      if (session.sending || session.closed || !session.sid) {
         return;
       }
-      updateStatus("未接続（再試行中）");
-      scheduleReconnect();
+      while (session.outbox.length > 0 && !session.closed) {
+        const batch = session.outbox.splice(0, session.outbox.length);
+        session.sending = true;
+        try {
+          const res = await fetch(sessionURL(), {
+            method: "POST",
+            headers,
+            body: `[${batch.join(",")}]`,
+          });
+          if (res.status === 404 || res.status === 410) {
+            deliver(await res.json());
+          }
+        } catch (_) {
+          // 受信側の切断検知に任せる
+        } finally {
+          session.sending = false;
+        }
+      }
     };
 
-    ws.onerror = () => {
+    session.stop = () => {
+      session.closed = true;
+      if (session.sid) {
+        fetch(sessionURL(), { method: "DELETE", headers, keepalive: true }).catch(
+          () => {}
+        );
+      }
+    };
+
+    fetch(`/ws/poll${cohortQuery()}`, {
+      method: "POST",
+      headers,
+      body: JSON.stringify(payload),
+    })
+      .then((res) => res.json().then((body) => ({ ok: res.ok, body })))
+      .then(({ ok, body }) => {
+        if (ok && body && body.sid) {
+          session.sid = body.sid;
+        }
+        if (session.closed) {
+          session.stop();
+          return;
+        }
+        if (!session.sid) {
+          if (!deliver(body)) {
+            finish(1006, "");
+          }
+          return;
+        }
+        handleOpen();
+        session.flush();
+        receive();
+      })
+      .catch(() => finish(1006, ""));
+  };
+
+  const stopCurrent = () => {
+    if (ws) {
       try {
         ws.close();
       } catch (_) {
         // noop
       }
-    };
+    }
+    if (poll) {
+      poll.stop();
+      poll = null;
+    }
+  };
+
+  const connect = () => {
+    stopCurrent();
+
+    if (!shouldConnect()) {
+      updateStatus("未接続");
+      return;
+    }
+    updateStatus("接続中…");
+    if (transport === "poll") {
+      connectPoll();
+      return;
+    }
+    connectWebSocket();
   };
 
   const send = (serialized) => {
+    if (poll) {
+      if (!poll.sid || poll.closed) {
+        return false;
+      }
+      poll.outbox.push(serialized);
+      poll.flush();
+      return true;
+    }
     if (!ws || ws.readyState !== WebSocket.OPEN) {
       return false;
     }
@@ -417,19 +932,32 @@ function createConnection({ getSession, getControllerId, updateStatus }) {
       }
       ws = null;
     }
+    if (poll) {
+      poll.stop();
+      poll = null;
+      updateStatus("未接続");
+    }
   };
 
   const onOpen = (callback) => {
     openCallbacks.add(callback);
   };
 
-  return { connect, send, onOpen, disconnect };
+  const onMessage = (callback) => {
+    messageCallbacks.add(callback);
+  };
+
+  const currentEpoch = () => epoch;
+
+  return { connect, send, onOpen, onMessage, disconnect, currentEpoch };
 }
 
 function createInputState(getControllerId, connection) {
   const axes = { x: 0, y: 0 };
   const btn = { a: false };
+  const changeCallbacks = new Set();
   let lastSent = "";
+  let seq = 0;
 
   const send = (force = false) => {
     const controllerId =
@@ -450,12 +978,20 @@ function createInputState(getControllerId, connection) {
       return;
     }
 
-    if (connection.send(serialized)) {
+    seq += 1;
+    const epoch = connection.currentEpoch();
+    const tagged = epoch > 0 ? { ...payload, seq, epoch } : { ...payload, seq };
+    if (connection.send(JSON.stringify(tagged))) {
       lastSent = serialized;
+      changeCallbacks.forEach((callback) => callback());
     }
   };
 
-  return { axes, btn, send };
+  const onChange = (callback) => {
+    changeCallbacks.add(callback);
+  };
+
+  return { axes, btn, send, onChange };
 }
 
 function initStick(stick, thumb, state) {
@@ -905,8 +1441,9 @@ function isSecretSlotHelperRoute() {
   return params.get("help") === SECRET_SLOT_HELPER_TOKEN;
 }
 
+// ロビーはキー不要の読み取り専用 API から取得する（API_KEYS を設定したハブでも同じ）。
 async function fetchLobbySnapshot() {
-  const response = await fetch("/api/game/lobby", { cache: "no-store" });
+  const response = await fetch("/api/controller/lobby", { cache: "no-store" });
   const text = await response.text();
   let data = null;
   if (text) {
@@ -921,16 +1458,33 @@ async function fetchLobbySnapshot() {
   }
 
   if (!response.ok) {
-    const message =
-      data && typeof data.error === "string" && data.error.trim()
-        ? data.error.trim()
-        : `サーバーエラー (${response.status})`;
-    throw new Error(message);
+    throw new Error(apiErrorMessage(data, response.status));
   }
 
   return normalizeLobbySnapshot(data);
 }
 
+// ハブの API エラーは {"code": "...", "error": "..."} 形式。
+// プレイヤー向けの文言は code で選び、未知の code はサーバーのメッセージを表示する。
+const API_ERROR_MESSAGES = {
+  user_not_in_lobby: "ロビーに登録されていません。受付で確認してください",
+  user_banned: "このユーザーは現在参加できません",
+  slot_taken: "このスロットは使用中です",
+  rate_limited: "アクセスが集中しています。少し待ってから再試行してください",
+  persona_upstream: "受付システムに接続できません。しばらくしてから再試行してください",
+  lobby_unavailable: "ロビー情報を取得できません。しばらくしてから再試行してください",
+};
+
+function apiErrorMessage(data, status) {
+  if (data && typeof data.code === "string" && API_ERROR_MESSAGES[data.code]) {
+    return API_ERROR_MESSAGES[data.code];
+  }
+  if (data && typeof data.error === "string" && data.error.trim()) {
+    return data.error.trim();
+  }
+  return `サーバーエラー (${status})`;
+}
+
 function normalizeLobbySnapshot(data) {
   const slots = new Map();
   const gameId = data && typeof data.gameId === "string" ? data.gameId : "";
@@ -1001,11 +1555,7 @@ async function requestControllerSession(userId) {
   }
 
   if (!response.ok) {
-    const message =
-      data && typeof data.error === "string" && data.error.trim()
-        ? data.error.trim()
-        : `サーバーエラー (${response.status})`;
-    throw new Error(message);
+    throw new Error(apiErrorMessage(data, response.status));
   }
 
   return normalizeSessionResponse(data, userId);
@@ -1056,6 +1606,41 @@ function normalizeSessionResponse(data, fallbackUserId) {
   };
 }
 
+// Join codes (/j/<code>) redirect here with the session in the URL fragment.
+function readJoinSession() {
+  const hash = window.location.hash.replace(/^#/, "");
+  if (!hash) {
+    return null;
+  }
+  const params = new URLSearchParams(hash);
+  const slotId = (params.get("slot") || "").toLowerCase();
+  const token = params.get("token") || "";
+  if (!slotId || !isValidPlayerId(slotId) || !token) {
+    return null;
+  }
+  // Keep the token out of the address bar and history.
+  window.history.replaceState(
+    null,
+    "",
+    window.location.pathname + window.location.search
+  );
+
+  const now = Date.now();
+  const expParsed = Number.parseInt(params.get("exp") || "", 10);
+  const expiresAt = Number.isFinite(expParsed) ? expParsed : now + 60 * 1000;
+  return {
+    slotId,
+    token,
+    userId: params.get("user") || "",
+    userName: params.get("name") || "",
+    personality: "",
+    ttlMs: Math.max(expiresAt - now, 1000),
+    expiresAt,
+    issuedAt: now,
+    gameId: "",
+  };
+}
+
 function readStoredSession() {
   try {
     const raw = window.sessionStorage.getItem(SESSION_STORAGE_KEY);
@@ -1110,6 +1695,17 @@ function isSessionExpired(session) {
   return session.expiresAt <= Date.now();
 }
 
+function formatSlotMeta(meta) {
+  const parts = [];
+  if (meta && typeof meta.station === "string" && meta.station.trim()) {
+    parts.push(`ステーション ${meta.station.trim()}`);
+  }
+  if (meta && typeof meta.position === "string" && meta.position.trim()) {
+    parts.push(meta.position.trim());
+  }
+  return parts.join(" ");
+}
+
 function formatUserDisplay(session) {
   if (!session) {
     return "ゲスト";
@@ -1220,6 +1816,22 @@ function readStoredInputMode() {
   return INPUT_MODES.DPAD;
 }
 
+function readTutorialDone() {
+  try {
+    return window.localStorage.getItem(TUTORIAL_STORAGE_KEY) === "1";
+  } catch (_) {
+    return false;
+  }
+}
+
+function persistTutorialDone() {
+  try {
+    window.localStorage.setItem(TUTORIAL_STORAGE_KEY, "1");
+  } catch (_) {
+    // ignore storage write issues
+  }
+}
+
 function persistInputMode(mode) {
   try {
     window.localStorage.setItem(
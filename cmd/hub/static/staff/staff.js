@@ -1,4 +1,5 @@
 const slotIds = ["1", "2", "3", "4"];
+const apiKeyStorageKey = "hub.apiKey";
 
 const elements = {
   status: document.querySelector("[data-status]"),
@@ -9,6 +10,10 @@ const elements = {
   updateLobby: document.querySelector("[data-action='update-lobby']"),
   clearLobby: document.querySelector("[data-action='clear-lobby']"),
   startForm: document.querySelector("[data-start-form]"),
+  resultAlert: document.querySelector("[data-result-alert]"),
+  resultElapsed: document.querySelector("[data-result-elapsed]"),
+  apiKeyForm: document.querySelector("[data-api-key-form]"),
+  apiKeyInput: document.querySelector("[data-api-key-input]"),
   slotInputs: new Map(),
   slotNames: new Map(),
   slotPersonalities: new Map(),
@@ -114,10 +119,12 @@ function renderLobby(data) {
   });
 }
 
+let apiKey = sessionStorage.getItem(apiKeyStorageKey) || "";
+
 async function sendJSON(url, { method = "GET", body } = {}) {
   const options = {
     method,
-    headers: {},
+    headers: apiKey ? { Authorization: `Bearer ${apiKey}` } : {},
     credentials: "same-origin",
   };
   if (body !== undefined) {
@@ -297,6 +304,31 @@ async function requestGameStart(event) {
   }
 }
 
+const timerPollMs = 10000;
+
+function formatElapsed(ms) {
+  const totalSeconds = Math.floor(ms / 1000);
+  const minutes = Math.floor(totalSeconds / 60);
+  const seconds = totalSeconds % 60;
+  return `${minutes} 分 ${String(seconds).padStart(2, "0")} 秒`;
+}
+
+async function pollMatchTimer() {
+  if (!elements.resultAlert) {
+    return;
+  }
+  try {
+    const data = await sendJSON("/api/game/timer");
+    const overdue = Boolean(data && data.resultOverdue);
+    elements.resultAlert.hidden = !overdue;
+    if (overdue && elements.resultElapsed) {
+      elements.resultElapsed.textContent = formatElapsed(data.elapsedMs || 0);
+    }
+  } catch {
+    // Keep the last known state; the next poll retries.
+  }
+}
+
 if (elements.fetchLobby) {
   elements.fetchLobby.addEventListener("click", () => {
     fetchLobby();
@@ -319,6 +351,26 @@ if (elements.startForm) {
   elements.startForm.addEventListener("submit", requestGameStart);
 }
 
+if (elements.apiKeyForm) {
+  if (elements.apiKeyInput) {
+    elements.apiKeyInput.value = apiKey;
+  }
+  elements.apiKeyForm.addEventListener("submit", (event) => {
+    event.preventDefault();
+    apiKey = elements.apiKeyInput ? elements.apiKeyInput.value.trim() : "";
+    if (apiKey) {
+      sessionStorage.setItem(apiKeyStorageKey, apiKey);
+    } else {
+      sessionStorage.removeItem(apiKeyStorageKey);
+    }
+    fetchLobby();
+    pollMatchTimer();
+  });
+}
+
 window.addEventListener("pageshow", () => {
   fetchLobby();
+  pollMatchTimer();
 });
+
+window.setInterval(pollMatchTimer, timerPollMs);
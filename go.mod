@@ -2,4 +2,7 @@ module github.com/aritumn2025/cgb-io-hub
 
 go 1.25.3
 
-require nhooyr.io/websocket v1.8.17
+require (
+	github.com/yuin/gopher-lua v1.1.1
+	nhooyr.io/websocket v1.8.17
+)
@@ -0,0 +1,124 @@
+// Package activity keeps a day-by-day journal of what happened at the
+// attraction, matches with their scores, controller sessions and PersonaGo
+// failures, as JSON Lines, and summarises a day of it for the event wrap-up.
+package activity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record kinds.
+const (
+	KindMatch          = "match"
+	KindConnect        = "connect"
+	KindDisconnect     = "disconnect"
+	KindPersonaFailure = "persona_failure"
+)
+
+// Record is one line of the journal.
+type Record struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	SlotID string    `json:"slotId,omitempty"`
+	UserID string    `json:"userId,omitempty"`
+	// Status, Reason and Dropped describe how a controller session ended;
+	// Dropped marks a connection lost without a close frame.
+	Status  int    `json:"status,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Dropped bool   `json:"dropped,omitempty"`
+	// Scores, StartTime and Queued describe a match whose result was
+	// accepted; Queued marks one still waiting for PersonaGo.
+	StartTime time.Time `json:"startTime,omitzero"`
+	Scores    []Score   `json:"scores,omitempty"`
+	Queued    bool      `json:"queued,omitempty"`
+	// Operation names the PersonaGo call that failed.
+	Operation string `json:"operation,omitempty"`
+}
+
+// Score is one player's result in a match record.
+type Score struct {
+	SlotID string `json:"slotId"`
+	UserID string `json:"userId"`
+	Name   string `json:"name,omitempty"`
+	Score  int    `json:"score"`
+}
+
+// Journal appends records to one file per local day,
+// "activity-2025-11-02.jsonl". It is safe for concurrent use.
+type Journal struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+// Open returns a Journal writing into dir, creating it if needed.
+func Open(dir string) (*Journal, error) {
+	if dir == "" {
+		return nil, errors.New("activity: directory required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("activity: create directory: %w", err)
+	}
+	return &Journal{dir: dir}, nil
+}
+
+// Dir reports the directory the journal writes to.
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// FileName returns the journal file holding the records of day.
+func FileName(day time.Time) string {
+	return "activity-" + day.Format(time.DateOnly) + ".jsonl"
+}
+
+// Append writes rec, stamping it with the current time when unset.
+func (j *Journal) Append(rec Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("activity: encode record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day := rec.Time.Local().Format(time.DateOnly)
+	if j.file == nil || j.day != day {
+		if j.file != nil {
+			j.file.Close()
+			j.file = nil
+		}
+		path := filepath.Join(j.dir, FileName(rec.Time.Local()))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("activity: open %s: %w", path, err)
+		}
+		j.file, j.day = file, day
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("activity: write: %w", err)
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
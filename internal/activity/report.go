@@ -0,0 +1,231 @@
+package activity
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Report summarises one day of the journal.
+type Report struct {
+	Date    string `json:"date"`
+	GameID  string `json:"gameId,omitempty"`
+	Matches int    `json:"matches"`
+	// QueuedResults counts matches whose result was still waiting for
+	// PersonaGo when it was journaled.
+	QueuedResults int            `json:"queuedResults"`
+	Plays         int            `json:"plays"`
+	UniquePlayers int            `json:"uniquePlayers"`
+	AverageScore  float64        `json:"averageScore"`
+	TopScores     []Score        `json:"topScores"`
+	FirstMatch    *time.Time     `json:"firstMatch"`
+	LastMatch     *time.Time     `json:"lastMatch"`
+	MatchesByHour map[string]int `json:"matchesByHour"`
+
+	ControllerSessions int `json:"controllerSessions"`
+	// Disconnects counts controller sessions that were lost or ended
+	// without a clean close or a hub decision such as a kick or idle
+	// timeout, i.e. dropped connections.
+	Disconnects       int            `json:"disconnects"`
+	DisconnectRate    float64        `json:"disconnectRate"`
+	DisconnectReasons map[string]int `json:"disconnectReasons"`
+
+	PersonaFailures            int            `json:"personaFailures"`
+	PersonaFailuresByOperation map[string]int `json:"personaFailuresByOperation"`
+
+	// SkippedLines counts journal lines that could not be read.
+	SkippedLines int `json:"skippedLines,omitempty"`
+}
+
+// topScoreCount is how many of the day's best scores a report lists.
+const topScoreCount = 5
+
+// lostReason groups connections lost without a close frame in
+// DisconnectReasons, whatever status the hub logged for them.
+const lostReason = "connection lost"
+
+// droppedStatus reports whether a controller session that ended with the
+// WebSocket status was dropped rather than closed on purpose. 1000 and 1001
+// are clean closes and 4000 and up are the hub's own decisions.
+func droppedStatus(status int) bool {
+	return status != 1000 && status != 1001 && status < 4000
+}
+
+// Summarize reads the journal file of day in dir. A day without a journal
+// yields an empty report.
+func Summarize(dir string, day time.Time) (Report, error) {
+	report := Report{
+		Date:                       day.Format(time.DateOnly),
+		TopScores:                  []Score{},
+		MatchesByHour:              map[string]int{},
+		DisconnectReasons:          map[string]int{},
+		PersonaFailuresByOperation: map[string]int{},
+	}
+
+	path := filepath.Join(dir, FileName(day))
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("activity: open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	players := make(map[string]struct{})
+	total := 0
+	var scores []Score
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			report.SkippedLines++
+			continue
+		}
+		switch rec.Kind {
+		case KindMatch:
+			report.Matches++
+			if rec.Queued {
+				report.QueuedResults++
+			}
+			at := rec.Time
+			if report.FirstMatch == nil || at.Before(*report.FirstMatch) {
+				report.FirstMatch = &at
+			}
+			if report.LastMatch == nil || at.After(*report.LastMatch) {
+				report.LastMatch = &at
+			}
+			report.MatchesByHour[at.Local().Format("15")]++
+			for _, score := range rec.Scores {
+				report.Plays++
+				total += score.Score
+				players[score.UserID] = struct{}{}
+				scores = append(scores, score)
+			}
+		case KindConnect:
+			report.ControllerSessions++
+		case KindDisconnect:
+			if rec.Dropped || droppedStatus(rec.Status) {
+				reason := rec.Reason
+				if rec.Dropped {
+					reason = lostReason
+				}
+				report.Disconnects++
+				report.DisconnectReasons[reason]++
+			}
+		case KindPersonaFailure:
+			report.PersonaFailures++
+			report.PersonaFailuresByOperation[rec.Operation]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, fmt.Errorf("activity: read %s: %w", path, err)
+	}
+
+	report.UniquePlayers = len(players)
+	if report.Plays > 0 {
+		report.AverageScore = float64(total) / float64(report.Plays)
+	}
+	if report.ControllerSessions > 0 {
+		report.DisconnectRate = float64(report.Disconnects) / float64(report.ControllerSessions)
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	report.TopScores = append(report.TopScores, scores[:min(len(scores), topScoreCount)]...)
+	return report, nil
+}
+
+// ParseDay reads "today", "yesterday" or a YYYY-MM-DD date in the local time
+// zone.
+func ParseDay(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "", "today":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("activity: date must be today, yesterday or YYYY-MM-DD: %q", value)
+	}
+	return day, nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	"score":   func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"clock": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Local().Format("15:04")
+	},
+	"hours": func(m map[string]int) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}).Parse(`<!doctype html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>{{if .GameID}}{{.GameID}} {{end}}日次レポート {{.Date}}</title>
+<style>
+body { font-family: "Segoe UI", "Hiragino Sans", sans-serif; margin: 2rem; color: #1b1f24; }
+h1 { font-size: 1.5rem; }
+table { border-collapse: collapse; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ccd; padding: 0.3rem 0.8rem; text-align: left; }
+th { background: #eef1f6; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{if .GameID}}{{.GameID}} {{end}}日次レポート {{.Date}}</h1>
+<table>
+<tr><th>試合数</th><td class="num">{{.Matches}}{{if .QueuedResults}}（うち結果送信待ち {{.QueuedResults}}）{{end}}</td></tr>
+<tr><th>プレイ人数（延べ）</th><td class="num">{{.Plays}}</td></tr>
+<tr><th>ユニークプレイヤー</th><td class="num">{{.UniquePlayers}}</td></tr>
+<tr><th>平均スコア</th><td class="num">{{score .AverageScore}}</td></tr>
+<tr><th>最初 / 最後の試合</th><td>{{clock .FirstMatch}} / {{clock .LastMatch}}</td></tr>
+<tr><th>コントローラ接続数</th><td class="num">{{.ControllerSessions}}</td></tr>
+<tr><th>切断（異常終了）</th><td class="num">{{.Disconnects}}（{{percent .DisconnectRate}}）</td></tr>
+<tr><th>PersonaGo 失敗</th><td class="num">{{.PersonaFailures}}</td></tr>
+</table>
+{{if .TopScores}}<h2>ハイスコア</h2>
+<table>
+<tr><th>スロット</th><th>ユーザー</th><th>スコア</th></tr>
+{{range .TopScores}}<tr><td>{{.SlotID}}</td><td>{{if .Name}}{{.Name}}{{else}}{{.UserID}}{{end}}</td><td class="num">{{.Score}}</td></tr>
+{{end}}</table>
+{{end}}{{if .MatchesByHour}}<h2>時間帯別の試合数</h2>
+<table>
+<tr><th>時</th><th>試合数</th></tr>
+{{$byHour := .MatchesByHour}}{{range hours $byHour}}<tr><td>{{.}}時</td><td class="num">{{index $byHour .}}</td></tr>
+{{end}}</table>
+{{end}}{{if .DisconnectReasons}}<h2>切断理由</h2>
+<table>
+<tr><th>理由</th><th>件数</th></tr>
+{{range $reason, $n := .DisconnectReasons}}<tr><td>{{if $reason}}{{$reason}}{{else}}-{{end}}</td><td class="num">{{$n}}</td></tr>
+{{end}}</table>
+{{end}}{{if .PersonaFailuresByOperation}}<h2>PersonaGo 失敗</h2>
+<table>
+<tr><th>操作</th><th>件数</th></tr>
+{{range $op, $n := .PersonaFailuresByOperation}}<tr><td>{{$op}}</td><td class="num">{{$n}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML renders the report as a standalone HTML page.
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+)
+
+// activityQueueSize bounds the hub events waiting to be journaled.
+const activityQueueSize = 256
+
+// personaFailureRecorder journals PersonaGo outages for the daily report,
+// or returns nil when ACTIVITY_DIR is not set.
+func personaFailureRecorder(journal *activity.Journal, logger *slog.Logger) func(operation string, status int) {
+	if journal == nil {
+		return nil
+	}
+	return func(operation string, status int) {
+		if err := journal.Append(activity.Record{Kind: activity.KindPersonaFailure, Operation: operation, Status: status}); err != nil {
+			logger.Warn("activity_write_failed", "err", err.Error())
+		}
+	}
+}
+
+func (a *App) journal(rec activity.Record) {
+	if a.activity == nil {
+		return
+	}
+	if err := a.activity.Append(rec); err != nil {
+		a.logger.Warn("activity_write_failed", "kind", rec.Kind, "err", err.Error())
+	}
+}
+
+// journalMatch records an accepted result; queued marks one PersonaGo has
+// not taken yet.
+func (a *App) journalMatch(startTime time.Time, submissions []persona.GameResult, queued bool) {
+	scores := make([]activity.Score, 0, len(submissions))
+	for _, res := range submissions {
+		scores = append(scores, activity.Score{
+			SlotID: fmt.Sprintf("p%d", res.Slot),
+			UserID: res.UserID,
+			Name:   res.Name,
+			Score:  res.Score,
+		})
+	}
+	a.journal(activity.Record{Kind: activity.KindMatch, StartTime: startTime.UTC(), Scores: scores, Queued: queued})
+}
+
+// runActivityJournal journals controller sessions from the hub event stream
+// into ACTIVITY_DIR.
+func (a *App) runActivityJournal(ctx context.Context) {
+	sub := a.hub.SubscribeEvents(activityQueueSize)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if ev.Role != "controller" {
+				continue
+			}
+			rec := activity.Record{Time: ev.Time, SlotID: ev.ID}
+			rec.UserID, _ = ev.Fields["userId"].(string)
+			switch ev.Type {
+			case "connected":
+				rec.Kind = activity.KindConnect
+			case "disconnected":
+				rec.Kind = activity.KindDisconnect
+				rec.Status, _ = ev.Fields["status"].(int)
+				rec.Reason, _ = ev.Fields["reason"].(string)
+				rec.Dropped, _ = ev.Fields["dropped"].(bool)
+			default:
+				continue
+			}
+			a.journal(rec)
+		}
+	}
+}
+
+// adminReportHandler serves GET /api/admin/report?date=today|yesterday|YYYY-MM-DD
+// with ?format=json (default) or html: the day's matches, players, scores,
+// dropped connections and PersonaGo failures from ACTIVITY_DIR. POST with
+// the same date exports both formats to STORAGE_URL; see exportReport.
+func (a *App) adminReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.activity == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodeActivityOff, "activity journal disabled; set ACTIVITY_DIR")
+		return
+	}
+
+	q := r.URL.Query()
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format != "" && format != "json" && format != "html" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "format must be json or html")
+		return
+	}
+	day, err := activity.ParseDay(strings.TrimSpace(q.Get("date")), time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	report, err := activity.Summarize(a.activity.Dir(), day)
+	if err != nil {
+		a.requestLogger(r).Error("activity_report_failed", "err", err.Error())
+		a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to read activity journal")
+		return
+	}
+	report.GameID = a.cfg.GameID
+
+	if r.Method == http.MethodPost {
+		files, err := a.exportReport(r.Context(), report)
+		if err != nil {
+			if errors.Is(err, errStorageDisabled) {
+				a.respondError(w, http.StatusServiceUnavailable, errCodeStorageOff, err.Error())
+				return
+			}
+			a.requestLogger(r).Error("activity_report_export_failed", "err", err.Error())
+			a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to export report")
+			return
+		}
+		a.requestLogger(r).Info("activity_report_exported", "date", report.Date, "files", len(files))
+		a.respondJSON(w, http.StatusCreated, map[string]any{"date": report.Date, "files": files})
+		return
+	}
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := report.WriteHTML(w); err != nil {
+			a.requestLogger(r).Error("activity_report_render_failed", "err", err.Error())
+		}
+		return
+	}
+	a.respondJSON(w, http.StatusOK, report)
+}
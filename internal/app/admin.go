@@ -0,0 +1,268 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+)
+
+// adminEnabled reports whether admin endpoints are served on their own listener.
+func (a *App) adminEnabled() bool {
+	return strings.TrimSpace(a.cfg.AdminAddr) != ""
+}
+
+// buildAdminRouter constructs the handler served on the dedicated admin listener.
+func (a *App) buildAdminRouter(assets *staticFiles) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/readyz", a.readyHandler)
+	a.registerAdminRoutes(mux)
+	a.registerAdminUI(mux, assets)
+	// The dashboard borrows the staff page's base styles, under a hashed
+	// name with STATIC_HASHED_NAMES.
+	mux.Handle("/staff/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if assets.assetName(r.URL.Path) != "staff/staff.css" {
+			http.NotFound(w, r)
+			return
+		}
+		assets.ServeHTTP(w, r)
+	}))
+	registerDebugRoutes(mux)
+	return mux
+}
+
+// registerAdminRoutes mounts management endpoints. Without a dedicated admin
+// listener they are served from the public router. With ADMIN_TOKEN set every
+// route requires a bearer token; see adminauth.go.
+func (a *App) registerAdminRoutes(mux *http.ServeMux) {
+	mux.Handle("/api/admin/relay", a.requireAdmin(a.adminRelayStatsHandler))
+	mux.Handle("/api/admin/upgrades", a.requireAdmin(a.adminUpgradeStatsHandler))
+	mux.Handle("/api/admin/limits", a.requireAdmin(a.adminSoftLimitsHandler))
+	mux.Handle("/api/admin/handoff", a.requireAdmin(a.adminHandoffHandler))
+	mux.Handle("/api/admin/disconnect", a.requireAdmin(a.adminDisconnectHandler))
+	mux.Handle("/api/admin/sessions", a.requireAdmin(a.adminSessionsHandler))
+	mux.Handle("/api/admin/accepting", a.requireAdmin(a.adminAcceptingHandler))
+	mux.Handle("/api/admin/overview", a.requireAdmin(a.adminOverviewHandler))
+	mux.Handle("/api/admin/rooms", a.requireAdmin(a.adminRoomsHandler))
+	mux.Handle("/api/admin/lobby/compare", a.requireAdmin(a.adminLobbyCompareHandler))
+	mux.Handle("/api/admin/lobby/reconcile", a.requireAdmin(a.adminLobbyReconcileHandler))
+	mux.Handle("/api/admin/tokens", a.requireAdmin(a.adminTokensHandler))
+	mux.Handle("/api/admin/config", a.requireAdmin(a.adminConfigHandler))
+	mux.Handle("/api/admin/cohorts", a.requireAdmin(a.adminCohortStatsHandler))
+	mux.Handle("/api/admin/kick", a.requireAdmin(a.adminKickHandler))
+	mux.Handle("/api/admin/ban", a.requireAdmin(a.adminBanHandler))
+	mux.Handle("/api/admin/permissions", a.requireAdmin(a.adminPermissionsHandler))
+	mux.Handle("/api/admin/slots/meta", a.requireAdmin(a.adminSlotMetaHandler))
+	mux.Handle("/api/admin/events/tail", a.requireAdmin(a.adminEventsTailHandler))
+	mux.Handle("/api/admin/recording", a.requireAdmin(a.adminRecordingHandler))
+	mux.Handle("/api/admin/replay", a.requireAdmin(a.adminReplayHandler))
+	mux.Handle("/api/admin/report", a.requireAdmin(a.adminReportHandler))
+	mux.Handle("/api/admin/support", a.requireAdmin(a.adminSupportHandler))
+}
+
+func (a *App) adminRelayStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := a.hub.QueueStats()
+	broadcast := a.hub.BroadcastStats()
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"policy":   stats.Policy,
+		"capacity": stats.Capacity,
+		"depth":    stats.Depth,
+		"drops": map[string]uint64{
+			"dropOldest": stats.DropOldest,
+			"dropNewest": stats.DropNewest,
+			"coalesced":  stats.Coalesced,
+			"closed":     stats.Closed,
+			"merged":     stats.Merged,
+			"priority":   stats.Priority,
+			"stale":      stats.Stale,
+			"filtered":   stats.Filtered,
+			"throttled":  stats.Throttled,
+		},
+		"broadcast": map[string]any{
+			"rateHz":    broadcast.RateHz,
+			"forwarded": broadcast.Forwarded,
+			"paced":     broadcast.Paced,
+			"dropped":   broadcast.Dropped,
+			"deltas":    broadcast.Deltas,
+			"channels":  a.hub.Channels(),
+		},
+	})
+}
+
+func (a *App) adminCohortStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := a.hub.CohortStats()
+	cohorts := make([]map[string]any, 0, len(stats))
+	for _, c := range stats {
+		cohorts = append(cohorts, map[string]any{
+			"cohort":      c.Cohort,
+			"connections": c.Connections,
+			"frames":      c.Frames,
+			"bytes":       c.Bytes,
+			"merged":      c.Merged,
+			"stale":       c.Stale,
+		})
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"cohorts": cohorts})
+}
+
+func (a *App) adminHandoffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a.limitBody(w, r)
+	defer r.Body.Close()
+
+	var req handoffRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		if errors.Is(err, io.EOF) {
+			a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
+			return
+		}
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
+		return
+	}
+	if err := decoder.Decode(new(struct{})); err != io.EOF {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
+		return
+	}
+
+	slotID := strings.ToLower(strings.TrimSpace(req.SlotID))
+	userID := strings.TrimSpace(req.UserID)
+	if slotID == "" || userID == "" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId and userId are required")
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	personality := strings.TrimSpace(req.Personality)
+	if a.persona != nil {
+		slot, err := a.persona.FindSlotForUser(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, persona.ErrUserNotFound) {
+				a.respondError(w, http.StatusNotFound, errCodeUserNotInLobby, "user not present in lobby")
+				return
+			}
+			a.requestLogger(r).Error("persona_lookup_failed", "user_id", userID, "err", err.Error())
+			a.respondError(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to fetch user profile")
+			return
+		}
+		name = slot.Name
+		personality = slot.Personality
+	}
+
+	previous, err := a.hub.HandoffSlot(slotID, userID, name, personality)
+	if err != nil {
+		if errors.Is(err, hub.ErrSlotNotConnected) {
+			a.respondErrorDetails(w, http.StatusConflict, errCodeSlotNotFound, "slot not connected: "+slotID, map[string]any{"slotId": slotID})
+			return
+		}
+		a.requestLogger(r).Error("slot_handoff_failed", "slot", slotID, "user_id", userID, "err", err.Error())
+		a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to hand off slot")
+		return
+	}
+
+	a.handoffPlaySlot(slotID, userID, name, personality)
+	a.requestLogger(r).Info("slot_handoff", "slot", slotID, "previous_user_id", previous, "user_id", userID)
+
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"slotId":         slotID,
+		"previousUserId": previous,
+		"user": map[string]string{
+			"id":          userID,
+			"name":        name,
+			"personality": personality,
+		},
+	})
+}
+
+// handoffRequest is the body of POST /api/admin/handoff.
+type handoffRequest struct {
+	SlotID      string `json:"slotId"`
+	UserID      string `json:"userId"`
+	Name        string `json:"name,omitempty"`
+	Personality string `json:"personality,omitempty"`
+}
+
+func (a *App) adminDisconnectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req disconnectRequest
+
+	if r.Body != nil {
+		a.limitBody(w, r)
+		defer r.Body.Close()
+
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) {
+				a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
+				return
+			}
+		} else if err := decoder.Decode(new(struct{})); err != io.EOF {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
+			return
+		}
+	}
+
+	slots := make([]string, 0, len(req.Slots))
+	for _, raw := range req.Slots {
+		if slotID := strings.ToLower(strings.TrimSpace(raw)); slotID != "" {
+			slots = append(slots, slotID)
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+
+	count := a.hub.DisconnectControllers(r.Context(), slots, reason, req.Game)
+	a.requestLogger(r).Info("admin_bulk_disconnect", "count", count, "slots", slots, "game", req.Game, "reason", reason)
+
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"disconnected": count,
+		"game":         req.Game,
+	})
+}
+
+// disconnectRequest is the body of POST /api/admin/disconnect. Without
+// slots every controller is closed; Game closes the game session too.
+type disconnectRequest struct {
+	Reason string   `json:"reason,omitempty"`
+	Slots  []string `json:"slots,omitempty"`
+	Game   bool     `json:"game,omitempty"`
+}
+
+// registerDebugRoutes mounts pprof handlers; these are only ever exposed on
+// the admin listener.
+func registerDebugRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// adminAudience is the audience admin-scope tokens must be issued for to
+// open the management endpoints.
+const adminAudience = "admin-api"
+
+// spectatorShedRetry is the Retry-After sent to spectators turned away
+// while the hub sheds load.
+const spectatorShedRetry = 30 * time.Second
+
+// requireAdmin guards a management endpoint once ADMIN_TOKEN is set. The
+// request must carry "Authorization: Bearer <token>" with either the
+// configured token or a live admin-scope token for adminAudience issued
+// through /api/admin/tokens. Without ADMIN_TOKEN the admin routes fall back
+// to the API key check. With neither, only a dedicated ADMIN_ADDR listener
+// serves them; on the public listener they are refused.
+func (a *App) requireAdmin(next http.HandlerFunc) http.Handler {
+	if a.cfg.AdminToken == "" {
+		if len(a.cfg.APIKeys) == 0 && !a.adminEnabled() {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				a.respondError(w, http.StatusForbidden, errCodeAdminDisabled, "admin API disabled: set ADMIN_TOKEN, API_KEYS or ADMIN_ADDR")
+			})
+		}
+		return a.requireAPIKey(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			a.respondError(w, http.StatusUnauthorized, errCodeAuthRequired, "admin token required")
+			return
+		}
+		if a.adminCredential(token) {
+			next(w, r)
+			return
+		}
+		a.requestLogger(r).Warn("admin_auth_failed", "path", r.URL.Path, "remote_ip", a.requestIP(r))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin", error="invalid_token"`)
+		a.respondError(w, http.StatusUnauthorized, errCodeAuthInvalid, "invalid admin token")
+	})
+}
+
+// requireAPIKey guards the lobby, game and assignment endpoints once
+// API_KEYS is set. A key is sent as "Authorization: Bearer <key>" or in the
+// X-API-Key header; admin credentials are accepted as well.
+func (a *App) requireAPIKey(next http.HandlerFunc) http.Handler {
+	if len(a.cfg.APIKeys) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := bearerToken(r)
+		if !ok {
+			key = strings.TrimSpace(r.Header.Get("X-API-Key"))
+		}
+		if key == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			a.respondError(w, http.StatusUnauthorized, errCodeAuthRequired, "api key required")
+			return
+		}
+		if a.validAPIKey(key) || a.adminCredential(key) {
+			next(w, r)
+			return
+		}
+		a.requestLogger(r).Warn("api_auth_failed", "path", r.URL.Path, "remote_ip", a.requestIP(r))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		a.respondError(w, http.StatusUnauthorized, errCodeAuthInvalid, "invalid api key")
+	})
+}
+
+// requireSpectator guards match state that scoreboards and spectator
+// displays read. On top of what requireAPIKey accepts, GET requests may
+// present a live spectator-scope token, so a display never holds a key that
+// could also start or end matches. Spectators are the first to go while the
+// hub sheds load; key holders such as the game keep being served.
+func (a *App) requireSpectator(next http.HandlerFunc) http.Handler {
+	keyed := a.requireAPIKey(next)
+	if len(a.cfg.APIKeys) == 0 {
+		return keyed
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if token, ok := bearerToken(r); ok {
+				if _, err := a.hub.VerifyToken(token, hub.ScopeSpectator, ""); err == nil {
+					if a.hub.ShedStatus().Shedding {
+						w.Header().Set("Retry-After", strconv.Itoa(int(spectatorShedRetry.Seconds())))
+						a.respondError(w, http.StatusServiceUnavailable, errCodeOverloaded, "hub is shedding load; retry later")
+						return
+					}
+					next(w, r)
+					return
+				}
+			}
+		}
+		keyed.ServeHTTP(w, r)
+	})
+}
+
+// requireControllerAuth applies CONTROLLER_SESSION_AUTH to the endpoints
+// players' browsers call to obtain a controller token. They stay open by
+// default since the controller page has no key to send.
+func (a *App) requireControllerAuth(next http.HandlerFunc) http.Handler {
+	if a.cfg.ControllerSessionAuth == config.ControllerSessionAuthAPIKey {
+		return a.requireAPIKey(next)
+	}
+	return next
+}
+
+func (a *App) adminCredential(token string) bool {
+	if a.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.AdminToken)) == 1 {
+		return true
+	}
+	_, err := a.hub.VerifyToken(token, hub.ScopeAdmin, adminAudience)
+	return err == nil
+}
+
+// validAPIKey compares key against every configured key so the time taken
+// does not reveal which one matched or how much of it.
+func (a *App) validAPIKey(key string) bool {
+	valid := 0
+	for _, candidate := range a.cfg.APIKeys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), []byte(candidate))
+	}
+	return valid == 1
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// adminAcceptingHandler reports or switches whether new controllers may
+// join, so staff can close the floor without restarting the hub.
+func (a *App) adminAcceptingHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req acceptingRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Accepting == nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "accepting is required")
+			return
+		}
+		a.hub.SetAccepting(*req.Accepting)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"accepting": a.hub.Accepting()})
+}
+
+// acceptingRequest is the body of PUT /api/admin/accepting.
+type acceptingRequest struct {
+	Accepting *bool `json:"accepting"`
+}
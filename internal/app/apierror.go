@@ -0,0 +1,55 @@
+package app
+
+import "net/http"
+
+// Error codes returned in the "code" field of API error responses. They are
+// part of the API: frontends and the game branch on them, so existing codes
+// must not change meaning. "error" stays the human readable message.
+const (
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeBodyRequired     = "body_required"
+	errCodeInvalidJSON      = "invalid_json"
+	errCodeAuthRequired     = "auth_required"
+	errCodeAuthInvalid      = "auth_invalid"
+	errCodeAdminDisabled    = "admin_disabled"
+	errCodeRateLimited      = "rate_limited"
+	errCodeNotFound         = "not_found"
+	errCodeInternal         = "internal_error"
+	errCodePersonaDisabled  = "persona_disabled"
+	errCodePersonaUpstream  = "persona_upstream"
+	errCodeLobbyUnavailable = "lobby_unavailable"
+	errCodeUserNotInLobby   = "user_not_in_lobby"
+	errCodeUserBanned       = "user_banned"
+	errCodeUnknownCohort    = "unknown_cohort"
+	errCodeSlotNotFound     = "slot_not_found"
+	errCodeSlotNotAssigned  = "slot_not_assigned"
+	errCodeSlotTaken        = "slot_taken"
+	errCodeClaimsDisabled   = "claims_disabled"
+	errCodeJWTDisabled      = "jwt_disabled"
+	errCodeRecordingOff     = "recording_disabled"
+	errCodeReplayRunning    = "replay_running"
+	errCodeMatchState       = "match_state_conflict"
+	errCodeVisitLimited     = "visit_limited"
+	errCodeActivityOff      = "activity_disabled"
+	errCodeOverloaded       = "overloaded"
+	errCodeStorageOff       = "storage_disabled"
+)
+
+// apiError is the body of every JSON API error response, e.g.
+// {"code":"slot_not_found","error":"slot not found: p3","details":{"slotId":"p3"}}.
+type apiError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"error"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// respondError writes an apiError with status.
+func (a *App) respondError(w http.ResponseWriter, status int, code, message string) {
+	a.respondJSON(w, status, apiError{Code: code, Message: message})
+}
+
+// respondErrorDetails is respondError with machine-readable details, such
+// as the offending slot id.
+func (a *App) respondErrorDetails(w http.ResponseWriter, status int, code, message string, details map[string]any) {
+	a.respondJSON(w, status, apiError{Code: code, Message: message, Details: details})
+}
@@ -5,14 +5,29 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+	"github.com/aritumn2025/cgb-io-hub/internal/blob"
+	"github.com/aritumn2025/cgb-io-hub/internal/buildinfo"
 	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
 	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/localinput"
+	"github.com/aritumn2025/cgb-io-hub/internal/midi"
+	"github.com/aritumn2025/cgb-io-hub/internal/mqtt"
+	"github.com/aritumn2025/cgb-io-hub/internal/osc"
 	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+	"github.com/aritumn2025/cgb-io-hub/internal/recorder"
+	"github.com/aritumn2025/cgb-io-hub/internal/sdnotify"
+	"github.com/aritumn2025/cgb-io-hub/internal/state"
 )
 
 const (
@@ -24,13 +39,67 @@ const (
 type App struct {
 	cfg     config.Config
 	logger  *slog.Logger
-	hub     *hub.Hub
+	hub     Hub
 	persona *persona.Client
 	server  *http.Server
+	admin   *http.Server
+	store   *state.Store
+	levels  *slog.LevelVar
+	joins   joinCodes
+	started time.Time
+	// trustedProxies are the TRUSTED_PROXIES whose X-Forwarded-For names
+	// the client, as on the hub.
+	trustedProxies []netip.Prefix
+	// activity journals the day for the wrap-up report; nil without
+	// ACTIVITY_DIR.
+	activity *activity.Journal
+
+	personaHealth personaHealth
+	// serving is set while the public listener accepts connections and
+	// stopping once shutdown has begun; /readyz reports both.
+	serving  atomic.Bool
+	stopping atomic.Bool
+	// degraded is set while the watchdog's last check failed; it holds back
+	// the systemd watchdog pings.
+	degraded atomic.Bool
+
+	playMu sync.Mutex
+	play   *state.PlaySession
+	// saveMu orders STATE_FILE writes; see saveState.
+	saveMu  sync.Mutex
+	results resultQueue
+	visits  visitLog
+	// leaderboard ranks the scores accepted by /api/game/result.
+	leaderboard leaderboard
+
+	// storage is the STORAGE_URL blob store, nil when unset; report exports
+	// and support bundles live under its exports/ and support/ prefixes.
+	// recordings is where recordings go, a prefix of storage or the
+	// RECORD_DIR directory.
+	storage    blob.Store
+	recordings blob.Store
+
+	recMu  sync.Mutex
+	rec    *recorder.Recorder
+	replay *replayRun
+
+	// osc mirrors relayed controller input to OSC_TARGET and midi maps it
+	// onto MIDI_DEVICE; each is nil when unset.
+	osc  *osc.Bridge
+	midi *midi.Bridge
+	// mqtt publishes hub events to MQTT_URL, nil when unset; mqttInputs
+	// samples input for it when MQTT_INPUT_INTERVAL is set.
+	mqtt       *mqtt.Client
+	mqttInputs *inputSampler
+	// localInput plays LOCAL_INPUT_DEVICE as LOCAL_INPUT_SLOT, nil when
+	// unset.
+	localInput *localinput.Reader
 }
 
-// New initialises application state and constructs the HTTP server.
-func New(cfg config.Config, assets http.FileSystem, logger *slog.Logger) (*App, error) {
+// New initialises application state and constructs the HTTP server. levels,
+// when non-nil, is the level variable behind logger so the admin API can
+// change verbosity at runtime.
+func New(cfg config.Config, assets http.FileSystem, logger *slog.Logger, levels *slog.LevelVar) (*App, error) {
 	if logger == nil {
 		return nil, errors.New("logger must not be nil")
 	}
@@ -38,13 +107,192 @@ func New(cfg config.Config, assets http.FileSystem, logger *slog.Logger) (*App,
 		return nil, errors.New("assets filesystem must not be nil")
 	}
 
+	if dir := strings.TrimSpace(cfg.StaticOverlayDir); dir != "" {
+		overlay, err := newOverlayFS(dir, assets)
+		if err != nil {
+			return nil, err
+		}
+		assets = overlay
+		logger.Info("static_overlay_enabled", "dir", dir)
+	}
+
+	queuePolicy, err := hub.ParseQueuePolicy(cfg.QueuePolicy)
+	if err != nil {
+		return nil, err
+	}
+	idMismatch, err := hub.ParseIDMismatchPolicy(cfg.IDMismatch)
+	if err != nil {
+		return nil, err
+	}
+	cohorts, err := hub.ParseCohorts(cfg.Cohorts)
+	if err != nil {
+		return nil, fmt.Errorf("parse cohorts: %w", err)
+	}
+	profiles, err := hub.ParseInputProfiles(cfg.InputProfiles)
+	if err != nil {
+		return nil, fmt.Errorf("parse input profiles: %w", err)
+	}
+	softLimits, err := hub.ParseSoftLimits(cfg.SoftLimits)
+	if err != nil {
+		return nil, fmt.Errorf("parse soft limits: %w", err)
+	}
+	trustedProxies, err := hub.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxies: %w", err)
+	}
+	if cfg.AllowAnonymous {
+		policy := hub.IDPolicy{MinLength: cfg.IDMinLength, MaxLength: cfg.IDMaxLength, Charset: cfg.IDCharset}
+		if err := policy.CheckAnonymous(); err != nil {
+			return nil, fmt.Errorf("id policy: %w", err)
+		}
+	}
+	signer, err := tokenSigner(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	hubInstance := hub.New(hub.Config{
-		AllowedOrigins:  cfg.Origins,
-		MaxControllers:  cfg.MaxControllers,
-		RelayQueueSize:  cfg.RateHz * 2,
-		RegisterTimeout: cfg.RegisterTimeout,
-		WriteTimeout:    cfg.WriteTimeout,
+		AllowedOrigins:     cfg.Origins,
+		MaxControllers:     cfg.MaxControllers,
+		RelayQueueSize:     cfg.RateHz * 2,
+		QueuePolicy:        queuePolicy,
+		CoalesceInput:      cfg.CoalesceInput,
+		RelayInterval:      time.Second / time.Duration(cfg.RateHz),
+		PriorityTypes:      cfg.PriorityTypes,
+		RegisterTimeout:    cfg.RegisterTimeout,
+		WriteTimeout:       cfg.WriteTimeout,
+		MinProtocolVersion: cfg.MinProtocolVersion,
+		IDPolicy: hub.IDPolicy{
+			MinLength:        cfg.IDMinLength,
+			MaxLength:        cfg.IDMaxLength,
+			Charset:          cfg.IDCharset,
+			ReservedPrefixes: cfg.IDReservedPrefixes,
+		},
+		AllowAnonymous:  cfg.AllowAnonymous,
+		Cohorts:         cohorts,
+		LoadShedding:    cfg.LoadShedding,
+		BroadcastRateHz: cfg.BroadcastRateHz,
+		InputRateHz:     cfg.InputRateHz,
+		StateDelta:      cfg.StateDelta,
+		TokenTTL:        cfg.SessionTokenTTL,
+		GameToken:       cfg.GameToken,
+		Signer:          signer,
+		MaxGames:        cfg.GameListeners,
+		HandoverDrain:   cfg.HandoverDrain,
+		IdleTimeout:     cfg.IdleTimeout,
+		VisibilityGrace: cfg.VisibilityGrace,
+		StaleAfter:      cfg.StaleAfter,
+		TimerInterval:   cfg.TimerInterval,
+		IDFields:        cfg.ControllerIDFields,
+		IDMismatch:      idMismatch,
+		Envelope:        cfg.RelayEnvelope,
+		EnvelopeKey:     []byte(cfg.RelaySigningKey),
+		Passthrough:     cfg.Passthrough,
+		InputProfiles:   profiles,
+		SoftLimits:      softLimits,
+
+		MaxConnsPerIP:         cfg.MaxConnsPerIP,
+		MaxPendingPerIP:       cfg.MaxPendingPerIP,
+		RegisterFailureLimit:  cfg.RegisterFailureLimit,
+		RegisterFailureWindow: cfg.RegisterFailureWindow,
+		RegisterLockout:       cfg.RegisterLockout,
+		CalibrationTimeout:    cfg.CalibrationTimeout,
+		LatencyHintInterval:   cfg.LatencyHintInterval,
+		TrustedProxies:        trustedProxies,
 	}, logger.With("component", "hub"))
+	if cfg.RelayTimestamp {
+		hubInstance.UseInterceptor(hub.ServerTimestamp("hubTs"))
+	}
+
+	if cfg.GameToken == "" {
+		logger.Warn("game_token_disabled", "hint", "set GAME_TOKEN so only the real game can register on /ws")
+	}
+
+	var storage, recordings blob.Store
+	if location := strings.TrimSpace(cfg.StorageURL); location != "" {
+		if storage, err = blob.Open(location); err != nil {
+			return nil, err
+		}
+		recordings = blob.WithPrefix(storage, "recordings")
+	} else if dir := strings.TrimSpace(cfg.RecordDir); dir != "" {
+		local, err := blob.NewDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		recordings = local
+	}
+
+	var journal *activity.Journal
+	if dir := strings.TrimSpace(cfg.ActivityDir); dir != "" {
+		if journal, err = activity.Open(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var oscBridge *osc.Bridge
+	if cfg.OSCTarget != "" {
+		oscBridge, err = osc.NewBridge(osc.Config{
+			Target: cfg.OSCTarget,
+			Prefix: cfg.OSCPrefix,
+			Types:  cfg.OSCTypes,
+			OnError: func(err error) {
+				logger.Warn("osc_bridge_error", "target", cfg.OSCTarget, "err", err.Error())
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var midiBridge *midi.Bridge
+	if cfg.MIDIDevice != "" {
+		mapping := cfg.MIDIMap
+		if mapping == "" {
+			mapping = midi.DefaultMap
+		}
+		rules, err := midi.ParseMap(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("parse MIDI_MAP: %w", err)
+		}
+		midiBridge, err = midi.NewBridge(midi.Config{
+			Device: cfg.MIDIDevice,
+			Rules:  rules,
+			OnError: func(err error) {
+				logger.Warn("midi_bridge_error", "device", cfg.MIDIDevice, "err", err.Error())
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var mqttClient *mqtt.Client
+	var mqttInputs *inputSampler
+	if cfg.MQTTURL != "" {
+		if mqttClient, err = newMQTTClient(cfg, logger); err != nil {
+			return nil, err
+		}
+		if cfg.MQTTInputInterval > 0 {
+			mqttInputs = newInputSampler()
+		}
+	}
+
+	var localInput *localinput.Reader
+	if cfg.LocalInputDevice != "" {
+		localInput, err = localinput.New(localinput.Config{
+			Device: cfg.LocalInputDevice,
+			Slot:   cfg.LocalInputSlot,
+			OnConnect: func() {
+				logger.Info("local_input_connected", "device", cfg.LocalInputDevice, "slot", cfg.LocalInputSlot)
+			},
+			OnDisconnect: func(err error) {
+				logger.Warn("local_input_unavailable", "device", cfg.LocalInputDevice, "slot", cfg.LocalInputSlot, "err", err.Error())
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var personaClient *persona.Client
 	if base := strings.TrimSpace(cfg.DBBaseURL); base != "" {
@@ -54,27 +302,67 @@ func New(cfg config.Config, assets http.FileSystem, logger *slog.Logger) (*App,
 			Attraction: cfg.AttractionID,
 			Staff:      cfg.StaffName,
 			Timeout:    cfg.DBAPITimeout,
+			APIVersion: cfg.DBAPIVersion,
+			OnFailure:  personaFailureRecorder(journal, logger),
 		})
 		if err != nil {
-			return nil, fmt.Errorf("initialise persona client: %w", err)
+			return nil, personaError{err: fmt.Errorf("initialise persona client: %w", err)}
 		}
 		personaClient = client
 	}
 
 	application := &App{
-		cfg:     cfg,
-		logger:  logger,
-		hub:     hubInstance,
-		persona: personaClient,
+		cfg:         cfg,
+		logger:      logger,
+		hub:         hubInstance,
+		persona:     personaClient,
+		levels:      levels,
+		started:     time.Now(),
+		results:     newResultQueue(),
+		leaderboard: newLeaderboard(time.Now()),
+		activity:    journal,
+		storage:     storage,
+		recordings:  recordings,
+		osc:         oscBridge,
+		midi:        midiBridge,
+		mqtt:        mqttClient,
+		mqttInputs:  mqttInputs,
+		localInput:  localInput,
+
+		trustedProxies: trustedProxies,
+	}
+	application.setRelayTap(nil)
+
+	if path := strings.TrimSpace(cfg.StateFile); path != "" {
+		store, err := state.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open state store: %w", err)
+		}
+		application.store = store
+	}
+	if err := application.restorePlaySession(); err != nil {
+		return nil, err
 	}
 
-	mux := application.buildRouter(assets)
+	static := newStaticFiles(assets, cfg.StaticHashedNames, cfg.StaticMaxAge, cfg.SPAFallback)
+	mux := application.buildRouter(static)
 
 	application.server = &http.Server{
 		Addr:              cfg.Addr,
-		Handler:           loggingMiddleware(logger, mux),
+		Handler:           application.loggingMiddleware(logger, application.compress(mux)),
 		ReadHeaderTimeout: readHeaderTimeout,
 		IdleTimeout:       idleTimeout,
+		Protocols:         serverProtocols(cfg),
+		ErrorLog:          serverErrorLog(logger, hubInstance),
+	}
+
+	if application.adminEnabled() {
+		application.admin = &http.Server{
+			Addr:              cfg.AdminAddr,
+			Handler:           application.loggingMiddleware(logger.With("listener", "admin"), application.compress(application.buildAdminRouter(static))),
+			ReadHeaderTimeout: readHeaderTimeout,
+			IdleTimeout:       idleTimeout,
+		}
 	}
 
 	return application, nil
@@ -87,32 +375,172 @@ func (a *App) Run(ctx context.Context) error {
 		return errors.New("context must not be nil")
 	}
 
-	serverErr := make(chan error, 1)
-	go func() {
-		a.logger.Info("server_listening", "addr", a.cfg.Addr)
-		serverErr <- a.server.ListenAndServe()
-	}()
+	if err := a.requirePersona(ctx); err != nil {
+		return err
+	}
+
+	// Bind before starting anything else so a taken or privileged port fails
+	// startup with a diagnosis instead of surfacing from a goroutine.
+	publicLn, err := a.listen(a.server.Addr, "-addr/ADDR")
+	if err != nil {
+		return err
+	}
+	var adminLn net.Listener
+	if a.admin != nil {
+		if adminLn, err = a.listen(a.admin.Addr, "-admin-addr/ADMIN_ADDR"); err != nil {
+			_ = publicLn.Close()
+			return err
+		}
+	}
+
+	if a.cfg.AdminToken == "" && len(a.cfg.APIKeys) == 0 && !a.adminEnabled() {
+		a.logger.Warn("admin_api_disabled", "hint", "set ADMIN_TOKEN, API_KEYS or ADMIN_ADDR to use /api/admin and hub ctl")
+	}
+	if a.cfg.AssignmentsWebhookURL != "" {
+		crash.Go(func() { a.runAssignmentsWebhook(ctx) })
+	}
+	if len(a.cfg.LifecycleWebhookURLs) > 0 {
+		crash.Go(func() { a.runLifecycleWebhooks(ctx) })
+	}
+	if a.cfg.LoadShedding {
+		crash.Go(func() { a.hub.RunLoadMonitor(ctx) })
+	}
+	if a.cfg.IdleTimeout > 0 {
+		crash.Go(func() { a.hub.RunIdleMonitor(ctx) })
+	}
+	crash.Go(func() { a.hub.RunMatchTimer(ctx) })
+	if a.cfg.LatencyHintInterval > 0 {
+		crash.Go(func() { a.hub.RunLatencyHints(ctx) })
+	}
+	crash.Go(func() { a.hub.RunSoftLimitMonitor(ctx) })
+	if a.cfg.ResultReminderAfter > 0 {
+		crash.Go(func() { a.runResultReminder(ctx) })
+	}
+	if a.persona != nil {
+		crash.Go(func() { a.runResultRetries(ctx) })
+	}
+	if a.activity != nil {
+		crash.Go(func() { a.runActivityJournal(ctx) })
+	}
+	if a.osc != nil {
+		a.logger.Info("osc_bridge_enabled", "target", a.osc.Target())
+		crash.Go(func() { a.osc.Run(ctx) })
+	}
+	if a.midi != nil {
+		a.logger.Info("midi_bridge_enabled", "device", a.midi.Device())
+		crash.Go(func() { a.midi.Run(ctx) })
+		crash.Go(func() { a.runMIDIReleases(ctx) })
+	}
+	if a.mqtt != nil {
+		a.logger.Info("mqtt_enabled", "broker", redactURL(a.cfg.MQTTURL))
+		crash.Go(func() { a.runMQTT(ctx) })
+	}
+	if a.localInput != nil {
+		a.logger.Info("local_input_enabled", "device", a.localInput.Device(), "slot", a.localInput.Slot())
+		crash.Go(func() { a.runLocalInput(ctx) })
+	}
+	if a.cfg.LobbyReconcileInterval > 0 && a.persona != nil {
+		crash.Go(func() { a.runLobbyReconcile(ctx) })
+	}
+
+	watchdogErr := make(chan error, 1)
+	if a.cfg.WatchdogInterval > 0 {
+		crash.Go(func() { a.runWatchdog(ctx, publicLn.Addr(), watchdogErr) })
+	}
+
+	serverErr := make(chan error, 2)
+	crash.Go(func() {
+		attrs := []any{
+			"addr", a.cfg.Addr,
+			"tls", a.tlsEnabled(),
+			"http2", a.cfg.HTTP2,
+			"h2c", a.cfg.H2C,
+		}
+		a.logger.Info("server_listening", append(attrs, buildinfo.Get().LogAttrs()...)...)
+		a.serving.Store(true)
+		defer a.serving.Store(false)
+		if a.tlsEnabled() {
+			serverErr <- a.server.ServeTLS(publicLn, a.cfg.TLSCertFile, a.cfg.TLSKeyFile)
+			return
+		}
+		serverErr <- a.server.Serve(publicLn)
+	})
+	if a.admin != nil {
+		crash.Go(func() {
+			a.logger.Info("admin_server_listening", "addr", a.cfg.AdminAddr)
+			serverErr <- a.admin.Serve(adminLn)
+		})
+	}
+
+	// The listeners are bound, so connections queue even before the serve
+	// goroutines run.
+	a.notifySystemd(sdnotify.Ready)
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		crash.Go(func() { a.runSystemdWatchdog(ctx, interval) })
+	}
+	defer a.notifySystemd(sdnotify.Stopping)
 
 	select {
 	case <-ctx.Done():
+		a.stopping.Store(true)
 		a.logger.Info("shutdown_signal", "reason", ctx.Err())
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
 		defer cancel()
 
 		a.hub.Shutdown(shutdownCtx)
+		a.stopRecording()
 
 		if err := a.server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
 			a.logger.Error("server_shutdown_error", "err", err.Error())
 		}
+		if a.admin != nil {
+			if err := a.admin.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				a.logger.Error("admin_server_shutdown_error", "err", err.Error())
+			}
+		}
 
-		if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return err
+		for i := 0; i < a.listenerCount(); i++ {
+			if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
 		}
 
 		a.logger.Info("shutdown_complete")
 		return nil
 
+	case err := <-watchdogErr:
+		a.stopping.Store(true)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+		defer cancel()
+		// The watchdog fires because the hub is wedged, most likely holding
+		// its lock, so Hub.Shutdown may never return. Try a graceful stop in
+		// the background and exit once ShutdownTimeout passes either way.
+		stopped := make(chan struct{})
+		crash.Go(func() {
+			defer close(stopped)
+			a.hub.Shutdown(shutdownCtx)
+			a.stopRecording()
+			_ = a.server.Shutdown(shutdownCtx)
+			if a.admin != nil {
+				_ = a.admin.Shutdown(shutdownCtx)
+			}
+		})
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			a.logger.Error("watchdog_shutdown_timeout", "timeout", a.cfg.ShutdownTimeout.String())
+		}
+		return err
+
 	case err := <-serverErr:
+		a.stopping.Store(true)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+		defer cancel()
+		a.hub.Shutdown(shutdownCtx)
+		_ = a.server.Shutdown(shutdownCtx)
+		if a.admin != nil {
+			_ = a.admin.Shutdown(shutdownCtx)
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			return err
 		}
@@ -120,6 +548,27 @@ func (a *App) Run(ctx context.Context) error {
 	}
 }
 
+func (a *App) tlsEnabled() bool {
+	return a.cfg.TLSCertFile != "" && a.cfg.TLSKeyFile != ""
+}
+
+// serverProtocols selects the HTTP versions for the public listener. HTTP/1.1
+// always stays enabled because WebSocket upgrades on /ws depend on it.
+func serverProtocols(cfg config.Config) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.HTTP2)
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+	return protocols
+}
+
+func (a *App) listenerCount() int {
+	if a.admin != nil {
+		return 2
+	}
+	return 1
+}
+
 func (a *App) logErrorWithStack(msg string, args ...any) {
 	stack := strings.TrimSpace(string(debug.Stack()))
 	fields := append(args, "stack", stack)
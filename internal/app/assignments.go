@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// assignmentsPollInterval bounds how long a token expiry, which the hub only
+// notices when it next sweeps its tokens, can go unreported.
+const assignmentsPollInterval = 5 * time.Second
+
+const assignmentsStreamBuffer = 64
+
+// assignmentEventTypes are the hub events that can change the response of
+// /api/controller/assignments.
+var assignmentEventTypes = map[string]bool{
+	"connected":        true,
+	"disconnected":     true,
+	"token_issued":     true,
+	"token_expired":    true,
+	"token_revoked":    true,
+	"slot_handoff":     true,
+	"tutorial_changed": true,
+}
+
+type assignmentEntry struct {
+	SlotID      string `json:"slotId"`
+	UserID      string `json:"userId,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Personality string `json:"personality,omitempty"`
+	Connected   bool   `json:"connected"`
+	Tutorial    string `json:"tutorial,omitempty"`
+	Cohort      string `json:"cohort,omitempty"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+func (e assignmentEntry) equal(other assignmentEntry) bool {
+	return e.SlotID == other.SlotID &&
+		e.UserID == other.UserID &&
+		e.Name == other.Name &&
+		e.Personality == other.Personality &&
+		e.Connected == other.Connected &&
+		e.Tutorial == other.Tutorial &&
+		e.Cohort == other.Cohort &&
+		maps.Equal(e.Meta, other.Meta)
+}
+
+type assignmentDiff struct {
+	Joined  []assignmentEntry `json:"joined"`
+	Left    []string          `json:"left"`
+	Changed []assignmentEntry `json:"changed"`
+}
+
+func (d assignmentDiff) empty() bool {
+	return len(d.Joined) == 0 && len(d.Left) == 0 && len(d.Changed) == 0
+}
+
+func snapshotAssignments(records []hub.ControllerAssignment) map[string]assignmentEntry {
+	snapshot := make(map[string]assignmentEntry, len(records))
+	for _, rec := range records {
+		snapshot[rec.SlotID] = assignmentEntry{
+			SlotID:      rec.SlotID,
+			UserID:      rec.UserID,
+			Name:        rec.Name,
+			Personality: rec.Personality,
+			Connected:   rec.Connected,
+			Tutorial:    string(rec.Tutorial),
+			Cohort:      rec.Cohort,
+			Meta:        rec.Meta,
+		}
+	}
+	return snapshot
+}
+
+// diffAssignments compares two snapshots. Only identity and connection state
+// are considered; activity fields such as lastSeen are ignored.
+func diffAssignments(prev, next map[string]assignmentEntry) assignmentDiff {
+	diff := assignmentDiff{
+		Joined:  []assignmentEntry{},
+		Left:    []string{},
+		Changed: []assignmentEntry{},
+	}
+	for slotID, entry := range next {
+		old, ok := prev[slotID]
+		switch {
+		case !ok:
+			diff.Joined = append(diff.Joined, entry)
+		case !old.equal(entry):
+			diff.Changed = append(diff.Changed, entry)
+		}
+	}
+	for slotID := range prev {
+		if _, ok := next[slotID]; !ok {
+			diff.Left = append(diff.Left, slotID)
+		}
+	}
+
+	sort.Slice(diff.Joined, func(i, j int) bool { return diff.Joined[i].SlotID < diff.Joined[j].SlotID })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].SlotID < diff.Changed[j].SlotID })
+	sort.Strings(diff.Left)
+	return diff
+}
+
+// watchAssignments invokes emit with every non-empty change to the
+// controller assignments until ctx is done.
+func (a *App) watchAssignments(ctx context.Context, emit func(assignmentDiff)) {
+	ticker := time.NewTicker(assignmentsPollInterval)
+	defer ticker.Stop()
+
+	changed := a.hub.AssignmentsChanged()
+	prev := snapshotAssignments(a.hub.ControllerAssignments())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			changed = a.hub.AssignmentsChanged()
+		case <-ticker.C:
+		}
+
+		next := snapshotAssignments(a.hub.ControllerAssignments())
+		if diff := diffAssignments(prev, next); !diff.empty() {
+			emit(diff)
+		}
+		prev = next
+	}
+}
+
+// runAssignmentsWebhook forwards assignment diffs to the configured webhook so
+// external scoreboards can follow along without holding a stream open.
+func (a *App) runAssignmentsWebhook(ctx context.Context) {
+	client := newWebhookClient(a.cfg.AssignmentsWebhookURL)
+	a.watchAssignments(ctx, func(diff assignmentDiff) {
+		payload := struct {
+			Type      string `json:"type"`
+			Timestamp int64  `json:"timestamp"`
+			assignmentDiff
+		}{
+			Type:           "assignments_diff",
+			Timestamp:      time.Now().UnixMilli(),
+			assignmentDiff: diff,
+		}
+		if err := client.post(ctx, payload); err != nil {
+			a.logger.Warn("assignments_webhook_failed", "err", err.Error())
+		}
+	})
+}
+
+// controllerAssignmentsStreamHandler pushes the assignments as Server-Sent
+// Events: one snapshot on connect, then a fresh one whenever the event bus
+// reports a controller or token change. Each event carries the full list in
+// the /api/controller/assignments shape so a client that missed one simply
+// catches up on the next.
+func (a *App) controllerAssignmentsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sub := a.hub.SubscribeEvents(assignmentsStreamBuffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		a.requestLogger(r).Warn("assignments_stream_flush_unsupported", "err", err.Error())
+		return
+	}
+
+	var seq uint64
+	send := func(reason, slotID string) error {
+		data, err := json.Marshal(map[string]any{
+			"reason":      reason,
+			"slotId":      slotID,
+			"assignments": assignmentResponses(a.hub.ControllerAssignments()),
+		})
+		if err != nil {
+			return err
+		}
+		seq++
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: assignments\ndata: %s\n\n", seq, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if err := send("snapshot", ""); err != nil {
+		return
+	}
+
+	// Reading the assignments sweeps expired tokens, which then arrive here
+	// as token_expired events.
+	sweep := time.NewTicker(assignmentsPollInterval)
+	defer sweep.Stop()
+	keepAlive := time.NewTicker(eventTailKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if !assignmentEventTypes[ev.Type] || (ev.Role != "" && ev.Role != "controller") {
+				continue
+			}
+			slotID := ev.ID
+			if slotID == "" {
+				slotID, _ = ev.Fields["slotId"].(string)
+			}
+			if err := send(ev.Type, slotID); err != nil {
+				return
+			}
+		case <-sweep.C:
+			a.hub.ControllerAssignments()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// bridgeEventBuffer is how many hub events the MIDI bridge may lag behind.
+const bridgeEventBuffer = 64
+
+// setRelayTap installs the observer of relayed controller frames: record,
+// the recording in progress if any, followed by the output bridges and the
+// MQTT input sampler.
+func (a *App) setRelayTap(record hub.RelayTap) {
+	var taps []hub.RelayTap
+	if record != nil {
+		taps = append(taps, record)
+	}
+	if a.osc != nil {
+		taps = append(taps, a.osc.Observe)
+	}
+	if a.midi != nil {
+		taps = append(taps, a.midi.Observe)
+	}
+	if a.mqttInputs != nil {
+		taps = append(taps, a.mqttInputs.observe)
+	}
+	var tap hub.RelayTap
+	switch len(taps) {
+	case 0:
+	case 1:
+		tap = taps[0]
+	default:
+		tap = func(slotID, msgType string, payload []byte) {
+			for _, tap := range taps {
+				tap(slotID, msgType, payload)
+			}
+		}
+	}
+	a.hub.SetRelayTap(tap)
+}
+
+// runLocalInput plays the local input device as a controller session of its
+// slot for as long as the device is plugged in, so the game, the
+// assignments and the relay tap see it like any phone.
+func (a *App) runLocalInput(ctx context.Context) {
+	slotID := a.localInput.Slot()
+	a.localInput.Run(ctx, func(frames <-chan []byte) {
+		// The reader closes frames once ctx is done, after the released
+		// state, so the session is left to end with them.
+		status, reason := a.hub.RunLocal(context.WithoutCancel(ctx), slotID, frames)
+		if status != websocket.StatusNormalClosure {
+			a.logger.Warn("local_input_closed", "device", a.localInput.Device(), "slot", slotID, "status", int(status), "reason", reason)
+		}
+	})
+}
+
+// runMIDIReleases returns a slot's MIDI controls to rest when its
+// controller disconnects, so a button held at that moment does not leave
+// its note sounding.
+func (a *App) runMIDIReleases(ctx context.Context) {
+	sub := a.hub.SubscribeEvents(bridgeEventBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if ev.Role == "controller" && ev.Type == "disconnected" {
+				a.midi.Release(ev.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// controllerClaimHandler reserves a slot for standalone deployments where
+// Persona cannot assign players. It mirrors the /api/controller/session
+// response so the controller client can use either.
+func (a *App) controllerClaimHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if a.persona != nil {
+		a.respondError(w, http.StatusConflict, errCodeClaimsDisabled, "slot claims are disabled while persona integration is enabled; use /api/controller/session")
+		return
+	}
+
+	var req claimRequest
+	if !a.decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is required")
+		return
+	}
+	if len([]rune(name)) > 32 {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "name must be at most 32 characters")
+		return
+	}
+
+	claim, err := a.hub.ClaimSlot(req.SlotID, name, strings.TrimSpace(req.Cohort), a.cfg.SessionTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, hub.ErrNoFreeSlot):
+			a.respondError(w, http.StatusConflict, errCodeSlotTaken, "all slots are taken")
+		case errors.Is(err, hub.ErrSlotTaken):
+			a.respondError(w, http.StatusConflict, errCodeSlotTaken, "slot already taken")
+		case errors.Is(err, hub.ErrUnknownCohort):
+			a.respondError(w, http.StatusBadRequest, errCodeUnknownCohort, "unknown cohort")
+		default:
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		}
+		return
+	}
+
+	ttlSeconds := int(time.Until(claim.ExpiresAt).Seconds())
+	if ttlSeconds < 1 {
+		ttlSeconds = 1
+	}
+
+	a.respondJSON(w, http.StatusCreated, map[string]any{
+		"slotId":    claim.SlotID,
+		"token":     claim.Token,
+		"ttl":       ttlSeconds,
+		"expiresAt": claim.ExpiresAt.UTC().Format(time.RFC3339),
+		"user": map[string]string{
+			"id":          claim.UserID,
+			"name":        claim.Name,
+			"personality": "",
+		},
+		"gameId": a.cfg.GameID,
+	})
+}
+
+// claimRequest is the body of POST /api/controller/claim. SlotID picks a
+// slot; without it the first free one is taken.
+type claimRequest struct {
+	Name   string `json:"name"`
+	SlotID string `json:"slotId,omitempty"`
+	Cohort string `json:"cohort,omitempty"`
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the media types worth compressing; images other
+// than SVG, fonts and the like are compressed already.
+var compressibleTypes = map[string]bool{
+	"application/javascript":    true,
+	"application/json":          true,
+	"application/manifest+json": true,
+	"application/xml":           true,
+	"image/svg+xml":             true,
+	"text/css":                  true,
+	"text/html":                 true,
+	"text/javascript":           true,
+	"text/plain":                true,
+	"text/xml":                  true,
+}
+
+// compressor encodes response bodies with gzip or deflate (zlib, as HTTP
+// means it) for clients that accept either. The controller page and the
+// assignment polling are often served over the venue's cellular fallback,
+// where the bytes matter more than the CPU.
+type compressor struct {
+	level   int
+	minSize int
+	gzip    sync.Pool
+	deflate sync.Pool
+}
+
+func newCompressor(level, minSize int) *compressor {
+	return &compressor{level: level, minSize: minSize}
+}
+
+// compress wraps next with response compression when COMPRESSION is set.
+func (a *App) compress(next http.Handler) http.Handler {
+	if !a.cfg.Compression {
+		return next
+	}
+	c := newCompressor(a.cfg.CompressionLevel, a.cfg.CompressionMinSize)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// WebSocket and Socket.IO upgrades take over the connection, and
+		// HEAD responses have no body to encode.
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, c: c, encoding: acceptedEncoding(r.Header.Get("Accept-Encoding"))}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip, then deflate, from an Accept-Encoding
+// header, honouring q=0; empty when neither is acceptable.
+func acceptedEncoding(header string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		value := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				value = parsed
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		value, ok := q[encoding]
+		if !ok {
+			value, ok = q["*"]
+		}
+		if ok && value > bestQ {
+			best, bestQ = encoding, value
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the first minSize bytes of a response to decide
+// whether compressing it is worthwhile. Statuses without a body, partial
+// content, streams and bodies the handler encoded itself pass through.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		// Superfluous; let net/http report it.
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	if status < http.StatusOK {
+		// Informational responses such as 103 Early Hints go out as is.
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if !w.eligible(w.Header().Get("Content-Type")) {
+		_ = w.commit(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.c.minSize {
+			return len(p), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends what is held back, uncompressed when the size threshold was
+// not reached yet, so streaming handlers keep working.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.commit(false)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker not supported")
+	}
+	return hj.Hijack()
+}
+
+// decide compresses the response once the threshold was reached.
+func (w *compressWriter) decide() error {
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" {
+		// What net/http would sniff on the first write.
+		contentType = http.DetectContentType(w.buf)
+		w.Header().Set("Content-Type", contentType)
+	}
+	return w.commit(w.eligible(contentType))
+}
+
+func (w *compressWriter) eligible(contentType string) bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if contentType != "" && (err != nil || !compressibleTypes[mediaType]) {
+		return false
+	}
+	if contentType != "" && !strings.Contains(h.Get("Vary"), "Accept-Encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	return w.encoding != ""
+}
+
+// commit writes the header and the held back bytes, through an encoder
+// when compress is set.
+func (w *compressWriter) commit(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The encoded bytes differ, so a strong validator no longer holds.
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.c.writer(w.encoding, w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response after the handler returned.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written; let net/http send its default.
+			return
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if w.Header().Get("Content-Type") == "" && len(w.buf) > 0 {
+			w.Header().Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		w.eligible(w.Header().Get("Content-Type"))
+		_ = w.commit(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.c.release(w.encoding, w.enc)
+		w.enc = nil
+	}
+}
+
+func (c *compressor) writer(encoding string, dst io.Writer) io.WriteCloser {
+	switch encoding {
+	case "gzip":
+		if zw, ok := c.gzip.Get().(*gzip.Writer); ok {
+			zw.Reset(dst)
+			return zw
+		}
+		zw, _ := gzip.NewWriterLevel(dst, c.level)
+		return zw
+	default:
+		if zw, ok := c.deflate.Get().(*zlib.Writer); ok {
+			zw.Reset(dst)
+			return zw
+		}
+		zw, _ := zlib.NewWriterLevel(dst, c.level)
+		return zw
+	}
+}
+
+func (c *compressor) release(encoding string, enc io.WriteCloser) {
+	switch encoding {
+	case "gzip":
+		c.gzip.Put(enc)
+	default:
+		c.deflate.Put(enc)
+	}
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+const (
+	adminUIPath = "/admin"
+
+	personaProbeTTL     = 5 * time.Second
+	personaProbeTimeout = 3 * time.Second
+)
+
+// personaHealth caches the last lobby fetch so an open dashboard polling the
+// overview does not turn into a steady stream of requests against PersonaGo.
+type personaHealth struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	latency   time.Duration
+	err       error
+}
+
+func (a *App) probePersona(ctx context.Context) (time.Time, time.Duration, error) {
+	p := &a.personaHealth
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checkedAt.IsZero() && time.Since(p.checkedAt) < personaProbeTTL {
+		return p.checkedAt, p.latency, p.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, personaProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := a.persona.FetchLobby(ctx)
+	p.checkedAt, p.latency, p.err = time.Now(), time.Since(start), err
+	if err != nil {
+		a.logger.Warn("persona_probe_failed", "err", err.Error())
+	}
+	return p.checkedAt, p.latency, p.err
+}
+
+// registerAdminUI serves the operator dashboard next to the admin API so it
+// always talks to the listener that owns /api/admin/*.
+func (a *App) registerAdminUI(mux *http.ServeMux, assets *staticFiles) {
+	mux.Handle(adminUIPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assets.serveFile(w, r, "admin/index.html")
+	}))
+	mux.Handle(adminUIPath+"/", assets)
+}
+
+// adminOverviewHandler returns everything the dashboard renders in one
+// snapshot; the page refreshes it whenever the event stream reports a change.
+func (a *App) adminOverviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := a.hub.Status()
+	rtt := make(map[string]float64)
+	telemetry := make(map[string]*hub.Telemetry)
+	for _, s := range a.hub.Sessions() {
+		if s.Role != "controller" {
+			continue
+		}
+		if s.RTT > 0 {
+			rtt[s.ID] = float64(s.RTT.Microseconds()) / 1000
+		}
+		if s.Telemetry != nil {
+			telemetry[s.ID] = s.Telemetry
+		}
+	}
+
+	slots := make([]map[string]any, 0, status.MaxControllers)
+	for _, rec := range a.hub.ControllerAssignments() {
+		entry := map[string]any{
+			"slotId":    rec.SlotID,
+			"connected": rec.Connected,
+			"stale":     rec.Stale,
+		}
+		if rec.Tutorial != "" {
+			entry["tutorial"] = rec.Tutorial
+		}
+		if rec.Cohort != "" {
+			entry["cohort"] = rec.Cohort
+		}
+		if rec.UserID != "" {
+			entry["userId"] = rec.UserID
+			entry["name"] = rec.Name
+		}
+		if !rec.LastSeen.IsZero() {
+			entry["lastSeen"] = rec.LastSeen.UTC().Format(time.RFC3339)
+		}
+		if !rec.TokenExpiresAt.IsZero() {
+			entry["tokenExpiresAt"] = rec.TokenExpiresAt.UTC().Format(time.RFC3339)
+		}
+		if ms, ok := rtt[rec.SlotID]; ok {
+			entry["rttMs"] = ms
+		}
+		if t, ok := telemetry[rec.SlotID]; ok {
+			entry["telemetry"] = telemetryResponse(t)
+		}
+		slots = append(slots, entry)
+	}
+
+	personaStatus := map[string]any{"enabled": a.persona != nil}
+	if a.persona != nil {
+		checkedAt, latency, err := a.probePersona(r.Context())
+		personaStatus["ok"] = err == nil
+		personaStatus["latencyMs"] = latency.Milliseconds()
+		personaStatus["checkedAt"] = checkedAt.UTC().Format(time.RFC3339)
+		if err != nil {
+			personaStatus["error"] = err.Error()
+		}
+	}
+
+	shed := a.hub.ShedStatus()
+	_, elapsed, running := a.hub.MatchTimer()
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"gameId":    a.cfg.GameID,
+		"accepting": a.hub.Accepting(),
+		"shedding":  shed.Shedding,
+		"game": map[string]any{
+			"connected": status.GameConnected,
+			"remoteIp":  status.GameRemoteIP,
+			"mirrors":   status.Mirrors,
+		},
+		"controllers":    status.Controllers,
+		"maxControllers": status.MaxControllers,
+		"match": map[string]any{
+			"running":       running,
+			"elapsedMs":     elapsed.Milliseconds(),
+			"resultOverdue": a.resultOverdue(elapsed, running),
+		},
+		"slots":   slots,
+		"persona": personaStatus,
+	})
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	eventTailBuffer    = 256
+	eventTailKeepAlive = 15 * time.Second
+)
+
+// adminEventsTailHandler streams hub events as newline-delimited JSON until
+// the client goes away, e.g. `curl -N .../api/admin/events/tail?types=kicked`.
+// Idle streams receive a keepalive line so proxies do not time them out.
+func (a *App) adminEventsTailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var types map[string]struct{}
+	if raw := strings.TrimSpace(r.URL.Query().Get("types")); raw != "" {
+		types = make(map[string]struct{})
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types[t] = struct{}{}
+			}
+		}
+	}
+
+	sub := a.hub.SubscribeEvents(eventTailBuffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		a.requestLogger(r).Warn("events_tail_flush_unsupported", "err", err.Error())
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	keepAlive := time.NewTicker(eventTailKeepAlive)
+	defer keepAlive.Stop()
+
+	var reported uint64
+	for {
+		var line any
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if types != nil {
+				if _, wanted := types[ev.Type]; !wanted {
+					continue
+				}
+			}
+			line = ev
+		case now := <-keepAlive.C:
+			line = map[string]any{"time": now.UTC(), "type": "keepalive"}
+		}
+
+		if dropped := sub.Dropped(); dropped > reported {
+			if err := enc.Encode(map[string]any{"time": time.Now().UTC(), "type": "events_dropped", "count": dropped - reported}); err != nil {
+				return
+			}
+			reported = dropped
+		}
+		if err := enc.Encode(line); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
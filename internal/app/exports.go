@@ -0,0 +1,217 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+	"github.com/aritumn2025/cgb-io-hub/internal/blob"
+	"github.com/aritumn2025/cgb-io-hub/internal/buildinfo"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// Report exports and support bundles go to the STORAGE_URL store next to
+// recordings, so they end up wherever the deployment keeps its files (a
+// shared directory or a bucket) instead of only on the mini-PC that made
+// them.
+const (
+	exportsPrefix = "exports"
+	supportPrefix = "support"
+)
+
+var errStorageDisabled = errors.New("blob storage disabled: set STORAGE_URL")
+
+// exportedFile is one object written by an export.
+type exportedFile struct {
+	Key     string `json:"key"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"modTime,omitempty"`
+}
+
+// putBlob writes data to key in store.
+func putBlob(ctx context.Context, store blob.Store, key string, data []byte) (exportedFile, error) {
+	w, err := store.Create(ctx, key)
+	if err != nil {
+		return exportedFile{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return exportedFile{}, err
+	}
+	if err := w.Close(); err != nil {
+		return exportedFile{}, err
+	}
+	return exportedFile{Key: key, Size: int64(len(data))}, nil
+}
+
+// exportReport stores report as report-YYYY-MM-DD.json and .html under
+// exports/, replacing an earlier export of the same day.
+func (a *App) exportReport(ctx context.Context, report activity.Report) ([]exportedFile, error) {
+	if a.storage == nil {
+		return nil, errStorageDisabled
+	}
+	store := blob.WithPrefix(a.storage, exportsPrefix)
+	base := "report-" + report.Date
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var page bytes.Buffer
+	if err := report.WriteHTML(&page); err != nil {
+		return nil, err
+	}
+
+	files := make([]exportedFile, 0, 2)
+	for _, f := range []struct {
+		key  string
+		data []byte
+	}{
+		{base + ".json", data},
+		{base + ".html", page.Bytes()},
+	} {
+		file, err := putBlob(ctx, store, f.key, f.data)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", f.key, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// supportBundle is what venue staff send along with a bug report: the build,
+// the effective configuration and a snapshot of the hub's state.
+type supportBundle struct {
+	CreatedAt string          `json:"createdAt"`
+	Build     buildinfo.Info  `json:"build"`
+	GameID    string          `json:"gameId"`
+	StartedAt string          `json:"startedAt"`
+	UptimeMs  int64           `json:"uptimeMs"`
+	Config    map[string]any  `json:"config"`
+	Hub       supportHubState `json:"hub"`
+}
+
+type supportHubState struct {
+	Status      hub.Status                 `json:"status"`
+	Sessions    []hub.SessionInfo          `json:"sessions"`
+	Assignments []hub.ControllerAssignment `json:"assignments"`
+	Match       hub.MatchStatus            `json:"match"`
+	Queue       hub.QueueStats             `json:"queue"`
+	Broadcast   hub.BroadcastStats         `json:"broadcast"`
+	Upgrades    hub.UpgradeStats           `json:"upgrades"`
+	Cohorts     []hub.CohortStats          `json:"cohorts"`
+	Shed        hub.ShedStatus             `json:"shed"`
+	SoftLimits  []hub.SoftLimit            `json:"softLimits"`
+	Bans        []hub.Ban                  `json:"bans"`
+}
+
+func (a *App) supportBundle(now time.Time) supportBundle {
+	return supportBundle{
+		CreatedAt: now.UTC().Format(time.RFC3339),
+		Build:     buildinfo.Get(),
+		GameID:    a.cfg.GameID,
+		StartedAt: a.started.UTC().Format(time.RFC3339),
+		UptimeMs:  now.Sub(a.started).Milliseconds(),
+		Config:    a.effectiveConfig(),
+		Hub: supportHubState{
+			Status:      a.hub.Status(),
+			Sessions:    a.hub.Sessions(),
+			Assignments: a.hub.ControllerAssignments(),
+			Match:       a.hub.Match(),
+			Queue:       a.hub.QueueStats(),
+			Broadcast:   a.hub.BroadcastStats(),
+			Upgrades:    a.hub.UpgradeStats(),
+			Cohorts:     a.hub.CohortStats(),
+			Shed:        a.hub.ShedStatus(),
+			SoftLimits:  a.hub.SoftLimits(),
+			Bans:        a.hub.Bans(),
+		},
+	}
+}
+
+// writeSupportBundle stores a new bundle as support/bundle-<time>.json.
+func (a *App) writeSupportBundle(ctx context.Context) (exportedFile, error) {
+	if a.storage == nil {
+		return exportedFile{}, errStorageDisabled
+	}
+	now := time.Now()
+	data, err := json.MarshalIndent(a.supportBundle(now), "", "  ")
+	if err != nil {
+		return exportedFile{}, err
+	}
+	key := "bundle-" + now.UTC().Format("20060102T150405Z") + ".json"
+	file, err := putBlob(ctx, blob.WithPrefix(a.storage, supportPrefix), key, data)
+	if err != nil {
+		return exportedFile{}, err
+	}
+	file.ModTime = now.UTC().Format(time.RFC3339)
+	return file, nil
+}
+
+// adminSupportHandler serves /api/admin/support. POST writes a support
+// bundle to STORAGE_URL; GET lists the stored bundles, or downloads one with
+// ?key=bundle-....json.
+func (a *App) adminSupportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.storage == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodeStorageOff, errStorageDisabled.Error())
+		return
+	}
+	store := blob.WithPrefix(a.storage, supportPrefix)
+
+	if r.Method == http.MethodPost {
+		file, err := a.writeSupportBundle(r.Context())
+		if err != nil {
+			a.requestLogger(r).Error("support_bundle_failed", "err", err.Error())
+			a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to write support bundle")
+			return
+		}
+		a.requestLogger(r).Info("support_bundle_written", "key", file.Key, "store", store.String())
+		a.respondJSON(w, http.StatusCreated, file)
+		return
+	}
+
+	if key := strings.TrimSpace(r.URL.Query().Get("key")); key != "" {
+		body, err := store.Open(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, blob.ErrNotFound) || blob.ValidKey(key) != nil {
+				a.respondError(w, http.StatusNotFound, errCodeNotFound, "support bundle not found: "+key)
+				return
+			}
+			a.respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+		defer body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+path.Base(key)+`"`)
+		_, _ = io.Copy(w, body)
+		return
+	}
+
+	infos, err := store.List(r.Context(), "")
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	bundles := make([]exportedFile, 0, len(infos))
+	for _, info := range infos {
+		bundles = append(bundles, exportedFile{
+			Key:     info.Key,
+			Size:    info.Size,
+			ModTime: info.ModTime.UTC().Format(time.RFC3339),
+		})
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"store": store.String(), "bundles": bundles})
+}
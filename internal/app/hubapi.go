@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// Hub is the part of the WebSocket hub the HTTP layer depends on. New wires
+// in *hub.Hub; handlers can be exercised against hub.Fake instead.
+type Hub interface {
+	HandleWS(w http.ResponseWriter, r *http.Request)
+	HandlePoll(w http.ResponseWriter, r *http.Request)
+	HandleSocketIO(w http.ResponseWriter, r *http.Request)
+	Shutdown(ctx context.Context)
+	Status() hub.Status
+	Sessions() []hub.SessionInfo
+
+	IssueControllerToken(slotID, userID, name, personality, cohort string, ttl time.Duration) (string, time.Time, error)
+	ClaimSlot(slotID, name, cohort string, ttl time.Duration) (hub.SlotClaim, error)
+	HandoffSlot(slotID, userID, name, personality string) (string, error)
+	RevokeSlotToken(slotID string) bool
+	RevokeToken(token string) bool
+	RevokeSubjectTokens(scope hub.TokenScope, subject string) int
+	ControllerAssignments() []hub.ControllerAssignment
+	ControllerTokens() []hub.ControllerToken
+	SetSlotMetadata(slotID string, meta map[string]string) error
+	SlotMetadata() map[string]map[string]string
+	AssignmentsChanged() <-chan struct{}
+	RestoreAssignments(assignments []hub.ControllerAssignment)
+	ClearRestoredAssignments()
+	IssueToken(req hub.TokenRequest) (string, time.Time, error)
+	VerifyToken(token string, scope hub.TokenScope, audience string) (hub.Principal, error)
+	JWKS() (hub.JWKSet, bool)
+
+	DisconnectControllers(ctx context.Context, slots []string, reason string, includeGame bool) int
+	Kick(ctx context.Context, slotID, reason string) error
+	Ban(ctx context.Context, subject string, duration time.Duration, reason string) (int, error)
+	Unban(subject string) bool
+	Bans() []hub.Ban
+	InjectFrame(slotID, msgType string, payload []byte) bool
+	RunLocal(ctx context.Context, slotID string, frames <-chan []byte) (websocket.StatusCode, string)
+	InputProfiles() map[string][]string
+	SetInputProfile(slot string, types []string) []string
+	ClearInputProfile(slot string) bool
+
+	MarkMatchStart(t time.Time)
+	MatchStartedAt() time.Time
+	ClearMatchStart()
+	MatchTimer() (start time.Time, elapsed time.Duration, running bool)
+	Match() hub.MatchStatus
+	AdvanceMatch(state hub.MatchState, source string) error
+	ResetMatch(source string)
+	NotifyGameStart(slots []string, forced bool, connected int) bool
+	BroadcastMatchStart(slots []string, countdown time.Duration)
+	HealRelay() error
+
+	SubscribeEvents(buffer int) *hub.EventSubscription
+	PublishEvent(eventType string, kv ...any)
+	SetMaxControllers(n int) error
+	SetAccepting(accepting bool)
+	Accepting() bool
+	SetRelayTap(tap hub.RelayTap)
+	Channels() []string
+	QueueStats() hub.QueueStats
+	BroadcastStats() hub.BroadcastStats
+	UpgradeStats() hub.UpgradeStats
+	CohortStats() []hub.CohortStats
+	CheckCohort(label string) error
+	ShedStatus() hub.ShedStatus
+	SoftLimits() []hub.SoftLimit
+	ObserveSoftLimit(name string, hard, size int)
+
+	RunIdleMonitor(ctx context.Context)
+	RunLoadMonitor(ctx context.Context)
+	RunMatchTimer(ctx context.Context)
+	RunLatencyHints(ctx context.Context)
+	RunSoftLimitMonitor(ctx context.Context)
+}
+
+var (
+	_ Hub = (*hub.Hub)(nil)
+	_ Hub = (*hub.Fake)(nil)
+)
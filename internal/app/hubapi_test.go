@@ -0,0 +1,300 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+const (
+	testAPIKey     = "test-key"
+	testAdminToken = "test-admin"
+)
+
+// newFakeApp returns the public router of an App whose hub is a hub.Fake
+// with four slots, guarded by testAPIKey and testAdminToken.
+func newFakeApp(t *testing.T) (http.Handler, *hub.Fake) {
+	t.Helper()
+	fake := hub.NewFake(4)
+	a := &App{
+		cfg: config.Config{
+			GameID:     "test",
+			APIKeys:    []string{testAPIKey},
+			AdminToken: testAdminToken,
+		},
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		hub:         fake,
+		started:     time.Now(),
+		results:     newResultQueue(),
+		leaderboard: newLeaderboard(time.Now()),
+	}
+	return a.buildRouter(newStaticFiles(http.Dir(t.TempDir()), false, 0, nil)), fake
+}
+
+// call serves one request and decodes a JSON response into out when it is
+// not nil.
+func call(t *testing.T, h http.Handler, method, target, token, body string, out any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func connectSlot(t *testing.T, fake *hub.Fake, slotID, userID, name string) {
+	t.Helper()
+	if _, _, err := fake.IssueControllerToken(slotID, userID, name, "", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	fake.Connect(slotID)
+}
+
+func TestAPIKeyRequired(t *testing.T) {
+	h, _ := newFakeApp(t)
+	var apiErr struct {
+		Code string `json:"code"`
+	}
+	if code := call(t, h, http.MethodGet, "/api/controller/assignments", "", "", &apiErr); code != http.StatusUnauthorized || apiErr.Code != errCodeAuthRequired {
+		t.Errorf("no key: %d %+v", code, apiErr)
+	}
+	if code := call(t, h, http.MethodGet, "/api/controller/assignments", "wrong", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("wrong key: %d", code)
+	}
+	if code := call(t, h, http.MethodGet, "/api/controller/assignments", testAdminToken, "", nil); code != http.StatusOK {
+		t.Errorf("admin token: %d", code)
+	}
+	if code := call(t, h, http.MethodGet, "/api/admin/ban", testAPIKey, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("api key on an admin route: %d", code)
+	}
+}
+
+func TestControllerAssignments(t *testing.T) {
+	h, fake := newFakeApp(t)
+	connectSlot(t, fake, "p1", "u1", "Alice")
+	if _, _, err := fake.IssueControllerToken("p2", "u2", "Bob", "", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp assignmentsResponse
+	if code := call(t, h, http.MethodGet, "/api/controller/assignments", testAPIKey, "", &resp); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(resp.Assignments) != 2 {
+		t.Fatalf("assignments = %+v", resp.Assignments)
+	}
+	p1, p2 := resp.Assignments[0], resp.Assignments[1]
+	if p1.SlotID != "p1" || p1.UserID != "u1" || p1.Name != "Alice" || !p1.Connected || p1.LastSeen == nil || p1.TokenExpiresAt == nil {
+		t.Errorf("p1 = %+v", p1)
+	}
+	if p2.SlotID != "p2" || p2.UserID != "u2" || p2.Connected {
+		t.Errorf("p2 = %+v", p2)
+	}
+}
+
+func TestControllerSessionRevoke(t *testing.T) {
+	h, fake := newFakeApp(t)
+	connectSlot(t, fake, "p1", "u1", "Alice")
+
+	var resp struct {
+		SlotID       string `json:"slotId"`
+		Revoked      bool   `json:"revoked"`
+		Disconnected bool   `json:"disconnected"`
+	}
+	if code := call(t, h, http.MethodDelete, "/api/controller/session/P1?disconnect=true", testAPIKey, "", &resp); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if resp.SlotID != "p1" || !resp.Revoked || !resp.Disconnected {
+		t.Errorf("response = %+v", resp)
+	}
+	if tokens := fake.ControllerTokens(); len(tokens) != 0 {
+		t.Errorf("tokens left: %+v", tokens)
+	}
+	if n := fake.Status().Controllers; n != 0 {
+		t.Errorf("controllers connected = %d", n)
+	}
+
+	if code := call(t, h, http.MethodDelete, "/api/controller/session/p2", testAPIKey, "", nil); code != http.StatusNotFound {
+		t.Errorf("unknown slot: %d", code)
+	}
+	if code := call(t, h, http.MethodGet, "/api/controller/session/p1", testAPIKey, "", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d", code)
+	}
+}
+
+func TestAdminKickAndBan(t *testing.T) {
+	h, fake := newFakeApp(t)
+
+	kick := `{"slotId":"p1","reason":"test"}`
+	if code := call(t, h, http.MethodPost, "/api/admin/kick", testAdminToken, kick, nil); code != http.StatusNotFound {
+		t.Errorf("kick of an empty slot: %d", code)
+	}
+	connectSlot(t, fake, "p1", "u1", "Alice")
+	if code := call(t, h, http.MethodPost, "/api/admin/kick", testAdminToken, kick, nil); code != http.StatusOK {
+		t.Errorf("kick: %d", code)
+	}
+	if n := fake.Status().Controllers; n != 0 {
+		t.Errorf("controllers after kick = %d", n)
+	}
+
+	fake.Connect("p1")
+	var banned banResponse
+	if code := call(t, h, http.MethodPost, "/api/admin/ban", testAdminToken, `{"subject":"u1","duration":"10m"}`, &banned); code != http.StatusOK {
+		t.Fatalf("ban: %d", code)
+	}
+	if banned.Subject != "u1" || banned.Kicked == nil || *banned.Kicked != 1 {
+		t.Errorf("ban = %+v", banned)
+	}
+	if _, _, err := fake.IssueControllerToken("p2", "u1", "Alice", "", "", time.Minute); !errors.Is(err, hub.ErrBanned) {
+		t.Errorf("token for a banned user: err = %v", err)
+	}
+	var list bansResponse
+	call(t, h, http.MethodGet, "/api/admin/ban", testAdminToken, "", &list)
+	if len(list.Bans) != 1 || list.Bans[0].Subject != "u1" || time.Until(list.Bans[0].Until) < 9*time.Minute {
+		t.Errorf("bans = %+v", list.Bans)
+	}
+	if code := call(t, h, http.MethodPost, "/api/admin/ban", testAdminToken, `{"subject":"u1","duration":"-1m"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("negative duration: %d", code)
+	}
+
+	if code := call(t, h, http.MethodDelete, "/api/admin/ban?subject=u1", testAdminToken, "", nil); code != http.StatusNoContent {
+		t.Errorf("unban: %d", code)
+	}
+	if code := call(t, h, http.MethodDelete, "/api/admin/ban?subject=u1", testAdminToken, "", nil); code != http.StatusNotFound {
+		t.Errorf("second unban: %d", code)
+	}
+	if len(fake.Bans()) != 0 {
+		t.Errorf("bans left: %+v", fake.Bans())
+	}
+}
+
+func TestAdminHandoff(t *testing.T) {
+	h, fake := newFakeApp(t)
+	body := `{"slotId":"p1","userId":"u2","name":"Bob"}`
+	if code := call(t, h, http.MethodPost, "/api/admin/handoff", testAdminToken, body, nil); code != http.StatusConflict {
+		t.Errorf("handoff of an empty slot: %d", code)
+	}
+
+	connectSlot(t, fake, "p1", "u1", "Alice")
+	var resp struct {
+		PreviousUserID string `json:"previousUserId"`
+	}
+	if code := call(t, h, http.MethodPost, "/api/admin/handoff", testAdminToken, body, &resp); code != http.StatusOK {
+		t.Fatalf("handoff: %d", code)
+	}
+	if resp.PreviousUserID != "u1" {
+		t.Errorf("previousUserId = %q", resp.PreviousUserID)
+	}
+	got := fake.ControllerAssignments()
+	if len(got) != 1 || got[0].UserID != "u2" || got[0].Name != "Bob" || !got[0].Connected {
+		t.Errorf("assignments = %+v", got)
+	}
+}
+
+func TestAdminSlotMeta(t *testing.T) {
+	h, fake := newFakeApp(t)
+	connectSlot(t, fake, "p1", "u1", "Alice")
+
+	if code := call(t, h, http.MethodPost, "/api/admin/slots/meta", testAdminToken, `{"slotId":"P1","meta":{"color":"red"}}`, nil); code != http.StatusOK {
+		t.Fatalf("set: %d", code)
+	}
+	var resp assignmentsResponse
+	call(t, h, http.MethodGet, "/api/controller/assignments", testAPIKey, "", &resp)
+	if len(resp.Assignments) != 1 || resp.Assignments[0].Meta["color"] != "red" {
+		t.Errorf("assignments = %+v", resp.Assignments)
+	}
+
+	if code := call(t, h, http.MethodPost, "/api/admin/slots/meta", testAdminToken, `{"slotId":"p1"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("set without meta: %d", code)
+	}
+	if code := call(t, h, http.MethodDelete, "/api/admin/slots/meta?slotId=p1", testAdminToken, "", nil); code != http.StatusNoContent {
+		t.Errorf("clear: %d", code)
+	}
+	if meta := fake.SlotMetadata(); len(meta) != 0 {
+		t.Errorf("metadata left: %+v", meta)
+	}
+	if code := call(t, h, http.MethodDelete, "/api/admin/slots/meta?slotId=p1", testAdminToken, "", nil); code != http.StatusNotFound {
+		t.Errorf("second clear: %d", code)
+	}
+}
+
+func TestControllerAssignmentsStream(t *testing.T) {
+	h, fake := newFakeApp(t)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/controller/assignments/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	type update struct {
+		Reason      string               `json:"reason"`
+		SlotID      string               `json:"slotId"`
+		Assignments []assignmentResponse `json:"assignments"`
+	}
+	lines := bufio.NewScanner(resp.Body)
+	next := func() update {
+		t.Helper()
+		for lines.Scan() {
+			data, ok := strings.CutPrefix(lines.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var u update
+			if err := json.Unmarshal([]byte(data), &u); err != nil {
+				t.Fatal(err)
+			}
+			return u
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return update{}
+	}
+
+	if u := next(); u.Reason != "snapshot" || len(u.Assignments) != 0 {
+		t.Errorf("first update = %+v", u)
+	}
+	if _, _, err := fake.IssueControllerToken("p3", "u3", "Carol", "", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	u := next()
+	if u.Reason != "token_issued" || u.SlotID != "p3" || len(u.Assignments) != 1 || u.Assignments[0].UserID != "u3" {
+		t.Errorf("update = %+v", u)
+	}
+}
@@ -0,0 +1,514 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// defaultRoomID names the single room a hub instance serves.
+const defaultRoomID = "default"
+
+func (a *App) adminSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions := a.hub.Sessions()
+	resp := make([]map[string]any, 0, len(sessions))
+	for _, s := range sessions {
+		entry := map[string]any{
+			"role":        s.Role,
+			"id":          s.ID,
+			"remoteIp":    s.RemoteIP,
+			"connectedAt": s.ConnectedAt.UTC().Format(time.RFC3339),
+		}
+		if s.UserID != "" {
+			entry["userId"] = s.UserID
+		}
+		if s.Cohort != "" {
+			entry["cohort"] = s.Cohort
+		}
+		if !s.LastSeen.IsZero() {
+			entry["lastSeen"] = s.LastSeen.UTC().Format(time.RFC3339)
+		}
+		if s.RTT > 0 {
+			entry["rttMs"] = float64(s.RTT.Microseconds()) / 1000
+		}
+		if s.Mirror {
+			entry["mirror"] = true
+		}
+		if t := s.Telemetry; t != nil {
+			entry["telemetry"] = telemetryResponse(t)
+		}
+		if len(s.Calibrations) > 0 {
+			entry["calibrations"] = calibrationResponses(s.Calibrations)
+		}
+		resp = append(resp, entry)
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"sessions": resp})
+}
+
+func (a *App) adminRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := a.hub.Status()
+	room := map[string]any{
+		"id":             defaultRoomID,
+		"gameConnected":  status.GameConnected,
+		"gameRemoteIp":   status.GameRemoteIP,
+		"controllers":    status.Controllers,
+		"maxControllers": status.MaxControllers,
+		"epoch":          status.Epoch,
+		"mirrors":        status.Mirrors,
+		"maxGames":       status.MaxGames,
+		"passthrough":    a.cfg.Passthrough,
+	}
+	if a.osc != nil {
+		room["osc"] = map[string]any{
+			"target":  a.osc.Target(),
+			"sent":    a.osc.Sent(),
+			"dropped": a.osc.Dropped(),
+		}
+	}
+	if a.mqtt != nil {
+		room["mqtt"] = map[string]any{
+			"broker":    a.mqtt.Broker(),
+			"connected": a.mqtt.Connected(),
+			"sent":      a.mqtt.Sent(),
+			"dropped":   a.mqtt.Dropped(),
+		}
+	}
+	if a.midi != nil {
+		room["midi"] = map[string]any{
+			"device":  a.midi.Device(),
+			"sent":    a.midi.Sent(),
+			"dropped": a.midi.Dropped(),
+		}
+	}
+	if a.localInput != nil {
+		room["local_input"] = map[string]any{
+			"device":    a.localInput.Device(),
+			"slot":      a.localInput.Slot(),
+			"connected": a.localInput.Connected(),
+			"frames":    a.localInput.Frames(),
+		}
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"rooms": []map[string]any{room}})
+}
+
+func (a *App) adminTokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		a.revokeTokenHandler(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req tokenRequest
+	if !a.decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	ttl := a.cfg.SessionTokenTTL
+	if raw := strings.TrimSpace(req.TTL); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "ttl must be a positive Go duration such as \"5m\"")
+			return
+		}
+		ttl = parsed
+	}
+
+	scope, err := hub.ParseTokenScope(req.Scope)
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	if scope == hub.ScopeAdmin && strings.TrimSpace(req.Audience) == "" {
+		// The admin routes only accept tokens bound to their audience.
+		req.Audience = adminAudience
+	}
+	if scope != hub.ScopeController {
+		a.issuePrincipalToken(w, hub.TokenRequest{
+			Scope:    scope,
+			Subject:  req.Subject,
+			Audience: req.Audience,
+			Claims:   req.Claims,
+			TTL:      ttl,
+		})
+		return
+	}
+
+	token, expiresAt, err := a.hub.IssueControllerToken(req.SlotID, req.UserID, req.Name, req.Personality, strings.TrimSpace(req.Cohort), ttl)
+	if err != nil {
+		if errors.Is(err, hub.ErrBanned) {
+			a.respondError(w, http.StatusForbidden, errCodeUserBanned, err.Error())
+			return
+		}
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	slotID := strings.ToLower(strings.TrimSpace(req.SlotID))
+	a.requestLogger(r).Info("admin_token_issued", "slot", slotID, "user_id", strings.TrimSpace(req.UserID), "ttl", ttl.String())
+	resp := map[string]any{
+		"scope":     hub.ScopeController,
+		"slotId":    slotID,
+		"token":     token,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	}
+	if req.JoinCode {
+		code, err := a.joins.issue(joinTarget{
+			slotID:    slotID,
+			token:     token,
+			userID:    strings.TrimSpace(req.UserID),
+			name:      strings.TrimSpace(req.Name),
+			expiresAt: expiresAt,
+		})
+		if err != nil {
+			a.requestLogger(r).Error("join_code_issue_failed", "slot", slotID, "err", err.Error())
+			a.respondError(w, http.StatusServiceUnavailable, errCodeInternal, "failed to issue join code")
+			return
+		}
+		resp["joinCode"] = code
+		resp["joinPath"] = joinPathPrefix + code
+	}
+	a.respondJSON(w, http.StatusCreated, resp)
+}
+
+// tokenRequest is the body of POST /api/admin/tokens. Scope picks which of
+// the fields apply: the slot and user fields for controller tokens, Subject
+// and Audience for admin and spectator ones.
+type tokenRequest struct {
+	Scope       string            `json:"scope,omitempty"`
+	SlotID      string            `json:"slotId,omitempty"`
+	UserID      string            `json:"userId,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Personality string            `json:"personality,omitempty"`
+	Cohort      string            `json:"cohort,omitempty"`
+	Subject     string            `json:"subject,omitempty"`
+	Audience    string            `json:"audience,omitempty"`
+	Claims      map[string]string `json:"claims,omitempty"`
+	TTL         string            `json:"ttl,omitempty"`
+	JoinCode    bool              `json:"joinCode,omitempty"`
+}
+
+// revokeTokenHandler withdraws tokens: the controller token of the slot named
+// by the slotId query parameter, every admin or spectator token of the scope
+// and subject query parameters, or the single token whose value is sent as
+// {"token": "..."} in the body so it stays out of access logs. A connected
+// controller stays connected.
+func (a *App) revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if slotID := strings.ToLower(strings.TrimSpace(query.Get("slotId"))); slotID != "" {
+		if !a.hub.RevokeSlotToken(slotID) {
+			a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotFound, "no token for slot "+slotID, map[string]any{"slotId": slotID})
+			return
+		}
+		a.requestLogger(r).Info("admin_token_revoked", "slot", slotID)
+		a.hub.PublishEvent("token_revoked", "slotId", slotID)
+		a.respondJSON(w, http.StatusOK, map[string]any{"slotId": slotID, "revoked": true})
+		return
+	}
+
+	if subject := strings.TrimSpace(query.Get("subject")); subject != "" {
+		scope, err := hub.ParseTokenScope(query.Get("scope"))
+		if err != nil || scope == hub.ScopeController {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "scope must be admin or spectator; revoke controller tokens by slotId")
+			return
+		}
+		revoked := a.hub.RevokeSubjectTokens(scope, subject)
+		if revoked == 0 {
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "no "+string(scope)+" token for "+subject)
+			return
+		}
+		a.requestLogger(r).Info("admin_token_revoked", "scope", scope, "subject", subject, "count", revoked)
+		a.hub.PublishEvent("token_revoked", "scope", string(scope), "subject", subject)
+		a.respondJSON(w, http.StatusOK, map[string]any{"scope": scope, "subject": subject, "revoked": revoked})
+		return
+	}
+
+	var req revokeTokenRequest
+	if !a.decodeJSONBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Token) == "" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId, subject or token is required")
+		return
+	}
+	if !a.hub.RevokeToken(req.Token) {
+		a.respondError(w, http.StatusNotFound, errCodeNotFound, "token not found")
+		return
+	}
+	a.requestLogger(r).Info("admin_token_revoked", "by", "value")
+	a.hub.PublishEvent("token_revoked")
+	a.respondJSON(w, http.StatusOK, map[string]any{"revoked": 1})
+}
+
+// revokeTokenRequest is the body of DELETE /api/admin/tokens when the token
+// itself is revoked.
+type revokeTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// issuePrincipalToken issues an admin or spectator token.
+func (a *App) issuePrincipalToken(w http.ResponseWriter, req hub.TokenRequest) {
+	token, expiresAt, err := a.hub.IssueToken(req)
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	a.respondJSON(w, http.StatusCreated, map[string]any{
+		"scope":     req.Scope,
+		"subject":   strings.TrimSpace(req.Subject),
+		"audience":  strings.TrimSpace(req.Audience),
+		"token":     token,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// adminConfigHandler exposes the effective configuration. PATCH changes the
+// few settings that can be tuned without a restart.
+func (a *App) adminConfigHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.respondJSON(w, http.StatusOK, map[string]any{"config": a.effectiveConfig()})
+
+	case http.MethodPatch:
+		var req configPatchRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+		key := strings.TrimSpace(req.Key)
+		if err := a.setRuntimeConfig(key, strings.TrimSpace(req.Value)); err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		a.requestLogger(r).Info("admin_config_set", "key", key, "value", req.Value)
+		a.respondJSON(w, http.StatusOK, map[string]any{"config": a.effectiveConfig()})
+
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// configPatchRequest is the body of PATCH /api/admin/config.
+type configPatchRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// runtimeConfigKeys lists the settings accepted by PATCH /api/admin/config.
+var runtimeConfigKeys = []string{"log-level", "max-controllers"}
+
+func (a *App) setRuntimeConfig(key, value string) error {
+	switch key {
+	case "log-level":
+		if a.levels == nil {
+			return errors.New("log level is not adjustable in this process")
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid log level %q", value)
+		}
+		a.levels.Set(level)
+		return nil
+	case "max-controllers":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max-controllers %q", value)
+		}
+		return a.hub.SetMaxControllers(n)
+	default:
+		return fmt.Errorf("unknown or read-only key %q (settable: %s)", key, strings.Join(runtimeConfigKeys, ", "))
+	}
+}
+
+// EffectiveConfig returns the settings the hub is running with, as served
+// by /api/admin/config. Secrets are reduced to whether they are set.
+func (a *App) EffectiveConfig() map[string]any {
+	return a.effectiveConfig()
+}
+
+func (a *App) effectiveConfig() map[string]any {
+	level := slog.LevelInfo
+	if a.levels != nil {
+		level = a.levels.Level()
+	}
+	return map[string]any{
+		"addr":                   a.cfg.Addr,
+		"admin-addr":             a.cfg.AdminAddr,
+		"tls":                    a.tlsEnabled(),
+		"http2":                  a.cfg.HTTP2,
+		"h2c":                    a.cfg.H2C,
+		"origins":                a.cfg.Origins,
+		"trusted-proxies":        a.cfg.TrustedProxies,
+		"max-controllers":        a.hub.Status().MaxControllers,
+		"rate-hz":                a.cfg.RateHz,
+		"broadcast-rate-hz":      a.cfg.BroadcastRateHz,
+		"input-rate-hz":          a.cfg.InputRateHz,
+		"queue-policy":           a.cfg.QueuePolicy,
+		"coalesce-input":         a.cfg.CoalesceInput,
+		"priority-types":         a.cfg.PriorityTypes,
+		"controller-id-fields":   a.cfg.ControllerIDFields,
+		"id-mismatch":            a.cfg.IDMismatch,
+		"relay-timestamp":        a.cfg.RelayTimestamp,
+		"relay-envelope":         a.cfg.RelayEnvelope,
+		"relay-signing-key":      a.cfg.RelaySigningKey != "",
+		"passthrough":            a.cfg.Passthrough,
+		"state-delta":            a.cfg.StateDelta,
+		"load-shedding":          a.cfg.LoadShedding,
+		"register-timeout":       a.cfg.RegisterTimeout.String(),
+		"idle-timeout":           a.cfg.IdleTimeout.String(),
+		"visibility-grace":       a.cfg.VisibilityGrace.String(),
+		"stale-after":            a.cfg.StaleAfter.String(),
+		"timer-interval":         a.cfg.TimerInterval.String(),
+		"latency-hint-interval":  a.cfg.LatencyHintInterval.String(),
+		"calibration-timeout":    a.cfg.CalibrationTimeout.String(),
+		"result-reminder-after":  a.cfg.ResultReminderAfter.String(),
+		"result-retry-attempts":  a.cfg.ResultRetryAttempts,
+		"visit-cooldown":         a.cfg.VisitCooldown.String(),
+		"visit-daily-limit":      a.cfg.VisitDailyLimit,
+		"leaderboard-history":    a.cfg.LeaderboardHistory,
+		"lobby-reconcile":        a.cfg.LobbyReconcileInterval.String(),
+		"lobby-dry-run":          a.cfg.LobbyReconcileDryRun,
+		"alert-hook":             redactURL(a.cfg.AlertWebhookURL),
+		"write-timeout":          a.cfg.WriteTimeout.String(),
+		"shutdown-timeout":       a.cfg.ShutdownTimeout.String(),
+		"session-token-ttl":      a.cfg.SessionTokenTTL.String(),
+		"persona":                a.persona != nil,
+		"game-id":                a.cfg.GameID,
+		"attraction-id":          a.cfg.AttractionID,
+		"db-api-version":         a.cfg.DBAPIVersion,
+		"persona-required":       a.cfg.PersonaRequired,
+		"state-file":             a.cfg.StateFile,
+		"static-overlay-dir":     a.cfg.StaticOverlayDir,
+		"record-dir":             a.cfg.RecordDir,
+		"activity-dir":           a.cfg.ActivityDir,
+		"storage-url":            a.cfg.StorageURL,
+		"socketio":               a.cfg.SocketIO,
+		"osc-target":             a.cfg.OSCTarget,
+		"osc-prefix":             a.cfg.OSCPrefix,
+		"osc-types":              a.cfg.OSCTypes,
+		"midi-device":            a.cfg.MIDIDevice,
+		"midi-map":               a.cfg.MIDIMap,
+		"local-input-device":     a.cfg.LocalInputDevice,
+		"local-input-slot":       a.cfg.LocalInputSlot,
+		"mqtt-url":               redactURL(a.cfg.MQTTURL),
+		"mqtt-client-id":         a.cfg.MQTTClientID,
+		"mqtt-event-topic":       a.cfg.MQTTEventTopic,
+		"mqtt-input-topic":       a.cfg.MQTTInputTopic,
+		"mqtt-status-topic":      a.cfg.MQTTStatusTopic,
+		"mqtt-input-interval":    a.cfg.MQTTInputInterval.String(),
+		"mqtt-qos":               a.cfg.MQTTQoS,
+		"mqtt-retain":            a.cfg.MQTTRetain,
+		"compression":            a.cfg.Compression,
+		"compression-level":      a.cfg.CompressionLevel,
+		"compression-min-size":   a.cfg.CompressionMinSize,
+		"static-hashed-names":    a.cfg.StaticHashedNames,
+		"static-max-age":         a.cfg.StaticMaxAge.String(),
+		"spa-fallback":           a.cfg.SPAFallback,
+		"crash-dir":              a.cfg.CrashDir,
+		"allow-anonymous":        a.cfg.AllowAnonymous,
+		"game-token":             a.cfg.GameToken != "",
+		"admin-token":            a.cfg.AdminToken != "",
+		"api-keys":               len(a.cfg.APIKeys),
+		"controller-auth":        a.cfg.ControllerSessionAuth,
+		"token-format":           a.cfg.TokenFormat,
+		"token-signing-key":      a.cfg.TokenSigningKey,
+		"token-verify-keys":      a.cfg.TokenVerifyKeys,
+		"session-rate":           fmt.Sprintf("%d/min burst %d", a.cfg.SessionRateLimit, a.cfg.SessionRateBurst),
+		"api-rate":               fmt.Sprintf("%d/min burst %d", a.cfg.APIRateLimit, a.cfg.APIRateBurst),
+		"max-conns-per-ip":       a.cfg.MaxConnsPerIP,
+		"max-pending-per-ip":     a.cfg.MaxPendingPerIP,
+		"game-listeners":         a.cfg.GameListeners,
+		"handover-drain":         a.cfg.HandoverDrain.String(),
+		"assignments-hook":       redactURL(a.cfg.AssignmentsWebhookURL),
+		"lifecycle-hooks":        len(a.cfg.LifecycleWebhookURLs),
+		"lifecycle-hook-secret":  a.cfg.LifecycleWebhookSecret != "",
+		"watchdog":               fmt.Sprintf("%s x%d", a.cfg.WatchdogInterval, a.cfg.WatchdogFailures),
+		"log-level":              strings.ToLower(level.String()),
+		"runtime-settable":       runtimeConfigKeys,
+		"min-protocol":           a.cfg.MinProtocolVersion,
+		"cohorts":                a.cfg.Cohorts,
+		"input-profiles":         a.cfg.InputProfiles,
+		"soft-limits":            a.cfg.SoftLimits,
+		"register-lockout":       a.cfg.RegisterLockout.String(),
+		"register-failure-limit": a.cfg.RegisterFailureLimit,
+	}
+}
+
+// redactURL drops credentials and the query string, which commonly carry
+// secrets for webhook endpoints.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid)"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
+
+func telemetryResponse(t *hub.Telemetry) map[string]any {
+	resp := map[string]any{
+		"updatedAt": t.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if t.Battery != nil {
+		resp["battery"] = *t.Battery
+	}
+	if t.Charging != nil {
+		resp["charging"] = *t.Charging
+	}
+	if t.Visibility != "" {
+		resp["visibility"] = t.Visibility
+	}
+	if t.Network != "" {
+		resp["network"] = t.Network
+	}
+	return resp
+}
+
+func calibrationResponses(calibrations []hub.Calibration) []map[string]any {
+	resp := make([]map[string]any, 0, len(calibrations))
+	for _, c := range calibrations {
+		entry := map[string]any{
+			"calibrationId": c.ID,
+			"kind":          c.Kind,
+			"status":        c.Status,
+			"requestedAt":   c.RequestedAt.UTC().Format(time.RFC3339),
+		}
+		if !c.CompletedAt.IsZero() {
+			entry["completedAt"] = c.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		if len(c.Data) > 0 {
+			entry["data"] = c.Data
+		}
+		if c.Error != "" {
+			entry["error"] = c.Error
+		}
+		resp = append(resp, entry)
+	}
+	return resp
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Join code format and limits. Join codes keep QR codes small: instead of a
+// full controller token the code encodes a short path such as /j/AB3F7K. The
+// hub resolves the code and redirects to the controller page with the
+// session in the URL fragment, which browsers never send back to the server.
+// A code works once and expires with its token. Guessing is held back by the
+// session rate limit and by locking out addresses that keep missing.
+const (
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	joinCodeLength   = 6
+	joinCodeAttempts = 8
+	joinPathPrefix   = "/j/"
+
+	// joinFailureLimit misses within joinFailureWindow lock an address out
+	// of /j/ for joinLockout.
+	joinFailureLimit  = 5
+	joinFailureWindow = 10 * time.Minute
+	joinLockout       = 10 * time.Minute
+)
+
+var errJoinCodesExhausted = errors.New("no free join code")
+
+type joinTarget struct {
+	slotID    string
+	token     string
+	userID    string
+	name      string
+	expiresAt time.Time
+}
+
+type joinCodes struct {
+	mu       sync.Mutex
+	codes    map[string]joinTarget
+	failures map[string]joinFailures
+}
+
+// joinFailures counts an address's misses since start.
+type joinFailures struct {
+	start       time.Time
+	count       int
+	lockedUntil time.Time
+}
+
+// issue stores target under a fresh code and returns it.
+func (j *joinCodes) issue(target joinTarget) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	if j.codes == nil {
+		j.codes = make(map[string]joinTarget)
+	}
+	for code, existing := range j.codes {
+		if !now.Before(existing.expiresAt) {
+			delete(j.codes, code)
+		}
+	}
+
+	for attempt := 0; attempt < joinCodeAttempts; attempt++ {
+		code, err := randomJoinCode()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := j.codes[code]; taken {
+			continue
+		}
+		j.codes[code] = target
+		return code, nil
+	}
+	return "", errJoinCodesExhausted
+}
+
+// redeem returns the target for code and forgets it.
+func (j *joinCodes) redeem(code string) (joinTarget, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	target, ok := j.codes[code]
+	if !ok {
+		return joinTarget{}, false
+	}
+	delete(j.codes, code)
+	if !time.Now().Before(target.expiresAt) {
+		return joinTarget{}, false
+	}
+	return target, true
+}
+
+// locked reports how long ip is still locked out, if it is.
+func (j *joinCodes) locked(ip string, now time.Time) (time.Duration, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, ok := j.failures[ip]
+	if !ok || !now.Before(f.lockedUntil) {
+		return 0, false
+	}
+	return f.lockedUntil.Sub(now), true
+}
+
+// fail counts a miss by ip and reports whether it locked ip out.
+func (j *joinCodes) fail(ip string, now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.failures == nil {
+		j.failures = make(map[string]joinFailures)
+	}
+	for addr, f := range j.failures {
+		if now.Sub(f.start) > joinFailureWindow && !now.Before(f.lockedUntil) {
+			delete(j.failures, addr)
+		}
+	}
+	f := j.failures[ip]
+	if now.Sub(f.start) > joinFailureWindow {
+		f = joinFailures{start: now}
+	}
+	f.count++
+	locked := f.count >= joinFailureLimit
+	if locked {
+		f.lockedUntil = now.Add(joinLockout)
+	}
+	j.failures[ip] = f
+	return locked
+}
+
+func randomJoinCode() (string, error) {
+	limit := big.NewInt(int64(len(joinCodeAlphabet)))
+	var b strings.Builder
+	for i := 0; i < joinCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(joinCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// joinRedirectHandler resolves /j/{code} to the controller page.
+func (a *App) joinRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	// HEAD is refused so link previews cannot use up a code.
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip := a.requestIP(r)
+	now := time.Now()
+	if retryAfter, locked := a.joins.locked(ip, now); locked {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		a.respondError(w, http.StatusTooManyRequests, errCodeRateLimited, "too many invalid join codes")
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, joinPathPrefix)))
+	target, ok := a.joins.redeem(code)
+	if !ok {
+		a.requestLogger(r).Warn("join_code_invalid", "code", code, "remote_ip", ip)
+		if a.joins.fail(ip, now) {
+			a.requestLogger(r).Warn("join_code_locked_out", "remote_ip", ip, "lockout", joinLockout.String())
+		}
+		http.Error(w, "join code is invalid or expired", http.StatusNotFound)
+		return
+	}
+
+	a.requestLogger(r).Info("join_code_redeemed", "code", code, "slot", target.slotID, "remote_ip", ip)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, controllerLink(target), http.StatusFound)
+}
+
+// controllerLink returns the controller page path carrying target's session
+// in the URL fragment.
+func controllerLink(target joinTarget) string {
+	fragment := url.Values{}
+	fragment.Set("slot", target.slotID)
+	fragment.Set("token", target.token)
+	fragment.Set("exp", strconv.FormatInt(target.expiresAt.UnixMilli(), 10))
+	if target.userID != "" {
+		fragment.Set("user", target.userID)
+	}
+	if target.name != "" {
+		fragment.Set("name", target.name)
+	}
+	return "/#" + fragment.Encode()
+}
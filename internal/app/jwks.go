@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+const jwksPath = "/.well-known/jwks.json"
+
+// tokenSigner returns the signer for TOKEN_FORMAT=jwt, or nil for opaque
+// tokens. Without TOKEN_SIGNING_KEY a key is generated per process, so
+// verifiers must refetch the key set after every restart.
+func tokenSigner(cfg config.Config, logger *slog.Logger) (*hub.TokenSigner, error) {
+	if cfg.TokenFormat != config.TokenFormatJWT {
+		return nil, nil
+	}
+	if cfg.TokenSigningKey == "" {
+		signer, err := hub.GenerateTokenSigner()
+		if err != nil {
+			return nil, fmt.Errorf("generate token signing key: %w", err)
+		}
+		logger.Warn("token_signing_key_ephemeral", "kid", signer.KeyID(), "hint", "set TOKEN_SIGNING_KEY so tokens survive a restart")
+		return signer, nil
+	}
+	signer, err := hub.LoadTokenSigner(cfg.TokenSigningKey, cfg.TokenVerifyKeys)
+	if err != nil {
+		return nil, fmt.Errorf("load token signing key: %w", err)
+	}
+	logger.Info("token_signing_key_loaded", "kid", signer.KeyID(), "verify_keys", len(cfg.TokenVerifyKeys))
+	return signer, nil
+}
+
+// jwksHandler publishes the keys hub-issued JWTs are signed with so the
+// game and other services can verify controller tokens on their own.
+func (a *App) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	set, ok := a.hub.JWKS()
+	if !ok {
+		a.respondError(w, http.StatusNotFound, errCodeJWTDisabled, "tokens are not JWTs; set TOKEN_FORMAT=jwt")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	a.respondJSON(w, http.StatusOK, set)
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+	"github.com/aritumn2025/cgb-io-hub/internal/state"
+)
+
+// Leaderboard scopes: the scores accepted since this hub process started, or
+// the whole history kept in STATE_FILE.
+const (
+	leaderboardScopeSession = "session"
+	leaderboardScopeAll     = "all"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// leaderboard keeps the scores accepted by /api/game/result so the venue
+// screen can show rankings without a Persona leaderboard API. The oldest
+// scores are dropped beyond LEADERBOARD_HISTORY.
+type leaderboard struct {
+	mu      sync.Mutex
+	since   time.Time
+	records []state.ScoreRecord
+}
+
+func newLeaderboard(since time.Time) leaderboard {
+	return leaderboard{since: since}
+}
+
+func (l *leaderboard) add(records []state.ScoreRecord, capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, records...)
+	if capacity > 0 && len(l.records) > capacity {
+		l.records = slices.Clone(l.records[len(l.records)-capacity:])
+	}
+}
+
+func (l *leaderboard) snapshot() []state.ScoreRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.records)
+}
+
+func (l *leaderboard) restore(records []state.ScoreRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = slices.Clone(records)
+}
+
+// leaderboardQuery selects and orders scores for GET /api/game/leaderboard.
+type leaderboardQuery struct {
+	Scope string
+	// Day, when set, keeps scores of matches started on that local day.
+	Day     time.Time
+	PerUser bool
+	Limit   int
+}
+
+// leaderboardEntry is one ranked score.
+type leaderboardEntry struct {
+	Rank     int       `json:"rank"`
+	SlotID   string    `json:"slotId"`
+	UserID   string    `json:"userId"`
+	Name     string    `json:"name,omitempty"`
+	Score    int       `json:"score"`
+	PlayedAt time.Time `json:"playedAt"`
+}
+
+// leaderboardResponse is the body of GET /api/game/leaderboard. Since is set
+// for the session scope and Date when filtered by day.
+type leaderboardResponse struct {
+	GameID  string             `json:"gameId"`
+	Scope   string             `json:"scope"`
+	PerUser bool               `json:"perUser"`
+	Total   int                `json:"total"`
+	Entries []leaderboardEntry `json:"entries"`
+	Since   string             `json:"since,omitempty"`
+	Date    string             `json:"date,omitempty"`
+}
+
+// rank returns the top scores matching q and how many matched in total.
+// Equal scores share a rank, the earlier one listed first.
+func (l *leaderboard) rank(q leaderboardQuery) ([]leaderboardEntry, int) {
+	l.mu.Lock()
+	records := make([]state.ScoreRecord, 0, len(l.records))
+	for _, rec := range l.records {
+		if q.Scope == leaderboardScopeSession && rec.SubmittedAt.Before(l.since) {
+			continue
+		}
+		if !q.Day.IsZero() && startOfDay(rec.StartTime.In(q.Day.Location())) != startOfDay(q.Day) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	l.mu.Unlock()
+
+	if q.PerUser {
+		best := make(map[string]int, len(records))
+		kept := records[:0]
+		for _, rec := range records {
+			if i, ok := best[rec.UserID]; ok {
+				if rec.Score > kept[i].Score {
+					kept[i] = rec
+				}
+				continue
+			}
+			best[rec.UserID] = len(kept)
+			kept = append(kept, rec)
+		}
+		records = kept
+	}
+
+	slices.SortStableFunc(records, func(x, y state.ScoreRecord) int {
+		if c := cmp.Compare(y.Score, x.Score); c != 0 {
+			return c
+		}
+		return x.StartTime.Compare(y.StartTime)
+	})
+
+	total := len(records)
+	entries := make([]leaderboardEntry, 0, min(total, q.Limit))
+	for i, rec := range records[:min(total, q.Limit)] {
+		rank := i + 1
+		if i > 0 && rec.Score == entries[i-1].Score {
+			rank = entries[i-1].Rank
+		}
+		entries = append(entries, leaderboardEntry{
+			Rank:     rank,
+			SlotID:   rec.SlotID,
+			UserID:   rec.UserID,
+			Name:     rec.Name,
+			Score:    rec.Score,
+			PlayedAt: rec.StartTime,
+		})
+	}
+	return entries, total
+}
+
+// recordMatch notes an accepted result in the leaderboard and the activity
+// journal; queued marks one PersonaGo has not taken yet.
+func (a *App) recordMatch(startTime time.Time, submissions []persona.GameResult, queued bool) {
+	now := time.Now().UTC()
+	records := make([]state.ScoreRecord, 0, len(submissions))
+	for _, res := range submissions {
+		records = append(records, state.ScoreRecord{
+			StartTime:   startTime.UTC(),
+			SubmittedAt: now,
+			SlotID:      fmt.Sprintf("p%d", res.Slot),
+			UserID:      res.UserID,
+			Name:        res.Name,
+			Score:       res.Score,
+		})
+	}
+	a.leaderboard.add(records, a.cfg.LeaderboardHistory)
+	a.saveState()
+	a.journalMatch(startTime, submissions, queued)
+}
+
+// gameLeaderboardHandler serves GET /api/game/leaderboard with
+// ?scope=all|session, ?date=today|yesterday|YYYY-MM-DD, ?perUser=true to
+// keep each user's best score only, and ?limit= (default 10, at most 100).
+func (a *App) gameLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values := r.URL.Query()
+	q := leaderboardQuery{Scope: leaderboardScopeAll, Limit: defaultLeaderboardLimit}
+	switch scope := strings.ToLower(strings.TrimSpace(values.Get("scope"))); scope {
+	case "", leaderboardScopeAll:
+	case leaderboardScopeSession:
+		q.Scope = scope
+	default:
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "scope must be all or session")
+		return
+	}
+	if raw := strings.TrimSpace(values.Get("date")); raw != "" {
+		day, err := activity.ParseDay(raw, time.Now())
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "date must be today, yesterday or YYYY-MM-DD")
+			return
+		}
+		q.Day = day
+	}
+	if raw := strings.TrimSpace(values.Get("perUser")); raw != "" {
+		perUser, err := strconv.ParseBool(raw)
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "perUser must be a boolean")
+			return
+		}
+		q.PerUser = perUser
+	}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxLeaderboardLimit {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxLeaderboardLimit))
+			return
+		}
+		q.Limit = limit
+	}
+
+	entries, total := a.leaderboard.rank(q)
+	resp := leaderboardResponse{
+		GameID:  a.cfg.GameID,
+		Scope:   q.Scope,
+		PerUser: q.PerUser,
+		Total:   total,
+		Entries: entries,
+	}
+	if q.Scope == leaderboardScopeSession {
+		resp.Since = a.leaderboard.since.UTC().Format(time.RFC3339)
+	}
+	if !q.Day.IsZero() {
+		resp.Date = q.Day.Format(time.DateOnly)
+	}
+	a.respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+const (
+	// lifecycleQueueSize bounds the notifications waiting for one URL; a
+	// receiver that stays down loses the oldest news rather than the hub
+	// buffering without limit.
+	lifecycleQueueSize   = 64
+	lifecycleBackoffBase = time.Second
+	lifecycleBackoffMax  = 30 * time.Second
+
+	lifecycleEventHeader     = "X-Hub-Event"
+	lifecycleDeliveryHeader  = "X-Hub-Delivery"
+	lifecycleTimestampHeader = "X-Hub-Timestamp"
+	lifecycleSignatureHeader = "X-Hub-Signature-256"
+)
+
+// lifecycleEvent is the body POSTed to LIFECYCLE_WEBHOOK_URLS, e.g.
+// {"type":"slot_filled","gameId":"Game_1","slotId":"p2","timestamp":...,"data":{"connected":2,"capacity":4}}.
+type lifecycleEvent struct {
+	Type      string         `json:"type"`
+	GameID    string         `json:"gameId"`
+	SlotID    string         `json:"slotId,omitempty"`
+	Timestamp int64          `json:"timestamp"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// lifecycleFromEvent maps a hub event to the notification sent for it, if
+// any. Mirror listeners are not reported as game connections.
+func (a *App) lifecycleFromEvent(ev hub.Event) (lifecycleEvent, bool) {
+	out := lifecycleEvent{GameID: a.cfg.GameID, Timestamp: ev.Time.UnixMilli()}
+	switch {
+	case ev.Type == "connected" && ev.Role == "game" && ev.ID == "":
+		out.Type = "game_connected"
+	case ev.Type == "disconnected" && ev.Role == "game" && ev.ID == "":
+		out.Type = "game_disconnected"
+		out.Data = map[string]any{"reason": ev.Fields["reason"]}
+	case ev.Type == "connected" && ev.Role == "controller":
+		out.Type = "slot_filled"
+	case ev.Type == "disconnected" && ev.Role == "controller":
+		out.Type = "slot_emptied"
+	case ev.Type == "match_started", ev.Type == "play_started", ev.Type == "result_submitted", ev.Type == "match_state":
+		out.Type = ev.Type
+		out.Data = ev.Fields
+	default:
+		return lifecycleEvent{}, false
+	}
+	if ev.Role == "controller" {
+		out.SlotID = ev.ID
+		status := a.hub.Status()
+		out.Data = map[string]any{
+			"connected": status.Controllers,
+			"capacity":  status.MaxControllers,
+		}
+		if userID, _ := ev.Fields["userId"].(string); userID != "" {
+			out.Data["userId"] = userID
+		}
+	}
+	return out, true
+}
+
+// runLifecycleWebhooks POSTs game, match and controller lifecycle events to
+// every LIFECYCLE_WEBHOOK_URLS entry. Each URL has its own queue, so a slow
+// receiver does not delay the others; failed deliveries are retried with
+// exponential backoff.
+func (a *App) runLifecycleWebhooks(ctx context.Context) {
+	sub := a.hub.SubscribeEvents(lifecycleQueueSize)
+	defer sub.Close()
+
+	queues := make([]chan lifecycleEvent, 0, len(a.cfg.LifecycleWebhookURLs))
+	for _, url := range a.cfg.LifecycleWebhookURLs {
+		queue := make(chan lifecycleEvent, lifecycleQueueSize)
+		queues = append(queues, queue)
+		crash.Go(func() { a.deliverLifecycle(ctx, newWebhookClient(url), queue) })
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			event, ok := a.lifecycleFromEvent(ev)
+			if !ok {
+				continue
+			}
+			for i, queue := range queues {
+				select {
+				case queue <- event:
+				default:
+					a.logger.Warn("lifecycle_webhook_dropped",
+						"url", redactURL(a.cfg.LifecycleWebhookURLs[i]),
+						"type", event.Type,
+					)
+				}
+			}
+		}
+	}
+}
+
+func (a *App) deliverLifecycle(ctx context.Context, client *webhookClient, queue <-chan lifecycleEvent) {
+	logger := a.logger.With("url", redactURL(client.url))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			a.postLifecycle(ctx, logger, client, event)
+		}
+	}
+}
+
+// postLifecycle delivers one event, retrying network errors, 429 and 5xx
+// answers up to LIFECYCLE_WEBHOOK_RETRIES times. Retries reuse the delivery
+// id so receivers can discard duplicates.
+func (a *App) postLifecycle(ctx context.Context, logger *slog.Logger, client *webhookClient, event lifecycleEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("lifecycle_webhook_encode_failed", "type", event.Type, "err", err.Error())
+		return
+	}
+	header := http.Header{}
+	header.Set(lifecycleEventHeader, event.Type)
+	header.Set(lifecycleDeliveryHeader, newDeliveryID())
+
+	backoff := lifecycleBackoffBase
+	for attempt := 0; ; attempt++ {
+		a.signLifecycle(header, body, time.Now())
+		status, err := client.send(ctx, body, header)
+		if err == nil {
+			logger.Debug("lifecycle_webhook_sent", "type", event.Type, "attempt", attempt+1)
+			return
+		}
+		retryable := status == 0 || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt >= a.cfg.LifecycleWebhookRetries || ctx.Err() != nil {
+			logger.Warn("lifecycle_webhook_failed", "type", event.Type, "attempts", attempt+1, "err", err.Error())
+			return
+		}
+		logger.Debug("lifecycle_webhook_retry", "type", event.Type, "attempt", attempt+1, "backoff", backoff.String(), "err", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, lifecycleBackoffMax)
+	}
+}
+
+// signLifecycle stamps the attempt's send time in X-Hub-Timestamp and,
+// with LIFECYCLE_WEBHOOK_SECRET set, signs "<timestamp>.<body>" in
+// X-Hub-Signature-256. Covering the timestamp lets receivers refuse a
+// captured request replayed later, not just an altered one.
+func (a *App) signLifecycle(header http.Header, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header.Set(lifecycleTimestampHeader, timestamp)
+	if a.cfg.LifecycleWebhookSecret == "" {
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(a.cfg.LifecycleWebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	header.Set(lifecycleSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func newDeliveryID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
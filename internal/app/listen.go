@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenError explains why a listener could not bind and what to do about
+// it, in place of the bare "listen tcp" error from net.Listen.
+type listenError struct {
+	addr   string
+	flag   string
+	err    error
+	holder string
+	hint   string
+}
+
+func (e *listenError) Error() string {
+	msg := fmt.Sprintf("listen on %s (%s): %v", e.addr, e.flag, e.err)
+	if e.holder != "" {
+		msg += "; held by " + e.holder
+	}
+	if e.hint != "" {
+		msg += "; " + e.hint
+	}
+	return msg
+}
+
+func (e *listenError) Unwrap() error { return e.err }
+
+// Is reports listen failures as ErrListen.
+func (e *listenError) Is(target error) bool { return target == ErrListen }
+
+// listen binds addr for the listener configured through flag, turning
+// address-in-use and permission failures into actionable errors.
+func (a *App) listen(addr, flag string) (net.Listener, error) {
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err == nil {
+		return ln, nil
+	}
+
+	lerr := &listenError{addr: addr, flag: flag, err: err}
+	port := listenPort(addr)
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		lerr.holder = portHolder(port)
+		lerr.hint = fmt.Sprintf("stop the other process or choose a free port with %s", flag)
+		if lerr.holder == "" {
+			lerr.hint = fmt.Sprintf("find the owner with `ss -ltnp 'sport = :%d'` (or `lsof -i :%d`), then stop it or choose a free port with %s", port, port, flag)
+		}
+	case errors.Is(err, syscall.EACCES):
+		if port > 0 && port < 1024 {
+			lerr.hint = fmt.Sprintf("ports below 1024 need root or CAP_NET_BIND_SERVICE (`sudo setcap cap_net_bind_service=+ep %s`); otherwise listen on a port above 1023 with %s behind a proxy", executable(), flag)
+		} else {
+			lerr.hint = fmt.Sprintf("the OS or a security policy refused the port; choose another with %s", flag)
+		}
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		lerr.hint = fmt.Sprintf("the host address is not assigned to this machine; use 0.0.0.0 or a local address in %s", flag)
+	}
+	a.logger.Error("listen_failed", "addr", addr, "flag", flag, "err", err.Error(), "holder", lerr.holder, "hint", lerr.hint)
+	return nil, lerr
+}
+
+func listenPort(addr string) int {
+	_, portName, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+	port, err := net.LookupPort("tcp", portName)
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
+func executable() string {
+	path, err := os.Executable()
+	if err != nil {
+		return "<binary>"
+	}
+	return path
+}
+
+// portHolder names the process listening on port, e.g. `pid 4242 (hub)`. It
+// reads /proc, so it only answers on Linux and only for processes this user
+// may inspect; otherwise it returns "".
+func portHolder(port int) string {
+	if port <= 0 {
+		return ""
+	}
+	inodes := make(map[string]struct{})
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			// local_address is HEXIP:HEXPORT; state 0A is LISTEN.
+			if len(fields) < 10 || fields[3] != "0A" {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if p, err := strconv.ParseUint(hexPort, 16, 16); !ok || err != nil || int(p) != port {
+				continue
+			}
+			inodes["socket:["+fields[9]+"]"] = struct{}{}
+		}
+	}
+	if len(inodes) == 0 {
+		return ""
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil {
+			continue
+		}
+		if _, ok := inodes[target]; !ok {
+			continue
+		}
+		pid := strings.Split(fd, "/")[2]
+		comm, err := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+		if err != nil {
+			return "pid " + pid
+		}
+		return fmt.Sprintf("pid %s (%s)", pid, strings.TrimSpace(string(comm)))
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+)
+
+// Kinds of drift between the Persona lobby and the hub's assignments.
+const (
+	// lobbyMissingToken: the lobby seats a user the hub has no token or
+	// connection for, so their controller cannot join.
+	lobbyMissingToken = "missing_token"
+	// lobbyNotInLobby: the hub holds a token or connection for a slot the
+	// lobby leaves empty.
+	lobbyNotInLobby = "not_in_lobby"
+	// lobbyUserMismatch: the slot is bound to different users on each side.
+	lobbyUserMismatch = "user_mismatch"
+)
+
+type lobbyMismatch struct {
+	SlotID      string `json:"slotId"`
+	Kind        string `json:"kind"`
+	LobbyUserID string `json:"lobbyUserId,omitempty"`
+	HubUserID   string `json:"hubUserId,omitempty"`
+	Connected   bool   `json:"connected"`
+}
+
+// compareLobby lists the slots where lobby and assignments disagree, sorted
+// by slot.
+func compareLobby(lobby *persona.Lobby, assignments []hub.ControllerAssignment) []lobbyMismatch {
+	seated := make(map[string]persona.Slot)
+	if lobby != nil {
+		for _, slot := range lobby.Slots {
+			if slot.UserID != "" {
+				seated[slot.SlotID] = slot
+			}
+		}
+	}
+	bound := make(map[string]hub.ControllerAssignment, len(assignments))
+	for _, assign := range assignments {
+		if assign.UserID != "" || assign.Connected {
+			bound[assign.SlotID] = assign
+		}
+	}
+
+	mismatches := []lobbyMismatch{}
+	for slotID, slot := range seated {
+		assign, ok := bound[slotID]
+		switch {
+		case !ok || assign.UserID == "":
+			mismatches = append(mismatches, lobbyMismatch{
+				SlotID:      slotID,
+				Kind:        lobbyMissingToken,
+				LobbyUserID: slot.UserID,
+				Connected:   assign.Connected,
+			})
+		case assign.UserID != slot.UserID:
+			mismatches = append(mismatches, lobbyMismatch{
+				SlotID:      slotID,
+				Kind:        lobbyUserMismatch,
+				LobbyUserID: slot.UserID,
+				HubUserID:   assign.UserID,
+				Connected:   assign.Connected,
+			})
+		}
+	}
+	for slotID, assign := range bound {
+		if _, ok := seated[slotID]; !ok {
+			mismatches = append(mismatches, lobbyMismatch{
+				SlotID:    slotID,
+				Kind:      lobbyNotInLobby,
+				HubUserID: assign.UserID,
+				Connected: assign.Connected,
+			})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].SlotID < mismatches[j].SlotID })
+	return mismatches
+}
+
+func (a *App) adminLobbyCompareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.persona == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+		return
+	}
+
+	lobby, err := a.persona.FetchLobby(r.Context())
+	if err != nil {
+		a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+		a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
+		return
+	}
+
+	mismatches := compareLobby(lobby, a.hub.ControllerAssignments())
+	if len(mismatches) > 0 {
+		a.requestLogger(r).Warn("lobby_drift_detected", "mismatches", len(mismatches))
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"gameId":     lobby.GameID,
+		"inSync":     len(mismatches) == 0,
+		"mismatches": mismatches,
+		"checkedAt":  time.Now().UTC(),
+	})
+}
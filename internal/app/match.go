@@ -0,0 +1,114 @@
+package app
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// requireMatchState answers 409 match_state_conflict unless the match is in
+// one of allowed, so out-of-order calls such as a result while idle are
+// refused before anything is sent to PersonaGo.
+func (a *App) requireMatchState(w http.ResponseWriter, r *http.Request, allowed ...hub.MatchState) bool {
+	current := a.hub.Match().State
+	if slices.Contains(allowed, current) {
+		return true
+	}
+	expected := make([]string, 0, len(allowed))
+	for _, state := range allowed {
+		expected = append(expected, string(state))
+	}
+	a.requestLogger(r).Warn("match_state_conflict", "path", r.URL.Path, "state", string(current))
+	a.respondErrorDetails(w, http.StatusConflict, errCodeMatchState,
+		"not allowed while match is "+string(current),
+		map[string]any{"state": current, "expected": expected},
+	)
+	return false
+}
+
+// matchPayload describes the match state for /api/game/match and the
+// /api/game/finish response.
+func (a *App) matchPayload() map[string]any {
+	status := a.hub.Match()
+	payload := map[string]any{
+		"gameId":    a.cfg.GameID,
+		"state":     status.State,
+		"since":     nil,
+		"next":      hub.MatchTransitions(status.State),
+		"startTime": nil,
+	}
+	if !status.Since.IsZero() {
+		payload["since"] = status.Since.UTC().Format(time.RFC3339Nano)
+	}
+	if start := a.hub.MatchStartedAt(); !start.IsZero() {
+		payload["startTime"] = start.Format(time.RFC3339)
+	}
+	return payload
+}
+
+// gameMatchHandler serves /api/game/match. GET reports the match state;
+// DELETE abandons the current match, dropping its play session, and returns
+// to idle.
+func (a *App) gameMatchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.respondJSON(w, http.StatusOK, a.matchPayload())
+
+	case http.MethodDelete:
+		previous := a.hub.Match().State
+		if a.currentPlaySession() != nil {
+			a.endPlaySession()
+		}
+		a.hub.ClearMatchStart()
+		a.hub.ResetMatch("api")
+		a.requestLogger(r).Warn("match_reset", "previous", string(previous))
+		a.respondJSON(w, http.StatusOK, a.matchPayload())
+
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// gameFinishHandler serves POST /api/game/finish, which ends play for a
+// running match. The result is still expected through /api/game/result.
+func (a *App) gameFinishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireMatchState(w, r, hub.MatchRunning) {
+		return
+	}
+	if err := a.hub.AdvanceMatch(hub.MatchFinished, "api"); err != nil {
+		// Lost a race with the game's own match_finish.
+		a.respondErrorDetails(w, http.StatusConflict, errCodeMatchState, err.Error(), map[string]any{"state": a.hub.Match().State})
+		return
+	}
+	a.respondJSON(w, http.StatusOK, a.matchPayload())
+}
+
+// resultAccepted closes the match once its result was submitted or queued
+// for retry.
+func (a *App) resultAccepted(hadPlay bool) {
+	if hadPlay {
+		a.endPlaySession()
+	}
+	a.hub.ClearMatchStart()
+	a.completeMatch()
+}
+
+// completeMatch returns the match to idle once its result was accepted,
+// passing through finished when the game never said it had ended.
+func (a *App) completeMatch() {
+	if a.hub.Match().State == hub.MatchRunning {
+		_ = a.hub.AdvanceMatch(hub.MatchFinished, "result")
+	}
+	if err := a.hub.AdvanceMatch(hub.MatchIdle, "result"); err != nil {
+		a.logger.Warn("match_complete_failed", "err", err.Error())
+	}
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+func (a *App) adminKickHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req kickRequest
+	if !a.decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	slotID := strings.ToLower(strings.TrimSpace(req.SlotID))
+	if slotID == "" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId is required")
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+
+	if err := a.hub.Kick(r.Context(), slotID, reason); err != nil {
+		if errors.Is(err, hub.ErrSlotNotConnected) {
+			a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotFound, "slot not connected: "+slotID, map[string]any{"slotId": slotID})
+			return
+		}
+		a.requestLogger(r).Error("admin_kick_failed", "slot", slotID, "err", err.Error())
+		a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to kick controller")
+		return
+	}
+
+	a.requestLogger(r).Info("admin_kick", "slot", slotID, "reason", reason)
+	a.respondJSON(w, http.StatusOK, kickResponse{SlotID: slotID})
+}
+
+// kickRequest is the body of POST /api/admin/kick.
+type kickRequest struct {
+	SlotID string `json:"slotId"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type kickResponse struct {
+	SlotID string `json:"slotId"`
+}
+
+type banResponse struct {
+	Subject string    `json:"subject"`
+	Until   time.Time `json:"until"`
+	// Kicked counts the sessions a new ban closed.
+	Kicked *int `json:"kicked,omitempty"`
+}
+
+type bansResponse struct {
+	Bans []banResponse `json:"bans"`
+}
+
+// banRequest is the body of POST /api/admin/ban. Subject is a remote IP or
+// a Persona user id.
+type banRequest struct {
+	Subject  string `json:"subject"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (a *App) adminBanHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bans := a.hub.Bans()
+		resp := make([]banResponse, 0, len(bans))
+		for _, ban := range bans {
+			resp = append(resp, banResponse{Subject: ban.Subject, Until: ban.Until.UTC()})
+		}
+		a.respondJSON(w, http.StatusOK, bansResponse{Bans: resp})
+
+	case http.MethodPost:
+		var req banRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		subject := strings.TrimSpace(req.Subject)
+		if subject == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "subject is required")
+			return
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(req.Duration))
+		if err != nil || duration <= 0 {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "duration must be a positive Go duration such as \"10m\"")
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+
+		kicked, err := a.hub.Ban(r.Context(), subject, duration, reason)
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		a.requestLogger(r).Info("admin_ban", "subject", subject, "duration", duration.String(), "kicked", kicked, "reason", reason)
+		a.respondJSON(w, http.StatusOK, banResponse{
+			Subject: subject,
+			Until:   time.Now().Add(duration).UTC(),
+			Kicked:  &kicked,
+		})
+
+	case http.MethodDelete:
+		subject := strings.TrimSpace(r.URL.Query().Get("subject"))
+		if subject == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "subject query parameter is required")
+			return
+		}
+		if !a.hub.Unban(subject) {
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "no active ban for "+subject)
+			return
+		}
+		a.requestLogger(r).Info("admin_unban", "subject", subject)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *App) adminPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.respondJSON(w, http.StatusOK, map[string]any{"profiles": a.hub.InputProfiles()})
+
+	case http.MethodPost:
+		var req inputProfileRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		slotID := strings.ToLower(strings.TrimSpace(req.SlotID))
+		if slotID == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId is required")
+			return
+		}
+		if req.Types == nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "types is required; use DELETE to lift a profile")
+			return
+		}
+
+		types := a.hub.SetInputProfile(slotID, req.Types)
+		a.respondJSON(w, http.StatusOK, map[string]any{"slotId": slotID, "types": types})
+
+	case http.MethodDelete:
+		slotID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("slotId")))
+		if slotID == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId query parameter is required")
+			return
+		}
+		if !a.hub.ClearInputProfile(slotID) {
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "no input profile for "+slotID)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// inputProfileRequest is the body of POST /api/admin/permissions: the
+// frame types the slot may still send.
+type inputProfileRequest struct {
+	SlotID string   `json:"slotId"`
+	Types  []string `json:"types"`
+}
+
+// maxRequestBody caps every JSON request body the API reads.
+const maxRequestBody = 1 << 20
+
+// limitBody caps the request body at maxRequestBody. A declared length near
+// the cap is reported to the hub so operators see it before requests fail.
+func (a *App) limitBody(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > 0 {
+		a.hub.ObserveSoftLimit(hub.LimitHTTPBodyBytes, maxRequestBody, int(r.ContentLength))
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+}
+
+// decodeJSONBody decodes a single JSON object from the request body into dst,
+// writing a 400 response and returning false when the body is malformed.
+func (a *App) decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	a.limitBody(w, r)
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
+			return false
+		}
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
+		return false
+	}
+	if err := decoder.Decode(new(struct{})); err != io.EOF {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
+		return false
+	}
+	return true
+}
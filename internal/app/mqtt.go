@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/config"
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/mqtt"
+)
+
+const mqttEventBuffer = 64
+
+// newMQTTClient connects the hub to MQTT_URL with a retained online/offline
+// status on MQTT_STATUS_TOPIC, so a dashboard also notices a hub that lost
+// power.
+func newMQTTClient(cfg config.Config, logger *slog.Logger) (*mqtt.Client, error) {
+	clientID := cfg.MQTTClientID
+	if clientID == "" {
+		clientID = "cgb-io-hub-" + cfg.GameID
+	}
+	status := mqttTopic(cfg.MQTTStatusTopic, cfg.GameID, "", "")
+	broker := redactURL(cfg.MQTTURL)
+	return mqtt.New(mqtt.Config{
+		URL:      cfg.MQTTURL,
+		ClientID: clientID,
+		Will:     &mqtt.Message{Topic: status, Payload: []byte("offline"), QoS: 1, Retain: true},
+		Birth:    &mqtt.Message{Topic: status, Payload: []byte("online"), QoS: 1, Retain: true},
+		OnConnect: func() {
+			logger.Info("mqtt_connected", "broker", broker)
+		},
+		OnError: func(err error) {
+			logger.Warn("mqtt_error", "broker", broker, "err", err.Error())
+		},
+	})
+}
+
+// mqttTopic fills the {game}, {type} and {slot} placeholders of template.
+func mqttTopic(template, gameID, eventType, slotID string) string {
+	return strings.NewReplacer("{game}", gameID, "{type}", eventType, "{slot}", slotID).Replace(template)
+}
+
+// runMQTT publishes the lifecycle events LIFECYCLE_WEBHOOK_URLS receive,
+// controller joins and leaves, game connections, matches and results, and,
+// with MQTT_INPUT_INTERVAL, each slot's latest input.
+func (a *App) runMQTT(ctx context.Context) {
+	crash.Go(func() { a.mqtt.Run(ctx) })
+	if a.mqttInputs != nil {
+		crash.Go(func() { a.runMQTTInputs(ctx) })
+	}
+
+	sub := a.hub.SubscribeEvents(mqttEventBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			event, ok := a.lifecycleFromEvent(ev)
+			if !ok {
+				continue
+			}
+			a.publishMQTT(mqttTopic(a.cfg.MQTTEventTopic, a.cfg.GameID, event.Type, event.SlotID), event, byte(a.cfg.MQTTQoS))
+		}
+	}
+}
+
+func (a *App) runMQTTInputs(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.MQTTInputInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, sample := range a.mqttInputs.take() {
+				var frame map[string]any
+				if err := json.Unmarshal(sample.payload, &frame); err != nil {
+					continue
+				}
+				if inner, ok := frame["payload"].(map[string]any); ok && frame["type"] == "relay" {
+					frame = inner
+				}
+				event := lifecycleEvent{
+					Type:      "input",
+					GameID:    a.cfg.GameID,
+					SlotID:    sample.slotID,
+					Timestamp: sample.at.UnixMilli(),
+					Data:      frame,
+				}
+				a.publishMQTT(mqttTopic(a.cfg.MQTTInputTopic, a.cfg.GameID, event.Type, event.SlotID), event, 0)
+			}
+		}
+	}
+}
+
+func (a *App) publishMQTT(topic string, event lifecycleEvent, qos byte) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		a.logger.Error("mqtt_encode_failed", "type", event.Type, "err", err.Error())
+		return
+	}
+	a.mqtt.Publish(mqtt.Message{Topic: topic, Payload: payload, QoS: qos, Retain: a.cfg.MQTTRetain})
+}
+
+// inputSampler keeps the newest relayed frame of each slot between two
+// MQTT_INPUT_INTERVAL ticks, so the broker sees input at a dashboard's pace
+// rather than the controllers' 60 Hz.
+type inputSampler struct {
+	mu     sync.Mutex
+	latest map[string]inputSample
+}
+
+type inputSample struct {
+	slotID  string
+	payload []byte
+	at      time.Time
+}
+
+func newInputSampler() *inputSampler {
+	return &inputSampler{latest: make(map[string]inputSample)}
+}
+
+// observe is a relay tap.
+func (s *inputSampler) observe(slotID, _ string, payload []byte) {
+	s.mu.Lock()
+	s.latest[slotID] = inputSample{slotID: slotID, payload: payload, at: time.Now()}
+	s.mu.Unlock()
+}
+
+// take returns the frames sampled since the last call.
+func (s *inputSampler) take() []inputSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := make([]inputSample, 0, len(s.latest))
+	for _, sample := range s.latest {
+		samples = append(samples, sample)
+	}
+	clear(s.latest)
+	return samples
+}
@@ -0,0 +1,568 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/activity"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/openapi"
+)
+
+const (
+	openAPIPath = "/api/openapi.json"
+	apiDocsPath = "/api/docs"
+)
+
+// Who may call an operation, which decides the security it declares.
+const (
+	authController = iota // open unless CONTROLLER_SESSION_AUTH=api_key
+	authAPIKey
+	authAdmin
+	authPublic
+	authSpectator // authAPIKey, or a spectator-scope token for reads
+)
+
+// apiOperation is one row of the contract table below. Bodies are Go values
+// whose types the schemas are generated from; errs lists the statuses that
+// answer with an apiError.
+type apiOperation struct {
+	method, path string
+	id           string
+	tag          string
+	summary      string
+	auth         int
+	params       []openapi.Parameter
+	request      any
+	optionalBody bool
+	responses    map[int]any
+	errs         []int
+}
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+func requiredQueryParam(name, typ, description string) openapi.Parameter {
+	p := queryParam(name, typ, description)
+	p.Required = true
+	return p
+}
+
+// rawBody documents a response that is not JSON by its media types.
+type rawBody []string
+
+// jsonObject documents a response the handler builds as a map; the summary
+// names its fields.
+type jsonObject = map[string]any
+
+// apiOperations is the documented REST surface; a route added to
+// buildRouter or registerAdminRoutes for other teams belongs here too.
+func apiOperations() []apiOperation {
+	return []apiOperation{
+		{
+			method: http.MethodPost, path: "/api/controller/session", id: "createControllerSession", tag: "controller",
+			summary: "Issue a controller token for a user in the PersonaGo lobby",
+			auth:    authController, request: controllerSessionRequest{},
+			responses: map[int]any{http.StatusCreated: controllerSessionResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/controller/sessions/batch", id: "createControllerSessions", tag: "controller",
+			summary: "Issue controller tokens for every occupied lobby slot",
+			auth:    authAPIKey, request: sessionsBatchRequest{},
+			responses: map[int]any{http.StatusCreated: sessionsBatchResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/controller/assignments", id: "listAssignments", tag: "controller",
+			summary: "List slot assignments and whether each controller is connected",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: assignmentsResponse{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/controller/assignments/stream", id: "streamAssignments", tag: "controller",
+			summary: "Stream the assignments as Server-Sent Events on every change",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: rawBody{"text/event-stream"}},
+		},
+		{
+			method: http.MethodGet, path: "/api/controller/sessions", id: "listControllerSessions", tag: "controller",
+			summary: "List outstanding controller tokens (slotId, userId, name, connected, expiresAt) without their values",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodDelete, path: "/api/controller/session/{slotId}", id: "revokeControllerSession", tag: "controller",
+			summary: "Withdraw a slot's controller token before it expires",
+			auth:    authAPIKey,
+			params: []openapi.Parameter{
+				{Name: "slotId", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+				queryParam("disconnect", "boolean", "also close the connected controller"),
+				queryParam("reason", "string", "close reason sent to the controller, default token_revoked"),
+			},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/controller/lobby", id: "getControllerLobby", tag: "controller",
+			summary: "Fetch the lobby names the controller page picks a player from",
+			auth:    authController, responses: map[int]any{http.StatusOK: lobbyResponse{}},
+			errs: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/controller/claim", id: "claimSlot", tag: "controller",
+			summary: "Reserve a slot without PersonaGo and issue its controller token",
+			auth:    authController, request: claimRequest{},
+			responses: map[int]any{http.StatusCreated: controllerSessionResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusConflict},
+		},
+		{
+			method: http.MethodGet, path: "/api/controller/qr", id: "getControllerQR", tag: "controller",
+			summary: "Render a QR code of the controller link for a slot or token",
+			auth:    authAPIKey,
+			params: []openapi.Parameter{
+				queryParam("slot", "string", "issue a fresh token for the user PersonaGo has in this slot"),
+				queryParam("token", "string", "encode a token issued earlier"),
+				queryParam("cohort", "string", "cohort of the token issued for slot"),
+				queryParam("join", "boolean", "encode a one-time /j/ join code instead of the token"),
+				queryParam("format", "string", "svg (default) or png"),
+				queryParam("scale", "integer", "PNG pixels per module, 1-"+strconv.Itoa(qrMaxScale)+", default "+strconv.Itoa(qrDefaultScale)),
+				queryParam("base", "string", "origin the link points at, default the request's"),
+			},
+			responses: map[int]any{http.StatusOK: rawBody{"image/svg+xml", "image/png"}},
+			errs:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/lobby", id: "getLobby", tag: "game",
+			summary: "Fetch the PersonaGo lobby",
+			auth:    authSpectator, responses: map[int]any{http.StatusOK: lobbyResponse{}},
+			errs: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/game/lobby", id: "updateLobby", tag: "game",
+			summary: "Assign users to lobby slots",
+			auth:    authAPIKey, request: lobbyUpdateRequest{},
+			responses: map[int]any{http.StatusOK: lobbyResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusConflict, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodDelete, path: "/api/game/lobby", id: "clearLobby", tag: "game",
+			summary: "Clear every lobby slot",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: lobbyResponse{}},
+			errs: []int{http.StatusConflict, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/game/start", id: "startGame", tag: "game",
+			summary: "Record the players' visit and start the match",
+			auth:    authAPIKey, request: gameStartRequest{}, optionalBody: true,
+			responses: map[int]any{http.StatusOK: gameStartResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/game/result", id: "submitResult", tag: "game",
+			summary: "Submit the scores of the finished match",
+			auth:    authAPIKey, request: gameResultRequest{},
+			responses: map[int]any{http.StatusOK: gameResultResponse{}, http.StatusAccepted: gameResultResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/result/queue", id: "listQueuedResults", tag: "game",
+			summary: "List results still waiting to reach PersonaGo",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodPost, path: "/api/game/result/queue", id: "retryQueuedResults", tag: "game",
+			summary: "Send one queued result (id) or all of them now, including those that gave up",
+			auth:    authAPIKey, params: []openapi.Parameter{queryParam("id", "string", "queued result to retry; all when omitted")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusNotFound, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodDelete, path: "/api/game/result/queue", id: "discardQueuedResults", tag: "game",
+			summary: "Discard one queued result (id) or all of them",
+			auth:    authAPIKey, params: []openapi.Parameter{queryParam("id", "string", "queued result to discard; all when omitted")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/api/game/finish", id: "finishMatch", tag: "game",
+			summary: "End play of the running match; the result still follows on /api/game/result",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs: []int{http.StatusConflict},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/match", id: "getMatch", tag: "game",
+			summary: "Report the match state (state, since, next, startTime)",
+			auth:    authSpectator, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodDelete, path: "/api/game/match", id: "abandonMatch", tag: "game",
+			summary: "Abandon the current match and return to idle",
+			auth:    authAPIKey, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/timer", id: "getMatchTimer", tag: "game",
+			summary: "Report the match clock (running, startTime, elapsedMs, resultOverdue)",
+			auth:    authSpectator, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/status", id: "getGameStatus", tag: "game",
+			summary: "Report whether the game is attached, the match and the relay load",
+			auth:    authSpectator, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/visits", id: "checkVisit", tag: "game",
+			summary: "Tell whether a user may play now under the visit policy, and from when if not",
+			auth:    authAPIKey, params: []openapi.Parameter{requiredQueryParam("userId", "string", "")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, path: "/api/game/leaderboard", id: "getLeaderboard", tag: "game",
+			summary: "Rank the recorded scores",
+			auth:    authSpectator,
+			params: []openapi.Parameter{
+				queryParam("scope", "string", "all (default) or session, the scores since the hub started"),
+				queryParam("date", "string", "today, yesterday or YYYY-MM-DD"),
+				queryParam("perUser", "boolean", "keep each user's best score only"),
+				queryParam("limit", "integer", "entries to return, 1-"+strconv.Itoa(maxLeaderboardLimit)+", default "+strconv.Itoa(defaultLeaderboardLimit)),
+			},
+			responses: map[int]any{http.StatusOK: leaderboardResponse{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, path: "/api/version", id: "getVersion", tag: "hub",
+			summary: "Report the running build",
+			auth:    authPublic, responses: map[int]any{http.StatusOK: versionResponse{}},
+		},
+		{
+			method: http.MethodGet, path: jwksPath, id: "getJWKS", tag: "hub",
+			summary: "Publish the keys hub-issued JWTs are signed with",
+			auth:    authPublic, responses: map[int]any{http.StatusOK: hub.JWKSet{}},
+			errs: []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/kick", id: "kickController", tag: "admin",
+			summary: "Disconnect the controller in a slot",
+			auth:    authAdmin, request: kickRequest{},
+			responses: map[int]any{http.StatusOK: kickResponse{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/ban", id: "listBans", tag: "admin",
+			summary: "List active bans",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: bansResponse{}},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/ban", id: "ban", tag: "admin",
+			summary: "Ban a remote IP or user and disconnect their sessions",
+			auth:    authAdmin, request: banRequest{},
+			responses: map[int]any{http.StatusOK: banResponse{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodDelete, path: "/api/admin/ban", id: "unban", tag: "admin",
+			summary: "Lift a ban",
+			auth:    authAdmin,
+			params: []openapi.Parameter{
+				{Name: "subject", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			},
+			responses: map[int]any{http.StatusNoContent: nil},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/recording", id: "listRecordings", tag: "admin",
+			summary: "Show the active recording and the stored ones",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: recordingStatus{}},
+			errs: []int{http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/recording", id: "controlRecording", tag: "admin",
+			summary: "Start or stop recording relayed controller frames",
+			auth:    authAdmin, request: recordingRequest{},
+			responses: map[int]any{http.StatusOK: recordingInfo{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/replay", id: "getReplay", tag: "admin",
+			summary: "Show the running replay",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: replayStatus{}},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/replay", id: "startReplay", tag: "admin",
+			summary: "Replay a recording to the game",
+			auth:    authAdmin, request: replayRequest{},
+			responses: map[int]any{http.StatusAccepted: replayStatus{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			method: http.MethodDelete, path: "/api/admin/replay", id: "stopReplay", tag: "admin",
+			summary: "Stop the running replay",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: replayStatus{}},
+			errs: []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/report", id: "getReport", tag: "admin",
+			summary: "Summarise a day of the activity journal",
+			auth:    authAdmin,
+			params: []openapi.Parameter{
+				queryParam("date", "string", "today (default), yesterday or YYYY-MM-DD"),
+				queryParam("format", "string", "json (default) or html"),
+			},
+			responses: map[int]any{http.StatusOK: activity.Report{}},
+			errs:      []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/report", id: "exportReport", tag: "admin",
+			summary:   "Write a day's report as JSON and HTML under exports/ in STORAGE_URL",
+			auth:      authAdmin,
+			params:    []openapi.Parameter{queryParam("date", "string", "today (default), yesterday or YYYY-MM-DD")},
+			responses: map[int]any{http.StatusCreated: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/support", id: "listSupportBundles", tag: "admin",
+			summary:   "List the stored support bundles, or download one",
+			auth:      authAdmin,
+			params:    []openapi.Parameter{queryParam("key", "string", "bundle to download")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/support", id: "createSupportBundle", tag: "admin",
+			summary: "Write the configuration and a hub snapshot under support/ in STORAGE_URL",
+			auth:    authAdmin, responses: map[int]any{http.StatusCreated: exportedFile{}},
+			errs: []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/overview", id: "getOverview", tag: "admin",
+			summary: "Everything the dashboard renders in one snapshot",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/sessions", id: "listSessions", tag: "admin",
+			summary: "List the connected sessions with telemetry and calibrations",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/rooms", id: "listRooms", tag: "admin",
+			summary: "Describe the room and its bridges",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/relay", id: "getRelayStats", tag: "admin",
+			summary: "Report the relay queue and broadcast counters",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/upgrades", id: "getUpgradeStats", tag: "admin",
+			summary: "Count accepted, failed and pending WebSocket upgrades",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/limits", id: "listSoftLimits", tag: "admin",
+			summary: "List every hard limit with its warning threshold",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/cohorts", id: "listCohortStats", tag: "admin",
+			summary: "Report connections and relayed traffic per cohort",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/accepting", id: "getAccepting", tag: "admin",
+			summary: "Report whether new controllers may join",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodPut, path: "/api/admin/accepting", id: "setAccepting", tag: "admin",
+			summary: "Open or close the floor to new controllers",
+			auth:    authAdmin, request: acceptingRequest{},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/config", id: "getConfig", tag: "admin",
+			summary: "Show the effective configuration; secrets only as whether they are set",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodPatch, path: "/api/admin/config", id: "patchConfig", tag: "admin",
+			summary: "Change a setting that can be tuned without a restart",
+			auth:    authAdmin, request: configPatchRequest{},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/tokens", id: "issueToken", tag: "admin",
+			summary: "Issue a controller, admin or spectator token",
+			auth:    authAdmin, request: tokenRequest{},
+			responses: map[int]any{http.StatusCreated: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodDelete, path: "/api/admin/tokens", id: "revokeToken", tag: "admin",
+			summary: "Revoke a slot's token (slotId), a subject's tokens (scope, subject) or one token sent in the body",
+			auth:    authAdmin,
+			params: []openapi.Parameter{
+				queryParam("slotId", "string", "controller slot whose token is revoked"),
+				queryParam("scope", "string", "admin or spectator, with subject"),
+				queryParam("subject", "string", "subject whose tokens of scope are revoked"),
+			},
+			request: revokeTokenRequest{}, optionalBody: true,
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/handoff", id: "handoffSlot", tag: "admin",
+			summary: "Hand a connected slot over to another lobby user",
+			auth:    authAdmin, request: handoffRequest{},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusBadGateway},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/disconnect", id: "disconnectControllers", tag: "admin",
+			summary: "Close the given controllers, or all of them, and optionally the game",
+			auth:    authAdmin, request: disconnectRequest{}, optionalBody: true,
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/permissions", id: "listInputProfiles", tag: "admin",
+			summary: "List the frame types each restricted slot may send",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/permissions", id: "setInputProfile", tag: "admin",
+			summary: "Restrict the frame types a slot may send",
+			auth:    authAdmin, request: inputProfileRequest{},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodDelete, path: "/api/admin/permissions", id: "clearInputProfile", tag: "admin",
+			summary: "Lift a slot's input restriction",
+			auth:    authAdmin, params: []openapi.Parameter{requiredQueryParam("slotId", "string", "")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/slots/meta", id: "listSlotMetadata", tag: "admin",
+			summary: "List the operator-set metadata of every slot",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/slots/meta", id: "setSlotMetadata", tag: "admin",
+			summary: "Replace a slot's metadata and push it to the controller and game",
+			auth:    authAdmin, request: slotMetaRequest{},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodDelete, path: "/api/admin/slots/meta", id: "clearSlotMetadata", tag: "admin",
+			summary: "Clear a slot's metadata",
+			auth:    authAdmin, params: []openapi.Parameter{requiredQueryParam("slotId", "string", "")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/lobby/compare", id: "compareLobby", tag: "admin",
+			summary: "Compare the PersonaGo lobby with the hub's assignments",
+			auth:    authAdmin, responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodPost, path: "/api/admin/lobby/reconcile", id: "reconcileLobby", tag: "admin",
+			summary:   "Run one lobby reconciliation pass",
+			auth:      authAdmin,
+			params:    []openapi.Parameter{queryParam("dryRun", "boolean", "only report the changes; defaults to the configured mode")},
+			responses: map[int]any{http.StatusOK: jsonObject{}},
+			errs:      []int{http.StatusBadRequest, http.StatusBadGateway, http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, path: "/api/admin/events/tail", id: "tailEvents", tag: "admin",
+			summary:   "Stream hub events as newline-delimited JSON",
+			auth:      authAdmin,
+			params:    []openapi.Parameter{queryParam("types", "string", "comma-separated event types to keep")},
+			responses: map[int]any{http.StatusOK: rawBody{"application/x-ndjson"}},
+			errs:      []int{http.StatusBadRequest},
+		},
+	}
+}
+
+// apiDocument builds the OpenAPI document of the REST API.
+func (a *App) apiDocument() *openapi.Document {
+	b := openapi.New(openapi.Info{
+		Title:   "cgb-io-hub API",
+		Version: "1",
+		Description: "REST API of the controller hub for game " + a.cfg.GameID + ". " +
+			"Controllers and the game exchange frames over the /ws WebSocket, which is not described here. " +
+			"Errors share one body: {\"code\",\"error\",\"details\"}.",
+	})
+	b.Tag("controller", "Controller tokens and slot assignments")
+	b.Tag("game", "Lobby, match and result endpoints the game calls")
+	b.Tag("hub", "Hub status")
+	b.Tag("admin", "Operator endpoints; on the admin listener when ADMIN_ADDR is set")
+	b.SecurityScheme("apiKey", &openapi.SecurityScheme{Type: "http", Scheme: "bearer", Description: "A key from API_KEYS; only checked when API_KEYS is set"})
+	b.SecurityScheme("apiKeyHeader", &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key", Description: "A key from API_KEYS"})
+	b.SecurityScheme("spectatorToken", &openapi.SecurityScheme{Type: "http", Scheme: "bearer", Description: "A spectator-scope token; accepted on GET only"})
+	b.SecurityScheme("adminToken", &openapi.SecurityScheme{Type: "http", Scheme: "bearer", Description: "ADMIN_TOKEN or an admin-scope token; falls back to the API key when ADMIN_TOKEN is unset"})
+
+	for _, spec := range apiOperations() {
+		op := openapi.Operation{
+			Tags:        []string{spec.tag},
+			Summary:     spec.summary,
+			OperationID: spec.id,
+			Parameters:  spec.params,
+			Responses:   make(map[string]*openapi.Response),
+		}
+		if spec.request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: !spec.optionalBody, Content: b.Body(spec.request)}
+		}
+		for status, body := range spec.responses {
+			if media, ok := body.(rawBody); ok {
+				content := make(map[string]openapi.MediaType, len(media))
+				for _, m := range media {
+					content[m] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+				}
+				op.Responses[strconv.Itoa(status)] = &openapi.Response{Description: openapi.StatusText(status), Content: content}
+				continue
+			}
+			if body == nil {
+				op.Responses[strconv.Itoa(status)] = &openapi.Response{Description: openapi.StatusText(status)}
+				continue
+			}
+			op.Responses[strconv.Itoa(status)] = b.JSONResponse(openapi.StatusText(status), body)
+		}
+		errs := spec.errs
+		switch spec.auth {
+		case authController:
+			op.Security = []map[string][]string{{}, {"apiKey": {}}, {"apiKeyHeader": {}}}
+		case authAPIKey:
+			op.Security = []map[string][]string{{"apiKey": {}}, {"apiKeyHeader": {}}}
+		case authSpectator:
+			op.Security = []map[string][]string{{"apiKey": {}}, {"apiKeyHeader": {}}, {"spectatorToken": {}}}
+		case authAdmin:
+			op.Security = []map[string][]string{{"adminToken": {}}}
+		}
+		if spec.auth != authPublic {
+			errs = append(errs, http.StatusUnauthorized)
+		}
+		if spec.auth == authController || spec.auth == authAPIKey || spec.auth == authSpectator {
+			errs = append(errs, http.StatusTooManyRequests)
+		}
+		for _, status := range errs {
+			op.Responses[strconv.Itoa(status)] = b.JSONResponse(openapi.StatusText(status), apiError{})
+		}
+		b.Add(spec.method, spec.path, op)
+	}
+	return b.Document()
+}
+
+// openAPIHandler serves the OpenAPI document of the REST API.
+func (a *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.respondJSON(w, http.StatusOK, a.apiDocument())
+}
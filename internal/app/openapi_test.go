@@ -0,0 +1,161 @@
+package app
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// undocumentedRoutes are the /api/ routes deliberately left out of
+// apiOperations: the documentation itself.
+var undocumentedRoutes = map[string]bool{
+	openAPIPath: true,
+	apiDocsPath: true,
+}
+
+// registeredRoutes returns the patterns this package passes to mux.Handle
+// and mux.HandleFunc. http.ServeMux cannot list its patterns, so they are
+// read from the source, resolving package-level string constants.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []*ast.File
+	consts := map[string]string{}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, file)
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, value := range vs.Values {
+					if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						consts[vs.Names[i].Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+
+	var resolve func(expr ast.Expr) (string, bool)
+	resolve = func(expr ast.Expr) (string, bool) {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			s, err := strconv.Unquote(e.Value)
+			return s, err == nil
+		case *ast.Ident:
+			s, ok := consts[e.Name]
+			return s, ok
+		case *ast.BinaryExpr:
+			x, okX := resolve(e.X)
+			y, okY := resolve(e.Y)
+			return x + y, okX && okY && e.Op == token.ADD
+		}
+		return "", false
+	}
+
+	var routes []string
+	for _, file := range parsed {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+				return true
+			}
+			if recv, ok := sel.X.(*ast.Ident); !ok || recv.Name != "mux" {
+				return true
+			}
+			pattern, ok := resolve(call.Args[0])
+			if !ok {
+				t.Errorf("%s: cannot resolve route pattern", fset.Position(call.Pos()))
+				return true
+			}
+			routes = append(routes, pattern)
+			return true
+		})
+	}
+	return routes
+}
+
+func isAPIRoute(pattern string) bool {
+	return strings.HasPrefix(pattern, "/api/") || strings.HasPrefix(pattern, "/.well-known/")
+}
+
+// documents reports whether the documented path is served by pattern; a
+// pattern ending in a slash serves the subtree below it, such as
+// /api/controller/session/{slotId}.
+func documents(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern) && len(path) > len(pattern)
+	}
+	return path == pattern
+}
+
+func TestAPIOperationsCoverRoutes(t *testing.T) {
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("no routes found")
+	}
+	ops := apiOperations()
+
+	for _, pattern := range routes {
+		if !isAPIRoute(pattern) || undocumentedRoutes[pattern] {
+			continue
+		}
+		found := false
+		for _, op := range ops {
+			if documents(pattern, op.path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("route %s is registered but missing from apiOperations", pattern)
+		}
+	}
+
+	for _, op := range ops {
+		found := false
+		for _, pattern := range routes {
+			if documents(pattern, op.path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s %s is documented but no route serves it", op.method, op.path)
+		}
+	}
+}
+
+func TestAPIOperationsUnique(t *testing.T) {
+	ids := map[string]bool{}
+	for _, op := range apiOperations() {
+		if ids[op.id] {
+			t.Errorf("operation id %s used twice", op.id)
+		}
+		ids[op.id] = true
+	}
+	// Add panics on a method declared twice for one path.
+	(&App{}).apiDocument()
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// overlayFS serves files from an on-disk overlay directory ahead of the
+// embedded assets, so a venue can swap logos, theme.css or other files
+// without rebuilding. Only regular files shadow: directories, and any path
+// the overlay lacks, come from the embedded bundle.
+type overlayFS struct {
+	overlay http.FileSystem
+	base    http.FileSystem
+}
+
+func newOverlayFS(dir string, base http.FileSystem) (http.FileSystem, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("static overlay: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("static overlay: %s is not a directory", dir)
+	}
+	return overlayFS{overlay: http.Dir(dir), base: base}, nil
+}
+
+func (o overlayFS) Open(name string) (http.File, error) {
+	file, err := o.overlay.Open(name)
+	if err == nil {
+		info, statErr := file.Stat()
+		if statErr == nil && info.Mode().IsRegular() {
+			return file, nil
+		}
+		file.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
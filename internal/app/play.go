@@ -0,0 +1,155 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/state"
+)
+
+// restorePlaySession reloads a match interrupted by a restart and re-seeds the
+// hub with its slot assignments so result submission can still resolve users.
+func (a *App) restorePlaySession() error {
+	if a.store == nil {
+		return nil
+	}
+
+	st, err := a.store.Load()
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	a.visits.restore(st.Visits)
+	a.leaderboard.restore(st.Leaderboard)
+	if len(st.Results) > 0 {
+		a.results.restore(st.Results)
+		a.logger.Warn("result_queue_restored", "entries", len(st.Results), "state_file", a.store.Path())
+	}
+	if st.Play == nil {
+		return nil
+	}
+
+	a.playMu.Lock()
+	a.play = st.Play
+	a.playMu.Unlock()
+
+	a.hub.RestoreAssignments(playAssignments(st.Play))
+	a.hub.MarkMatchStart(st.Play.StartTime)
+	if err := a.hub.AdvanceMatch(hub.MatchRunning, "restore"); err != nil {
+		a.logger.Warn("match_restore_state", "err", err.Error())
+	}
+	a.logger.Warn("play_session_resumed",
+		"start_time", st.Play.StartTime.UTC().Format(time.RFC3339),
+		"slots", len(st.Play.Slots),
+		"state_file", a.store.Path(),
+	)
+	return nil
+}
+
+// beginPlaySession records a started match so it survives a crash before
+// its result is submitted. Bindings restored for an interrupted match are
+// dropped: the new match's slots were taken from the live assignments.
+func (a *App) beginPlaySession(startTime time.Time, slots []state.SlotAssignment) {
+	play := &state.PlaySession{
+		StartTime: startTime.UTC(),
+		Slots:     slots,
+	}
+
+	a.playMu.Lock()
+	a.play = play
+	a.playMu.Unlock()
+
+	a.hub.ClearRestoredAssignments()
+	a.saveState()
+	a.hub.MarkMatchStart(startTime)
+	a.hub.PublishEvent("play_started", "startTime", play.StartTime.Format(time.RFC3339), "slots", len(slots))
+
+	if a.recordingEnabled() {
+		if _, err := a.startRecording("match"); err != nil {
+			a.logger.Error("recording_start_failed", "err", err.Error())
+		}
+	}
+}
+
+// endPlaySession clears the in-flight match after its result was accepted.
+func (a *App) endPlaySession() {
+	a.playMu.Lock()
+	a.play = nil
+	a.playMu.Unlock()
+
+	a.hub.ClearRestoredAssignments()
+	a.saveState()
+	a.stopRecording()
+}
+
+// handoffPlaySlot rebinds a slot of the in-flight match so results are
+// credited to the user who finished it.
+func (a *App) handoffPlaySlot(slotID, userID, name, personality string) {
+	a.playMu.Lock()
+	play := a.play
+	changed := false
+	if play != nil {
+		for i := range play.Slots {
+			if play.Slots[i].SlotID != slotID {
+				continue
+			}
+			play.Slots[i] = state.SlotAssignment{
+				SlotID:      slotID,
+				UserID:      userID,
+				Name:        name,
+				Personality: personality,
+			}
+			changed = true
+		}
+	}
+	a.playMu.Unlock()
+
+	if changed {
+		a.saveState()
+	}
+}
+
+// currentPlaySession returns a copy of the in-flight match, if any.
+func (a *App) currentPlaySession() *state.PlaySession {
+	a.playMu.Lock()
+	defer a.playMu.Unlock()
+
+	if a.play == nil {
+		return nil
+	}
+	play := *a.play
+	play.Slots = append([]state.SlotAssignment(nil), a.play.Slots...)
+	return &play
+}
+
+// saveState journals the in-flight match, the result queue, the visit log
+// and the leaderboard to STATE_FILE.
+func (a *App) saveState() {
+	if a.store == nil {
+		return
+	}
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	st := state.State{
+		Play:        a.currentPlaySession(),
+		Results:     a.results.snapshot(),
+		Visits:      a.visits.snapshot(),
+		Leaderboard: a.leaderboard.snapshot(),
+	}
+	if err := a.store.Save(st); err != nil {
+		a.logger.Error("state_save_failed", "err", err.Error())
+	}
+}
+
+func playAssignments(play *state.PlaySession) []hub.ControllerAssignment {
+	assignments := make([]hub.ControllerAssignment, 0, len(play.Slots))
+	for _, slot := range play.Slots {
+		assignments = append(assignments, hub.ControllerAssignment{
+			SlotID:      slot.SlotID,
+			UserID:      slot.UserID,
+			Name:        slot.Name,
+			Personality: slot.Personality,
+		})
+	}
+	return assignments
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/internal/qrcode"
+)
+
+const (
+	qrDefaultScale = 8
+	qrMaxScale     = 32
+	// qrBorder is the quiet zone the standard asks for, in modules.
+	qrBorder = 4
+)
+
+// controllerQRHandler serves GET /api/controller/qr, a scannable QR code of
+// the controller page with a session in its fragment, so the operator screen
+// can show codes straight from the hub.
+//
+// ?slot=p1 issues a fresh token for the user Persona has in that slot;
+// ?token=... encodes a token issued earlier. ?join=true encodes a one-time
+// /j/ join code instead, which gives a smaller, easier to scan symbol.
+// ?format=svg (default) or png, with ?scale= pixels per module for PNG.
+// ?base= overrides the origin the link points at, which otherwise comes
+// from the request.
+func (a *App) controllerQRHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format == "" {
+		format = "svg"
+	}
+	if format != "svg" && format != "png" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "format must be svg or png")
+		return
+	}
+	scale := qrDefaultScale
+	if raw := q.Get("scale"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > qrMaxScale {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "scale must be 1-"+strconv.Itoa(qrMaxScale))
+			return
+		}
+		scale = n
+	}
+	base, err := qrBaseURL(r, q.Get("base"))
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	var target joinTarget
+	switch token := strings.TrimSpace(q.Get("token")); {
+	case token != "":
+		principal, err := a.hub.VerifyToken(token, hub.ScopeController, "")
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "token is invalid or expired")
+			return
+		}
+		target = joinTarget{
+			slotID:    principal.Subject,
+			token:     token,
+			userID:    principal.Claims["userId"],
+			expiresAt: principal.ExpiresAt,
+		}
+	case q.Get("slot") != "":
+		var ok bool
+		if target, ok = a.issueSlotTarget(w, r, q.Get("slot"), q.Get("cohort")); !ok {
+			return
+		}
+	default:
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slot or token is required")
+		return
+	}
+
+	link := controllerLink(target)
+	if q.Get("join") == "true" {
+		code, err := a.joins.issue(target)
+		if err != nil {
+			a.requestLogger(r).Error("join_code_issue_failed", "slot", target.slotID, "err", err.Error())
+			a.respondError(w, http.StatusServiceUnavailable, errCodeInternal, "failed to issue join code")
+			return
+		}
+		link = joinPathPrefix + code
+	}
+
+	code, err := qrcode.Encode([]byte(base+link), qrcode.LevelM)
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "link too long for a QR code")
+		return
+	}
+
+	a.requestLogger(r).Info("controller_qr_rendered", "slot", target.slotID, "format", format, "join", q.Get("join") == "true", "version", code.Version)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Slot-Id", target.slotID)
+	w.Header().Set("X-Expires-At", target.expiresAt.UTC().Format(time.RFC3339))
+	if format == "png" {
+		body, err := code.PNG(scale, qrBorder)
+		if err != nil {
+			a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to render QR code")
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write(code.SVG(qrBorder))
+}
+
+// issueSlotTarget issues a controller token for the user Persona has in
+// slot. It writes the error response and reports false on failure.
+func (a *App) issueSlotTarget(w http.ResponseWriter, r *http.Request, rawSlot, cohort string) (joinTarget, bool) {
+	slotID, _, ok := hub.NormalizeSlotID(rawSlot)
+	if !ok {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid slot key: "+rawSlot)
+		return joinTarget{}, false
+	}
+	if a.persona == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+		return joinTarget{}, false
+	}
+	lobby, err := a.persona.FetchLobby(r.Context())
+	if err != nil {
+		a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+		a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
+		return joinTarget{}, false
+	}
+
+	for _, slot := range lobby.Slots {
+		if slot.SlotID != slotID || slot.UserID == "" {
+			continue
+		}
+		token, expiresAt, err := a.hub.IssueControllerToken(slot.SlotID, slot.UserID, slot.Name, slot.Personality, strings.TrimSpace(cohort), a.cfg.SessionTokenTTL)
+		switch {
+		case errors.Is(err, hub.ErrUnknownCohort):
+			a.respondError(w, http.StatusBadRequest, errCodeUnknownCohort, "unknown cohort")
+			return joinTarget{}, false
+		case errors.Is(err, hub.ErrBanned):
+			a.respondErrorDetails(w, http.StatusForbidden, errCodeUserBanned, "user is banned", map[string]any{"slotId": slotID})
+			return joinTarget{}, false
+		case err != nil:
+			a.logErrorWithStack("token_issue_failed", "slot", slot.SlotID, "user_id", slot.UserID, "err", err.Error())
+			a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to issue controller token")
+			return joinTarget{}, false
+		}
+		return joinTarget{
+			slotID:    slot.SlotID,
+			token:     token,
+			userID:    slot.UserID,
+			name:      slot.Name,
+			expiresAt: expiresAt,
+		}, true
+	}
+	a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotAssigned, "no user in lobby slot "+slotID, map[string]any{"slotId": slotID})
+	return joinTarget{}, false
+}
+
+// qrBaseURL returns the origin QR links point at: override when given,
+// otherwise the origin the request reached, honouring the usual reverse
+// proxy headers.
+func qrBaseURL(r *http.Request, override string) (string, error) {
+	if override = strings.TrimSpace(override); override != "" {
+		u, err := url.Parse(override)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", errors.New("base must be an absolute http(s) URL")
+		}
+		return strings.TrimSuffix(u.Scheme+"://"+u.Host+u.Path, "/"), nil
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-Host")); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host, nil
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitPruneThreshold is the tracked address count above which full
+	// buckets are dropped.
+	rateLimitPruneThreshold = 1024
+	// rateLimitLogEvery spaces the http_rate_limited warnings of one budget.
+	rateLimitLogEvery = 10 * time.Second
+)
+
+// rateLimiter is a per-IP token bucket: an address may send burst requests
+// at once and regains perMinute of them every minute. It keeps a buggy
+// controller page from hammering Persona through the hub.
+type rateLimiter struct {
+	name  string
+	rate  float64 // tokens per second
+	burst float64
+
+	mu         sync.Mutex
+	buckets    map[string]*rateBucket
+	lastLog    time.Time
+	suppressed uint64
+}
+
+type rateBucket struct {
+	tokens float64
+	seen   time.Time
+}
+
+// newRateLimiter returns nil when perMinute is not positive, which leaves the
+// routes it would guard unlimited. burst defaults to ten seconds' worth.
+func newRateLimiter(name string, perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = max(1, perMinute/6)
+	}
+	return &rateLimiter{
+		name:    name,
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// allow takes a token for ip. When none is left it reports how long until
+// the next one is available.
+func (l *rateLimiter) allow(ip string, now time.Time) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.buckets[ip]
+	if bucket == nil {
+		if len(l.buckets) >= rateLimitPruneThreshold {
+			l.pruneLocked(now)
+		}
+		bucket = &rateBucket{tokens: l.burst}
+		l.buckets[ip] = bucket
+	} else {
+		bucket.tokens = l.refill(bucket, now)
+	}
+	bucket.seen = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second)), false
+}
+
+func (l *rateLimiter) refill(bucket *rateBucket, now time.Time) float64 {
+	return min(l.burst, bucket.tokens+now.Sub(bucket.seen).Seconds()*l.rate)
+}
+
+// pruneLocked forgets addresses whose bucket has refilled; they would start
+// from a full bucket anyway.
+func (l *rateLimiter) pruneLocked(now time.Time) {
+	for ip, bucket := range l.buckets {
+		if l.refill(bucket, now) >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// noteRejected logs a refused request, at most once per rateLimitLogEvery,
+// with the number of refusals not logged since.
+func (l *rateLimiter) noteRejected(logger *slog.Logger, r *http.Request, ip string, now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastLog) < rateLimitLogEvery {
+		l.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	suppressed := l.suppressed
+	l.suppressed = 0
+	l.lastLog = now
+	l.mu.Unlock()
+
+	logger.Warn("http_rate_limited",
+		"budget", l.name,
+		"path", r.URL.Path,
+		"remote_ip", ip,
+		"suppressed", suppressed,
+	)
+}
+
+// rateLimit applies limiter to next, answering 429 with Retry-After once the
+// caller's bucket is empty. A nil limiter disables the check.
+func (a *App) rateLimit(limiter *rateLimiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := a.requestIP(r)
+		now := time.Now()
+		retryAfter, ok := limiter.allow(ip, now)
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limiter.noteRejected(a.requestLogger(r), r, ip, now)
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		a.respondErrorDetails(w, http.StatusTooManyRequests, errCodeRateLimited, "rate limit exceeded",
+			map[string]any{"budget": limiter.name, "retryAfter": seconds})
+	})
+}
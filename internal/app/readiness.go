@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"time"
+)
+
+// readyHandler reports whether the hub should receive new players. Unlike
+// /healthz it answers 503 when a dependency is down: shutdown has begun, the
+// public listener no longer serves /ws, PersonaGo is unreachable or load
+// shedding is active. Orchestrators and lobby tooling use it to route new
+// players away.
+func (a *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]any)
+	var failing []string
+	check := func(name string, ok bool, detail map[string]any) {
+		if detail == nil {
+			detail = make(map[string]any)
+		}
+		detail["ok"] = ok
+		checks[name] = detail
+		if !ok {
+			failing = append(failing, name)
+		}
+	}
+
+	check("shutdown", !a.stopping.Load(), nil)
+	check("websocket", a.serving.Load(), map[string]any{"addr": a.cfg.Addr})
+
+	if a.persona != nil {
+		checkedAt, latency, err := a.probePersona(r.Context())
+		detail := map[string]any{
+			"latencyMs": latency.Milliseconds(),
+			"checkedAt": checkedAt.UTC().Format(time.RFC3339),
+		}
+		if err != nil {
+			detail["error"] = err.Error()
+		}
+		check("persona", err == nil, detail)
+	}
+
+	status := a.hub.ShedStatus()
+	load := map[string]any{"shedding": status.Shedding, "rejected": status.Rejected}
+	if status.Shedding {
+		load["reason"] = status.Reason
+		load["since"] = status.Since.UTC().Format(time.RFC3339)
+	}
+	check("load", !status.Shedding, load)
+
+	body := map[string]any{
+		"ready":    len(failing) == 0,
+		"shedding": status.Shedding,
+		"rejected": status.Rejected,
+		"checks":   checks,
+	}
+	if status.Shedding {
+		body["reason"] = status.Reason
+		body["since"] = status.Since.UTC().Format(time.RFC3339)
+	}
+	if len(failing) > 0 {
+		body["failing"] = failing
+		a.respondJSON(w, http.StatusServiceUnavailable, body)
+		return
+	}
+	a.respondJSON(w, http.StatusOK, body)
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+)
+
+// Reconcile actions taken for a lobby mismatch.
+const (
+	reconcileIssueToken  = "issue_token"
+	reconcileHandoff     = "handoff"
+	reconcileRevokeToken = "revoke_token"
+	reconcileSkip        = "skip"
+)
+
+type reconcileAction struct {
+	SlotID string `json:"slotId"`
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	UserID string `json:"userId,omitempty"`
+	// Token and ExpiresAt carry the controller token an issue_token action
+	// minted, for staff to hand to the player. They are left out of the log
+	// and the event stream.
+	Token     string `json:"token,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	Note      string `json:"note,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// reconcileLobby realigns hub assignments with the Persona lobby: seated
+// users without a token get one, connected controllers bound to the wrong
+// user are handed over, and tokens for slots the lobby leaves empty are
+// revoked. Connected controllers are never disconnected. With dryRun the
+// actions are only reported. Every action is logged and published on the
+// event stream as an audit trail.
+func (a *App) reconcileLobby(ctx context.Context, dryRun bool) ([]reconcileAction, error) {
+	lobby, err := a.persona.FetchLobby(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seated := make(map[string]persona.Slot, len(lobby.Slots))
+	for _, slot := range lobby.Slots {
+		seated[slot.SlotID] = slot
+	}
+
+	actions := []reconcileAction{}
+	for _, mismatch := range compareLobby(lobby, a.hub.ControllerAssignments()) {
+		action := reconcileAction{SlotID: mismatch.SlotID, Kind: mismatch.Kind}
+		slot := seated[mismatch.SlotID]
+
+		switch {
+		case mismatch.Kind == lobbyNotInLobby && mismatch.Connected:
+			action.Action = reconcileSkip
+			action.UserID = mismatch.HubUserID
+			action.Note = "controller connected"
+		case mismatch.Kind == lobbyNotInLobby:
+			action.Action = reconcileRevokeToken
+			action.UserID = mismatch.HubUserID
+			if !dryRun {
+				a.hub.RevokeSlotToken(mismatch.SlotID)
+			}
+		case mismatch.Connected:
+			action.Action = reconcileHandoff
+			action.UserID = slot.UserID
+			if !dryRun {
+				if _, err := a.hub.HandoffSlot(slot.SlotID, slot.UserID, slot.Name, slot.Personality); err != nil {
+					action.Error = err.Error()
+				}
+			}
+		default:
+			action.Action = reconcileIssueToken
+			action.UserID = slot.UserID
+			if !dryRun {
+				token, expiresAt, err := a.hub.IssueControllerToken(slot.SlotID, slot.UserID, slot.Name, slot.Personality, "", a.cfg.SessionTokenTTL)
+				if err != nil {
+					action.Error = err.Error()
+				} else {
+					action.Token = token
+					action.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+				}
+			}
+		}
+
+		a.logger.Info("lobby_reconcile_action",
+			"slot", action.SlotID,
+			"kind", action.Kind,
+			"action", action.Action,
+			"user_id", action.UserID,
+			"dry_run", dryRun,
+			"err", action.Error,
+		)
+		a.hub.PublishEvent("lobby_reconcile",
+			"slotId", action.SlotID,
+			"kind", action.Kind,
+			"action", action.Action,
+			"userId", action.UserID,
+			"dryRun", dryRun,
+			"error", action.Error,
+		)
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// runLobbyReconcile runs reconcileLobby every LobbyReconcileInterval.
+func (a *App) runLobbyReconcile(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.LobbyReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, a.cfg.DBAPITimeout)
+		actions, err := a.reconcileLobby(reqCtx, a.cfg.LobbyReconcileDryRun)
+		cancel()
+		if err != nil {
+			a.logger.Warn("lobby_reconcile_failed", "err", err.Error())
+			continue
+		}
+		if len(actions) > 0 {
+			a.logger.Info("lobby_reconciled", "actions", len(actions), "dry_run", a.cfg.LobbyReconcileDryRun)
+		}
+	}
+}
+
+// adminLobbyReconcileHandler runs one reconciliation pass on demand. The
+// dryRun query parameter defaults to the configured mode.
+func (a *App) adminLobbyReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.persona == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+		return
+	}
+
+	dryRun := a.cfg.LobbyReconcileDryRun
+	if raw := r.URL.Query().Get("dryRun"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "dryRun must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+
+	actions, err := a.reconcileLobby(r.Context(), dryRun)
+	if err != nil {
+		a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+		a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
+		return
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"dryRun":  dryRun,
+		"actions": actions,
+	})
+}
@@ -0,0 +1,326 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/blob"
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/recorder"
+)
+
+var (
+	errRecordingDisabled = errors.New("recording disabled: set RECORD_DIR or STORAGE_URL")
+	errReplayRunning     = errors.New("replay already running")
+)
+
+// replayRun tracks an in-progress replay.
+type replayRun struct {
+	name    string
+	speed   float64
+	started time.Time
+	emitted atomic.Int64
+	cancel  context.CancelFunc
+}
+
+func (a *App) recordingEnabled() bool {
+	return a.recordings != nil
+}
+
+// startRecording begins capturing relayed controller frames, closing any
+// recording already in progress.
+func (a *App) startRecording(label string) (*recorder.Recorder, error) {
+	if !a.recordingEnabled() {
+		return nil, errRecordingDisabled
+	}
+	rec, err := recorder.Create(context.Background(), a.recordings, label)
+	if err != nil {
+		return nil, err
+	}
+
+	a.recMu.Lock()
+	previous := a.rec
+	a.rec = rec
+	a.recMu.Unlock()
+
+	var failed atomic.Bool
+	a.setRelayTap(func(slotID, msgType string, payload []byte) {
+		if err := rec.Record(slotID, msgType, payload); err != nil && !errors.Is(err, recorder.ErrClosed) {
+			if failed.CompareAndSwap(false, true) {
+				a.logger.Error("recording_write_failed", "name", rec.Name(), "err", err.Error())
+			}
+		}
+	})
+
+	a.closeRecording(previous)
+	a.logger.Info("recording_started", "name", rec.Name())
+	return rec, nil
+}
+
+// stopRecording ends the current recording, if any.
+func (a *App) stopRecording() *recorder.Recorder {
+	a.recMu.Lock()
+	rec := a.rec
+	a.rec = nil
+	a.recMu.Unlock()
+
+	if rec == nil {
+		return nil
+	}
+	a.setRelayTap(nil)
+	a.closeRecording(rec)
+	return rec
+}
+
+func (a *App) closeRecording(rec *recorder.Recorder) {
+	if rec == nil {
+		return
+	}
+	if err := rec.Close(); err != nil {
+		a.logger.Error("recording_close_failed", "name", rec.Name(), "err", err.Error())
+		return
+	}
+	a.logger.Info("recording_stopped", "name", rec.Name(), "frames", rec.Frames())
+}
+
+// recordingRequest is the body of POST /api/admin/recording.
+type recordingRequest struct {
+	// Action is "start" or "stop".
+	Action string `json:"action"`
+	Label  string `json:"label,omitempty"`
+}
+
+// recordingInfo describes the recording in progress or just stopped.
+type recordingInfo struct {
+	Name    string `json:"name"`
+	Started string `json:"started"`
+	Frames  int    `json:"frames"`
+}
+
+type recordingFile struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"modTime"`
+}
+
+type recordingStatus struct {
+	Enabled    bool            `json:"enabled"`
+	Active     *recordingInfo  `json:"active"`
+	Recordings []recordingFile `json:"recordings"`
+}
+
+func recordingResponse(rec *recorder.Recorder) recordingInfo {
+	return recordingInfo{
+		Name:    rec.Name(),
+		Started: rec.Started().UTC().Format(time.RFC3339),
+		Frames:  rec.Frames(),
+	}
+}
+
+func (a *App) adminRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp := recordingStatus{
+			Enabled:    a.recordingEnabled(),
+			Recordings: []recordingFile{},
+		}
+		a.recMu.Lock()
+		if a.rec != nil {
+			active := recordingResponse(a.rec)
+			resp.Active = &active
+		}
+		a.recMu.Unlock()
+		if a.recordingEnabled() {
+			infos, err := recorder.List(r.Context(), a.recordings)
+			if err != nil {
+				a.respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+				return
+			}
+			for _, info := range infos {
+				resp.Recordings = append(resp.Recordings, recordingFile{
+					Name:    info.Name,
+					Size:    info.Size,
+					ModTime: info.ModTime.UTC().Format(time.RFC3339),
+				})
+			}
+		}
+		a.respondJSON(w, http.StatusOK, resp)
+
+	case http.MethodPost:
+		var req recordingRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+		switch strings.ToLower(strings.TrimSpace(req.Action)) {
+		case "start":
+			label := req.Label
+			if strings.TrimSpace(label) == "" {
+				label = "manual"
+			}
+			rec, err := a.startRecording(label)
+			if err != nil {
+				if errors.Is(err, errRecordingDisabled) {
+					a.respondError(w, http.StatusConflict, errCodeRecordingOff, err.Error())
+					return
+				}
+				a.respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+				return
+			}
+			a.respondJSON(w, http.StatusOK, recordingResponse(rec))
+		case "stop":
+			rec := a.stopRecording()
+			if rec == nil {
+				a.respondError(w, http.StatusNotFound, errCodeNotFound, "no recording in progress")
+				return
+			}
+			a.respondJSON(w, http.StatusOK, recordingResponse(rec))
+		default:
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "action must be start or stop")
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startReplay feeds a recording back to the game listeners at its original
+// timing in the background.
+func (a *App) startReplay(name string, speed float64) (*replayRun, error) {
+	if !a.recordingEnabled() {
+		return nil, errRecordingDisabled
+	}
+	if err := recorder.ValidName(name); err != nil {
+		return nil, err
+	}
+	if _, err := a.recordings.Stat(context.Background(), name); err != nil {
+		return nil, err
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &replayRun{name: name, speed: speed, started: time.Now(), cancel: cancel}
+
+	a.recMu.Lock()
+	if a.replay != nil {
+		a.recMu.Unlock()
+		cancel()
+		return nil, errReplayRunning
+	}
+	a.replay = run
+	a.recMu.Unlock()
+
+	a.logger.Info("replay_started", "name", name, "speed", speed)
+	crash.Go(func() {
+		defer cancel()
+		skipped := 0
+		emitted, err := recorder.Replay(ctx, a.recordings, name, speed, func(frame recorder.Frame) error {
+			if !a.hub.InjectFrame(frame.Slot, frame.Type, frame.Data) {
+				skipped++
+			}
+			run.emitted.Add(1)
+			return nil
+		})
+
+		a.recMu.Lock()
+		if a.replay == run {
+			a.replay = nil
+		}
+		a.recMu.Unlock()
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			a.logger.Info("replay_cancelled", "name", name, "frames", emitted)
+		case err != nil:
+			a.logger.Error("replay_failed", "name", name, "frames", emitted, "err", err.Error())
+		default:
+			a.logger.Info("replay_finished", "name", name, "frames", emitted, "skipped_no_game", skipped)
+		}
+	})
+	return run, nil
+}
+
+// replayRequest is the body of POST /api/admin/replay.
+type replayRequest struct {
+	Name string `json:"name"`
+	// Speed scales playback; 0 plays at the recorded pace.
+	Speed float64 `json:"speed,omitempty"`
+}
+
+type replayInfo struct {
+	Name    string  `json:"name"`
+	Speed   float64 `json:"speed"`
+	Started string  `json:"started"`
+	Frames  int64   `json:"frames"`
+}
+
+// replayStatus carries the running replay, or null.
+type replayStatus struct {
+	Replay *replayInfo `json:"replay"`
+}
+
+func replayResponse(run *replayRun) *replayInfo {
+	return &replayInfo{
+		Name:    run.name,
+		Speed:   run.speed,
+		Started: run.started.UTC().Format(time.RFC3339),
+		Frames:  run.emitted.Load(),
+	}
+}
+
+func (a *App) adminReplayHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.recMu.Lock()
+		run := a.replay
+		a.recMu.Unlock()
+		if run == nil {
+			a.respondJSON(w, http.StatusOK, replayStatus{})
+			return
+		}
+		a.respondJSON(w, http.StatusOK, replayStatus{Replay: replayResponse(run)})
+
+	case http.MethodPost:
+		var req replayRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+		run, err := a.startReplay(strings.TrimSpace(req.Name), req.Speed)
+		switch {
+		case errors.Is(err, errRecordingDisabled):
+			a.respondError(w, http.StatusConflict, errCodeRecordingOff, err.Error())
+			return
+		case errors.Is(err, errReplayRunning):
+			a.respondError(w, http.StatusConflict, errCodeReplayRunning, err.Error())
+			return
+		case errors.Is(err, blob.ErrNotFound):
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "recording not found")
+			return
+		case err != nil:
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		a.respondJSON(w, http.StatusAccepted, replayStatus{Replay: replayResponse(run)})
+
+	case http.MethodDelete:
+		a.recMu.Lock()
+		run := a.replay
+		a.recMu.Unlock()
+		if run == nil {
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "no replay running")
+			return
+		}
+		run.cancel()
+		a.respondJSON(w, http.StatusOK, replayStatus{Replay: replayResponse(run)})
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
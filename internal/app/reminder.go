@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// resultReminderPollInterval bounds how late an overdue result is noticed.
+const resultReminderPollInterval = 15 * time.Second
+
+// resultOverdue reports whether the running match has gone longer than
+// ResultReminderAfter without its result being submitted.
+func (a *App) resultOverdue(elapsed time.Duration, running bool) bool {
+	return running && a.cfg.ResultReminderAfter > 0 && elapsed > a.cfg.ResultReminderAfter
+}
+
+// runResultReminder alerts the operator once per match when no result has
+// been submitted within ResultReminderAfter, the usual sign that somebody
+// forgot to send the scores. The alert is logged, published on the event
+// stream and posted to ALERT_WEBHOOK_URL when configured.
+func (a *App) runResultReminder(ctx context.Context) {
+	interval := min(a.cfg.ResultReminderAfter/4, resultReminderPollInterval)
+	interval = max(interval, time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var client *webhookClient
+	if a.cfg.AlertWebhookURL != "" {
+		client = newWebhookClient(a.cfg.AlertWebhookURL)
+	}
+
+	var reminded time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start, elapsed, running := a.hub.MatchTimer()
+		if !a.resultOverdue(elapsed, running) || start.Equal(reminded) {
+			continue
+		}
+		reminded = start
+
+		slots := []string{}
+		if play := a.currentPlaySession(); play != nil {
+			for _, slot := range play.Slots {
+				slots = append(slots, slot.SlotID)
+			}
+		}
+
+		startTime := start.UTC().Format(time.RFC3339)
+		a.logger.Warn("result_overdue", "start_time", startTime, "elapsed", elapsed.Round(time.Second).String(), "slots", slots)
+		a.hub.PublishEvent("result_overdue", "startTime", startTime, "elapsedMs", elapsed.Milliseconds(), "slots", slots)
+
+		if client == nil {
+			continue
+		}
+		payload := map[string]any{
+			"type":      "result_overdue",
+			"gameId":    a.cfg.GameID,
+			"startTime": startTime,
+			"elapsedMs": elapsed.Milliseconds(),
+			"slots":     slots,
+			"timestamp": time.Now().UnixMilli(),
+		}
+		if err := client.post(ctx, payload); err != nil {
+			a.logger.Warn("alert_webhook_failed", "type", "result_overdue", "err", err.Error())
+		}
+	}
+}
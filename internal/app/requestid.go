@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+)
+
+const (
+	requestIDHeader = persona.RequestIDHeader
+	// maxRequestIDLen bounds ids taken from clients so they cannot bloat
+	// every log line of the request.
+	maxRequestIDLen = 128
+)
+
+type requestLoggerKey struct{}
+
+// requestID returns the caller's X-Request-ID when it is short and made of
+// safe characters, and a new random id otherwise.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	var buf [12]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID tags r with id: the response echoes it, logger gains a
+// request_id attribute for the handler to use, and PersonaGo calls made with
+// the request context forward it.
+func withRequestID(w http.ResponseWriter, r *http.Request, logger *slog.Logger, id string) (*http.Request, *slog.Logger) {
+	w.Header().Set(requestIDHeader, id)
+	logger = logger.With("request_id", id)
+	ctx := context.WithValue(r.Context(), requestLoggerKey{}, logger)
+	ctx = persona.WithRequestID(ctx, id)
+	return r.WithContext(ctx), logger
+}
+
+// requestLogger returns the logger scoped to r by loggingMiddleware, or the
+// application logger for requests that did not pass through it.
+func (a *App) requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(requestLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return a.logger
+}
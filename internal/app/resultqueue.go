@@ -0,0 +1,318 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+	"github.com/aritumn2025/cgb-io-hub/internal/state"
+)
+
+const (
+	resultRetryBase = 2 * time.Second
+	resultRetryMax  = 5 * time.Minute
+)
+
+// resultQueue holds results PersonaGo failed to accept. Entries are retried
+// one at a time by runResultRetries and journaled to STATE_FILE with the
+// play session, so scores survive both a PersonaGo outage and a hub restart.
+type resultQueue struct {
+	mu      sync.Mutex
+	pending []state.PendingResult
+	// wake nudges runResultRetries when an entry is added or re-driven.
+	wake chan struct{}
+}
+
+func newResultQueue() resultQueue {
+	return resultQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *resultQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *resultQueue) snapshot() []state.PendingResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.pending)
+}
+
+func (q *resultQueue) restore(pending []state.PendingResult) {
+	q.mu.Lock()
+	q.pending = slices.Clone(pending)
+	q.mu.Unlock()
+	q.notify()
+}
+
+func (q *resultQueue) add(entry state.PendingResult) {
+	q.mu.Lock()
+	q.pending = append(q.pending, entry)
+	q.mu.Unlock()
+	q.notify()
+}
+
+// next returns the entry due soonest among those still retried
+// automatically. ok is false while it is not yet due, with wait the time
+// until it is; wait is negative when nothing is pending.
+func (q *resultQueue) next(now time.Time) (entry state.PendingResult, wait time.Duration, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	found := false
+	for _, candidate := range q.pending {
+		if candidate.Failed {
+			continue
+		}
+		if !found || candidate.NextAttempt.Before(entry.NextAttempt) {
+			entry, found = candidate, true
+		}
+	}
+	if !found {
+		return state.PendingResult{}, -1, false
+	}
+	if wait = entry.NextAttempt.Sub(now); wait > 0 {
+		return state.PendingResult{}, wait, false
+	}
+	return entry, 0, true
+}
+
+// update replaces the entry with the same id, reporting whether it was
+// still queued.
+func (q *resultQueue) update(entry state.PendingResult) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.pending {
+		if q.pending[i].ID == entry.ID {
+			q.pending[i] = entry
+			return true
+		}
+	}
+	return false
+}
+
+// remove drops the entry with id, or every entry when id is empty, and
+// returns how many went.
+func (q *resultQueue) remove(id string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	before := len(q.pending)
+	q.pending = slices.DeleteFunc(q.pending, func(entry state.PendingResult) bool {
+		return id == "" || entry.ID == id
+	})
+	return before - len(q.pending)
+}
+
+// redrive makes the entry with id, or every entry when id is empty, due now
+// with a fresh retry budget, including entries that had given up.
+func (q *resultQueue) redrive(id string, now time.Time) int {
+	q.mu.Lock()
+	n := 0
+	for i := range q.pending {
+		if id != "" && q.pending[i].ID != id {
+			continue
+		}
+		q.pending[i].Failed = false
+		q.pending[i].Attempts = 0
+		q.pending[i].NextAttempt = now
+		n++
+	}
+	q.mu.Unlock()
+	if n > 0 {
+		q.notify()
+	}
+	return n
+}
+
+// retryableResultError reports whether a failed submission may succeed
+// later: the request never got an answer, or PersonaGo was overloaded or
+// failing. Other answers mean the result itself was refused.
+func retryableResultError(err error) bool {
+	var apiErr *persona.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Status == 0 || apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= 500
+}
+
+func resultRetryBackoff(attempts int) time.Duration {
+	backoff := resultRetryBase
+	for i := 1; i < attempts && backoff < resultRetryMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, resultRetryMax)
+}
+
+// queueResult journals a submission PersonaGo did not take so
+// runResultRetries sends it again under the same id.
+func (a *App) queueResult(id string, startTime time.Time, submissions []persona.GameResult, cause error) state.PendingResult {
+	now := time.Now().UTC()
+	entry := state.PendingResult{
+		ID:          id,
+		StartTime:   startTime.UTC(),
+		Results:     make([]state.ResultEntry, 0, len(submissions)),
+		QueuedAt:    now,
+		Attempts:    1,
+		NextAttempt: now.Add(resultRetryBackoff(1)),
+		LastError:   cause.Error(),
+	}
+	for _, res := range submissions {
+		entry.Results = append(entry.Results, state.ResultEntry{
+			Slot:     res.Slot,
+			UserID:   res.UserID,
+			Name:     res.Name,
+			Score:    res.Score,
+			Metadata: res.Metadata,
+		})
+	}
+	a.results.add(entry)
+	a.saveState()
+	a.logger.Warn("result_queued", "id", entry.ID, "start_time", entry.StartTime.Format(time.RFC3339), "err", entry.LastError)
+	a.hub.PublishEvent("result_queued", "id", entry.ID, "startTime", entry.StartTime.Format(time.RFC3339), "submitted", len(entry.Results))
+	return entry
+}
+
+// runResultRetries re-sends queued results as they fall due, backing off
+// exponentially between attempts. After RESULT_RETRY_ATTEMPTS failures an
+// entry is marked failed and left for an operator to re-drive or discard.
+func (a *App) runResultRetries(ctx context.Context) {
+	for {
+		entry, wait, ok := a.results.next(time.Now())
+		if ok {
+			a.retryResult(ctx, entry)
+			continue
+		}
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-a.results.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (a *App) retryResult(ctx context.Context, entry state.PendingResult) {
+	submissions := make([]persona.GameResult, 0, len(entry.Results))
+	for _, res := range entry.Results {
+		submissions = append(submissions, persona.GameResult{
+			Slot:     res.Slot,
+			UserID:   res.UserID,
+			Name:     res.Name,
+			Score:    res.Score,
+			Metadata: res.Metadata,
+		})
+	}
+
+	resp, err := a.persona.SubmitGameResult(ctx, entry.StartTime, submissions, entry.ID)
+	if ctx.Err() != nil {
+		return
+	}
+	entry.Attempts++
+	if err == nil {
+		a.results.remove(entry.ID)
+		a.saveState()
+		a.logger.Info("result_retry_submitted", "id", entry.ID, "play_id", resp.PlayID, "attempts", entry.Attempts)
+		a.hub.PublishEvent("result_submitted",
+			"playId", resp.PlayID,
+			"startTime", entry.StartTime.Format(time.RFC3339),
+			"submitted", len(submissions),
+			"queueId", entry.ID,
+		)
+		return
+	}
+
+	entry.LastError = err.Error()
+	if !retryableResultError(err) || entry.Attempts >= a.cfg.ResultRetryAttempts {
+		entry.Failed = true
+		a.logger.Error("result_retry_gave_up", "id", entry.ID, "attempts", entry.Attempts, "err", entry.LastError)
+		a.hub.PublishEvent("result_failed", "id", entry.ID, "attempts", entry.Attempts, "error", entry.LastError)
+	} else {
+		entry.NextAttempt = time.Now().UTC().Add(resultRetryBackoff(entry.Attempts))
+		a.logger.Warn("result_retry_failed", "id", entry.ID, "attempts", entry.Attempts, "next_attempt", entry.NextAttempt.Format(time.RFC3339), "err", entry.LastError)
+	}
+	if a.results.update(entry) {
+		a.saveState()
+	}
+}
+
+// resultQueueHandler serves /api/game/result/queue. GET lists the queued
+// results; POST re-drives one (?id=) or all of them now, including those
+// that gave up; DELETE discards them once the scores were entered some
+// other way.
+func (a *App) resultQueueHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if a.persona == nil {
+			a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+			return
+		}
+		n := a.results.redrive(id, time.Now().UTC())
+		if n == 0 && id != "" {
+			a.respondErrorDetails(w, http.StatusNotFound, errCodeNotFound, "no queued result "+id, map[string]any{"id": id})
+			return
+		}
+		a.saveState()
+		a.requestLogger(r).Info("result_redrive", "id", id, "count", n)
+	case http.MethodDelete:
+		n := a.results.remove(id)
+		if n == 0 && id != "" {
+			a.respondErrorDetails(w, http.StatusNotFound, errCodeNotFound, "no queued result "+id, map[string]any{"id": id})
+			return
+		}
+		a.saveState()
+		a.requestLogger(r).Warn("result_discarded", "id", id, "count", n)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pending := a.results.snapshot()
+	entries := make([]map[string]any, 0, len(pending))
+	failed := 0
+	for _, entry := range pending {
+		status := "pending"
+		if entry.Failed {
+			status = "failed"
+			failed++
+		}
+		item := map[string]any{
+			"id":        entry.ID,
+			"status":    status,
+			"startTime": entry.StartTime.Format(time.RFC3339),
+			"queuedAt":  entry.QueuedAt.Format(time.RFC3339),
+			"attempts":  entry.Attempts,
+			"lastError": entry.LastError,
+			"results":   entry.Results,
+		}
+		if !entry.Failed {
+			item["nextAttemptAt"] = entry.NextAttempt.Format(time.RFC3339)
+		}
+		entries = append(entries, item)
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"gameId":  a.cfg.GameID,
+		"entries": entries,
+		"pending": len(entries) - failed,
+		"failed":  failed,
+	})
+}
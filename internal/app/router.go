@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/aritumn2025/cgb-io-hub/internal/hub"
 	"github.com/aritumn2025/cgb-io-hub/internal/persona"
+	"github.com/aritumn2025/cgb-io-hub/internal/state"
 )
 
 const (
@@ -22,70 +24,83 @@ const (
 	secretControllerToken = "111525"
 )
 
-func (a *App) buildRouter(assets http.FileSystem) http.Handler {
+// maxStartCountdown bounds the countdown /api/game/start may announce.
+const maxStartCountdown = 60
+
+func (a *App) buildRouter(assets *staticFiles) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/readyz", a.readyHandler)
+	mux.HandleFunc("/api/version", a.versionHandler)
+	mux.HandleFunc(jwksPath, a.jwksHandler)
+	mux.HandleFunc(openAPIPath, a.openAPIHandler)
+	mux.Handle(apiDocsPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assets.serveFile(w, r, "api-docs/index.html")
+	}))
 	mux.Handle("/ws", http.HandlerFunc(a.hub.HandleWS))
-	mux.HandleFunc("/api/controller/session", a.controllerSessionHandler)
-	mux.HandleFunc("/api/controller/assignments", a.controllerAssignmentsHandler)
-	mux.HandleFunc("/api/game/lobby", a.gameLobbyHandler)
-	mux.HandleFunc("/api/game/start", a.gameStartHandler)
-	mux.HandleFunc("/api/game/result", a.gameResultHandler)
+	mux.Handle("/ws/poll", http.HandlerFunc(a.hub.HandlePoll))
+	if a.cfg.SocketIO {
+		mux.Handle("/socket.io/", http.HandlerFunc(a.hub.HandleSocketIO))
+	}
+	// Session and claim call Persona, so they get a tighter budget than the
+	// lobby, game and assignment APIs.
+	session := newRateLimiter("session", a.cfg.SessionRateLimit, a.cfg.SessionRateBurst)
+	api := newRateLimiter("api", a.cfg.APIRateLimit, a.cfg.APIRateBurst)
+	mux.Handle("/api/controller/session", a.rateLimit(session, a.requireControllerAuth(a.controllerSessionHandler)))
+	mux.Handle(controllerSessionPrefix, a.rateLimit(api, a.requireAPIKey(a.controllerSessionRevokeHandler)))
+	mux.Handle("/api/controller/sessions", a.rateLimit(api, a.requireAPIKey(a.controllerSessionsHandler)))
+	mux.Handle("/api/controller/sessions/batch", a.rateLimit(api, a.requireAPIKey(a.controllerSessionsBatchHandler)))
+	mux.Handle("/api/controller/qr", a.rateLimit(api, a.requireAPIKey(a.controllerQRHandler)))
+	mux.Handle("/api/controller/lobby", a.rateLimit(session, a.requireControllerAuth(a.controllerLobbyHandler)))
+	mux.Handle("/api/controller/claim", a.rateLimit(session, a.requireControllerAuth(a.controllerClaimHandler)))
+	mux.Handle("/api/controller/assignments", a.rateLimit(api, a.requireAPIKey(a.controllerAssignmentsHandler)))
+	mux.Handle("/api/controller/assignments/stream", a.rateLimit(api, a.requireAPIKey(a.controllerAssignmentsStreamHandler)))
+	mux.Handle("/api/game/lobby", a.rateLimit(api, a.requireSpectator(a.gameLobbyHandler)))
+	mux.Handle("/api/game/start", a.rateLimit(api, a.requireAPIKey(a.gameStartHandler)))
+	mux.Handle("/api/game/result", a.rateLimit(api, a.requireAPIKey(a.gameResultHandler)))
+	mux.Handle("/api/game/result/queue", a.rateLimit(api, a.requireAPIKey(a.resultQueueHandler)))
+	mux.Handle("/api/game/visits", a.rateLimit(api, a.requireAPIKey(a.gameVisitsHandler)))
+	mux.Handle("/api/game/leaderboard", a.rateLimit(api, a.requireSpectator(a.gameLeaderboardHandler)))
+	mux.Handle("/api/game/finish", a.rateLimit(api, a.requireAPIKey(a.gameFinishHandler)))
+	mux.Handle("/api/game/match", a.rateLimit(api, a.requireSpectator(a.gameMatchHandler)))
+	mux.Handle("/api/game/timer", a.rateLimit(api, a.requireSpectator(a.gameTimerHandler)))
+	mux.Handle("/api/game/status", a.rateLimit(api, a.requireSpectator(a.gameStatusHandler)))
+	mux.Handle(joinPathPrefix, a.rateLimit(session, http.HandlerFunc(a.joinRedirectHandler)))
+	if !a.adminEnabled() {
+		a.registerAdminRoutes(mux)
+		a.registerAdminUI(mux, assets)
+	} else {
+		// The dashboard lives on the admin listener only.
+		mux.Handle(adminUIPath, http.NotFoundHandler())
+		mux.Handle(adminUIPath+"/", http.NotFoundHandler())
+	}
 	mux.Handle(secretControllerPath, http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			if r.URL.Query().Get("help") != secretControllerToken {
 				http.NotFound(w, r)
 				return
 			}
-			serveAssetFile(w, r, assets, secretControllerPath+"/index.html")
+			assets.serveFile(w, r, secretControllerPath+"/index.html")
 		}))
 	mux.Handle("/staff", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		serveAssetFile(w, r, assets, "staff/index.html")
+		assets.serveFile(w, r, "staff/index.html")
 	}))
-	staticHandler := http.FileServer(assets)
 	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if path == "" || path == "/" {
-			serveAssetFile(w, r, assets, "index.html")
+			assets.serveFile(w, r, "index.html")
 			return
 		}
 		if path == "/index.html" {
 			// Avoid duplicate content path; serve main entry point.
-			serveAssetFile(w, r, assets, "index.html")
+			assets.serveFile(w, r, "index.html")
 			return
 		}
-		staticHandler.ServeHTTP(w, r)
+		assets.ServeHTTP(w, r)
 	}))
 	return mux
 }
 
-func serveAssetFile(w http.ResponseWriter, r *http.Request, assets http.FileSystem, name string) {
-	file, err := assets.Open(name)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
-	defer file.Close()
-
-	info, err := file.Stat()
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
-
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		http.NotFound(w, r)
-		return
-	}
-
-	displayName := name
-	if idx := strings.LastIndex(name, "/"); idx >= 0 && idx+1 < len(name) {
-		displayName = name[idx+1:]
-	}
-
-	http.ServeContent(w, r, displayName, info.ModTime(), file)
-}
-
 func (a *App) controllerSessionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", http.MethodPost)
@@ -94,43 +109,39 @@ func (a *App) controllerSessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if a.persona == nil {
-		a.respondJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error": "persona integration disabled",
-		})
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	a.limitBody(w, r)
 	defer r.Body.Close()
 
-	var req struct {
-		UserID string `json:"userId"`
-	}
+	var req controllerSessionRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&req); err != nil {
 		if errors.Is(err, io.EOF) {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "request body required"})
+			a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
 			return
 		}
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
 		return
 	}
 	if err := decoder.Decode(new(struct{})); err != io.EOF {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "unexpected trailing content"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
 		return
 	}
 
 	userID := strings.TrimSpace(req.UserID)
 	if userID == "" {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "userId is required"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "userId is required")
 		return
 	}
 
 	slot, err := a.persona.FindSlotForUser(r.Context(), userID)
 	if err != nil {
 		if errors.Is(err, persona.ErrUserNotFound) {
-			a.respondJSON(w, http.StatusNotFound, map[string]string{"error": "user not present in lobby"})
+			a.respondError(w, http.StatusNotFound, errCodeUserNotInLobby, "user not present in lobby")
 			return
 		}
 		var apiErr *persona.APIError
@@ -145,23 +156,63 @@ func (a *App) controllerSessionHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			a.logErrorWithStack("persona_lookup_failed", "user_id", userID, "err", err.Error())
 		}
-		a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to verify user lobby assignment"})
+		a.respondError(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to verify user lobby assignment")
 		return
 	}
 
+	cohort := strings.TrimSpace(req.Cohort)
 	token, expiresAt, err := a.hub.IssueControllerToken(
 		slot.SlotID,
 		slot.UserID,
 		slot.Name,
 		slot.Personality,
+		cohort,
 		a.cfg.SessionTokenTTL,
 	)
+	if errors.Is(err, hub.ErrUnknownCohort) {
+		a.respondError(w, http.StatusBadRequest, errCodeUnknownCohort, "unknown cohort")
+		return
+	}
+	if errors.Is(err, hub.ErrBanned) {
+		a.requestLogger(r).Warn("token_issue_refused", "slot", slot.SlotID, "user_id", slot.UserID, "err", err.Error())
+		a.respondError(w, http.StatusForbidden, errCodeUserBanned, "user is banned")
+		return
+	}
 	if err != nil {
 		a.logErrorWithStack("token_issue_failed", "slot", slot.SlotID, "user_id", slot.UserID, "err", err.Error())
-		a.respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to issue controller token"})
+		a.respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to issue controller token")
 		return
 	}
 
+	a.respondJSON(w, http.StatusCreated, a.sessionResponse(*slot, cohort, token, expiresAt))
+}
+
+// controllerSessionRequest is the body of POST /api/controller/session.
+type controllerSessionRequest struct {
+	UserID string `json:"userId"`
+	Cohort string `json:"cohort,omitempty"`
+}
+
+// controllerSessionResponse is a controller token issued to a lobby user.
+type controllerSessionResponse struct {
+	SlotID    string      `json:"slotId"`
+	Token     string      `json:"token"`
+	TTL       int         `json:"ttl"`
+	ExpiresAt string      `json:"expiresAt"`
+	User      sessionUser `json:"user"`
+	GameID    string      `json:"gameId"`
+	Cohort    string      `json:"cohort,omitempty"`
+}
+
+type sessionUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Personality string `json:"personality"`
+}
+
+// sessionResponse is the /api/controller/session body for a token issued
+// to the user in slot for cohort.
+func (a *App) sessionResponse(slot persona.Slot, cohort, token string, expiresAt time.Time) controllerSessionResponse {
 	ttlSeconds := int(time.Until(expiresAt).Seconds())
 	if ttlSeconds < 1 {
 		ttlSeconds = int(a.cfg.SessionTokenTTL.Seconds())
@@ -170,18 +221,19 @@ func (a *App) controllerSessionHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	a.respondJSON(w, http.StatusCreated, map[string]any{
-		"slotId":    slot.SlotID,
-		"token":     token,
-		"ttl":       ttlSeconds,
-		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
-		"user": map[string]string{
-			"id":          slot.UserID,
-			"name":        slot.Name,
-			"personality": slot.Personality,
+	return controllerSessionResponse{
+		SlotID:    slot.SlotID,
+		Token:     token,
+		TTL:       ttlSeconds,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		User: sessionUser{
+			ID:          slot.UserID,
+			Name:        slot.Name,
+			Personality: slot.Personality,
 		},
-		"gameId": a.cfg.GameID,
-	})
+		GameID: a.cfg.GameID,
+		Cohort: cohort,
+	}
 }
 
 func (a *App) controllerAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
@@ -191,17 +243,32 @@ func (a *App) controllerAssignmentsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	assignments := a.hub.ControllerAssignments()
-	type assignmentResponse struct {
-		SlotID         string  `json:"slotId"`
-		UserID         string  `json:"userId,omitempty"`
-		Name           string  `json:"name,omitempty"`
-		Personality    string  `json:"personality,omitempty"`
-		Connected      bool    `json:"connected"`
-		LastSeen       *string `json:"lastSeen,omitempty"`
-		TokenExpiresAt *string `json:"tokenExpiresAt,omitempty"`
-	}
+	a.respondJSON(w, http.StatusOK, assignmentsResponse{
+		Assignments: assignmentResponses(a.hub.ControllerAssignments()),
+	})
+}
+
+type assignmentsResponse struct {
+	Assignments []assignmentResponse `json:"assignments"`
+}
 
+type assignmentResponse struct {
+	SlotID         string  `json:"slotId"`
+	UserID         string  `json:"userId,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Personality    string  `json:"personality,omitempty"`
+	Connected      bool    `json:"connected"`
+	LastSeen       *string `json:"lastSeen,omitempty"`
+	TokenExpiresAt *string `json:"tokenExpiresAt,omitempty"`
+	LastSeq        uint64  `json:"lastSeq"`
+	Stale          bool    `json:"stale"`
+	Tutorial       string  `json:"tutorial,omitempty"`
+	Cohort         string  `json:"cohort,omitempty"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+func assignmentResponses(assignments []hub.ControllerAssignment) []assignmentResponse {
 	responses := make([]assignmentResponse, 0, len(assignments))
 	for _, record := range assignments {
 		resp := assignmentResponse{
@@ -210,6 +277,11 @@ func (a *App) controllerAssignmentsHandler(w http.ResponseWriter, r *http.Reques
 			Name:        record.Name,
 			Personality: record.Personality,
 			Connected:   record.Connected,
+			LastSeq:     record.LastSeq,
+			Stale:       record.Stale,
+			Tutorial:    string(record.Tutorial),
+			Cohort:      record.Cohort,
+			Meta:        record.Meta,
 		}
 		if !record.LastSeen.IsZero() {
 			lastSeen := record.LastSeen.UTC().Format(time.RFC3339)
@@ -221,10 +293,7 @@ func (a *App) controllerAssignmentsHandler(w http.ResponseWriter, r *http.Reques
 		}
 		responses = append(responses, resp)
 	}
-
-	a.respondJSON(w, http.StatusOK, map[string]any{
-		"assignments": responses,
-	})
+	return responses
 }
 
 func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
@@ -235,33 +304,38 @@ func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if a.persona == nil {
-		a.respondJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error": "persona integration disabled",
-		})
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
 		return
 	}
-
-	var req struct {
-		Slots []string `json:"slots"`
+	if !a.requireMatchState(w, r, hub.MatchIdle, hub.MatchLobbyLocked) {
+		return
 	}
 
+	var req gameStartRequest
+
 	if r.Body != nil {
-		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+		a.limitBody(w, r)
 		defer r.Body.Close()
 
 		decoder := json.NewDecoder(r.Body)
 		decoder.DisallowUnknownFields()
 		if err := decoder.Decode(&req); err != nil {
 			if !errors.Is(err, io.EOF) {
-				a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+				a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
 				return
 			}
 		} else if err := decoder.Decode(new(struct{})); err != io.EOF {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "unexpected trailing content"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
 			return
 		}
 	}
 
+	if req.Countdown < 0 || req.Countdown > maxStartCountdown {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("countdown must be 0-%d seconds", maxStartCountdown))
+		return
+	}
+	countdown := time.Duration(req.Countdown) * time.Second
+
 	assignments := a.hub.ControllerAssignments()
 	index := make(map[string]hub.ControllerAssignment, len(assignments))
 	connectedPlayers := 0
@@ -284,7 +358,7 @@ func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			if _, ok := index[slotID]; !ok {
-				a.respondJSON(w, http.StatusNotFound, map[string]string{"error": "slot not found: " + slotID})
+				a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotFound, "slot not found: "+slotID, map[string]any{"slotId": slotID})
 				return
 			}
 			seen[slotID] = struct{}{}
@@ -300,7 +374,9 @@ func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
 
 	sort.Strings(targetSlots)
 
-	requiredPlayers := a.cfg.MaxControllers
+	// The limit can be changed at runtime through /api/admin/config, so the
+	// hub's current value counts rather than the configured one.
+	requiredPlayers := a.hub.Status().MaxControllers
 	if requiredPlayers <= 0 {
 		requiredPlayers = 4
 	}
@@ -311,24 +387,37 @@ func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
 		if forceStart {
 			notified = a.hub.NotifyGameStart(targetSlots, true, connectedPlayers)
 		}
-		a.respondJSON(w, http.StatusOK, map[string]any{
-			"gameId":    a.cfg.GameID,
-			"marked":    []any{},
-			"count":     0,
-			"slots":     targetSlots,
-			"skipped":   []any{},
-			"message":   "no eligible players to mark",
-			"connected": connectedPlayers,
-			"required":  requiredPlayers,
-			"forced":    forceStart,
-			"notified":  notified,
+		a.respondJSON(w, http.StatusOK, gameStartResponse{
+			GameID:    a.cfg.GameID,
+			Marked:    []visitResult{},
+			Slots:     targetSlots,
+			Skipped:   []string{},
+			Message:   "no eligible players to mark",
+			Connected: connectedPlayers,
+			Required:  requiredPlayers,
+			Forced:    forceStart,
+			Notified:  notified,
 		})
 		return
 	}
 
-	type visitResult struct {
-		SlotID string `json:"slotId"`
-		UserID string `json:"userId"`
+	visitTime := time.Now()
+	if a.visitPolicyEnabled() {
+		denied := make([]map[string]any, 0)
+		for _, slotID := range targetSlots {
+			userID := index[slotID].UserID
+			if userID == "" {
+				continue
+			}
+			if denial, ok := a.visits.check(userID, visitTime, a.cfg.VisitCooldown, a.cfg.VisitDailyLimit); !ok {
+				denied = append(denied, visitDenialDetail(slotID, userID, denial))
+			}
+		}
+		if len(denied) > 0 {
+			a.requestLogger(r).Warn("visit_limited", "slots", len(denied))
+			a.respondErrorDetails(w, http.StatusConflict, errCodeVisitLimited, "visit policy refuses some players; remove them or wait", map[string]any{"slots": denied})
+			return
+		}
 	}
 
 	results := make([]visitResult, 0, len(targetSlots))
@@ -341,40 +430,92 @@ func (a *App) gameStartHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := a.persona.RecordVisit(r.Context(), rec.UserID); err != nil {
-			a.logger.Error("persona_visit_failed", "slot", slotID, "user_id", rec.UserID, "err", err.Error())
-			a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to mark visit for slot " + slotID})
+			a.requestLogger(r).Error("persona_visit_failed", "slot", slotID, "user_id", rec.UserID, "err", err.Error())
+			a.respondErrorDetails(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to mark visit for slot "+slotID, map[string]any{"slotId": slotID})
 			return
 		}
 
+		if a.visitPolicyEnabled() {
+			a.visits.record(rec.UserID, visitTime, a.cfg.VisitCooldown)
+		}
 		results = append(results, visitResult{
 			SlotID: slotID,
 			UserID: rec.UserID,
 		})
 	}
 
+	startTime := time.Now().UTC()
+	playSlots := make([]state.SlotAssignment, 0, len(results))
+	for _, res := range results {
+		rec := index[res.SlotID]
+		playSlots = append(playSlots, state.SlotAssignment{
+			SlotID:      res.SlotID,
+			UserID:      res.UserID,
+			Name:        rec.Name,
+			Personality: rec.Personality,
+		})
+	}
+	a.beginPlaySession(startTime, playSlots)
+	if err := a.hub.AdvanceMatch(hub.MatchRunning, "api"); err != nil {
+		a.requestLogger(r).Warn("match_start_state", "err", err.Error())
+	}
+
+	a.hub.BroadcastMatchStart(targetSlots, countdown)
+
 	notified := false
 	if forceStart {
 		notified = a.hub.NotifyGameStart(targetSlots, true, connectedPlayers)
 	}
 
-	a.respondJSON(w, http.StatusOK, map[string]any{
-		"gameId":    a.cfg.GameID,
-		"marked":    results,
-		"count":     len(results),
-		"slots":     targetSlots,
-		"skipped":   skipped,
-		"connected": connectedPlayers,
-		"required":  requiredPlayers,
-		"forced":    forceStart,
-		"notified":  notified,
+	a.respondJSON(w, http.StatusOK, gameStartResponse{
+		GameID:    a.cfg.GameID,
+		StartTime: startTime.Format(time.RFC3339),
+		Countdown: req.Countdown,
+		Marked:    results,
+		Count:     len(results),
+		Slots:     targetSlots,
+		Skipped:   skipped,
+		Connected: connectedPlayers,
+		Required:  requiredPlayers,
+		Forced:    forceStart,
+		Notified:  notified,
 	})
 }
 
+// gameStartRequest is the optional body of POST /api/game/start.
+type gameStartRequest struct {
+	// Slots limits the match to these slots; by default every connected
+	// player takes part.
+	Slots []string `json:"slots,omitempty"`
+	// Countdown is the seconds the game and controllers count down
+	// before play begins, announced in the match_start frame.
+	Countdown int `json:"countdown,omitempty"`
+}
+
+// gameStartResponse reports the players whose visit was recorded.
+type gameStartResponse struct {
+	GameID    string        `json:"gameId"`
+	StartTime string        `json:"startTime,omitempty"`
+	Countdown int           `json:"countdown"`
+	Marked    []visitResult `json:"marked"`
+	Count     int           `json:"count"`
+	Slots     []string      `json:"slots"`
+	Skipped   []string      `json:"skipped"`
+	Message   string        `json:"message,omitempty"`
+	Connected int           `json:"connected"`
+	Required  int           `json:"required"`
+	Forced    bool          `json:"forced"`
+	Notified  bool          `json:"notified"`
+}
+
+type visitResult struct {
+	SlotID string `json:"slotId"`
+	UserID string `json:"userId"`
+}
+
 func (a *App) gameLobbyHandler(w http.ResponseWriter, r *http.Request) {
 	if a.persona == nil {
-		a.respondJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error": "persona integration disabled",
-		})
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
 		return
 	}
 
@@ -382,50 +523,50 @@ func (a *App) gameLobbyHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		lobby, err := a.persona.FetchLobby(r.Context())
 		if err != nil {
-			a.logger.Error("persona_lobby_fetch_failed", "err", err.Error())
-			a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to fetch lobby"})
+			a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+			a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
 			return
 		}
 		a.respondJSON(w, http.StatusOK, lobbyResponsePayload(lobby))
 
 	case http.MethodPost:
+		if !a.requireMatchState(w, r, hub.MatchIdle, hub.MatchRunning, hub.MatchFinished) {
+			return
+		}
 		if r.Body == nil {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "request body required"})
+			a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
 			return
 		}
 
-		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+		a.limitBody(w, r)
 		defer r.Body.Close()
 
-		var req struct {
-			GameID string             `json:"gameId"`
-			Lobby  map[string]*string `json:"lobby"`
-		}
+		var req lobbyUpdateRequest
 		decoder := json.NewDecoder(r.Body)
 		decoder.DisallowUnknownFields()
 		if err := decoder.Decode(&req); err != nil {
 			if errors.Is(err, io.EOF) {
-				a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "request body required"})
+				a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
 				return
 			}
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
 			return
 		}
 		if err := decoder.Decode(new(struct{})); err != io.EOF {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "unexpected trailing content"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
 			return
 		}
 
 		if len(req.Lobby) == 0 {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "lobby mapping required"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "lobby mapping required")
 			return
 		}
 
 		slots := make(map[int]string, len(req.Lobby))
 		for key, value := range req.Lobby {
-			_, slotNum, ok := normalizeSlotID("p" + key)
+			_, slotNum, ok := hub.NormalizeSlotID("p" + key)
 			if !ok {
-				a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid slot key: " + key})
+				a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid slot key: "+key)
 				return
 			}
 			if value == nil {
@@ -436,18 +577,21 @@ func (a *App) gameLobbyHandler(w http.ResponseWriter, r *http.Request) {
 
 		lobby, err := a.persona.UpdateLobby(r.Context(), slots)
 		if err != nil {
-			a.logger.Error("persona_lobby_update_failed", "err", err.Error())
-			a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to update lobby"})
+			a.requestLogger(r).Error("persona_lobby_update_failed", "err", err.Error())
+			a.respondError(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to update lobby")
 			return
 		}
 
 		a.respondJSON(w, http.StatusOK, lobbyResponsePayload(lobby))
 
 	case http.MethodDelete:
+		if !a.requireMatchState(w, r, hub.MatchIdle, hub.MatchRunning, hub.MatchFinished) {
+			return
+		}
 		lobby, err := a.persona.ClearLobby(r.Context())
 		if err != nil {
-			a.logger.Error("persona_lobby_delete_failed", "err", err.Error())
-			a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to clear lobby"})
+			a.requestLogger(r).Error("persona_lobby_delete_failed", "err", err.Error())
+			a.respondError(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to clear lobby")
 			return
 		}
 		a.respondJSON(w, http.StatusOK, lobbyResponsePayload(lobby))
@@ -466,52 +610,52 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if a.persona == nil {
-		a.respondJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error": "persona integration disabled",
-		})
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
 		return
 	}
 
 	if r.Body == nil {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "request body required"})
+		a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	a.limitBody(w, r)
 	defer r.Body.Close()
 
-	var req struct {
-		StartTime string `json:"startTime"`
-		Results   []struct {
-			SlotID string `json:"slotId"`
-			UserID string `json:"userId"`
-			Score  int    `json:"score"`
-			Name   string `json:"name"`
-		} `json:"results"`
-	}
+	var req gameResultRequest
 
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&req); err != nil {
 		if errors.Is(err, io.EOF) {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "request body required"})
+			a.respondError(w, http.StatusBadRequest, errCodeBodyRequired, "request body required")
 			return
 		}
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON payload")
 		return
 	}
 	if err := decoder.Decode(new(struct{})); err != io.EOF {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "unexpected trailing content"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "unexpected trailing content")
 		return
 	}
 
 	if len(req.Results) == 0 {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "results array required"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "results array required")
+		return
+	}
+	if !a.requireMatchState(w, r, hub.MatchRunning, hub.MatchFinished) {
 		return
 	}
 
+	play := a.currentPlaySession()
+
 	assignments := a.hub.ControllerAssignments()
 	index := make(map[string]hub.ControllerAssignment, len(assignments))
+	if play != nil {
+		for _, rec := range playAssignments(play) {
+			index[strings.ToLower(strings.TrimSpace(rec.SlotID))] = rec
+		}
+	}
 	for _, rec := range assignments {
 		slot := strings.ToLower(strings.TrimSpace(rec.SlotID))
 		if slot == "" {
@@ -522,21 +666,22 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 
 	submissions := make([]persona.GameResult, 0, len(req.Results))
 	seen := make(map[int]string, len(req.Results))
+	droppedMetadata := 0
 
 	for _, entry := range req.Results {
 		slotRaw := strings.TrimSpace(entry.SlotID)
 		if slotRaw == "" {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "slotId is required"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId is required")
 			return
 		}
 
-		slotKey, slotNum, ok := normalizeSlotID(slotRaw)
+		slotKey, slotNum, ok := hub.NormalizeSlotID(slotRaw)
 		if !ok {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid slotId: " + slotRaw})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid slotId: "+slotRaw)
 			return
 		}
 		if _, exists := seen[slotNum]; exists {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "duplicate slotId: " + slotKey})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "duplicate slotId: "+slotKey)
 			return
 		}
 		seen[slotNum] = slotKey
@@ -544,7 +689,7 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 		assign, assignExists := index[slotKey]
 
 		if entry.Score < 0 {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "score must be non-negative"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "score must be non-negative")
 			return
 		}
 
@@ -553,7 +698,7 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 
 		if userID == "" {
 			if !assignExists || strings.TrimSpace(assign.UserID) == "" {
-				a.respondJSON(w, http.StatusNotFound, map[string]string{"error": "slot not assigned to user: " + slotKey})
+				a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotAssigned, "slot not assigned to user: "+slotKey, map[string]any{"slotId": slotKey})
 				return
 			}
 			userID = strings.TrimSpace(assign.UserID)
@@ -566,30 +711,57 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 			name = userID
 		}
 
+		if err := validateResultMetadata(entry.Metadata); err != nil {
+			a.respondErrorDetails(w, http.StatusBadRequest, errCodeInvalidRequest, slotKey+": "+err.Error(), map[string]any{"slotId": slotKey})
+			return
+		}
+		metadata := entry.Metadata
+		if len(metadata) > 0 && !a.persona.SupportsResultMetadata() {
+			droppedMetadata++
+			metadata = nil
+		}
+
 		submissions = append(submissions, persona.GameResult{
-			Slot:   slotNum,
-			UserID: userID,
-			Name:   name,
-			Score:  entry.Score,
+			Slot:     slotNum,
+			UserID:   userID,
+			Name:     name,
+			Score:    entry.Score,
+			Metadata: metadata,
 		})
 	}
 
+	if droppedMetadata > 0 {
+		a.requestLogger(r).Warn("result_metadata_dropped", "slots", droppedMetadata, "db_api_version", a.cfg.DBAPIVersion)
+	}
+
 	if len(submissions) == 0 {
-		a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "no valid results provided"})
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "no valid results provided")
 		return
 	}
 
-	startTime := time.Now().UTC()
+	// Prefer the game's own startTime, then the start the hub tracked from
+	// /api/game/start or the match_start message.
+	startTime := a.hub.MatchStartedAt()
+	startSource := "hub"
 	if raw := strings.TrimSpace(req.StartTime); raw != "" {
 		parsed, err := time.Parse(time.RFC3339, raw)
 		if err != nil {
-			a.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid startTime"})
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid startTime")
 			return
 		}
 		startTime = parsed
+		startSource = "request"
+	}
+	if startTime.IsZero() {
+		startTime = time.Now().UTC()
+		startSource = "now"
+		a.requestLogger(r).Warn("result_start_time_unknown", "start_time", startTime.Format(time.RFC3339))
 	}
 
-	resp, err := a.persona.SubmitGameResult(r.Context(), startTime, submissions)
+	// The id doubles as the idempotency key, so a submission that reached
+	// PersonaGo but timed out is not recorded twice when the queue retries.
+	resultID := newDeliveryID()
+	resp, err := a.persona.SubmitGameResult(r.Context(), startTime, submissions, resultID)
 	if err != nil {
 		var apiErr *persona.APIError
 		if errors.As(err, &apiErr) {
@@ -602,58 +774,234 @@ func (a *App) gameResultHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			a.logErrorWithStack("persona_result_failed", "err", err.Error())
 		}
-		a.respondJSON(w, http.StatusBadGateway, map[string]string{"error": "failed to submit game results"})
+		if !retryableResultError(err) {
+			a.respondError(w, http.StatusBadGateway, errCodePersonaUpstream, "failed to submit game results")
+			return
+		}
+
+		// The scores are safe in the queue, so the match is over as far as
+		// the game is concerned.
+		entry := a.queueResult(resultID, startTime, submissions, err)
+		a.recordMatch(startTime, submissions, true)
+		a.resultAccepted(play != nil)
+		a.respondJSON(w, http.StatusAccepted, gameResultResponse{
+			GameID:          a.cfg.GameID,
+			Queued:          true,
+			QueueID:         entry.ID,
+			NextAttemptAt:   entry.NextAttempt.Format(time.RFC3339),
+			Submitted:       len(submissions),
+			StartTime:       startTime.UTC().Format(time.RFC3339),
+			StartTimeSource: startSource,
+		})
 		return
 	}
 
-	a.respondJSON(w, http.StatusOK, map[string]any{
-		"gameId":    resp.GameID,
-		"playId":    resp.PlayID,
-		"submitted": len(submissions),
-		"startTime": startTime.UTC().Format(time.RFC3339),
+	a.recordMatch(startTime, submissions, false)
+	a.resultAccepted(play != nil)
+	a.hub.PublishEvent("result_submitted",
+		"playId", resp.PlayID,
+		"startTime", startTime.UTC().Format(time.RFC3339),
+		"submitted", len(submissions),
+	)
+
+	a.respondJSON(w, http.StatusOK, gameResultResponse{
+		GameID:          resp.GameID,
+		PlayID:          resp.PlayID,
+		Submitted:       len(submissions),
+		StartTime:       startTime.UTC().Format(time.RFC3339),
+		StartTimeSource: startSource,
 	})
 }
 
-func normalizeSlotID(raw string) (string, int, bool) {
-	slot := strings.ToLower(strings.TrimSpace(raw))
-	if slot == "" {
-		return "", 0, false
+// gameResultRequest is the body of POST /api/game/result.
+type gameResultRequest struct {
+	// StartTime is the RFC 3339 start of the match; the hub's own record
+	// of the start is used when it is empty.
+	StartTime string             `json:"startTime,omitempty"`
+	Results   []gameResultSubmit `json:"results"`
+}
+
+type gameResultSubmit struct {
+	SlotID   string         `json:"slotId"`
+	UserID   string         `json:"userId,omitempty"`
+	Score    int            `json:"score"`
+	Name     string         `json:"name,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// gameResultResponse reports a submitted match, or one queued for retry
+// when PersonaGo was unreachable (202 with Queued set).
+type gameResultResponse struct {
+	GameID          string `json:"gameId"`
+	PlayID          int    `json:"playId,omitempty"`
+	Queued          bool   `json:"queued,omitempty"`
+	QueueID         string `json:"queueId,omitempty"`
+	NextAttemptAt   string `json:"nextAttemptAt,omitempty"`
+	Submitted       int    `json:"submitted"`
+	StartTime       string `json:"startTime"`
+	StartTimeSource string `json:"startTimeSource"`
+}
+
+func (a *App) gameTimerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, elapsed, running := a.hub.MatchTimer()
+	resp := map[string]any{
+		"running":    running,
+		"startTime":  nil,
+		"elapsedMs":  elapsed.Milliseconds(),
+		"serverTime": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if running {
+		resp["startTime"] = start.Format(time.RFC3339Nano)
+	}
+	resp["resultOverdue"] = a.resultOverdue(elapsed, running)
+	a.respondJSON(w, http.StatusOK, resp)
+}
+
+// gameStatusHandler tells the operator whether the game client is attached
+// and how busy the relay to it is, without needing the admin listener.
+func (a *App) gameStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := a.hub.Status()
+	game := map[string]any{
+		"connected":   status.GameConnected,
+		"remoteIp":    status.GameRemoteIP,
+		"connectedAt": nil,
+		"mirrors":     status.Mirrors,
+	}
+	for _, session := range a.hub.Sessions() {
+		if session.Role == "game" && !session.Mirror {
+			game["connectedAt"] = session.ConnectedAt.UTC().Format(time.RFC3339)
+			game["uptimeMs"] = time.Since(session.ConnectedAt).Milliseconds()
+			break
+		}
+	}
+
+	start, elapsed, running := a.hub.MatchTimer()
+	match := map[string]any{
+		"state":     a.hub.Match().State,
+		"running":   running,
+		"startTime": nil,
+		"elapsedMs": elapsed.Milliseconds(),
+	}
+	if running {
+		match["startTime"] = start.UTC().Format(time.RFC3339)
 	}
-	if strings.HasPrefix(slot, "p") {
-		slot = strings.TrimPrefix(slot, "p")
+
+	queue := a.hub.QueueStats()
+	controllers := map[string]int{
+		"connected": status.Controllers,
+		"capacity":  status.MaxControllers,
+		"assigned":  0,
+		"ready":     0,
 	}
-	num, err := strconv.Atoi(slot)
-	if err != nil || num < 1 || num > 4 {
-		return "", 0, false
+	for _, assignment := range a.hub.ControllerAssignments() {
+		if assignment.UserID != "" {
+			controllers["assigned"]++
+		}
+		if assignment.Connected && assignment.Tutorial == hub.TutorialReady {
+			controllers["ready"]++
+		}
 	}
-	return "p" + strconv.Itoa(num), num, true
+
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"gameId":      a.cfg.GameID,
+		"game":        game,
+		"epoch":       status.Epoch,
+		"match":       match,
+		"relayQueue":  map[string]int{"depth": queue.Depth, "capacity": queue.Capacity},
+		"controllers": controllers,
+		"serverTime":  time.Now().UTC().Format(time.RFC3339Nano),
+	})
 }
 
-func lobbyResponsePayload(lobby *persona.Lobby) map[string]any {
-	gameID := ""
-	if lobby != nil {
-		gameID = lobby.GameID
+const (
+	maxResultMetadataKeys   = 16
+	maxResultMetadataKeyLen = 32
+)
+
+// validateResultMetadata bounds the per-slot metadata map. Values are passed
+// through to PersonaGo untouched.
+func validateResultMetadata(metadata map[string]any) error {
+	if len(metadata) > maxResultMetadataKeys {
+		return fmt.Errorf("metadata allows at most %d keys", maxResultMetadataKeys)
+	}
+	for key := range metadata {
+		if strings.TrimSpace(key) == "" || len(key) > maxResultMetadataKeyLen {
+			return fmt.Errorf("invalid metadata key %q", key)
+		}
 	}
+	return nil
+}
+
+// lobbyUpdateRequest is the body of POST /api/game/lobby: user ids keyed by
+// slot number "1"-"4", where null leaves the slot as it is.
+type lobbyUpdateRequest struct {
+	GameID string             `json:"gameId,omitempty"`
+	Lobby  map[string]*string `json:"lobby"`
+}
+
+// lobbyResponse is the PersonaGo lobby with every slot "1"-"4" present and
+// null when empty.
+type lobbyResponse struct {
+	GameID string                  `json:"gameId"`
+	Lobby  map[string]*sessionUser `json:"lobby"`
+}
 
-	response := map[string]any{
-		"gameId": gameID,
-		"lobby":  map[string]any{"1": nil, "2": nil, "3": nil, "4": nil},
+// controllerLobbyHandler serves the read-only lobby the controller page
+// picks a player from. It is open like /api/controller/session, so it only
+// names the players: no personality and no way to change the lobby.
+func (a *App) controllerLobbyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.persona == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+		return
 	}
+	lobby, err := a.persona.FetchLobby(r.Context())
+	if err != nil {
+		a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+		a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
+		return
+	}
+	response := lobbyResponsePayload(lobby)
+	for _, user := range response.Lobby {
+		if user != nil {
+			user.Personality = ""
+		}
+	}
+	a.respondJSON(w, http.StatusOK, response)
+}
 
+func lobbyResponsePayload(lobby *persona.Lobby) lobbyResponse {
+	response := lobbyResponse{
+		Lobby: map[string]*sessionUser{"1": nil, "2": nil, "3": nil, "4": nil},
+	}
 	if lobby == nil {
 		return response
 	}
 
-	payloadLobby := response["lobby"].(map[string]any)
+	response.GameID = lobby.GameID
 	for _, slot := range lobby.Slots {
-		entry := map[string]string{
-			"id":          slot.UserID,
-			"name":        slot.Name,
-			"personality": slot.Personality,
+		response.Lobby[strconv.Itoa(slot.Index)] = &sessionUser{
+			ID:          slot.UserID,
+			Name:        slot.Name,
+			Personality: slot.Personality,
 		}
-		payloadLobby[strconv.Itoa(slot.Index)] = entry
 	}
-
 	return response
 }
 
@@ -675,18 +1023,23 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`{"ok":true}`))
 }
 
-func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+func (a *App) loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		r, logger := withRequestID(w, r, logger, requestID(r))
 		lrw := &responseLogger{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(lrw, r)
 		duration := time.Since(start)
-		logger.Info("http_request",
+		level := slog.LevelInfo
+		if r.UserAgent() == watchdogUserAgent {
+			level = slog.LevelDebug
+		}
+		logger.Log(r.Context(), level, "http_request",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", lrw.status,
 			"duration_ms", duration.Milliseconds(),
-			"remote_ip", requestIP(r),
+			"remote_ip", a.requestIP(r),
 		)
 	})
 }
@@ -701,6 +1054,12 @@ func (r *responseLogger) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController so streaming
+// handlers can flush through the logging middleware.
+func (r *responseLogger) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func (r *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hj, ok := r.ResponseWriter.(http.Hijacker)
 	if !ok {
@@ -709,18 +1068,8 @@ func (r *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hj.Hijack()
 }
 
-func requestIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		for _, part := range strings.Split(xff, ",") {
-			candidate := strings.TrimSpace(part)
-			if candidate != "" {
-				return candidate
-			}
-		}
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
+// requestIP is the client address of r: the peer, or the X-Forwarded-For
+// hop a trusted proxy reported.
+func (a *App) requestIP(r *http.Request) string {
+	return hub.ClientIP(r, a.trustedProxies)
 }
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// controllerSessionsBatchHandler issues controller tokens for every
+// occupied lobby slot from a single Persona lobby fetch, so a kiosk hands
+// out a whole group's controllers in one call. Sessions are keyed by slot
+// and use the /api/controller/session shape; slots whose token could not be
+// issued, such as a banned user's, are listed under "skipped" instead of
+// failing the batch.
+func (a *App) controllerSessionsBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if a.persona == nil {
+		a.respondError(w, http.StatusServiceUnavailable, errCodePersonaDisabled, "persona integration disabled")
+		return
+	}
+
+	var req sessionsBatchRequest
+	if !a.decodeJSONBody(w, r, &req) {
+		return
+	}
+	var only map[string]bool
+	if len(req.Slots) > 0 {
+		only = make(map[string]bool, len(req.Slots))
+		for _, raw := range req.Slots {
+			slotID, _, ok := hub.NormalizeSlotID(raw)
+			if !ok {
+				a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid slot key: "+raw)
+				return
+			}
+			only[slotID] = true
+		}
+	}
+
+	cohort := strings.TrimSpace(req.Cohort)
+	if err := a.hub.CheckCohort(cohort); err != nil {
+		a.respondError(w, http.StatusBadRequest, errCodeUnknownCohort, "unknown cohort")
+		return
+	}
+
+	lobby, err := a.persona.FetchLobby(r.Context())
+	if err != nil {
+		a.requestLogger(r).Error("persona_lobby_fetch_failed", "err", err.Error())
+		a.respondError(w, http.StatusBadGateway, errCodeLobbyUnavailable, "failed to fetch lobby")
+		return
+	}
+
+	sessions := make(map[string]controllerSessionResponse, len(lobby.Slots))
+	skipped := make([]apiError, 0)
+	for _, slot := range lobby.Slots {
+		if slot.UserID == "" || (only != nil && !only[slot.SlotID]) {
+			continue
+		}
+		token, expiresAt, err := a.hub.IssueControllerToken(slot.SlotID, slot.UserID, slot.Name, slot.Personality, cohort, a.cfg.SessionTokenTTL)
+		switch {
+		case errors.Is(err, hub.ErrBanned):
+			a.requestLogger(r).Warn("token_issue_refused", "slot", slot.SlotID, "user_id", slot.UserID, "err", err.Error())
+			skipped = append(skipped, apiError{Code: errCodeUserBanned, Message: "user is banned", Details: map[string]any{"slotId": slot.SlotID}})
+		case err != nil:
+			a.logErrorWithStack("token_issue_failed", "slot", slot.SlotID, "user_id", slot.UserID, "err", err.Error())
+			skipped = append(skipped, apiError{Code: errCodeInternal, Message: "failed to issue controller token", Details: map[string]any{"slotId": slot.SlotID}})
+		default:
+			sessions[slot.SlotID] = a.sessionResponse(slot, cohort, token, expiresAt)
+		}
+	}
+
+	a.requestLogger(r).Info("controller_sessions_batch_issued", "issued", len(sessions), "skipped", len(skipped))
+	a.respondJSON(w, http.StatusCreated, sessionsBatchResponse{
+		GameID:   a.cfg.GameID,
+		Sessions: sessions,
+		Skipped:  skipped,
+		Count:    len(sessions),
+	})
+}
+
+// sessionsBatchRequest is the body of POST /api/controller/sessions/batch.
+type sessionsBatchRequest struct {
+	Cohort string `json:"cohort,omitempty"`
+	// Slots limits issuing to these slots; by default every occupied slot
+	// gets a token.
+	Slots []string `json:"slots,omitempty"`
+}
+
+// sessionsBatchResponse holds the tokens issued by slot id and the slots
+// that were refused.
+type sessionsBatchResponse struct {
+	GameID   string                               `json:"gameId"`
+	Sessions map[string]controllerSessionResponse `json:"sessions"`
+	Skipped  []apiError                           `json:"skipped"`
+	Count    int                                  `json:"count"`
+}
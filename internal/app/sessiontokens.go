@@ -0,0 +1,88 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const controllerSessionPrefix = "/api/controller/session/"
+
+// controllerSessionsHandler lists the outstanding controller tokens with
+// their expiry so staff can spot a token handed to the wrong player. Token
+// values are never returned.
+func (a *App) controllerSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	tokens := a.hub.ControllerTokens()
+	sessions := make([]map[string]any, 0, len(tokens))
+	for _, token := range tokens {
+		session := map[string]any{
+			"slotId":      token.SlotID,
+			"userId":      token.UserID,
+			"name":        token.Name,
+			"connected":   token.Connected,
+			"expiresAt":   token.ExpiresAt.UTC().Format(time.RFC3339),
+			"expiresInMs": token.ExpiresAt.Sub(now).Milliseconds(),
+		}
+		if token.Cohort != "" {
+			session["cohort"] = token.Cohort
+		}
+		sessions = append(sessions, session)
+	}
+
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"gameId":     a.cfg.GameID,
+		"sessions":   sessions,
+		"count":      len(sessions),
+		"serverTime": now.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// controllerSessionRevokeHandler serves DELETE /api/controller/session/{slotId}.
+// It withdraws the slot's token before its TTL runs out; with
+// ?disconnect=true the live controller is closed as well, otherwise it stays
+// connected but cannot refresh.
+func (a *App) controllerSessionRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	slotID := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, controllerSessionPrefix)))
+	if slotID == "" || strings.Contains(slotID, "/") {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slot id is required in the path")
+		return
+	}
+	disconnect := r.URL.Query().Get("disconnect") == "true"
+
+	revoked := a.hub.RevokeSlotToken(slotID)
+	disconnected := 0
+	if disconnect {
+		reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+		if reason == "" {
+			reason = "token_revoked"
+		}
+		disconnected = a.hub.DisconnectControllers(r.Context(), []string{slotID}, reason, false)
+	}
+	if !revoked && disconnected == 0 {
+		a.respondErrorDetails(w, http.StatusNotFound, errCodeSlotNotFound, "no token for slot "+slotID, map[string]any{"slotId": slotID})
+		return
+	}
+
+	a.requestLogger(r).Info("controller_token_revoked", "slot", slotID, "revoked", revoked, "disconnected", disconnected)
+	if revoked {
+		a.hub.PublishEvent("token_revoked", "slotId", slotID)
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"slotId":       slotID,
+		"revoked":      revoked,
+		"disconnected": disconnected > 0,
+	})
+}
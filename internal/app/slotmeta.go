@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+)
+
+// adminSlotMetaHandler manages operator-set slot metadata such as colour,
+// station number or physical position. POST replaces a slot's metadata; the
+// hub pushes it to the connected controller and game and includes it in
+// register acks and the assignments feed.
+func (a *App) adminSlotMetaHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.respondJSON(w, http.StatusOK, map[string]any{"slots": a.hub.SlotMetadata()})
+
+	case http.MethodPost:
+		var req slotMetaRequest
+		if !a.decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		slotID := strings.ToLower(strings.TrimSpace(req.SlotID))
+		if slotID == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId is required")
+			return
+		}
+		if req.Meta == nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "meta is required; use DELETE to clear a slot")
+			return
+		}
+		if err := a.hub.SetSlotMetadata(slotID, req.Meta); err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		a.requestLogger(r).Info("admin_slot_meta_set", "slot", slotID, "meta", req.Meta)
+		a.respondJSON(w, http.StatusOK, map[string]any{"slotId": slotID, "meta": req.Meta})
+
+	case http.MethodDelete:
+		slotID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("slotId")))
+		if slotID == "" {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "slotId query parameter is required")
+			return
+		}
+		if _, ok := a.hub.SlotMetadata()[slotID]; !ok {
+			a.respondError(w, http.StatusNotFound, errCodeNotFound, "no metadata for "+slotID)
+			return
+		}
+		if err := a.hub.SetSlotMetadata(slotID, nil); err != nil {
+			a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		a.requestLogger(r).Info("admin_slot_meta_cleared", "slot", slotID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// slotMetaRequest is the body of POST /api/admin/slots/meta.
+type slotMetaRequest struct {
+	SlotID string            `json:"slotId"`
+	Meta   map[string]string `json:"meta"`
+}
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// ErrSmokeTest is returned by SmokeTest when at least one check failed.
+var ErrSmokeTest = errors.New("smoke test failed")
+
+const (
+	// smokeTestTimeout bounds each smoke test check.
+	smokeTestTimeout = 5 * time.Second
+	// smokeTestSlot is the slot the synthetic controller registers into.
+	smokeTestSlot = "p1"
+	smokeTestUser = "smoke-test"
+)
+
+// SmokeTest serves the app on the address in the config, which cmd/hub sets
+// to an ephemeral loopback port, and checks it end to end: /healthz answers,
+// a game registers, a controller registers with a freshly issued token and
+// its input reaches the game, and, when DB_BASE_URL is set, PersonaGo
+// returns the lobby. Each check is reported on out as PASS, FAIL or SKIP.
+func (a *App) SmokeTest(ctx context.Context, out io.Writer) error {
+	ln, err := a.listen(a.server.Addr, "-addr/ADDR")
+	if err != nil {
+		return err
+	}
+	serveErr := make(chan error, 1)
+	crash.Go(func() {
+		if a.tlsEnabled() {
+			serveErr <- a.server.ServeTLS(ln, a.cfg.TLSCertFile, a.cfg.TLSKeyFile)
+			return
+		}
+		serveErr <- a.server.Serve(ln)
+	})
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+		defer cancel()
+		a.hub.Shutdown(shutdownCtx)
+		_ = a.server.Shutdown(shutdownCtx)
+		<-serveErr
+	}()
+
+	base := watchdogBaseURL(ln.Addr(), a.tlsEnabled())
+	client := a.selfClient()
+	fmt.Fprintf(out, "smoke test on %s\n", base)
+
+	failed := 0
+	for _, check := range []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"http", func(ctx context.Context) error { return probeHealthz(ctx, client, base) }},
+		{"register", func(ctx context.Context) error { return a.smokeRegister(ctx, client, base) }},
+		{"persona", a.smokePersona},
+	} {
+		checkCtx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+		err := check.run(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var skip smokeSkip
+		switch {
+		case errors.As(err, &skip):
+			fmt.Fprintf(out, "SKIP %s: %s\n", check.name, string(skip))
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", check.name, err)
+		default:
+			fmt.Fprintf(out, "PASS %s\n", check.name)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "%d checks failed\n", failed)
+		return ErrSmokeTest
+	}
+	fmt.Fprintln(out, "smoke test passed")
+	return nil
+}
+
+// smokeSkip marks a check that does not apply to this configuration.
+type smokeSkip string
+
+func (s smokeSkip) Error() string { return string(s) }
+
+// smokeRegister registers both roles over /ws: the game must get its
+// snapshot, see the controller join and receive the controller's input.
+func (a *App) smokeRegister(ctx context.Context, client *http.Client, base string) error {
+	token, _, err := a.hub.IssueControllerToken(smokeTestSlot, smokeTestUser, smokeTestUser, "", "", smokeTestTimeout)
+	if err != nil {
+		return fmt.Errorf("issue controller token: %w", err)
+	}
+	defer a.hub.RevokeSlotToken(smokeTestSlot)
+
+	url := "ws" + strings.TrimPrefix(base, "http") + "/ws"
+	game, err := smokeDial(ctx, client, url, map[string]any{
+		"role":            "game",
+		"token":           a.cfg.GameToken,
+		"protocolVersion": hub.ProtocolVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("game: %w", err)
+	}
+	defer game.CloseNow()
+	if err := smokeExpect(ctx, game, "state"); err != nil {
+		return fmt.Errorf("game snapshot: %w", err)
+	}
+
+	controller, err := smokeDial(ctx, client, url, map[string]any{
+		"role":            "controller",
+		"token":           token,
+		"protocolVersion": hub.ProtocolVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("controller: %w", err)
+	}
+	defer controller.CloseNow()
+	if err := smokeExpect(ctx, game, "controller_joined"); err != nil {
+		return fmt.Errorf("controller join: %w", err)
+	}
+
+	if err := controller.Write(ctx, websocket.MessageText, []byte(`{"type":"input","btn":{"a":true}}`)); err != nil {
+		return fmt.Errorf("controller input: %w", err)
+	}
+	if err := a.smokeExpectRelayed(ctx, game, "input"); err != nil {
+		return fmt.Errorf("relay input: %w", err)
+	}
+
+	controller.Close(websocket.StatusNormalClosure, "")
+	game.Close(websocket.StatusNormalClosure, "")
+	return nil
+}
+
+func (a *App) smokePersona(ctx context.Context) error {
+	if a.persona == nil {
+		return smokeSkip("DB_BASE_URL is not set")
+	}
+	_, err := a.persona.FetchLobby(ctx)
+	return err
+}
+
+func smokeDial(ctx context.Context, client *http.Client, url string, register map[string]any) (*websocket.Conn, error) {
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPClient: client})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(register)
+	if err != nil {
+		conn.CloseNow()
+		return nil, err
+	}
+	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+		conn.CloseNow()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// smokeExpect reads conn until a message of msgType arrives, skipping
+// others.
+func smokeExpect(ctx context.Context, conn *websocket.Conn, msgType string) error {
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if smokeMessageType(data) == msgType {
+			return nil
+		}
+	}
+}
+
+// smokeExpectRelayed is smokeExpect for a frame the smoke test controller
+// sent, unwrapped from the framing the game is configured for: behind the
+// slot header in passthrough mode or inside the relay envelope.
+func (a *App) smokeExpectRelayed(ctx context.Context, conn *websocket.Conn, msgType string) error {
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case a.cfg.Passthrough:
+			slot, payload, ok := bytes.Cut(data, []byte{'\n'})
+			if !ok || string(slot) != smokeTestSlot {
+				continue
+			}
+			data = payload
+		case a.cfg.RelayEnvelope:
+			var envelope struct {
+				Type    string          `json:"type"`
+				SlotID  string          `json:"slotId"`
+				Payload json.RawMessage `json:"payload"`
+			}
+			if json.Unmarshal(data, &envelope) != nil || envelope.Type != "relay" || envelope.SlotID != smokeTestSlot {
+				continue
+			}
+			data = envelope.Payload
+		}
+		if smokeMessageType(data) == msgType {
+			return nil
+		}
+	}
+}
+
+func smokeMessageType(data []byte) string {
+	var msg struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(data, &msg) != nil {
+		return ""
+	}
+	return msg.Type
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http"
+	"time"
+)
+
+// adminSoftLimitsHandler lists every hard limit with its warning threshold,
+// so operators can see how close the hub runs to dropping traffic.
+func (a *App) adminSoftLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limits := a.hub.SoftLimits()
+	out := make([]map[string]any, 0, len(limits))
+	for _, limit := range limits {
+		entry := map[string]any{
+			"name":     limit.Name,
+			"hard":     limit.Hard,
+			"soft":     limit.Soft,
+			"current":  limit.Current,
+			"peak":     limit.Peak,
+			"over":     limit.Over,
+			"warnings": limit.Warnings,
+		}
+		if !limit.LastWarn.IsZero() {
+			entry["lastWarning"] = limit.LastWarn.UTC().Format(time.RFC3339)
+		}
+		out = append(out, entry)
+	}
+	a.respondJSON(w, http.StatusOK, map[string]any{"limits": out})
+}
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// assetHashLen is the number of hex digits of the content hash used in
+	// ETags and hashed file names.
+	assetHashLen = 12
+	// immutableCacheControl lets browsers keep a hashed file name for a
+	// year without asking; new content gets a new name.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+// spaReserved are the path prefixes that keep their 404s under SPA_FALLBACK,
+// so a mistyped API call or socket path is not answered with a page.
+var spaReserved = []string{"/api", "/ws", "/socket.io", "/debug"}
+
+// assetRef matches the src and href attributes STATIC_HASHED_NAMES rewrites.
+var assetRef = regexp.MustCompile(`\b(src|href)="([^"]+)"`)
+
+// staticFiles serves the embedded pages and assets (or their
+// STATIC_OVERLAY_DIR replacements) with content-hash ETags, so a phone that
+// reconnects revalidates with a 304 instead of downloading the page again.
+// With STATIC_HASHED_NAMES, pages link assets as name.<hash>.ext, which are
+// cached for good and never revalidated.
+type staticFiles struct {
+	fs     http.FileSystem
+	hashed bool
+	maxAge time.Duration
+	// spa are the SPA_FALLBACK prefixes whose unknown paths get the page of
+	// the single-page app instead of a 404.
+	spa []string
+	// dirs handles directory requests, which keep http.FileServer's
+	// redirects and listings.
+	dirs http.Handler
+
+	mu      sync.Mutex
+	entries map[string]*staticEntry
+}
+
+// staticEntry is a file read into memory; page holds its copy with hashed
+// asset links once it has been served as a page.
+type staticEntry struct {
+	modTime time.Time
+	size    int64
+	body    []byte
+	hash    string
+	page    *staticEntry
+	// assets are the hashes a rewritten page links, by file name.
+	assets map[string]string
+}
+
+func newStaticFiles(fs http.FileSystem, hashed bool, maxAge time.Duration, spa []string) *staticFiles {
+	return &staticFiles{
+		fs:      fs,
+		hashed:  hashed,
+		maxAge:  maxAge,
+		spa:     spa,
+		dirs:    http.FileServer(fs),
+		entries: make(map[string]*staticEntry),
+	}
+}
+
+// ServeHTTP serves the asset at the request path, resolving hashed names.
+func (s *staticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if strings.HasSuffix(r.URL.Path, "/") || name == "" || path.Base(name) == "index.html" {
+		if _, err := s.load(name); err != nil {
+			if page, ok := s.fallback(r); ok {
+				s.serveFile(w, r, page)
+				return
+			}
+		}
+		s.dirs.ServeHTTP(w, r)
+		return
+	}
+	entry, err := s.load(name)
+	if err == nil && entry == nil {
+		s.dirs.ServeHTTP(w, r)
+		return
+	}
+	if err != nil && s.hashed {
+		if original, hash, ok := unhashName(name); ok {
+			if entry, err = s.load(original); err == nil && entry != nil {
+				// A stale hash still gets the current file, but only until
+				// the page naming it is reloaded.
+				cacheControl := "no-cache"
+				if entry.hash == hash {
+					cacheControl = immutableCacheControl
+				}
+				w.Header().Set("Cache-Control", cacheControl)
+				s.serveEntry(w, r, original, entry)
+				return
+			}
+		}
+	}
+	if err != nil {
+		if page, ok := s.fallback(r); ok {
+			s.serveFile(w, r, page)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	s.serveEntry(w, r, name, entry)
+}
+
+// fallback picks the page a missing path under an SPA_FALLBACK prefix is
+// answered with: the prefix's own index.html if it has one, else the root
+// one. Paths that look like files keep their 404, as a missing script
+// should not turn into HTML.
+func (s *staticFiles) fallback(r *http.Request) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+	p := path.Clean("/" + r.URL.Path)
+	if path.Ext(p) != "" {
+		return "", false
+	}
+	for _, reserved := range spaReserved {
+		if underPrefix(p, reserved) {
+			return "", false
+		}
+	}
+	for _, prefix := range s.spa {
+		if !underPrefix(p, prefix) {
+			continue
+		}
+		page := strings.TrimPrefix(path.Join(prefix, "index.html"), "/")
+		if entry, err := s.load(page); err != nil || entry == nil {
+			page = "index.html"
+		}
+		return page, true
+	}
+	return "", false
+}
+
+// underPrefix reports whether p is prefix or below it.
+func underPrefix(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// assetName is the file a request path names, with any content hash
+// removed.
+func (s *staticFiles) assetName(urlPath string) string {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if s.hashed {
+		if original, _, ok := unhashName(name); ok {
+			if _, err := s.load(name); err != nil {
+				return original
+			}
+		}
+	}
+	return name
+}
+
+// serveFile serves the named file whatever the request path, as the entry
+// pages are.
+func (s *staticFiles) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	entry, err := s.load(name)
+	if err != nil || entry == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveEntry(w, r, name, entry)
+}
+
+func (s *staticFiles) serveEntry(w http.ResponseWriter, r *http.Request, name string, entry *staticEntry) {
+	h := w.Header()
+	if strings.HasSuffix(name, ".html") {
+		if s.hashed {
+			entry = s.page(entry, path.Dir(name))
+		}
+		// Pages name the assets, so they are always revalidated.
+		h.Set("Cache-Control", "no-cache")
+	} else if h.Get("Cache-Control") == "" {
+		if s.maxAge > 0 {
+			h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.maxAge/time.Second)))
+		} else {
+			h.Set("Cache-Control", "no-cache")
+		}
+	}
+	h.Set("ETag", `"`+entry.hash+`"`)
+	http.ServeContent(w, r, path.Base(name), entry.modTime, bytes.NewReader(entry.body))
+}
+
+// load returns the file, read and hashed once per modification; nil
+// without an error for a directory.
+func (s *staticFiles) load(name string) (*staticEntry, error) {
+	name = strings.TrimPrefix(name, "/")
+	file, err := s.fs.Open("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	entry := s.entries[name]
+	s.mu.Unlock()
+	if entry != nil && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry, nil
+	}
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	entry = &staticEntry{modTime: info.ModTime(), size: info.Size(), body: body, hash: contentHash(body)}
+	s.mu.Lock()
+	s.entries[name] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// page returns entry with the asset links in its src and href attributes
+// hashed, resolving relative links against dir, the directory of the file.
+// The request path is not used: under SPA_FALLBACK every client route would
+// get a copy of its own.
+func (s *staticFiles) page(entry *staticEntry, dir string) *staticEntry {
+	s.mu.Lock()
+	rewritten := entry.page
+	s.mu.Unlock()
+	if rewritten != nil && s.current(rewritten.assets) {
+		return rewritten
+	}
+	assets := make(map[string]string)
+	body := assetRef.ReplaceAllFunc(entry.body, func(match []byte) []byte {
+		parts := assetRef.FindSubmatch(match)
+		ref := string(parts[2])
+		if strings.ContainsAny(ref, ":?#") || strings.HasPrefix(ref, "//") || strings.HasSuffix(ref, "/") {
+			return match
+		}
+		target := ref
+		if !strings.HasPrefix(ref, "/") {
+			target = path.Join(dir, ref)
+		}
+		name := strings.TrimPrefix(path.Clean(target), "/")
+		asset, err := s.load(name)
+		if err != nil || asset == nil {
+			return match
+		}
+		assets[name] = asset.hash
+		return []byte(string(parts[1]) + `="` + hashName(ref, asset.hash) + `"`)
+	})
+	rewritten = &staticEntry{modTime: entry.modTime, size: int64(len(body)), body: body, hash: contentHash(body), assets: assets}
+	s.mu.Lock()
+	entry.page = rewritten
+	s.mu.Unlock()
+	return rewritten
+}
+
+// current reports whether the linked assets still have the given hashes,
+// so an overlay file replaced while the hub runs gets a new name.
+func (s *staticFiles) current(assets map[string]string) bool {
+	for name, hash := range assets {
+		if asset, err := s.load(name); err != nil || asset == nil || asset.hash != hash {
+			return false
+		}
+	}
+	return true
+}
+
+func contentHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])[:assetHashLen]
+}
+
+// hashName turns "dir/name.ext" into "dir/name.<hash>.ext".
+func hashName(name, hash string) string {
+	dir, file := path.Split(name)
+	if i := strings.LastIndex(file, "."); i > 0 {
+		return dir + file[:i] + "." + hash + file[i:]
+	}
+	return dir + file + "." + hash
+}
+
+// unhashName undoes hashName, reporting the hash it removed.
+func unhashName(name string) (original, hash string, ok bool) {
+	dir, file := path.Split(name)
+	parts := strings.Split(file, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if len(parts[i]) != assetHashLen {
+			continue
+		}
+		if _, err := hex.DecodeString(parts[i]); err != nil {
+			continue
+		}
+		rest := append(append([]string{}, parts[:i]...), parts[i+1:]...)
+		return dir + strings.Join(rest, "."), parts[i], true
+	}
+	return "", "", false
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticHashedPageUnderSPAFallback(t *testing.T) {
+	files := fstest.MapFS{
+		"app/index.html": {Data: []byte(`<script src="app.js"></script><link href="/style.css">`)},
+		"app/app.js":     {Data: []byte("console.log(1)")},
+		"style.css":      {Data: []byte("body{}")},
+	}
+	s := newStaticFiles(http.FS(files), true, 0, []string{"/app"})
+	js, _ := s.load("app/app.js")
+	css, _ := s.load("style.css")
+
+	var first string
+	for _, target := range []string{"/app/rooms/42", "/app/a/b/c/d", "/app/settings"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, `src="app.`+js.hash+`.js"`) || !strings.Contains(body, `href="/style.`+css.hash+`.css"`) {
+			t.Errorf("%s: links not hashed against the page's directory: %s", target, body)
+		}
+		if first == "" {
+			first = body
+		} else if body != first {
+			t.Errorf("%s: body differs from the first request", target)
+		}
+	}
+
+	page, _ := s.load("app/index.html")
+	s.mu.Lock()
+	rewritten := page.page
+	s.mu.Unlock()
+	if rewritten == nil || string(rewritten.body) != first {
+		t.Error("rewritten page is not cached on its entry")
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app/app."+js.hash+".js", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Errorf("hashed asset: status %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/sdnotify"
+)
+
+// Failure classes Run and New mark their errors with, so cmd/hub can exit
+// with a status a supervisor can tell apart: a port that is taken will not
+// free itself on a quick restart, while PersonaGo may well come back.
+var (
+	ErrListen  = errors.New("listen failed")
+	ErrPersona = errors.New("persona unavailable")
+)
+
+// personaError marks err as a PersonaGo failure that stops the hub.
+type personaError struct {
+	err error
+}
+
+func (e personaError) Error() string        { return e.err.Error() }
+func (e personaError) Unwrap() error        { return e.err }
+func (e personaError) Is(target error) bool { return target == ErrPersona }
+
+// requirePersona fails startup when PERSONA_REQUIRED is set and PersonaGo
+// does not answer a lobby fetch, instead of serving players who cannot get
+// a session.
+func (a *App) requirePersona(ctx context.Context) error {
+	if !a.cfg.PersonaRequired || a.persona == nil {
+		return nil
+	}
+	_, latency, err := a.probePersona(ctx)
+	if err != nil {
+		a.logger.Error("persona_required_unavailable", "err", err.Error())
+		return personaError{err: fmt.Errorf("persona required but unreachable: %w", err)}
+	}
+	a.logger.Info("persona_required_ok", "latency_ms", latency.Milliseconds())
+	return nil
+}
+
+// notifySystemd sends state to systemd when the hub runs as a Type=notify
+// unit; elsewhere it does nothing.
+func (a *App) notifySystemd(state string) {
+	if _, err := sdnotify.Notify(state); err != nil {
+		a.logger.Warn("sd_notify_failed", "state", state, "err", err.Error())
+	}
+}
+
+// runSystemdWatchdog pings systemd at half of WatchdogSec= while the hub is
+// alive: each ping waits for the hub to answer a status read, which takes
+// its lock, within a quarter of WatchdogSec=. Pings also stop while the
+// internal watchdog reports failed checks, so systemd restarts a hub that is
+// wedged before WATCHDOG_FAILURES is reached, or when the internal watchdog
+// is off.
+func (a *App) runSystemdWatchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if a.degraded.Load() || a.stopping.Load() {
+			continue
+		}
+		if !a.hubResponsive(ctx, interval/4) {
+			if ctx.Err() == nil {
+				a.logger.Warn("sd_watchdog_skipped", "err", "hub did not answer within "+(interval/4).String())
+			}
+			continue
+		}
+		a.notifySystemd(sdnotify.Watchdog)
+	}
+}
+
+// hubResponsive reports whether the hub answers a status read within
+// timeout. A wedged hub leaves the read blocked on its lock; the goroutine
+// is abandoned, as systemd is about to restart the process anyway.
+func (a *App) hubResponsive(ctx context.Context, timeout time.Duration) bool {
+	done := make(chan struct{})
+	crash.Go(func() {
+		a.hub.Status()
+		close(done)
+	})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
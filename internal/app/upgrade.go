@@ -0,0 +1,57 @@
+package app
+
+import (
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+const tlsHandshakeErrorPrefix = "http: TLS handshake error from "
+
+// serverErrorLog routes net/http's internal error log into slog. TLS
+// handshake failures never reach a handler, so they are counted here as /ws
+// upgrade failures: on a TLS listener a controller that cannot complete the
+// handshake is the usual symptom of a certificate problem.
+func serverErrorLog(logger *slog.Logger, h *hub.Hub) *log.Logger {
+	return log.New(serverErrorWriter{logger: logger, hub: h}, "", 0)
+}
+
+type serverErrorWriter struct {
+	logger *slog.Logger
+	hub    *hub.Hub
+}
+
+func (w serverErrorWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSpace(string(p))
+	if rest, ok := strings.CutPrefix(line, tlsHandshakeErrorPrefix); ok {
+		remote, reason, _ := strings.Cut(rest, ": ")
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+		w.hub.RecordUpgradeFailure(hub.UpgradeFailTLS)
+		w.logger.Warn("tls_handshake_failed", "remote_ip", remote, "err", reason)
+		return len(p), nil
+	}
+	w.logger.Warn("http_server_error", "err", line)
+	return len(p), nil
+}
+
+func (a *App) adminUpgradeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := a.hub.UpgradeStats()
+	a.respondJSON(w, http.StatusOK, map[string]any{
+		"accepted":    stats.Accepted,
+		"failed":      stats.Failed,
+		"pending":     stats.Pending,
+		"pendingByIp": stats.PendingByIP,
+	})
+}
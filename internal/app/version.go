@@ -0,0 +1,33 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/buildinfo"
+)
+
+// versionResponse is the body of GET /api/version.
+type versionResponse struct {
+	buildinfo.Info
+	GameID    string `json:"gameId"`
+	StartedAt string `json:"startedAt"`
+	UptimeMs  int64  `json:"uptimeMs"`
+}
+
+// versionHandler tells operators which build is running, so every mini-PC
+// at the venue can be checked against the release without a shell.
+func (a *App) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	a.respondJSON(w, http.StatusOK, versionResponse{
+		Info:      buildinfo.Get(),
+		GameID:    a.cfg.GameID,
+		StartedAt: a.started.UTC().Format(time.RFC3339),
+		UptimeMs:  time.Since(a.started).Milliseconds(),
+	})
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons a visit is refused, reported as details.slots[].reason.
+const (
+	visitReasonCooldown   = "cooldown"
+	visitReasonDailyLimit = "daily_limit"
+)
+
+// visitLog remembers when each user last visited this attraction so the
+// visit policy (VISIT_COOLDOWN, VISIT_DAILY_LIMIT) is enforced by the hub
+// with a clear error, instead of PersonaGo refusing a repeat visit opaquely.
+// It is journaled to STATE_FILE so a restart does not reset it. Days follow
+// the hub's local time zone (TZ).
+type visitLog struct {
+	mu     sync.Mutex
+	byUser map[string][]time.Time
+}
+
+// visitDenial explains why a user may not visit yet.
+type visitDenial struct {
+	Reason      string
+	AvailableAt time.Time
+	VisitsToday int
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (a *App) visitPolicyEnabled() bool {
+	return a.cfg.VisitCooldown > 0 || a.cfg.VisitDailyLimit > 0
+}
+
+// check reports whether userID may visit at now under the policy.
+func (v *visitLog) check(userID string, now time.Time, cooldown time.Duration, dailyLimit int) (visitDenial, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	visits := v.byUser[userID]
+
+	today := startOfDay(now)
+	count := 0
+	var last time.Time
+	for _, at := range visits {
+		if !at.Before(today) {
+			count++
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+
+	if dailyLimit > 0 && count >= dailyLimit {
+		return visitDenial{Reason: visitReasonDailyLimit, AvailableAt: today.AddDate(0, 0, 1), VisitsToday: count}, false
+	}
+	if cooldown > 0 && !last.IsZero() && now.Sub(last) < cooldown {
+		return visitDenial{Reason: visitReasonCooldown, AvailableAt: last.Add(cooldown), VisitsToday: count}, false
+	}
+	return visitDenial{VisitsToday: count}, true
+}
+
+// record notes a visit by userID at now, forgetting visits the policy no
+// longer looks at.
+func (v *visitLog) record(userID string, now time.Time, cooldown time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.byUser == nil {
+		v.byUser = make(map[string][]time.Time)
+	}
+	horizon := startOfDay(now)
+	if since := now.Add(-cooldown); since.Before(horizon) {
+		horizon = since
+	}
+	for user, visits := range v.byUser {
+		visits = slices.DeleteFunc(visits, func(at time.Time) bool { return at.Before(horizon) })
+		if len(visits) == 0 {
+			delete(v.byUser, user)
+			continue
+		}
+		v.byUser[user] = visits
+	}
+	v.byUser[userID] = append(v.byUser[userID], now.UTC())
+}
+
+func (v *visitLog) snapshot() map[string][]time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.byUser) == 0 {
+		return nil
+	}
+	out := make(map[string][]time.Time, len(v.byUser))
+	for user, visits := range v.byUser {
+		out[user] = slices.Clone(visits)
+	}
+	return out
+}
+
+func (v *visitLog) restore(visits map[string][]time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.byUser = make(map[string][]time.Time, len(visits))
+	for user, at := range visits {
+		v.byUser[user] = slices.Clone(at)
+	}
+}
+
+// visitDenialDetail describes a refused visit for API error details.
+func visitDenialDetail(slotID, userID string, denial visitDenial) map[string]any {
+	detail := map[string]any{
+		"userId":      userID,
+		"reason":      denial.Reason,
+		"availableAt": denial.AvailableAt.UTC().Format(time.RFC3339),
+		"visitsToday": denial.VisitsToday,
+	}
+	if slotID != "" {
+		detail["slotId"] = slotID
+	}
+	return detail
+}
+
+// gameVisitsHandler serves GET /api/game/visits?userId=, telling reception
+// whether a user may play now under the visit policy and, if not, from
+// when.
+func (a *App) gameVisitsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := strings.TrimSpace(r.URL.Query().Get("userId"))
+	if userID == "" {
+		a.respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "userId is required")
+		return
+	}
+
+	resp := map[string]any{
+		"gameId":     a.cfg.GameID,
+		"userId":     userID,
+		"allowed":    true,
+		"cooldownMs": a.cfg.VisitCooldown.Milliseconds(),
+		"dailyLimit": a.cfg.VisitDailyLimit,
+	}
+	denial, ok := a.visits.check(userID, time.Now(), a.cfg.VisitCooldown, a.cfg.VisitDailyLimit)
+	resp["visitsToday"] = denial.VisitsToday
+	if !ok {
+		resp["allowed"] = false
+		resp["reason"] = denial.Reason
+		resp["availableAt"] = denial.AvailableAt.UTC().Format(time.RFC3339)
+	}
+	a.respondJSON(w, http.StatusOK, resp)
+}
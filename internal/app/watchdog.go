@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+)
+
+// ErrWatchdog is returned by Run when the watchdog gave up on the process
+// after WATCHDOG_FAILURES consecutive failed checks. cmd/hub exits with a
+// distinct code for it so a supervisor restarts the hub.
+var ErrWatchdog = errors.New("watchdog: hub unresponsive")
+
+// maxWatchdogProbe bounds each self-probe; a probe still blocked after this
+// is counted as failed, which is what a deadlock looks like from outside.
+const maxWatchdogProbe = 5 * time.Second
+
+// watchdogUserAgent marks probe requests, which are logged at debug level
+// so they do not flood the request log.
+const watchdogUserAgent = "cgb-io-hub-watchdog"
+
+// runWatchdog checks every WatchdogInterval that the public listener serves
+// /healthz, that a synthetic WebSocket connection gets its echo and that the
+// game writer is draining. A wedged writer is healed by dropping the game
+// session. Other failures are counted and, once WatchdogFailures checks in a
+// row failed, reported on failed so Run shuts down with ErrWatchdog.
+func (a *App) runWatchdog(ctx context.Context, addr net.Addr, failed chan<- error) {
+	base := watchdogBaseURL(addr, a.tlsEnabled())
+	client := a.selfClient()
+
+	ticker := time.NewTicker(a.cfg.WatchdogInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var failing []string
+		for _, probe := range []struct {
+			name string
+			run  func(context.Context) error
+		}{
+			{"http", func(ctx context.Context) error { return probeHealthz(ctx, client, base) }},
+			{"websocket", func(ctx context.Context) error { return probeWebSocket(ctx, client, base) }},
+			{"relay", func(context.Context) error { return a.hub.HealRelay() }},
+		} {
+			err := a.runProbe(ctx, probe.run)
+			if ctx.Err() != nil {
+				return
+			}
+			switch {
+			case errors.Is(err, errRelayHealed):
+				a.logger.Warn("watchdog_healed", "probe", probe.name)
+			case err != nil:
+				a.logger.Warn("watchdog_probe_failed", "probe", probe.name, "err", err.Error())
+				failing = append(failing, probe.name)
+			}
+		}
+
+		a.degraded.Store(len(failing) > 0)
+		if len(failing) == 0 {
+			if failures > 0 {
+				a.logger.Info("watchdog_recovered", "failures", failures)
+			}
+			failures = 0
+			continue
+		}
+		failures++
+		a.hub.PublishEvent("watchdog_failed", "probes", failing, "failures", failures)
+		if failures >= a.cfg.WatchdogFailures {
+			a.logger.Error("watchdog_restart", "probes", failing, "failures", failures)
+			failed <- fmt.Errorf("%w: %s", ErrWatchdog, strings.Join(failing, ", "))
+			return
+		}
+	}
+}
+
+// errRelayHealed marks a relay probe that found and fixed a stall.
+var errRelayHealed = errors.New("relay healed")
+
+// runProbe runs probe with a deadline and gives up waiting once it passes,
+// even if probe ignores its context because it is stuck on a lock.
+func (a *App) runProbe(ctx context.Context, probe func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, a.watchdogTimeout())
+	defer cancel()
+	done := make(chan error, 1)
+	crash.Go(func() {
+		err := probe(ctx)
+		if errors.Is(err, hub.ErrRelayStalled) {
+			err = errRelayHealed
+		}
+		done <- err
+	})
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("no answer within %s", a.watchdogTimeout())
+	}
+}
+
+// selfClient returns the client probes use to reach this process. Probes
+// are bounded by their context: the WebSocket dialer refuses a client with a
+// Timeout.
+func (a *App) selfClient() *http.Client {
+	client := &http.Client{}
+	if a.tlsEnabled() {
+		// The probe targets our own certificate by IP; the handshake itself
+		// is what is being exercised, not the certificate chain.
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return client
+}
+
+func (a *App) watchdogTimeout() time.Duration {
+	return min(a.cfg.WatchdogInterval, maxWatchdogProbe)
+}
+
+// watchdogBaseURL returns the loopback URL of the public listener; probing
+// through the wildcard address would depend on the host's routing.
+func watchdogBaseURL(addr net.Addr, tlsEnabled bool) string {
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+	host := "127.0.0.1"
+	port := ""
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		port = fmt.Sprint(tcp.Port)
+		if tcp.IP.To4() == nil && !tcp.IP.IsUnspecified() {
+			host = "::1"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+func probeHealthz(ctx context.Context, client *http.Client, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", watchdogUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/healthz answered %s", resp.Status)
+	}
+	return nil
+}
+
+// probeWebSocket registers as the hub's watchdog role and expects its id
+// echoed back.
+func probeWebSocket(ctx context.Context, client *http.Client, base string) error {
+	url := "ws" + strings.TrimPrefix(base, "http") + "/ws"
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{
+		HTTPClient: client,
+		HTTPHeader: http.Header{"User-Agent": {watchdogUserAgent}},
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.CloseNow()
+
+	id := newDeliveryID()
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"role":"watchdog","id":"`+id+`"}`)); err != nil {
+		return err
+	}
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return err
+	}
+	if reply.Type != "watchdog" || reply.ID != id {
+		return fmt.Errorf("unexpected echo %s", data)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+	return nil
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const webhookTimeout = 5 * time.Second
+
+// webhookClient posts JSON payloads to an operator configured URL.
+type webhookClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+func newWebhookClient(url string) *webhookClient {
+	return &webhookClient{
+		url:        url,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (c *webhookClient) post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	_, err = c.send(ctx, body, nil)
+	return err
+}
+
+// send posts an encoded body with the extra headers and returns the
+// response status, 0 when no response arrived.
+func (c *webhookClient) send(ctx context.Context, body []byte, header http.Header) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create webhook request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,145 @@
+// Package blob stores named files (recordings, report exports and support
+// bundles) behind one interface so a deployment picks where they land (a
+// local directory, S3 or memory) without the features that write them
+// knowing.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for a key the store does not hold.
+var ErrNotFound = errors.New("blob: not found")
+
+// Info describes a stored object.
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store holds objects addressed by slash-separated keys such as
+// "recordings/match-20251102T130405Z.jsonl".
+type Store interface {
+	// Create starts writing key, replacing any object already there. The
+	// object is complete once Close returns nil; a store may not show it
+	// before then.
+	Create(ctx context.Context, key string) (io.WriteCloser, error)
+	// Open reads key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat describes key.
+	Stat(ctx context.Context, key string) (Info, error)
+	// List returns the objects whose keys start with prefix, in key order.
+	List(ctx context.Context, prefix string) ([]Info, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// String describes where the objects live, for logs.
+	String() string
+}
+
+// Open returns the store at location:
+//
+//	/var/lib/hub, file:///var/lib/hub   a local directory
+//	s3://bucket/prefix?region=...       an S3 bucket (see S3Config)
+//	mem://                              process memory, lost on restart
+func Open(location string) (Store, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, errors.New("blob: location required")
+	}
+	scheme, rest, ok := strings.Cut(location, "://")
+	if !ok {
+		return NewDir(location)
+	}
+	switch strings.ToLower(scheme) {
+	case "file":
+		return NewDir(rest)
+	case "mem", "memory":
+		return NewMemory(), nil
+	case "s3":
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("blob: parse %q: %w", location, err)
+		}
+		return newS3FromURL(u)
+	default:
+		return nil, fmt.Errorf("blob: unsupported location scheme %q", scheme)
+	}
+}
+
+// ValidKey reports whether key is a clean relative slash-separated path.
+func ValidKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") || path.Clean(key) != key {
+		return fmt.Errorf("blob: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("blob: invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// WithPrefix returns a view of s whose keys live under prefix, so several
+// features can share one store without seeing each other's objects.
+func WithPrefix(s Store, prefix string) Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	return prefixed{store: s, prefix: prefix + "/"}
+}
+
+type prefixed struct {
+	store  Store
+	prefix string
+}
+
+func (p prefixed) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	return p.store.Create(ctx, p.prefix+key)
+}
+
+func (p prefixed) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	return p.store.Open(ctx, p.prefix+key)
+}
+
+func (p prefixed) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidKey(key); err != nil {
+		return Info{}, err
+	}
+	info, err := p.store.Stat(ctx, p.prefix+key)
+	info.Key = strings.TrimPrefix(info.Key, p.prefix)
+	return info, err
+}
+
+func (p prefixed) List(ctx context.Context, prefix string) ([]Info, error) {
+	infos, err := p.store.List(ctx, p.prefix+prefix)
+	for i := range infos {
+		infos[i].Key = strings.TrimPrefix(infos[i].Key, p.prefix)
+	}
+	return infos, err
+}
+
+func (p prefixed) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	return p.store.Delete(ctx, p.prefix+key)
+}
+
+func (p prefixed) String() string {
+	return strings.TrimSuffix(p.store.String(), "/") + "/" + p.prefix
+}
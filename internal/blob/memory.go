@@ -0,0 +1,115 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps objects in process memory. It suits demos and rehearsals
+// where nothing needs to outlive the hub.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+type memoryWriter struct {
+	bytes.Buffer
+	store  *Memory
+	key    string
+	closed bool
+}
+
+func (w *memoryWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.objects[w.key] = memoryObject{data: bytes.Clone(w.Bytes()), modTime: time.Now()}
+	return nil
+}
+
+// Create implements Store.
+func (m *Memory) Create(_ context.Context, key string) (io.WriteCloser, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	return &memoryWriter{store: m, key: key}, nil
+}
+
+func (m *Memory) object(key string) (memoryObject, error) {
+	if err := ValidKey(key); err != nil {
+		return memoryObject{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return memoryObject{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return obj, nil
+}
+
+// Open implements Store.
+func (m *Memory) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	obj, err := m.object(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// Stat implements Store.
+func (m *Memory) Stat(_ context.Context, key string) (Info, error) {
+	obj, err := m.object(key)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime}, nil
+}
+
+// List implements Store.
+func (m *Memory) List(_ context.Context, prefix string) ([]Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]Info, 0, len(m.objects))
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, Info{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) String() string {
+	return "mem://"
+}
@@ -0,0 +1,412 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Timeout bounds one S3 request, uploads of a long recording included.
+const s3Timeout = 2 * time.Minute
+
+// S3Config locates a bucket. Credentials default to AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, and Region to AWS_REGION or
+// AWS_DEFAULT_REGION.
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to every key.
+	Prefix string
+	Region string
+	// Endpoint overrides the AWS endpoint for S3-compatible services such as
+	// MinIO, e.g. "http://minio:9000"; requests then use path-style URLs.
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	HTTPClient   *http.Client
+}
+
+// S3 stores objects in an S3 bucket, signing requests with AWS Signature
+// Version 4. Objects are spooled to a temporary file and uploaded on Close,
+// so nothing reaches the bucket until then.
+type S3 struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+}
+
+// newS3FromURL reads s3://bucket/prefix?region=...&endpoint=...
+func newS3FromURL(u *url.URL) (*S3, error) {
+	q := u.Query()
+	return NewS3(S3Config{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Region:   q.Get("region"),
+		Endpoint: q.Get("endpoint"),
+	})
+}
+
+// NewS3 returns a store for the bucket in cfg.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("blob: s3 bucket required")
+	}
+	cfg.Region = firstSet(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	cfg.AccessKey = firstSet(cfg.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+	cfg.SecretKey = firstSet(cfg.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	cfg.SessionToken = firstSet(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("blob: s3 credentials required (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY)")
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	var base *url.URL
+	var err error
+	if cfg.Endpoint != "" {
+		base, err = url.Parse(strings.TrimSuffix(cfg.Endpoint, "/") + "/" + cfg.Bucket)
+	} else {
+		base, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", cfg.Bucket, cfg.Region))
+	}
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("blob: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: s3Timeout}
+	}
+	return &S3{cfg: cfg, base: base, client: client}, nil
+}
+
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *S3) objectKey(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+// request signs and sends one S3 call. body may be nil; payloadHash is the
+// hex SHA-256 of body.
+func (s *S3) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := *s.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("blob: s3 %s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Error turns a failed response into an error, ErrNotFound for 404.
+func s3Error(resp *http.Response, op, key string) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("blob: s3 %s %s: %d %s: %s", op, key, resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("blob: s3 %s %s: status %d", op, key, resp.StatusCode)
+}
+
+// s3Writer spools an object to a temporary file, hashing it for the
+// signature, and uploads it on Close.
+type s3Writer struct {
+	ctx    context.Context
+	store  *S3
+	key    string
+	file   *os.File
+	hash   hash.Hash
+	size   int64
+	closed bool
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *s3Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer os.Remove(w.file.Name())
+	defer w.file.Close()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("blob: rewind spool: %w", err)
+	}
+	key := w.store.objectKey(w.key)
+	resp, err := w.store.request(w.ctx, http.MethodPut, key, nil, w.file, w.size, hex.EncodeToString(w.hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp, "put", key)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Create implements Store. The upload runs on Close under ctx.
+func (s *S3) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "hub-blob-*")
+	if err != nil {
+		return nil, fmt.Errorf("blob: create spool: %w", err)
+	}
+	return &s3Writer{ctx: context.WithoutCancel(ctx), store: s, key: key, file: file, hash: sha256.New()}, nil
+}
+
+// Open implements Store.
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	full := s.objectKey(key)
+	resp, err := s.request(ctx, http.MethodGet, full, nil, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp, "get", full)
+	}
+	return resp.Body, nil
+}
+
+// Stat implements Store.
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidKey(key); err != nil {
+		return Info{}, err
+	}
+	full := s.objectKey(key)
+	resp, err := s.request(ctx, http.MethodHead, full, nil, nil, 0, "")
+	if err != nil {
+		return Info{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Info{}, s3Error(resp, "head", full)
+	}
+	resp.Body.Close()
+	info := Info{Key: key, Size: resp.ContentLength}
+	if mod, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = mod
+	}
+	return info, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List implements Store, paging through ListObjectsV2.
+func (s *S3) List(ctx context.Context, prefix string) ([]Info, error) {
+	full := s.objectKey(prefix)
+	var infos []Info
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {full}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.request(ctx, http.MethodGet, "", query, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, s3Error(resp, "list", full)
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("blob: s3 list %s: %w", full, err)
+		}
+		for _, obj := range page.Contents {
+			key := obj.Key
+			if s.cfg.Prefix != "" {
+				key = strings.TrimPrefix(key, s.cfg.Prefix+"/")
+			}
+			infos = append(infos, Info{Key: key, Size: obj.Size, ModTime: obj.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Delete implements Store.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	full := s.objectKey(key)
+	resp, err := s.request(ctx, http.MethodDelete, full, nil, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, "delete", full)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) String() string {
+	if s.cfg.Prefix == "" {
+		return "s3://" + s.cfg.Bucket
+	}
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix
+}
+
+// sign adds the AWS Signature Version 4 headers to req.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.cfg.SessionToken != "" {
+		headers["x-amz-security-token"] = s.cfg.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key as SigV4 requires; the same
+// string is sent so the signature matches.
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the RFC 3986 unreserved
+// characters, and '/' unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
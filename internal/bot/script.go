@@ -0,0 +1,212 @@
+// Package bot runs the scripts behind scripted bot players. A script is
+// Lua: it moves the stick and button of a controller slot, so a single
+// visitor can play against filled slots during quiet periods.
+//
+// A script sees these globals:
+//
+//	slot      the slot the bot plays, e.g. "p4"
+//	input     {axes = {x = 0, y = 0}, btn = {a = false}}, the controller
+//	          state; the script changes it and the bot sends it
+//	interval  milliseconds between tick calls, 100 unless the script sets
+//	          it while loading
+//	log(...)  prints its arguments
+//
+// and may define
+//
+//	tick(t)           called every interval; t is milliseconds since start
+//	on_message(msg)   called with each message the hub or game sends, as a
+//	                  table, e.g. {type = "match_start", ...}
+//
+// Only the base, table, string and math libraries are loaded; scripts
+// cannot reach files or the network.
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	defaultInterval = 100 * time.Millisecond
+	minInterval     = 10 * time.Millisecond
+	// callTimeout stops a script stuck in a loop.
+	callTimeout = time.Second
+)
+
+// State is the controller state a script set.
+type State struct {
+	X, Y float64
+	A    bool
+}
+
+// Script is a loaded bot script. It is not safe for concurrent use.
+type Script struct {
+	l       *lua.LState
+	started time.Time
+}
+
+// Load runs the script at path for slot, which defines its functions and
+// may set interval. logf receives the script's log output.
+func Load(path, slot string, logf func(string)) (*Script, error) {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		l.Push(l.NewFunction(lib.open))
+		l.Push(lua.LString(lib.name))
+		l.Call(1, 0)
+	}
+	// The base library can still load files.
+	for _, name := range []string{"dofile", "loadfile"} {
+		l.SetGlobal(name, lua.LNil)
+	}
+
+	s := &Script{l: l, started: time.Now()}
+	input := l.NewTable()
+	axes := l.NewTable()
+	axes.RawSetString("x", lua.LNumber(0))
+	axes.RawSetString("y", lua.LNumber(0))
+	btn := l.NewTable()
+	btn.RawSetString("a", lua.LFalse)
+	input.RawSetString("axes", axes)
+	input.RawSetString("btn", btn)
+	l.SetGlobal("input", input)
+	l.SetGlobal("slot", lua.LString(slot))
+	l.SetGlobal("interval", lua.LNumber(defaultInterval/time.Millisecond))
+	l.SetGlobal("log", l.NewFunction(func(l *lua.LState) int {
+		parts := make([]string, l.GetTop())
+		for i := range parts {
+			parts[i] = l.ToStringMeta(l.Get(i + 1)).String()
+		}
+		logf(strings.Join(parts, " "))
+		return 0
+	}))
+	// Bots on the same machine should not all make the same moves.
+	_ = l.DoString(fmt.Sprintf("math.randomseed(%d)", time.Now().UnixNano()%math.MaxInt32))
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	l.SetContext(ctx)
+	if err := l.DoFile(path); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("load bot script: %w", err)
+	}
+	l.RemoveContext()
+	return s, nil
+}
+
+// Interval is how often Tick should be called.
+func (s *Script) Interval() time.Duration {
+	ms, ok := s.l.GetGlobal("interval").(lua.LNumber)
+	if !ok {
+		return defaultInterval
+	}
+	return max(time.Duration(float64(ms)*float64(time.Millisecond)), minInterval)
+}
+
+// Tick calls the script's tick function, if any, and returns the state it
+// left in input.
+func (s *Script) Tick(now time.Time) (State, error) {
+	if err := s.call("tick", lua.LNumber(now.Sub(s.started).Milliseconds())); err != nil {
+		return State{}, err
+	}
+	return s.state(), nil
+}
+
+// Message hands a message from the hub to the script's on_message function,
+// if any. Messages that are not JSON objects are ignored.
+func (s *Script) Message(raw []byte) error {
+	var msg map[string]any
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil
+	}
+	return s.call("on_message", toLua(s.l, msg))
+}
+
+// Close releases the interpreter.
+func (s *Script) Close() {
+	s.l.Close()
+}
+
+func (s *Script) call(name string, args ...lua.LValue) error {
+	fn, ok := s.l.GetGlobal(name).(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	s.l.SetContext(ctx)
+	defer s.l.RemoveContext()
+	if err := s.l.CallByParam(lua.P{Fn: fn, Protect: true}, args...); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("bot script %s: did not return within %s", name, callTimeout)
+		}
+		return fmt.Errorf("bot script %s: %w", name, err)
+	}
+	return nil
+}
+
+// state reads input, clamping the axes to -1..1 as the WebUI stick does.
+func (s *Script) state() State {
+	var st State
+	input, ok := s.l.GetGlobal("input").(*lua.LTable)
+	if !ok {
+		return st
+	}
+	if axes, ok := input.RawGetString("axes").(*lua.LTable); ok {
+		st.X = axis(axes.RawGetString("x"))
+		st.Y = axis(axes.RawGetString("y"))
+	}
+	if btn, ok := input.RawGetString("btn").(*lua.LTable); ok {
+		st.A = lua.LVAsBool(btn.RawGetString("a"))
+	}
+	return st
+}
+
+func axis(v lua.LValue) float64 {
+	n, ok := v.(lua.LNumber)
+	if !ok || math.IsNaN(float64(n)) {
+		return 0
+	}
+	return max(-1, min(1, float64(n)))
+}
+
+// toLua converts decoded JSON into Lua values.
+func toLua(l *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []any:
+		t := l.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(toLua(l, item))
+		}
+		return t
+	case map[string]any:
+		t := l.CreateTable(0, len(v))
+		for key, item := range v {
+			t.RawSetString(key, toLua(l, item))
+		}
+		return t
+	}
+	return lua.LNil
+}
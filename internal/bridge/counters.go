@@ -0,0 +1,63 @@
+// Package bridge holds what the hub's outbound bridges (OSC, MIDI, MQTT)
+// share: delivery counters and error reporting that does not flood the log
+// while a receiver is away.
+package bridge
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ErrorInterval spaces out OnError calls; a receiver that is down fails
+// every message.
+const ErrorInterval = time.Minute
+
+// Counters counts delivered and dropped messages and passes failures to
+// OnError at most once per ErrorInterval. Bridges embed it for their Sent
+// and Dropped methods.
+type Counters struct {
+	onError  func(error)
+	sent     atomic.Uint64
+	dropped  atomic.Uint64
+	reported atomic.Int64 // unix nanoseconds of the last OnError call
+}
+
+// NewCounters returns Counters reporting to onError, which may be nil.
+func NewCounters(onError func(error)) *Counters {
+	return &Counters{onError: onError}
+}
+
+// Sent is the number of messages delivered.
+func (c *Counters) Sent() uint64 {
+	return c.sent.Load()
+}
+
+// Dropped is the number of messages dropped, mostly because the queue was
+// full.
+func (c *Counters) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// AddSent counts n delivered messages.
+func (c *Counters) AddSent(n uint64) {
+	c.sent.Add(n)
+}
+
+// AddDropped counts one dropped message.
+func (c *Counters) AddDropped() {
+	c.dropped.Add(1)
+}
+
+// Fail reports err unless another error was reported within the last
+// ErrorInterval.
+func (c *Counters) Fail(err error) {
+	if c.onError == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := c.reported.Load()
+	if now-last < int64(ErrorInterval) || !c.reported.CompareAndSwap(last, now) {
+		return
+	}
+	c.onError(err)
+}
@@ -0,0 +1,93 @@
+// Package buildinfo reports which build of the hub is running. Release
+// builds stamp the variables below with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/aritumn2025/cgb-io-hub/internal/buildinfo.Version=v1.4.0 \
+//	  -X github.com/aritumn2025/cgb-io-hub/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/aritumn2025/cgb-io-hub/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/hub
+//
+// Unstamped builds fall back to the VCS details the Go toolchain records.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags -X.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime,omitempty"`
+	// CommitTime is the time of Commit as recorded by the toolchain.
+	CommitTime string `json:"commitTime,omitempty"`
+	// Modified reports a build from a working tree with uncommitted
+	// changes, as recorded by the toolchain.
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information, computed once.
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			if info.Version == "" && bi.Main.Version != "(devel)" {
+				info.Version = bi.Main.Version
+			}
+			for _, s := range bi.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					if info.Commit == "" {
+						info.Commit = s.Value
+					}
+				case "vcs.time":
+					info.CommitTime = s.Value
+				case "vcs.modified":
+					info.Modified = s.Value == "true"
+				}
+			}
+		}
+		if info.Version == "" {
+			info.Version = "dev"
+		}
+		if info.Commit == "" {
+			info.Commit = "unknown"
+		}
+	})
+	return info
+}
+
+// String renders the build on one line, e.g.
+// "v1.4.0 (3f2c1ab04d9e, 2026-10-17T09:00:00Z, go1.25.1)".
+func (i Info) String() string {
+	commit := i.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if i.Modified {
+		commit += "-dirty"
+	}
+	s := i.Version + " (" + commit
+	if i.BuildTime != "" {
+		s += ", " + i.BuildTime
+	}
+	return s + ", " + i.GoVersion + ")"
+}
+
+// LogAttrs are the fields the startup log line carries.
+func (i Info) LogAttrs() []any {
+	return []any{"version", i.Version, "commit", i.Commit, "build_time", i.BuildTime, "commit_time", i.CommitTime, "modified", i.Modified, "go", i.GoVersion}
+}
@@ -7,29 +7,209 @@ const (
 	defaultOrigins         = "*"
 	defaultMaxControllers  = 4
 	defaultRateHz          = 60
+	defaultBroadcastRateHz = 30
+	defaultInputRateHz     = 120
+	defaultQueuePolicy     = "drop-oldest"
+	defaultPriorityTypes   = "pause,emergency_stop"
+	defaultIDFields        = "id"
+	defaultIDMismatch      = "reject"
 	defaultRegisterTimeout = 5 * time.Second
+	defaultMaxConnsPerIP   = 8
+	defaultMaxPendingPerIP = 4
+	defaultFailureLimit    = 10
+	defaultFailureWindow   = time.Minute
+	defaultRegisterLockout = time.Minute
 	defaultWriteTimeout    = 2 * time.Second
 	defaultShutdownTimeout = 10 * time.Second
 	defaultDBAPITimeout    = 3 * time.Second
+	defaultDBAPIVersion    = 1
 	defaultSessionTokenTTL = 60 * time.Second
 	defaultGameID          = "Game_1"
 	defaultAttractionID    = "Game_1"
 	defaultStaffName       = "hub"
+	defaultLogLevel        = "info"
+	defaultGameListeners   = 1
+	defaultHandoverDrain   = 2 * time.Second
+	defaultVisibilityGrace = 60 * time.Second
+	defaultStaleAfter      = 10 * time.Second
+	defaultTimerInterval   = time.Second
+	defaultCalibration     = 10 * time.Second
+	defaultWebhookRetries  = 3
+	defaultWatchdogLimit   = 3
+	defaultResultRetries   = 10
+	defaultLeaderboardSize = 5000
+	defaultMinProtocol     = 1
+	defaultCompressLevel   = 5
+	defaultMQTTEventTopic  = "cgb/{game}/events/{type}"
+	defaultMQTTInputTopic  = "cgb/{game}/input/{slot}"
+	defaultMQTTStatusTopic = "cgb/{game}/status"
+	defaultCompressMinSize = 1024
+	minRelaySigningKeyLen  = 32
+)
+
+// Token formats: random opaque strings, or Ed25519-signed JWTs whose keys
+// are published at /.well-known/jwks.json.
+const (
+	TokenFormatOpaque = "opaque"
+	TokenFormatJWT    = "jwt"
+)
+
+// Controller session auth policies: whether /api/controller/session and
+// /api/controller/claim need an API key like the other management routes.
+const (
+	ControllerSessionAuthOpen   = "open"
+	ControllerSessionAuthAPIKey = "api-key"
 )
 
 // Config holds application level configuration.
 type Config struct {
-	Addr            string
-	Origins         []string
-	MaxControllers  int
-	RateHz          int
-	RegisterTimeout time.Duration
-	WriteTimeout    time.Duration
-	ShutdownTimeout time.Duration
-	DBBaseURL       string
-	GameID          string
-	AttractionID    string
-	StaffName       string
-	DBAPITimeout    time.Duration
-	SessionTokenTTL time.Duration
+	Addr                  string
+	AdminAddr             string
+	AdminToken            string
+	APIKeys               []string
+	ControllerSessionAuth string
+	TokenFormat           string
+	TokenSigningKey       string
+	TokenVerifyKeys       []string
+	SessionRateLimit      int
+	SessionRateBurst      int
+	APIRateLimit          int
+	APIRateBurst          int
+	TLSCertFile           string
+	TLSKeyFile            string
+	HTTP2                 bool
+	H2C                   bool
+	Origins               []string
+	TrustedProxies        []string
+	MaxControllers        int
+	RateHz                int
+	QueuePolicy           string
+	CoalesceInput         bool
+	PriorityTypes         []string
+	ControllerIDFields    []string
+	IDMismatch            string
+	RelayTimestamp        bool
+	RelayEnvelope         bool
+	RelaySigningKey       string
+	Passthrough           bool
+	RegisterTimeout       time.Duration
+	WriteTimeout          time.Duration
+	ShutdownTimeout       time.Duration
+	DBBaseURL             string
+	GameID                string
+	AttractionID          string
+	StaffName             string
+	DBAPITimeout          time.Duration
+	DBAPIVersion          int
+	PersonaRequired       bool
+	SessionTokenTTL       time.Duration
+	StateFile             string
+	StaticOverlayDir      string
+	MinProtocolVersion    int
+	IDMinLength           int
+	IDMaxLength           int
+	IDCharset             string
+	IDReservedPrefixes    []string
+	AllowAnonymous        bool
+	AssignmentsWebhookURL string
+	Cohorts               string
+	InputProfiles         string
+	SoftLimits            string
+	LoadShedding          bool
+	BroadcastRateHz       int
+	InputRateHz           int
+	StateDelta            bool
+	MaxConnsPerIP         int
+	MaxPendingPerIP       int
+	RegisterFailureLimit  int
+	RegisterFailureWindow time.Duration
+	RegisterLockout       time.Duration
+	LogLevel              string
+	GameToken             string
+	GameListeners         int
+	HandoverDrain         time.Duration
+	RecordDir             string
+	CrashDir              string
+	IdleTimeout           time.Duration
+	VisibilityGrace       time.Duration
+	StaleAfter            time.Duration
+	TimerInterval         time.Duration
+	CalibrationTimeout    time.Duration
+	ResultReminderAfter   time.Duration
+	AlertWebhookURL       string
+
+	LobbyReconcileInterval time.Duration
+	LobbyReconcileDryRun   bool
+
+	LifecycleWebhookURLs    []string
+	LifecycleWebhookSecret  string
+	LifecycleWebhookRetries int
+
+	WatchdogInterval time.Duration
+	WatchdogFailures int
+
+	LatencyHintInterval time.Duration
+	ResultRetryAttempts int
+
+	VisitCooldown   time.Duration
+	VisitDailyLimit int
+
+	ActivityDir string
+
+	LeaderboardHistory int
+
+	// StorageURL selects the blob store for recordings, report exports and
+	// support bundles: a directory, s3://bucket/prefix or mem://. It takes
+	// precedence over RecordDir.
+	StorageURL string
+
+	// SocketIO serves Socket.IO game clients on /socket.io/.
+	SocketIO bool
+
+	// OSCTarget is the host:port OSC messages mirroring controller input
+	// are sent to; empty disables the bridge.
+	OSCTarget string
+	OSCPrefix string
+	OSCTypes  []string
+
+	// MIDIDevice is the MIDI device node mapped controller input is written
+	// to; empty disables the bridge. MIDIMap holds the mapping rules.
+	MIDIDevice string
+	MIDIMap    string
+
+	// LocalInputDevice is a keyboard or gamepad event node read by the hub
+	// itself and injected as LocalInputSlot; empty disables it.
+	LocalInputDevice string
+	LocalInputSlot   string
+
+	// MQTTURL is the broker hub events are published to; empty disables
+	// MQTT. Topics may use {game}, {type} and {slot}. Inputs are published
+	// every MQTTInputInterval, never when zero.
+	MQTTURL           string
+	MQTTClientID      string
+	MQTTEventTopic    string
+	MQTTInputTopic    string
+	MQTTStatusTopic   string
+	MQTTInputInterval time.Duration
+	MQTTQoS           int
+	MQTTRetain        bool
+
+	// Compression gzip- or deflate-encodes API and static responses of at
+	// least CompressionMinSize bytes at CompressionLevel (1-9).
+	Compression        bool
+	CompressionLevel   int
+	CompressionMinSize int
+
+	// StaticHashedNames makes pages link their assets by content-hashed
+	// names, cached for good; StaticMaxAge lets browsers reuse the other
+	// assets without revalidating.
+	StaticHashedNames bool
+	StaticMaxAge      time.Duration
+	// SPAFallback lists the path prefixes whose unknown, non-file paths are
+	// answered with the index.html of a single-page app.
+	SPAFallback []string
+
+	// SmokeTest runs the startup self-test instead of serving; it is a
+	// command line switch only.
+	SmokeTest bool
 }
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
@@ -12,9 +15,51 @@ import (
 func Load(args []string) (Config, error) {
 	fs := flag.NewFlagSet("hub", flag.ContinueOnError)
 	addrFlag := fs.String("addr", "", "listen address (ADDR)")
+	adminAddrFlag := fs.String("admin-addr", "", "admin/pprof listen address, empty to disable (ADMIN_ADDR)")
+	tlsCertFileFlag := fs.String("tls-cert", "", "TLS certificate file, enables HTTPS when set with -tls-key (TLS_CERT_FILE)")
+	tlsKeyFileFlag := fs.String("tls-key", "", "TLS private key file (TLS_KEY_FILE)")
+	http2Flag := fs.Bool("http2", false, "enable HTTP/2 on the TLS listener (HTTP2)")
+	h2cFlag := fs.Bool("h2c", false, "enable cleartext HTTP/2 for reverse proxies (H2C)")
 	originsFlag := fs.String("origins", "", "allowed origins, comma separated (ORIGINS)")
+	trustedProxiesFlag := fs.String("trusted-proxies", "", "reverse proxy addresses or CIDR ranges whose X-Forwarded-For is trusted, comma separated (TRUSTED_PROXIES)")
 	maxControllersFlag := fs.Int("max-clients", 0, "max controller connections (MAX_CLIENTS)")
 	rateHzFlag := fs.Int("rate-hz", 0, "relay rate limit in Hz (RATE_HZ)")
+	broadcastRateHzFlag := fs.Int("broadcast-rate-hz", 0, "max game state frames per second forwarded to each controller (BROADCAST_RATE_HZ)")
+	inputRateHzFlag := fs.Int("input-rate-hz", -1, "max frames per second accepted from each controller; 0 disables the limit (INPUT_RATE_HZ)")
+	queuePolicyFlag := fs.String("queue-policy", "", "game send queue overflow policy: drop-oldest, drop-newest, coalesce-by-controller, close-connection (QUEUE_POLICY)")
+	coalesceInputFlag := fs.Bool("coalesce-input", false, "relay only the newest frame per controller and type each tick (COALESCE_INPUT)")
+	priorityTypesFlag := fs.String("priority-types", "", "message types relayed on the high-priority lane, comma separated, or none (PRIORITY_TYPES)")
+	minProtocolFlag := fs.Int("min-protocol-version", 0, "oldest /ws protocol version accepted (MIN_PROTOCOL_VERSION)")
+	idMinLengthFlag := fs.Int("id-min-length", 0, "minimum controller id length (ID_MIN_LENGTH)")
+	idMaxLengthFlag := fs.Int("id-max-length", 0, "maximum controller id length (ID_MAX_LENGTH)")
+	idCharsetFlag := fs.String("id-charset", "", "characters allowed in controller ids (ID_CHARSET)")
+	idReservedPrefixesFlag := fs.String("id-reserved-prefixes", "", "controller id prefixes reserved for hub generated ids, comma separated (ID_RESERVED_PREFIXES)")
+	allowAnonymousFlag := fs.Bool("allow-anonymous", false, "assign generated ids to controllers registering without id or token (ALLOW_ANONYMOUS)")
+	assignmentsWebhookFlag := fs.String("assignments-webhook", "", "URL receiving assignment diff notifications (ASSIGNMENTS_WEBHOOK_URL)")
+	softLimitsFlag := fs.String("soft-limits", "", "warning thresholds ahead of hard limits: a default ratio and name=value overrides, e.g. \"0.75,relay_queue=100\" (SOFT_LIMITS)")
+	inputProfilesFlag := fs.String("input-profiles", "", "message types each slot may send, e.g. \"p1:steer,boost;p4:emote\" (INPUT_PROFILES)")
+	cohortsFlag := fs.String("cohorts", "", "experiment cohorts, e.g. \"fast:coalesce=off,compress=on;batched:coalesce=on\" (COHORTS)")
+	loadSheddingFlag := fs.Bool("load-shedding", false, "shed new controllers and coalesce input under sustained overload (LOAD_SHEDDING)")
+	stateDeltaFlag := fs.Bool("state-delta", false, "send state broadcasts as merge-patch deltas to controllers that opt in (STATE_DELTA)")
+	maxPendingPerIPFlag := fs.Int("max-pending-per-ip", 0, "WebSocket connections per remote IP allowed to wait for their register message (MAX_PENDING_PER_IP)")
+	maxConnsPerIPFlag := fs.Int("max-conns-per-ip", 0, "concurrent WebSocket connections allowed per remote IP (MAX_CONNS_PER_IP)")
+	registerFailureLimitFlag := fs.Int("register-failure-limit", 0, "failed register attempts per IP before a lockout (REGISTER_FAILURE_LIMIT)")
+	registerFailureWindowFlag := fs.Duration("register-failure-window", 0, "window for counting failed register attempts (REGISTER_FAILURE_WINDOW)")
+	registerLockoutFlag := fs.Duration("register-lockout", 0, "how long an IP is refused after too many failed registers (REGISTER_LOCKOUT)")
+	handoverDrainFlag := fs.Duration("handover-drain", 0, "how long a replaced game may flush state after the handover frame (HANDOVER_DRAIN)")
+	gameListenersFlag := fs.Int("game-listeners", 0, "concurrent game connections: the primary game plus read-only mirrors (GAME_LISTENERS)")
+	adminTokenFlag := fs.String("admin-token", "", "bearer token required by the /api/admin endpoints, which are disabled unless this, API_KEYS or ADMIN_ADDR is set (ADMIN_TOKEN)")
+	apiKeysFlag := fs.String("api-keys", "", "comma separated keys accepted by the lobby, game and assignment APIs, empty to leave them open (API_KEYS)")
+	controllerSessionAuthFlag := fs.String("controller-session-auth", "", "controller session and claim endpoints: open or api-key (CONTROLLER_SESSION_AUTH)")
+	tokenFormatFlag := fs.String("token-format", "", "format of issued tokens: opaque or jwt (TOKEN_FORMAT)")
+	tokenSigningKeyFlag := fs.String("token-signing-key", "", "PKCS#8 PEM Ed25519 private key signing jwt tokens, empty for a per-process key (TOKEN_SIGNING_KEY)")
+	tokenVerifyKeysFlag := fs.String("token-verify-keys", "", "comma separated PEM public keys also published in the JWKS, e.g. the previous signing key (TOKEN_VERIFY_KEYS)")
+	sessionRateFlag := fs.Int("session-rate-limit", 0, "controller session and claim requests per minute per IP, 0 to disable (SESSION_RATE_LIMIT)")
+	sessionBurstFlag := fs.Int("session-rate-burst", 0, "controller session and claim requests an IP may send at once, default a sixth of the rate (SESSION_RATE_BURST)")
+	apiRateFlag := fs.Int("api-rate-limit", 0, "lobby, game and assignment API requests per minute per IP, 0 to disable (API_RATE_LIMIT)")
+	apiBurstFlag := fs.Int("api-rate-burst", 0, "lobby, game and assignment API requests an IP may send at once, default a sixth of the rate (API_RATE_BURST)")
+	gameTokenFlag := fs.String("game-token", "", "shared secret the game must present when registering on /ws, empty to disable (GAME_TOKEN)")
+	logLevelFlag := fs.String("log-level", "", "log level: debug, info, warn or error (LOG_LEVEL)")
 	registerTimeoutFlag := fs.Duration("register-timeout", 0, "controller register timeout (REGISTER_TIMEOUT)")
 	writeTimeoutFlag := fs.Duration("write-timeout", 0, "game write timeout (WRITE_TIMEOUT)")
 	shutdownTimeoutFlag := fs.Duration("shutdown-timeout", 0, "graceful shutdown timeout (SHUTDOWN_TIMEOUT)")
@@ -26,9 +71,65 @@ func Load(args []string) (Config, error) {
 	personaAttractionFlag := fs.String("persona-attraction", "", "PersonaGo attraction name (deprecated: PERSONA_ATTRACTION)")
 	staffNameFlag := fs.String("staff-name", "", "PersonaGo staff identifier (STAFF_NAME)")
 	personaStaffFlag := fs.String("persona-staff", "", "PersonaGo staff identifier (deprecated: PERSONA_STAFF)")
+	personaRequiredFlag := fs.Bool("persona-required", false, "exit at startup when PersonaGo does not answer a lobby fetch (PERSONA_REQUIRED)")
 	dbAPITimeoutFlag := fs.Duration("db-api-timeout", 0, "PersonaGo API client timeout (DB_API_TIMEOUT)")
 	personaTimeoutFlag := fs.Duration("persona-timeout", 0, "PersonaGo API client timeout (deprecated: PERSONA_TIMEOUT)")
+	dbAPIVersionFlag := fs.Int("db-api-version", 0, "PersonaGo result schema version; 2 forwards per-slot result metadata (DB_API_VERSION)")
 	sessionTokenTTLFlag := fs.Duration("session-token-ttl", 0, "controller session token TTL (SESSION_TOKEN_TTL)")
+	idleTimeoutFlag := fs.Duration("idle-timeout", 0, "disconnect controllers silent for this long, 0 to disable (IDLE_TIMEOUT)")
+	staleAfterFlag := fs.Duration("stale-after", 0, "silence after which a connected controller is reported stale (STALE_AFTER)")
+	visibilityGraceFlag := fs.Duration("visibility-grace", 0, "how long a controller with a hidden page is spared from idle eviction (VISIBILITY_GRACE)")
+	calibrationTimeoutFlag := fs.Duration("calibration-timeout", 0, "how long a controller has to answer a calibrate request from the game (CALIBRATION_TIMEOUT)")
+	latencyHintFlag := fs.Duration("latency-hint-interval", 0, "how often the game is sent each slot's estimated one-way latency, 0 to disable (LATENCY_HINT_INTERVAL)")
+	timerIntervalFlag := fs.Duration("timer-interval", 0, "how often the match timer is broadcast while a match runs (TIMER_INTERVAL)")
+	visitCooldownFlag := fs.Duration("visit-cooldown", 0, "minimum time before the same user may visit again, 0 to disable (VISIT_COOLDOWN)")
+	visitDailyLimitFlag := fs.Int("visit-daily-limit", 0, "visits allowed per user per day in the hub's time zone, 0 for no limit (VISIT_DAILY_LIMIT)")
+	activityDirFlag := fs.String("activity-dir", "", "directory journaling matches, controller sessions and PersonaGo failures for the daily report (ACTIVITY_DIR)")
+	storageURLFlag := fs.String("storage-url", "", "blob store for recordings, report exports and support bundles: a directory, s3://bucket/prefix?region=&endpoint= or mem:// (STORAGE_URL)")
+	socketIOFlag := fs.Bool("socketio", false, "accept Socket.IO game clients (WebSocket transport) on /socket.io/ (SOCKETIO)")
+	oscTargetFlag := fs.String("osc-target", "", "host:port receiving controller input as OSC messages over UDP, empty to disable (OSC_TARGET)")
+	oscPrefixFlag := fs.String("osc-prefix", "", "address prefix of the OSC messages, default /cgb (OSC_PREFIX)")
+	oscTypesFlag := fs.String("osc-types", "", "comma separated message types mirrored over OSC, all when empty (OSC_TYPES)")
+	midiDeviceFlag := fs.String("midi-device", "", "MIDI device node receiving mapped controller input, e.g. an snd-virmidi /dev/snd/midiC1D0 (MIDI_DEVICE)")
+	localInputDeviceFlag := fs.String("local-input-device", "", "keyboard or gamepad event node, e.g. /dev/input/by-id/…-event-joystick, played as LOCAL_INPUT_SLOT (LOCAL_INPUT_DEVICE)")
+	localInputSlotFlag := fs.String("local-input-slot", "", "controller slot the local input device plays as, e.g. p4 (LOCAL_INPUT_SLOT)")
+	midiMapFlag := fs.String("midi-map", "", "comma separated <field>=note:<n> or <field>=cc:<n>[:<min>:<max>] rules for the MIDI bridge (MIDI_MAP)")
+	mqttURLFlag := fs.String("mqtt-url", "", "MQTT broker receiving hub events, mqtt://[user:pass@]host[:1883] or mqtts:// (MQTT_URL)")
+	mqttClientIDFlag := fs.String("mqtt-client-id", "", "MQTT client id, default cgb-io-hub-<game id> (MQTT_CLIENT_ID)")
+	mqttEventTopicFlag := fs.String("mqtt-event-topic", "", "topic of join, match and result events; {game} and {type} are replaced (MQTT_EVENT_TOPIC)")
+	mqttInputTopicFlag := fs.String("mqtt-input-topic", "", "topic of sampled controller input; {game} and {slot} are replaced (MQTT_INPUT_TOPIC)")
+	mqttStatusTopicFlag := fs.String("mqtt-status-topic", "", "retained online/offline topic of the hub; {game} is replaced (MQTT_STATUS_TOPIC)")
+	mqttInputIntervalFlag := fs.Duration("mqtt-input-interval", 0, "how often each slot's latest input is published, 0 to disable (MQTT_INPUT_INTERVAL)")
+	mqttQoSFlag := fs.Int("mqtt-qos", 0, "QoS of published events, 0-2; input is always QoS 0 (MQTT_QOS)")
+	mqttRetainFlag := fs.Bool("mqtt-retain", false, "publish events and input as retained messages (MQTT_RETAIN)")
+	compressionFlag := fs.Bool("compression", false, "gzip/deflate-encode API and static responses for clients that accept it (COMPRESSION)")
+	compressionLevelFlag := fs.Int("compression-level", 0, "compression level from 1 (fastest) to 9 (smallest) (COMPRESSION_LEVEL)")
+	compressionMinSizeFlag := fs.Int("compression-min-size", 0, "smallest response body in bytes worth compressing (COMPRESSION_MIN_SIZE)")
+	staticHashedNamesFlag := fs.Bool("static-hashed-names", false, "link page assets as name.<hash>.ext, cached by browsers for good (STATIC_HASHED_NAMES)")
+	staticMaxAgeFlag := fs.Duration("static-max-age", 0, "how long browsers reuse assets without revalidating, 0 to always revalidate (STATIC_MAX_AGE)")
+	spaFallbackFlag := fs.String("spa-fallback", "", "comma separated path prefixes, e.g. /play, whose unknown paths serve index.html (SPA_FALLBACK)")
+	leaderboardFlag := fs.Int("leaderboard-history", 0, "scores kept for /api/game/leaderboard before the oldest are dropped (LEADERBOARD_HISTORY)")
+	resultRetriesFlag := fs.Int("result-retry-attempts", 0, "attempts at a result PersonaGo failed to take before the queued result is marked failed (RESULT_RETRY_ATTEMPTS)")
+	resultReminderFlag := fs.Duration("result-reminder-after", 0, "alert when a match runs this long without a result, 0 to disable (RESULT_REMINDER_AFTER)")
+	lobbyReconcileFlag := fs.Duration("lobby-reconcile-interval", 0, "realign hub tokens with the Persona lobby this often, 0 to disable (LOBBY_RECONCILE_INTERVAL)")
+	lobbyReconcileDryRunFlag := fs.Bool("lobby-reconcile-dry-run", false, "only log the actions lobby reconciliation would take (LOBBY_RECONCILE_DRY_RUN)")
+	lifecycleWebhooksFlag := fs.String("lifecycle-webhooks", "", "comma separated URLs receiving game, match and controller lifecycle events (LIFECYCLE_WEBHOOK_URLS)")
+	lifecycleSecretFlag := fs.String("lifecycle-webhook-secret", "", "shared secret HMAC-signing lifecycle webhook bodies in X-Hub-Signature-256 (LIFECYCLE_WEBHOOK_SECRET)")
+	lifecycleRetriesFlag := fs.Int("lifecycle-webhook-retries", -1, "retries of a failed lifecycle webhook delivery, with exponential backoff; 0 sends once (LIFECYCLE_WEBHOOK_RETRIES)")
+	watchdogIntervalFlag := fs.Duration("watchdog-interval", 0, "interval of the self-health checks of /healthz, a WebSocket echo and the game relay, 0 to disable (WATCHDOG_INTERVAL)")
+	watchdogFailuresFlag := fs.Int("watchdog-failures", 0, "consecutive failed watchdog checks before the hub exits for a restart (WATCHDOG_FAILURES)")
+	smokeTestFlag := fs.Bool("smoke-test", false, "boot on an ephemeral loopback port, register a game and a controller, check PersonaGo when configured, then exit 0 or 1")
+	alertWebhookFlag := fs.String("alert-webhook", "", "URL receiving operator alerts such as overdue results (ALERT_WEBHOOK_URL)")
+	controllerIDFieldsFlag := fs.String("controller-id-fields", "", "comma separated controller frame fields holding the slot id, checked in order (CONTROLLER_ID_FIELDS)")
+	relayTimestampFlag := fs.Bool("relay-timestamp", false, "stamp relayed controller frames with the hub time in hubTs (RELAY_TIMESTAMP)")
+	relayEnvelopeFlag := fs.Bool("relay-envelope", false, "wrap relayed controller frames in an envelope with the hub-verified slot, user and receive time (RELAY_ENVELOPE)")
+	relaySigningKeyFlag := fs.String("relay-signing-key", "", "shared secret HMAC-signing each relay envelope sent to the game, empty to disable (RELAY_SIGNING_KEY)")
+	passthroughFlag := fs.Bool("passthrough", false, "relay controller and game frames verbatim behind a slot id header, without JSON parsing; applies to the hub's single room (PASSTHROUGH)")
+	idMismatchFlag := fs.String("id-mismatch", "", "controller frames naming another slot: reject, drop or rewrite (ID_MISMATCH)")
+	crashDirFlag := fs.String("crash-dir", "", "directory for crash reports written on fatal errors and panics, empty to disable (CRASH_DIR)")
+	recordDirFlag := fs.String("record-dir", "", "directory for controller input recordings, empty to disable (RECORD_DIR)")
+	staticOverlayFlag := fs.String("static-overlay-dir", "", "directory whose files shadow the embedded static assets (STATIC_OVERLAY_DIR)")
+	stateFileFlag := fs.String("state-file", "", "path of the persisted hub state, empty to disable (STATE_FILE)")
 
 	if err := fs.Parse(args); err != nil {
 		return Config{}, err
@@ -36,9 +137,18 @@ func Load(args []string) (Config, error) {
 
 	cfg := Config{
 		Addr:            firstNonEmpty(*addrFlag, os.Getenv("ADDR"), defaultAddr),
+		AdminAddr:       strings.TrimSpace(firstNonEmpty(*adminAddrFlag, os.Getenv("ADMIN_ADDR"))),
+		TLSCertFile:     strings.TrimSpace(firstNonEmpty(*tlsCertFileFlag, os.Getenv("TLS_CERT_FILE"))),
+		TLSKeyFile:      strings.TrimSpace(firstNonEmpty(*tlsKeyFileFlag, os.Getenv("TLS_KEY_FILE"))),
+		HTTP2:           *http2Flag || envToBool("HTTP2"),
+		H2C:             *h2cFlag || envToBool("H2C"),
 		Origins:         parseOrigins(firstNonEmpty(*originsFlag, os.Getenv("ORIGINS"), defaultOrigins)),
+		TrustedProxies:  parseList(firstNonEmpty(*trustedProxiesFlag, os.Getenv("TRUSTED_PROXIES"))),
 		MaxControllers:  firstPositiveInt(*maxControllersFlag, envToInt("MAX_CLIENTS"), defaultMaxControllers),
 		RateHz:          firstPositiveInt(*rateHzFlag, envToInt("RATE_HZ"), defaultRateHz),
+		QueuePolicy:     strings.TrimSpace(firstNonEmpty(*queuePolicyFlag, os.Getenv("QUEUE_POLICY"), defaultQueuePolicy)),
+		CoalesceInput:   *coalesceInputFlag || envToBool("COALESCE_INPUT"),
+		PriorityTypes:   parseOptionalList(firstNonEmpty(*priorityTypesFlag, os.Getenv("PRIORITY_TYPES"), defaultPriorityTypes)),
 		RegisterTimeout: firstPositiveDuration(*registerTimeoutFlag, envToDuration("REGISTER_TIMEOUT"), defaultRegisterTimeout),
 		WriteTimeout:    firstPositiveDuration(*writeTimeoutFlag, envToDuration("WRITE_TIMEOUT"), defaultWriteTimeout),
 		ShutdownTimeout: firstPositiveDuration(*shutdownTimeoutFlag, envToDuration("SHUTDOWN_TIMEOUT"), defaultShutdownTimeout),
@@ -76,13 +186,315 @@ func Load(args []string) (Config, error) {
 			envToDuration("PERSONA_TIMEOUT"),
 			defaultDBAPITimeout,
 		),
+		DBAPIVersion:    firstPositiveInt(*dbAPIVersionFlag, envToInt("DB_API_VERSION"), defaultDBAPIVersion),
+		PersonaRequired: *personaRequiredFlag || envToBool("PERSONA_REQUIRED"),
 		SessionTokenTTL: firstPositiveDuration(*sessionTokenTTLFlag, envToDuration("SESSION_TOKEN_TTL"), defaultSessionTokenTTL),
+		StateFile:       strings.TrimSpace(firstNonEmpty(*stateFileFlag, os.Getenv("STATE_FILE"))),
+		IdleTimeout:     firstPositiveDuration(*idleTimeoutFlag, envToDuration("IDLE_TIMEOUT")),
+		VisibilityGrace: firstPositiveDuration(*visibilityGraceFlag, envToDuration("VISIBILITY_GRACE"), defaultVisibilityGrace),
+		StaleAfter:      firstPositiveDuration(*staleAfterFlag, envToDuration("STALE_AFTER"), defaultStaleAfter),
+		TimerInterval:   firstPositiveDuration(*timerIntervalFlag, envToDuration("TIMER_INTERVAL"), defaultTimerInterval),
+		CalibrationTimeout: firstPositiveDuration(
+			*calibrationTimeoutFlag,
+			envToDuration("CALIBRATION_TIMEOUT"),
+			defaultCalibration,
+		),
+		StaticOverlayDir: strings.TrimSpace(
+			firstNonEmpty(*staticOverlayFlag, os.Getenv("STATIC_OVERLAY_DIR")),
+		),
+		ResultReminderAfter: firstPositiveDuration(
+			*resultReminderFlag,
+			envToDuration("RESULT_REMINDER_AFTER"),
+		),
+		LobbyReconcileInterval: firstPositiveDuration(
+			*lobbyReconcileFlag,
+			envToDuration("LOBBY_RECONCILE_INTERVAL"),
+		),
+		LobbyReconcileDryRun: *lobbyReconcileDryRunFlag ||
+			envToBool("LOBBY_RECONCILE_DRY_RUN"),
+		AlertWebhookURL: strings.TrimSpace(firstNonEmpty(*alertWebhookFlag, os.Getenv("ALERT_WEBHOOK_URL"))),
+		RecordDir:       strings.TrimSpace(firstNonEmpty(*recordDirFlag, os.Getenv("RECORD_DIR"))),
+		CrashDir:        strings.TrimSpace(firstNonEmpty(*crashDirFlag, os.Getenv("CRASH_DIR"))),
+		MinProtocolVersion: firstPositiveInt(
+			*minProtocolFlag,
+			envToInt("MIN_PROTOCOL_VERSION"),
+			defaultMinProtocol,
+		),
+		IDMinLength:    firstPositiveInt(*idMinLengthFlag, envToInt("ID_MIN_LENGTH")),
+		IDMaxLength:    firstPositiveInt(*idMaxLengthFlag, envToInt("ID_MAX_LENGTH")),
+		IDCharset:      strings.TrimSpace(firstNonEmpty(*idCharsetFlag, os.Getenv("ID_CHARSET"))),
+		AllowAnonymous: *allowAnonymousFlag || envToBool("ALLOW_ANONYMOUS"),
+		AssignmentsWebhookURL: strings.TrimSpace(firstNonEmpty(
+			*assignmentsWebhookFlag,
+			os.Getenv("ASSIGNMENTS_WEBHOOK_URL"),
+		)),
+		Cohorts:      strings.TrimSpace(firstNonEmpty(*cohortsFlag, os.Getenv("COHORTS"))),
+		LoadShedding: *loadSheddingFlag || envToBool("LOAD_SHEDDING"),
+		InputProfiles: strings.TrimSpace(firstNonEmpty(
+			*inputProfilesFlag,
+			os.Getenv("INPUT_PROFILES"),
+		)),
+		SoftLimits: strings.TrimSpace(firstNonEmpty(
+			*softLimitsFlag,
+			os.Getenv("SOFT_LIMITS"),
+		)),
+		BroadcastRateHz: firstPositiveInt(
+			*broadcastRateHzFlag,
+			envToInt("BROADCAST_RATE_HZ"),
+			defaultBroadcastRateHz,
+		),
+		InputRateHz: firstNonNegativeInt(
+			*inputRateHzFlag,
+			envToOptionalInt("INPUT_RATE_HZ"),
+			defaultInputRateHz,
+		),
+		StateDelta:    *stateDeltaFlag || envToBool("STATE_DELTA"),
+		MaxConnsPerIP: firstPositiveInt(*maxConnsPerIPFlag, envToInt("MAX_CONNS_PER_IP"), defaultMaxConnsPerIP),
+		MaxPendingPerIP: firstPositiveInt(
+			*maxPendingPerIPFlag,
+			envToInt("MAX_PENDING_PER_IP"),
+			defaultMaxPendingPerIP,
+		),
+		RegisterFailureLimit: firstPositiveInt(
+			*registerFailureLimitFlag,
+			envToInt("REGISTER_FAILURE_LIMIT"),
+			defaultFailureLimit,
+		),
+		RegisterFailureWindow: firstPositiveDuration(
+			*registerFailureWindowFlag,
+			envToDuration("REGISTER_FAILURE_WINDOW"),
+			defaultFailureWindow,
+		),
+		RegisterLockout: firstPositiveDuration(
+			*registerLockoutFlag,
+			envToDuration("REGISTER_LOCKOUT"),
+			defaultRegisterLockout,
+		),
+		IDMismatch: strings.TrimSpace(firstNonEmpty(*idMismatchFlag, os.Getenv("ID_MISMATCH"), defaultIDMismatch)),
+		ControllerIDFields: parseList(firstNonEmpty(
+			*controllerIDFieldsFlag,
+			os.Getenv("CONTROLLER_ID_FIELDS"),
+			defaultIDFields,
+		)),
+		RelayTimestamp: *relayTimestampFlag || envToBool("RELAY_TIMESTAMP"),
+		RelayEnvelope:  *relayEnvelopeFlag || envToBool("RELAY_ENVELOPE"),
+		Passthrough:    *passthroughFlag || envToBool("PASSTHROUGH"),
+		RelaySigningKey: strings.TrimSpace(firstNonEmpty(
+			*relaySigningKeyFlag,
+			os.Getenv("RELAY_SIGNING_KEY"),
+		)),
+		GameListeners: firstPositiveInt(
+			*gameListenersFlag,
+			envToInt("GAME_LISTENERS"),
+			defaultGameListeners,
+		),
+		HandoverDrain: firstPositiveDuration(
+			*handoverDrainFlag,
+			envToDuration("HANDOVER_DRAIN"),
+			defaultHandoverDrain,
+		),
+		AdminToken: strings.TrimSpace(
+			firstNonEmpty(*adminTokenFlag, os.Getenv("ADMIN_TOKEN")),
+		),
+		APIKeys: parseList(firstNonEmpty(*apiKeysFlag, os.Getenv("API_KEYS"))),
+		ControllerSessionAuth: strings.ToLower(strings.TrimSpace(firstNonEmpty(
+			*controllerSessionAuthFlag,
+			os.Getenv("CONTROLLER_SESSION_AUTH"),
+			ControllerSessionAuthOpen,
+		))),
+		TokenFormat: strings.ToLower(strings.TrimSpace(firstNonEmpty(
+			*tokenFormatFlag,
+			os.Getenv("TOKEN_FORMAT"),
+			TokenFormatOpaque,
+		))),
+		TokenSigningKey: strings.TrimSpace(firstNonEmpty(
+			*tokenSigningKeyFlag,
+			os.Getenv("TOKEN_SIGNING_KEY"),
+		)),
+		TokenVerifyKeys: parseList(firstNonEmpty(
+			*tokenVerifyKeysFlag,
+			os.Getenv("TOKEN_VERIFY_KEYS"),
+		)),
+		SessionRateLimit: firstPositiveInt(
+			*sessionRateFlag,
+			envToInt("SESSION_RATE_LIMIT"),
+		),
+		SessionRateBurst: firstPositiveInt(
+			*sessionBurstFlag,
+			envToInt("SESSION_RATE_BURST"),
+		),
+		APIRateLimit: firstPositiveInt(
+			*apiRateFlag,
+			envToInt("API_RATE_LIMIT"),
+		),
+		APIRateBurst: firstPositiveInt(
+			*apiBurstFlag,
+			envToInt("API_RATE_BURST"),
+		),
+		LifecycleWebhookURLs: parseList(firstNonEmpty(
+			*lifecycleWebhooksFlag,
+			os.Getenv("LIFECYCLE_WEBHOOK_URLS"),
+		)),
+		LifecycleWebhookSecret: strings.TrimSpace(firstNonEmpty(
+			*lifecycleSecretFlag,
+			os.Getenv("LIFECYCLE_WEBHOOK_SECRET"),
+		)),
+		LifecycleWebhookRetries: firstNonNegativeInt(
+			*lifecycleRetriesFlag,
+			envToOptionalInt("LIFECYCLE_WEBHOOK_RETRIES"),
+			defaultWebhookRetries,
+		),
+		WatchdogInterval: firstPositiveDuration(
+			*watchdogIntervalFlag,
+			envToDuration("WATCHDOG_INTERVAL"),
+		),
+		WatchdogFailures: firstPositiveInt(
+			*watchdogFailuresFlag,
+			envToInt("WATCHDOG_FAILURES"),
+			defaultWatchdogLimit,
+		),
+		LatencyHintInterval: firstPositiveDuration(
+			*latencyHintFlag,
+			envToDuration("LATENCY_HINT_INTERVAL"),
+		),
+		ResultRetryAttempts: firstPositiveInt(
+			*resultRetriesFlag,
+			envToInt("RESULT_RETRY_ATTEMPTS"),
+			defaultResultRetries,
+		),
+		VisitCooldown: firstPositiveDuration(
+			*visitCooldownFlag,
+			envToDuration("VISIT_COOLDOWN"),
+		),
+		VisitDailyLimit: firstPositiveInt(
+			*visitDailyLimitFlag,
+			envToInt("VISIT_DAILY_LIMIT"),
+		),
+		LeaderboardHistory: firstPositiveInt(
+			*leaderboardFlag,
+			envToInt("LEADERBOARD_HISTORY"),
+			defaultLeaderboardSize,
+		),
+		MQTTURL:      strings.TrimSpace(firstNonEmpty(*mqttURLFlag, os.Getenv("MQTT_URL"))),
+		MQTTClientID: strings.TrimSpace(firstNonEmpty(*mqttClientIDFlag, os.Getenv("MQTT_CLIENT_ID"))),
+		MQTTEventTopic: strings.TrimSpace(firstNonEmpty(
+			*mqttEventTopicFlag,
+			os.Getenv("MQTT_EVENT_TOPIC"),
+			defaultMQTTEventTopic,
+		)),
+		MQTTInputTopic: strings.TrimSpace(firstNonEmpty(
+			*mqttInputTopicFlag,
+			os.Getenv("MQTT_INPUT_TOPIC"),
+			defaultMQTTInputTopic,
+		)),
+		MQTTStatusTopic: strings.TrimSpace(firstNonEmpty(
+			*mqttStatusTopicFlag,
+			os.Getenv("MQTT_STATUS_TOPIC"),
+			defaultMQTTStatusTopic,
+		)),
+		MQTTInputInterval: firstPositiveDuration(
+			*mqttInputIntervalFlag,
+			envToDuration("MQTT_INPUT_INTERVAL"),
+		),
+		MQTTQoS:     firstPositiveInt(*mqttQoSFlag, envToInt("MQTT_QOS")),
+		MQTTRetain:  *mqttRetainFlag || envToBool("MQTT_RETAIN"),
+		Compression: *compressionFlag || envToBool("COMPRESSION"),
+		CompressionLevel: firstPositiveInt(
+			*compressionLevelFlag,
+			envToInt("COMPRESSION_LEVEL"),
+			defaultCompressLevel,
+		),
+		CompressionMinSize: firstPositiveInt(
+			*compressionMinSizeFlag,
+			envToInt("COMPRESSION_MIN_SIZE"),
+			defaultCompressMinSize,
+		),
+		StaticHashedNames: *staticHashedNamesFlag || envToBool("STATIC_HASHED_NAMES"),
+		StaticMaxAge:      firstPositiveDuration(*staticMaxAgeFlag, envToDuration("STATIC_MAX_AGE")),
+		SPAFallback:       parseList(firstNonEmpty(*spaFallbackFlag, os.Getenv("SPA_FALLBACK"))),
+		LocalInputDevice:  strings.TrimSpace(firstNonEmpty(*localInputDeviceFlag, os.Getenv("LOCAL_INPUT_DEVICE"))),
+		LocalInputSlot:    strings.TrimSpace(firstNonEmpty(*localInputSlotFlag, os.Getenv("LOCAL_INPUT_SLOT"))),
+
+		StorageURL:  strings.TrimSpace(firstNonEmpty(*storageURLFlag, os.Getenv("STORAGE_URL"))),
+		SocketIO:    *socketIOFlag || envToBool("SOCKETIO"),
+		OSCTarget:   strings.TrimSpace(firstNonEmpty(*oscTargetFlag, os.Getenv("OSC_TARGET"))),
+		OSCPrefix:   strings.TrimSpace(firstNonEmpty(*oscPrefixFlag, os.Getenv("OSC_PREFIX"))),
+		OSCTypes:    parseList(firstNonEmpty(*oscTypesFlag, os.Getenv("OSC_TYPES"))),
+		MIDIDevice:  strings.TrimSpace(firstNonEmpty(*midiDeviceFlag, os.Getenv("MIDI_DEVICE"))),
+		MIDIMap:     strings.TrimSpace(firstNonEmpty(*midiMapFlag, os.Getenv("MIDI_MAP"))),
+		ActivityDir: strings.TrimSpace(firstNonEmpty(*activityDirFlag, os.Getenv("ACTIVITY_DIR"))),
+		SmokeTest:   *smokeTestFlag,
+		GameToken:   strings.TrimSpace(firstNonEmpty(*gameTokenFlag, os.Getenv("GAME_TOKEN"))),
+		LogLevel:    strings.ToLower(strings.TrimSpace(firstNonEmpty(*logLevelFlag, os.Getenv("LOG_LEVEL"), defaultLogLevel))),
+	}
+
+	if raw := firstNonEmpty(*idReservedPrefixesFlag, os.Getenv("ID_RESERVED_PREFIXES")); raw != "" {
+		cfg.IDReservedPrefixes = parseList(raw)
 	}
 
 	if cfg.SessionTokenTTL <= 0 {
 		cfg.SessionTokenTTL = defaultSessionTokenTTL
 	}
 
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.HTTP2 && cfg.TLSCertFile == "" {
+		return Config{}, errors.New("HTTP2 requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	switch cfg.ControllerSessionAuth {
+	case ControllerSessionAuthOpen, ControllerSessionAuthAPIKey:
+	default:
+		return Config{}, fmt.Errorf("invalid CONTROLLER_SESSION_AUTH %q", cfg.ControllerSessionAuth)
+	}
+	switch cfg.TokenFormat {
+	case TokenFormatOpaque:
+		if cfg.TokenSigningKey != "" {
+			return Config{}, errors.New("TOKEN_SIGNING_KEY requires TOKEN_FORMAT=jwt")
+		}
+	case TokenFormatJWT:
+	default:
+		return Config{}, fmt.Errorf("invalid TOKEN_FORMAT %q", cfg.TokenFormat)
+	}
+	if cfg.RelaySigningKey != "" {
+		if !cfg.RelayEnvelope {
+			return Config{}, errors.New("RELAY_SIGNING_KEY requires RELAY_ENVELOPE=true")
+		}
+		if len(cfg.RelaySigningKey) < minRelaySigningKeyLen {
+			return Config{}, fmt.Errorf("RELAY_SIGNING_KEY must be at least %d characters, e.g. `openssl rand -hex 32`", minRelaySigningKeyLen)
+		}
+	}
+	if cfg.PersonaRequired && strings.TrimSpace(cfg.DBBaseURL) == "" {
+		return Config{}, errors.New("PERSONA_REQUIRED requires DB_BASE_URL")
+	}
+	if cfg.LifecycleWebhookSecret != "" && len(cfg.LifecycleWebhookURLs) == 0 {
+		return Config{}, errors.New("LIFECYCLE_WEBHOOK_SECRET requires LIFECYCLE_WEBHOOK_URLS")
+	}
+	if cfg.OSCTarget == "" && (cfg.OSCPrefix != "" || len(cfg.OSCTypes) > 0) {
+		return Config{}, errors.New("OSC_PREFIX and OSC_TYPES require OSC_TARGET")
+	}
+	if cfg.MIDIMap != "" && cfg.MIDIDevice == "" {
+		return Config{}, errors.New("MIDI_MAP requires MIDI_DEVICE")
+	}
+	for _, prefix := range cfg.SPAFallback {
+		if !strings.HasPrefix(prefix, "/") {
+			return Config{}, fmt.Errorf("invalid SPA_FALLBACK prefix %q: must start with /", prefix)
+		}
+	}
+	if (cfg.LocalInputDevice == "") != (cfg.LocalInputSlot == "") {
+		return Config{}, errors.New("LOCAL_INPUT_DEVICE and LOCAL_INPUT_SLOT must be set together")
+	}
+	if cfg.MQTTQoS > 2 {
+		return Config{}, fmt.Errorf("invalid MQTT_QOS %d: must be 0, 1 or 2", cfg.MQTTQoS)
+	}
+	if cfg.CompressionLevel > 9 {
+		return Config{}, fmt.Errorf("invalid COMPRESSION_LEVEL %d: must be 1-9", cfg.CompressionLevel)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return Config{}, fmt.Errorf("invalid LOG_LEVEL %q", cfg.LogLevel)
+	}
+
 	return cfg, nil
 }
 
@@ -111,6 +523,36 @@ func parseOrigins(raw string) []string {
 	return origins
 }
 
+func parseList(raw string) []string {
+	parts := strings.Split(raw, ",")
+	values := make([]string, 0, len(parts))
+	for _, p := range parts {
+		candidate := strings.TrimSpace(p)
+		if candidate != "" {
+			values = append(values, candidate)
+		}
+	}
+	return values
+}
+
+// parseOptionalList is parseList for settings with a non-empty default,
+// where an empty value means "use the default": "none" turns them off.
+func parseOptionalList(raw string) []string {
+	if strings.EqualFold(strings.TrimSpace(raw), "none") {
+		return []string{}
+	}
+	return parseList(raw)
+}
+
+func firstNonNegativeInt(values ...int) int {
+	for _, v := range values {
+		if v >= 0 {
+			return v
+		}
+	}
+	return 0
+}
+
 func firstPositiveInt(values ...int) int {
 	for _, v := range values {
 		if v > 0 {
@@ -141,6 +583,20 @@ func envToInt(key string) int {
 	return v
 }
 
+// envToOptionalInt is envToInt for settings where 0 is meaningful: it
+// returns -1 when key is unset or not a number.
+func envToOptionalInt(key string) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return -1
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
 func envToDuration(key string) time.Duration {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -152,3 +608,15 @@ func envToDuration(key string) time.Duration {
 	}
 	return d
 }
+
+func envToBool(key string) bool {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return false
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false
+	}
+	return v
+}
@@ -0,0 +1,248 @@
+// Package crash keeps the most recent log lines in memory and writes them,
+// with a goroutine dump and the effective configuration, to a crash report
+// when the hub exits on a fatal error or panic. Venue staff usually restart
+// the box before anyone reads the console, so the report is what is left for
+// the postmortem.
+package crash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/buildinfo"
+)
+
+// DefaultRingLines is the number of log lines a Ring keeps by default.
+const DefaultRingLines = 2000
+
+// RuntimeFile is the file in the report directory that receives the Go
+// runtime's own output for panics and fatal errors, e.g. a panic in a
+// goroutine not started through Go. It holds the stack traces but not the
+// log lines.
+const RuntimeFile = "crash-runtime.log"
+
+// Ring is an io.Writer that passes writes through to another writer and
+// keeps copies of the last lines written. It expects whole lines per Write,
+// as slog handlers produce.
+type Ring struct {
+	out io.Writer
+
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+// NewRing returns a Ring keeping the last n lines written to out.
+func NewRing(out io.Writer, n int) *Ring {
+	if n <= 0 {
+		n = DefaultRingLines
+	}
+	return &Ring{out: out, lines: make([][]byte, n)}
+}
+
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.lines[r.next] = append(r.lines[r.next][:0], p...)
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+	return r.out.Write(p)
+}
+
+// WriteTo writes the kept lines, oldest first.
+func (r *Ring) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	var buf bytes.Buffer
+	if r.full {
+		for _, line := range r.lines[r.next:] {
+			buf.Write(line)
+		}
+	}
+	for _, line := range r.lines[:r.next] {
+		buf.Write(line)
+	}
+	r.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+// Reporter writes crash reports into a directory. A nil Reporter, or one
+// with an empty directory, writes nothing.
+type Reporter struct {
+	dir  string
+	ring *Ring
+
+	mu     sync.Mutex
+	config func() map[string]any
+}
+
+// NewReporter returns a Reporter writing into dir with the lines kept by
+// ring. It returns nil when dir is empty.
+func NewReporter(dir string, ring *Ring) *Reporter {
+	if dir == "" {
+		return nil
+	}
+	return &Reporter{dir: dir, ring: ring}
+}
+
+// SetConfig sets the function reports call for the effective configuration.
+func (r *Reporter) SetConfig(config func() map[string]any) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.config = config
+	r.mu.Unlock()
+}
+
+// CaptureRuntime sends the Go runtime's output for unrecovered panics and
+// fatal errors to RuntimeFile, with the stacks of all goroutines. Each
+// crash is appended, so the file survives repeated restarts.
+func (r *Reporter) CaptureRuntime() error {
+	if r == nil {
+		return nil
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(r.dir, RuntimeFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	debug.SetTraceback("all")
+	return debug.SetCrashOutput(file, debug.CrashOptions{})
+}
+
+// Write writes a report named after the current time, e.g.
+// crash-20261017T101500.123Z.log, and returns its path. reason heads the
+// report; stack, when set, is the stack of the panicking goroutine.
+func (r *Reporter) Write(reason string, stack []byte) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	path := filepath.Join(r.dir, "crash-"+now.Format("20060102T150405.000Z")+".log")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "reason: %s\ntime: %s\npid: %d\ngo: %s\n", reason, now.Format(time.RFC3339Nano), os.Getpid(), runtime.Version())
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&buf, "module: %s %s\n", info.Main.Path, info.Main.Version)
+	}
+	fmt.Fprintf(&buf, "build: %s\n", buildinfo.Get())
+	if len(stack) > 0 {
+		buf.WriteString("\n== panic ==\n")
+		buf.Write(stack)
+	}
+
+	buf.WriteString("\n== config ==\n")
+	r.writeConfig(&buf)
+
+	buf.WriteString("\n== logs ==\n")
+	if r.ring != nil {
+		_, _ = r.ring.WriteTo(&buf)
+	}
+
+	buf.WriteString("\n== goroutines ==\n")
+	buf.Write(allStacks())
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeConfig renders the configuration, recovering if collecting it panics
+// so a broken application still leaves the rest of the report.
+func (r *Reporter) writeConfig(buf *bytes.Buffer) {
+	r.mu.Lock()
+	config := r.config
+	r.mu.Unlock()
+	if config == nil {
+		buf.WriteString("unavailable\n")
+		return
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			fmt.Fprintf(buf, "unavailable: %v\n", v)
+		}
+	}()
+	data, err := json.MarshalIndent(config(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(buf, "unavailable: %v\n", err)
+		return
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+}
+
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64<<20 {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+type guard struct {
+	reporter *Reporter
+	logger   *slog.Logger
+}
+
+var installed atomic.Pointer[guard]
+
+// Install makes Recover, and so every goroutine started through Go, write
+// a report with reporter and log it to logger before a panic takes the
+// process down.
+func Install(reporter *Reporter, logger *slog.Logger) {
+	installed.Store(&guard{reporter: reporter, logger: logger})
+}
+
+// Go runs fn in a new goroutine guarded by Recover. The hub's background
+// loops and connection goroutines are started this way, since a panic there
+// ends the process without passing any deferred recover in main.
+func Go(fn func()) {
+	go func() {
+		defer Recover()
+		fn()
+	}()
+}
+
+// Recover, deferred at the top of a goroutine, writes a crash report for a
+// panic unwinding it and then panics again with the same value, so the
+// process still exits and the runtime still writes RuntimeFile.
+func Recover() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if g := installed.Load(); g != nil {
+		g.logger.Error("panic", "panic", fmt.Sprint(v))
+		path, err := g.reporter.Write(fmt.Sprintf("panic: %v", v), debug.Stack())
+		switch {
+		case err != nil:
+			g.logger.Error("crash_report_failed", "err", err.Error())
+		case path != "":
+			g.logger.Error("crash_report_written", "path", path)
+		}
+	}
+	panic(v)
+}
@@ -0,0 +1,29 @@
+package hub
+
+// SetAccepting opens or closes the hub to new controllers. While closed,
+// only controllers for slots that are already assigned (connected, holding
+// a token or restored from state) may register; others are refused with
+// ReasonNotAccepting. Game connections are unaffected.
+func (h *Hub) SetAccepting(accepting bool) {
+	if h.paused.Swap(!accepting) == !accepting {
+		return
+	}
+	h.log.Info("accepting_changed", "accepting", accepting)
+	h.emit("accepting_changed", "", "", "", "accepting", accepting)
+}
+
+// Accepting reports whether new controllers may register.
+func (h *Hub) Accepting() bool {
+	return !h.paused.Load()
+}
+
+// admitWhilePaused reports whether a controller for slotID may register
+// given the accepting switch.
+func (h *Hub) admitWhilePaused(slotID string) bool {
+	if !h.paused.Load() {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.isKnownSlotLocked(slotID)
+}
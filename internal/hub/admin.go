@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"context"
+
+	"nhooyr.io/websocket"
+)
+
+// maxCloseReason is the largest close reason permitted by RFC 6455 (125 byte
+// control frame payload minus the 2 byte status code).
+const maxCloseReason = 123
+
+// DisconnectControllers notifies the selected controllers with reason and
+// closes them. An empty slot list selects every connected controller. The
+// game session is closed too when includeGame is set. It returns the number
+// of controllers disconnected.
+func (h *Hub) DisconnectControllers(ctx context.Context, slots []string, reason string, includeGame bool) int {
+	if reason == "" {
+		reason = "disconnected by operator"
+	}
+
+	filter := make(map[string]struct{}, len(slots))
+	for _, slotID := range slots {
+		filter[slotID] = struct{}{}
+	}
+
+	h.mu.Lock()
+	targets := make([]*controllerSession, 0, len(h.controllers))
+	for slotID, session := range h.controllers {
+		if _, ok := filter[slotID]; len(filter) > 0 && !ok {
+			continue
+		}
+		targets = append(targets, session)
+	}
+	var games []*gameSession
+	if includeGame {
+		games = h.gameListenersLocked()
+	}
+	h.mu.Unlock()
+
+	notice := struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}{Type: "disconnect", Reason: reason}
+	for _, session := range targets {
+		if err := h.writeController(ctx, session, notice); err != nil {
+			session.logger.Debug("disconnect_notice_failed", "err", err.Error())
+		}
+		session.logger.Info("disconnected_by_operator", "reason", reason)
+		_ = session.conn.Close(websocket.StatusNormalClosure, truncateReason(reason))
+	}
+
+	for _, game := range games {
+		game.logger.Info("disconnected_by_operator", "reason", reason)
+		game.close(websocket.StatusNormalClosure, truncateReason(reason))
+	}
+
+	return len(targets)
+}
+
+func truncateReason(reason string) string {
+	if len(reason) <= maxCloseReason {
+		return reason
+	}
+	cut := maxCloseReason
+	// Avoid splitting a multi-byte UTF-8 sequence.
+	for cut > 0 && reason[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return reason[:cut]
+}
@@ -0,0 +1,310 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+const (
+	// msgTypeState marks game frames that carry full state and are paced.
+	msgTypeState = "state"
+	// controllerOutboxSize bounds the non-state frames queued per controller.
+	controllerOutboxSize = 64
+)
+
+// BroadcastStats reports game to controller forwarding.
+type BroadcastStats struct {
+	RateHz    int
+	Forwarded uint64
+	Paced     uint64
+	Dropped   uint64
+	Deltas    uint64
+}
+
+type broadcastCounters struct {
+	forwarded atomic.Uint64
+	paced     atomic.Uint64
+	dropped   atomic.Uint64
+	deltas    atomic.Uint64
+}
+
+// BroadcastStats returns game to controller forwarding statistics.
+func (h *Hub) BroadcastStats() BroadcastStats {
+	return BroadcastStats{
+		RateHz:    h.cfg.BroadcastRateHz,
+		Forwarded: h.broadcast.forwarded.Load(),
+		Paced:     h.broadcast.paced.Load(),
+		Dropped:   h.broadcast.dropped.Load(),
+		Deltas:    h.broadcast.deltas.Load(),
+	}
+}
+
+// controllerOutbox holds frames from the game waiting to be written to one
+// controller. State frames are paced: only the newest pending frame of each
+// channel is kept.
+type controllerOutbox struct {
+	mu       sync.Mutex
+	states   map[string][]byte
+	channels []string
+	events   []outboxEvent
+	notify   chan struct{}
+}
+
+// outboxEvent is a non-state frame; binary is only set for frames relayed
+// verbatim in passthrough mode.
+type outboxEvent struct {
+	data   []byte
+	binary bool
+}
+
+type channelFrame struct {
+	channel string
+	data    []byte
+}
+
+func newControllerOutbox() *controllerOutbox {
+	return &controllerOutbox{
+		states: make(map[string][]byte),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// offer queues a frame and reports whether an older frame was discarded.
+// State frames replace the pending frame of the same channel.
+func (o *controllerOutbox) offer(payload []byte, state bool, channel string) bool {
+	if !state {
+		return o.offerEvent(outboxEvent{data: payload})
+	}
+	o.mu.Lock()
+	_, discarded := o.states[channel]
+	if !discarded {
+		o.channels = append(o.channels, channel)
+	}
+	o.states[channel] = payload
+	o.mu.Unlock()
+
+	o.wake()
+	return discarded
+}
+
+// offerEvent queues a non-state frame and reports whether the oldest pending
+// one was discarded to make room.
+func (o *controllerOutbox) offerEvent(event outboxEvent) bool {
+	o.mu.Lock()
+	discarded := false
+	if len(o.events) >= controllerOutboxSize {
+		o.events = o.events[1:]
+		discarded = true
+	}
+	o.events = append(o.events, event)
+	o.mu.Unlock()
+
+	o.wake()
+	return discarded
+}
+
+// depth returns how many non-state frames are waiting.
+func (o *controllerOutbox) depth() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.events)
+}
+
+func (o *controllerOutbox) wake() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (o *controllerOutbox) takeEvents() []outboxEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	events := o.events
+	o.events = nil
+	return events
+}
+
+// takeStates returns the pending state frames in the order their channels
+// first became pending.
+func (o *controllerOutbox) takeStates() []channelFrame {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	frames := make([]channelFrame, 0, len(o.channels))
+	for _, channel := range o.channels {
+		frames = append(frames, channelFrame{channel: channel, data: o.states[channel]})
+	}
+	o.channels = o.channels[:0]
+	clear(o.states)
+	return frames
+}
+
+func (o *controllerOutbox) hasState() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.channels) > 0
+}
+
+// routeGameMessage forwards a frame received from the game to the
+// controllers named in its "to" field, or to every controller when absent.
+func (h *Hub) routeGameMessage(game *gameSession, payload []byte) {
+	var brief struct {
+		Type    string          `json:"type"`
+		Channel string          `json:"channel"`
+		To      json.RawMessage `json:"to"`
+	}
+	if err := json.Unmarshal(payload, &brief); err != nil {
+		game.logger.Warn("game_payload_invalid", "err", err.Error())
+		return
+	}
+	switch brief.Type {
+	case msgTypeMatchStart:
+		h.gameMatchStarted(game)
+	case msgTypeLobbyLock, msgTypeLobbyUnlock, msgTypeMatchFinish:
+		h.gameMatchSignal(game, brief.Type)
+	}
+
+	var targets map[string]struct{}
+	if len(brief.To) > 0 && string(brief.To) != "null" {
+		var one string
+		var many []string
+		switch {
+		case json.Unmarshal(brief.To, &one) == nil:
+			many = []string{one}
+		case json.Unmarshal(brief.To, &many) == nil:
+		default:
+			game.logger.Warn("game_payload_invalid", "err", "to must be a slot id or list of slot ids")
+			return
+		}
+		targets = make(map[string]struct{}, len(many))
+		for _, id := range many {
+			targets[id] = struct{}{}
+		}
+	}
+
+	if brief.Type == msgTypeCalibrate {
+		h.startCalibration(game, payload, targets)
+		return
+	}
+
+	frame := cloneBytes(payload)
+	state := brief.Type == msgTypeState
+	channel := normalizeChannel(brief.Channel)
+	if !validChannels(channel) {
+		game.logger.Warn("game_payload_invalid", "err", "channel name too long", "max", maxChannelName)
+		return
+	}
+
+	h.mu.Lock()
+	if state && targets == nil {
+		if _, known := h.snapshots[channel]; known || len(h.snapshots) < maxStateChannels {
+			h.snapshots[channel] = frame
+		} else {
+			game.logger.Warn("state_channel_limit", "channel", channel, "max", maxStateChannels)
+		}
+	}
+	sessions := make([]*controllerSession, 0, len(h.controllers))
+	for id, session := range h.controllers {
+		if _, ok := targets[id]; targets != nil && !ok {
+			continue
+		}
+		if state && !session.subscribed(channel) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		if session.outbox.offer(frame, state, channel) {
+			if state {
+				h.broadcast.paced.Add(1)
+			} else {
+				h.broadcast.dropped.Add(1)
+				session.logger.Warn("outbox_drop_oldest")
+			}
+		}
+	}
+}
+
+// runControllerWriter writes game frames to one controller until ctx is done.
+// State frames leave at most BroadcastRateHz times per second, less while
+// shedding; other frames are written as soon as possible.
+func (h *Hub) runControllerWriter(ctx context.Context, session *controllerSession) {
+	var (
+		lastState time.Time
+		timer     *time.Timer
+		timerC    <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	write := func(msgType websocket.MessageType, payload []byte) bool {
+		writeCtx, cancel := context.WithTimeout(ctx, h.cfg.WriteTimeout)
+		defer cancel()
+		if err := session.conn.Write(writeCtx, msgType, payload); err != nil {
+			if ctx.Err() == nil {
+				session.logger.Warn("broadcast_write_failed", "err", err.Error())
+			}
+			return false
+		}
+		h.broadcast.forwarded.Add(1)
+		return true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-session.outbox.notify:
+		case <-timerC:
+			timerC = nil
+		}
+
+		for _, event := range session.outbox.takeEvents() {
+			msgType := websocket.MessageText
+			if event.binary {
+				msgType = websocket.MessageBinary
+			}
+			if !write(msgType, event.data) {
+				return
+			}
+		}
+
+		if !session.outbox.hasState() || timerC != nil {
+			continue
+		}
+		if wait := h.stateInterval() - time.Since(lastState); wait > 0 {
+			if timer == nil {
+				timer = time.NewTimer(wait)
+			} else {
+				timer.Reset(wait)
+			}
+			timerC = timer.C
+			continue
+		}
+		for _, state := range session.outbox.takeStates() {
+			data := state.data
+			if session.delta != nil {
+				encoded, isDelta := session.delta.encode(state.channel, data)
+				if isDelta {
+					h.broadcast.deltas.Add(1)
+				}
+				data = encoded
+			}
+			if !write(websocket.MessageText, data) {
+				return
+			}
+		}
+		session.stateFlushes.Add(1)
+		lastState = time.Now()
+	}
+}
@@ -0,0 +1,271 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Calibration is a request/response exchange the hub mediates between the
+// game and a controller, e.g. zeroing the gyro:
+//
+//	game → hub        {"type":"calibrate","to":"p1","kind":"gyro","timeoutMs":5000}
+//	hub → controller  {"type":"calibrate","kind":"gyro","calibrationId":"cal-7",...}
+//	controller → hub  {"type":"calibration","calibrationId":"cal-7","ok":true,"data":{...}}
+//	hub → game        {"type":"calibration","id":"p1","calibrationId":"cal-7","kind":"gyro","status":"done","data":{...}}
+//
+// The hub answers the game itself with status "timeout" when the controller
+// does not reply in time and "failed" when the slot is not connected. The
+// latest completed calibration of each kind stays on the controller session
+// and is replayed, marked "replay":true, to a game that (re)connects.
+const (
+	msgTypeCalibrate   = "calibrate"
+	msgTypeCalibration = "calibration"
+
+	defaultCalibrationTimeout = 10 * time.Second
+	maxCalibrationTimeout     = 5 * time.Minute
+	maxCalibrationKind        = 32
+)
+
+// CalibrationStatus is the state of a calibration exchange.
+type CalibrationStatus string
+
+const (
+	CalibrationPending CalibrationStatus = "pending"
+	CalibrationDone    CalibrationStatus = "done"
+	CalibrationFailed  CalibrationStatus = "failed"
+	CalibrationTimeout CalibrationStatus = "timeout"
+)
+
+// Calibration is the latest exchange of one kind on a controller session.
+type Calibration struct {
+	ID          string
+	Kind        string
+	Status      CalibrationStatus
+	Data        json.RawMessage
+	Error       string
+	RequestedAt time.Time
+	CompletedAt time.Time
+
+	timer *time.Timer
+}
+
+type calibrationEvent struct {
+	Type          string            `json:"type"`
+	ID            string            `json:"id"`
+	CalibrationID string            `json:"calibrationId"`
+	Kind          string            `json:"kind"`
+	Status        CalibrationStatus `json:"status"`
+	Data          json.RawMessage   `json:"data,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Replay        bool              `json:"replay,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+}
+
+func (c *Calibration) event(slotID string, replay bool) ([]byte, error) {
+	return json.Marshal(calibrationEvent{
+		Type:          msgTypeCalibration,
+		ID:            slotID,
+		CalibrationID: c.ID,
+		Kind:          c.Kind,
+		Status:        c.Status,
+		Data:          c.Data,
+		Error:         c.Error,
+		Replay:        replay,
+		Timestamp:     time.Now().UnixMilli(),
+	})
+}
+
+// startCalibration forwards a calibrate request from the game to the
+// targeted controllers, all of them when targets is nil, stamped with a
+// calibrationId. A newer request of the same kind supersedes a pending one.
+func (h *Hub) startCalibration(game *gameSession, payload []byte, targets map[string]struct{}) {
+	var fields map[string]json.RawMessage
+	var req struct {
+		Kind      string `json:"kind"`
+		TimeoutMs int64  `json:"timeoutMs"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		game.logger.Warn("calibration_invalid", "err", err.Error())
+		return
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		game.logger.Warn("calibration_invalid", "err", err.Error())
+		return
+	}
+	if req.Kind == "" || len(req.Kind) > maxCalibrationKind {
+		game.logger.Warn("calibration_invalid", "err", "kind must be 1-32 characters")
+		return
+	}
+	timeout := h.cfg.CalibrationTimeout
+	if req.TimeoutMs > 0 {
+		timeout = min(time.Duration(req.TimeoutMs)*time.Millisecond, maxCalibrationTimeout)
+	}
+
+	id := fmt.Sprintf("cal-%d", h.calibrationSeq.Add(1))
+	idJSON, _ := json.Marshal(id)
+	fields["calibrationId"] = idJSON
+	frame, err := json.Marshal(fields)
+	if err != nil {
+		game.logger.Warn("calibration_invalid", "err", err.Error())
+		return
+	}
+
+	now := time.Now()
+	var (
+		sessions []*controllerSession
+		missing  []string
+	)
+	h.mu.Lock()
+	for slotID := range targets {
+		if _, ok := h.controllers[slotID]; !ok {
+			missing = append(missing, slotID)
+		}
+	}
+	for slotID, session := range h.controllers {
+		if _, ok := targets[slotID]; targets != nil && !ok {
+			continue
+		}
+		if session.calibrations == nil {
+			session.calibrations = make(map[string]*Calibration)
+		}
+		if previous := session.calibrations[req.Kind]; previous != nil && previous.timer != nil {
+			previous.timer.Stop()
+		}
+		session.calibrations[req.Kind] = &Calibration{
+			ID:          id,
+			Kind:        req.Kind,
+			Status:      CalibrationPending,
+			RequestedAt: now,
+			timer:       time.AfterFunc(timeout, func() { h.expireCalibration(session, req.Kind, id) }),
+		}
+		sessions = append(sessions, session)
+	}
+	h.mu.Unlock()
+
+	game.logger.Info("calibration_requested", "calibration_id", id, "kind", req.Kind, "slots", len(sessions), "timeout", timeout.String())
+	for _, session := range sessions {
+		if session.outbox.offer(frame, false, "") {
+			h.broadcast.dropped.Add(1)
+			session.logger.Warn("outbox_drop_oldest")
+		}
+	}
+	sort.Strings(missing)
+	for _, slotID := range missing {
+		failed := &Calibration{ID: id, Kind: req.Kind, Status: CalibrationFailed, Error: "not_connected"}
+		h.sendCalibration(slotID, failed)
+	}
+}
+
+// recordCalibration completes the pending calibration a controller replied
+// to. Replies that match no pending request, such as late ones after a
+// timeout, are ignored.
+func (h *Hub) recordCalibration(session *controllerSession, payload []byte) {
+	var reply struct {
+		CalibrationID string          `json:"calibrationId"`
+		OK            *bool           `json:"ok"`
+		Data          json.RawMessage `json:"data"`
+		Error         string          `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil {
+		session.logger.Warn("calibration_invalid", "err", err.Error())
+		return
+	}
+
+	h.mu.Lock()
+	var done *Calibration
+	for _, c := range session.calibrations {
+		if c.ID == reply.CalibrationID && c.Status == CalibrationPending {
+			done = c
+			break
+		}
+	}
+	if done != nil {
+		done.timer.Stop()
+		done.Status = CalibrationDone
+		if reply.OK != nil && !*reply.OK {
+			done.Status = CalibrationFailed
+			done.Error = reply.Error
+		}
+		done.Data = append(json.RawMessage(nil), reply.Data...)
+		done.CompletedAt = time.Now()
+		copied := *done
+		done = &copied
+	}
+	h.mu.Unlock()
+
+	if done == nil {
+		session.logger.Debug("calibration_unexpected", "calibration_id", reply.CalibrationID)
+		return
+	}
+	session.logger.Info("calibration_completed", "calibration_id", done.ID, "kind", done.Kind, "status", string(done.Status),
+		"duration_ms", done.CompletedAt.Sub(done.RequestedAt).Milliseconds())
+	h.emit("calibration_completed", roleController, session.id, session.remoteIP, "kind", done.Kind, "status", string(done.Status))
+	h.sendCalibration(session.id, done)
+}
+
+func (h *Hub) expireCalibration(session *controllerSession, kind, id string) {
+	h.mu.Lock()
+	c := session.calibrations[kind]
+	current := h.controllers[session.id] == session
+	if c == nil || c.ID != id || c.Status != CalibrationPending {
+		h.mu.Unlock()
+		return
+	}
+	c.Status = CalibrationTimeout
+	c.CompletedAt = time.Now()
+	expired := *c
+	h.mu.Unlock()
+
+	// A controller that left already produced controller_left.
+	if !current {
+		return
+	}
+	session.logger.Warn("calibration_timeout", "calibration_id", id, "kind", kind)
+	h.emit("calibration_completed", roleController, session.id, session.remoteIP, "kind", kind, "status", string(CalibrationTimeout))
+	h.sendCalibration(session.id, &expired)
+}
+
+func (h *Hub) sendCalibration(slotID string, c *Calibration) {
+	payload, err := c.event(slotID, false)
+	if err != nil {
+		h.log.Error("calibration_event_encode_failed", "err", err.Error())
+		return
+	}
+	h.enqueueToListeners(payload)
+}
+
+// replayCalibrationsLocked queues the completed calibrations of every
+// connected controller to a newly registered game listener, after the
+// roster. The caller must hold h.mu.
+func (h *Hub) replayCalibrationsLocked(game *gameSession) {
+	slots := make([]string, 0, len(h.controllers))
+	for slotID := range h.controllers {
+		slots = append(slots, slotID)
+	}
+	sort.Strings(slots)
+	for _, slotID := range slots {
+		for _, c := range h.controllers[slotID].calibrationsLocked() {
+			if c.Status != CalibrationDone {
+				continue
+			}
+			if payload, err := c.event(slotID, true); err == nil {
+				game.enqueue(payload, "server")
+			}
+		}
+	}
+}
+
+// calibrationsLocked returns copies of the session's calibrations ordered by
+// kind. The caller must hold Hub.mu.
+func (c *controllerSession) calibrationsLocked() []Calibration {
+	out := make([]Calibration, 0, len(c.calibrations))
+	for _, cal := range c.calibrations {
+		copied := *cal
+		copied.timer = nil
+		out = append(out, copied)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kind < out[j].Kind })
+	return out
+}
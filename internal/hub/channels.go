@@ -0,0 +1,88 @@
+package hub
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultChannel names the state channel of frames without a "channel" field.
+const defaultChannel = "state"
+
+const (
+	// maxSubscriptions bounds the channels a controller may subscribe to.
+	maxSubscriptions = 32
+	// maxStateChannels bounds the channels the hub keeps a snapshot or a
+	// delta encoder for; further channels are still relayed, in full.
+	maxStateChannels = 32
+	// maxChannelName bounds a channel name in bytes.
+	maxChannelName = 64
+)
+
+func normalizeChannel(channel string) string {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if channel == "" {
+		return defaultChannel
+	}
+	return channel
+}
+
+// validChannels reports whether every name fits maxChannelName.
+func validChannels(channels ...string) bool {
+	for _, channel := range channels {
+		if len(normalizeChannel(channel)) > maxChannelName {
+			return false
+		}
+	}
+	return true
+}
+
+// newSubscriptions builds a controller's channel filter from its register
+// payload. A nil result subscribes to every channel.
+func newSubscriptions(channels []string) map[string]struct{} {
+	if channels == nil {
+		return nil
+	}
+	subs := make(map[string]struct{}, len(channels))
+	for _, channel := range channels {
+		subs[normalizeChannel(channel)] = struct{}{}
+	}
+	return subs
+}
+
+func (c *controllerSession) subscribed(channel string) bool {
+	if c.channels == nil {
+		return true
+	}
+	_, ok := c.channels[channel]
+	return ok
+}
+
+// Channels lists the state channels that currently hold a snapshot, sorted.
+func (h *Hub) Channels() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	channels := make([]string, 0, len(h.snapshots))
+	for channel := range h.snapshots {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// replaySnapshots queues the latest snapshot of every channel the controller
+// subscribes to, so a late joiner does not wait for the next game publish.
+func (h *Hub) replaySnapshots(session *controllerSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for channel, frame := range h.snapshots {
+		if session.subscribed(channel) {
+			session.outbox.offer(frame, true, channel)
+		}
+	}
+}
+
+// clearSnapshotsLocked forgets every channel snapshot; they belong to the
+// game session that published them.
+func (h *Hub) clearSnapshotsLocked() {
+	clear(h.snapshots)
+}
@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoFreeSlot indicates that every controller slot is connected or reserved.
+	ErrNoFreeSlot = errors.New("no free slot")
+	// ErrSlotTaken indicates that the requested slot is connected or reserved.
+	ErrSlotTaken = errors.New("slot already taken")
+)
+
+// SlotClaim is a reservation made without Persona.
+type SlotClaim struct {
+	SlotID    string
+	UserID    string
+	Name      string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// SlotIDs lists the controller slots p1..pN served by the hub.
+func (h *Hub) SlotIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.slotIDsLocked()
+}
+
+func (h *Hub) slotIDsLocked() []string {
+	slots := make([]string, 0, h.cfg.MaxControllers)
+	for i := 1; i <= h.cfg.MaxControllers; i++ {
+		slots = append(slots, fmt.Sprintf("p%d", i))
+	}
+	return slots
+}
+
+// ClaimSlot reserves a slot for a local player and issues a controller token
+// bound to it, for deployments without Persona. An empty slotID takes the
+// lowest free slot. The player gets a generated user id so results and
+// assignments can still tell players apart.
+func (h *Hub) ClaimSlot(slotID, name, cohort string, ttl time.Duration) (SlotClaim, error) {
+	slotID = strings.ToLower(strings.TrimSpace(slotID))
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return SlotClaim{}, errors.New("name required")
+	}
+	if cohort != "" {
+		resolved, err := h.resolveCohort(cohort)
+		if err != nil {
+			return SlotClaim{}, err
+		}
+		cohort = resolved.Name
+	}
+
+	userID, err := generateLocalUserID()
+	if err != nil {
+		return SlotClaim{}, fmt.Errorf("generate user id: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cleanupExpiredTokensLocked(time.Now())
+
+	if slotID == "" {
+		for _, candidate := range h.slotIDsLocked() {
+			if !h.isKnownSlotLocked(candidate) {
+				slotID = candidate
+				break
+			}
+		}
+		if slotID == "" {
+			return SlotClaim{}, ErrNoFreeSlot
+		}
+	} else {
+		if !h.isServedSlot(slotID) {
+			return SlotClaim{}, fmt.Errorf("invalid slot id %q", slotID)
+		}
+		if h.isKnownSlotLocked(slotID) {
+			return SlotClaim{}, fmt.Errorf("%w: %s", ErrSlotTaken, slotID)
+		}
+	}
+
+	profile := userProfile{ID: userID, Name: name}
+	tokenValue, expiresAt, err := h.storeTokenLocked(slotID, profile, cohort, ttl)
+	if err != nil {
+		return SlotClaim{}, err
+	}
+	h.log.Info("slot_claimed", "slot", slotID, "user_id", userID)
+
+	return SlotClaim{
+		SlotID:    slotID,
+		UserID:    userID,
+		Name:      name,
+		Token:     tokenValue,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (h *Hub) isServedSlot(slotID string) bool {
+	for _, candidate := range h.slotIDsLocked() {
+		if candidate == slotID {
+			return true
+		}
+	}
+	return false
+}
+
+func generateLocalUserID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "local-" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,71 @@
+package hub
+
+import "nhooyr.io/websocket"
+
+// Application close codes, in the 4000-4999 range RFC 6455 leaves to
+// applications. The hub sends each with the matching Reason constant as the
+// close frame's reason text, so clients can branch on either. Transport
+// level failures such as a binary frame or a relay queue overflow keep their
+// standard codes.
+const (
+	CloseRegisterInvalid websocket.StatusCode = 4000
+	CloseTokenExpired    websocket.StatusCode = 4001
+	CloseTokenInvalid    websocket.StatusCode = 4002
+	CloseSlotTaken       websocket.StatusCode = 4003
+	CloseReplaced        websocket.StatusCode = 4004
+	CloseIdleTimeout     websocket.StatusCode = 4005
+	CloseKicked          websocket.StatusCode = 4006
+	CloseBanned          websocket.StatusCode = 4007
+	CloseOverloaded      websocket.StatusCode = 4008
+	CloseNotAccepting    websocket.StatusCode = 4009
+)
+
+// Machine-readable close reasons, one per application close code.
+const (
+	// ReasonRegisterInvalid: the register message was malformed, named an
+	// unknown role or cohort, or carried an unacceptable id.
+	ReasonRegisterInvalid = "register_invalid"
+	// ReasonTokenExpired: the controller token has expired; claim a new one.
+	ReasonTokenExpired = "token_expired"
+	// ReasonTokenInvalid: the controller or game token was not recognised or
+	// names a different slot.
+	ReasonTokenInvalid = "token_invalid"
+	// ReasonSlotTaken: no slot or listener place is free.
+	ReasonSlotTaken = "slot_taken"
+	// ReasonReplaced: a newer connection took over this slot or game role.
+	ReasonReplaced = "replaced"
+	// ReasonIdleTimeout: the controller sent nothing for IdleTimeout.
+	ReasonIdleTimeout = "idle_timeout"
+	// ReasonKicked: an operator kicked the slot, which is cooling down.
+	ReasonKicked = "kicked"
+	// ReasonBanned: the user or address is banned.
+	ReasonBanned = "banned"
+	// ReasonOverloaded: the hub is shedding load; retry later.
+	ReasonOverloaded = "overloaded"
+	// ReasonNotAccepting: staff closed the hub to new controllers.
+	ReasonNotAccepting = "not_accepting"
+)
+
+var closeCodes = map[string]websocket.StatusCode{
+	ReasonRegisterInvalid: CloseRegisterInvalid,
+	ReasonTokenExpired:    CloseTokenExpired,
+	ReasonTokenInvalid:    CloseTokenInvalid,
+	ReasonSlotTaken:       CloseSlotTaken,
+	ReasonReplaced:        CloseReplaced,
+	ReasonIdleTimeout:     CloseIdleTimeout,
+	ReasonKicked:          CloseKicked,
+	ReasonBanned:          CloseBanned,
+	ReasonOverloaded:      CloseOverloaded,
+	ReasonNotAccepting:    CloseNotAccepting,
+}
+
+// CloseCodeFor returns the application close code sent with reason.
+func CloseCodeFor(reason string) (websocket.StatusCode, bool) {
+	code, ok := closeCodes[reason]
+	return code, ok
+}
+
+// closeWith returns the close code and reason text for reason.
+func closeWith(reason string) (websocket.StatusCode, string) {
+	return closeCodes[reason], reason
+}
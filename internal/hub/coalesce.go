@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+type coalesceKey struct {
+	controllerID string
+	msgType      string
+}
+
+// inputCoalescer buffers controller frames for one relay tick, keeping only
+// the newest frame per (controller, type). A replaced frame is moved to the
+// end of the buffer so frames still leave in hub sequence order.
+type inputCoalescer struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []queuedFrame
+	index   map[coalesceKey]int
+}
+
+func newInputCoalescer(enabled bool, interval time.Duration) *inputCoalescer {
+	if !enabled || interval <= 0 {
+		return nil
+	}
+	return &inputCoalescer{
+		interval: interval,
+		index:    make(map[coalesceKey]int),
+	}
+}
+
+// add buffers a frame and reports whether it replaced an older one.
+func (c *inputCoalescer) add(frame queuedFrame, msgType string) bool {
+	key := coalesceKey{controllerID: frame.controllerID, msgType: msgType}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, replaced := c.index[key]
+	if replaced {
+		c.pending[i] = queuedFrame{}
+	}
+	c.index[key] = len(c.pending)
+	c.pending = append(c.pending, frame)
+	return replaced
+}
+
+func (c *inputCoalescer) take() []queuedFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) == 0 {
+		return nil
+	}
+	frames := c.pending
+	c.pending = nil
+	clear(c.index)
+	return frames
+}
+
+func (g *gameSession) runCoalesceFlush() {
+	ticker := time.NewTicker(g.coalesce.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, frame := range g.coalesce.take() {
+				if frame.data == nil {
+					continue
+				}
+				g.enqueue(frame.data, frame.controllerID)
+			}
+		}
+	}
+}
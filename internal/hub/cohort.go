@@ -0,0 +1,164 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultCohort labels sessions that are not part of any experiment.
+const defaultCohort = "default"
+
+// ErrUnknownCohort indicates a cohort label that is not configured.
+var ErrUnknownCohort = errors.New("unknown cohort")
+
+// Cohort describes the relay behaviour trialled for an experiment group.
+type Cohort struct {
+	Name string
+	// Coalesce enables per-tick input coalescing for the cohort's frames.
+	Coalesce bool
+	// Compress negotiates permessage-deflate. Compression is fixed during the
+	// upgrade, so it only applies when the cohort is given as the "cohort"
+	// query parameter on /ws.
+	Compress bool
+}
+
+// CohortStats reports relay metrics for one cohort.
+type CohortStats struct {
+	Cohort      string
+	Connections int64
+	Frames      uint64
+	Bytes       uint64
+	Merged      uint64
+	Stale       uint64
+}
+
+type cohortCounters struct {
+	connections atomic.Int64
+	frames      atomic.Uint64
+	bytes       atomic.Uint64
+	merged      atomic.Uint64
+	stale       atomic.Uint64
+}
+
+// ParseCohorts reads cohort definitions of the form
+// "name:coalesce=on,compress=off;other:coalesce=off". Options that are not
+// listed default to off.
+func ParseCohorts(raw string) (map[string]Cohort, error) {
+	cohorts := make(map[string]Cohort)
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(entry, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == defaultCohort {
+			return nil, fmt.Errorf("invalid cohort name in %q", entry)
+		}
+		if _, dup := cohorts[name]; dup {
+			return nil, fmt.Errorf("duplicate cohort %q", name)
+		}
+
+		cohort := Cohort{Name: name}
+		for _, opt := range strings.Split(opts, ",") {
+			opt = strings.TrimSpace(opt)
+			if opt == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(opt, "=")
+			var on bool
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "on", "true", "1", "yes":
+				on = true
+			case "off", "false", "0", "no":
+			default:
+				return nil, fmt.Errorf("cohort %q: invalid value for %s", name, key)
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "coalesce":
+				cohort.Coalesce = on
+			case "compress":
+				cohort.Compress = on
+			default:
+				return nil, fmt.Errorf("cohort %q: unknown option %q", name, key)
+			}
+		}
+		cohorts[name] = cohort
+	}
+	return cohorts, nil
+}
+
+// resolveCohort maps a requested label onto a configured cohort. An empty
+// label resolves to the default cohort.
+func (h *Hub) resolveCohort(label string) (Cohort, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" || label == defaultCohort {
+		return Cohort{Name: defaultCohort, Coalesce: h.cfg.CoalesceInput}, nil
+	}
+	cohort, ok := h.cfg.Cohorts[label]
+	if !ok {
+		return Cohort{}, fmt.Errorf("%w: %s", ErrUnknownCohort, label)
+	}
+	return cohort, nil
+}
+
+// CheckCohort reports ErrUnknownCohort for a label IssueControllerToken
+// would refuse, so batch callers can fail before issuing anything.
+func (h *Hub) CheckCohort(label string) error {
+	_, err := h.resolveCohort(label)
+	return err
+}
+
+func (h *Hub) cohortCounters(name string) *cohortCounters {
+	if c, ok := h.cohortStats[name]; ok {
+		return c
+	}
+	return h.cohortStats[defaultCohort]
+}
+
+// CohortStats returns relay metrics for every configured cohort, the default
+// cohort first.
+func (h *Hub) CohortStats() []CohortStats {
+	names := make([]string, 0, len(h.cohortStats))
+	for name := range h.cohortStats {
+		if name != defaultCohort {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{defaultCohort}, names...)
+
+	stats := make([]CohortStats, 0, len(names))
+	for _, name := range names {
+		c := h.cohortStats[name]
+		stats = append(stats, CohortStats{
+			Cohort:      name,
+			Connections: c.connections.Load(),
+			Frames:      c.frames.Load(),
+			Bytes:       c.bytes.Load(),
+			Merged:      c.merged.Load(),
+			Stale:       c.stale.Load(),
+		})
+	}
+	return stats
+}
+
+func newCohortStats(cohorts map[string]Cohort) map[string]*cohortCounters {
+	stats := map[string]*cohortCounters{defaultCohort: {}}
+	for name := range cohorts {
+		stats[name] = &cohortCounters{}
+	}
+	return stats
+}
+
+func anyCohortCoalesces(cohorts map[string]Cohort) bool {
+	for _, cohort := range cohorts {
+		if cohort.Coalesce {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+const (
+	msgTypeStateDelta = "state_delta"
+	msgTypeStateAck   = "state_ack"
+	// deltaHistory is how many unacknowledged snapshots are kept per
+	// controller as potential delta bases.
+	deltaHistory = 16
+)
+
+type stateDeltaFrame struct {
+	Type    string         `json:"type"`
+	Channel string         `json:"channel"`
+	Rev     uint64         `json:"rev"`
+	Base    uint64         `json:"base"`
+	Patch   map[string]any `json:"patch"`
+}
+
+type deltaSnapshot struct {
+	rev   uint64
+	state map[string]any
+}
+
+// deltaSet keeps one deltaEncoder per state channel of a controller.
+type deltaSet struct {
+	mu       sync.Mutex
+	encoders map[string]*deltaEncoder
+}
+
+func newDeltaSet() *deltaSet {
+	return &deltaSet{encoders: make(map[string]*deltaEncoder)}
+}
+
+// encoder returns the encoder of channel, creating it while the set holds
+// fewer than maxStateChannels. It returns nil past the limit.
+func (s *deltaSet) encoder(channel string) *deltaEncoder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enc, ok := s.encoders[channel]
+	if !ok && len(s.encoders) < maxStateChannels {
+		enc = &deltaEncoder{channel: channel}
+		s.encoders[channel] = enc
+	}
+	return enc
+}
+
+// encode is deltaEncoder.encode for channel. Channels past the limit are
+// sent in full without a rev.
+func (s *deltaSet) encode(channel string, payload []byte) ([]byte, bool) {
+	enc := s.encoder(channel)
+	if enc == nil {
+		return payload, false
+	}
+	return enc.encode(payload)
+}
+
+// ack forwards a controller's acknowledgement. Only channels the hub has
+// sent are known, so an ack cannot add encoders.
+func (s *deltaSet) ack(channel string, rev uint64) {
+	s.mu.Lock()
+	enc := s.encoders[normalizeChannel(channel)]
+	s.mu.Unlock()
+	if enc != nil {
+		enc.ack(rev)
+	}
+}
+
+// deltaEncoder turns state frames of one channel into JSON merge patches
+// (RFC 7386) against the newest snapshot the controller acknowledged. Every
+// frame it emits carries a "rev"; the controller answers with
+// {"type":"state_ack","channel":C,"rev":N} once it applied a frame.
+type deltaEncoder struct {
+	channel string
+
+	mu      sync.Mutex
+	rev     uint64
+	acked   uint64
+	history []deltaSnapshot
+}
+
+// encode returns the frame to send for a state payload and whether it is a
+// delta rather than a full snapshot.
+func (d *deltaEncoder) encode(payload []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var state map[string]any
+	if err := decoder.Decode(&state); err != nil {
+		return payload, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rev++
+	rev := d.rev
+	var base *deltaSnapshot
+	for i := range d.history {
+		if d.history[i].rev == d.acked {
+			base = &d.history[i]
+			break
+		}
+	}
+	if len(d.history) >= deltaHistory {
+		d.history = d.history[1:]
+	}
+	d.history = append(d.history, deltaSnapshot{rev: rev, state: state})
+
+	full := withRev(payload, rev)
+	if base == nil {
+		return full, false
+	}
+	patch, ok := mergeDiff(base.state, state)
+	if !ok {
+		return full, false
+	}
+	encoded, err := json.Marshal(stateDeltaFrame{Type: msgTypeStateDelta, Channel: d.channel, Rev: rev, Base: base.rev, Patch: patch})
+	if err != nil || len(encoded) >= len(full) {
+		return full, false
+	}
+	return encoded, true
+}
+
+// ack records that the controller holds the snapshot rev.
+func (d *deltaEncoder) ack(rev uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if rev <= d.acked || rev > d.rev {
+		return
+	}
+	d.acked = rev
+	for len(d.history) > 0 && d.history[0].rev < rev {
+		d.history = d.history[1:]
+	}
+}
+
+func withRev(payload []byte, rev uint64) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	fields["rev"] = json.RawMessage(strconv.FormatUint(rev, 10))
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return encoded
+}
+
+// mergeDiff builds a merge patch turning prev into next. It reports false
+// when next contains a null value, which a merge patch cannot express.
+func mergeDiff(prev, next map[string]any) (map[string]any, bool) {
+	patch := make(map[string]any)
+	for key, value := range next {
+		if value == nil {
+			return nil, false
+		}
+		old, exists := prev[key]
+		if exists && reflect.DeepEqual(old, value) {
+			continue
+		}
+		oldObj, oldIsObj := old.(map[string]any)
+		newObj, newIsObj := value.(map[string]any)
+		if exists && oldIsObj && newIsObj {
+			nested, ok := mergeDiff(oldObj, newObj)
+			if !ok {
+				return nil, false
+			}
+			patch[key] = nested
+			continue
+		}
+		if containsNull(value) {
+			return nil, false
+		}
+		patch[key] = value
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	return patch, true
+}
+
+func containsNull(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		for _, item := range v {
+			if containsNull(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if containsNull(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package hub
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+const msgTypeRelay = "relay"
+
+// relayEnvelope wraps a controller frame with what the hub knows about its
+// sender, so a game can attribute input without trusting fields the
+// controller wrote itself. Seq matches the hubSeq stamped into Payload.
+type relayEnvelope struct {
+	Type       string          `json:"type"`
+	SlotID     string          `json:"slotId"`
+	UserID     string          `json:"userId,omitempty"`
+	ReceivedAt int64           `json:"receivedAt"`
+	Seq        uint64          `json:"seq"`
+	Payload    json.RawMessage `json:"payload"`
+
+	// LatencyMs is the estimated one-way latency of the sender; omitted
+	// until the first ping was answered. See latency.go.
+	LatencyMs *int64 `json:"latencyMs,omitempty"`
+}
+
+// wrapRelay returns payload inside a relay envelope when Config.Envelope is
+// set, and payload unchanged otherwise.
+func (h *Hub) wrapRelay(session *controllerSession, payload []byte, received time.Time, seq uint64) ([]byte, error) {
+	if !h.cfg.Envelope {
+		return payload, nil
+	}
+	// A slot handoff may swap the user mid-session.
+	h.mu.Lock()
+	userID := session.user.ID
+	h.mu.Unlock()
+
+	envelope := relayEnvelope{
+		Type:       msgTypeRelay,
+		SlotID:     session.id,
+		UserID:     userID,
+		ReceivedAt: received.UnixMilli(),
+		Seq:        seq,
+		Payload:    payload,
+	}
+	if hint, ok := session.latency.hint(); ok {
+		envelope.LatencyMs = &hint.OneWayMs
+	}
+	frame, err := json.Marshal(envelope)
+	if err != nil || len(h.cfg.EnvelopeKey) == 0 {
+		return frame, err
+	}
+	return signEnvelope(h.cfg.EnvelopeKey, frame), nil
+}
+
+// signEnvelope appends a "sig" member as the last field of frame: the
+// base64url (unpadded) HMAC-SHA256 of the frame bytes before it was added.
+// The game verifies by cutting the trailing `,"sig":"..."` off the text it
+// received and recomputing the MAC over the rest, so no JSON
+// canonicalisation is involved. seq and receivedAt are covered, which lets
+// the game reject replayed frames as well as injected or altered ones.
+func signEnvelope(key, frame []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(frame)
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	signed := make([]byte, 0, len(frame)+len(sig)+9)
+	signed = append(signed, frame[:len(frame)-1]...)
+	signed = append(signed, `,"sig":"`...)
+	signed = append(signed, sig...)
+	return append(signed, `"}`...)
+}
@@ -0,0 +1,122 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+type epochNotice struct {
+	Type  string `json:"type"`
+	Epoch uint64 `json:"epoch"`
+}
+
+// Epoch returns the current game generation. It advances whenever a game
+// session connects and whenever a match is started, so controller frames
+// produced for an earlier game or match can be recognised and dropped.
+func (h *Hub) Epoch() uint64 {
+	return h.epoch.Load()
+}
+
+// advanceEpoch starts a new generation, discards controller frames still
+// buffered for game and its mirrors, and tells every controller the new epoch so it can tag
+// subsequent frames.
+func (h *Hub) advanceEpoch(game *gameSession, cause string) uint64 {
+	epoch := h.epoch.Add(1)
+
+	h.mu.Lock()
+	var listeners []*gameSession
+	if game != nil {
+		listeners = append(listeners, game)
+		for mirror := range h.mirrors {
+			listeners = append(listeners, mirror)
+		}
+	}
+	controllers := make([]*controllerSession, 0, len(h.controllers))
+	for _, session := range h.controllers {
+		controllers = append(controllers, session)
+	}
+	h.mu.Unlock()
+
+	for _, listener := range listeners {
+		if n := listener.discardInput(); n > 0 {
+			h.drops.stale.Add(uint64(n))
+			listener.logger.Info("stale_input_discarded", "frames", n, "epoch", epoch)
+		}
+	}
+
+	h.log.Info("epoch_advanced", "epoch", epoch, "cause", cause, "controllers", len(controllers))
+	h.emit("epoch_advanced", "", "", "", "epoch", epoch, "cause", cause)
+	for _, session := range controllers {
+		crash.Go(func() { h.sendEpoch(context.Background(), session, epoch) })
+	}
+	return epoch
+}
+
+func (h *Hub) sendEpoch(ctx context.Context, session *controllerSession, epoch uint64) {
+	if h.cfg.Passthrough {
+		return
+	}
+	if err := h.writeController(ctx, session, epochNotice{Type: "epoch", Epoch: epoch}); err != nil {
+		session.logger.Debug("epoch_notice_failed", "err", err.Error())
+	}
+}
+
+// isStaleEpoch reports whether a frame tagged with epoch belongs to an
+// earlier generation. Untagged frames (epoch 0) are never stale.
+func (h *Hub) isStaleEpoch(epoch uint64) bool {
+	return epoch != 0 && epoch < h.epoch.Load()
+}
+
+// restampEpoch rewrites the epoch of a stamped controller frame. Inside a
+// relay envelope the inner payload is restamped and the envelope signed
+// again. Frames that are not JSON objects, such as passthrough frames, are
+// returned unchanged.
+func (h *Hub) restampEpoch(payload []byte, epoch uint64) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) != nil {
+		return payload
+	}
+	envelope := string(fields["type"]) == `"`+msgTypeRelay+`"`
+	if inner, ok := fields["payload"]; envelope && ok {
+		fields["payload"] = h.restampEpoch(inner, epoch)
+		delete(fields, "sig")
+	} else {
+		fields["epoch"] = json.RawMessage(strconv.FormatUint(epoch, 10))
+	}
+	stamped, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	if envelope && len(h.cfg.EnvelopeKey) > 0 {
+		return signEnvelope(h.cfg.EnvelopeKey, stamped)
+	}
+	return stamped
+}
+
+// discardInput drops every queued or coalescing controller frame, keeping
+// hub-originated events. It returns the number of frames removed.
+func (g *gameSession) discardInput() int {
+	isInput := func(frame queuedFrame) bool {
+		return frame.controllerID != "server"
+	}
+
+	g.queueMu.Lock()
+	before := len(g.queue) + len(g.priority)
+	g.queue = slices.DeleteFunc(g.queue, isInput)
+	g.priority = slices.DeleteFunc(g.priority, isInput)
+	n := before - len(g.queue) - len(g.priority)
+	g.queueMu.Unlock()
+
+	if g.coalesce != nil {
+		for _, frame := range g.coalesce.take() {
+			if frame.data != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package hub
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is a notable hub occurrence published on the internal event bus.
+// Types mirror the matching log messages, e.g. "connected", "ban_added".
+type Event struct {
+	Time     time.Time      `json:"time"`
+	Type     string         `json:"type"`
+	Role     string         `json:"role,omitempty"`
+	ID       string         `json:"id,omitempty"`
+	RemoteIP string         `json:"remoteIp,omitempty"`
+	Fields   map[string]any `json:"fields,omitempty"`
+}
+
+// EventSubscription receives hub events until Close is called. Events are
+// dropped rather than blocking the hub when the subscriber falls behind.
+type EventSubscription struct {
+	bus     *eventBus
+	ch      chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// C returns the channel events are delivered on. It is closed by Close.
+func (s *EventSubscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped reports how many events were discarded because C was full.
+func (s *EventSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close detaches the subscription from the bus.
+func (s *EventSubscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		close(s.ch)
+		s.bus.mu.Unlock()
+	})
+}
+
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*EventSubscription]struct{}
+}
+
+// SubscribeEvents attaches a new subscriber buffering up to buffer events.
+func (h *Hub) SubscribeEvents(buffer int) *EventSubscription {
+	return h.events.subscribe(buffer)
+}
+
+// PublishEvent publishes an application level event, such as an operator
+// alert, alongside the hub's own connection events.
+func (h *Hub) PublishEvent(eventType string, kv ...any) {
+	h.emit(eventType, "", "", "", kv...)
+}
+
+// emit publishes an event to every subscriber. kv are alternating key/value
+// pairs stored in Event.Fields.
+func (h *Hub) emit(eventType, role, id, remote string, kv ...any) {
+	h.events.publish(eventType, role, id, remote, kv...)
+}
+
+func (b *eventBus) subscribe(buffer int) *EventSubscription {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	sub := &EventSubscription{bus: b, ch: make(chan Event, buffer)}
+
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*EventSubscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *eventBus) publish(eventType, role, id, remote string, kv ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	ev := Event{
+		Time:     time.Now().UTC(),
+		Type:     eventType,
+		Role:     role,
+		ID:       id,
+		RemoteIP: remote,
+	}
+	if len(kv) > 1 {
+		ev.Fields = make(map[string]any, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			if key, ok := kv[i].(string); ok {
+				ev.Fields[key] = kv[i+1]
+			}
+		}
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,795 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// Fake is an in-memory stand-in for Hub that never opens a WebSocket. It
+// keeps slot assignments, tokens, bans and events in maps so HTTP handlers
+// built on top of the hub can be exercised without real connections.
+// Controllers are "connected" with Connect and Disconnect; injected frames
+// and game start notifications are recorded for inspection.
+type Fake struct {
+	events eventBus
+
+	mu             sync.Mutex
+	maxControllers int
+	slots          map[string]*fakeSlot
+	tokens         map[string]issuedToken
+	bans           map[string]time.Time
+	profiles       map[string][]string
+	slotMeta       map[string]map[string]string
+	changed        chan struct{}
+	matchStart     time.Time
+	match          matchMachine
+	gameConnected  bool
+	paused         bool
+	tap            RelayTap
+	frames         []FakeFrame
+	starts         [][]string
+	countdowns     []time.Duration
+	shutdown       bool
+}
+
+type fakeSlot struct {
+	assignment ControllerAssignment
+	token      string
+}
+
+// FakeFrame is a frame handed to the Fake by InjectFrame.
+type FakeFrame struct {
+	SlotID  string
+	Type    string
+	Payload []byte
+}
+
+// NewFake returns a Fake serving slots p1..pN for maxControllers N, or four
+// slots when maxControllers is not positive.
+func NewFake(maxControllers int) *Fake {
+	if maxControllers <= 0 {
+		maxControllers = 4
+	}
+	return &Fake{
+		maxControllers: maxControllers,
+		slots:          make(map[string]*fakeSlot),
+		tokens:         make(map[string]issuedToken),
+		bans:           make(map[string]time.Time),
+		profiles:       make(map[string][]string),
+		slotMeta:       make(map[string]map[string]string),
+		changed:        make(chan struct{}),
+	}
+}
+
+// Connect marks slotID as having a live controller.
+func (f *Fake) Connect(slotID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slot := f.slotLocked(slotID)
+	slot.assignment.Connected = true
+	slot.assignment.LastSeen = time.Now()
+	f.notifyLocked()
+	f.events.publish("connected", roleController, slot.assignment.SlotID, "")
+}
+
+// Disconnect marks slotID as no longer connected.
+func (f *Fake) Disconnect(slotID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slot, ok := f.slots[normalizeFakeSlot(slotID)]; ok && slot.assignment.Connected {
+		slot.assignment.Connected = false
+		f.notifyLocked()
+		f.events.publish("disconnected", roleController, slot.assignment.SlotID, "")
+	}
+}
+
+// SetGameConnected sets whether a game appears to be connected.
+func (f *Fake) SetGameConnected(connected bool) {
+	f.mu.Lock()
+	f.gameConnected = connected
+	f.mu.Unlock()
+}
+
+// Frames returns the frames injected so far.
+func (f *Fake) Frames() []FakeFrame {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeFrame(nil), f.frames...)
+}
+
+// GameStarts returns the slot lists passed to NotifyGameStart.
+func (f *Fake) GameStarts() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.starts...)
+}
+
+// IsShutdown reports whether Shutdown was called.
+func (f *Fake) IsShutdown() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.shutdown
+}
+
+// HandleWS refuses every upgrade; the fake has no WebSocket side.
+func (f *Fake) HandleWS(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "websocket not available", http.StatusNotImplemented)
+}
+
+// HandlePoll refuses long-poll sessions like HandleWS.
+func (f *Fake) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "websocket not available", http.StatusNotImplemented)
+}
+
+// HandleSocketIO refuses Socket.IO clients like HandleWS.
+func (f *Fake) HandleSocketIO(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "websocket not available", http.StatusNotImplemented)
+}
+
+func (f *Fake) Shutdown(ctx context.Context) {
+	f.mu.Lock()
+	f.shutdown = true
+	for _, slot := range f.slots {
+		slot.assignment.Connected = false
+	}
+	f.notifyLocked()
+	f.mu.Unlock()
+}
+
+func (f *Fake) IssueControllerToken(slotID, userID, name, personality, cohort string, ttl time.Duration) (string, time.Time, error) {
+	slotID = normalizeFakeSlot(slotID)
+	if !f.servesSlot(slotID) {
+		return "", time.Time{}, fmt.Errorf("invalid slot id %q", slotID)
+	}
+	userID = strings.TrimSpace(userID)
+	if f.banned(userID) {
+		return "", time.Time{}, ErrBanned
+	}
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	expiresAt := time.Now().Add(ttl)
+	profile := userProfile{ID: userID, Name: strings.TrimSpace(name), Personality: strings.TrimSpace(personality)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storeLocked(slotID, token, profile, strings.TrimSpace(cohort), expiresAt)
+	return token, expiresAt, nil
+}
+
+func (f *Fake) ClaimSlot(slotID, name, cohort string, ttl time.Duration) (SlotClaim, error) {
+	slotID = normalizeFakeSlot(slotID)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return SlotClaim{}, errors.New("name required")
+	}
+	token, err := generateToken()
+	if err != nil {
+		return SlotClaim{}, fmt.Errorf("generate token: %w", err)
+	}
+	userID, err := generateLocalUserID()
+	if err != nil {
+		return SlotClaim{}, fmt.Errorf("generate user id: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slotID == "" {
+		for i := 1; i <= f.maxControllers; i++ {
+			if _, taken := f.slots[fmt.Sprintf("p%d", i)]; !taken {
+				slotID = fmt.Sprintf("p%d", i)
+				break
+			}
+		}
+		if slotID == "" {
+			return SlotClaim{}, ErrNoFreeSlot
+		}
+	} else {
+		if !f.servesSlotLocked(slotID) {
+			return SlotClaim{}, fmt.Errorf("invalid slot id %q", slotID)
+		}
+		if _, taken := f.slots[slotID]; taken {
+			return SlotClaim{}, fmt.Errorf("%w: %s", ErrSlotTaken, slotID)
+		}
+	}
+	expiresAt := time.Now().Add(ttl)
+	f.storeLocked(slotID, token, userProfile{ID: userID, Name: name}, strings.TrimSpace(cohort), expiresAt)
+	return SlotClaim{SlotID: slotID, UserID: userID, Name: name, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+func (f *Fake) HandoffSlot(slotID, userID, name, personality string) (string, error) {
+	slotID = normalizeFakeSlot(slotID)
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return "", errors.New("user id required")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slot, ok := f.slots[slotID]
+	if !ok || !slot.assignment.Connected {
+		return "", fmt.Errorf("%w: %s", ErrSlotNotConnected, slotID)
+	}
+	previous := slot.assignment.UserID
+	slot.assignment.UserID = userID
+	slot.assignment.Name = strings.TrimSpace(name)
+	slot.assignment.Personality = strings.TrimSpace(personality)
+	if info, ok := f.tokens[slot.token]; ok {
+		info.user = userProfile{ID: userID, Name: slot.assignment.Name, Personality: slot.assignment.Personality}
+		f.tokens[slot.token] = info
+	}
+	f.notifyLocked()
+	return previous, nil
+}
+
+func (f *Fake) RevokeSlotToken(slotID string) bool {
+	slotID = normalizeFakeSlot(slotID)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slot, ok := f.slots[slotID]
+	if !ok || slot.token == "" && slot.assignment.UserID == "" {
+		return false
+	}
+	delete(f.tokens, slot.token)
+	if slot.assignment.Connected {
+		slot.token = ""
+		slot.assignment.TokenExpiresAt = time.Time{}
+	} else {
+		delete(f.slots, slotID)
+	}
+	f.notifyLocked()
+	return true
+}
+
+func (f *Fake) ControllerTokens() []ControllerToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	out := make([]ControllerToken, 0, len(f.tokens))
+	for _, info := range f.tokens {
+		if info.scope != ScopeController || info.expiresAt.Before(now) {
+			continue
+		}
+		out = append(out, ControllerToken{
+			SlotID:    info.subject,
+			UserID:    info.user.ID,
+			Name:      info.user.Name,
+			Cohort:    info.cohort,
+			ExpiresAt: info.expiresAt,
+			Connected: f.slots[info.subject] != nil && f.slots[info.subject].assignment.Connected,
+		})
+	}
+	sortControllerTokens(out)
+	return out
+}
+
+func (f *Fake) ControllerAssignments() []ControllerAssignment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	out := make([]ControllerAssignment, 0, len(f.slots))
+	for _, slot := range f.slots {
+		assignment := slot.assignment
+		assignment.Stale = assignment.Connected && now.Sub(assignment.LastSeen) > 10*time.Second
+		assignment.Meta = maps.Clone(f.slotMeta[assignment.SlotID])
+		out = append(out, assignment)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SlotID < out[j].SlotID })
+	return out
+}
+
+func (f *Fake) SetSlotMetadata(slotID string, meta map[string]string) error {
+	slotID = normalizeFakeSlot(slotID)
+	if slotID == "" {
+		return errors.New("slot id required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(meta) == 0 {
+		delete(f.slotMeta, slotID)
+	} else {
+		f.slotMeta[slotID] = maps.Clone(meta)
+	}
+	f.notifyLocked()
+	return nil
+}
+
+func (f *Fake) SlotMetadata() map[string]map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]map[string]string, len(f.slotMeta))
+	for slotID, meta := range f.slotMeta {
+		out[slotID] = maps.Clone(meta)
+	}
+	return out
+}
+
+func (f *Fake) AssignmentsChanged() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.changed
+}
+
+func (f *Fake) RestoreAssignments(assignments []ControllerAssignment) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, assignment := range assignments {
+		slot := f.slotLocked(assignment.SlotID)
+		if slot.assignment.UserID != "" {
+			continue
+		}
+		slot.assignment.UserID = assignment.UserID
+		slot.assignment.Name = assignment.Name
+		slot.assignment.Personality = assignment.Personality
+	}
+	f.notifyLocked()
+}
+
+func (f *Fake) ClearRestoredAssignments() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, slot := range f.slots {
+		if slot.token == "" && !slot.assignment.Connected {
+			delete(f.slots, id)
+		}
+	}
+	f.notifyLocked()
+}
+
+func (f *Fake) IssueToken(req TokenRequest) (string, time.Time, error) {
+	subject := strings.TrimSpace(req.Subject)
+	switch {
+	case req.Scope == ScopeController:
+		return "", time.Time{}, errors.New("controller tokens are issued per slot")
+	case req.Scope != ScopeAdmin && req.Scope != ScopeSpectator:
+		return "", time.Time{}, fmt.Errorf("unknown token scope %q", req.Scope)
+	case subject == "":
+		return "", time.Time{}, errors.New("subject required")
+	}
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	}
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	f.mu.Lock()
+	f.tokens[token] = issuedToken{
+		scope:     req.Scope,
+		subject:   subject,
+		audience:  strings.TrimSpace(req.Audience),
+		claims:    req.Claims,
+		expiresAt: expiresAt,
+	}
+	f.mu.Unlock()
+	return token, expiresAt, nil
+}
+
+func (f *Fake) VerifyToken(token string, scope TokenScope, audience string) (Principal, error) {
+	f.mu.Lock()
+	info, ok := f.tokens[strings.TrimSpace(token)]
+	f.mu.Unlock()
+
+	switch {
+	case !ok || info.scope != scope:
+		return Principal{}, ErrTokenInvalid
+	case info.expiresAt.Before(time.Now()):
+		return Principal{}, ErrTokenExpired
+	case audience != "" && info.audience != audience:
+		return Principal{}, ErrTokenAudience
+	}
+	return Principal{
+		Scope:     info.scope,
+		Subject:   info.subject,
+		Audience:  info.audience,
+		Claims:    info.claims,
+		ExpiresAt: info.expiresAt,
+	}, nil
+}
+
+func (f *Fake) RevokeToken(token string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token = strings.TrimSpace(token)
+	info, ok := f.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(f.tokens, token)
+	if slot, ok := f.slots[info.subject]; ok && info.scope == ScopeController && slot.token == token {
+		slot.token = ""
+		slot.assignment.TokenExpiresAt = time.Time{}
+		f.notifyLocked()
+	}
+	return true
+}
+
+func (f *Fake) RevokeSubjectTokens(scope TokenScope, subject string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subject = strings.TrimSpace(subject)
+	revoked := 0
+	for token, info := range f.tokens {
+		if info.scope == scope && info.subject == subject && scope != ScopeController {
+			delete(f.tokens, token)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (f *Fake) DisconnectControllers(ctx context.Context, slots []string, reason string, includeGame bool) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	want := make(map[string]struct{}, len(slots))
+	for _, id := range slots {
+		want[normalizeFakeSlot(id)] = struct{}{}
+	}
+	closed := 0
+	for id, slot := range f.slots {
+		if _, ok := want[id]; len(want) > 0 && !ok {
+			continue
+		}
+		if slot.assignment.Connected {
+			slot.assignment.Connected = false
+			closed++
+		}
+	}
+	if includeGame && f.gameConnected {
+		f.gameConnected = false
+		closed++
+	}
+	if closed > 0 {
+		f.notifyLocked()
+	}
+	return closed
+}
+
+func (f *Fake) Kick(ctx context.Context, slotID, reason string) error {
+	slotID = normalizeFakeSlot(slotID)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slot, ok := f.slots[slotID]
+	if !ok || !slot.assignment.Connected {
+		return fmt.Errorf("%w: %s", ErrSlotNotConnected, slotID)
+	}
+	slot.assignment.Connected = false
+	f.notifyLocked()
+	return nil
+}
+
+func (f *Fake) Ban(ctx context.Context, subject string, duration time.Duration, reason string) (int, error) {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return 0, errors.New("ban subject required")
+	}
+	if duration <= 0 {
+		return 0, errors.New("ban duration must be positive")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bans[subject] = time.Now().Add(duration)
+	closed := 0
+	for id, slot := range f.slots {
+		if slot.assignment.Connected && (slot.assignment.UserID == subject || id == subject) {
+			slot.assignment.Connected = false
+			closed++
+		}
+	}
+	if closed > 0 {
+		f.notifyLocked()
+	}
+	return closed, nil
+}
+
+func (f *Fake) Unban(subject string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.bans[strings.TrimSpace(subject)]
+	delete(f.bans, strings.TrimSpace(subject))
+	return ok
+}
+
+func (f *Fake) Bans() []Ban {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Ban, 0, len(f.bans))
+	for subject, until := range f.bans {
+		out = append(out, Ban{Subject: subject, Until: until})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Subject < out[j].Subject })
+	return out
+}
+
+func (f *Fake) InjectFrame(slotID, msgType string, payload []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = append(f.frames, FakeFrame{SlotID: slotID, Type: msgType, Payload: cloneBytes(payload)})
+	return f.gameConnected
+}
+
+// RunLocal connects slotID and records frames as injected frames until
+// frames is closed or ctx is done.
+func (f *Fake) RunLocal(ctx context.Context, slotID string, frames <-chan []byte) (websocket.StatusCode, string) {
+	f.Connect(slotID)
+	defer f.Disconnect(slotID)
+	for {
+		select {
+		case <-ctx.Done():
+			return websocket.StatusGoingAway, "server shutdown"
+		case data, ok := <-frames:
+			if !ok {
+				return websocket.StatusNormalClosure, "local input closed"
+			}
+			f.InjectFrame(slotID, msgTypeState, data)
+		}
+	}
+}
+
+func (f *Fake) InputProfiles() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string][]string, len(f.profiles))
+	for slot, types := range f.profiles {
+		out[slot] = append([]string(nil), types...)
+	}
+	return out
+}
+
+func (f *Fake) SetInputProfile(slot string, types []string) []string {
+	normalized := normalizeTypes(types)
+	f.mu.Lock()
+	f.profiles[normalizeFakeSlot(slot)] = normalized
+	f.mu.Unlock()
+	return normalized
+}
+
+func (f *Fake) ClearInputProfile(slot string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slot = normalizeFakeSlot(slot)
+	_, ok := f.profiles[slot]
+	delete(f.profiles, slot)
+	return ok
+}
+
+func (f *Fake) MarkMatchStart(t time.Time) {
+	f.mu.Lock()
+	f.matchStart = t
+	f.mu.Unlock()
+}
+
+func (f *Fake) MatchStartedAt() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.matchStart.IsZero() {
+		return time.Time{}
+	}
+	return f.matchStart.UTC()
+}
+
+// HealRelay reports a healthy relay.
+func (f *Fake) HealRelay() error {
+	return nil
+}
+
+func (f *Fake) ClearMatchStart() {
+	f.MarkMatchStart(time.Time{})
+}
+
+func (f *Fake) MatchTimer() (start time.Time, elapsed time.Duration, running bool) {
+	start = f.MatchStartedAt()
+	if start.IsZero() {
+		return start, 0, false
+	}
+	return start, time.Since(start), true
+}
+
+// MatchStartBroadcasts returns the countdowns passed to BroadcastMatchStart.
+func (f *Fake) MatchStartBroadcasts() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Duration(nil), f.countdowns...)
+}
+
+func (f *Fake) BroadcastMatchStart(slots []string, countdown time.Duration) {
+	f.mu.Lock()
+	f.countdowns = append(f.countdowns, countdown)
+	f.mu.Unlock()
+}
+
+func (f *Fake) NotifyGameStart(slots []string, forced bool, connected int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starts = append(f.starts, append([]string(nil), slots...))
+	return f.gameConnected
+}
+
+func (f *Fake) SubscribeEvents(buffer int) *EventSubscription {
+	return f.events.subscribe(buffer)
+}
+
+func (f *Fake) PublishEvent(eventType string, kv ...any) {
+	f.events.publish(eventType, "", "", "", kv...)
+}
+
+func (f *Fake) SetMaxControllers(n int) error {
+	if n < 1 {
+		return errors.New("max controllers must be at least 1")
+	}
+	f.mu.Lock()
+	f.maxControllers = n
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *Fake) Match() MatchStatus {
+	return f.match.current()
+}
+
+func (f *Fake) AdvanceMatch(state MatchState, source string) error {
+	from, err := f.match.advance(state, false)
+	if err != nil {
+		return err
+	}
+	f.events.publish("match_state", "", "", "", "state", string(state), "previous", string(from), "source", source)
+	return nil
+}
+
+func (f *Fake) ResetMatch(source string) {
+	if from, _ := f.match.advance(MatchIdle, true); from != MatchIdle {
+		f.events.publish("match_state", "", "", "", "state", string(MatchIdle), "previous", string(from), "source", source)
+	}
+}
+
+func (f *Fake) SetAccepting(accepting bool) {
+	f.mu.Lock()
+	f.paused = !accepting
+	f.mu.Unlock()
+}
+
+func (f *Fake) Accepting() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.paused
+}
+
+func (f *Fake) SetRelayTap(tap RelayTap) {
+	f.mu.Lock()
+	f.tap = tap
+	f.mu.Unlock()
+}
+
+func (f *Fake) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := Status{GameConnected: f.gameConnected, MaxControllers: f.maxControllers, MaxGames: 1}
+	for _, slot := range f.slots {
+		if slot.assignment.Connected {
+			status.Controllers++
+		}
+	}
+	return status
+}
+
+func (f *Fake) Sessions() []SessionInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []SessionInfo
+	if f.gameConnected {
+		out = append(out, SessionInfo{Role: roleGame})
+	}
+	ids := make([]string, 0, len(f.slots))
+	for id, slot := range f.slots {
+		if slot.assignment.Connected {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		a := f.slots[id].assignment
+		out = append(out, SessionInfo{Role: roleController, ID: id, UserID: a.UserID, ConnectedAt: a.LastSeen, LastSeen: a.LastSeen})
+	}
+	return out
+}
+
+func (f *Fake) Channels() []string                 { return nil }
+func (f *Fake) QueueStats() QueueStats             { return QueueStats{} }
+func (f *Fake) BroadcastStats() BroadcastStats     { return BroadcastStats{} }
+func (f *Fake) UpgradeStats() UpgradeStats         { return UpgradeStats{} }
+func (f *Fake) CohortStats() []CohortStats         { return nil }
+func (f *Fake) CheckCohort(string) error           { return nil }
+func (f *Fake) ShedStatus() ShedStatus             { return ShedStatus{} }
+func (f *Fake) SoftLimits() []SoftLimit            { return nil }
+func (f *Fake) JWKS() (JWKSet, bool)               { return JWKSet{}, false }
+func (f *Fake) ObserveSoftLimit(string, int, int)  {}
+func (f *Fake) RunIdleMonitor(ctx context.Context) { <-ctx.Done() }
+func (f *Fake) RunLoadMonitor(ctx context.Context) { <-ctx.Done() }
+func (f *Fake) RunMatchTimer(ctx context.Context)  { <-ctx.Done() }
+
+func (f *Fake) RunLatencyHints(ctx context.Context) { <-ctx.Done() }
+
+func (f *Fake) RunSoftLimitMonitor(ctx context.Context) { <-ctx.Done() }
+
+func (f *Fake) banned(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	until, ok := f.bans[userID]
+	return ok && time.Now().Before(until)
+}
+
+func (f *Fake) servesSlot(slotID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.servesSlotLocked(slotID)
+}
+
+func (f *Fake) servesSlotLocked(slotID string) bool {
+	for i := 1; i <= f.maxControllers; i++ {
+		if slotID == fmt.Sprintf("p%d", i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) slotLocked(slotID string) *fakeSlot {
+	slotID = normalizeFakeSlot(slotID)
+	slot, ok := f.slots[slotID]
+	if !ok {
+		slot = &fakeSlot{assignment: ControllerAssignment{SlotID: slotID}}
+		f.slots[slotID] = slot
+	}
+	return slot
+}
+
+func (f *Fake) storeLocked(slotID, token string, profile userProfile, cohort string, expiresAt time.Time) {
+	slot := f.slotLocked(slotID)
+	delete(f.tokens, slot.token)
+	slot.token = token
+	slot.assignment.UserID = profile.ID
+	slot.assignment.Name = profile.Name
+	slot.assignment.Personality = profile.Personality
+	slot.assignment.TokenExpiresAt = expiresAt
+	f.tokens[token] = issuedToken{
+		scope:     ScopeController,
+		subject:   slotID,
+		user:      profile,
+		cohort:    cohort,
+		expiresAt: expiresAt,
+	}
+	f.notifyLocked()
+	f.events.publish("token_issued", roleController, slotID, "", "userId", profile.ID, "expiresAt", expiresAt.UTC())
+}
+
+func (f *Fake) notifyLocked() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func normalizeFakeSlot(slotID string) string {
+	return strings.ToLower(strings.TrimSpace(slotID))
+}
@@ -0,0 +1,86 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSlotNotConnected indicates that no controller is connected on the slot.
+var ErrSlotNotConnected = errors.New("slot not connected")
+
+type slotHandoffEvent struct {
+	Type           string `json:"type"`
+	SlotID         string `json:"slotId"`
+	PreviousUserID string `json:"previousUserId,omitempty"`
+	UserID         string `json:"userId"`
+	Name           string `json:"name,omitempty"`
+	Personality    string `json:"personality,omitempty"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// HandoffSlot rebinds a connected controller slot to a different user without
+// dropping the connection. Any outstanding token or restored binding for the
+// slot is updated as well, and the game is told about the swap. It returns
+// the user previously bound to the slot.
+func (h *Hub) HandoffSlot(slotID, userID, name, personality string) (string, error) {
+	slotID = strings.ToLower(strings.TrimSpace(slotID))
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return "", errors.New("user id required")
+	}
+
+	profile := userProfile{
+		ID:          userID,
+		Name:        strings.TrimSpace(name),
+		Personality: strings.TrimSpace(personality),
+	}
+
+	h.mu.Lock()
+	session := h.controllers[slotID]
+	if session == nil {
+		h.mu.Unlock()
+		return "", fmt.Errorf("%w: %s", ErrSlotNotConnected, slotID)
+	}
+	previous := session.user.ID
+	if previous == "" {
+		previous = h.restored[slotID].ID
+	}
+	session.user = profile
+	if token, ok := h.slotTokens[slotID]; ok {
+		info := h.tokens[token]
+		info.user = profile
+		h.tokens[token] = info
+	}
+	if _, ok := h.restored[slotID]; ok {
+		h.restored[slotID] = profile
+	}
+	games := h.gameListenersLocked()
+	h.notifyAssignmentsLocked()
+	h.mu.Unlock()
+
+	session.logger.Info("slot_handoff", "previous_user_id", previous, "user_id", userID)
+	h.emit("slot_handoff", roleController, slotID, session.remoteIP, "previousUserId", previous, "userId", userID)
+
+	if len(games) > 0 {
+		payload, err := json.Marshal(slotHandoffEvent{
+			Type:           "slot_handoff",
+			SlotID:         slotID,
+			PreviousUserID: previous,
+			UserID:         profile.ID,
+			Name:           profile.Name,
+			Personality:    profile.Personality,
+			Timestamp:      time.Now().UnixMilli(),
+		})
+		if err != nil {
+			return previous, fmt.Errorf("encode handoff event: %w", err)
+		}
+		for _, game := range games {
+			game.enqueue(payload, "server")
+		}
+	}
+
+	return previous, nil
+}
@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+type handoverEvent struct {
+	Type      string `json:"type"`
+	DrainMs   int64  `json:"drainMs"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type gameChangedEvent struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// handOver retires previous after a newer game connection from remote took
+// its place. The caller must not hold h.mu.
+//
+// When a game registers while another is connected, the outgoing game is
+// not cut off mid-write. It is sent
+//
+//	{"type":"handover","drainMs":2000,"timestamp":...}
+//
+// and keeps its connection for HandoverDrain so it can flush state of its
+// own, such as saving a result, before the hub closes it with
+// ReasonReplaced. The game may also close first once it is done. Frames it
+// sends while draining are no longer routed: the controllers already belong
+// to the new game, which they learn from a game_changed frame (not sent in
+// passthrough mode, where controllers only receive game frames).
+func (h *Hub) handOver(previous *gameSession, remote string) {
+	drain := h.cfg.HandoverDrain
+	previous.retired.Store(true)
+	h.emit("game_replaced", roleGame, "", previous.remoteIP, "by", remote, "drain_ms", drain.Milliseconds())
+
+	now := time.Now().UnixMilli()
+	if payload, err := json.Marshal(handoverEvent{
+		Type:      "handover",
+		DrainMs:   drain.Milliseconds(),
+		Timestamp: now,
+	}); err != nil {
+		previous.logger.Error("handover_encode_failed", "err", err.Error())
+	} else {
+		previous.enqueue(payload, "server")
+	}
+	previous.logger.Info("game_handover", "by", remote, "drain", drain.String())
+
+	crash.Go(func() {
+		timer := time.NewTimer(drain)
+		defer timer.Stop()
+		select {
+		case <-previous.ctx.Done():
+		case <-timer.C:
+		}
+		previous.close(closeWith(ReasonReplaced))
+	})
+
+	payload, err := json.Marshal(gameChangedEvent{Type: "game_changed", Timestamp: now})
+	if err != nil {
+		h.log.Error("game_changed_encode_failed", "err", err.Error())
+		return
+	}
+	h.mu.Lock()
+	sessions := make([]*controllerSession, 0, len(h.controllers))
+	if !h.cfg.Passthrough {
+		for _, session := range h.controllers {
+			sessions = append(sessions, session)
+		}
+	}
+	h.mu.Unlock()
+	for _, session := range sessions {
+		if session.outbox.offer(payload, false, "") {
+			h.broadcast.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package hub
+
+import "time"
+
+// msgTypeHeartbeat is a lightweight liveness frame, {"type":"hb"}, that a
+// controller may send every few seconds while it has no input to report. It
+// refreshes lastSeen like any other frame but is never relayed to the game.
+const msgTypeHeartbeat = "hb"
+
+// isStale reports whether a connected controller last seen at
+// lastSeen has been silent for longer than StaleAfter.
+func (h *Hub) isStale(lastSeen, now time.Time) bool {
+	return !lastSeen.IsZero() && now.Sub(lastSeen) > h.cfg.StaleAfter
+}
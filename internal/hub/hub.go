@@ -3,20 +3,24 @@ package hub
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
-	"regexp"
+	"net/netip"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
 )
 
 const (
@@ -24,11 +28,11 @@ const (
 	roleController = "controller"
 )
 
-var controllerIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+const msgTypeUnregister = "unregister"
 
 var (
-	errInvalidToken = errors.New("invalid controller token")
-	errExpiredToken = errors.New("controller token expired")
+	errUnregistered = errors.New("controller unregistered")
+	errRefresh      = errors.New("controller token refresh requested")
 )
 
 type userProfile struct {
@@ -37,12 +41,6 @@ type userProfile struct {
 	Personality string
 }
 
-type controllerToken struct {
-	slotID    string
-	user      userProfile
-	expiresAt time.Time
-}
-
 type gameStartEvent struct {
 	Type      string   `json:"type"`
 	Slots     []string `json:"slots"`
@@ -60,15 +58,97 @@ type ControllerAssignment struct {
 	Connected      bool
 	LastSeen       time.Time
 	TokenExpiresAt time.Time
+	LastSeq        uint64
+	// Stale marks a connected controller silent for longer than
+	// Config.StaleAfter.
+	Stale bool
+	// Tutorial is the onboarding state of the connected session; empty
+	// while the slot has no connection.
+	Tutorial TutorialState
+	// Cohort is the experiment cohort of the connected session, or the one
+	// the pending token was issued for.
+	Cohort string
+	// Meta is the operator-set slot metadata; see SetSlotMetadata.
+	Meta map[string]string
 }
 
 // Config collects tunable parameters for Hub behaviour.
 type Config struct {
-	AllowedOrigins  []string
-	MaxControllers  int
-	RelayQueueSize  int
-	RegisterTimeout time.Duration
-	WriteTimeout    time.Duration
+	AllowedOrigins     []string
+	MaxControllers     int
+	RelayQueueSize     int
+	QueuePolicy        QueuePolicy
+	CoalesceInput      bool
+	RelayInterval      time.Duration
+	PriorityTypes      []string
+	RegisterTimeout    time.Duration
+	WriteTimeout       time.Duration
+	MinProtocolVersion int
+	IDPolicy           IDPolicy
+	IDGenerator        IDGenerator
+	AllowAnonymous     bool
+	Cohorts            map[string]Cohort
+	LoadShedding       bool
+	BroadcastRateHz    int
+	StateDelta         bool
+	TokenTTL           time.Duration
+	// IdleTimeout disconnects controllers silent for longer than this; zero
+	// disables idle eviction. VisibilityGrace suspends it for controllers
+	// whose page reported being hidden.
+	IdleTimeout     time.Duration
+	VisibilityGrace time.Duration
+	// StaleAfter is how long a connected controller may stay silent before
+	// its assignment is reported as stale.
+	StaleAfter time.Duration
+	// IDFields names the controller frame fields checked, in order, against
+	// the registered slot; IDMismatch decides what happens on a mismatch.
+	IDFields   []string
+	IDMismatch IDMismatchPolicy
+	// Envelope wraps relayed controller frames in a "relay" envelope
+	// carrying the hub's view of the sender. EnvelopeKey, when set, adds an
+	// HMAC-SHA256 signature to each envelope.
+	Envelope    bool
+	EnvelopeKey []byte
+	// Passthrough relays frames verbatim behind a slot id header instead of
+	// parsing them; see passthrough.go. A hub serves a single room, so this
+	// is the room's setting: run one hub per room to mix modes.
+	Passthrough bool
+	// InputProfiles limits the message types each listed slot may send.
+	InputProfiles map[string][]string
+	// HandoverDrain is how long a replaced game keeps its connection after
+	// the handover frame.
+	HandoverDrain time.Duration
+	// TimerInterval paces the match timer broadcast.
+	TimerInterval time.Duration
+	// LatencyHintInterval paces the per-slot latency frame sent to the
+	// game; zero disables it. See latency.go.
+	LatencyHintInterval time.Duration
+	// CalibrationTimeout is how long a controller has to answer a
+	// calibrate request when the game does not set timeoutMs.
+	CalibrationTimeout time.Duration
+	// MaxGames caps concurrent game listeners: the primary game plus
+	// read-only mirrors. Values below 1 allow the primary only.
+	MaxGames int
+	// GameToken, when set, must be presented as the token of a game
+	// register message; other game registrations are refused.
+	GameToken string
+	// Signer, when set, issues tokens as signed JWTs; see jwt.go.
+	Signer *TokenSigner
+	// SoftLimits sets the thresholds at which approaching a hard limit is
+	// logged; see softlimit.go.
+	SoftLimits SoftLimitConfig
+	// InputRateHz caps the frames accepted from each controller per
+	// second; zero disables the limit. See inputrate.go.
+	InputRateHz int
+
+	MaxConnsPerIP         int
+	MaxPendingPerIP       int
+	RegisterFailureLimit  int
+	RegisterFailureWindow time.Duration
+	RegisterLockout       time.Duration
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For names
+	// the client; see ClientIP.
+	TrustedProxies []netip.Prefix
 }
 
 // Hub coordinator for controller and game WebSocket connections.
@@ -79,8 +159,38 @@ type Hub struct {
 	mu          sync.Mutex
 	controllers map[string]*controllerSession
 	game        *gameSession
-	tokens      map[string]controllerToken
+	mirrors     map[*gameSession]struct{}
+	tokens      map[string]issuedToken
 	slotTokens  map[string]string
+	slotSeq     map[string]uint64
+	restored    map[string]userProfile
+	allocated   map[string]struct{}
+	bans        map[string]time.Time
+	kicked      map[string]time.Time
+	changed     chan struct{}
+	drops       queueCounters
+	upgrades    upgradeCounters
+	epoch       atomic.Uint64
+	cohortStats map[string]*cohortCounters
+	shed        loadShedder
+	broadcast   broadcastCounters
+	soft        *softLimits
+	snapshots   map[string][]byte
+	profiles    map[string][]string
+	limiter     *ipLimiter
+	events      eventBus
+	tap         atomic.Pointer[RelayTap]
+	interceptMu sync.Mutex
+	intercepts  atomic.Pointer[[]RelayInterceptor]
+	matchStart  atomic.Int64 // unix nanoseconds, 0 when unknown
+	paused      atomic.Bool  // refusing new controllers; see accepting.go
+
+	calibrationSeq atomic.Uint64
+	relayProbe     relayProbe                   // guarded by mu; see watchdog.go
+	slotMeta       map[string]map[string]string // guarded by mu; see slotmeta.go
+	match          matchMachine                 // see matchstate.go
+	polls          pollRegistry                 // see longpoll.go
+	roster         rosterOutbox                 // see roster.go
 }
 
 // New creates a Hub with sane defaults applied to the provided Config.
@@ -91,22 +201,85 @@ func New(cfg Config, logger *slog.Logger) *Hub {
 	if cfg.RelayQueueSize <= 0 {
 		cfg.RelayQueueSize = 128
 	}
+	if cfg.VisibilityGrace <= 0 {
+		cfg.VisibilityGrace = time.Minute
+	}
+	if cfg.CalibrationTimeout <= 0 {
+		cfg.CalibrationTimeout = defaultCalibrationTimeout
+	}
+	if len(cfg.IDFields) == 0 {
+		cfg.IDFields = []string{"id"}
+	}
+	if cfg.IDMismatch == "" {
+		cfg.IDMismatch = IDMismatchReject
+	}
+	if cfg.TimerInterval <= 0 {
+		cfg.TimerInterval = time.Second
+	}
+	if cfg.MaxGames <= 0 {
+		cfg.MaxGames = 1
+	}
+	if cfg.QueuePolicy == "" {
+		cfg.QueuePolicy = QueueDropOldest
+	}
+	if cfg.RelayInterval <= 0 {
+		cfg.RelayInterval = time.Second / 60
+	}
 	if cfg.RegisterTimeout <= 0 {
 		cfg.RegisterTimeout = 5 * time.Second
 	}
 	if cfg.WriteTimeout <= 0 {
 		cfg.WriteTimeout = 2 * time.Second
 	}
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = time.Minute
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = 10 * time.Second
+	}
+	if cfg.HandoverDrain <= 0 {
+		cfg.HandoverDrain = 2 * time.Second
+	}
+	if cfg.RegisterFailureWindow <= 0 {
+		cfg.RegisterFailureWindow = time.Minute
+	}
+	if cfg.RegisterLockout <= 0 {
+		cfg.RegisterLockout = time.Minute
+	}
+	if cfg.MinProtocolVersion <= 0 || cfg.MinProtocolVersion > ProtocolVersion {
+		cfg.MinProtocolVersion = 1
+	}
+	cfg.IDPolicy = cfg.IDPolicy.withDefaults()
+	if cfg.IDGenerator == nil {
+		cfg.IDGenerator = RandomIDGenerator{Prefix: anonymousIDPrefix, Policy: cfg.IDPolicy}
+	}
 	if len(cfg.AllowedOrigins) == 1 && cfg.AllowedOrigins[0] == "*" {
 		cfg.AllowedOrigins = nil
 	}
 
+	profiles := make(map[string][]string, len(cfg.InputProfiles))
+	for slot, types := range cfg.InputProfiles {
+		profiles[strings.ToLower(slot)] = normalizeTypes(types)
+	}
+
 	return &Hub{
 		cfg:         cfg,
 		log:         logger,
 		controllers: make(map[string]*controllerSession),
-		tokens:      make(map[string]controllerToken),
+		mirrors:     make(map[*gameSession]struct{}),
+		tokens:      make(map[string]issuedToken),
 		slotTokens:  make(map[string]string),
+		slotSeq:     make(map[string]uint64),
+		restored:    make(map[string]userProfile),
+		allocated:   make(map[string]struct{}),
+		bans:        make(map[string]time.Time),
+		kicked:      make(map[string]time.Time),
+		changed:     make(chan struct{}),
+		cohortStats: newCohortStats(cfg.Cohorts),
+		snapshots:   make(map[string][]byte),
+		limiter:     newIPLimiter(cfg),
+		soft:        newSoftLimits(cfg.SoftLimits),
+		profiles:    profiles,
 	}
 }
 
@@ -138,17 +311,56 @@ func (h *Hub) NotifyGameStart(slots []string, forced bool, connected int) bool {
 		return false
 	}
 
-	session.enqueue(payload, "server")
+	h.advanceEpoch(session, "game_start")
+	h.enqueueToListeners(payload)
 	h.log.Info("game_start_event_dispatched", "forced", forced, "connected", connected, "slots", slotsCopy)
 	return true
 }
 
 // HandleWS upgrades HTTP connections to WebSocket and manages session lifecycles.
 func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
-	remote := remoteAddr(r)
+	remote := h.remoteAddr(r)
+
+	if r.ProtoMajor != 1 {
+		// nhooyr/websocket only implements the HTTP/1.1 upgrade handshake, so
+		// HTTP/2 (including h2c) clients must fall back to HTTP/1.1 for /ws.
+		h.upgrades.fail(UpgradeFailProtocol)
+		h.log.Warn("ws_upgrade_unsupported_protocol", "proto", r.Proto, "remote_ip", remote)
+		http.Error(w, "websocket requires HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
 
+	if retryAfter, ok := h.limiter.acquire(remote, time.Now()); !ok {
+		if retryAfter > 0 {
+			h.upgrades.fail(UpgradeFailLockout)
+			h.log.Debug("ws_upgrade_locked_out", "remote_ip", remote)
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "too many failed register attempts", http.StatusTooManyRequests)
+			return
+		}
+		h.upgrades.fail(UpgradeFailIPLimit)
+		h.log.Warn("ws_upgrade_ip_limit", "remote_ip", remote, "limit", h.cfg.MaxConnsPerIP)
+		http.Error(w, "too many connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	defer h.limiter.release(remote, time.Now())
+
+	if !h.limiter.beginRegister(remote) {
+		h.upgrades.fail(UpgradeFailPending)
+		h.log.Warn("ws_upgrade_pending_limit", "remote_ip", remote, "limit", h.cfg.MaxPendingPerIP)
+		http.Error(w, "too many unregistered connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	registering := sync.OnceFunc(func() { h.limiter.endRegister(remote) })
+	defer registering()
+
+	cohortHint := r.URL.Query().Get("cohort")
 	opts := &websocket.AcceptOptions{
 		CompressionMode: websocket.CompressionDisabled,
+		Subprotocols:    h.subprotocols(),
+	}
+	if cohort, err := h.resolveCohort(cohortHint); err == nil && cohort.Compress {
+		opts.CompressionMode = websocket.CompressionNoContextTakeover
 	}
 	if len(h.cfg.AllowedOrigins) > 0 {
 		opts.OriginPatterns = h.cfg.AllowedOrigins
@@ -156,9 +368,16 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	conn, err := websocket.Accept(w, r, opts)
 	if err != nil {
-		h.log.Error("ws_accept_failed", "role", "", "id", "", "remote_ip", remote, "err", err.Error())
+		class := classifyAcceptError(err)
+		h.upgrades.fail(class)
+		attrs := []any{"role", "", "id", "", "remote_ip", remote, "reason", class, "origin", r.Header.Get("Origin"), "host", r.Host, "err", err.Error()}
+		if class == UpgradeFailOrigin {
+			attrs = append(attrs, "hint", "add the origin host to ORIGINS")
+		}
+		h.log.Warn("ws_accept_failed", attrs...)
 		return
 	}
+	h.upgrades.accepted.Add(1)
 
 	status := websocket.StatusNormalClosure
 	reason := statusText(status)
@@ -168,7 +387,10 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	reg, regErrStatus, regErrReason := h.readRegister(ctx, conn, remote)
+	registering()
+	reg.cohortHint = cohortHint
 	if regErrStatus != 0 {
+		h.registerFailed(remote)
 		status = regErrStatus
 		reason = regErrReason
 		return
@@ -176,12 +398,25 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	switch reg.Role {
 	case roleGame:
-		status, reason = h.handleGame(ctx, conn, remote)
+		if !h.authenticateGame(reg.Token) {
+			status, reason = closeWith(ReasonTokenInvalid)
+			h.registerFailed(remote)
+			h.log.Warn("register_game_token_invalid", "role", roleGame, "id", "", "remote_ip", remote, "token_present", reg.Token != "")
+			h.emit("register_game_token_invalid", roleGame, "", remote)
+			break
+		}
+		if reg.Mirror {
+			status, reason = h.handleMirror(ctx, conn, remote, reg)
+			break
+		}
+		status, reason = h.handleGame(ctx, conn, remote, reg)
 	case roleController:
 		status, reason = h.handleController(ctx, conn, remote, reg)
+	case roleWatchdog:
+		status, reason = h.handleWatchdog(ctx, conn, r, reg)
 	default:
-		status = websocket.StatusPolicyViolation
-		reason = "invalid role"
+		status, reason = closeWith(ReasonRegisterInvalid)
+		h.registerFailed(remote)
 		h.log.Warn("register_invalid_role", "role", reg.Role, "id", reg.ID, "remote_ip", remote)
 	}
 
@@ -193,16 +428,18 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 // Shutdown requests a graceful close of active sessions.
 func (h *Hub) Shutdown(ctx context.Context) {
 	h.mu.Lock()
-	game := h.game
+	games := h.gameListenersLocked()
 	controllers := make([]*controllerSession, 0, len(h.controllers))
 	for _, c := range h.controllers {
 		controllers = append(controllers, c)
 	}
 	h.game = nil
+	h.mirrors = make(map[*gameSession]struct{})
 	h.controllers = make(map[string]*controllerSession)
+	h.notifyAssignmentsLocked()
 	h.mu.Unlock()
 
-	if game != nil {
+	for _, game := range games {
 		game.close(websocket.StatusNormalClosure, "server shutdown")
 	}
 	for _, c := range controllers {
@@ -216,9 +453,18 @@ func (h *Hub) Shutdown(ctx context.Context) {
 }
 
 type registerPayload struct {
-	Role  string `json:"role"`
-	ID    string `json:"id,omitempty"`
-	Token string `json:"token,omitempty"`
+	Role            string   `json:"role"`
+	ID              string   `json:"id,omitempty"`
+	Token           string   `json:"token,omitempty"`
+	ProtocolVersion int      `json:"protocolVersion,omitempty"`
+	Cohort          string   `json:"cohort,omitempty"`
+	StateDelta      bool     `json:"stateDelta,omitempty"`
+	Channels        []string `json:"channels,omitempty"`
+	Mirror          bool     `json:"mirror,omitempty"`
+
+	// cohortHint is the cohort requested in the upgrade query string, which
+	// decided whether compression was negotiated.
+	cohortHint string
 }
 
 func (h *Hub) readRegister(ctx context.Context, conn *websocket.Conn, remote string) (registerPayload, websocket.StatusCode, string) {
@@ -231,61 +477,89 @@ func (h *Hub) readRegister(ctx context.Context, conn *websocket.Conn, remote str
 		h.log.Warn("register_read_failed", "role", "", "id", "", "remote_ip", remote, "err", err.Error())
 		return registerPayload{}, status, reason
 	}
+	h.checkSize(LimitWSMessageBytes, wsMessageLimit, len(data))
 
 	if msgType != websocket.MessageText {
 		h.log.Warn("register_invalid_type", "role", "", "id", "", "remote_ip", remote)
 		return registerPayload{}, websocket.StatusUnsupportedData, "text frame required"
 	}
+	return h.parseRegister(data, conn.Subprotocol(), remote)
+}
 
+// parseRegister validates a register message; subprotocol is the one the
+// WebSocket negotiated, if any.
+func (h *Hub) parseRegister(data []byte, subprotocol, remote string) (registerPayload, websocket.StatusCode, string) {
 	var payload registerPayload
 	if err := json.Unmarshal(data, &payload); err != nil {
 		h.log.Warn("register_invalid_json", "role", "", "id", "", "remote_ip", remote, "err", err.Error())
-		return registerPayload{}, websocket.StatusPolicyViolation, "invalid register payload"
+		status, reason := closeWith(ReasonRegisterInvalid)
+		return registerPayload{}, status, reason
 	}
 
 	payload.Role = strings.ToLower(strings.TrimSpace(payload.Role))
 	payload.ID = strings.ToLower(strings.TrimSpace(payload.ID))
 	payload.Token = strings.TrimSpace(payload.Token)
 
+	version, err := h.negotiateProtocol(subprotocol, payload.ProtocolVersion)
+	if err != nil {
+		h.log.Warn("register_protocol_unsupported", "role", payload.Role, "id", payload.ID, "remote_ip", remote, "err", err.Error())
+		status, reason := closeWith(ReasonRegisterInvalid)
+		return registerPayload{}, status, reason
+	}
+	payload.ProtocolVersion = version
+
 	if payload.Role == roleController {
 		if payload.Token == "" {
 			if payload.ID == "" {
+				if h.cfg.AllowAnonymous {
+					return payload, 0, ""
+				}
 				h.log.Warn("register_missing_id", "role", roleController, "id", "", "remote_ip", remote)
-				return registerPayload{}, websocket.StatusPolicyViolation, "controller id required"
+				status, reason := closeWith(ReasonRegisterInvalid)
+				return registerPayload{}, status, reason
 			}
-			if !controllerIDPattern.MatchString(payload.ID) {
-				h.log.Warn("register_invalid_id", "role", roleController, "id", payload.ID, "remote_ip", remote)
-				return registerPayload{}, websocket.StatusPolicyViolation, "invalid controller id"
+			if err := h.cfg.IDPolicy.Validate(payload.ID); err != nil {
+				h.log.Warn("register_invalid_id", "role", roleController, "id", payload.ID, "remote_ip", remote, "err", err.Error())
+				status, reason := closeWith(ReasonRegisterInvalid)
+				return registerPayload{}, status, reason
+			}
+		} else if payload.ID != "" {
+			if err := h.cfg.IDPolicy.validateShape(payload.ID); err != nil {
+				h.log.Warn("register_invalid_id_optional", "role", roleController, "id", payload.ID, "remote_ip", remote, "err", err.Error())
+				status, reason := closeWith(ReasonRegisterInvalid)
+				return registerPayload{}, status, reason
 			}
-		} else if payload.ID != "" && !controllerIDPattern.MatchString(payload.ID) {
-			h.log.Warn("register_invalid_id_optional", "role", roleController, "id", payload.ID, "remote_ip", remote)
-			return registerPayload{}, websocket.StatusPolicyViolation, "invalid controller id"
 		}
 	}
 
 	return payload, 0, ""
 }
 
-func (h *Hub) handleGame(ctx context.Context, conn *websocket.Conn, remote string) (websocket.StatusCode, string) {
-	session := newGameSession(ctx, conn, remote, h.cfg.RelayQueueSize, h.cfg.WriteTimeout, h.log)
+func (h *Hub) handleGame(ctx context.Context, conn gameConn, remote string, reg registerPayload) (websocket.StatusCode, string) {
+	session := newGameSession(ctx, conn, remote, h.cfg, &h.drops, h.log.With("protocol", reg.ProtocolVersion))
 
 	h.mu.Lock()
 	previous := h.game
 	h.game = session
+	h.clearSnapshotsLocked()
+	h.sendRosterLocked(session)
+	h.replayCalibrationsLocked(session)
 	h.mu.Unlock()
 
 	if previous != nil {
-		previous.close(websocket.StatusPolicyViolation, "game replaced")
+		h.handOver(previous, remote)
 	}
 
-	session.logger.Info("connected")
+	epoch := h.advanceEpoch(nil, "game_connected")
+	session.logger.Info("connected", "epoch", epoch)
+	h.emit("connected", roleGame, "", remote, "epoch", epoch)
 	session.startWriter()
 
 	status := websocket.StatusNormalClosure
 	reason := statusText(status)
 
 	for {
-		_, _, err := conn.Read(ctx)
+		msgType, data, err := conn.Read(ctx)
 		if err != nil {
 			status, reason = closeStatusFromError(err, websocket.StatusNormalClosure)
 			if !errors.Is(err, context.Canceled) {
@@ -295,6 +569,19 @@ func (h *Hub) handleGame(ctx context.Context, conn *websocket.Conn, remote strin
 			}
 			break
 		}
+		h.checkSize(LimitWSMessageBytes, wsMessageLimit, len(data))
+		if session.retired.Load() {
+			continue
+		}
+		if h.cfg.Passthrough {
+			h.routeRawGameMessage(session, msgType, data)
+			continue
+		}
+		if msgType != websocket.MessageText {
+			session.logger.Warn("game_payload_invalid", "err", "text frame required")
+			continue
+		}
+		h.routeGameMessage(session, data)
 	}
 
 	h.mu.Lock()
@@ -302,68 +589,168 @@ func (h *Hub) handleGame(ctx context.Context, conn *websocket.Conn, remote strin
 		h.game = nil
 	}
 	h.mu.Unlock()
+	h.emit("disconnected", roleGame, "", remote, "status", int(status), "reason", reason)
 
 	session.close(status, reason)
 
 	return status, reason
 }
 
-func (h *Hub) handleController(ctx context.Context, conn *websocket.Conn, remote string, reg registerPayload) (websocket.StatusCode, string) {
+func (h *Hub) handleController(ctx context.Context, conn controllerConn, remote string, reg registerPayload) (websocket.StatusCode, string) {
 	controllerID := reg.ID
 	var profile userProfile
+	cohortLabel := reg.Cohort
+	if cohortLabel == "" {
+		cohortLabel = reg.cohortHint
+	}
 
 	if reg.Token != "" {
 		tokenInfo, err := h.resolveControllerToken(reg.Token)
 		if err != nil {
-			reason := "invalid controller token"
-			switch {
-			case errors.Is(err, errExpiredToken):
-				reason = "controller token expired"
-			}
+			h.registerFailed(remote)
 			h.log.Warn("register_token_invalid", "role", roleController, "id", controllerID, "remote_ip", remote, "err", err.Error())
-			return websocket.StatusPolicyViolation, reason
+			if errors.Is(err, ErrTokenExpired) {
+				return closeWith(ReasonTokenExpired)
+			}
+			return closeWith(ReasonTokenInvalid)
 		}
-		controllerID = tokenInfo.slotID
+		controllerID = tokenInfo.subject
 		profile = tokenInfo.user
+		if tokenInfo.cohort != "" {
+			cohortLabel = tokenInfo.cohort
+		}
 		if reg.ID != "" && reg.ID != controllerID {
+			h.registerFailed(remote)
 			h.log.Warn("register_token_slot_mismatch", "role", roleController, "id", reg.ID, "remote_ip", remote, "expected", controllerID)
-			return websocket.StatusPolicyViolation, "token slot mismatch"
+			return closeWith(ReasonTokenInvalid)
+		}
+	}
+
+	anonymous := false
+	if controllerID == "" && reg.Token == "" && h.cfg.AllowAnonymous {
+		id, err := h.allocateAnonymousID()
+		if err != nil {
+			h.log.Error("register_id_allocation_failed", "role", roleController, "remote_ip", remote, "err", err.Error())
+			return closeWith(ReasonSlotTaken)
 		}
+		defer h.releaseAnonymousID(id)
+		controllerID = id
+		anonymous = true
 	}
 
 	if controllerID == "" {
+		h.registerFailed(remote)
 		h.log.Warn("register_missing_id", "role", roleController, "id", "", "remote_ip", remote)
-		return websocket.StatusPolicyViolation, "controller id required"
+		return closeWith(ReasonRegisterInvalid)
+	}
+
+	if err := h.cfg.IDPolicy.validateShape(controllerID); err != nil {
+		h.registerFailed(remote)
+		h.log.Warn("register_invalid_id", "role", roleController, "id", controllerID, "remote_ip", remote, "err", err.Error())
+		return closeWith(ReasonRegisterInvalid)
+	}
+
+	if err := h.checkAdmission(controllerID, remote, profile.ID); err != nil {
+		h.log.Warn("register_refused", "role", roleController, "id", controllerID, "remote_ip", remote, "err", err.Error())
+		if errors.Is(err, ErrBanned) {
+			return closeWith(ReasonBanned)
+		}
+		return closeWith(ReasonKicked)
 	}
 
-	if !controllerIDPattern.MatchString(controllerID) {
-		h.log.Warn("register_invalid_id", "role", roleController, "id", controllerID, "remote_ip", remote)
-		return websocket.StatusPolicyViolation, "invalid controller id"
+	if !h.admitWhilePaused(controllerID) {
+		h.log.Warn("register_not_accepting", "role", roleController, "id", controllerID, "remote_ip", remote)
+		return closeWith(ReasonNotAccepting)
 	}
 
-	session := newControllerSession(conn, controllerID, remote, profile, h.log)
+	if !h.admitUnderLoad(controllerID, reg.Token != "") {
+		h.shed.reject()
+		h.log.Warn("register_shed", "role", roleController, "id", controllerID, "remote_ip", remote)
+		return closeWith(ReasonOverloaded)
+	}
+
+	cohort, err := h.resolveCohort(cohortLabel)
+	if err != nil {
+		h.registerFailed(remote)
+		h.log.Warn("register_invalid_cohort", "role", roleController, "id", controllerID, "remote_ip", remote, "err", err.Error())
+		return closeWith(ReasonRegisterInvalid)
+	}
+
+	session := newControllerSession(conn, controllerID, remote, profile, cohort, h.cohortCounters(cohort.Name), h.log.With("protocol", reg.ProtocolVersion))
+	if reg.Token != "" {
+		session.token = strings.TrimSpace(reg.Token)
+	}
+	if len(reg.Channels) > maxSubscriptions || !validChannels(reg.Channels...) {
+		h.log.Warn("register_too_many_channels", "role", roleController, "id", controllerID, "remote_ip", remote, "channels", len(reg.Channels))
+		return closeWith(ReasonRegisterInvalid)
+	}
+	session.channels = newSubscriptions(reg.Channels)
+	if h.cfg.StateDelta && reg.StateDelta {
+		session.delta = newDeltaSet()
+	}
 
 	replaced, err := h.addController(session)
 	if err != nil {
 		session.logger.Warn("rejected", "reason", err.Error())
-		return websocket.StatusPolicyViolation, err.Error()
+		return closeWith(ReasonSlotTaken)
 	}
 
 	if replaced != nil {
-		_ = replaced.conn.Close(websocket.StatusPolicyViolation, "controller replaced")
+		_ = replaced.conn.Close(closeWith(ReasonReplaced))
 	}
 
-	session.logger.Info("connected")
+	session.logger.Info("connected", "anonymous", anonymous)
+	h.emit("connected", roleController, controllerID, remote, "userId", profile.ID, "cohort", cohort.Name)
+	if pc, ok := conn.(*pollConn); ok {
+		pc.markOpen(controllerID)
+	}
+	if cohort.Compress && !strings.EqualFold(reg.cohortHint, cohort.Name) {
+		session.logger.Warn("cohort_compression_unavailable", "hint", reg.cohortHint)
+	}
+	session.stats.connections.Add(1)
+	defer session.stats.connections.Add(-1)
+
+	writerCtx, stopWriter := context.WithCancel(ctx)
+	defer stopWriter()
+	crash.Go(func() { h.runControllerWriter(writerCtx, session) })
+	crash.Go(func() { h.runPinger(writerCtx, session) })
+	h.replaySnapshots(session)
+
+	// Controllers that named their slot only get the ack when the operator
+	// attached metadata to it.
+	h.mu.Lock()
+	meta := h.slotMetaLocked(session.id)
+	h.mu.Unlock()
+	if anonymous || meta != nil {
+		if err := h.writeController(ctx, session, controllerNotice{Type: "registered", ID: session.id, Meta: meta}); err != nil {
+			session.logger.Warn("registered_ack_failed", "err", err.Error())
+		}
+	}
+	if epoch := h.Epoch(); epoch > 0 {
+		h.sendEpoch(ctx, session, epoch)
+	}
 
 	status := websocket.StatusNormalClosure
 	reason := statusText(status)
+	// dropped marks a connection lost without a close frame, e.g. a phone
+	// leaving Wi-Fi range, which closeStatusFromError reports as normal.
+	dropped := false
 
 	for {
 		msgType, data, err := conn.Read(ctx)
 		if err != nil {
 			status, reason = closeStatusFromError(err, websocket.StatusNormalClosure)
+			dropped = websocket.CloseStatus(err) == -1 && ctx.Err() == nil
 			break
 		}
+		h.checkSize(LimitWSMessageBytes, wsMessageLimit, len(data))
+		if !h.allowInput(session, time.Now()) {
+			continue
+		}
+		if h.cfg.Passthrough {
+			h.relayRaw(session, msgType, data)
+			continue
+		}
 		if msgType != websocket.MessageText {
 			status = websocket.StatusUnsupportedData
 			reason = "text frame required"
@@ -371,6 +758,16 @@ func (h *Hub) handleController(ctx context.Context, conn *websocket.Conn, remote
 		}
 
 		if err := h.processControllerMessage(session, data); err != nil {
+			if errors.Is(err, errRefresh) {
+				h.handleRefresh(ctx, session)
+				continue
+			}
+			if errors.Is(err, errUnregistered) {
+				h.unregisterController(ctx, session)
+				status = websocket.StatusNormalClosure
+				reason = "unregistered"
+				break
+			}
 			session.logger.Warn("payload_invalid", "err", err.Error())
 			status = websocket.StatusPolicyViolation
 			reason = err.Error()
@@ -378,51 +775,166 @@ func (h *Hub) handleController(ctx context.Context, conn *websocket.Conn, remote
 		}
 	}
 
-	h.removeController(controllerID, session)
+	h.removeController(controllerID, session, reason)
 	session.logger.Info("disconnected", "status", status, "reason", reason)
+	h.emit("disconnected", roleController, controllerID, remote, "status", int(status), "reason", reason, "dropped", dropped)
 
 	return status, reason
 }
 
 func (h *Hub) processControllerMessage(session *controllerSession, payload []byte) error {
+	received := time.Now()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
 	var brief struct {
-		ID string `json:"id"`
+		Type    string  `json:"type"`
+		Seq     *uint64 `json:"seq"`
+		Epoch   uint64  `json:"epoch"`
+		Rev     *uint64 `json:"rev"`
+		Channel string  `json:"channel"`
 	}
 	if err := json.Unmarshal(payload, &brief); err != nil {
 		return fmt.Errorf("invalid payload: %w", err)
 	}
-	if brief.ID != "" && brief.ID != session.id {
-		return fmt.Errorf("id mismatch")
+	relay, err := h.checkIdentity(session, fields)
+	if err != nil {
+		return err
 	}
 
 	session.touch()
-	h.forwardToGame(payload, session)
+	if !relay {
+		return nil
+	}
+
+	if brief.Type == msgTypeUnregister {
+		return errUnregistered
+	}
+
+	if brief.Type == msgTypeRefresh {
+		return errRefresh
+	}
+
+	if brief.Type == msgTypeTelemetry {
+		h.recordTelemetry(session, payload)
+		return nil
+	}
+
+	if brief.Type == msgTypeHeartbeat {
+		return nil
+	}
+
+	if brief.Type == msgTypeTutorial {
+		h.recordTutorial(session, payload)
+		return nil
+	}
+
+	if brief.Type == msgTypeCalibration {
+		h.recordCalibration(session, payload)
+		return nil
+	}
+
+	if brief.Type == msgTypeStateAck {
+		if session.delta != nil && brief.Rev != nil {
+			session.delta.ack(brief.Channel, *brief.Rev)
+		}
+		return nil
+	}
+
+	if !h.inputAllowed(session, brief.Type) {
+		return nil
+	}
+
+	if h.isStaleEpoch(brief.Epoch) {
+		h.drops.stale.Add(1)
+		session.stats.stale.Add(1)
+		session.logger.Debug("input_stale_epoch", "epoch", brief.Epoch, "current_epoch", h.Epoch())
+		return nil
+	}
+
+	if brief.Seq != nil {
+		if !session.acceptSeq(*brief.Seq) {
+			session.logger.Debug("input_out_of_order", "seq", *brief.Seq, "last_seq", session.clientSeq)
+			return nil
+		}
+	}
+
+	epoch := h.Epoch()
+	hubSeq := h.nextSlotSeq(session.id)
+	fields["hubSeq"] = json.RawMessage(strconv.FormatUint(hubSeq, 10))
+	fields["epoch"] = json.RawMessage(strconv.FormatUint(epoch, 10))
+	stamped, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("encode payload: %w", err)
+	}
+	stamped, relay = h.intercept(session, stamped)
+	if !relay {
+		return nil
+	}
+	stamped, err = h.wrapRelay(session, stamped, received, hubSeq)
+	if err != nil {
+		return fmt.Errorf("encode envelope: %w", err)
+	}
+
+	h.forwardToGame(stamped, session, brief.Type, epoch)
 	return nil
 }
 
+// controllerNotice is a hub originated message written to a controller.
+type controllerNotice struct {
+	Type string            `json:"type"`
+	ID   string            `json:"id"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// writeController sends a JSON message directly to a controller connection.
+func (h *Hub) writeController(ctx context.Context, session *controllerSession, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, h.cfg.WriteTimeout)
+	defer cancel()
+	return session.conn.Write(writeCtx, websocket.MessageText, payload)
+}
+
+// nextSlotSeq advances the hub-assigned sequence for a slot. The counter is
+// kept per slot rather than per session so it stays monotonic across
+// controller reconnects.
+func (h *Hub) nextSlotSeq(slotID string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.slotSeq[slotID]++
+	return h.slotSeq[slotID]
+}
+
 // IssueControllerToken generates a signed token that authorises the given slot
-// to register as the supplied Persona user within the provided TTL.
-func (h *Hub) IssueControllerToken(slotID, userID, name, personality string, ttl time.Duration) (string, time.Time, error) {
+// to register as the supplied Persona user within the provided TTL. A
+// non-empty cohort places the controller in that experiment cohort.
+func (h *Hub) IssueControllerToken(slotID, userID, name, personality, cohort string, ttl time.Duration) (string, time.Time, error) {
 	slotID = strings.ToLower(strings.TrimSpace(slotID))
 	userID = strings.TrimSpace(userID)
 	name = strings.TrimSpace(name)
 	personality = strings.TrimSpace(personality)
 
-	if !controllerIDPattern.MatchString(slotID) {
-		return "", time.Time{}, fmt.Errorf("invalid slot id %q", slotID)
+	if err := h.cfg.IDPolicy.validateShape(slotID); err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid slot id %q: %w", slotID, err)
 	}
 	if userID == "" {
 		return "", time.Time{}, errors.New("user id required")
 	}
-	if ttl <= 0 {
-		ttl = time.Minute
+	if h.isUserBanned(userID) {
+		return "", time.Time{}, fmt.Errorf("%w: %s", ErrBanned, userID)
 	}
-
-	tokenValue, err := generateToken()
-	if err != nil {
-		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	if cohort != "" {
+		resolved, err := h.resolveCohort(cohort)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		cohort = resolved.Name
 	}
-	expiresAt := time.Now().Add(ttl)
 
 	profile := userProfile{
 		ID:          userID,
@@ -434,25 +946,53 @@ func (h *Hub) IssueControllerToken(slotID, userID, name, personality string, ttl
 	defer h.mu.Unlock()
 
 	h.cleanupExpiredTokensLocked(time.Now())
+	return h.storeTokenLocked(slotID, profile, cohort, ttl)
+}
+
+// storeTokenLocked issues a token bound to slotID, replacing any token
+// previously issued for the slot, and returns it with its expiry.
+func (h *Hub) storeTokenLocked(slotID string, profile userProfile, cohort string, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	now := time.Now()
+	info := issuedToken{
+		scope:     ScopeController,
+		subject:   slotID,
+		user:      profile,
+		cohort:    cohort,
+		expiresAt: now.Add(ttl),
+	}
+	tokenValue, err := h.mintToken(info, now)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	}
 
 	if previous := h.slotTokens[slotID]; previous != "" {
 		delete(h.tokens, previous)
 	}
 
-	h.tokens[tokenValue] = controllerToken{
-		slotID:    slotID,
-		user:      profile,
-		expiresAt: expiresAt,
-	}
+	h.tokens[tokenValue] = info
 	h.slotTokens[slotID] = tokenValue
+	h.notifyAssignmentsLocked()
+	h.emit("token_issued", roleController, slotID, "", "userId", profile.ID, "expiresAt", info.expiresAt.UTC())
 
-	return tokenValue, expiresAt, nil
+	return tokenValue, info.expiresAt, nil
 }
 
-func (h *Hub) resolveControllerToken(token string) (controllerToken, error) {
+// authenticateGame reports whether a game register message may take over
+// the game slot. Without a configured GameToken every game is accepted.
+func (h *Hub) authenticateGame(token string) bool {
+	if h.cfg.GameToken == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.GameToken)) == 1
+}
+
+func (h *Hub) resolveControllerToken(token string) (issuedToken, error) {
 	token = strings.TrimSpace(token)
 	if token == "" {
-		return controllerToken{}, errInvalidToken
+		return issuedToken{}, ErrTokenInvalid
 	}
 
 	h.mu.Lock()
@@ -462,30 +1002,36 @@ func (h *Hub) resolveControllerToken(token string) (controllerToken, error) {
 	h.cleanupExpiredTokensLocked(now)
 
 	info, ok := h.tokens[token]
-	if !ok {
-		return controllerToken{}, errInvalidToken
+	if !ok || info.scope != ScopeController {
+		return issuedToken{}, ErrTokenInvalid
 	}
 	if info.expiresAt.Before(now) {
 		delete(h.tokens, token)
-		if current, ok := h.slotTokens[info.slotID]; ok && current == token {
-			delete(h.slotTokens, info.slotID)
+		if current, ok := h.slotTokens[info.subject]; ok && current == token {
+			delete(h.slotTokens, info.subject)
 		}
-		return controllerToken{}, errExpiredToken
+		return issuedToken{}, ErrTokenExpired
 	}
 
 	return info, nil
 }
 
 func (h *Hub) cleanupExpiredTokensLocked(now time.Time) {
+	expired := false
 	for tokenValue, info := range h.tokens {
 		if info.expiresAt.After(now) {
 			continue
 		}
 		delete(h.tokens, tokenValue)
-		if current, ok := h.slotTokens[info.slotID]; ok && current == tokenValue {
-			delete(h.slotTokens, info.slotID)
+		if current, ok := h.slotTokens[info.subject]; ok && current == tokenValue {
+			delete(h.slotTokens, info.subject)
+			expired = true
+			h.emit("token_expired", roleController, info.subject, "", "userId", info.user.ID)
 		}
 	}
+	if expired {
+		h.notifyAssignmentsLocked()
+	}
 }
 
 // ControllerAssignments returns the known mapping between controller slots and users.
@@ -496,19 +1042,29 @@ func (h *Hub) ControllerAssignments() []ControllerAssignment {
 	now := time.Now()
 	h.cleanupExpiredTokensLocked(now)
 
-	bySlot := make(map[string]ControllerAssignment, len(h.controllers)+len(h.tokens))
+	bySlot := make(map[string]ControllerAssignment, len(h.controllers)+len(h.tokens)+len(h.restored))
+
+	for slotID, user := range h.restored {
+		bySlot[slotID] = ControllerAssignment{
+			SlotID:      slotID,
+			UserID:      user.ID,
+			Name:        user.Name,
+			Personality: user.Personality,
+		}
+	}
 
 	for _, token := range h.tokens {
-		if token.expiresAt.Before(now) {
+		if token.scope != ScopeController || token.expiresAt.Before(now) {
 			continue
 		}
-		assign := bySlot[token.slotID]
-		assign.SlotID = token.slotID
+		assign := bySlot[token.subject]
+		assign.SlotID = token.subject
 		assign.UserID = token.user.ID
 		assign.Name = token.user.Name
 		assign.Personality = token.user.Personality
 		assign.TokenExpiresAt = token.expiresAt
-		bySlot[token.slotID] = assign
+		assign.Cohort = token.cohort
+		bySlot[token.subject] = assign
 	}
 
 	for slotID, session := range h.controllers {
@@ -526,12 +1082,31 @@ func (h *Hub) ControllerAssignments() []ControllerAssignment {
 		if session.user.Personality != "" {
 			assign.Personality = session.user.Personality
 		}
+		session.lastSeenM.Lock()
+		lastSeen := session.lastSeen
+		session.lastSeenM.Unlock()
 		assign.Connected = true
-		assign.LastSeen = session.lastSeen
+		assign.LastSeen = lastSeen
+		assign.Stale = h.isStale(lastSeen, now)
+		assign.Tutorial = session.tutorial
+		assign.Cohort = session.cohort.Name
 		assign.TokenExpiresAt = time.Time{}
 		bySlot[slotID] = assign
 	}
 
+	for slotID, seq := range h.slotSeq {
+		assign, ok := bySlot[slotID]
+		if !ok {
+			continue
+		}
+		assign.LastSeq = seq
+		bySlot[slotID] = assign
+	}
+	for slotID, assign := range bySlot {
+		assign.Meta = h.slotMetaLocked(slotID)
+		bySlot[slotID] = assign
+	}
+
 	slots := make([]string, 0, len(bySlot))
 	for slotID := range bySlot {
 		slots = append(slots, slotID)
@@ -547,6 +1122,34 @@ func (h *Hub) ControllerAssignments() []ControllerAssignment {
 	return assignments
 }
 
+// RestoreAssignments seeds slot to user bindings recovered after a restart so
+// they are reported by ControllerAssignments until controllers reconnect.
+func (h *Hub) RestoreAssignments(assignments []ControllerAssignment) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, assign := range assignments {
+		slotID := strings.ToLower(strings.TrimSpace(assign.SlotID))
+		if h.cfg.IDPolicy.validateShape(slotID) != nil || strings.TrimSpace(assign.UserID) == "" {
+			continue
+		}
+		h.restored[slotID] = userProfile{
+			ID:          strings.TrimSpace(assign.UserID),
+			Name:        strings.TrimSpace(assign.Name),
+			Personality: strings.TrimSpace(assign.Personality),
+		}
+	}
+	h.notifyAssignmentsLocked()
+}
+
+// ClearRestoredAssignments drops bindings added by RestoreAssignments.
+func (h *Hub) ClearRestoredAssignments() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.restored)
+	h.notifyAssignmentsLocked()
+}
+
 func generateToken() (string, error) {
 	buf := make([]byte, 32)
 	if _, err := rand.Read(buf); err != nil {
@@ -555,55 +1158,144 @@ func generateToken() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
 
-func (h *Hub) forwardToGame(payload []byte, controller *controllerSession) {
-	h.mu.Lock()
-	game := h.game
-	h.mu.Unlock()
-
-	if game == nil {
+func (h *Hub) forwardToGame(payload []byte, controller *controllerSession, msgType string, epoch uint64) {
+	games := h.gameListeners()
+	if len(games) == 0 {
+		return
+	}
+	if h.isStaleEpoch(epoch) {
+		h.drops.stale.Add(1)
+		controller.stats.stale.Add(1)
 		return
 	}
 
-	game.enqueue(payload, controller.id)
+	h.observeRelay(controller.id, msgType, payload)
+	controller.stats.frames.Add(1)
+	controller.stats.bytes.Add(uint64(len(payload)))
+	// While shedding every controller is coalesced to cut the relay rate.
+	coalesce := controller.cohort.Coalesce || h.shed.active()
+	for i, game := range games {
+		// Merge counters follow the primary (or first) listener only.
+		if game.relay(payload, controller.id, msgType, coalesce) && i == 0 {
+			controller.stats.merged.Add(1)
+		}
+	}
 }
 
 func (h *Hub) addController(session *controllerSession) (*controllerSession, error) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	if existing := h.controllers[session.id]; existing != nil {
-		h.controllers[session.id] = session
-		return existing, nil
-	}
-
-	if len(h.controllers) >= h.cfg.MaxControllers {
+	existing := h.controllers[session.id]
+	if existing == nil && len(h.controllers) >= h.cfg.MaxControllers {
+		h.mu.Unlock()
 		return nil, fmt.Errorf("controller limit reached")
 	}
 
 	h.controllers[session.id] = session
-	return nil, nil
+	h.notifyAssignmentsLocked()
+	push := h.rosterChangeLocked(rosterJoin, session, existing != nil, "")
+	h.mu.Unlock()
+
+	push.deliver()
+	return existing, nil
 }
 
-func (h *Hub) removeController(id string, session *controllerSession) {
+// unregisterController frees the slot held by session at the client's
+// request: the resume token is revoked and any restored binding dropped so
+// the seat can be claimed again straight away. The client receives an ack
+// before the connection is closed.
+func (h *Hub) unregisterController(ctx context.Context, session *controllerSession) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	if token, ok := h.slotTokens[session.id]; ok {
+		delete(h.tokens, token)
+		delete(h.slotTokens, session.id)
+	}
+	delete(h.restored, session.id)
+	var push rosterPush
+	if current, ok := h.controllers[session.id]; ok && current == session {
+		delete(h.controllers, session.id)
+		push = h.rosterChangeLocked(rosterLeave, session, false, "unregistered")
+	}
+	h.notifyAssignmentsLocked()
+	h.mu.Unlock()
+	push.deliver()
+
+	session.logger.Info("unregistered")
+
+	if err := h.writeController(ctx, session, controllerNotice{Type: "unregistered", ID: session.id}); err != nil {
+		session.logger.Debug("unregister_ack_failed", "err", err.Error())
+	}
+}
+
+func (h *Hub) removeController(id string, session *controllerSession, reason string) {
+	h.mu.Lock()
+	var push rosterPush
 	if current, ok := h.controllers[id]; ok && current == session {
 		delete(h.controllers, id)
+		h.notifyAssignmentsLocked()
+		push = h.rosterChangeLocked(rosterLeave, session, false, reason)
 	}
+	h.mu.Unlock()
+	push.deliver()
+}
+
+// gameConn is the transport of a game listener: a WebSocket, or a Socket.IO
+// client (see socketIOConn).
+type gameConn interface {
+	Read(ctx context.Context) (websocket.MessageType, []byte, error)
+	Write(ctx context.Context, typ websocket.MessageType, p []byte) error
+	Close(code websocket.StatusCode, reason string) error
+}
+
+// controllerConn is the transport of a controller session: a WebSocket, or
+// the HTTP long-poll fallback (see pollConn).
+type controllerConn interface {
+	Read(ctx context.Context) (websocket.MessageType, []byte, error)
+	Write(ctx context.Context, typ websocket.MessageType, p []byte) error
+	Close(code websocket.StatusCode, reason string) error
+	Ping(ctx context.Context) error
 }
 
 type controllerSession struct {
 	id        string
-	conn      *websocket.Conn
+	conn      controllerConn
 	remoteIP  string
 	lastSeen  time.Time
 	logger    *slog.Logger
 	lastSeenM sync.Mutex
 	user      userProfile
+	cohort    Cohort
+	stats     *cohortCounters
+	outbox    *controllerOutbox
+	delta     *deltaSet
+	channels  map[string]struct{}
+	token     string // guarded by Hub.mu
+	rtt       atomic.Int64
+	latency   latencyEstimate
+
+	connectedAt time.Time
+	telemetry   *Telemetry // guarded by Hub.mu
+	hiddenSince time.Time  // guarded by Hub.mu; zero while the page is visible
+
+	tutorial     TutorialState           // guarded by Hub.mu
+	calibrations map[string]*Calibration // guarded by Hub.mu; by kind
+
+	// clientSeq tracks the controller supplied sequence; only accessed from
+	// the session read loop.
+	clientSeq    uint64
+	hasClientSeq bool
+	// forbidden records the disallowed types already warned about; only
+	// accessed from the session read loop.
+	forbidden map[string]struct{}
+	// input rate limits the frames read from the controller; only accessed
+	// from the session read loop. inputs and stateFlushes count frames
+	// read and state batches written for the soft limit monitor.
+	input        inputBucket
+	inputs       atomic.Uint64
+	stateFlushes atomic.Uint64
 }
 
-func newControllerSession(conn *websocket.Conn, id, remote string, user userProfile, logger *slog.Logger) *controllerSession {
-	logArgs := []any{"role", roleController, "id", id, "remote_ip", remote}
+func newControllerSession(conn controllerConn, id, remote string, user userProfile, cohort Cohort, stats *cohortCounters, logger *slog.Logger) *controllerSession {
+	logArgs := []any{"role", roleController, "id", id, "remote_ip", remote, "cohort", cohort.Name}
 	if user.ID != "" {
 		logArgs = append(logArgs, "user_id", user.ID)
 	}
@@ -613,7 +1305,13 @@ func newControllerSession(conn *websocket.Conn, id, remote string, user userProf
 		remoteIP: remote,
 		lastSeen: time.Now(),
 		user:     user,
+		cohort:   cohort,
+		stats:    stats,
+		outbox:   newControllerOutbox(),
 		logger:   logger.With(logArgs...),
+
+		connectedAt: time.Now(),
+		tutorial:    TutorialPending,
 	}
 }
 
@@ -623,99 +1321,246 @@ func (c *controllerSession) touch() {
 	c.lastSeenM.Unlock()
 }
 
+// acceptSeq reports whether seq is newer than the last accepted frame of this
+// session, recording it when it is.
+func (c *controllerSession) acceptSeq(seq uint64) bool {
+	if c.hasClientSeq && seq <= c.clientSeq {
+		return false
+	}
+	c.clientSeq = seq
+	c.hasClientSeq = true
+	return true
+}
+
 type gameSession struct {
-	conn         *websocket.Conn
+	conn         gameConn
 	remoteIP     string
-	send         chan []byte
+	connectedAt  time.Time
 	ctx          context.Context
 	cancel       context.CancelFunc
 	writeTimeout time.Duration
 	logger       *slog.Logger
 	closeOnce    sync.Once
+	// retired is set once a newer game took over; see handover.go.
+	retired atomic.Bool
+	// written counts frames delivered, for the watchdog's stall check.
+	written atomic.Uint64
+
+	queueMu   sync.Mutex
+	queue     []queuedFrame
+	queueSize int
+	policy    QueuePolicy
+	drops     *queueCounters
+	notify    chan struct{}
+	coalesce  *inputCoalescer
+	priority  []queuedFrame
+	urgent    map[string]struct{}
 }
 
-func newGameSession(ctx context.Context, conn *websocket.Conn, remote string, queueSize int, writeTimeout time.Duration, logger *slog.Logger) *gameSession {
+func newGameSession(ctx context.Context, conn gameConn, remote string, cfg Config, drops *queueCounters, logger *slog.Logger) *gameSession {
+	queueSize := cfg.RelayQueueSize
 	if queueSize <= 0 {
 		queueSize = 32
 	}
+	if drops == nil {
+		drops = &queueCounters{}
+	}
 	sessionCtx, cancel := context.WithCancel(ctx)
 	return &gameSession{
 		conn:         conn,
 		remoteIP:     remote,
-		send:         make(chan []byte, queueSize),
+		connectedAt:  time.Now(),
 		ctx:          sessionCtx,
 		cancel:       cancel,
-		writeTimeout: writeTimeout,
+		writeTimeout: cfg.WriteTimeout,
 		logger:       logger.With("role", roleGame, "id", "", "remote_ip", remote),
+		queue:        make([]queuedFrame, 0, queueSize),
+		queueSize:    queueSize,
+		policy:       cfg.QueuePolicy,
+		drops:        drops,
+		notify:       make(chan struct{}, 1),
+		coalesce:     newInputCoalescer(cfg.CoalesceInput || cfg.LoadShedding || anyCohortCoalesces(cfg.Cohorts), cfg.RelayInterval),
+		urgent:       typeSet(cfg.PriorityTypes),
 	}
 }
 
 func (g *gameSession) startWriter() {
-	go func() {
+	if g.coalesce != nil {
+		crash.Go(func() { g.runCoalesceFlush() })
+	}
+	crash.Go(func() {
 		for {
 			select {
 			case <-g.ctx.Done():
 				return
-			case msg, ok := <-g.send:
+			case <-g.notify:
+			}
+			for {
+				frame, ok := g.dequeue()
 				if !ok {
-					return
+					break
+				}
+				msgType := websocket.MessageText
+				if frame.binary {
+					msgType = websocket.MessageBinary
 				}
 				writeCtx, cancel := context.WithTimeout(g.ctx, g.writeTimeout)
-				err := g.conn.Write(writeCtx, websocket.MessageText, msg)
+				err := g.conn.Write(writeCtx, msgType, frame.data)
 				cancel()
 				if err != nil {
 					g.logger.Error("write_failed", "err", err.Error())
 					g.close(websocket.StatusInternalError, "relay failed")
 					return
 				}
+				g.written.Add(1)
 			}
 		}
-	}()
+	})
 }
 
-func (g *gameSession) enqueue(payload []byte, controllerID string) {
-	data := cloneBytes(payload)
-	select {
-	case g.send <- data:
+// relay hands a controller frame to the game. Priority types skip both
+// coalescing and the normal queue; other frames are buffered for the current
+// tick when coalesce is set. It reports whether the frame replaced an older
+// buffered one.
+func (g *gameSession) relay(payload []byte, controllerID, msgType string, coalesce bool) bool {
+	if _, ok := g.urgent[msgType]; ok {
+		g.enqueuePriority(payload, controllerID)
+		return false
+	}
+	if g.coalesce == nil || !coalesce {
+		g.enqueue(payload, controllerID)
+		return false
+	}
+	if g.ctx.Err() != nil {
+		return false
+	}
+	frame := queuedFrame{data: cloneBytes(payload), controllerID: controllerID}
+	if !g.coalesce.add(frame, msgType) {
+		return false
+	}
+	g.drops.merged.Add(1)
+	return true
+}
+
+// enqueuePriority places a frame on the high-priority lane, which the writer
+// drains before the normal queue. The lane shares the queue capacity but is
+// never subject to the overflow policy; only its own oldest entry is dropped.
+func (g *gameSession) enqueuePriority(payload []byte, controllerID string) {
+	if g.ctx.Err() != nil {
 		return
-	default:
 	}
+	frame := queuedFrame{data: cloneBytes(payload), controllerID: controllerID}
+
+	g.queueMu.Lock()
+	if len(g.priority) >= g.queueSize {
+		g.priority = g.priority[1:]
+		g.drops.priority.Add(1)
+		g.logger.Warn("priority_queue_drop_oldest", "controller_id", controllerID)
+	}
+	g.priority = append(g.priority, frame)
+	g.queueMu.Unlock()
 
 	select {
-	case <-g.send:
-		g.logger.Warn("queue_drop_oldest", "controller_id", controllerID)
+	case g.notify <- struct{}{}:
 	default:
 	}
+}
+
+func (g *gameSession) enqueue(payload []byte, controllerID string) {
+	g.enqueueFrame(queuedFrame{data: cloneBytes(payload), controllerID: controllerID})
+}
+
+// enqueueFrame appends frame to the normal queue, applying the overflow
+// policy when it is full. The frame's data must not be modified afterwards.
+func (g *gameSession) enqueueFrame(frame queuedFrame) {
+	if g.ctx.Err() != nil {
+		return
+	}
+
+	g.queueMu.Lock()
+	if len(g.queue) >= g.queueSize && !g.makeRoomLocked(frame) {
+		g.queueMu.Unlock()
+		if g.policy == QueueClose {
+			g.close(websocket.StatusTryAgainLater, "relay queue overflow")
+		}
+		return
+	}
+	g.queue = append(g.queue, frame)
+	g.queueMu.Unlock()
 
 	select {
-	case g.send <- data:
+	case g.notify <- struct{}{}:
 	default:
-		g.logger.Warn("queue_drop_latest", "controller_id", controllerID)
 	}
 }
 
+// makeRoomLocked applies the configured policy to a full queue. It reports
+// whether the incoming frame should still be appended.
+func (g *gameSession) makeRoomLocked(frame queuedFrame) bool {
+	switch g.policy {
+	case QueueDropNewest:
+		g.drops.dropNewest.Add(1)
+		g.logger.Warn("queue_drop_latest", "controller_id", frame.controllerID)
+		return false
+	case QueueClose:
+		g.drops.closed.Add(1)
+		g.logger.Warn("queue_overflow_close", "controller_id", frame.controllerID, "depth", len(g.queue))
+		return false
+	case QueueCoalesce:
+		for i, queued := range g.queue {
+			if queued.controllerID != frame.controllerID {
+				continue
+			}
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			g.drops.coalesced.Add(1)
+			return true
+		}
+	}
+	g.queue = g.queue[1:]
+	g.drops.dropOldest.Add(1)
+	g.logger.Warn("queue_drop_oldest", "controller_id", frame.controllerID)
+	return true
+}
+
+func (g *gameSession) dequeue() (queuedFrame, bool) {
+	g.queueMu.Lock()
+	defer g.queueMu.Unlock()
+	if len(g.priority) > 0 {
+		frame := g.priority[0]
+		g.priority[0] = queuedFrame{}
+		g.priority = g.priority[1:]
+		return frame, true
+	}
+	if len(g.queue) == 0 {
+		return queuedFrame{}, false
+	}
+	frame := g.queue[0]
+	g.queue[0] = queuedFrame{}
+	g.queue = g.queue[1:]
+	return frame, true
+}
+
+func (g *gameSession) depth() int {
+	g.queueMu.Lock()
+	defer g.queueMu.Unlock()
+	return len(g.queue) + len(g.priority)
+}
+
 func (g *gameSession) close(status websocket.StatusCode, reason string) {
 	g.closeOnce.Do(func() {
 		g.cancel()
-		close(g.send)
 		_ = g.conn.Close(status, reason)
 	})
 }
 
-func remoteAddr(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		for _, p := range strings.Split(xff, ",") {
-			candidate := strings.TrimSpace(p)
-			if candidate != "" {
-				return candidate
-			}
+func typeSet(types []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			set[t] = struct{}{}
 		}
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
+	return set
 }
 
 func closeStatusFromError(err error, fallback websocket.StatusCode) (websocket.StatusCode, string) {
@@ -0,0 +1,116 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IDMismatchPolicy selects what happens to a controller frame whose identity
+// field names a slot other than the one the controller registered as.
+type IDMismatchPolicy string
+
+const (
+	// IDMismatchReject closes the controller connection.
+	IDMismatchReject IDMismatchPolicy = "reject"
+	// IDMismatchDrop discards the frame and keeps the connection.
+	IDMismatchDrop IDMismatchPolicy = "drop"
+	// IDMismatchRewrite overwrites the identity with the registered slot
+	// and relays the frame, for firmwares that number players differently.
+	IDMismatchRewrite IDMismatchPolicy = "rewrite"
+)
+
+// ParseIDMismatchPolicy validates a policy name. An empty value selects
+// reject.
+func ParseIDMismatchPolicy(raw string) (IDMismatchPolicy, error) {
+	switch policy := IDMismatchPolicy(strings.ToLower(strings.TrimSpace(raw))); policy {
+	case "":
+		return IDMismatchReject, nil
+	case IDMismatchReject, IDMismatchDrop, IDMismatchRewrite:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown id mismatch policy %q", raw)
+	}
+}
+
+// NormalizeSlotID maps the ways a player slot is written, "p1", "P1", "1",
+// onto the slot id "p1" and its number. It reports false for anything but
+// players 1-4.
+func NormalizeSlotID(raw string) (string, int, bool) {
+	slot := strings.ToLower(strings.TrimSpace(raw))
+	if slot == "" {
+		return "", 0, false
+	}
+	if strings.HasPrefix(slot, "p") {
+		slot = strings.TrimPrefix(slot, "p")
+	}
+	num, err := strconv.Atoi(slot)
+	if err != nil || num < 1 || num > 4 {
+		return "", 0, false
+	}
+	return "p" + strconv.Itoa(num), num, true
+}
+
+// claimedID returns the slot named by the first configured identity field
+// present in fields, along with that field's name. String values are
+// compared case-insensitively; numbers are compared in their decimal form.
+func (h *Hub) claimedID(fields map[string]json.RawMessage) (id, field string, ok bool) {
+	for _, name := range h.cfg.IDFields {
+		raw, present := fields[name]
+		if !present {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", name, true
+		}
+		switch v := value.(type) {
+		case string:
+			return strings.ToLower(strings.TrimSpace(v)), name, true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), name, true
+		case nil:
+			continue
+		default:
+			return "", name, true
+		}
+	}
+	return "", "", false
+}
+
+// checkIdentity applies the mismatch policy to a controller frame. It
+// reports whether the frame should still be relayed; fields is updated in
+// place so the game always sees the registered slot under "id", whether or
+// not the controller sent one. Games can therefore attribute frames by "id"
+// without trusting the controller.
+func (h *Hub) checkIdentity(session *controllerSession, fields map[string]json.RawMessage) (bool, error) {
+	canonical, err := json.Marshal(session.id)
+	if err != nil {
+		return false, fmt.Errorf("encode id: %w", err)
+	}
+	claimed, field, ok := h.claimedID(fields)
+	if ok && !claimsSlot(claimed, session.id) {
+		switch h.cfg.IDMismatch {
+		case IDMismatchDrop:
+			session.logger.Debug("input_id_mismatch_dropped", "field", field, "claimed", claimed)
+			return false, nil
+		case IDMismatchRewrite:
+			fields[field] = canonical
+		default:
+			return false, fmt.Errorf("id mismatch")
+		}
+	}
+	fields["id"] = canonical
+	return true, nil
+}
+
+// claimsSlot reports whether a claimed identity names slotID, either
+// verbatim or as a player number: {"player": 1} claims slot "p1".
+func claimsSlot(claimed, slotID string) bool {
+	if claimed == slotID {
+		return true
+	}
+	slot, _, ok := NormalizeSlotID(claimed)
+	return ok && slot == slotID
+}
@@ -0,0 +1,86 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// controllerActivityEvent tells the game a controller page went to the
+// background or came back. A background controller is still connected and
+// keeps its slot for GraceMs, but may not send input.
+type controllerActivityEvent struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Reason    string `json:"reason,omitempty"`
+	GraceMs   int64  `json:"graceMs,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// RunIdleMonitor disconnects controllers that have sent nothing for
+// IdleTimeout until ctx is done. Controllers that reported their page hidden
+// are spared for VisibilityGrace so a player glancing at another app does not
+// lose the slot. It is a no-op when IdleTimeout is not set.
+func (h *Hub) RunIdleMonitor(ctx context.Context) {
+	if h.cfg.IdleTimeout <= 0 {
+		return
+	}
+	interval := h.cfg.IdleTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, session := range h.idleControllers(now) {
+				h.evict(ctx, session, "idle", "idle timeout", ReasonIdleTimeout)
+			}
+		}
+	}
+}
+
+func (h *Hub) idleControllers(now time.Time) []*controllerSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var idle []*controllerSession
+	for _, session := range h.controllers {
+		if !session.hiddenSince.IsZero() && now.Sub(session.hiddenSince) < h.cfg.VisibilityGrace {
+			continue
+		}
+		session.lastSeenM.Lock()
+		lastSeen := session.lastSeen
+		session.lastSeenM.Unlock()
+		if now.Sub(lastSeen) > h.cfg.IdleTimeout {
+			idle = append(idle, session)
+		}
+	}
+	return idle
+}
+
+// notifyActivity forwards a controller_inactive or controller_active control
+// message to the game listeners.
+func (h *Hub) notifyActivity(session *controllerSession, active bool) {
+	event := controllerActivityEvent{
+		Type:      "controller_active",
+		ID:        session.id,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if !active {
+		event.Type = "controller_inactive"
+		event.Reason = "hidden"
+		event.GraceMs = h.cfg.VisibilityGrace.Milliseconds()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		session.logger.Error("activity_event_encode_failed", "err", err.Error())
+		return
+	}
+	h.enqueueToListeners(payload)
+	h.emit(event.Type, roleController, session.id, session.remoteIP)
+}
@@ -0,0 +1,209 @@
+package hub
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const defaultIDCharset = "abcdefghijklmnopqrstuvwxyz0123456789_-"
+
+// IDPolicy describes which controller and slot IDs the hub accepts.
+type IDPolicy struct {
+	MinLength int
+	MaxLength int
+	// Charset lists every permitted character.
+	Charset string
+	// ReservedPrefixes may not be used by client supplied IDs; they are kept
+	// for IDs issued by the hub itself.
+	ReservedPrefixes []string
+}
+
+// DefaultIDPolicy matches the historical `^[a-z0-9_-]{1,32}$` rule and
+// reserves the prefix used for generated anonymous IDs.
+func DefaultIDPolicy() IDPolicy {
+	return IDPolicy{
+		MinLength:        1,
+		MaxLength:        32,
+		Charset:          defaultIDCharset,
+		ReservedPrefixes: []string{anonymousIDPrefix},
+	}
+}
+
+func (p IDPolicy) withDefaults() IDPolicy {
+	def := DefaultIDPolicy()
+	if p.MinLength <= 0 {
+		p.MinLength = def.MinLength
+	}
+	if p.MaxLength <= 0 {
+		p.MaxLength = def.MaxLength
+	}
+	if p.MaxLength < p.MinLength {
+		p.MaxLength = p.MinLength
+	}
+	if p.Charset == "" {
+		p.Charset = def.Charset
+	}
+	// Clients must never claim a generated anonymous ID, whatever else the
+	// operator reserves.
+	if !slices.Contains(p.ReservedPrefixes, anonymousIDPrefix) {
+		p.ReservedPrefixes = append(slices.Clip(p.ReservedPrefixes), anonymousIDPrefix)
+	}
+	return p
+}
+
+// CheckAnonymous reports whether anonymous IDs, "anon-" and a random
+// suffix, can satisfy the policy, so ALLOW_ANONYMOUS fails at startup rather
+// than on every registration.
+func (p IDPolicy) CheckAnonymous() error {
+	p = p.withDefaults()
+	if err := p.checkChars(anonymousIDPrefix); err != nil {
+		return fmt.Errorf("anonymous id prefix %q: %w", anonymousIDPrefix, err)
+	}
+	if idSuffixCharset(p.Charset) == "" {
+		return errors.New("id charset has no alphanumeric characters for anonymous ids")
+	}
+	if len(anonymousIDPrefix) >= p.MaxLength {
+		return fmt.Errorf("id max length %d leaves no room after the anonymous prefix %q", p.MaxLength, anonymousIDPrefix)
+	}
+	return nil
+}
+
+// Validate checks a client supplied ID against the policy.
+func (p IDPolicy) Validate(id string) error {
+	if err := p.validateShape(id); err != nil {
+		return err
+	}
+	for _, prefix := range p.ReservedPrefixes {
+		if prefix != "" && strings.HasPrefix(id, prefix) {
+			return fmt.Errorf("id prefix %q is reserved", prefix)
+		}
+	}
+	return nil
+}
+
+// validateShape checks length and charset only; hub generated IDs skip the
+// reserved prefix rule.
+func (p IDPolicy) validateShape(id string) error {
+	if n := len(id); n < p.MinLength || n > p.MaxLength {
+		return fmt.Errorf("id length must be between %d and %d", p.MinLength, p.MaxLength)
+	}
+	return p.checkChars(id)
+}
+
+func (p IDPolicy) checkChars(id string) error {
+	for _, r := range id {
+		if !strings.ContainsRune(p.Charset, r) {
+			return fmt.Errorf("id contains disallowed character %q", r)
+		}
+	}
+	return nil
+}
+
+// idSuffixCharset keeps the letters and digits of charset; punctuation is
+// left out of generated suffixes.
+func idSuffixCharset(charset string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, charset)
+}
+
+// IDGenerator produces candidate IDs for anonymous controllers. The hub
+// rejects candidates already in use, so implementations only need to be
+// reasonably unique.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+const anonymousIDPrefix = "anon-"
+
+// RandomIDGenerator draws random suffixes from the policy charset.
+type RandomIDGenerator struct {
+	Prefix string
+	Length int
+	Policy IDPolicy
+}
+
+// NewID implements IDGenerator. The suffix is shortened or lengthened so
+// the whole ID stays within the policy's length bounds.
+func (g RandomIDGenerator) NewID() (string, error) {
+	policy := g.Policy.withDefaults()
+	charset := idSuffixCharset(policy.Charset)
+	if charset == "" {
+		return "", errors.New("id charset has no alphanumeric characters")
+	}
+	length := g.Length
+	if length <= 0 {
+		length = 8
+	}
+	length = min(length, policy.MaxLength-len(g.Prefix))
+	length = max(length, policy.MinLength-len(g.Prefix))
+	if length <= 0 {
+		return "", fmt.Errorf("id max length %d leaves no room after prefix %q", policy.MaxLength, g.Prefix)
+	}
+
+	var b strings.Builder
+	b.WriteString(g.Prefix)
+	limit := big.NewInt(int64(len(charset)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(charset[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+const maxIDAttempts = 16
+
+// allocateAnonymousID reserves a generated ID that is not bound to any live
+// controller, token, restored assignment, or outstanding reservation.
+func (h *Hub) allocateAnonymousID() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := h.cfg.IDGenerator.NewID()
+		if err != nil {
+			return "", fmt.Errorf("generate id: %w", err)
+		}
+		if err := h.cfg.IDPolicy.validateShape(id); err != nil {
+			return "", fmt.Errorf("generated id %q: %w", id, err)
+		}
+		if h.idInUseLocked(id) {
+			continue
+		}
+		h.allocated[id] = struct{}{}
+		return id, nil
+	}
+	return "", errors.New("could not allocate a unique controller id")
+}
+
+func (h *Hub) releaseAnonymousID(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.allocated, id)
+}
+
+func (h *Hub) idInUseLocked(id string) bool {
+	if _, ok := h.controllers[id]; ok {
+		return true
+	}
+	if _, ok := h.slotTokens[id]; ok {
+		return true
+	}
+	if _, ok := h.restored[id]; ok {
+		return true
+	}
+	_, ok := h.allocated[id]
+	return ok
+}
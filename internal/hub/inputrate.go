@@ -0,0 +1,48 @@
+package hub
+
+import "time"
+
+// inputThrottleLogEvery spaces the input_rate_limited warnings of one
+// session; each reports the frames dropped since the previous one.
+const inputThrottleLogEvery = 10 * time.Second
+
+// inputBucket is the token bucket limiting the frames one controller may
+// send. It holds up to one second of frames so short bursts pass; only
+// accessed from the session read loop.
+type inputBucket struct {
+	tokens   float64
+	refilled time.Time
+	dropped  uint64
+	lastWarn time.Time
+}
+
+// allowInput reports whether the session may send another frame at now,
+// counting it towards the controller_input_rate gauge either way.
+func (h *Hub) allowInput(session *controllerSession, now time.Time) bool {
+	session.inputs.Add(1)
+	rate := h.cfg.InputRateHz
+	if rate <= 0 {
+		return true
+	}
+
+	bucket := &session.input
+	if bucket.refilled.IsZero() {
+		bucket.tokens = float64(rate)
+	} else {
+		bucket.tokens = min(float64(rate), bucket.tokens+now.Sub(bucket.refilled).Seconds()*float64(rate))
+	}
+	bucket.refilled = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true
+	}
+
+	h.drops.throttled.Add(1)
+	bucket.dropped++
+	if now.Sub(bucket.lastWarn) >= inputThrottleLogEvery {
+		session.logger.Warn("input_rate_limited", "limit_hz", rate, "dropped", bucket.dropped)
+		bucket.dropped = 0
+		bucket.lastWarn = now
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package hub
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// RelayInterceptor inspects a controller frame after the hub has stamped it
+// and before it reaches the game listeners. It may return the payload
+// unchanged, a rewritten payload, or nil to drop the frame quietly; an error
+// drops the frame and is logged. Interceptors run on the controller read
+// loop and must not block.
+type RelayInterceptor func(slotID string, payload []byte) ([]byte, error)
+
+// UseInterceptor appends interceptor to the chain. Interceptors run in the
+// order they were added, each receiving the previous one's output.
+func (h *Hub) UseInterceptor(interceptor RelayInterceptor) {
+	if interceptor == nil {
+		return
+	}
+	h.interceptMu.Lock()
+	defer h.interceptMu.Unlock()
+
+	var chain []RelayInterceptor
+	if current := h.intercepts.Load(); current != nil {
+		chain = append(chain, *current...)
+	}
+	chain = append(chain, interceptor)
+	h.intercepts.Store(&chain)
+}
+
+// intercept runs the chain over payload and reports whether the frame should
+// still be relayed.
+func (h *Hub) intercept(session *controllerSession, payload []byte) ([]byte, bool) {
+	chain := h.intercepts.Load()
+	if chain == nil {
+		return payload, true
+	}
+	for _, interceptor := range *chain {
+		out, err := interceptor(session.id, payload)
+		if err != nil {
+			h.drops.filtered.Add(1)
+			session.logger.Warn("input_intercept_rejected", "err", err.Error())
+			return nil, false
+		}
+		if out == nil {
+			h.drops.filtered.Add(1)
+			session.logger.Debug("input_intercept_dropped")
+			return nil, false
+		}
+		payload = out
+	}
+	return payload, true
+}
+
+// ServerTimestamp returns an interceptor that records the time the hub
+// relayed each frame, in Unix milliseconds, under field.
+func ServerTimestamp(field string) RelayInterceptor {
+	return func(_ string, payload []byte) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, err
+		}
+		fields[field] = json.RawMessage(strconv.FormatInt(time.Now().UnixMilli(), 10))
+		return json.Marshal(fields)
+	}
+}
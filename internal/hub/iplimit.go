@@ -0,0 +1,187 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// ipPruneThreshold is the tracked address count above which idle entries
+// are swept.
+const ipPruneThreshold = 1024
+
+type ipState struct {
+	conns        int
+	pending      int
+	failures     int
+	windowStart  time.Time
+	lockedUntil  time.Time
+	lockoutNoted bool
+}
+
+// ipLimiter caps concurrent connections per remote IP, caps how many of them
+// may be waiting to register, and locks out addresses that keep failing
+// registration.
+type ipLimiter struct {
+	maxConns      int
+	maxPending    int
+	failureLimit  int
+	failureWindow time.Duration
+	lockout       time.Duration
+
+	mu      sync.Mutex
+	ips     map[string]*ipState
+	pending int
+}
+
+func newIPLimiter(cfg Config) *ipLimiter {
+	return &ipLimiter{
+		maxConns:      cfg.MaxConnsPerIP,
+		maxPending:    cfg.MaxPendingPerIP,
+		failureLimit:  cfg.RegisterFailureLimit,
+		failureWindow: cfg.RegisterFailureWindow,
+		lockout:       cfg.RegisterLockout,
+		ips:           make(map[string]*ipState),
+	}
+}
+
+// acquire reserves a connection for ip. It returns a non-zero retry delay
+// when the address is locked out, or ok=false when it has too many open
+// connections.
+func (l *ipLimiter) acquire(ip string, now time.Time) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.stateLocked(ip)
+	if now.Before(st.lockedUntil) {
+		return st.lockedUntil.Sub(now), false
+	}
+	if l.maxConns > 0 && st.conns >= l.maxConns {
+		return 0, false
+	}
+	st.conns++
+	return 0, true
+}
+
+func (l *ipLimiter) release(ip string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if st, ok := l.ips[ip]; ok {
+		st.conns--
+		l.pruneLocked(ip, st, now)
+	}
+}
+
+// beginRegister reserves a place for a connection from ip that has not yet
+// registered. It reports false when the address already has maxPending such
+// connections, so bare sockets cannot pile up waiting on RegisterTimeout.
+func (l *ipLimiter) beginRegister(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.stateLocked(ip)
+	if l.maxPending > 0 && st.pending >= l.maxPending {
+		return false
+	}
+	st.pending++
+	l.pending++
+	return true
+}
+
+// endRegister releases the place taken by beginRegister once the register
+// message has been read or the connection has failed.
+func (l *ipLimiter) endRegister(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if st, ok := l.ips[ip]; ok && st.pending > 0 {
+		st.pending--
+		l.pending--
+	}
+}
+
+// pendingCounts returns the connections waiting to register, in total and
+// per address.
+func (l *ipLimiter) pendingCounts() (int, map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	byIP := make(map[string]int)
+	for ip, st := range l.ips {
+		if st.pending > 0 {
+			byIP[ip] = st.pending
+		}
+	}
+	return l.pending, byIP
+}
+
+// peaks returns the highest open connection and pending register counts
+// of any single address.
+func (l *ipLimiter) peaks() (conns, pending int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, st := range l.ips {
+		conns = max(conns, st.conns)
+		pending = max(pending, st.pending)
+	}
+	return conns, pending
+}
+
+// fail records a failed register attempt. It reports true the first time
+// the address crosses the limit and gets locked out.
+func (l *ipLimiter) fail(ip string, now time.Time) bool {
+	if l.failureLimit <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.stateLocked(ip)
+	if now.Sub(st.windowStart) > l.failureWindow {
+		st.windowStart = now
+		st.failures = 0
+		st.lockoutNoted = false
+	}
+	st.failures++
+	if st.failures < l.failureLimit {
+		return false
+	}
+	st.lockedUntil = now.Add(l.lockout)
+	if st.lockoutNoted {
+		return false
+	}
+	st.lockoutNoted = true
+	return true
+}
+
+func (l *ipLimiter) stateLocked(ip string) *ipState {
+	st, ok := l.ips[ip]
+	if !ok {
+		if len(l.ips) >= ipPruneThreshold {
+			now := time.Now()
+			for key, candidate := range l.ips {
+				l.pruneLocked(key, candidate, now)
+			}
+		}
+		st = &ipState{}
+		l.ips[ip] = st
+	}
+	return st
+}
+
+func (l *ipLimiter) pruneLocked(ip string, st *ipState, now time.Time) {
+	if st.conns <= 0 && now.After(st.lockedUntil) && now.Sub(st.windowStart) > l.failureWindow {
+		delete(l.ips, ip)
+	}
+}
+
+// registerFailed records a failed register attempt from remote and logs the
+// start of a lockout.
+func (h *Hub) registerFailed(remote string) {
+	if h.limiter.fail(remote, time.Now()) {
+		h.log.Warn("register_lockout", "remote_ip", remote, "duration", h.cfg.RegisterLockout.String())
+		h.emit("register_lockout", "", "", remote, "duration", h.cfg.RegisterLockout.String())
+	}
+}
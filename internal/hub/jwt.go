@@ -0,0 +1,203 @@
+package hub
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TokenIssuer is the iss claim of every JWT the hub signs.
+const TokenIssuer = "cgb-io-hub"
+
+// TokenSigner signs hub tokens as EdDSA (Ed25519) JWTs so other services can
+// verify them against the published key set without calling the hub. Tokens
+// stay registered in the hub as well, so revocation and expiry work the same
+// as for opaque tokens.
+type TokenSigner struct {
+	key    ed25519.PrivateKey
+	kid    string
+	verify []JWK
+}
+
+// JWK is an Ed25519 public key in JSON Web Key form (RFC 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewTokenSigner signs with key and additionally publishes verifyOnly, e.g.
+// the previous key while tokens signed with it are still live.
+func NewTokenSigner(key ed25519.PrivateKey, verifyOnly ...ed25519.PublicKey) *TokenSigner {
+	signer := &TokenSigner{key: key}
+	own := newJWK(key.Public().(ed25519.PublicKey))
+	signer.kid = own.Kid
+	signer.verify = append(signer.verify, own)
+	for _, pub := range verifyOnly {
+		if jwk := newJWK(pub); jwk.Kid != own.Kid {
+			signer.verify = append(signer.verify, jwk)
+		}
+	}
+	return signer
+}
+
+// GenerateTokenSigner creates a signer with a fresh key. Tokens it signs
+// cannot be verified after a restart, so it suits development only.
+func GenerateTokenSigner() (*TokenSigner, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenSigner(key), nil
+}
+
+// LoadTokenSigner reads a PKCS#8 PEM Ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`, plus PEM public keys to publish
+// alongside it.
+func LoadTokenSigner(keyFile string, verifyFiles []string) (*TokenSigner, error) {
+	block, err := readPEM(keyFile, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyFile, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", keyFile)
+	}
+
+	var verifyOnly []ed25519.PublicKey
+	for _, file := range verifyFiles {
+		block, err := readPEM(file, "PUBLIC KEY")
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		pub, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an Ed25519 public key", file)
+		}
+		verifyOnly = append(verifyOnly, pub)
+	}
+	return NewTokenSigner(key, verifyOnly...), nil
+}
+
+func readPEM(file, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", file)
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("%s: expected a %q PEM block, found %q", file, blockType, block.Type)
+	}
+	return block, nil
+}
+
+// newJWK builds the key entry; kid is the RFC 7638 thumbprint.
+func newJWK(pub ed25519.PublicKey) JWK {
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   x,
+		Kid: base64.RawURLEncoding.EncodeToString(sum[:]),
+		Use: "sig",
+		Alg: "EdDSA",
+	}
+}
+
+// KeyID returns the kid placed in the header of signed tokens.
+func (s *TokenSigner) KeyID() string { return s.kid }
+
+// JWKS returns the public keys verifiers should accept.
+func (s *TokenSigner) JWKS() JWKSet {
+	return JWKSet{Keys: append([]JWK(nil), s.verify...)}
+}
+
+// tokenClaims is the JWT payload. Controller tokens carry the Persona user
+// and cohort; admin and spectator tokens carry their free-form claims.
+type tokenClaims struct {
+	Issuer    string            `json:"iss"`
+	Subject   string            `json:"sub"`
+	Audience  string            `json:"aud,omitempty"`
+	IssuedAt  int64             `json:"iat"`
+	ExpiresAt int64             `json:"exp"`
+	ID        string            `json:"jti"`
+	Scope     TokenScope        `json:"scope"`
+	UserID    string            `json:"userId,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Cohort    string            `json:"cohort,omitempty"`
+	Claims    map[string]string `json:"claims,omitempty"`
+}
+
+func (s *TokenSigner) sign(info issuedToken, issuedAt time.Time) (string, error) {
+	jti, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": s.kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(tokenClaims{
+		Issuer:    TokenIssuer,
+		Subject:   info.subject,
+		Audience:  info.audience,
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: info.expiresAt.Unix(),
+		ID:        jti,
+		Scope:     info.scope,
+		UserID:    info.user.ID,
+		Name:      info.user.Name,
+		Cohort:    info.cohort,
+		Claims:    info.claims,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(s.key, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWKS returns the key set to publish, or false when tokens are opaque.
+func (h *Hub) JWKS() (JWKSet, bool) {
+	if h.cfg.Signer == nil {
+		return JWKSet{}, false
+	}
+	return h.cfg.Signer.JWKS(), true
+}
+
+// mintToken returns the bearer value for info: a signed JWT when a signer is
+// configured, a random opaque string otherwise.
+func (h *Hub) mintToken(info issuedToken, now time.Time) (string, error) {
+	if h.cfg.Signer == nil {
+		return generateToken()
+	}
+	return h.cfg.Signer.sign(info, now)
+}
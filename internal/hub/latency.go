@@ -0,0 +1,123 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+const msgTypeLatency = "latency"
+
+// latencyEstimate smooths the ping round trips measured by runPinger like
+// TCP's SRTT and RTTVAR (RFC 6298). The zero value is ready.
+//
+// Latency hints let a game compensate input timing per player: half the
+// smoothed round trip is reported as the estimated one-way latency, in relay
+// envelopes as latencyMs and, when Config.LatencyHintInterval is set, in a
+// periodic frame to the game
+//
+//	{"type":"latency","slots":{"p1":{"oneWayMs":21,"rttMs":42,"jitterMs":5}},"timestamp":...}
+//
+// Slots without a measurement yet are left out.
+type latencyEstimate struct {
+	mu      sync.Mutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+func (l *latencyEstimate) observe(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.samples == 0 {
+		l.srtt, l.rttvar = rtt, rtt/2
+	} else {
+		diff := l.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		l.rttvar = (3*l.rttvar + diff) / 4
+		l.srtt = (7*l.srtt + rtt) / 8
+	}
+	l.samples++
+}
+
+// hint returns the estimate, or false before the first sample.
+func (l *latencyEstimate) hint() (latencyHint, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.samples == 0 {
+		return latencyHint{}, false
+	}
+	return latencyHint{
+		OneWayMs: (l.srtt / 2).Milliseconds(),
+		RTTMs:    l.srtt.Milliseconds(),
+		JitterMs: l.rttvar.Milliseconds(),
+	}, true
+}
+
+type latencyHint struct {
+	OneWayMs int64 `json:"oneWayMs"`
+	RTTMs    int64 `json:"rttMs"`
+	JitterMs int64 `json:"jitterMs"`
+}
+
+type latencyFrame struct {
+	Type      string                 `json:"type"`
+	Slots     map[string]latencyHint `json:"slots"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// RunLatencyHints sends the latency frame to the game listeners every
+// LatencyHintInterval until ctx is done. It is a no-op when the interval is
+// not set.
+func (h *Hub) RunLatencyHints(ctx context.Context) {
+	if h.cfg.LatencyHintInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.cfg.LatencyHintInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.sendLatencyHints(now)
+		}
+	}
+}
+
+func (h *Hub) sendLatencyHints(now time.Time) {
+	h.mu.Lock()
+	listeners := h.gameListenersLocked()
+	sessions := make([]*controllerSession, 0, len(h.controllers))
+	for _, session := range h.controllers {
+		sessions = append(sessions, session)
+	}
+	h.mu.Unlock()
+	if len(listeners) == 0 {
+		return
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	frame := latencyFrame{Type: msgTypeLatency, Slots: make(map[string]latencyHint, len(sessions)), Timestamp: now.UnixMilli()}
+	for _, session := range sessions {
+		if hint, ok := session.latency.hint(); ok {
+			frame.Slots[session.id] = hint
+		}
+	}
+	if len(frame.Slots) == 0 {
+		return
+	}
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		h.log.Error("latency_encode_failed", "err", err.Error())
+		return
+	}
+	for _, game := range listeners {
+		game.enqueue(payload, "server")
+	}
+}
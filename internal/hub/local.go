@@ -0,0 +1,92 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"nhooyr.io/websocket"
+)
+
+// localRemote stands in for the address of controllers played from inside
+// the hub process.
+const localRemote = "local"
+
+var errLocalClosed = errors.New("local controller closed")
+
+// localConn adapts a controller played from inside the hub process, such as
+// a keyboard attached to the hub machine, to controllerConn. Frames the hub
+// sends it are dropped; the device has no screen.
+type localConn struct {
+	frames <-chan []byte
+	done   chan struct{}
+
+	mu          sync.Mutex
+	closed      bool
+	closeCode   websocket.StatusCode
+	closeReason string
+}
+
+// Read implements controllerConn, returning the device's frames. Once they
+// end the session closes normally, like a phone that went away cleanly.
+func (l *localConn) Read(ctx context.Context) (websocket.MessageType, []byte, error) {
+	select {
+	case data, ok := <-l.frames:
+		if !ok {
+			return 0, nil, websocket.CloseError{Code: websocket.StatusNormalClosure, Reason: "local input closed"}
+		}
+		return websocket.MessageText, data, nil
+	case <-l.done:
+		l.mu.Lock()
+		code, reason := l.closeCode, l.closeReason
+		l.mu.Unlock()
+		return 0, nil, websocket.CloseError{Code: code, Reason: reason}
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// Write implements controllerConn by dropping data.
+func (l *localConn) Write(context.Context, websocket.MessageType, []byte) error {
+	select {
+	case <-l.done:
+		return errLocalClosed
+	default:
+		return nil
+	}
+}
+
+// Close implements controllerConn. Closing twice keeps the first code.
+func (l *localConn) Close(code websocket.StatusCode, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	l.closeCode, l.closeReason = code, reason
+	close(l.done)
+	return nil
+}
+
+// Ping implements controllerConn; there is no connection to probe.
+func (l *localConn) Ping(context.Context) error {
+	return errPingUnsupported
+}
+
+// RunLocal plays slotID as a controller fed from inside the hub process,
+// such as a keyboard attached to the hub machine. frames are the messages
+// the controller sends. The session registers, relays and is listed like a
+// phone's, and replaces a phone holding the slot. It runs until frames is
+// closed, ctx is done or the hub closes it, e.g. when a phone takes the slot
+// back, and returns the close status.
+func (h *Hub) RunLocal(ctx context.Context, slotID string, frames <-chan []byte) (websocket.StatusCode, string) {
+	conn := &localConn{frames: frames, done: make(chan struct{})}
+	reg := registerPayload{Role: roleController, ID: slotID, ProtocolVersion: ProtocolVersion}
+	status, reason := h.handleController(ctx, conn, localRemote, reg)
+	if reason == "" {
+		reason = statusText(status)
+	}
+	_ = conn.Close(status, reason)
+	return status, reason
+}
@@ -0,0 +1,473 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+// Long-poll fallback for controllers on networks that break WebSockets,
+// such as some captive portals. A controller opens a session with
+// POST /ws/poll carrying the usual register message, then sends frames with
+// POST /ws/poll?sid= and collects the hub's frames with GET /ws/poll?sid=.
+// The session goes through handleController like a WebSocket, so relaying,
+// kicks, idle timeouts and the close codes behave the same.
+const (
+	// pollWait is how long GET /ws/poll holds the request open without
+	// frames to deliver.
+	pollWait = 25 * time.Second
+	// pollIdleTimeout drops a session whose client stopped polling, the
+	// long-poll counterpart of a broken connection.
+	pollIdleTimeout = pollWait + 15*time.Second
+	// pollLinger keeps a closed session around for the client to collect
+	// the close code.
+	pollLinger = pollWait
+	// pollInboundQueue bounds frames posted but not yet read by the session.
+	pollInboundQueue = 64
+	// pollOutboxLimit bounds frames waiting for the client to poll; beyond
+	// it writes fail like on a stalled WebSocket.
+	pollOutboxLimit = 256
+	// pollBatchLimit bounds the frames in one POST.
+	pollBatchLimit = 32
+)
+
+var (
+	errPingUnsupported = errors.New("transport has no ping")
+	errPollLost        = errors.New("long-poll client stopped polling")
+	errPollBacklog     = errors.New("long-poll client is not collecting frames")
+	errPollClosed      = errors.New("long-poll session closed")
+)
+
+// pollConn adapts a long-poll session to controllerConn.
+type pollConn struct {
+	sid     string
+	token   string
+	inbound chan []byte
+	opened  chan struct{}
+	done    chan struct{}
+	// notify wakes a waiting poll when frames or the close arrive.
+	notify chan struct{}
+	// collected is closed once a poll delivered the close.
+	collected chan struct{}
+
+	lastActive atomic.Int64 // unix nanoseconds
+	polling    atomic.Int32
+
+	mu          sync.Mutex
+	id          string
+	outbox      []json.RawMessage
+	closed      bool
+	closeCode   websocket.StatusCode
+	closeReason string
+}
+
+func newPollConn(sid, token string) *pollConn {
+	p := &pollConn{
+		sid:       sid,
+		token:     token,
+		inbound:   make(chan []byte, pollInboundQueue),
+		opened:    make(chan struct{}),
+		done:      make(chan struct{}),
+		notify:    make(chan struct{}, 1),
+		collected: make(chan struct{}),
+	}
+	p.touch()
+	return p
+}
+
+func (p *pollConn) touch() {
+	p.lastActive.Store(time.Now().UnixNano())
+}
+
+func (p *pollConn) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// markOpen is called by handleController once the session is registered.
+func (p *pollConn) markOpen(id string) {
+	p.mu.Lock()
+	p.id = id
+	p.mu.Unlock()
+	close(p.opened)
+}
+
+// Read implements controllerConn, returning posted frames. A client that
+// stops polling reads as a lost connection.
+func (p *pollConn) Read(ctx context.Context) (websocket.MessageType, []byte, error) {
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+	for {
+		select {
+		case data := <-p.inbound:
+			return websocket.MessageText, data, nil
+		case <-p.done:
+			p.mu.Lock()
+			code, reason := p.closeCode, p.closeReason
+			p.mu.Unlock()
+			return 0, nil, websocket.CloseError{Code: code, Reason: reason}
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case <-check.C:
+			idle := time.Since(time.Unix(0, p.lastActive.Load()))
+			if p.polling.Load() == 0 && idle > pollIdleTimeout {
+				return 0, nil, errPollLost
+			}
+		}
+	}
+}
+
+// Write implements controllerConn by queueing data for the next poll.
+func (p *pollConn) Write(_ context.Context, typ websocket.MessageType, data []byte) error {
+	if typ != websocket.MessageText || !json.Valid(data) {
+		return errors.New("long-poll carries JSON text frames only")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errPollClosed
+	}
+	if len(p.outbox) >= pollOutboxLimit {
+		return errPollBacklog
+	}
+	p.outbox = append(p.outbox, json.RawMessage(append([]byte(nil), data...)))
+	p.wake()
+	return nil
+}
+
+// Close implements controllerConn; the next poll reports code and reason.
+// Closing twice keeps the first code.
+func (p *pollConn) Close(code websocket.StatusCode, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	p.closeCode, p.closeReason = code, reason
+	close(p.done)
+	p.wake()
+	return nil
+}
+
+// Ping implements controllerConn; long-poll has no ping.
+func (p *pollConn) Ping(context.Context) error {
+	return errPingUnsupported
+}
+
+// pollClose is the close notice of a long-poll response.
+type pollClose struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+type pollResponse struct {
+	Messages []json.RawMessage `json:"messages"`
+	Closed   *pollClose        `json:"closed,omitempty"`
+}
+
+// take removes the queued frames, and reports the close once they are all
+// delivered.
+func (p *pollConn) take() pollResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	resp := pollResponse{Messages: p.outbox}
+	if resp.Messages == nil {
+		resp.Messages = []json.RawMessage{}
+	}
+	p.outbox = nil
+	if p.closed {
+		resp.Closed = &pollClose{Code: int(p.closeCode), Reason: p.closeReason}
+		select {
+		case <-p.collected:
+		default:
+			close(p.collected)
+		}
+	}
+	return resp
+}
+
+// pollRegistry maps session ids to live long-poll sessions.
+type pollRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*pollConn
+}
+
+func (r *pollRegistry) add(p *pollConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions == nil {
+		r.sessions = make(map[string]*pollConn)
+	}
+	r.sessions[p.sid] = p
+}
+
+func (r *pollRegistry) get(sid string) *pollConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[sid]
+}
+
+func (r *pollRegistry) remove(sid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sid)
+}
+
+func writePollJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// HandlePoll serves the long-poll controller transport on /ws/poll.
+func (h *Hub) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	sid := strings.TrimSpace(r.URL.Query().Get("sid"))
+	if sid == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.openPoll(w, r)
+		return
+	}
+
+	p := h.polls.get(sid)
+	if p == nil || !p.authorized(r) {
+		// Unknown and expired sessions look alike so ids cannot be probed;
+		// the client registers again.
+		writePollJSON(w, http.StatusNotFound, pollResponse{
+			Messages: []json.RawMessage{},
+			Closed:   &pollClose{Code: int(websocket.StatusGoingAway), Reason: "poll session not found"},
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.servePoll(w, r, p)
+	case http.MethodPost:
+		h.receivePoll(w, r, p)
+	case http.MethodDelete:
+		p.touch()
+		_ = p.Close(websocket.StatusNormalClosure, statusText(websocket.StatusNormalClosure))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks that a request for a session registered with a
+// controller token carries the same token as a bearer credential.
+func (p *pollConn) authorized(r *http.Request) bool {
+	if p.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && strings.TrimSpace(token) == p.token
+}
+
+// openPoll registers a long-poll session and answers once the hub accepted
+// or refused it.
+func (h *Hub) openPoll(w http.ResponseWriter, r *http.Request) {
+	remote := h.remoteAddr(r)
+	if retryAfter, ok := h.limiter.acquire(remote, time.Now()); !ok {
+		if retryAfter > 0 {
+			h.upgrades.fail(UpgradeFailLockout)
+			http.Error(w, "too many failed register attempts", http.StatusTooManyRequests)
+			return
+		}
+		h.upgrades.fail(UpgradeFailIPLimit)
+		h.log.Warn("poll_open_ip_limit", "remote_ip", remote, "limit", h.cfg.MaxConnsPerIP)
+		http.Error(w, "too many connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	released := sync.OnceFunc(func() { h.limiter.release(remote, time.Now()) })
+
+	if !h.originAllowed(r) {
+		released()
+		h.upgrades.fail(UpgradeFailOrigin)
+		h.log.Warn("poll_open_origin_refused", "remote_ip", remote, "origin", r.Header.Get("Origin"), "host", r.Host, "hint", "add the origin host to ORIGINS")
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !h.limiter.beginRegister(remote) {
+		released()
+		h.upgrades.fail(UpgradeFailPending)
+		h.log.Warn("poll_open_pending_limit", "remote_ip", remote, "limit", h.cfg.MaxPendingPerIP)
+		http.Error(w, "too many unregistered connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	registering := sync.OnceFunc(func() { h.limiter.endRegister(remote) })
+	defer registering()
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, wsMessageLimit))
+	if err != nil {
+		released()
+		http.Error(w, "register message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	reg, status, reason := h.parseRegister(data, "", remote)
+	registering()
+	if status == 0 && reg.Role != roleController {
+		h.log.Warn("poll_register_invalid_role", "role", reg.Role, "remote_ip", remote)
+		status, reason = closeWith(ReasonRegisterInvalid)
+	}
+	if status != 0 {
+		released()
+		h.registerFailed(remote)
+		writePollJSON(w, http.StatusForbidden, pollResponse{Messages: []json.RawMessage{}, Closed: &pollClose{Code: int(status), Reason: reason}})
+		return
+	}
+	reg.cohortHint = r.URL.Query().Get("cohort")
+
+	sid, err := generateToken()
+	if err != nil {
+		released()
+		h.log.Error("poll_session_id_failed", "err", err.Error())
+		http.Error(w, "failed to open session", http.StatusInternalServerError)
+		return
+	}
+	p := newPollConn(sid, reg.Token)
+	h.polls.add(p)
+
+	finished := make(chan struct{})
+	crash.Go(func() {
+		defer released()
+		status, reason := h.handleController(context.Background(), p, remote, reg)
+		if reason == "" {
+			reason = statusText(status)
+		}
+		_ = p.Close(status, reason)
+		close(finished)
+		select {
+		case <-p.collected:
+		case <-time.After(pollLinger):
+		}
+		h.polls.remove(sid)
+	})
+
+	select {
+	case <-p.opened:
+		h.upgrades.accepted.Add(1)
+		p.mu.Lock()
+		id := p.id
+		p.mu.Unlock()
+		writePollJSON(w, http.StatusOK, map[string]any{
+			"sid":        sid,
+			"id":         id,
+			"pollWaitMs": pollWait.Milliseconds(),
+		})
+	case <-finished:
+		writePollJSON(w, http.StatusForbidden, p.take())
+	}
+}
+
+// originAllowed applies ORIGINS to a request as /ws does through the
+// WebSocket handshake: no Origin header, the hub's own host or a host
+// matching one of the patterns.
+func (h *Hub) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(r.Host, u.Host) {
+		return true
+	}
+	for _, pattern := range h.cfg.AllowedOrigins {
+		if matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(u.Host)); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// servePoll waits up to pollWait for frames and returns them, with the
+// close code once the session has ended.
+func (h *Hub) servePoll(w http.ResponseWriter, r *http.Request, p *pollConn) {
+	p.polling.Add(1)
+	defer func() {
+		p.touch()
+		p.polling.Add(-1)
+	}()
+	p.touch()
+
+	timer := time.NewTimer(pollWait)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		ready := len(p.outbox) > 0 || p.closed
+		p.mu.Unlock()
+		if ready {
+			break
+		}
+		select {
+		case <-p.notify:
+			continue
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+		break
+	}
+	writePollJSON(w, http.StatusOK, p.take())
+}
+
+// receivePoll feeds posted frames, one JSON message or an array of them, to
+// the session's read loop.
+func (h *Hub) receivePoll(w http.ResponseWriter, r *http.Request, p *pollConn) {
+	p.touch()
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, pollBatchLimit*wsMessageLimit))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var frames []json.RawMessage
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &frames); err != nil {
+			http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+	} else {
+		frames = []json.RawMessage{data}
+	}
+	if len(frames) > pollBatchLimit {
+		http.Error(w, "too many frames in one request", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	accepted := 0
+	for _, frame := range frames {
+		select {
+		case p.inbound <- frame:
+			accepted++
+		case <-p.done:
+			writePollJSON(w, http.StatusGone, p.take())
+			return
+		default:
+			// The session is not keeping up; later frames supersede these.
+		}
+	}
+	writePollJSON(w, http.StatusOK, map[string]any{"accepted": accepted, "dropped": len(frames) - accepted})
+}
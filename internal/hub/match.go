@@ -0,0 +1,161 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// msgTypeMatchStart is sent by the game when play actually begins, e.g. at
+// the end of its countdown. It is still broadcast to the controllers.
+const msgTypeMatchStart = "match_start"
+
+// MarkMatchStart records t as the start of the current match. A later call,
+// such as the game's match_start after /api/game/start, wins.
+func (h *Hub) MarkMatchStart(t time.Time) {
+	h.matchStart.Store(t.UnixNano())
+}
+
+// MatchStartedAt returns the recorded start of the current match, or the
+// zero time when none is known.
+func (h *Hub) MatchStartedAt() time.Time {
+	ns := h.matchStart.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+// ClearMatchStart forgets the recorded start once the match result is in.
+func (h *Hub) ClearMatchStart() {
+	h.matchStart.Store(0)
+}
+
+// gameMatchStarted handles the game's match_start. It moves an idle or
+// locked match to running; when /api/game/start already did, it only moves
+// the recorded start to now.
+func (h *Hub) gameMatchStarted(game *gameSession) {
+	if h.Match().State != MatchRunning {
+		if err := h.AdvanceMatch(MatchRunning, "game"); err != nil {
+			game.logger.Warn("match_signal_rejected", "type", msgTypeMatchStart, "state", string(h.Match().State))
+			return
+		}
+	}
+	now := time.Now()
+	h.MarkMatchStart(now)
+	game.logger.Info("match_started", "start_time", now.UTC().Format(time.RFC3339))
+	h.emit("match_started", roleGame, "", game.remoteIP)
+}
+
+// MatchTimer reports the start and elapsed time of the current match.
+// running is false when no start has been recorded.
+func (h *Hub) MatchTimer() (start time.Time, elapsed time.Duration, running bool) {
+	start = h.MatchStartedAt()
+	if start.IsZero() {
+		return start, 0, false
+	}
+	return start, time.Since(start), true
+}
+
+// matchStartEvent is sent to the game and every controller when a match is
+// started through the API, so screens and phones count down together.
+type matchStartEvent struct {
+	Type      string   `json:"type"`
+	Countdown int      `json:"countdown"`
+	StartAt   int64    `json:"startAt"`
+	Slots     []string `json:"slots"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// BroadcastMatchStart sends {"type":"match_start","countdown":N} to the game
+// and the controllers; play begins countdown after now, at startAt (unix
+// milliseconds).
+func (h *Hub) BroadcastMatchStart(slots []string, countdown time.Duration) {
+	now := time.Now()
+	payload, err := json.Marshal(matchStartEvent{
+		Type:      msgTypeMatchStart,
+		Countdown: int(countdown / time.Second),
+		StartAt:   now.Add(countdown).UnixMilli(),
+		Slots:     append([]string{}, slots...),
+		Timestamp: now.UnixMilli(),
+	})
+	if err != nil {
+		h.log.Error("match_start_encode_failed", "err", err.Error())
+		return
+	}
+	h.broadcastHubEvent(payload)
+	h.log.Info("match_start_broadcast", "countdown", countdown.String(), "slots", slots)
+}
+
+// timerEvent carries the match clock to controllers and game listeners so
+// every screen shows the same elapsed time.
+type timerEvent struct {
+	Type      string `json:"type"`
+	Running   bool   `json:"running"`
+	StartTime string `json:"startTime,omitempty"`
+	ElapsedMs int64  `json:"elapsedMs"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// RunMatchTimer broadcasts a timer frame every TimerInterval while a match
+// is running, plus a single stopped frame when it ends, until ctx is done.
+func (h *Hub) RunMatchTimer(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.TimerInterval)
+	defer ticker.Stop()
+
+	wasRunning := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start, elapsed, running := h.MatchTimer()
+		if !running && !wasRunning {
+			continue
+		}
+		wasRunning = running
+
+		event := timerEvent{
+			Type:      "timer",
+			Running:   running,
+			ElapsedMs: elapsed.Milliseconds(),
+			Timestamp: time.Now().UnixMilli(),
+		}
+		if running {
+			event.StartTime = start.Format(time.RFC3339Nano)
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			h.log.Error("timer_encode_failed", "err", err.Error())
+			continue
+		}
+		h.broadcastHubEvent(payload)
+	}
+}
+
+// broadcastHubEvent queues a hub originated frame to every controller and
+// game listener.
+func (h *Hub) broadcastHubEvent(payload []byte) {
+	h.mu.Lock()
+	var sessions []*controllerSession
+	// Controllers in passthrough mode only ever receive game frames.
+	if !h.cfg.Passthrough {
+		sessions = make([]*controllerSession, 0, len(h.controllers))
+		for _, session := range h.controllers {
+			sessions = append(sessions, session)
+		}
+	}
+	listeners := h.gameListenersLocked()
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		if session.outbox.offer(payload, false, "") {
+			h.broadcast.dropped.Add(1)
+		}
+	}
+	for _, game := range listeners {
+		game.enqueue(payload, "server")
+	}
+}
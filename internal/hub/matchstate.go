@@ -0,0 +1,162 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MatchState is the phase of the current match. A match moves
+// idle → lobby_locked → running → finished and back to idle once its result
+// is in; lobby_locked is optional for games that start straight away.
+type MatchState string
+
+const (
+	MatchIdle        MatchState = "idle"
+	MatchLobbyLocked MatchState = "lobby_locked"
+	MatchRunning     MatchState = "running"
+	MatchFinished    MatchState = "finished"
+)
+
+// ErrMatchState is returned when an operation does not fit the current
+// match state, such as finishing a match that never started.
+var ErrMatchState = errors.New("operation not allowed in current match state")
+
+// Game messages that move the match along. Like match_start they are still
+// broadcast to the controllers.
+const (
+	msgTypeLobbyLock   = "lobby_lock"
+	msgTypeLobbyUnlock = "lobby_unlock"
+	msgTypeMatchFinish = "match_finish"
+)
+
+var matchTransitions = map[MatchState][]MatchState{
+	MatchIdle:        {MatchLobbyLocked, MatchRunning},
+	MatchLobbyLocked: {MatchIdle, MatchRunning},
+	MatchRunning:     {MatchFinished},
+	MatchFinished:    {MatchIdle},
+}
+
+// MatchTransitions returns the states the match may move to from state.
+func MatchTransitions(state MatchState) []MatchState {
+	return slices.Clone(matchTransitions[state])
+}
+
+// CanAdvanceMatch reports whether the match may move from one state to the
+// other.
+func CanAdvanceMatch(from, to MatchState) bool {
+	return slices.Contains(matchTransitions[from], to)
+}
+
+// MatchStatus is the current match state and when it was entered. Since is
+// zero while the match has never left idle.
+type MatchStatus struct {
+	State MatchState
+	Since time.Time
+}
+
+// matchMachine holds the match state; the zero value is idle.
+type matchMachine struct {
+	mu     sync.Mutex
+	status MatchStatus
+}
+
+func (m *matchMachine) current() MatchStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.status
+	if status.State == "" {
+		status.State = MatchIdle
+	}
+	return status
+}
+
+// advance moves to state and returns the state it left. Unless force is
+// set, a move the transition table does not allow fails with ErrMatchState.
+func (m *matchMachine) advance(to MatchState, force bool) (MatchState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := m.status.State
+	if from == "" {
+		from = MatchIdle
+	}
+	if !force && !CanAdvanceMatch(from, to) {
+		return from, fmt.Errorf("%w: %s to %s", ErrMatchState, from, to)
+	}
+	m.status = MatchStatus{State: to, Since: time.Now()}
+	return from, nil
+}
+
+// matchStateEvent tells controllers and game listeners the match moved on.
+type matchStateEvent struct {
+	Type      string     `json:"type"`
+	State     MatchState `json:"state"`
+	Previous  MatchState `json:"previous"`
+	Timestamp int64      `json:"timestamp"`
+}
+
+// Match returns the current match state.
+func (h *Hub) Match() MatchStatus {
+	return h.match.current()
+}
+
+// AdvanceMatch moves the match to state, or returns ErrMatchState when the
+// current state does not lead there. source says what asked, e.g. "api" or
+// "game", for the log and the match_state event.
+func (h *Hub) AdvanceMatch(state MatchState, source string) error {
+	from, err := h.match.advance(state, false)
+	if err != nil {
+		return err
+	}
+	h.matchStateChanged(from, state, source)
+	return nil
+}
+
+// ResetMatch returns the match to idle from any state, for an operator
+// abandoning a match that will never report a result.
+func (h *Hub) ResetMatch(source string) {
+	from, _ := h.match.advance(MatchIdle, true)
+	if from != MatchIdle {
+		h.matchStateChanged(from, MatchIdle, source)
+	}
+}
+
+func (h *Hub) matchStateChanged(from, to MatchState, source string) {
+	h.log.Info("match_state_changed", "from", string(from), "to", string(to), "source", source)
+	h.emit("match_state", "", "", "", "state", string(to), "previous", string(from), "source", source)
+
+	payload, err := json.Marshal(matchStateEvent{
+		Type:      "match_state",
+		State:     to,
+		Previous:  from,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		h.log.Error("match_state_encode_failed", "err", err.Error())
+		return
+	}
+	h.broadcastHubEvent(payload)
+}
+
+// gameMatchSignal applies a lobby_lock, lobby_unlock or match_finish sent by
+// the game. A signal that does not fit the current state is logged and
+// otherwise ignored.
+func (h *Hub) gameMatchSignal(game *gameSession, msgType string) {
+	var to MatchState
+	switch msgType {
+	case msgTypeLobbyLock:
+		to = MatchLobbyLocked
+	case msgTypeLobbyUnlock:
+		to = MatchIdle
+	case msgTypeMatchFinish:
+		to = MatchFinished
+	default:
+		return
+	}
+	if err := h.AdvanceMatch(to, "game"); err != nil {
+		game.logger.Warn("match_signal_rejected", "type", msgType, "state", string(h.Match().State))
+	}
+}
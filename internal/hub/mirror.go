@@ -0,0 +1,94 @@
+package hub
+
+import (
+	"context"
+	"errors"
+
+	"nhooyr.io/websocket"
+)
+
+// gameListenersLocked returns the primary game, if any, followed by the
+// mirrors. The caller must hold h.mu.
+func (h *Hub) gameListenersLocked() []*gameSession {
+	listeners := make([]*gameSession, 0, len(h.mirrors)+1)
+	if h.game != nil {
+		listeners = append(listeners, h.game)
+	}
+	for mirror := range h.mirrors {
+		listeners = append(listeners, mirror)
+	}
+	return listeners
+}
+
+func (h *Hub) gameListeners() []*gameSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.gameListenersLocked()
+}
+
+// enqueueToListeners sends a hub originated frame to every game listener.
+func (h *Hub) enqueueToListeners(payload []byte) int {
+	listeners := h.gameListeners()
+	for _, game := range listeners {
+		game.enqueue(payload, "server")
+	}
+	return len(listeners)
+}
+
+// handleMirror registers a mirror game listener.
+//
+// Mirror game listeners receive the same controller stream as the primary
+// game, e.g. for a spectator display, but are never authoritative: their
+// frames are not routed to controllers and they cannot replace the primary.
+// A game registers as a mirror with {"role":"game","mirror":true}; at most
+// MaxGames-1 mirrors are accepted alongside the primary.
+func (h *Hub) handleMirror(ctx context.Context, conn gameConn, remote string, reg registerPayload) (websocket.StatusCode, string) {
+	session := newGameSession(ctx, conn, remote, h.cfg, &h.drops, h.log.With("protocol", reg.ProtocolVersion, "mirror", true))
+
+	if h.shed.active() {
+		h.shed.reject()
+		session.logger.Warn("register_shed", "role", roleGame)
+		session.cancel()
+		return closeWith(ReasonOverloaded)
+	}
+
+	h.mu.Lock()
+	if len(h.mirrors)+1 >= h.cfg.MaxGames {
+		h.mu.Unlock()
+		session.logger.Warn("rejected", "reason", "game listener limit reached", "max_games", h.cfg.MaxGames)
+		session.cancel()
+		return closeWith(ReasonSlotTaken)
+	}
+	h.mirrors[session] = struct{}{}
+	h.sendRosterLocked(session)
+	h.replayCalibrationsLocked(session)
+	h.mu.Unlock()
+
+	session.logger.Info("connected")
+	h.emit("connected", roleGame, "mirror", remote)
+	session.startWriter()
+
+	status := websocket.StatusNormalClosure
+	reason := statusText(status)
+	for {
+		_, _, err := conn.Read(ctx)
+		if err != nil {
+			status, reason = closeStatusFromError(err, websocket.StatusNormalClosure)
+			if !errors.Is(err, context.Canceled) {
+				session.logger.Info("disconnected", "status", status, "reason", reason, "err", err.Error())
+			} else {
+				session.logger.Info("disconnected", "status", status, "reason", reason)
+			}
+			break
+		}
+		session.logger.Debug("mirror_payload_ignored")
+	}
+
+	h.mu.Lock()
+	delete(h.mirrors, session)
+	h.mu.Unlock()
+	h.emit("disconnected", roleGame, "mirror", remote, "status", int(status), "reason", reason)
+
+	session.close(status, reason)
+	return status, reason
+}
@@ -0,0 +1,163 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kickCooldown is how long a kicked slot is refused re-registration.
+const kickCooldown = 30 * time.Second
+
+// ErrBanned indicates that the remote address or user is currently banned.
+var ErrBanned = errors.New("banned")
+
+// Ban describes an active ban on a remote IP or Persona user ID.
+type Ban struct {
+	Subject string
+	Until   time.Time
+}
+
+// Kick disconnects the controller on slotID with a policy-violation status
+// and refuses the slot for a short cooldown so the client cannot bounce
+// straight back in.
+func (h *Hub) Kick(ctx context.Context, slotID, reason string) error {
+	slotID = strings.ToLower(strings.TrimSpace(slotID))
+	if reason == "" {
+		reason = "kicked by operator"
+	}
+
+	h.mu.Lock()
+	session := h.controllers[slotID]
+	if session == nil {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrSlotNotConnected, slotID)
+	}
+	h.kicked[slotID] = time.Now().Add(kickCooldown)
+	h.mu.Unlock()
+
+	h.evict(ctx, session, "kicked", reason, ReasonKicked)
+	return nil
+}
+
+// Ban refuses registrations from subject, matched against the controller's
+// remote IP and its Persona user ID, for the given duration. Connected
+// controllers matching subject are disconnected. It returns the number of
+// controllers removed.
+func (h *Hub) Ban(ctx context.Context, subject string, duration time.Duration, reason string) (int, error) {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return 0, errors.New("ban subject required")
+	}
+	if duration <= 0 {
+		return 0, errors.New("ban duration must be positive")
+	}
+	if reason == "" {
+		reason = "banned by operator"
+	}
+
+	h.mu.Lock()
+	h.bans[subject] = time.Now().Add(duration)
+	var targets []*controllerSession
+	for _, session := range h.controllers {
+		if session.remoteIP == subject || session.user.ID == subject {
+			targets = append(targets, session)
+		}
+	}
+	h.mu.Unlock()
+
+	h.log.Info("ban_added", "subject", subject, "duration", duration.String(), "reason", reason)
+	h.emit("ban_added", "", "", "", "subject", subject, "duration", duration.String(), "reason", reason)
+	for _, session := range targets {
+		h.evict(ctx, session, "banned", reason, ReasonBanned)
+	}
+	return len(targets), nil
+}
+
+// Unban lifts a ban. It reports whether a ban was active.
+func (h *Hub) Unban(subject string) bool {
+	subject = strings.TrimSpace(subject)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pruneBansLocked(time.Now())
+	if _, ok := h.bans[subject]; !ok {
+		return false
+	}
+	delete(h.bans, subject)
+	h.log.Info("ban_removed", "subject", subject)
+	h.emit("ban_removed", "", "", "", "subject", subject)
+	return true
+}
+
+// Bans lists the active bans ordered by subject.
+func (h *Hub) Bans() []Ban {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pruneBansLocked(time.Now())
+	bans := make([]Ban, 0, len(h.bans))
+	for subject, until := range h.bans {
+		bans = append(bans, Ban{Subject: subject, Until: until})
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Subject < bans[j].Subject })
+	return bans
+}
+
+// checkAdmission reports why a controller may not register right now.
+func (h *Hub) checkAdmission(slotID, remoteIP, userID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.pruneBansLocked(now)
+	if _, ok := h.bans[remoteIP]; ok && remoteIP != "" {
+		return ErrBanned
+	}
+	if _, ok := h.bans[userID]; ok && userID != "" {
+		return ErrBanned
+	}
+	if until, ok := h.kicked[slotID]; ok {
+		if now.Before(until) {
+			return errors.New("slot recently kicked")
+		}
+		delete(h.kicked, slotID)
+	}
+	return nil
+}
+
+func (h *Hub) isUserBanned(userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pruneBansLocked(time.Now())
+	_, ok := h.bans[userID]
+	return ok
+}
+
+func (h *Hub) pruneBansLocked(now time.Time) {
+	for subject, until := range h.bans {
+		if !now.Before(until) {
+			delete(h.bans, subject)
+		}
+	}
+}
+
+// evict tells session why it is being removed, then closes it with the
+// application close code for closeReason.
+func (h *Hub) evict(ctx context.Context, session *controllerSession, kind, reason, closeReason string) {
+	notice := struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}{Type: kind, Reason: reason}
+	if err := h.writeController(ctx, session, notice); err != nil {
+		session.logger.Debug("evict_notice_failed", "err", err.Error())
+	}
+	session.logger.Warn(kind, "reason", reason)
+	h.emit(kind, roleController, session.id, session.remoteIP, "reason", reason)
+	_ = session.conn.Close(closeWith(closeReason))
+}
@@ -0,0 +1,78 @@
+package hub
+
+import (
+	"bytes"
+
+	"nhooyr.io/websocket"
+)
+
+// rawSlotAll addresses every connected controller from the game.
+const rawSlotAll = "*"
+
+// relayRaw forwards a controller frame to the game listeners unparsed,
+// prefixed with the sender's slot id.
+//
+// Passthrough mode relays frames verbatim for games that bring their own
+// protocol. The hub still handles registration, slots and close codes but
+// never parses a frame after register; text and binary frames keep their
+// type. Each frame crossing the hub carries a routing header of the slot id
+// and a newline:
+//
+//	controller p1 sends   <payload>
+//	game receives         p1\n<payload>
+//	game sends            p1\n<payload>   (or *\n<payload> for every slot)
+//	controller p1 gets    <payload>
+//
+// Hub-originated frames such as roster updates still reach the game as JSON
+// text without a header. Controllers receive only game frames, plus the
+// "registered" ack when their id was assigned by the hub.
+func (h *Hub) relayRaw(session *controllerSession, msgType websocket.MessageType, data []byte) {
+	session.touch()
+	games := h.gameListeners()
+	if len(games) == 0 {
+		return
+	}
+
+	frame := make([]byte, 0, len(session.id)+1+len(data))
+	frame = append(frame, session.id...)
+	frame = append(frame, '\n')
+	frame = append(frame, data...)
+
+	session.stats.frames.Add(1)
+	session.stats.bytes.Add(uint64(len(data)))
+	for _, game := range games {
+		game.enqueueFrame(queuedFrame{
+			data:         frame,
+			controllerID: session.id,
+			binary:       msgType == websocket.MessageBinary,
+		})
+	}
+}
+
+// routeRawGameMessage delivers a game frame to the controller named by its
+// routing header, or to every controller for "*", with the header removed.
+func (h *Hub) routeRawGameMessage(game *gameSession, msgType websocket.MessageType, data []byte) {
+	target, payload, ok := bytes.Cut(data, []byte{'\n'})
+	if !ok || len(target) == 0 {
+		game.logger.Warn("game_payload_invalid", "err", "slot header required")
+		return
+	}
+	slot := string(target)
+
+	h.mu.Lock()
+	sessions := make([]*controllerSession, 0, len(h.controllers))
+	for id, session := range h.controllers {
+		if slot == rawSlotAll || slot == id {
+			sessions = append(sessions, session)
+		}
+	}
+	h.mu.Unlock()
+
+	event := outboxEvent{data: cloneBytes(payload), binary: msgType == websocket.MessageBinary}
+	for _, session := range sessions {
+		if session.outbox.offerEvent(event) {
+			h.broadcast.dropped.Add(1)
+			session.logger.Warn("outbox_drop_oldest")
+		}
+	}
+}
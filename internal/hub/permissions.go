@@ -0,0 +1,102 @@
+package hub
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ParseInputProfiles reads profiles of the form "p1:steer,boost;p4:emote".
+func ParseInputProfiles(raw string) (map[string][]string, error) {
+	profiles := make(map[string][]string)
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		slot, types, ok := strings.Cut(entry, ":")
+		slot = strings.ToLower(strings.TrimSpace(slot))
+		if !ok || slot == "" {
+			return nil, fmt.Errorf("invalid input profile %q", entry)
+		}
+		if _, dup := profiles[slot]; dup {
+			return nil, fmt.Errorf("duplicate input profile for %q", slot)
+		}
+		profiles[slot] = normalizeTypes(strings.Split(types, ","))
+	}
+	return profiles, nil
+}
+
+func normalizeTypes(types []string) []string {
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// InputProfiles returns the configured profiles keyed by slot.
+func (h *Hub) InputProfiles() map[string][]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string][]string, len(h.profiles))
+	for slot, types := range h.profiles {
+		out[slot] = slices.Clone(types)
+	}
+	return out
+}
+
+// SetInputProfile limits slot to the given message types, replacing any
+// existing profile. An empty list blocks every input frame from the slot.
+func (h *Hub) SetInputProfile(slot string, types []string) []string {
+	slot = strings.ToLower(strings.TrimSpace(slot))
+	allowed := normalizeTypes(types)
+	h.mu.Lock()
+	h.profiles[slot] = allowed
+	h.mu.Unlock()
+	h.log.Info("input_profile_set", "slot", slot, "types", allowed)
+	return slices.Clone(allowed)
+}
+
+// ClearInputProfile lifts the restriction on slot. It reports whether a
+// profile was set.
+func (h *Hub) ClearInputProfile(slot string) bool {
+	slot = strings.ToLower(strings.TrimSpace(slot))
+	h.mu.Lock()
+	_, ok := h.profiles[slot]
+	delete(h.profiles, slot)
+	h.mu.Unlock()
+	if ok {
+		h.log.Info("input_profile_cleared", "slot", slot)
+	}
+	return ok
+}
+
+// inputAllowed reports whether session may relay frames of msgType, warning
+// once per session and type when it may not.
+//
+// Input profiles restrict which message types a slot may send, e.g. letting
+// a spectating slot emote but not steer. Slots without a profile may send
+// anything. Frames of other types are dropped with a warning; hub control
+// messages such as telemetry or unregister are always accepted.
+func (h *Hub) inputAllowed(session *controllerSession, msgType string) bool {
+	h.mu.Lock()
+	types, restricted := h.profiles[session.id]
+	allowed := !restricted || slices.Contains(types, msgType)
+	h.mu.Unlock()
+	if allowed {
+		return true
+	}
+
+	if _, warned := session.forbidden[msgType]; !warned {
+		if session.forbidden == nil {
+			session.forbidden = make(map[string]struct{})
+		}
+		session.forbidden[msgType] = struct{}{}
+		session.logger.Warn("input_type_forbidden", "type", msgType, "allowed", types)
+	}
+	return false
+}
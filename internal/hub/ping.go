@@ -0,0 +1,45 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// pingInterval paces the WebSocket pings used to measure controller round
+// trip time.
+const pingInterval = 5 * time.Second
+
+// runPinger pings session every pingInterval until ctx is done and records
+// the round trip time. A failed ping is left to the read loop, which sees
+// the broken connection as well. Transports without pings, the long-poll
+// fallback, are not measured.
+func (h *Hub) runPinger(ctx context.Context, session *controllerSession) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, h.cfg.WriteTimeout)
+		start := time.Now()
+		err := session.conn.Ping(pingCtx)
+		cancel()
+		if errors.Is(err, errPingUnsupported) {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				session.logger.Debug("ping_failed", "err", err.Error())
+			}
+			continue
+		}
+		rtt := time.Since(start)
+		session.rtt.Store(int64(rtt))
+		session.latency.observe(rtt)
+	}
+}
@@ -0,0 +1,53 @@
+package hub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion is the newest wire format implemented by the hub.
+const ProtocolVersion = 1
+
+// subprotocolPrefix names the Sec-WebSocket-Protocol values accepted on /ws,
+// e.g. "cgb.v1".
+const subprotocolPrefix = "cgb.v"
+
+// subprotocols lists the negotiable subprotocols, newest first so clients
+// offering several versions get the highest one both sides support.
+func (h *Hub) subprotocols() []string {
+	names := make([]string, 0, ProtocolVersion-h.cfg.MinProtocolVersion+1)
+	for v := ProtocolVersion; v >= h.cfg.MinProtocolVersion; v-- {
+		names = append(names, subprotocolPrefix+strconv.Itoa(v))
+	}
+	return names
+}
+
+// negotiateProtocol resolves the wire format for a connection from the
+// negotiated subprotocol and the register payload. Clients that specify
+// neither are treated as speaking version 1.
+func (h *Hub) negotiateProtocol(subprotocol string, requested int) (int, error) {
+	version := 0
+	if subprotocol != "" {
+		v, err := strconv.Atoi(strings.TrimPrefix(subprotocol, subprotocolPrefix))
+		if err != nil || !strings.HasPrefix(subprotocol, subprotocolPrefix) {
+			return 0, fmt.Errorf("unsupported subprotocol %q", subprotocol)
+		}
+		version = v
+	}
+
+	if requested != 0 {
+		if version != 0 && version != requested {
+			return 0, fmt.Errorf("protocol version %d conflicts with subprotocol %q", requested, subprotocol)
+		}
+		version = requested
+	}
+
+	if version == 0 {
+		version = 1
+	}
+	if version < h.cfg.MinProtocolVersion || version > ProtocolVersion {
+		return 0, fmt.Errorf("unsupported protocol version %d (supported %d-%d)", version, h.cfg.MinProtocolVersion, ProtocolVersion)
+	}
+	return version, nil
+}
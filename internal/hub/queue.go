@@ -0,0 +1,94 @@
+package hub
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// QueuePolicy selects how the game send queue behaves once it is full.
+type QueuePolicy string
+
+const (
+	// QueueDropOldest discards the oldest queued frame to make room.
+	QueueDropOldest QueuePolicy = "drop-oldest"
+	// QueueDropNewest discards the incoming frame.
+	QueueDropNewest QueuePolicy = "drop-newest"
+	// QueueCoalesce replaces the oldest queued frame from the same controller,
+	// falling back to drop-oldest when the controller has nothing queued.
+	QueueCoalesce QueuePolicy = "coalesce-by-controller"
+	// QueueClose disconnects the game so it can reconnect with a clean queue.
+	QueueClose QueuePolicy = "close-connection"
+)
+
+// ParseQueuePolicy validates a policy name. An empty value selects drop-oldest.
+func ParseQueuePolicy(raw string) (QueuePolicy, error) {
+	switch policy := QueuePolicy(strings.ToLower(strings.TrimSpace(raw))); policy {
+	case "":
+		return QueueDropOldest, nil
+	case QueueDropOldest, QueueDropNewest, QueueCoalesce, QueueClose:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown queue policy %q", raw)
+	}
+}
+
+// QueueStats reports the state of the game send queue and cumulative drops.
+type QueueStats struct {
+	Policy     QueuePolicy
+	Capacity   int
+	Depth      int
+	DropOldest uint64
+	DropNewest uint64
+	Coalesced  uint64
+	Closed     uint64
+	Merged     uint64
+	Priority   uint64
+	Stale      uint64
+	Filtered   uint64
+	// Throttled counts controller frames dropped by Config.InputRateHz.
+	Throttled uint64
+}
+
+type queueCounters struct {
+	dropOldest atomic.Uint64
+	dropNewest atomic.Uint64
+	coalesced  atomic.Uint64
+	closed     atomic.Uint64
+	merged     atomic.Uint64
+	priority   atomic.Uint64
+	stale      atomic.Uint64
+	filtered   atomic.Uint64
+	throttled  atomic.Uint64
+}
+
+type queuedFrame struct {
+	data         []byte
+	controllerID string
+	binary       bool
+}
+
+// QueueStats returns the current relay queue statistics.
+func (h *Hub) QueueStats() QueueStats {
+	h.mu.Lock()
+	game := h.game
+	h.mu.Unlock()
+
+	stats := QueueStats{
+		Policy:     h.cfg.QueuePolicy,
+		Capacity:   h.cfg.RelayQueueSize,
+		DropOldest: h.drops.dropOldest.Load(),
+		DropNewest: h.drops.dropNewest.Load(),
+		Coalesced:  h.drops.coalesced.Load(),
+		Closed:     h.drops.closed.Load(),
+		Merged:     h.drops.merged.Load(),
+		Priority:   h.drops.priority.Load(),
+		Stale:      h.drops.stale.Load(),
+		Filtered:   h.drops.filtered.Load(),
+		Throttled:  h.drops.throttled.Load(),
+	}
+	if game != nil {
+		stats.Depth = game.depth()
+	}
+	return stats
+}
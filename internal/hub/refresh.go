@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	msgTypeRefresh = "refresh"
+	msgTypeToken   = "token"
+)
+
+type tokenNotice struct {
+	Type      string `json:"type"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+	TTL       int    `json:"ttl"`
+}
+
+type refreshFailedNotice struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// refreshControllerToken replaces the token a connected controller registered
+// with, so it can reconnect after SESSION_TOKEN_TTL without a new lobby
+// lookup. It fails once the slot's token was revoked or reissued to someone
+// else.
+func (h *Hub) refreshControllerToken(session *controllerSession) (string, time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// session.user changes under h.mu when the slot is handed off.
+	if session.user.ID != "" {
+		h.pruneBansLocked(time.Now())
+		if _, banned := h.bans[session.user.ID]; banned {
+			return "", time.Time{}, ErrBanned
+		}
+	}
+	if session.token == "" {
+		return "", time.Time{}, errors.New("controller did not register with a token")
+	}
+	if h.controllers[session.id] != session || h.slotTokens[session.id] != session.token {
+		return "", time.Time{}, errors.New("slot token was revoked")
+	}
+
+	cohort := session.cohort.Name
+	if cohort == defaultCohort {
+		cohort = ""
+	}
+	tokenValue, expiresAt, err := h.storeTokenLocked(session.id, session.user, cohort, h.cfg.TokenTTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	session.token = tokenValue
+	return tokenValue, expiresAt, nil
+}
+
+func (h *Hub) handleRefresh(ctx context.Context, session *controllerSession) {
+	token, expiresAt, err := h.refreshControllerToken(session)
+	if err != nil {
+		session.logger.Warn("token_refresh_failed", "err", err.Error())
+		if err := h.writeController(ctx, session, refreshFailedNotice{Type: "refresh_failed", Reason: err.Error()}); err != nil {
+			session.logger.Debug("refresh_notice_failed", "err", err.Error())
+		}
+		return
+	}
+
+	session.logger.Info("token_refreshed", "expires_at", expiresAt.UTC().Format(time.RFC3339))
+	notice := tokenNotice{
+		Type:      msgTypeToken,
+		Token:     token,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		TTL:       int(time.Until(expiresAt).Seconds()),
+	}
+	if err := h.writeController(ctx, session, notice); err != nil {
+		session.logger.Warn("refresh_notice_failed", "err", err.Error())
+	}
+}
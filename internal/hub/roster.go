@@ -0,0 +1,189 @@
+package hub
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Roster frames keep a game listener's view of the connected controllers in
+// sync. As soon as it registers, a listener receives
+// {"type":"state","op":"snapshot","controllers":[...]}, then one "join" or
+// "leave" frame per change with the affected controller in "controllers". A
+// join for a slot the game already knows means the controller reconnected
+// and replaces the entry. Roster frames are told apart from relayed
+// controller state by "op".
+//
+// Alongside each join or leave the listeners also receive a
+// controller_joined or controller_left presence frame naming the slot and
+// its player, for games that only want to display connection status.
+const (
+	rosterSnapshot = "snapshot"
+	rosterJoin     = "join"
+	rosterLeave    = "leave"
+)
+
+type rosterEntry struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Personality string `json:"personality,omitempty"`
+	Cohort      string `json:"cohort,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"`
+	ConnectedAt int64  `json:"connectedAt"`
+	LastSeen    int64  `json:"lastSeen"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+type rosterFrame struct {
+	Type        string        `json:"type"`
+	Op          string        `json:"op"`
+	Controllers []rosterEntry `json:"controllers"`
+	Timestamp   int64         `json:"timestamp"`
+}
+
+type controllerPresenceEvent struct {
+	Type        string `json:"type"`
+	SlotID      string `json:"slotId"`
+	UserID      string `json:"userId,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Personality string `json:"personality,omitempty"`
+	Replaced    bool   `json:"replaced,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// rosterEntryLocked describes session for the game. The caller must hold
+// h.mu.
+func (h *Hub) rosterEntryLocked(session *controllerSession) rosterEntry {
+	session.lastSeenM.Lock()
+	lastSeen := session.lastSeen
+	session.lastSeenM.Unlock()
+	return rosterEntry{
+		ID:          session.id,
+		UserID:      session.user.ID,
+		Name:        session.user.Name,
+		Personality: session.user.Personality,
+		Cohort:      session.cohort.Name,
+		Hidden:      !session.hiddenSince.IsZero(),
+		ConnectedAt: session.connectedAt.UnixMilli(),
+		LastSeen:    lastSeen.UnixMilli(),
+		Meta:        h.slotMetaLocked(session.id),
+	}
+}
+
+func (h *Hub) encodeRoster(op string, entries []rosterEntry) []byte {
+	if entries == nil {
+		entries = []rosterEntry{}
+	}
+	payload, err := json.Marshal(rosterFrame{
+		Type:        "state",
+		Op:          op,
+		Controllers: entries,
+		Timestamp:   time.Now().UnixMilli(),
+	})
+	if err != nil {
+		h.log.Error("roster_encode_failed", "op", op, "err", err.Error())
+		return nil
+	}
+	return payload
+}
+
+// sendRosterLocked queues a snapshot of every connected controller to a
+// newly registered game listener. Holding h.mu across the enqueue orders the
+// snapshot ahead of any join or leave pushed afterwards; the listener's queue
+// is still empty so enqueue cannot block. The caller must hold h.mu.
+func (h *Hub) sendRosterLocked(game *gameSession) {
+	entries := make([]rosterEntry, 0, len(h.controllers))
+	for _, session := range h.controllers {
+		entries = append(entries, h.rosterEntryLocked(session))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	if payload := h.encodeRoster(rosterSnapshot, entries); payload != nil {
+		game.enqueue(payload, "server")
+	}
+}
+
+// rosterChangeLocked queues the join or leave frames for session to the
+// current listeners. replaced marks a join that took over a live connection
+// for the same slot; reason explains a leave. The caller must hold h.mu and
+// call deliver on the result once it is released.
+func (h *Hub) rosterChangeLocked(op string, session *controllerSession, replaced bool, reason string) rosterPush {
+	listeners := h.gameListenersLocked()
+	if len(listeners) == 0 {
+		return rosterPush{}
+	}
+
+	presence := controllerPresenceEvent{
+		Type:        "controller_joined",
+		SlotID:      session.id,
+		UserID:      session.user.ID,
+		Name:        session.user.Name,
+		Personality: session.user.Personality,
+		Replaced:    replaced,
+		Timestamp:   time.Now().UnixMilli(),
+	}
+	if op == rosterLeave {
+		presence.Type = "controller_left"
+		presence.Reason = reason
+	} else {
+		presence.Meta = h.slotMetaLocked(session.id)
+	}
+
+	push := rosterPush{listeners: listeners}
+	if payload := h.encodeRoster(op, []rosterEntry{h.rosterEntryLocked(session)}); payload != nil {
+		push.payloads = append(push.payloads, payload)
+	}
+	if payload, err := json.Marshal(presence); err != nil {
+		session.logger.Error("presence_event_encode_failed", "err", err.Error())
+	} else {
+		push.payloads = append(push.payloads, payload)
+	}
+	h.roster.pending = append(h.roster.pending, push)
+	return rosterPush{hub: h}
+}
+
+// rosterOutbox orders roster changes across the release of h.mu. Changes
+// are queued in pending, under h.mu, in the order they happen and handed to
+// the listeners under flush, so two changes racing for the listeners'
+// queues, such as a leave and the rejoin that follows it, reach the game in
+// the order the hub saw them.
+type rosterOutbox struct {
+	flush   sync.Mutex
+	pending []rosterPush // guarded by Hub.mu
+}
+
+type rosterPush struct {
+	hub       *Hub
+	payloads  [][]byte
+	listeners []*gameSession
+}
+
+// deliver sends every queued roster change, this one included. The caller
+// must not hold h.mu.
+func (p rosterPush) deliver() {
+	h := p.hub
+	if h == nil {
+		return
+	}
+	h.roster.flush.Lock()
+	defer h.roster.flush.Unlock()
+
+	h.mu.Lock()
+	pending := h.roster.pending
+	h.roster.pending = nil
+	h.mu.Unlock()
+
+	for _, push := range pending {
+		for _, payload := range push.payloads {
+			for _, game := range push.listeners {
+				game.enqueue(payload, "server")
+			}
+		}
+	}
+}
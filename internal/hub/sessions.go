@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// SessionInfo describes a connected WebSocket session.
+type SessionInfo struct {
+	Role        string
+	ID          string
+	RemoteIP    string
+	UserID      string
+	Cohort      string
+	ConnectedAt time.Time
+	LastSeen    time.Time
+	// RTT is the last measured WebSocket ping round trip, zero until the
+	// first ping completes.
+	RTT       time.Duration
+	Telemetry *Telemetry
+	// Calibrations holds the latest calibration of each kind, by kind.
+	Calibrations []Calibration
+	// Mirror marks a read-only game listener.
+	Mirror bool
+}
+
+// Status summarises the hub's single room: the game session and its
+// controllers.
+type Status struct {
+	GameConnected  bool
+	GameRemoteIP   string
+	Controllers    int
+	MaxControllers int
+	Epoch          uint64
+	Mirrors        int
+	MaxGames       int
+}
+
+// Sessions lists the game session, if any, then mirror listeners by connect
+// time, followed by the connected controllers ordered by slot.
+func (h *Hub) Sessions() []SessionInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sessions := make([]SessionInfo, 0, len(h.controllers)+1)
+	if h.game != nil {
+		sessions = append(sessions, SessionInfo{
+			Role:        roleGame,
+			RemoteIP:    h.game.remoteIP,
+			ConnectedAt: h.game.connectedAt,
+		})
+	}
+	mirrors := make([]SessionInfo, 0, len(h.mirrors))
+	for mirror := range h.mirrors {
+		mirrors = append(mirrors, SessionInfo{
+			Role:        roleGame,
+			ID:          "mirror",
+			RemoteIP:    mirror.remoteIP,
+			ConnectedAt: mirror.connectedAt,
+			Mirror:      true,
+		})
+	}
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].ConnectedAt.Before(mirrors[j].ConnectedAt) })
+	sessions = append(sessions, mirrors...)
+
+	controllers := make([]SessionInfo, 0, len(h.controllers))
+	for id, session := range h.controllers {
+		session.lastSeenM.Lock()
+		lastSeen := session.lastSeen
+		session.lastSeenM.Unlock()
+		controllers = append(controllers, SessionInfo{
+			Role:         roleController,
+			ID:           id,
+			RemoteIP:     session.remoteIP,
+			UserID:       session.user.ID,
+			Cohort:       session.cohort.Name,
+			ConnectedAt:  session.connectedAt,
+			LastSeen:     lastSeen,
+			RTT:          time.Duration(session.rtt.Load()),
+			Telemetry:    session.telemetry.clone(),
+			Calibrations: session.calibrationsLocked(),
+		})
+	}
+	sort.Slice(controllers, func(i, j int) bool { return controllers[i].ID < controllers[j].ID })
+	return append(sessions, controllers...)
+}
+
+// Status reports the current room state.
+func (h *Hub) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := Status{
+		Controllers:    len(h.controllers),
+		MaxControllers: h.cfg.MaxControllers,
+		Epoch:          h.epoch.Load(),
+		Mirrors:        len(h.mirrors),
+		MaxGames:       h.cfg.MaxGames,
+	}
+	if h.game != nil {
+		status.GameConnected = true
+		status.GameRemoteIP = h.game.remoteIP
+	}
+	return status
+}
+
+// SetMaxControllers changes the controller capacity at runtime. Lowering it
+// does not disconnect controllers already above the new limit.
+func (h *Hub) SetMaxControllers(n int) error {
+	if n <= 0 {
+		return errors.New("max controllers must be positive")
+	}
+	h.mu.Lock()
+	previous := h.cfg.MaxControllers
+	h.cfg.MaxControllers = n
+	h.mu.Unlock()
+
+	h.log.Info("max_controllers_changed", "previous", previous, "max_controllers", n)
+	return nil
+}
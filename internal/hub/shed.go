@@ -0,0 +1,169 @@
+package hub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	shedSampleInterval = 500 * time.Millisecond
+	// shedQueueRatio is the relay queue fill ratio counted as overloaded.
+	shedQueueRatio = 0.9
+	// shedLagThreshold is how late the sampling ticker may fire before the
+	// process is considered CPU starved.
+	shedLagThreshold = 100 * time.Millisecond
+	// shedEnterSamples and shedExitSamples add hysteresis so brief spikes do
+	// not toggle shedding.
+	shedEnterSamples = 4
+	shedExitSamples  = 10
+	// shedBroadcastDivisor divides BroadcastRateHz while shedding.
+	shedBroadcastDivisor = 2
+)
+
+// ShedStatus reports whether the hub is shedding load.
+type ShedStatus struct {
+	Shedding bool
+	Reason   string
+	Since    time.Time
+	// Rejected counts controller and mirror registrations refused while
+	// shedding.
+	Rejected uint64
+}
+
+type loadShedder struct {
+	mu     sync.Mutex
+	status ShedStatus
+}
+
+func (s *loadShedder) get() ShedStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *loadShedder) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Shedding
+}
+
+func (s *loadShedder) set(shedding bool, reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Shedding == shedding {
+		return false
+	}
+	s.status.Shedding = shedding
+	s.status.Reason = reason
+	s.status.Since = time.Now()
+	return true
+}
+
+func (s *loadShedder) reject() {
+	s.mu.Lock()
+	s.status.Rejected++
+	s.mu.Unlock()
+}
+
+// ShedStatus returns the current load shedding state.
+func (h *Hub) ShedStatus() ShedStatus {
+	return h.shed.get()
+}
+
+// RunLoadMonitor samples the relay queue and scheduler lag until ctx is done,
+// switching load shedding on under sustained overload. While shedding, new
+// controllers without a lobby assignment and new mirror listeners are
+// refused, all controller input is coalesced per relay tick and state frames
+// reach controllers at a fraction of BroadcastRateHz; the game and connected
+// controllers are kept. Config.LoadShedding must be set so the game session
+// has a coalescer.
+func (h *Hub) RunLoadMonitor(ctx context.Context) {
+	ticker := time.NewTicker(shedSampleInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	overloaded, healthy := 0, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			lag := now.Sub(last) - shedSampleInterval
+			last = now
+
+			reason := h.overloadReason(lag)
+			if reason == "" {
+				overloaded = 0
+				healthy++
+			} else {
+				healthy = 0
+				overloaded++
+			}
+
+			switch {
+			case overloaded >= shedEnterSamples:
+				if h.shed.set(true, reason) {
+					h.log.Warn("load_shedding_started", "reason", reason, "lag", lag.String())
+					h.emit("load_shedding_started", "", "", "", "reason", reason)
+				}
+			case healthy >= shedExitSamples:
+				if h.shed.set(false, "") {
+					h.log.Info("load_shedding_stopped")
+					h.emit("load_shedding_stopped", "", "", "")
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) overloadReason(lag time.Duration) string {
+	if lag > shedLagThreshold {
+		return "cpu_saturated"
+	}
+
+	h.mu.Lock()
+	game := h.game
+	h.mu.Unlock()
+	if game != nil && float64(game.depth()) >= float64(h.cfg.RelayQueueSize)*shedQueueRatio {
+		return "queue_full"
+	}
+	return ""
+}
+
+// isKnownSlotLocked reports whether slotID is connected or bound to a lobby
+// user, i.e. whether it must keep working while shedding.
+func (h *Hub) isKnownSlotLocked(slotID string) bool {
+	if _, ok := h.controllers[slotID]; ok {
+		return true
+	}
+	if _, ok := h.slotTokens[slotID]; ok {
+		return true
+	}
+	_, ok := h.restored[slotID]
+	return ok
+}
+
+// admitUnderLoad reports whether a controller registration may proceed while
+// shedding. Token holders were placed by the lobby and are always admitted.
+func (h *Hub) admitUnderLoad(slotID string, hasToken bool) bool {
+	if hasToken || !h.shed.active() {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.isKnownSlotLocked(slotID)
+}
+
+// stateInterval is the minimum spacing of state frames to one controller,
+// stretched while shedding. Zero leaves state frames unpaced.
+func (h *Hub) stateInterval() time.Duration {
+	if h.cfg.BroadcastRateHz <= 0 {
+		return 0
+	}
+	interval := time.Second / time.Duration(h.cfg.BroadcastRateHz)
+	if h.shed.active() {
+		interval *= shedBroadcastDivisor
+	}
+	return interval
+}
@@ -0,0 +1,100 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+const (
+	maxSlotMetaKeys  = 16
+	maxSlotMetaKey   = 32
+	maxSlotMetaValue = 128
+)
+
+type slotMetaFrame struct {
+	Type      string            `json:"type"`
+	SlotID    string            `json:"slotId"`
+	Meta      map[string]string `json:"meta"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// SetSlotMetadata replaces the metadata of slotID; an empty map clears it.
+//
+// Slot metadata is operator-set identity for a slot, such as its colour,
+// station number or physical position, so the game and the controller show
+// the same cues. It is sent in the controller's "registered" ack, the game's
+// roster entries and controller_joined frames, and as a
+// {"type":"slot_meta","slotId":...,"meta":{...}} frame to both sides when it
+// changes while the slot is connected.
+func (h *Hub) SetSlotMetadata(slotID string, meta map[string]string) error {
+	slotID = strings.ToLower(strings.TrimSpace(slotID))
+	if err := h.cfg.IDPolicy.validateShape(slotID); err != nil {
+		return fmt.Errorf("invalid slot id %q: %w", slotID, err)
+	}
+	if len(meta) > maxSlotMetaKeys {
+		return fmt.Errorf("at most %d metadata keys allowed", maxSlotMetaKeys)
+	}
+	for key, value := range meta {
+		if key == "" || len(key) > maxSlotMetaKey {
+			return fmt.Errorf("metadata keys must be 1-%d characters", maxSlotMetaKey)
+		}
+		if len(value) > maxSlotMetaValue {
+			return fmt.Errorf("metadata value of %q exceeds %d bytes", key, maxSlotMetaValue)
+		}
+	}
+
+	h.mu.Lock()
+	if len(meta) == 0 {
+		delete(h.slotMeta, slotID)
+	} else {
+		if h.slotMeta == nil {
+			h.slotMeta = make(map[string]map[string]string)
+		}
+		h.slotMeta[slotID] = maps.Clone(meta)
+	}
+	session := h.controllers[slotID]
+	listeners := h.gameListenersLocked()
+	h.notifyAssignmentsLocked()
+	h.mu.Unlock()
+
+	h.log.Info("slot_meta_set", "slot", slotID, "keys", len(meta))
+	if session == nil {
+		return nil
+	}
+	frame := slotMetaFrame{Type: "slot_meta", SlotID: slotID, Meta: meta, Timestamp: time.Now().UnixMilli()}
+	if frame.Meta == nil {
+		frame.Meta = map[string]string{}
+	}
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	for _, game := range listeners {
+		game.enqueue(payload, "server")
+	}
+	if err := h.writeController(context.Background(), session, frame); err != nil {
+		session.logger.Debug("slot_meta_notice_failed", "err", err.Error())
+	}
+	return nil
+}
+
+// SlotMetadata returns a copy of the metadata of every slot that has some.
+func (h *Hub) SlotMetadata() map[string]map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]map[string]string, len(h.slotMeta))
+	for slotID, meta := range h.slotMeta {
+		out[slotID] = maps.Clone(meta)
+	}
+	return out
+}
+
+// slotMetaLocked returns a copy of slotID's metadata, or nil. The caller
+// must hold h.mu.
+func (h *Hub) slotMetaLocked(slotID string) map[string]string {
+	return maps.Clone(h.slotMeta[slotID])
+}
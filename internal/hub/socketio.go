@@ -0,0 +1,381 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+// Socket.IO compatibility for game clients built on a Socket.IO library.
+// Only what such a client needs to talk to the game role is implemented:
+// Engine.IO 4 over the WebSocket transport (clients must set
+// transports: ["websocket"]), the default namespace, and text events.
+//
+// The CONNECT auth object is the register message, so
+// io(url, {auth: {token: "..."}}) registers as {"role":"game","token":"..."}.
+// Hub frames arrive as events named after their "type", with the frame as
+// the argument; an event the game emits becomes a frame of that type with
+// the argument's fields. The "message" event carries a frame verbatim in
+// both directions.
+const (
+	socketIOPingInterval = 25 * time.Second
+	socketIOPingTimeout  = 20 * time.Second
+	socketIOEvent        = "message"
+)
+
+// Engine.IO packet types.
+const (
+	eioOpen    = '0'
+	eioClose   = '1'
+	eioPing    = '2'
+	eioPong    = '3'
+	eioMessage = '4'
+	eioNoop    = '6'
+)
+
+// Socket.IO packet types, carried in Engine.IO messages.
+const (
+	sioConnect      = '0'
+	sioDisconnect   = '1'
+	sioEvent        = '2'
+	sioAck          = '3'
+	sioConnectError = '4'
+)
+
+// socketIOConn adapts a Socket.IO client on a WebSocket to gameConn.
+type socketIOConn struct {
+	ws       *websocket.Conn
+	lastSeen atomic.Int64 // unix nanoseconds
+
+	writeMu sync.Mutex
+}
+
+func (c *socketIOConn) send(ctx context.Context, packet string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.Write(ctx, websocket.MessageText, []byte(packet))
+}
+
+// readPacket returns the next Socket.IO packet, answering Engine.IO pings
+// and skipping pongs on the way.
+func (c *socketIOConn) readPacket(ctx context.Context) ([]byte, error) {
+	for {
+		typ, data, err := c.ws.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.lastSeen.Store(time.Now().UnixNano())
+		if typ != websocket.MessageText || len(data) == 0 {
+			continue
+		}
+		switch data[0] {
+		case eioMessage:
+			if len(data) > 1 {
+				return data[1:], nil
+			}
+		case eioPing:
+			if err := c.send(ctx, string(eioPong)+string(data[1:])); err != nil {
+				return nil, err
+			}
+		case eioClose:
+			return nil, websocket.CloseError{Code: websocket.StatusNormalClosure, Reason: "client closed"}
+		case eioPong, eioNoop:
+		}
+	}
+}
+
+// Read implements gameConn, returning the game's events as hub frames.
+func (c *socketIOConn) Read(ctx context.Context) (websocket.MessageType, []byte, error) {
+	for {
+		packet, err := c.readPacket(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch packet[0] {
+		case sioDisconnect:
+			return 0, nil, websocket.CloseError{Code: websocket.StatusNormalClosure, Reason: "client disconnected"}
+		case sioEvent:
+			ack, frame, err := decodeSocketIOEvent(packet[1:])
+			if err != nil {
+				// Match the WebSocket path, where the hub logs and drops
+				// frames it cannot parse.
+				return websocket.MessageText, packet, nil
+			}
+			if ack != "" {
+				if err := c.send(ctx, string(eioMessage)+string(sioAck)+ack+"[]"); err != nil {
+					return 0, nil, err
+				}
+			}
+			return websocket.MessageText, frame, nil
+		}
+	}
+}
+
+// decodeSocketIOEvent turns `<ack id>["type",{...}]` into a hub frame.
+func decodeSocketIOEvent(packet []byte) (string, []byte, error) {
+	ackEnd := 0
+	for ackEnd < len(packet) && packet[ackEnd] >= '0' && packet[ackEnd] <= '9' {
+		ackEnd++
+	}
+	ack := string(packet[:ackEnd])
+
+	var args []json.RawMessage
+	if err := json.Unmarshal(packet[ackEnd:], &args); err != nil || len(args) == 0 {
+		return "", nil, errors.New("socket.io: malformed event")
+	}
+	var name string
+	if err := json.Unmarshal(args[0], &name); err != nil || name == "" {
+		return "", nil, errors.New("socket.io: event name must be a string")
+	}
+	var arg json.RawMessage
+	if len(args) > 1 {
+		arg = args[1]
+	}
+
+	if name == socketIOEvent {
+		var text string
+		if json.Unmarshal(arg, &text) == nil {
+			return ack, []byte(text), nil
+		}
+		return ack, arg, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(arg) > 0 && !bytes.Equal(arg, []byte("null")) {
+		if err := json.Unmarshal(arg, &fields); err != nil {
+			return "", nil, fmt.Errorf("socket.io: %s argument must be an object", name)
+		}
+	}
+	typ, _ := json.Marshal(name)
+	fields["type"] = typ
+	frame, err := json.Marshal(fields)
+	return ack, frame, err
+}
+
+// Write implements gameConn, emitting a hub frame as an event named after
+// its type.
+func (c *socketIOConn) Write(ctx context.Context, typ websocket.MessageType, p []byte) error {
+	name := socketIOEvent
+	arg := json.RawMessage(p)
+	if typ != websocket.MessageText || !json.Valid(p) {
+		// Passthrough frames are not JSON; strings survive, binary as
+		// base64.
+		var err error
+		if typ == websocket.MessageText {
+			arg, err = json.Marshal(string(p))
+		} else {
+			arg, err = json.Marshal(p)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		var head struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(p, &head) == nil && head.Type != "" {
+			name = head.Type
+		}
+	}
+	event, err := json.Marshal([]any{name, arg})
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, string(eioMessage)+string(sioEvent)+string(event))
+}
+
+// Close implements gameConn. Hub decisions such as a handover also end
+// the Socket.IO session, so the client library does not reconnect on its
+// own; after a plain close it does.
+func (c *socketIOConn) Close(code websocket.StatusCode, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if code >= 4000 {
+		if notice, err := json.Marshal([]any{"close", map[string]any{"code": int(code), "reason": reason}}); err == nil {
+			_ = c.send(ctx, string(eioMessage)+string(sioEvent)+string(notice))
+		}
+		_ = c.send(ctx, string(eioMessage)+string(sioDisconnect))
+	}
+	return c.ws.Close(code, reason)
+}
+
+// keepAlive sends Engine.IO pings and drops the client once it stops
+// answering.
+func (c *socketIOConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(socketIOPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastSeen.Load())) > socketIOPingInterval+socketIOPingTimeout {
+			_ = c.ws.Close(websocket.StatusGoingAway, "ping timeout")
+			return
+		}
+		if err := c.send(ctx, string(eioPing)); err != nil {
+			return
+		}
+	}
+}
+
+// HandleSocketIO serves Socket.IO game clients, e.g. on /socket.io/.
+func (h *Hub) HandleSocketIO(w http.ResponseWriter, r *http.Request) {
+	remote := h.remoteAddr(r)
+	q := r.URL.Query()
+	if q.Get("EIO") != "4" {
+		writeEngineIOError(w, 5, "Unsupported protocol version")
+		return
+	}
+	if q.Get("transport") != "websocket" {
+		// Long-polling, the Socket.IO default, is not implemented.
+		writeEngineIOError(w, 0, "Transport unknown")
+		return
+	}
+
+	if retryAfter, ok := h.limiter.acquire(remote, time.Now()); !ok {
+		if retryAfter > 0 {
+			h.upgrades.fail(UpgradeFailLockout)
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "too many failed register attempts", http.StatusTooManyRequests)
+			return
+		}
+		h.upgrades.fail(UpgradeFailIPLimit)
+		h.log.Warn("socketio_ip_limit", "remote_ip", remote, "limit", h.cfg.MaxConnsPerIP)
+		http.Error(w, "too many connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	defer h.limiter.release(remote, time.Now())
+
+	if !h.limiter.beginRegister(remote) {
+		h.upgrades.fail(UpgradeFailPending)
+		h.log.Warn("socketio_pending_limit", "remote_ip", remote, "limit", h.cfg.MaxPendingPerIP)
+		http.Error(w, "too many unregistered connections from this address", http.StatusTooManyRequests)
+		return
+	}
+	registering := sync.OnceFunc(func() { h.limiter.endRegister(remote) })
+	defer registering()
+
+	opts := &websocket.AcceptOptions{CompressionMode: websocket.CompressionDisabled}
+	if len(h.cfg.AllowedOrigins) > 0 {
+		opts.OriginPatterns = h.cfg.AllowedOrigins
+	}
+	ws, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		class := classifyAcceptError(err)
+		h.upgrades.fail(class)
+		h.log.Warn("socketio_accept_failed", "remote_ip", remote, "reason", class, "origin", r.Header.Get("Origin"), "err", err.Error())
+		return
+	}
+	h.upgrades.accepted.Add(1)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	conn := &socketIOConn{ws: ws}
+	conn.lastSeen.Store(time.Now().UnixNano())
+
+	sid, err := generateToken()
+	if err != nil {
+		h.log.Error("socketio_session_id_failed", "err", err.Error())
+		_ = ws.Close(websocket.StatusInternalError, "internal error")
+		return
+	}
+	open, _ := json.Marshal(map[string]any{
+		"sid":          sid,
+		"upgrades":     []string{},
+		"pingInterval": socketIOPingInterval.Milliseconds(),
+		"pingTimeout":  socketIOPingTimeout.Milliseconds(),
+		"maxPayload":   wsMessageLimit,
+	})
+	if err := conn.send(ctx, string(eioOpen)+string(open)); err != nil {
+		_ = ws.Close(websocket.StatusInternalError, "internal error")
+		return
+	}
+	crash.Go(func() { conn.keepAlive(ctx) })
+
+	reg, status, reason := h.readSocketIOConnect(ctx, conn, remote)
+	registering()
+	if status == 0 && !h.authenticateGame(reg.Token) {
+		h.log.Warn("register_game_token_invalid", "role", roleGame, "id", "", "remote_ip", remote, "token_present", reg.Token != "", "transport", "socketio")
+		h.emit("register_game_token_invalid", roleGame, "", remote)
+		status, reason = closeWith(ReasonTokenInvalid)
+	}
+	if status != 0 {
+		h.registerFailed(remote)
+		if msg, err := json.Marshal(map[string]string{"message": reason}); err == nil {
+			_ = conn.send(ctx, string(eioMessage)+string(sioConnectError)+string(msg))
+		}
+		_ = ws.Close(status, reason)
+		return
+	}
+	connected, _ := json.Marshal(map[string]string{"sid": sid})
+	if err := conn.send(ctx, string(eioMessage)+string(sioConnect)+string(connected)); err != nil {
+		_ = ws.Close(websocket.StatusInternalError, "internal error")
+		return
+	}
+
+	if reg.Mirror {
+		status, reason = h.handleMirror(ctx, conn, remote, reg)
+	} else {
+		status, reason = h.handleGame(ctx, conn, remote, reg)
+	}
+	if reason == "" {
+		reason = statusText(status)
+	}
+	_ = conn.Close(status, reason)
+}
+
+// readSocketIOConnect waits for the CONNECT packet and reads its auth
+// object as a game register message.
+func (h *Hub) readSocketIOConnect(ctx context.Context, conn *socketIOConn, remote string) (registerPayload, websocket.StatusCode, string) {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.RegisterTimeout)
+	defer cancel()
+
+	packet, err := conn.readPacket(ctx)
+	if err != nil {
+		h.log.Warn("register_read_failed", "role", roleGame, "id", "", "remote_ip", remote, "err", err.Error(), "transport", "socketio")
+		return registerPayload{}, websocket.StatusPolicyViolation, "register timeout"
+	}
+	if packet[0] != sioConnect || (len(packet) > 1 && packet[1] == '/') {
+		// Only the default namespace exists.
+		h.log.Warn("socketio_connect_invalid", "remote_ip", remote, "packet", string(packet[:min(len(packet), 32)]))
+		status, reason := closeWith(ReasonRegisterInvalid)
+		return registerPayload{}, status, reason
+	}
+
+	auth := map[string]json.RawMessage{}
+	if len(packet) > 1 {
+		if err := json.Unmarshal(packet[1:], &auth); err != nil {
+			h.log.Warn("register_invalid_json", "role", roleGame, "id", "", "remote_ip", remote, "err", err.Error(), "transport", "socketio")
+			status, reason := closeWith(ReasonRegisterInvalid)
+			return registerPayload{}, status, reason
+		}
+	}
+	auth["role"] = json.RawMessage(`"` + roleGame + `"`)
+	data, err := json.Marshal(auth)
+	if err != nil {
+		status, reason := closeWith(ReasonRegisterInvalid)
+		return registerPayload{}, status, reason
+	}
+	return h.parseRegister(data, "", remote)
+}
+
+// writeEngineIOError answers a handshake the shim cannot serve the way an
+// Engine.IO server does.
+func writeEngineIOError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": code, "message": message})
+}
@@ -0,0 +1,311 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit names accepted by ParseSoftLimits and reported by SoftLimits.
+const (
+	LimitRelayQueue       = "relay_queue"
+	LimitControllerOutbox = "controller_outbox"
+	LimitBroadcastRate    = "broadcast_rate"
+	LimitInputRate        = "controller_input_rate"
+	LimitControllers      = "controllers"
+	LimitConnsPerIP       = "conns_per_ip"
+	LimitPendingPerIP     = "pending_per_ip"
+	LimitWSMessageBytes   = "ws_message_bytes"
+	LimitHTTPBodyBytes    = "http_body_bytes"
+)
+
+var limitNames = []string{
+	LimitRelayQueue,
+	LimitControllerOutbox,
+	LimitBroadcastRate,
+	LimitInputRate,
+	LimitControllers,
+	LimitConnsPerIP,
+	LimitPendingPerIP,
+	LimitWSMessageBytes,
+	LimitHTTPBodyBytes,
+}
+
+const (
+	defaultSoftLimitRatio = 0.8
+	softLimitInterval     = time.Second
+	// softLimitRepeat spaces the warnings for size limits, which are checked
+	// per message rather than sampled.
+	softLimitRepeat = 10 * time.Second
+	// wsMessageLimit is the read limit nhooyr.io/websocket applies to every
+	// connection; larger messages close it with 1009.
+	wsMessageLimit = 32768
+)
+
+// SoftLimitConfig sets the warning threshold of each hard limit. Limits
+// without an explicit threshold warn at Ratio of the hard limit.
+type SoftLimitConfig struct {
+	Ratio      float64
+	Thresholds map[string]int
+}
+
+// ParseSoftLimits parses a comma separated SOFT_LIMITS value such as
+// "0.75,relay_queue=100,controllers=3". A bare number between 0 and 1 sets
+// the default ratio; name=N sets an absolute threshold for one limit.
+func ParseSoftLimits(raw string) (SoftLimitConfig, error) {
+	cfg := SoftLimitConfig{Ratio: defaultSoftLimitRatio, Thresholds: map[string]int{}}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, named := strings.Cut(part, "=")
+		if !named {
+			ratio, err := strconv.ParseFloat(part, 64)
+			if err != nil || ratio <= 0 || ratio > 1 {
+				return SoftLimitConfig{}, fmt.Errorf("soft limit ratio %q must be in (0, 1]", part)
+			}
+			cfg.Ratio = ratio
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !isLimitName(name) {
+			return SoftLimitConfig{}, fmt.Errorf("unknown soft limit %q (known: %s)", name, strings.Join(limitNames, ", "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n <= 0 {
+			return SoftLimitConfig{}, fmt.Errorf("soft limit %s=%q must be a positive integer", name, value)
+		}
+		cfg.Thresholds[name] = n
+	}
+	return cfg, nil
+}
+
+func isLimitName(name string) bool {
+	for _, known := range limitNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SoftLimit reports one hard limit with its warning threshold. Current is
+// the last sampled value. Size limits only record sizes at or over the soft
+// threshold, and stay Over for softLimitRepeat after the last of them.
+type SoftLimit struct {
+	Name     string
+	Hard     int
+	Soft     int
+	Current  int
+	Peak     int
+	Over     bool
+	Warnings uint64
+	LastWarn time.Time
+
+	warned  uint64
+	size    bool
+	lastHit time.Time
+}
+
+type softLimits struct {
+	cfg SoftLimitConfig
+
+	mu     sync.Mutex
+	limits map[string]*SoftLimit
+}
+
+func newSoftLimits(cfg SoftLimitConfig) *softLimits {
+	if cfg.Ratio <= 0 || cfg.Ratio > 1 {
+		cfg.Ratio = defaultSoftLimitRatio
+	}
+	return &softLimits{cfg: cfg, limits: make(map[string]*SoftLimit)}
+}
+
+func (s *softLimits) threshold(name string, hard int) int {
+	if n, ok := s.cfg.Thresholds[name]; ok {
+		return n
+	}
+	return max(1, int(math.Ceil(float64(hard)*s.cfg.Ratio)))
+}
+
+// entryLocked returns the state for name, refreshing hard and soft since
+// limits such as MaxControllers change at runtime.
+func (s *softLimits) entryLocked(name string, hard int) *SoftLimit {
+	limit := s.limits[name]
+	if limit == nil {
+		limit = &SoftLimit{Name: name}
+		s.limits[name] = limit
+	}
+	limit.Hard = hard
+	limit.Soft = s.threshold(name, hard)
+	return limit
+}
+
+// gauge records a sampled value. It reports +1 when the value crossed the
+// soft threshold upwards and -1 when it dropped back below.
+func (s *softLimits) gauge(name string, hard, value int, now time.Time) (SoftLimit, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := s.entryLocked(name, hard)
+	limit.Current = value
+	limit.Peak = max(limit.Peak, value)
+	switch over := value >= limit.Soft; {
+	case over && !limit.Over:
+		limit.Over = true
+		limit.Warnings++
+		limit.LastWarn = now
+		return *limit, 1
+	case !over && limit.Over:
+		limit.Over = false
+		return *limit, -1
+	}
+	return *limit, 0
+}
+
+// hit records a size that reached the soft threshold of a per-message
+// limit. It reports true when no warning went out within softLimitRepeat;
+// suppressed counts the hits since the last warning.
+func (s *softLimits) hit(name string, hard, size int, now time.Time) (limit SoftLimit, warn bool, suppressed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entryLocked(name, hard)
+	entry.Current = size
+	entry.Peak = max(entry.Peak, size)
+	entry.Over = true
+	entry.size = true
+	entry.lastHit = now
+	entry.Warnings++
+	if now.Sub(entry.LastWarn) < softLimitRepeat {
+		return *entry, false, 0
+	}
+	suppressed = entry.Warnings - entry.warned - 1
+	entry.warned = entry.Warnings
+	entry.LastWarn = now
+	return *entry, true, suppressed
+}
+
+func (s *softLimits) snapshot() []SoftLimit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	out := make([]SoftLimit, 0, len(s.limits))
+	for _, limit := range s.limits {
+		if limit.size {
+			limit.Over = now.Sub(limit.lastHit) < softLimitRepeat
+		}
+		out = append(out, *limit)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// SoftLimits returns the tracked limits with their thresholds and the
+// values last seen, ordered by name.
+func (h *Hub) SoftLimits() []SoftLimit {
+	return h.soft.snapshot()
+}
+
+// ObserveSoftLimit checks a size against a per-message hard limit enforced
+// outside the hub, such as the HTTP request body cap.
+func (h *Hub) ObserveSoftLimit(name string, hard, size int) {
+	h.checkSize(name, hard, size)
+}
+
+// checkSize runs for every message, so sizes below the threshold return
+// before taking the soft limit lock; the thresholds never change at runtime.
+func (h *Hub) checkSize(name string, hard, size int) {
+	if hard <= 0 || size < h.soft.threshold(name, hard) {
+		return
+	}
+	limit, warn, suppressed := h.soft.hit(name, hard, size, time.Now())
+	if !warn {
+		return
+	}
+	h.log.Warn("soft_limit_exceeded", "limit", name, "value", size, "soft", limit.Soft, "hard", hard, "suppressed", suppressed)
+	h.emit("soft_limit_exceeded", "", "", "", "limit", name, "value", size, "soft", limit.Soft, "hard", hard)
+}
+
+// RunSoftLimitMonitor samples the hub's gauges until ctx is done and warns
+// once when one reaches its soft threshold, before the hard limit starts
+// dropping frames or refusing connections.
+func (h *Hub) RunSoftLimitMonitor(ctx context.Context) {
+	ticker := time.NewTicker(softLimitInterval)
+	defer ticker.Stop()
+
+	// The rate limits apply per controller, so the gauges report the
+	// busiest session rather than the sum over all of them.
+	var lastFlushes, lastInputs map[*controllerSession]uint64
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(last).Seconds()
+			last = now
+			flushes := make(map[*controllerSession]uint64, len(lastFlushes))
+			inputs := make(map[*controllerSession]uint64, len(lastInputs))
+
+			h.mu.Lock()
+			game := h.game
+			controllers := len(h.controllers)
+			outbox, broadcastRate, inputRate := 0, 0, 0
+			for _, session := range h.controllers {
+				outbox = max(outbox, session.outbox.depth())
+				flushes[session] = session.stateFlushes.Load()
+				inputs[session] = session.inputs.Load()
+				if prev, ok := lastFlushes[session]; ok {
+					broadcastRate = max(broadcastRate, perSecond(flushes[session]-prev, elapsed))
+				}
+				if prev, ok := lastInputs[session]; ok {
+					inputRate = max(inputRate, perSecond(inputs[session]-prev, elapsed))
+				}
+			}
+			maxControllers := h.cfg.MaxControllers
+			h.mu.Unlock()
+			lastFlushes, lastInputs = flushes, inputs
+
+			queue := 0
+			if game != nil {
+				queue = game.depth()
+			}
+			conns, pending := h.limiter.peaks()
+
+			h.checkGauge(LimitRelayQueue, h.cfg.RelayQueueSize, queue, now)
+			h.checkGauge(LimitControllerOutbox, controllerOutboxSize, outbox, now)
+			h.checkGauge(LimitBroadcastRate, h.cfg.BroadcastRateHz, broadcastRate, now)
+			h.checkGauge(LimitInputRate, h.cfg.InputRateHz, inputRate, now)
+			h.checkGauge(LimitControllers, maxControllers, controllers, now)
+			h.checkGauge(LimitConnsPerIP, h.cfg.MaxConnsPerIP, conns, now)
+			h.checkGauge(LimitPendingPerIP, h.cfg.MaxPendingPerIP, pending, now)
+		}
+	}
+}
+
+func (h *Hub) checkGauge(name string, hard, value int, now time.Time) {
+	if hard <= 0 {
+		return
+	}
+	limit, crossed := h.soft.gauge(name, hard, value, now)
+	switch crossed {
+	case 1:
+		h.log.Warn("soft_limit_exceeded", "limit", name, "value", value, "soft", limit.Soft, "hard", hard)
+		h.emit("soft_limit_exceeded", "", "", "", "limit", name, "value", value, "soft", limit.Soft, "hard", hard)
+	case -1:
+		h.log.Info("soft_limit_cleared", "limit", name, "value", value, "soft", limit.Soft, "peak", limit.Peak)
+		h.emit("soft_limit_cleared", "", "", "", "limit", name, "value", value)
+	}
+}
+
+func perSecond(count uint64, seconds float64) int {
+	if seconds <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(count) / seconds))
+}
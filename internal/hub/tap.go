@@ -0,0 +1,38 @@
+package hub
+
+// RelayTap observes every controller frame relayed to the game listeners,
+// after epoch stamping. It runs on the controller read loop and must not
+// block.
+type RelayTap func(slotID, msgType string, payload []byte)
+
+// SetRelayTap installs tap, replacing any previous one. A nil tap disables
+// observation.
+func (h *Hub) SetRelayTap(tap RelayTap) {
+	if tap == nil {
+		h.tap.Store(nil)
+		return
+	}
+	h.tap.Store(&tap)
+}
+
+func (h *Hub) observeRelay(slotID, msgType string, payload []byte) {
+	if tap := h.tap.Load(); tap != nil {
+		(*tap)(slotID, msgType, payload)
+	}
+}
+
+// InjectFrame queues payload to the game listeners as if slotID had sent it,
+// bypassing controller registration, sequencing and the relay tap. It is
+// used to replay recorded input and reports whether any listener was
+// connected. The frame's epoch is restamped with the current one as it is
+// sent, so a replay spanning a match start keeps up with the game.
+func (h *Hub) InjectFrame(slotID, msgType string, payload []byte) bool {
+	games := h.gameListeners()
+	if len(games) > 0 {
+		payload = h.restampEpoch(payload, h.Epoch())
+	}
+	for _, game := range games {
+		game.relay(payload, slotID, msgType, false)
+	}
+	return len(games) > 0
+}
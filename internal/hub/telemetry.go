@@ -0,0 +1,128 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	msgTypeTelemetry = "telemetry"
+
+	// lowBatteryLevel is the charge below which a discharging controller is
+	// reported so staff can swap or plug in the phone before it dies.
+	lowBatteryLevel = 0.15
+
+	maxTelemetryLabel = 16
+)
+
+// Telemetry is the device status last reported by a controller. Battery is a
+// fraction between 0 and 1; nil fields were not reported, typically because
+// the browser lacks the corresponding API.
+type Telemetry struct {
+	Battery    *float64
+	Charging   *bool
+	Visibility string
+	Network    string
+	UpdatedAt  time.Time
+}
+
+type telemetryMessage struct {
+	Battery    *float64 `json:"battery"`
+	Charging   *bool    `json:"charging"`
+	Visibility string   `json:"visibility"`
+	Network    string   `json:"network"`
+}
+
+// recordTelemetry stores a telemetry message on session. Telemetry is for
+// operators only and is not relayed to the game. Malformed reports are logged
+// and ignored rather than disconnecting a player over optional data.
+func (h *Hub) recordTelemetry(session *controllerSession, payload []byte) {
+	var msg telemetryMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		session.logger.Warn("telemetry_invalid", "err", err.Error())
+		return
+	}
+	if msg.Battery != nil && (*msg.Battery < 0 || *msg.Battery > 1) {
+		session.logger.Warn("telemetry_invalid", "err", "battery out of range")
+		return
+	}
+	visibility := strings.ToLower(strings.TrimSpace(msg.Visibility))
+	switch visibility {
+	case "", "visible", "hidden":
+	default:
+		session.logger.Warn("telemetry_invalid", "err", fmt.Sprintf("unknown visibility %q", msg.Visibility))
+		return
+	}
+	network := strings.ToLower(strings.TrimSpace(msg.Network))
+	if len(network) > maxTelemetryLabel {
+		network = network[:maxTelemetryLabel]
+	}
+
+	h.mu.Lock()
+	previous := session.telemetry
+	next := &Telemetry{
+		Battery:    msg.Battery,
+		Charging:   msg.Charging,
+		Visibility: visibility,
+		Network:    network,
+		UpdatedAt:  time.Now(),
+	}
+	// Fields missing from a partial update keep their last known value.
+	if previous != nil {
+		if next.Battery == nil {
+			next.Battery = previous.Battery
+		}
+		if next.Charging == nil {
+			next.Charging = previous.Charging
+		}
+		if next.Visibility == "" {
+			next.Visibility = previous.Visibility
+		}
+		if next.Network == "" {
+			next.Network = previous.Network
+		}
+	}
+	session.telemetry = next
+	wasHidden := !session.hiddenSince.IsZero()
+	hidden := next.Visibility == "hidden"
+	switch {
+	case hidden && !wasHidden:
+		session.hiddenSince = next.UpdatedAt
+	case !hidden && wasHidden:
+		session.hiddenSince = time.Time{}
+	}
+	h.mu.Unlock()
+
+	if hidden != wasHidden {
+		h.notifyActivity(session, !hidden)
+	}
+
+	if batteryLow(next) && !batteryLow(previous) {
+		session.logger.Warn("battery_low", "battery", *next.Battery)
+		h.emit("battery_low", roleController, session.id, session.remoteIP, "battery", *next.Battery)
+	}
+	if previous != nil && previous.Visibility != next.Visibility && next.Visibility != "" {
+		session.logger.Info("visibility_changed", "visibility", next.Visibility)
+		h.emit("visibility_changed", roleController, session.id, session.remoteIP, "visibility", next.Visibility)
+	}
+}
+
+func batteryLow(t *Telemetry) bool {
+	if t == nil || t.Battery == nil {
+		return false
+	}
+	if t.Charging != nil && *t.Charging {
+		return false
+	}
+	return *t.Battery < lowBatteryLevel
+}
+
+func (t *Telemetry) clone() *Telemetry {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,286 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TokenScope names what a bearer token may be used for.
+type TokenScope string
+
+const (
+	// ScopeController tokens let a controller register for one slot as a
+	// Persona user.
+	ScopeController TokenScope = "controller"
+	// ScopeAdmin tokens authorise operators on management endpoints.
+	ScopeAdmin TokenScope = "admin"
+	// ScopeSpectator tokens grant read-only access to match state.
+	ScopeSpectator TokenScope = "spectator"
+)
+
+const (
+	maxTokenClaims   = 16
+	maxTokenClaimKey = 32
+)
+
+var (
+	// ErrTokenInvalid reports a token that was never issued, was revoked or
+	// was issued for another scope.
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenExpired reports a token past its expiry.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenAudience reports a token issued for a different audience.
+	ErrTokenAudience = errors.New("token audience mismatch")
+)
+
+// ParseTokenScope validates a scope name. An empty value selects the
+// controller scope.
+func ParseTokenScope(raw string) (TokenScope, error) {
+	switch scope := TokenScope(strings.ToLower(strings.TrimSpace(raw))); scope {
+	case "":
+		return ScopeController, nil
+	case ScopeController, ScopeAdmin, ScopeSpectator:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("unknown token scope %q", raw)
+	}
+}
+
+// issuedToken is a bearer token held in Hub.tokens. Controller tokens bind
+// subject to a slot and carry the Persona user and cohort; the other scopes
+// name an operator or viewer and carry free-form claims.
+type issuedToken struct {
+	scope     TokenScope
+	subject   string
+	audience  string
+	claims    map[string]string
+	user      userProfile
+	cohort    string
+	expiresAt time.Time
+}
+
+// TokenRequest describes a non-controller token to issue. Controller tokens
+// are issued with IssueControllerToken, which also binds the slot.
+type TokenRequest struct {
+	Scope TokenScope
+	// Subject names the operator or viewer the token stands for.
+	Subject string
+	// Audience, when set, restricts the token to verifiers asking for the
+	// same audience.
+	Audience string
+	Claims   map[string]string
+	TTL      time.Duration
+}
+
+// Principal is the identity behind a verified token.
+type Principal struct {
+	Scope     TokenScope
+	Subject   string
+	Audience  string
+	Claims    map[string]string
+	ExpiresAt time.Time
+}
+
+// IssueToken generates a token for req. Several tokens may be live for the
+// same subject.
+func (h *Hub) IssueToken(req TokenRequest) (string, time.Time, error) {
+	subject := strings.TrimSpace(req.Subject)
+	switch {
+	case req.Scope == ScopeController:
+		return "", time.Time{}, errors.New("controller tokens are issued per slot")
+	case req.Scope != ScopeAdmin && req.Scope != ScopeSpectator:
+		return "", time.Time{}, fmt.Errorf("unknown token scope %q", req.Scope)
+	case subject == "":
+		return "", time.Time{}, errors.New("subject required")
+	case len(req.Claims) > maxTokenClaims:
+		return "", time.Time{}, fmt.Errorf("at most %d claims allowed", maxTokenClaims)
+	}
+	for key := range req.Claims {
+		if key == "" || len(key) > maxTokenClaimKey {
+			return "", time.Time{}, fmt.Errorf("claim keys must be 1-%d characters", maxTokenClaimKey)
+		}
+	}
+
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = h.cfg.TokenTTL
+	}
+	now := time.Now()
+	info := issuedToken{
+		scope:     req.Scope,
+		subject:   subject,
+		audience:  strings.TrimSpace(req.Audience),
+		claims:    maps.Clone(req.Claims),
+		expiresAt: now.Add(ttl),
+	}
+	tokenValue, err := h.mintToken(info, now)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	}
+	expiresAt := info.expiresAt
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cleanupExpiredTokensLocked(now)
+	h.tokens[tokenValue] = info
+	h.log.Info("token_issued", "scope", req.Scope, "subject", subject, "audience", req.Audience, "ttl", ttl.String())
+	return tokenValue, expiresAt, nil
+}
+
+// VerifyToken returns the principal behind token when it is live and was
+// issued for scope. When audience is set the token must have been issued for
+// exactly that audience; a token without one is refused. An empty audience
+// accepts any.
+func (h *Hub) VerifyToken(token string, scope TokenScope, audience string) (Principal, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return Principal{}, ErrTokenInvalid
+	}
+
+	h.mu.Lock()
+	info, ok := h.tokens[token]
+	h.mu.Unlock()
+
+	switch {
+	case !ok || info.scope != scope:
+		return Principal{}, ErrTokenInvalid
+	case info.expiresAt.Before(time.Now()):
+		return Principal{}, ErrTokenExpired
+	case audience != "" && info.audience != audience:
+		return Principal{}, ErrTokenAudience
+	}
+
+	claims := maps.Clone(info.claims)
+	if scope == ScopeController && info.user.ID != "" {
+		if claims == nil {
+			claims = make(map[string]string)
+		}
+		claims["userId"] = info.user.ID
+	}
+	return Principal{
+		Scope:     info.scope,
+		Subject:   info.subject,
+		Audience:  info.audience,
+		Claims:    claims,
+		ExpiresAt: info.expiresAt,
+	}, nil
+}
+
+// ControllerToken describes a live controller token without its value.
+type ControllerToken struct {
+	SlotID    string
+	UserID    string
+	Name      string
+	Cohort    string
+	ExpiresAt time.Time
+	// Connected reports whether a controller currently holds the slot.
+	Connected bool
+}
+
+// ControllerTokens lists the live controller tokens ordered by slot, then
+// expiry.
+func (h *Hub) ControllerTokens() []ControllerToken {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.cleanupExpiredTokensLocked(now)
+	out := make([]ControllerToken, 0, len(h.slotTokens))
+	for _, info := range h.tokens {
+		if info.scope != ScopeController || info.expiresAt.Before(now) {
+			continue
+		}
+		out = append(out, ControllerToken{
+			SlotID:    info.subject,
+			UserID:    info.user.ID,
+			Name:      info.user.Name,
+			Cohort:    info.cohort,
+			ExpiresAt: info.expiresAt,
+			Connected: h.controllers[info.subject] != nil,
+		})
+	}
+	sortControllerTokens(out)
+	return out
+}
+
+func sortControllerTokens(tokens []ControllerToken) {
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].SlotID != tokens[j].SlotID {
+			return tokens[i].SlotID < tokens[j].SlotID
+		}
+		return tokens[i].ExpiresAt.Before(tokens[j].ExpiresAt)
+	})
+}
+
+// RevokeSlotToken withdraws the controller token and any restored binding
+// for slotID so the slot no longer counts as assigned. A connected
+// controller stays connected but can no longer refresh its token. It reports
+// whether anything was revoked.
+func (h *Hub) RevokeSlotToken(slotID string) bool {
+	slotID = strings.ToLower(strings.TrimSpace(slotID))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	token, hadToken := h.slotTokens[slotID]
+	if hadToken {
+		delete(h.tokens, token)
+		delete(h.slotTokens, slotID)
+	}
+	_, hadRestored := h.restored[slotID]
+	delete(h.restored, slotID)
+	if !hadToken && !hadRestored {
+		return false
+	}
+	h.notifyAssignmentsLocked()
+	return true
+}
+
+// RevokeToken withdraws a token of any scope by value. Revoking a controller
+// token also frees its slot's assignment. It reports whether the token was
+// live.
+func (h *Hub) RevokeToken(token string) bool {
+	token = strings.TrimSpace(token)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	info, ok := h.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(h.tokens, token)
+	if info.scope == ScopeController && h.slotTokens[info.subject] == token {
+		delete(h.slotTokens, info.subject)
+		h.notifyAssignmentsLocked()
+	}
+	h.log.Info("token_revoked", "scope", info.scope, "subject", info.subject)
+	return true
+}
+
+// RevokeSubjectTokens withdraws every admin or spectator token issued to
+// subject, for when the token values are no longer at hand. It returns how
+// many were revoked.
+func (h *Hub) RevokeSubjectTokens(scope TokenScope, subject string) int {
+	subject = strings.TrimSpace(subject)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	revoked := 0
+	for token, info := range h.tokens {
+		if info.scope == scope && info.subject == subject && scope != ScopeController {
+			delete(h.tokens, token)
+			revoked++
+		}
+	}
+	if revoked > 0 {
+		h.log.Info("token_revoked", "scope", scope, "subject", subject, "count", revoked)
+	}
+	return revoked
+}
@@ -0,0 +1,98 @@
+package hub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// msgTypeTutorial is the onboarding control frame a controller page sends as
+// the player works through the on-screen tutorial and calibration, e.g.
+// {"type":"tutorial","step":"calibrate"}. It is not relayed as input.
+const msgTypeTutorial = "tutorial"
+
+// TutorialState is how far a controller session has got through onboarding.
+// Every connection starts pending, so a reconnecting page repeats the
+// handshake or skips it.
+type TutorialState string
+
+const (
+	TutorialPending     TutorialState = "pending"
+	TutorialInProgress  TutorialState = "tutorial"
+	TutorialCalibrating TutorialState = "calibrating"
+	TutorialReady       TutorialState = "ready"
+)
+
+// tutorialSteps maps each step to the states it may be sent from and the
+// state it leads to. "skip" lets a returning player go straight to ready;
+// "reset" starts over from any state.
+var tutorialSteps = map[string]struct {
+	from []TutorialState
+	to   TutorialState
+}{
+	"start":     {from: []TutorialState{TutorialPending, TutorialReady}, to: TutorialInProgress},
+	"calibrate": {from: []TutorialState{TutorialInProgress}, to: TutorialCalibrating},
+	"done":      {from: []TutorialState{TutorialInProgress, TutorialCalibrating}, to: TutorialReady},
+	"skip":      {from: []TutorialState{TutorialPending}, to: TutorialReady},
+	"reset":     {from: []TutorialState{TutorialInProgress, TutorialCalibrating, TutorialReady}, to: TutorialPending},
+}
+
+type controllerTutorialEvent struct {
+	Type      string        `json:"type"`
+	ID        string        `json:"id"`
+	State     TutorialState `json:"state"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// recordTutorial advances session's onboarding state. Unknown steps and
+// steps sent out of order are logged and ignored rather than disconnecting
+// the player over a page bug. A change updates the assignments and tells the
+// game listeners with a controller_tutorial frame.
+func (h *Hub) recordTutorial(session *controllerSession, payload []byte) {
+	var msg struct {
+		Step string `json:"step"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		session.logger.Warn("tutorial_invalid", "err", err.Error())
+		return
+	}
+	step, ok := tutorialSteps[msg.Step]
+	if !ok {
+		session.logger.Warn("tutorial_invalid", "step", msg.Step)
+		return
+	}
+
+	h.mu.Lock()
+	from := session.tutorial
+	allowed := false
+	for _, state := range step.from {
+		if state == from {
+			allowed = true
+			break
+		}
+	}
+	if allowed {
+		session.tutorial = step.to
+		h.notifyAssignmentsLocked()
+	}
+	h.mu.Unlock()
+
+	if !allowed {
+		session.logger.Debug("tutorial_step_ignored", "step", msg.Step, "state", string(from))
+		return
+	}
+
+	session.logger.Info("tutorial_changed", "step", msg.Step, "from", string(from), "state", string(step.to))
+	h.emit("tutorial_changed", roleController, session.id, session.remoteIP, "state", string(step.to))
+
+	event, err := json.Marshal(controllerTutorialEvent{
+		Type:      "controller_tutorial",
+		ID:        session.id,
+		State:     step.to,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		session.logger.Error("tutorial_event_encode_failed", "err", err.Error())
+		return
+	}
+	h.enqueueToListeners(event)
+}
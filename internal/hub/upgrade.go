@@ -0,0 +1,84 @@
+package hub
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Handshake failure classes counted by UpgradeStats. nhooyr/websocket does
+// not export typed accept errors, so they are told apart by message.
+const (
+	UpgradeFailOrigin   = "bad_origin"
+	UpgradeFailHeaders  = "bad_upgrade"
+	UpgradeFailProtocol = "unsupported_protocol"
+	UpgradeFailTLS      = "tls"
+	UpgradeFailPending  = "pending_limit"
+	UpgradeFailIPLimit  = "ip_limit"
+	UpgradeFailLockout  = "locked_out"
+	UpgradeFailOther    = "other"
+)
+
+// UpgradeStats reports /ws handshake outcomes. Pending counts connections
+// that are upgraded but have not sent their register message yet.
+type UpgradeStats struct {
+	Accepted    uint64
+	Failed      map[string]uint64
+	Pending     int
+	PendingByIP map[string]int
+}
+
+type upgradeCounters struct {
+	accepted atomic.Uint64
+	mu       sync.Mutex
+	failed   map[string]uint64
+}
+
+func (c *upgradeCounters) fail(class string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed == nil {
+		c.failed = make(map[string]uint64)
+	}
+	c.failed[class]++
+}
+
+// UpgradeStats returns the handshake counters.
+func (h *Hub) UpgradeStats() UpgradeStats {
+	pending, byIP := h.limiter.pendingCounts()
+
+	h.upgrades.mu.Lock()
+	defer h.upgrades.mu.Unlock()
+	failed := maps.Clone(h.upgrades.failed)
+	if failed == nil {
+		failed = make(map[string]uint64)
+	}
+	return UpgradeStats{
+		Accepted:    h.upgrades.accepted.Load(),
+		Failed:      failed,
+		Pending:     pending,
+		PendingByIP: byIP,
+	}
+}
+
+// RecordUpgradeFailure counts a handshake failure detected outside HandleWS,
+// such as a TLS handshake rejected by the HTTP server.
+func (h *Hub) RecordUpgradeFailure(class string) {
+	h.upgrades.fail(class)
+}
+
+// classifyAcceptError maps a websocket.Accept error to a failure class.
+func classifyAcceptError(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Origin"):
+		return UpgradeFailOrigin
+	case strings.Contains(msg, "protocol violation"), strings.Contains(msg, "protocol version"):
+		return UpgradeFailHeaders
+	case strings.Contains(msg, "Hijacker"), strings.Contains(msg, "hijack"):
+		return UpgradeFailProtocol
+	default:
+		return UpgradeFailOther
+	}
+}
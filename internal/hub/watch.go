@@ -0,0 +1,17 @@
+package hub
+
+// AssignmentsChanged returns a channel closed at the next change to the
+// controller assignments (connect, disconnect, token issue, handoff, ...).
+// Callers re-read ControllerAssignments and call it again to keep watching.
+// Token expiry signals the channel only once the expired token is swept,
+// which happens lazily on the next token or assignment call.
+func (h *Hub) AssignmentsChanged() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.changed
+}
+
+func (h *Hub) notifyAssignmentsLocked() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
@@ -0,0 +1,85 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"nhooyr.io/websocket"
+)
+
+// roleWatchdog registers the hub's own synthetic connection. The hub takes
+// its lock, answers {"type":"watchdog","id":<id>,"controllers":N} and closes,
+// which proves the upgrade path and the hub state are both responsive. Only
+// loopback peers may use it.
+const roleWatchdog = "watchdog"
+
+// ErrRelayStalled is returned by HealRelay when the game writer stopped
+// draining its queue and the game session was closed to recover.
+var ErrRelayStalled = errors.New("hub: game relay stalled")
+
+// relayProbe is what the previous HealRelay call saw of the game writer.
+type relayProbe struct {
+	game    *gameSession
+	written uint64
+	pending bool
+}
+
+func (h *Hub) handleWatchdog(ctx context.Context, conn *websocket.Conn, r *http.Request, reg registerPayload) (websocket.StatusCode, string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		h.log.Warn("register_invalid_role", "role", roleWatchdog, "id", reg.ID, "remote_ip", h.remoteAddr(r))
+		return closeWith(ReasonRegisterInvalid)
+	}
+
+	h.mu.Lock()
+	controllers := len(h.controllers)
+	h.mu.Unlock()
+
+	reply, err := json.Marshal(map[string]any{"type": roleWatchdog, "id": reg.ID, "controllers": controllers})
+	if err != nil {
+		return websocket.StatusInternalError, "encode failed"
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, h.cfg.WriteTimeout)
+	defer cancel()
+	if err := conn.Write(writeCtx, websocket.MessageText, reply); err != nil {
+		return websocket.StatusInternalError, "write failed"
+	}
+	return websocket.StatusNormalClosure, ""
+}
+
+// HealRelay checks that the game writer is draining its queue. When frames
+// were pending at the previous call and nothing has been written since, the
+// writer is wedged: the game session is closed so the game reconnects with a
+// fresh one, and ErrRelayStalled is returned. Calls should be spaced well
+// beyond WriteTimeout.
+func (h *Hub) HealRelay() error {
+	h.mu.Lock()
+	game := h.game
+	previous := h.relayProbe
+	h.mu.Unlock()
+
+	if game == nil {
+		h.setRelayProbe(relayProbe{})
+		return nil
+	}
+	written := game.written.Load()
+	pending := game.depth() > 0
+	if pending && previous.pending && previous.game == game && previous.written == written {
+		h.setRelayProbe(relayProbe{})
+		game.logger.Error("relay_stalled", "depth", game.depth(), "written", written)
+		h.emit("relay_stalled", roleGame, "", game.remoteIP)
+		game.close(websocket.StatusTryAgainLater, "relay stalled")
+		return ErrRelayStalled
+	}
+	h.setRelayProbe(relayProbe{game: game, written: written, pending: pending})
+	return nil
+}
+
+func (h *Hub) setRelayProbe(probe relayProbe) {
+	h.mu.Lock()
+	h.relayProbe = probe
+	h.mu.Unlock()
+}
@@ -0,0 +1,82 @@
+// Package inputs flattens relayed controller frames into the numeric input
+// values the output bridges (OSC, MIDI) map onto their own protocols.
+package inputs
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+)
+
+// Fields of a controller frame that describe the frame rather than the
+// input; Flatten leaves them out.
+var skippedFields = map[string]bool{
+	"type":   true,
+	"id":     true,
+	"t":      true,
+	"seq":    true,
+	"hubSeq": true,
+	"epoch":  true,
+}
+
+// Field is one input value of a frame. Path joins the JSON keys and array
+// indexes leading to it with "/", e.g. "axes/x"; booleans are 0 or 1.
+type Field struct {
+	Path  string
+	Value float64
+}
+
+// Flatten returns every numeric and boolean field of payload in path order.
+// A frame in a relay envelope is unwrapped first; anything that is not a
+// JSON object has no fields.
+func Flatten(payload []byte) []Field {
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil
+	}
+	if fields["type"] == "relay" {
+		inner, ok := fields["payload"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		fields = inner
+	}
+	var out []Field
+	for _, key := range sortedKeys(fields) {
+		if skippedFields[key] {
+			continue
+		}
+		out = appendValue(out, key, fields[key])
+	}
+	return out
+}
+
+func appendValue(out []Field, path string, value any) []Field {
+	switch v := value.(type) {
+	case float64:
+		return append(out, Field{Path: path, Value: v})
+	case bool:
+		if v {
+			return append(out, Field{Path: path, Value: 1})
+		}
+		return append(out, Field{Path: path, Value: 0})
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			out = appendValue(out, path+"/"+key, v[key])
+		}
+	case []any:
+		for i, item := range v {
+			out = appendValue(out, path+"/"+strconv.Itoa(i), item)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,22 @@
+package localinput
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// absRange asks the kernel for the minimum and maximum of an absolute axis
+// with EVIOCGABS.
+func absRange(file *os.File, code uint16) (lo, hi int32, ok bool) {
+	// struct input_absinfo: value, minimum, maximum, fuzz, flat, resolution.
+	var info [24]byte
+	// _IOR('E', 0x40 + code, struct input_absinfo)
+	req := uintptr(2)<<30 | uintptr(len(info))<<16 | uintptr('E')<<8 | uintptr(0x40+code)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), req, uintptr(unsafe.Pointer(&info[0])))
+	if errno != 0 {
+		return 0, 0, false
+	}
+	return int32(binary.NativeEndian.Uint32(info[4:8])), int32(binary.NativeEndian.Uint32(info[8:12])), true
+}
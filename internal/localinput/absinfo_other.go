@@ -0,0 +1,10 @@
+//go:build !linux
+
+package localinput
+
+import "os"
+
+// absRange is only available through the Linux evdev ioctls.
+func absRange(*os.File, uint16) (lo, hi int32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,101 @@
+// Package localinput reads a keyboard or gamepad attached to the hub machine
+// through the Linux evdev interface (/dev/input/event*) and turns it into
+// the state frames the WebUI controller sends, so a booth can keep a wired
+// backup controller for when phones fail.
+package localinput
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Event types and codes of linux/input-event-codes.h used by the mapping.
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evAbs = 0x03
+
+	keyEnter = 28
+	keyW     = 17
+	keyA     = 30
+	keyS     = 31
+	keyD     = 32
+	keyZ     = 44
+	keySpace = 57
+	keyUp    = 103
+	keyLeft  = 105
+	keyRight = 106
+	keyDown  = 108
+
+	btnTrigger = 0x120
+	btnSouth   = 0x130
+
+	absX     = 0x00
+	absY     = 0x01
+	absHat0X = 0x10
+	absHat0Y = 0x11
+)
+
+// eventSize is the size of struct input_event: a struct timeval followed by
+// type, code and value, so it follows the word size.
+var eventSize = 8 + 2*strconv.IntSize/8
+
+// inputEvent is one evdev input event.
+type inputEvent struct {
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// device is an open event node.
+type device struct {
+	file *os.File
+	buf  []byte
+	// ranges holds the reported extent of the absolute axes the mapping
+	// reads, for scaling them to -1..1.
+	ranges map[uint16][2]int32
+}
+
+func openDevice(path string) (*device, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open input device: %w", err)
+	}
+	d := &device{file: file, buf: make([]byte, eventSize), ranges: make(map[uint16][2]int32)}
+	for _, code := range []uint16{absX, absY, absHat0X, absHat0Y} {
+		if lo, hi, ok := absRange(file, code); ok && hi > lo {
+			d.ranges[code] = [2]int32{lo, hi}
+		}
+	}
+	return d, nil
+}
+
+// read blocks for the next event.
+func (d *device) read() (inputEvent, error) {
+	if _, err := io.ReadFull(d.file, d.buf); err != nil {
+		return inputEvent{}, err
+	}
+	tail := d.buf[eventSize-8:]
+	return inputEvent{
+		Type:  binary.NativeEndian.Uint16(tail[0:2]),
+		Code:  binary.NativeEndian.Uint16(tail[2:4]),
+		Value: int32(binary.NativeEndian.Uint32(tail[4:8])),
+	}, nil
+}
+
+// axis scales an absolute axis value to -1..1. Axes that did not report
+// their range are taken as -1..1 already, as hats are.
+func (d *device) axis(code uint16, value int32) float64 {
+	r, ok := d.ranges[code]
+	if !ok {
+		return max(-1, min(1, float64(value)))
+	}
+	return max(-1, min(1, 2*float64(value-r[0])/float64(r[1]-r[0])-1))
+}
+
+func (d *device) Close() error {
+	return d.file.Close()
+}
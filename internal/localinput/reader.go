@@ -0,0 +1,308 @@
+package localinput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+const (
+	// defaultResend matches the WebUI controller, which sends its state
+	// again every 2.5s even when nothing changed.
+	defaultResend = 2500 * time.Millisecond
+	// reopenInterval is how often a missing or unplugged device is tried.
+	reopenInterval = 2 * time.Second
+	// deadzone is the stick travel read as centred, as on the WebUI stick.
+	deadzone = 0.22
+	// frameBuffer bounds the frames waiting for the session; beyond it they
+	// are dropped, as the next change or resend supersedes them.
+	frameBuffer = 16
+)
+
+// Config configures a Reader.
+type Config struct {
+	// Device is the event node, e.g. /dev/input/by-id/…-event-joystick.
+	Device string
+	// Slot is the controller slot the device plays as.
+	Slot string
+	// Resend is how often an unchanged state is sent again; 2.5s when zero.
+	Resend time.Duration
+	// OnConnect and OnDisconnect, when set, are told when the device is
+	// opened and when it goes away or cannot be opened. Failures to open
+	// are reported once until the device shows up.
+	OnConnect    func()
+	OnDisconnect func(error)
+}
+
+// Reader maps the keys and buttons of one device to the WebUI controller's
+// stick and button:
+//
+//	stick   arrow keys, WASD, the d-pad or the left stick
+//	button  Space, Enter, Z, the south face button (A/×) or the trigger
+//
+// and emits a state frame whenever they change.
+type Reader struct {
+	cfg       Config
+	connected atomic.Bool
+	frames    atomic.Uint64
+	seq       uint64
+}
+
+// New validates cfg. The device is opened once Run is running, and
+// reopened whenever it is unplugged.
+func New(cfg Config) (*Reader, error) {
+	if cfg.Device == "" {
+		return nil, errors.New("local input device must not be empty")
+	}
+	if cfg.Slot == "" {
+		return nil, errors.New("local input slot must not be empty")
+	}
+	if cfg.Resend <= 0 {
+		cfg.Resend = defaultResend
+	}
+	return &Reader{cfg: cfg}, nil
+}
+
+// Device is the event node read.
+func (r *Reader) Device() string {
+	return r.cfg.Device
+}
+
+// Slot is the slot the device plays as.
+func (r *Reader) Slot() string {
+	return r.cfg.Slot
+}
+
+// Connected reports whether the device is open.
+func (r *Reader) Connected() bool {
+	return r.connected.Load()
+}
+
+// Frames is the number of state frames handed to a session.
+func (r *Reader) Frames() uint64 {
+	return r.frames.Load()
+}
+
+// Run reads the device until ctx is done. Each time the device is opened,
+// play is started with the channel of its state frames. When the device
+// goes away a released state is sent, so a button held at that moment does
+// not stay down, and the channel is closed; Run waits for play to return
+// before opening the device again.
+func (r *Reader) Run(ctx context.Context, play func(frames <-chan []byte)) {
+	reported := false
+	for {
+		dev, err := openDevice(r.cfg.Device)
+		if err == nil {
+			reported = false
+			err = r.session(ctx, dev, play)
+			if ctx.Err() != nil {
+				return
+			}
+			r.notify(err)
+		} else if !reported {
+			reported = true
+			r.notify(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reopenInterval):
+		}
+	}
+}
+
+func (r *Reader) notify(err error) {
+	if r.cfg.OnDisconnect != nil {
+		r.cfg.OnDisconnect(err)
+	}
+}
+
+// session reads one opened device until it fails or ctx is done.
+func (r *Reader) session(ctx context.Context, dev *device, play func(<-chan []byte)) error {
+	defer dev.Close()
+	r.connected.Store(true)
+	defer r.connected.Store(false)
+	if r.cfg.OnConnect != nil {
+		r.cfg.OnConnect()
+	}
+
+	frames := make(chan []byte, frameBuffer)
+	played := make(chan struct{})
+	crash.Go(func() {
+		defer close(played)
+		play(frames)
+	})
+	defer func() {
+		close(frames)
+		<-played
+	}()
+	emit := func(payload []byte) {
+		select {
+		case frames <- payload:
+			r.frames.Add(1)
+		default:
+			// The session is behind or has ended, e.g. a phone took
+			// the slot back.
+		}
+	}
+
+	events := make(chan inputEvent, 64)
+	readErr := make(chan error, 1)
+	crash.Go(func() {
+		for {
+			ev, err := dev.read()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	var st state
+	st.held = make(map[uint16]bool)
+	last := st.frame()
+	r.emit(emit, last)
+	resend := time.NewTicker(r.cfg.Resend)
+	defer resend.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.emit(emit, (&state{}).frame())
+			return nil
+		case err := <-readErr:
+			r.emit(emit, (&state{}).frame())
+			return err
+		case <-resend.C:
+			r.emit(emit, last)
+		case ev := <-events:
+			// The device reports a change as a batch of events closed by
+			// SYN_REPORT; a stick moved diagonally is one change.
+			if ev.Type != evSyn {
+				st.apply(dev, ev)
+				continue
+			}
+			if next := st.frame(); next != last {
+				last = next
+				r.emit(emit, last)
+				resend.Reset(r.cfg.Resend)
+			}
+		}
+	}
+}
+
+func (r *Reader) emit(emit func([]byte), f frame) {
+	r.seq++
+	payload, err := json.Marshal(stateMessage{
+		Type: "state",
+		ID:   r.cfg.Slot,
+		Axes: axes{X: f.x, Y: f.y},
+		Btn:  buttons{A: f.a},
+		T:    time.Now().UnixMilli(),
+		Seq:  r.seq,
+	})
+	if err != nil {
+		return
+	}
+	emit(payload)
+}
+
+// stateMessage is the WebUI controller's state frame.
+type stateMessage struct {
+	Type string  `json:"type"`
+	ID   string  `json:"id"`
+	Axes axes    `json:"axes"`
+	Btn  buttons `json:"btn"`
+	T    int64   `json:"t"`
+	Seq  uint64  `json:"seq"`
+}
+
+type axes struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type buttons struct {
+	A bool `json:"a"`
+}
+
+// frame is the mapped controller state: digital axes, up and right
+// positive, as the WebUI stick reports them.
+type frame struct {
+	x, y float64
+	a    bool
+}
+
+// state is what the device currently holds.
+type state struct {
+	held           map[uint16]bool
+	stickX, stickY float64
+	hatX, hatY     float64
+}
+
+func (s *state) apply(dev *device, ev inputEvent) {
+	switch ev.Type {
+	case evKey:
+		// 1 is a press, 2 an autorepeat and 0 a release.
+		s.held[ev.Code] = ev.Value != 0
+	case evAbs:
+		switch ev.Code {
+		case absX:
+			s.stickX = dev.axis(ev.Code, ev.Value)
+		case absY:
+			s.stickY = dev.axis(ev.Code, ev.Value)
+		case absHat0X:
+			s.hatX = dev.axis(ev.Code, ev.Value)
+		case absHat0Y:
+			s.hatY = dev.axis(ev.Code, ev.Value)
+		}
+	}
+}
+
+// frame combines the sources, keys over the d-pad over the stick. evdev
+// axes grow downwards, so they are flipped.
+func (s *state) frame() frame {
+	keyX := s.direction(keyRight, keyD) - s.direction(keyLeft, keyA)
+	keyY := s.direction(keyUp, keyW) - s.direction(keyDown, keyS)
+	return frame{
+		x: firstNonZero(keyX, quantize(s.hatX), quantize(s.stickX)),
+		y: firstNonZero(keyY, -quantize(s.hatY), -quantize(s.stickY)),
+		a: s.held[keySpace] || s.held[keyEnter] || s.held[keyZ] || s.held[btnSouth] || s.held[btnTrigger],
+	}
+}
+
+func (s *state) direction(codes ...uint16) float64 {
+	for _, code := range codes {
+		if s.held[code] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func quantize(v float64) float64 {
+	switch {
+	case v >= deadzone:
+		return 1
+	case v <= -deadzone:
+		return -1
+	}
+	return 0
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package midi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/bridge"
+	"github.com/aritumn2025/cgb-io-hub/internal/inputs"
+)
+
+const defaultQueueSize = 256
+
+// ErrQueueFull is reported when frames arrive faster than they are written
+// and new ones are dropped.
+var ErrQueueFull = errors.New("midi queue full")
+
+// Config configures a Bridge.
+type Config struct {
+	// Device is the MIDI device node messages are written to.
+	Device string
+	Rules  []Rule
+	// QueueSize is the number of frames buffered for the writer.
+	QueueSize int
+	// OnError, when set, is told about write failures and dropped frames,
+	// at most once per bridge.ErrorInterval.
+	OnError func(error)
+}
+
+type event struct {
+	slotID  string
+	payload []byte
+	// release resets the slot instead of applying payload.
+	release bool
+}
+
+// Bridge applies the rules to the frames of slots p1..p16, each on its own
+// MIDI channel, and writes a message whenever a mapped value changes: the
+// button goes down or up, or the stick moves to another CC step. Only
+// changes are sent, so the controller heartbeat adds no traffic.
+type Bridge struct {
+	*bridge.Counters
+
+	out    io.WriteCloser
+	device string
+	rules  []Rule
+	queue  chan event
+
+	// levels holds the last level sent per slot and rule; Run owns it.
+	levels map[string][]int
+}
+
+// NewBridge opens cfg.Device for writing. Messages are written once Run is
+// running.
+func NewBridge(cfg Config) (*Bridge, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("midi bridge needs at least one rule")
+	}
+	out, err := os.OpenFile(cfg.Device, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open midi device: %w", err)
+	}
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	return &Bridge{
+		Counters: bridge.NewCounters(cfg.OnError),
+		out:      out,
+		device:   cfg.Device,
+		rules:    cfg.Rules,
+		queue:    make(chan event, size),
+		levels:   make(map[string][]int),
+	}, nil
+}
+
+// Device is the device node messages are written to.
+func (b *Bridge) Device() string {
+	return b.device
+}
+
+// Observe queues a relayed controller frame. It never blocks, so it can run
+// on the controller read loop; payload must not be modified afterwards.
+func (b *Bridge) Observe(slotID, _ string, payload []byte) {
+	if _, ok := Channel(slotID); !ok {
+		return
+	}
+	b.enqueue(event{slotID: slotID, payload: payload})
+}
+
+// Release returns the slot's controls to rest, releasing held notes, once
+// its controller is gone.
+func (b *Bridge) Release(slotID string) {
+	if _, ok := Channel(slotID); !ok {
+		return
+	}
+	b.enqueue(event{slotID: slotID, release: true})
+}
+
+func (b *Bridge) enqueue(ev event) {
+	select {
+	case b.queue <- ev:
+	default:
+		b.AddDropped()
+		b.Fail(ErrQueueFull)
+	}
+}
+
+// Run writes queued frames until ctx is done, then releases every slot so
+// no note is left hanging and closes the device.
+func (b *Bridge) Run(ctx context.Context) {
+	defer b.out.Close()
+	var buf []byte
+	for {
+		select {
+		case <-ctx.Done():
+			buf = buf[:0]
+			for slotID := range b.levels {
+				buf = b.appendRelease(buf, slotID)
+			}
+			b.write(buf)
+			return
+		case ev := <-b.queue:
+			if ev.release {
+				buf = b.appendRelease(buf[:0], ev.slotID)
+			} else {
+				buf = b.appendChanges(buf[:0], ev.slotID, inputs.Flatten(ev.payload))
+			}
+			b.write(buf)
+		}
+	}
+}
+
+// appendChanges appends a message for every rule whose level fields moved.
+func (b *Bridge) appendChanges(buf []byte, slotID string, fields []inputs.Field) []byte {
+	channel, _ := Channel(slotID)
+	levels := b.slotLevels(slotID)
+	for _, field := range fields {
+		for i, rule := range b.rules {
+			if rule.Field != field.Path {
+				continue
+			}
+			level := int(rule.level(field.Value))
+			if level == levels[i] || (levels[i] < 0 && rule.Kind == KindNote && level == 0) {
+				continue
+			}
+			levels[i] = level
+			buf = append(buf, rule.message(channel, byte(level))...)
+		}
+	}
+	return buf
+}
+
+// appendRelease appends the messages taking the slot back to rest, a value
+// of 0 on every rule, and forgets its levels.
+func (b *Bridge) appendRelease(buf []byte, slotID string) []byte {
+	levels, ok := b.levels[slotID]
+	if !ok {
+		return buf
+	}
+	channel, _ := Channel(slotID)
+	for i, rule := range b.rules {
+		if levels[i] < 0 {
+			continue
+		}
+		if rest := int(rule.level(0)); rest != levels[i] {
+			buf = append(buf, rule.message(channel, byte(rest))...)
+		}
+	}
+	delete(b.levels, slotID)
+	return buf
+}
+
+// slotLevels returns the levels of the slot, -1 for rules never sent.
+func (b *Bridge) slotLevels(slotID string) []int {
+	levels, ok := b.levels[slotID]
+	if !ok {
+		levels = make([]int, len(b.rules))
+		for i := range levels {
+			levels[i] = -1
+		}
+		b.levels[slotID] = levels
+	}
+	return levels
+}
+
+func (b *Bridge) write(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+	if _, err := b.out.Write(buf); err != nil {
+		b.Fail(fmt.Errorf("write to %s: %w", b.device, err))
+		return
+	}
+	// Every message is three bytes: status and two data bytes.
+	b.AddSent(uint64(len(buf) / 3))
+}
@@ -0,0 +1,130 @@
+// Package midi turns mapped controller inputs into MIDI for installations
+// driven by MIDI-capable software instead of a game client. Messages are
+// written as raw MIDI bytes to a device node, typically a virtual port such
+// as the ALSA snd-virmidi /dev/snd/midiC1D0, which the software then
+// subscribes to like any other MIDI input.
+package midi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultMap fits the WebUI controller: the stick on CC 1 and 2 and the
+// button on middle C.
+const DefaultMap = "axes/x=cc:1,axes/y=cc:2,btn/a=note:60"
+
+// Rule kinds.
+const (
+	KindNote = "note"
+	KindCC   = "cc"
+)
+
+const (
+	statusNoteOff = 0x80
+	statusNoteOn  = 0x90
+	statusCC      = 0xb0
+	noteVelocity  = 127
+	// noteThreshold is the value above which a field holds its note.
+	noteThreshold = 0.5
+)
+
+// Rule maps one input field to a note or a control change.
+type Rule struct {
+	// Field is the input path, e.g. "axes/x"; see inputs.Field.
+	Field string
+	Kind  string
+	// Number is the note or controller number, 0-127.
+	Number int
+	// Min and Max are the field values sent as CC 0 and 127.
+	Min, Max float64
+}
+
+// ParseMap parses comma separated rules of the form
+//
+//	<field>=note:<number>
+//	<field>=cc:<number>[:<min>:<max>]
+//
+// Fields may use "." or "/" between keys. A note is on while the field is
+// above 0.5; a CC scales the field from min..max (default -1..1, a stick
+// axis) onto 0..127.
+func ParseMap(raw string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, target, ok := strings.Cut(entry, "=")
+		field = strings.Trim(strings.ReplaceAll(strings.TrimSpace(field), ".", "/"), "/")
+		if !ok || field == "" {
+			return nil, fmt.Errorf("midi map entry %q: want <field>=note:<n> or <field>=cc:<n>", entry)
+		}
+		parts := strings.Split(strings.TrimSpace(target), ":")
+		rule := Rule{Field: field, Kind: strings.ToLower(parts[0]), Min: -1, Max: 1}
+		switch {
+		case rule.Kind == KindNote && len(parts) == 2:
+		case rule.Kind == KindCC && (len(parts) == 2 || len(parts) == 4):
+			if len(parts) == 4 {
+				var errMin, errMax error
+				rule.Min, errMin = strconv.ParseFloat(parts[2], 64)
+				rule.Max, errMax = strconv.ParseFloat(parts[3], 64)
+				if errMin != nil || errMax != nil || rule.Min == rule.Max {
+					return nil, fmt.Errorf("midi map entry %q: invalid range", entry)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("midi map entry %q: want note:<n> or cc:<n>[:<min>:<max>]", entry)
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 0 || n > 127 {
+			return nil, fmt.Errorf("midi map entry %q: number must be 0-127", entry)
+		}
+		rule.Number = n
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("midi map %q has no rules", raw)
+	}
+	return rules, nil
+}
+
+// level is the 0-127 data byte v produces under r: the velocity of a held
+// note (0 when released) or the controller value.
+func (r Rule) level(v float64) byte {
+	if r.Kind == KindNote {
+		if v > noteThreshold {
+			return noteVelocity
+		}
+		return 0
+	}
+	scaled := (v - r.Min) / (r.Max - r.Min)
+	return byte(math.Round(math.Max(0, math.Min(1, scaled)) * 127))
+}
+
+// message encodes the change of r to level on the zero-based channel.
+func (r Rule) message(channel int, level byte) []byte {
+	if r.Kind == KindCC {
+		return []byte{statusCC | byte(channel), byte(r.Number), level}
+	}
+	if level == 0 {
+		return []byte{statusNoteOff | byte(channel), byte(r.Number), 0}
+	}
+	return []byte{statusNoteOn | byte(channel), byte(r.Number), level}
+}
+
+// Channel is the zero-based MIDI channel of a slot: p1 plays on channel 1
+// (0 on the wire) up to p16. Other slots have none.
+func Channel(slotID string) (int, bool) {
+	digits, ok := strings.CutPrefix(slotID, "p")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 1 || n > 16 || strconv.Itoa(n) != digits {
+		return 0, false
+	}
+	return n - 1, true
+}
@@ -0,0 +1,395 @@
+// Package mqtt is a minimal MQTT 3.1.1 publisher: it keeps one connection
+// to a broker, reconnecting with backoff, and publishes queued messages at
+// QoS 0, 1 or 2. Subscribing is not supported; the hub only reports to the
+// venue's IoT dashboards.
+//
+// The first connection of a run starts a clean session; reconnects resume
+// it, so a QoS 1 or 2 message cut off by a lost connection is completed
+// under its original packet id and a QoS 2 message is still delivered
+// exactly once. Sessions do not outlive the process: a restart starts clean.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/bridge"
+	"github.com/aritumn2025/cgb-io-hub/internal/crash"
+)
+
+const (
+	defaultKeepAlive = 30 * time.Second
+	defaultQueueSize = 256
+	dialTimeout      = 10 * time.Second
+	writeTimeout     = 5 * time.Second
+	ackTimeout       = 10 * time.Second
+	backoffBase      = time.Second
+	backoffMax       = 30 * time.Second
+)
+
+// ErrQueueFull is reported when messages are published faster than the
+// broker takes them, or while it is unreachable, and new ones are dropped.
+var ErrQueueFull = errors.New("mqtt queue full")
+
+// Message is one publication.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// QoS is 0 (at most once), 1 (at least once) or 2 (exactly once while
+	// the hub runs; see the package comment).
+	QoS    byte
+	Retain bool
+}
+
+// Config configures a Client.
+type Config struct {
+	// URL is the broker, mqtt://[user:password@]host[:1883] or
+	// mqtts://…[:8883] for TLS.
+	URL      string
+	ClientID string
+	// KeepAlive is the ping interval; 30s when zero.
+	KeepAlive time.Duration
+	// Will is published by the broker when the hub disappears without
+	// disconnecting, and Birth by the client after every connect, e.g. a
+	// retained "offline" and "online" on a status topic.
+	Will  *Message
+	Birth *Message
+	// QueueSize is the number of messages buffered while the broker is
+	// slow or away.
+	QueueSize int
+	// OnConnect and OnError, when set, are told about connections and,
+	// at most once per bridge.ErrorInterval, about failures and dropped
+	// messages.
+	OnConnect func()
+	OnError   func(error)
+}
+
+// Client publishes to one broker. Publish is safe for concurrent use; Run
+// owns the connection.
+type Client struct {
+	*bridge.Counters
+
+	cfg      Config
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	queue    chan Message
+
+	// pending is the delivery a lost connection interrupted; it is
+	// completed after reconnecting.
+	pending *delivery
+	nextID  uint16
+	// resume is set once a connection was accepted; later connections
+	// resume its session instead of starting a clean one.
+	resume bool
+
+	connected atomic.Bool
+}
+
+// New validates cfg. The broker is dialled once Run is running.
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("mqtt url: %w", err)
+	}
+	c := &Client{Counters: bridge.NewCounters(cfg.OnError), cfg: cfg}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		port = "8883"
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("mqtt url %q: scheme must be mqtt or mqtts", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt url %q: missing host", u.Redacted())
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	c.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if strings.TrimSpace(cfg.ClientID) == "" {
+		return nil, errors.New("mqtt client id must not be empty")
+	}
+	if c.cfg.KeepAlive <= 0 {
+		c.cfg.KeepAlive = defaultKeepAlive
+	}
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	c.queue = make(chan Message, size)
+	return c, nil
+}
+
+// Broker is the host:port the client connects to.
+func (c *Client) Broker() string {
+	return c.addr
+}
+
+// Connected reports whether the broker accepted the current connection.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// Publish queues m without blocking and reports whether it was queued.
+func (c *Client) Publish(m Message) bool {
+	if m.QoS > 2 {
+		m.QoS = 2
+	}
+	if len(m.Topic)+len(m.Payload)+4 > maxRemaining {
+		c.AddDropped()
+		return false
+	}
+	select {
+	case c.queue <- m:
+		return true
+	default:
+		c.AddDropped()
+		c.Fail(ErrQueueFull)
+		return false
+	}
+}
+
+// Run connects and publishes until ctx is done, reconnecting with
+// exponential backoff whenever the connection is lost.
+func (c *Client) Run(ctx context.Context) {
+	backoff := backoffBase
+	for ctx.Err() == nil {
+		connected, err := c.session(ctx)
+		c.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = backoffBase
+		}
+		c.Fail(fmt.Errorf("broker %s: %w", c.addr, err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, backoffMax)
+	}
+}
+
+// session runs one connection. connected reports whether the broker
+// accepted it, which resets the reconnect backoff.
+func (c *Client) session(ctx context.Context) (connected bool, err error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if c.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	keepAlive := uint16(min(c.cfg.KeepAlive/time.Second, 65535))
+	if err := c.write(conn, connectPacket(c.cfg.ClientID, c.username, c.password, keepAlive, !c.resume, c.cfg.Will)); err != nil {
+		return false, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	ack, err := readPacket(r)
+	if err != nil {
+		return false, fmt.Errorf("read connack: %w", err)
+	}
+	if ack.kind() != packetConnack || len(ack.body) < 2 {
+		return false, fmt.Errorf("%w: expected connack", errMalformed)
+	}
+	if code := ack.body[1]; code != 0 {
+		reason := connackReasons[code]
+		if reason == "" {
+			reason = fmt.Sprintf("code %d", code)
+		}
+		return false, fmt.Errorf("connection refused: %s", reason)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	c.resume = true
+	c.connected.Store(true)
+	if c.cfg.OnConnect != nil {
+		c.cfg.OnConnect()
+	}
+
+	// The reader hands acknowledgements to the delivery below; any read,
+	// including a PINGRESP, proves the connection alive.
+	acks := make(chan packet, 16)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	var lastRead atomic.Int64
+	lastRead.Store(time.Now().UnixNano())
+	crash.Go(func() {
+		for {
+			p, err := readPacket(r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			lastRead.Store(time.Now().UnixNano())
+			switch p.kind() {
+			case packetPuback, packetPubrec, packetPubcomp:
+				select {
+				case acks <- p:
+				case <-done:
+					return
+				}
+			}
+		}
+	})
+
+	s := &session{c: c, conn: conn, acks: acks, readErr: readErr}
+	if c.cfg.Birth != nil {
+		if err := s.deliver(ctx, c.newDelivery(*c.cfg.Birth)); err != nil {
+			return true, err
+		}
+	}
+	ping := time.NewTicker(c.cfg.KeepAlive)
+	defer ping.Stop()
+	for {
+		if c.pending != nil {
+			if err := s.deliver(ctx, c.pending); err != nil {
+				return true, err
+			}
+			c.pending = nil
+		}
+		select {
+		case <-ctx.Done():
+			// A clean DISCONNECT discards the will, so say it ourselves.
+			if will := c.cfg.Will; will != nil {
+				_ = c.write(conn, publishPacket(Message{Topic: will.Topic, Payload: will.Payload, Retain: will.Retain}, 0, false))
+			}
+			_ = c.write(conn, encode(packetDisconnect, nil))
+			return true, nil
+		case err := <-readErr:
+			return true, err
+		case <-ping.C:
+			if time.Since(time.Unix(0, lastRead.Load())) > c.cfg.KeepAlive*3/2 {
+				return true, errors.New("broker stopped answering pings")
+			}
+			if err := c.write(conn, encode(packetPingreq, nil)); err != nil {
+				return true, err
+			}
+		case m := <-c.queue:
+			d := c.newDelivery(m)
+			if err := s.deliver(ctx, d); err != nil {
+				if m.QoS > 0 {
+					c.pending = d
+				}
+				return true, err
+			}
+		}
+	}
+}
+
+type session struct {
+	c       *Client
+	conn    net.Conn
+	acks    <-chan packet
+	readErr <-chan error
+}
+
+// delivery is one message on its way to the broker. It keeps its packet id
+// across reconnects, as the resumed session knows the message by it.
+type delivery struct {
+	msg Message
+	id  uint16
+	// dup is set once the PUBLISH was written, so a resend is flagged.
+	dup bool
+	// released is set once the broker sent PUBREC for a QoS 2 message;
+	// from then on only PUBREL is resent, never the message.
+	released bool
+}
+
+func (c *Client) newDelivery(m Message) *delivery {
+	d := &delivery{msg: m}
+	if m.QoS > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		d.id = c.nextID
+	}
+	return d
+}
+
+// deliver publishes d and, above QoS 0, waits for the broker to take it:
+// PUBACK, or PUBREC answered with PUBREL then PUBCOMP.
+func (s *session) deliver(ctx context.Context, d *delivery) error {
+	if !d.released {
+		if err := s.c.write(s.conn, publishPacket(d.msg, d.id, d.dup)); err != nil {
+			return err
+		}
+		d.dup = true
+		switch d.msg.QoS {
+		case 1:
+			if err := s.await(ctx, packetPuback, d.id); err != nil {
+				return err
+			}
+		case 2:
+			if err := s.await(ctx, packetPubrec, d.id); err != nil {
+				return err
+			}
+			d.released = true
+		}
+	}
+	if d.msg.QoS == 2 {
+		if err := s.c.write(s.conn, ackPacket(packetPubrel, d.id)); err != nil {
+			return err
+		}
+		if err := s.await(ctx, packetPubcomp, d.id); err != nil {
+			return err
+		}
+	}
+	s.c.AddSent(1)
+	return nil
+}
+
+func (s *session) await(ctx context.Context, kind byte, want uint16) error {
+	timeout := time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-s.readErr:
+			return err
+		case <-timeout.C:
+			return fmt.Errorf("no acknowledgement for packet %d", want)
+		case p := <-s.acks:
+			id, err := p.packetID()
+			if err != nil {
+				return err
+			}
+			if p.kind() == kind && id == want {
+				return nil
+			}
+			// A late acknowledgement of an earlier, resent packet.
+		}
+	}
+}
+
+func (c *Client) write(conn net.Conn, b []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := conn.Write(b)
+	return err
+}
@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Control packet types of MQTT 3.1.1, shifted into the high nibble.
+const (
+	packetConnect    = 1 << 4
+	packetConnack    = 2 << 4
+	packetPublish    = 3 << 4
+	packetPuback     = 4 << 4
+	packetPubrec     = 5 << 4
+	packetPubrel     = 6 << 4
+	packetPubcomp    = 7 << 4
+	packetPingreq    = 12 << 4
+	packetPingresp   = 13 << 4
+	packetDisconnect = 14 << 4
+)
+
+const (
+	protocolLevel = 4 // MQTT 3.1.1
+	// maxRemaining is the largest remaining length the four byte varint
+	// can carry.
+	maxRemaining = 268435455
+)
+
+// Connect flags.
+const (
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+)
+
+// connackReasons are the CONNACK return codes a broker refuses with.
+var connackReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+var errMalformed = errors.New("malformed packet")
+
+type packet struct {
+	header byte
+	body   []byte
+}
+
+func (p packet) kind() byte {
+	return p.header & 0xf0
+}
+
+// encode frames body under header with the remaining length varint.
+func encode(header byte, body []byte) []byte {
+	out := make([]byte, 0, len(body)+5)
+	out = append(out, header)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+func appendBytes(dst, b []byte) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(b)))
+	return append(dst, b...)
+}
+
+// connectPacket is CONNECT, for a clean session or resuming the last one.
+func connectPacket(clientID, username, password string, keepAlive uint16, clean bool, will *Message) []byte {
+	var flags byte
+	if clean {
+		flags |= flagCleanSession
+	}
+	if will != nil {
+		flags |= flagWill | will.QoS<<3
+		if will.Retain {
+			flags |= flagWillRetain
+		}
+	}
+	if username != "" {
+		flags |= flagUsername
+		if password != "" {
+			flags |= flagPassword
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if will != nil {
+		body = appendString(body, will.Topic)
+		body = appendBytes(body, will.Payload)
+	}
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return encode(packetConnect, body)
+}
+
+func publishPacket(m Message, id uint16, dup bool) []byte {
+	header := byte(packetPublish) | m.QoS<<1
+	if dup {
+		header |= 0x08
+	}
+	if m.Retain {
+		header |= 0x01
+	}
+	body := appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, m.Payload...)
+	return encode(header, body)
+}
+
+// ackPacket is PUBACK, PUBREC, PUBREL or PUBCOMP for packet id.
+func ackPacket(kind byte, id uint16) []byte {
+	header := kind
+	if kind == packetPubrel {
+		// PUBREL has reserved flags 0010.
+		header |= 0x02
+	}
+	return encode(header, binary.BigEndian.AppendUint16(nil, id))
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header: header, body: body}, nil
+}
+
+// packetID reads the id of an acknowledgement packet.
+func (p packet) packetID() (uint16, error) {
+	if len(p.body) < 2 {
+		return 0, fmt.Errorf("%w: type %d without packet id", errMalformed, p.header>>4)
+	}
+	return binary.BigEndian.Uint16(p.body), nil
+}
@@ -0,0 +1,336 @@
+// Package openapi builds an OpenAPI 3.0 document from the Go types the HTTP
+// handlers decode and encode, so the published contract follows the code
+// instead of a hand-maintained copy.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Version is the OpenAPI version the documents declare.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path by lower-case method.
+type PathItem map[string]*Operation
+
+// Operation is one method on one path.
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a query, path or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is a JSON request body.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one status of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType carries the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes a credential.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is the subset of JSON Schema OpenAPI 3.0 uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Builder collects operations and the component schemas they reference.
+// It is not safe for concurrent use.
+type Builder struct {
+	doc   Document
+	names map[reflect.Type]string
+}
+
+// New starts a document described by info.
+func New(info Info) *Builder {
+	return &Builder{
+		doc: Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   make(map[string]*PathItem),
+			Components: Components{
+				Schemas:         make(map[string]*Schema),
+				SecuritySchemes: make(map[string]*SecurityScheme),
+			},
+		},
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Tag declares an operation group.
+func (b *Builder) Tag(name, description string) {
+	b.doc.Tags = append(b.doc.Tags, Tag{Name: name, Description: description})
+}
+
+// SecurityScheme declares a credential operations may require by name.
+func (b *Builder) SecurityScheme(name string, scheme *SecurityScheme) {
+	b.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// Schema returns the schema of v's type. Named struct types are added to
+// the components once and referenced from then on.
+func (b *Builder) Schema(v any) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+// Body is a JSON request or response content of v's type.
+func (b *Builder) Body(v any) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: b.Schema(v)}}
+}
+
+// Add registers op as method on path. It panics when the operation is
+// declared twice, which is a bug in the caller's table.
+func (b *Builder) Add(method, path string, op Operation) {
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+	key := strings.ToLower(method)
+	if _, ok := (*item)[key]; ok {
+		panic(fmt.Sprintf("openapi: %s %s declared twice", method, path))
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]*Response{}
+	}
+	(*item)[key] = &op
+}
+
+// Document returns the document built so far.
+func (b *Builder) Document() *Document {
+	return &b.doc
+}
+
+// JSONResponse is a response with a JSON body of v's type.
+func (b *Builder) JSONResponse(description string, v any) *Response {
+	return &Response{Description: description, Content: b.Body(v)}
+}
+
+// StatusText is the default description of status.
+func StatusText(status int) string {
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return strconv.Itoa(status)
+}
+
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := b.inlineSchema(t)
+	if nullable {
+		if s.Ref != "" {
+			// $ref siblings are ignored in 3.0; wrapping the reference is
+			// the usual way to allow null.
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+	}
+	return s
+}
+
+func (b *Builder) inlineSchema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	default:
+		// Interfaces and anything else accept any JSON value.
+		return &Schema{}
+	}
+}
+
+// component registers the named struct t and returns its component name.
+func (b *Builder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := exportName(t.Name())
+	if _, taken := b.doc.Components.Schemas[name]; taken {
+		name = exportName(pathBase(t.PkgPath())) + name
+	}
+	b.names[t] = name
+	// Reserve the name first so recursive types terminate.
+	b.doc.Components.Schemas[name] = &Schema{}
+	*b.doc.Components.Schemas[name] = *b.structSchema(t)
+	return name
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+// addFields mirrors encoding/json: embedded structs without a tag are
+// flattened and omitempty fields are optional.
+func (b *Builder) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(s, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := b.schemaOf(field.Type)
+		if strings.Contains(","+opts+",", ",string,") {
+			prop = &Schema{Type: "string"}
+		}
+		s.Properties[name] = prop
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func exportName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func pathBase(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
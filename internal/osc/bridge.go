@@ -0,0 +1,151 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/bridge"
+	"github.com/aritumn2025/cgb-io-hub/internal/inputs"
+)
+
+// DefaultPrefix is the address prefix when Config.Prefix is empty.
+const DefaultPrefix = "/cgb"
+
+const defaultQueueSize = 256
+
+// ErrQueueFull is reported when frames arrive faster than they are sent
+// and new ones are dropped.
+var ErrQueueFull = errors.New("osc queue full")
+
+// Config configures a Bridge.
+type Config struct {
+	// Target is the host:port of the OSC receiver.
+	Target string
+	// Prefix starts every address; DefaultPrefix when empty.
+	Prefix string
+	// Types limits the mirrored frames to these message types; all types
+	// when empty.
+	Types []string
+	// QueueSize is the number of frames buffered for the sender.
+	QueueSize int
+	// OnError, when set, is told about send failures and dropped frames,
+	// at most once per bridge.ErrorInterval.
+	OnError func(error)
+}
+
+type frame struct {
+	slotID  string
+	payload []byte
+}
+
+// Bridge sends every input field of a controller frame (see inputs.Flatten)
+// as an OSC message <prefix>/<slot>/<field path> with one float32 argument;
+// booleans are 0 or 1. For the WebUI controller's
+// {"type":"state","axes":{"x":0.5,"y":0},"btn":{"a":true}} from p1 that is
+// /cgb/p1/axes/x 0.5, /cgb/p1/axes/y 0 and /cgb/p1/btn/a 1.
+type Bridge struct {
+	*bridge.Counters
+
+	conn   net.Conn
+	prefix string
+	types  []string
+	queue  chan frame
+}
+
+// NewBridge resolves cfg.Target and opens the UDP socket. Frames are sent
+// once Run is running.
+func NewBridge(cfg Config) (*Bridge, error) {
+	target := strings.TrimSpace(cfg.Target)
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return nil, fmt.Errorf("osc target %q: %w", target, err)
+	}
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("osc target %q: %w", target, err)
+	}
+	prefix := strings.TrimRight(strings.TrimSpace(cfg.Prefix), "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	return &Bridge{
+		Counters: bridge.NewCounters(cfg.OnError),
+		conn:     conn,
+		prefix:   prefix,
+		types:    cfg.Types,
+		queue:    make(chan frame, size),
+	}, nil
+}
+
+// Target is the address messages are sent to.
+func (b *Bridge) Target() string {
+	return b.conn.RemoteAddr().String()
+}
+
+// Observe queues a relayed controller frame. It never blocks, so it can run
+// on the controller read loop; payload must not be modified afterwards.
+func (b *Bridge) Observe(slotID, msgType string, payload []byte) {
+	if len(b.types) > 0 && !slices.Contains(b.types, msgType) {
+		return
+	}
+	select {
+	case b.queue <- frame{slotID: slotID, payload: payload}:
+	default:
+		b.AddDropped()
+		b.Fail(ErrQueueFull)
+	}
+}
+
+// Run sends queued frames until ctx is done, then closes the socket.
+func (b *Bridge) Run(ctx context.Context) {
+	defer b.conn.Close()
+	var buf []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f := <-b.queue:
+			for _, msg := range b.messages(f) {
+				buf = AppendMessage(buf[:0], msg.address, msg.value)
+				if _, err := b.conn.Write(buf); err != nil {
+					b.Fail(fmt.Errorf("send to %s: %w", b.Target(), err))
+					continue
+				}
+				b.AddSent(1)
+			}
+		}
+	}
+}
+
+type message struct {
+	address string
+	value   float32
+}
+
+// messages maps f to one message per input field.
+func (b *Bridge) messages(f frame) []message {
+	fields := inputs.Flatten(f.payload)
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make([]message, 0, len(fields))
+	base := b.prefix + "/" + addressPart(f.slotID)
+	for _, field := range fields {
+		parts := strings.Split(field.Path, "/")
+		for i, part := range parts {
+			parts[i] = addressPart(part)
+		}
+		out = append(out, message{address: base + "/" + strings.Join(parts, "/"), value: float32(field.Value)})
+	}
+	return out
+}
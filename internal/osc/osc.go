@@ -0,0 +1,53 @@
+// Package osc mirrors controller input to an Open Sound Control receiver,
+// such as the lighting desk or audio rig at the booth, over UDP. Only the
+// subset of OSC 1.0 the bridge sends is implemented: messages with float32
+// arguments.
+package osc
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// AppendMessage appends the OSC message address with float32 arguments
+// to dst.
+func AppendMessage(dst []byte, address string, args ...float32) []byte {
+	dst = appendString(dst, address)
+	tags := make([]byte, 0, len(args)+1)
+	tags = append(tags, ',')
+	for range args {
+		tags = append(tags, 'f')
+	}
+	dst = appendString(dst, string(tags))
+	for _, v := range args {
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
+
+// appendString writes s NUL-terminated and padded to a multiple of four
+// bytes, as OSC strings are.
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, s...)
+	pad := 4 - len(s)%4
+	for range pad {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// addressPart makes s usable as one part of an OSC address by replacing
+// the characters OSC reserves for pattern matching, and the separator.
+func addressPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '#', '*', ',', '/', '?', '[', ']', '{', '}':
+			return '_'
+		}
+		if r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -16,6 +16,10 @@ import (
 
 const maxResponseBody = 1 << 20 // 1 MiB
 
+// ExtendedResultVersion is the first PersonaGo API version whose result
+// schema accepts per-slot metadata.
+const ExtendedResultVersion = 2
+
 // Config collects parameters used to initialise the PersonaGo API client.
 type Config struct {
 	BaseURL    string
@@ -24,6 +28,11 @@ type Config struct {
 	Staff      string
 	Timeout    time.Duration
 	HTTPClient *http.Client
+	// APIVersion selects the PersonaGo result schema; zero means 1.
+	APIVersion int
+	// OnFailure, when set, is called for every request that got no answer
+	// (status 0) or a 5xx one, with the method and path as the operation.
+	OnFailure func(operation string, status int)
 }
 
 // Client wraps PersonaGo backend HTTP calls needed by the hub.
@@ -32,7 +41,9 @@ type Client struct {
 	gameName   string
 	attraction string
 	staff      string
+	apiVersion int
 	httpClient *http.Client
+	onFailure  func(operation string, status int)
 }
 
 // Lobby represents the current lobby occupants for a Persona game.
@@ -56,6 +67,10 @@ type GameResult struct {
 	UserID string
 	Name   string
 	Score  int
+	// Metadata carries optional per-slot details such as rank, clears or
+	// accuracy. It is only sent when the client targets
+	// ExtendedResultVersion or later.
+	Metadata map[string]any
 }
 
 // GameResultResponse describes the Persona API reply after submitting results.
@@ -123,15 +138,50 @@ func New(cfg Config) (*Client, error) {
 		httpClient.Timeout = timeout
 	}
 
+	apiVersion := cfg.APIVersion
+	if apiVersion <= 0 {
+		apiVersion = 1
+	}
+
 	return &Client{
 		baseURL:    strings.TrimRight(base, "/"),
 		gameName:   gameName,
 		attraction: attraction,
 		staff:      staff,
+		apiVersion: apiVersion,
 		httpClient: httpClient,
+		onFailure:  cfg.OnFailure,
 	}, nil
 }
 
+// RequestIDHeader carries the id of the hub request that caused a PersonaGo
+// call, so both services' logs can be matched up.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose PersonaGo calls send id in
+// RequestIDHeader.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if id, ok := req.Context().Value(requestIDKey{}).(string); ok && id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	resp, err := c.httpClient.Do(req)
+	if c.onFailure != nil {
+		switch {
+		case err != nil:
+			c.onFailure(req.Method+" "+req.URL.Path, 0)
+		case resp.StatusCode >= 500:
+			c.onFailure(req.Method+" "+req.URL.Path, resp.StatusCode)
+		}
+	}
+	return resp, err
+}
+
 // FetchLobby retrieves the current lobby state from PersonaGo.
 func (c *Client) FetchLobby(ctx context.Context) (*Lobby, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.buildURL("api", "games", "lobby", c.gameName), nil)
@@ -139,7 +189,7 @@ func (c *Client) FetchLobby(ctx context.Context) (*Lobby, error) {
 		return nil, fmt.Errorf("persona: create lobby request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("persona: lobby request: %w", err)
 	}
@@ -211,7 +261,7 @@ func (c *Client) RecordVisit(ctx context.Context, userID string) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("persona: visit request: %w", err)
 	}
@@ -244,7 +294,7 @@ func (c *Client) ClearLobby(ctx context.Context) (*Lobby, error) {
 		return nil, fmt.Errorf("persona: create lobby delete request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("persona: lobby delete request: %w", err)
 	}
@@ -317,7 +367,7 @@ func (c *Client) UpdateLobby(ctx context.Context, slots map[int]string) (*Lobby,
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("persona: lobby update request: %w", err)
 	}
@@ -350,8 +400,17 @@ func (c *Client) UpdateLobby(ctx context.Context, slots map[int]string) (*Lobby,
 	return decoded.toLobby(), nil
 }
 
+// SupportsResultMetadata reports whether SubmitGameResult forwards
+// GameResult.Metadata.
+func (c *Client) SupportsResultMetadata() bool {
+	return c.apiVersion >= ExtendedResultVersion
+}
+
 // SubmitGameResult uploads the scores for a completed match to the Persona API.
-func (c *Client) SubmitGameResult(ctx context.Context, startTime time.Time, results []GameResult) (*GameResultResponse, error) {
+// idempotencyKey, when set, is sent as the Idempotency-Key header and must
+// stay the same across retries of one result, so PersonaGo can recognise a
+// retry of a submission it did record even though the answer was lost.
+func (c *Client) SubmitGameResult(ctx context.Context, startTime time.Time, results []GameResult, idempotencyKey string) (*GameResultResponse, error) {
 	if len(results) == 0 {
 		return nil, errors.New("persona: at least one game result required")
 	}
@@ -381,11 +440,15 @@ func (c *Client) SubmitGameResult(ctx context.Context, startTime time.Time, resu
 			return nil, fmt.Errorf("persona: duplicate slot %d", res.Slot)
 		}
 		seenSlots[res.Slot] = struct{}{}
-		payload.Results[strconv.Itoa(res.Slot)] = &gameResultSlot{
+		slot := &gameResultSlot{
 			UserID: res.UserID,
 			Name:   res.Name,
 			Score:  res.Score,
 		}
+		if c.SupportsResultMetadata() && len(res.Metadata) > 0 {
+			slot.Metadata = res.Metadata
+		}
+		payload.Results[strconv.Itoa(res.Slot)] = slot
 	}
 
 	body, err := json.Marshal(payload)
@@ -403,8 +466,11 @@ func (c *Client) SubmitGameResult(ctx context.Context, startTime time.Time, resu
 		return nil, fmt.Errorf("persona: create game result request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("persona: game result request: %w", err)
 	}
@@ -501,9 +567,10 @@ type gameResultRequest struct {
 }
 
 type gameResultSlot struct {
-	UserID string `json:"id"`
-	Name   string `json:"name"`
-	Score  int    `json:"score"`
+	UserID   string         `json:"id"`
+	Name     string         `json:"name"`
+	Score    int            `json:"score"`
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 type gameResultResponse struct {
@@ -0,0 +1,197 @@
+package qrcode
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// goldenCase is one symbol from testdata/golden.json, produced by an
+// independent encoder (see testdata/generate.js). Each row is the modules
+// left to right as hex, most significant bit first.
+type goldenCase struct {
+	Name    string   `json:"name"`
+	Data    string   `json:"data"`
+	Level   string   `json:"level"`
+	Mask    int      `json:"mask"`
+	Version int      `json:"version"`
+	Rows    []string `json:"rows"`
+}
+
+var levelNames = map[string]Level{"L": LevelL, "M": LevelM, "Q": LevelQ, "H": LevelH}
+
+func loadGolden(t *testing.T) []goldenCase {
+	t.Helper()
+	data, err := os.ReadFile("testdata/golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []goldenCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+// render draws a symbol as text, one line per row, for failure output.
+func render(size int, dark func(x, y int) bool) string {
+	var b strings.Builder
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if dark(x, y) {
+				b.WriteString("##")
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range loadGolden(t) {
+		t.Run(tc.Name, func(t *testing.T) {
+			level, ok := levelNames[tc.Level]
+			if !ok {
+				t.Fatalf("unknown level %q", tc.Level)
+			}
+			c, err := encode([]byte(tc.Data), level, tc.Mask)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version != tc.Version {
+				t.Fatalf("version = %d, want %d", c.Version, tc.Version)
+			}
+			want := func(x, y int) bool {
+				nibble, _ := strconv.ParseUint(tc.Rows[y][x/4:x/4+1], 16, 8)
+				return nibble>>(3-x%4)&1 != 0
+			}
+			diff := 0
+			for y := 0; y < c.Size; y++ {
+				for x := 0; x < c.Size; x++ {
+					if c.Dark(x, y) != want(x, y) {
+						diff++
+					}
+				}
+			}
+			if diff > 0 {
+				t.Errorf("%d modules differ\ngot:\n%swant:\n%s", diff, render(c.Size, c.Dark), render(c.Size, want))
+			}
+		})
+	}
+}
+
+// TestCapacity checks the largest byte-mode payload of each level at the
+// version boundaries against the capacity table of the standard.
+func TestCapacity(t *testing.T) {
+	capacity := map[Level][]struct{ version, bytes int }{
+		LevelL: {{1, 17}, {2, 32}, {9, 230}, {10, 271}, {40, 2953}},
+		LevelM: {{1, 14}, {2, 26}, {9, 180}, {10, 213}, {40, 2331}},
+		LevelQ: {{1, 11}, {2, 20}, {9, 130}, {10, 151}, {40, 1663}},
+		LevelH: {{1, 7}, {2, 14}, {9, 98}, {10, 119}, {40, 1273}},
+	}
+	for level, limits := range capacity {
+		for _, limit := range limits {
+			c, err := Encode(make([]byte, limit.bytes), level)
+			if err != nil {
+				t.Errorf("level %d, %d bytes: %v", level, limit.bytes, err)
+				continue
+			}
+			if c.Version != limit.version {
+				t.Errorf("level %d, %d bytes: version %d, want %d", level, limit.bytes, c.Version, limit.version)
+			}
+			if limit.version == maxVersion {
+				if _, err := Encode(make([]byte, limit.bytes+1), level); !errors.Is(err, ErrTooLong) {
+					t.Errorf("level %d, %d bytes: err = %v, want ErrTooLong", level, limit.bytes+1, err)
+				}
+				continue
+			}
+			c, err = Encode(make([]byte, limit.bytes+1), level)
+			if err != nil || c.Version != limit.version+1 {
+				t.Errorf("level %d, %d bytes: version %d, %v; want %d", level, limit.bytes+1, c.Version, err, limit.version+1)
+			}
+		}
+	}
+}
+
+func TestEncodePicksLowestPenalty(t *testing.T) {
+	for _, tc := range loadGolden(t)[:8] {
+		data := []byte(tc.Data)
+		got, err := Encode(data, levelNames[tc.Level])
+		if err != nil {
+			t.Fatal(err)
+		}
+		penalties := make([]int, 8)
+		for mask := range penalties {
+			c, _ := encode(data, levelNames[tc.Level], mask)
+			penalties[mask] = c.penalty()
+		}
+		chosen := got.penalty()
+		for mask, p := range penalties {
+			if p < chosen {
+				t.Errorf("%q: mask %d scores %d, below the chosen symbol's %d", tc.Data, mask, p, chosen)
+			}
+		}
+	}
+}
+
+func TestLinePenalty(t *testing.T) {
+	line := func(s string) []bool {
+		out := make([]bool, len(s))
+		for i, r := range s {
+			out[i] = r == '#'
+		}
+		return out
+	}
+	tests := []struct {
+		line string
+		want int
+	}{
+		{"#.#.#.#.", 0},
+		{"####.#.#", 0},
+		{"#####.#.", 3},         // a run of five
+		{"#######.", 5},         // five, plus one per extra module
+		{".....#####", 6},       // two runs
+		{"#.###.#....", 40},     // finder-like with the light side after
+		{"....#.###.#", 40},     // and before
+		{"....#.###.#....", 80}, // both sides count
+		{"#.###.#...#", 0},      // only three light modules
+		{"#..###.#....#", 0},    // ratio broken
+	}
+	for _, tt := range tests {
+		if got := linePenalty(line(tt.line)); got != tt.want {
+			t.Errorf("linePenalty(%q) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// Annex C of the standard: level M, mask 5 gives 100000011001110 after
+	// masking with 101010000010010.
+	c := newCode(1)
+	c.drawFormatBits(LevelM, 5)
+	const want = "100000011001110"
+	got := make([]byte, 0, len(want))
+	bit := func(x, y int) {
+		if c.Dark(x, y) {
+			got = append(got, '1')
+		} else {
+			got = append(got, '0')
+		}
+	}
+	// Bits 14 to 8 run along row 8 from the left and bits 7 to 0 up
+	// column 8, both skipping the timing pattern.
+	for _, x := range []int{0, 1, 2, 3, 4, 5, 7} {
+		bit(x, 8)
+	}
+	for _, y := range []int{8, 7, 5, 4, 3, 2, 1, 0} {
+		bit(8, y)
+	}
+	if string(got) != want {
+		t.Errorf("format bits = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+// Regenerates golden.json with an independent encoder, Kazuhiko Arase's
+// QRCode for JavaScript as vendored by the qrcode-terminal npm package:
+//
+//   node generate.js "$(npm root -g)/npm/node_modules/qrcode-terminal/vendor/QRCode" > golden.json
+//
+// Its mask selection does not follow the standard's penalty rules, so every
+// case fixes the mask and the test encodes with the same one.
+
+const path = require("path");
+
+const vendor = process.argv[2];
+const QRCode = require(vendor);
+const levels = require(path.join(vendor, "QRErrorCorrectLevel"));
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789";
+const filler = (n) => Array.from({ length: n }, (_, i) => alphabet[i % alphabet.length]).join("");
+
+const cases = [];
+const add = (name, data, level, mask) => cases.push({ name, data, level, mask });
+
+const link = "https://hub.example/join?slot=p1";
+for (let mask = 0; mask < 8; mask++) {
+  add(`mask ${mask}`, link, "M", mask);
+}
+["L", "M", "Q", "H"].forEach((level, i) => add(`level ${level}`, "cgb-io-hub", level, i * 2 + 1));
+
+// Byte-mode capacities from the standard: the last length that fits
+// version 1 and version 9 (the last with an 8-bit length field), then one
+// more byte, which moves to the next version. Version 7 is the first with
+// version information.
+const capacity = { L: [17, 230], M: [14, 180], Q: [11, 130], H: [7, 98] };
+let i = 0;
+for (const [level, [v1, v9]] of Object.entries(capacity)) {
+  for (const n of [v1, v1 + 1, v9, v9 + 1]) {
+    add(`${level} ${n} bytes`, filler(n), level, i++ % 8);
+  }
+}
+add("version 7", filler(135), "L", 6);
+
+const out = cases.map(({ name, data, level, mask }) => {
+  const qr = new QRCode(-1, levels[level]);
+  qr.addData(data);
+  qr.make();
+  qr.makeImpl(false, mask);
+  const rows = qr.modules.map((row) => {
+    const bits = row.map((dark) => (dark ? "1" : "0")).join("");
+    const padded = bits.padEnd(Math.ceil(bits.length / 4) * 4, "0");
+    let hex = "";
+    for (let j = 0; j < padded.length; j += 4) {
+      hex += parseInt(padded.slice(j, j + 4), 2).toString(16);
+    }
+    return hex;
+  });
+  return { name, data, level, mask, version: qr.typeNumber, rows };
+});
+
+process.stdout.write(JSON.stringify(out, null, 1) + "\n");
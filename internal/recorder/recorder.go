@@ -0,0 +1,226 @@
+// Package recorder captures the controller frames relayed to the game as
+// timestamped JSON Lines and plays them back at their original timing.
+// Recordings are kept in a blob.Store.
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/blob"
+)
+
+// Extension is the file suffix used for recordings.
+const Extension = ".jsonl"
+
+// ErrClosed is returned when recording into a closed Recorder.
+var ErrClosed = errors.New("recorder: closed")
+
+// Frame is one relayed controller message. Offset is measured from the
+// start of the recording.
+type Frame struct {
+	Offset time.Duration   `json:"-"`
+	At     time.Time       `json:"at"`
+	Slot   string          `json:"slot"`
+	Type   string          `json:"type,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// MarshalJSON writes Offset as fractional milliseconds.
+func (f Frame) MarshalJSON() ([]byte, error) {
+	type plain Frame
+	return json.Marshal(struct {
+		OffsetMs float64 `json:"offsetMs"`
+		plain
+	}{float64(f.Offset) / float64(time.Millisecond), plain(f)})
+}
+
+// UnmarshalJSON reads a line written by MarshalJSON.
+func (f *Frame) UnmarshalJSON(data []byte) error {
+	type plain Frame
+	var line struct {
+		OffsetMs float64 `json:"offsetMs"`
+		plain
+	}
+	if err := json.Unmarshal(data, &line); err != nil {
+		return err
+	}
+	*f = Frame(line.plain)
+	f.Offset = time.Duration(line.OffsetMs * float64(time.Millisecond))
+	return nil
+}
+
+// Recorder appends frames to a JSON Lines object. It is safe for concurrent
+// use.
+type Recorder struct {
+	name  string
+	start time.Time
+
+	mu     sync.Mutex
+	out    io.WriteCloser
+	w      *bufio.Writer
+	frames int
+	err    error
+}
+
+// Create starts a new recording in store named after label and the current
+// time, e.g. "match-20251102T130405Z.jsonl".
+func Create(ctx context.Context, store blob.Store, label string) (*Recorder, error) {
+	if store == nil {
+		return nil, errors.New("recorder: store required")
+	}
+	label = sanitizeLabel(label)
+	start := time.Now()
+	base := fmt.Sprintf("%s-%s", label, start.UTC().Format("20060102T150405Z"))
+
+	var name string
+	for i := 1; ; i++ {
+		name = base + Extension
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d%s", base, i, Extension)
+		}
+		_, err := store.Stat(ctx, name)
+		if errors.Is(err, blob.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("recorder: create %s: %w", name, err)
+		}
+		if i >= 100 {
+			return nil, fmt.Errorf("recorder: create %s: name taken", name)
+		}
+	}
+	out, err := store.Create(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: create %s: %w", name, err)
+	}
+	return &Recorder{
+		name:  name,
+		start: start,
+		out:   out,
+		w:     bufio.NewWriter(out),
+	}, nil
+}
+
+// Name reports the recording being written.
+func (r *Recorder) Name() string {
+	return r.name
+}
+
+// Started reports when the recording began.
+func (r *Recorder) Started() time.Time {
+	return r.start
+}
+
+// Frames reports how many frames were written so far.
+func (r *Recorder) Frames() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frames
+}
+
+// Record appends a frame relayed from slot. data must be a JSON document;
+// it is stored verbatim.
+func (r *Recorder) Record(slot, msgType string, data []byte) error {
+	now := time.Now()
+	line, err := json.Marshal(Frame{
+		Offset: now.Sub(r.start),
+		At:     now.UTC(),
+		Slot:   slot,
+		Type:   msgType,
+		Data:   json.RawMessage(data),
+	})
+	if err != nil {
+		return fmt.Errorf("recorder: encode frame: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.out == nil {
+		return ErrClosed
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = fmt.Errorf("recorder: write %s: %w", r.name, err)
+		return r.err
+	}
+	r.frames++
+	return nil
+}
+
+// Close flushes and closes the recording; stores that upload on close,
+// such as S3, do so now. Closing twice is a no-op.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.out == nil {
+		return nil
+	}
+	flushErr := r.w.Flush()
+	closeErr := r.out.Close()
+	r.out = nil
+	if flushErr != nil {
+		return fmt.Errorf("recorder: flush %s: %w", r.name, flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("recorder: close %s: %w", r.name, closeErr)
+	}
+	return nil
+}
+
+// Info describes a stored recording.
+type Info struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the recordings in store, newest first.
+func List(ctx context.Context, store blob.Store) ([]Info, error) {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("recorder: list %s: %w", store, err)
+	}
+	infos := make([]Info, 0, len(objects))
+	for _, obj := range objects {
+		if strings.Contains(obj.Key, "/") || !strings.HasSuffix(obj.Key, Extension) {
+			continue
+		}
+		infos = append(infos, Info{Name: obj.Key, Size: obj.Size, ModTime: obj.ModTime})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime.After(infos[j].ModTime) })
+	return infos, nil
+}
+
+// ValidName rejects recording names that are not a plain recording file
+// name, such as ones reaching into another part of the store.
+func ValidName(name string) error {
+	if name == "" || strings.Contains(name, "/") || !strings.HasSuffix(name, Extension) || blob.ValidKey(name) != nil {
+		return fmt.Errorf("recorder: invalid recording name %q", name)
+	}
+	return nil
+}
+
+func sanitizeLabel(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(label) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "recording"
+	}
+	return b.String()
+}
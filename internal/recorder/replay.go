@@ -0,0 +1,77 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/blob"
+)
+
+// maxLineSize bounds a single recorded frame.
+const maxLineSize = 1 << 20
+
+// Replay reads the recording name in store and calls emit for each frame at its
+// recorded offset, scaled by speed (2 plays twice as fast; values <= 0 mean
+// 1). It returns the number of frames emitted. Replay stops early when ctx
+// is done or emit fails.
+func Replay(ctx context.Context, store blob.Store, name string, speed float64, emit func(Frame) error) (int, error) {
+	if speed <= 0 {
+		speed = 1
+	}
+	if err := ValidName(name); err != nil {
+		return 0, err
+	}
+
+	file, err := store.Open(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("recorder: open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	emitted := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return emitted, fmt.Errorf("recorder: %s line %d: %w", name, line, err)
+		}
+
+		due := start.Add(time.Duration(float64(frame.Offset) / speed))
+		if wait := time.Until(due); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return emitted, ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return emitted, err
+		}
+
+		if err := emit(frame); err != nil {
+			return emitted, err
+		}
+		emitted++
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return emitted, fmt.Errorf("recorder: %s: frame exceeds %d bytes", name, maxLineSize)
+		}
+		return emitted, fmt.Errorf("recorder: read %s: %w", name, err)
+	}
+	return emitted, nil
+}
@@ -0,0 +1,55 @@
+// Package sdnotify implements the systemd service notification protocol
+// (sd_notify(3)) without linking libsystemd: state strings such as READY=1
+// or WATCHDOG=1 are sent as datagrams to the socket named by NOTIFY_SOCKET.
+// Outside systemd the variable is unset and every call is a no-op.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the service manager. It reports false, with a nil
+// error, when NOTIFY_SOCKET is not set.
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the Linux abstract namespace.
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often WATCHDOG=1 must be sent when the unit
+// sets WatchdogSec=, or 0 when the watchdog is off or meant for another
+// process (WATCHDOG_PID).
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the on-disk format written by this build.
+const SchemaVersion = 1
+
+// ErrIncompatibleVersion indicates the state file was written by a newer hub
+// whose format this build cannot read.
+var ErrIncompatibleVersion = errors.New("state: incompatible schema version")
+
+// migration upgrades a raw document from version n to n+1.
+type migration func(doc map[string]json.RawMessage) error
+
+// migrations is indexed by the source version.
+var migrations = map[int]migration{
+	// Version 0 files predate the version field; the layout is unchanged.
+	0: func(doc map[string]json.RawMessage) error { return nil },
+}
+
+// migrate upgrades doc in place to SchemaVersion, reporting the version it
+// was read at.
+func migrate(doc map[string]json.RawMessage) (int, error) {
+	from := 0
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &from); err != nil {
+			return 0, fmt.Errorf("state: decode version: %w", err)
+		}
+	}
+
+	if from > SchemaVersion {
+		return from, fmt.Errorf("%w: file has version %d, this build supports up to %d; upgrade the hub or move the state file aside", ErrIncompatibleVersion, from, SchemaVersion)
+	}
+
+	for v := from; v < SchemaVersion; v++ {
+		step, ok := migrations[v]
+		if !ok {
+			return from, fmt.Errorf("state: no migration from version %d", v)
+		}
+		if err := step(doc); err != nil {
+			return from, fmt.Errorf("state: migrate version %d: %w", v, err)
+		}
+	}
+	doc["version"] = json.RawMessage(fmt.Sprint(SchemaVersion))
+	return from, nil
+}
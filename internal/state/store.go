@@ -0,0 +1,174 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State is the hub data persisted across restarts.
+type State struct {
+	Version int             `json:"version"`
+	Play    *PlaySession    `json:"play,omitempty"`
+	Results []PendingResult `json:"results,omitempty"`
+	// Visits holds recent visit times per user id, for the visit policy.
+	Visits map[string][]time.Time `json:"visits,omitempty"`
+	// Leaderboard holds the scores accepted by /api/game/result, oldest
+	// first.
+	Leaderboard []ScoreRecord `json:"leaderboard,omitempty"`
+}
+
+// PlaySession records a match started via /api/game/start that has not yet
+// received its result submission.
+type PlaySession struct {
+	StartTime time.Time        `json:"startTime"`
+	Slots     []SlotAssignment `json:"slots"`
+}
+
+// SlotAssignment captures the user bound to a slot when the match started.
+type SlotAssignment struct {
+	SlotID      string `json:"slotId"`
+	UserID      string `json:"userId"`
+	Name        string `json:"name,omitempty"`
+	Personality string `json:"personality,omitempty"`
+}
+
+// PendingResult is a match result PersonaGo has not accepted yet, kept so it
+// can be re-sent after a restart.
+type PendingResult struct {
+	ID          string        `json:"id"`
+	StartTime   time.Time     `json:"startTime"`
+	Results     []ResultEntry `json:"results"`
+	QueuedAt    time.Time     `json:"queuedAt"`
+	Attempts    int           `json:"attempts"`
+	NextAttempt time.Time     `json:"nextAttempt"`
+	LastError   string        `json:"lastError,omitempty"`
+	// Failed is set once automatic retries gave up; only a manual re-drive
+	// sends the result again.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// ResultEntry is one slot's score in a PendingResult.
+type ResultEntry struct {
+	Slot     int            `json:"slot"`
+	UserID   string         `json:"userId"`
+	Name     string         `json:"name"`
+	Score    int            `json:"score"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ScoreRecord is one player's score in the leaderboard history.
+type ScoreRecord struct {
+	StartTime   time.Time `json:"startTime"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	SlotID      string    `json:"slotId"`
+	UserID      string    `json:"userId"`
+	Name        string    `json:"name,omitempty"`
+	Score       int       `json:"score"`
+}
+
+// Store persists State as a JSON document on the local filesystem.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open returns a Store writing to path. The file is created lazily on the
+// first Save.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		return nil, errors.New("state: path required")
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("state: create directory: %w", err)
+		}
+	}
+	return &Store{path: path}, nil
+}
+
+// Path reports the file backing the store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load reads the persisted state. A missing file yields an empty State.
+func (s *Store) Load() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return State{}, nil
+		}
+		return State{}, fmt.Errorf("state: read %s: %w", s.path, err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return State{}, fmt.Errorf("state: decode %s: %w", s.path, err)
+	}
+	from, err := migrate(doc)
+	if err != nil {
+		return State{}, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return State{}, fmt.Errorf("state: encode migrated state: %w", err)
+	}
+	var st State
+	if err := json.Unmarshal(migrated, &st); err != nil {
+		return State{}, fmt.Errorf("state: decode %s: %w", s.path, err)
+	}
+
+	if from != SchemaVersion {
+		if err := s.saveLocked(st); err != nil {
+			return State{}, err
+		}
+	}
+	return st, nil
+}
+
+// Save atomically replaces the persisted state.
+func (s *Store) Save(st State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked(st)
+}
+
+func (s *Store) saveLocked(st State) error {
+	st.Version = SchemaVersion
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("state: encode: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("state: create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("state: write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("state: sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("state: close temp file: %w", err)
+	}
+	if err := os.Rename(tmpName, s.path); err != nil {
+		return fmt.Errorf("state: replace %s: %w", s.path, err)
+	}
+	return nil
+}
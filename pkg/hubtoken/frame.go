@@ -0,0 +1,55 @@
+package hubtoken
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Errors returned by VerifyFrame.
+var (
+	ErrUnsignedFrame  = errors.New("hubtoken: frame is not signed")
+	ErrFrameSignature = errors.New("hubtoken: invalid frame signature")
+)
+
+// sigMember is how the hub appends the signature: always the last member of
+// the envelope object.
+var sigMember = []byte(`,"sig":"`)
+
+// VerifyFrame checks a relay envelope signed by a hub running with
+// RELAY_SIGNING_KEY and returns it without the "sig" member. frame must be
+// the text exactly as read from the WebSocket. Any of keys may match, so a
+// game can accept the old and new secret while rotating.
+//
+// The signature is the base64url (no padding) HMAC-SHA256, under the shared
+// key, of the frame with its trailing `,"sig":"<signature>"` removed and the
+// closing brace kept. The signed bytes include seq and receivedAt; games
+// that must reject replays should also require seq to increase per slotId.
+func VerifyFrame(frame []byte, keys ...[]byte) ([]byte, error) {
+	frame = bytes.TrimRight(frame, " \t\r\n")
+	if !bytes.HasSuffix(frame, []byte(`"}`)) {
+		return nil, ErrUnsignedFrame
+	}
+	at := bytes.LastIndex(frame, sigMember)
+	if at < 0 {
+		return nil, ErrUnsignedFrame
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(string(frame[at+len(sigMember) : len(frame)-2]))
+	if err != nil || len(sig) != sha256.Size {
+		return nil, ErrFrameSignature
+	}
+
+	unsigned := make([]byte, 0, at+1)
+	unsigned = append(unsigned, frame[:at]...)
+	unsigned = append(unsigned, '}')
+	for _, key := range keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(unsigned)
+		if hmac.Equal(mac.Sum(nil), sig) {
+			return unsigned, nil
+		}
+	}
+	return nil, ErrFrameSignature
+}
@@ -0,0 +1,162 @@
+package hubtoken_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/pkg/hubtoken"
+)
+
+// relayFrame connects a game and a controller to a hub signing envelopes
+// with key and returns the relay frame the game receives for one input.
+func relayFrame(t *testing.T, key []byte) []byte {
+	t.Helper()
+	h := hub.New(hub.Config{Envelope: true, EnvelopeKey: key}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	server := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dial := func(hello string) *websocket.Conn {
+		conn, _, err := websocket.Dial(ctx, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Write(ctx, websocket.MessageText, []byte(hello)); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	// next returns the next frame of the given type sent to conn.
+	next := func(conn *websocket.Conn, typ string) []byte {
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				t.Fatalf("waiting for %s: %v", typ, err)
+			}
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(data, &msg) == nil && msg.Type == typ {
+				return data
+			}
+		}
+	}
+
+	game := dial(`{"role":"game"}`)
+	defer game.CloseNow()
+	next(game, "state")
+	controller := dial(`{"role":"controller","id":"p1"}`)
+	defer controller.CloseNow()
+	next(game, "controller_joined")
+	input := `{"type":"input","id":"p1","axes":{"x":1,"y":0},"btn":{"a":true}}`
+	if err := controller.Write(ctx, websocket.MessageText, []byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	return next(game, "relay")
+}
+
+func TestVerifyFrameFromHub(t *testing.T) {
+	key := []byte("relay-secret")
+	frame := relayFrame(t, key)
+
+	unsigned, err := hubtoken.VerifyFrame(frame, []byte("previous-secret"), key)
+	if err != nil {
+		t.Fatalf("VerifyFrame: %v\n%s", err, frame)
+	}
+	var envelope struct {
+		SlotID  string `json:"slotId"`
+		Sig     string `json:"sig"`
+		Payload struct {
+			ID   string          `json:"id"`
+			Btn  map[string]bool `json:"btn"`
+			Axes map[string]int  `json:"axes"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(unsigned, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Sig != "" {
+		t.Error("verified frame still carries sig")
+	}
+	if envelope.SlotID != "p1" || envelope.Payload.ID != "p1" || !envelope.Payload.Btn["a"] || envelope.Payload.Axes["x"] != 1 {
+		t.Errorf("envelope = %s", unsigned)
+	}
+
+	if _, err := hubtoken.VerifyFrame(frame, []byte("other-secret")); !errors.Is(err, hubtoken.ErrFrameSignature) {
+		t.Errorf("wrong key: err = %v, want ErrFrameSignature", err)
+	}
+	if _, err := hubtoken.VerifyFrame(frame); !errors.Is(err, hubtoken.ErrFrameSignature) {
+		t.Errorf("no keys: err = %v, want ErrFrameSignature", err)
+	}
+}
+
+// sign appends a signature to frame the way the hub documents it.
+func sign(key []byte, frame string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(frame))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return frame[:len(frame)-1] + `,"sig":"` + sig + `"}`
+}
+
+func TestVerifyFrameTampered(t *testing.T) {
+	key := []byte("relay-secret")
+	frame := `{"type":"relay","slotId":"p1","receivedAt":1700000000000,"seq":7,"payload":{"type":"input","btn":{"a":true}}}`
+	signed := sign(key, frame)
+	if got, err := hubtoken.VerifyFrame([]byte(signed+"\n"), key); err != nil || string(got) != frame {
+		t.Fatalf("VerifyFrame = %s, %v; want %s", got, err, frame)
+	}
+
+	sigAt := strings.LastIndex(signed, `"sig":"`) + len(`"sig":"`)
+	flipped := []byte(signed)
+	if flipped[sigAt] == 'A' {
+		flipped[sigAt] = 'B'
+	} else {
+		flipped[sigAt] = 'A'
+	}
+
+	tests := []struct {
+		name  string
+		frame string
+		want  error
+	}{
+		{"slot changed", strings.Replace(signed, `"p1"`, `"p2"`, 1), hubtoken.ErrFrameSignature},
+		{"seq replayed lower", strings.Replace(signed, `"seq":7`, `"seq":6`, 1), hubtoken.ErrFrameSignature},
+		{"payload changed", strings.Replace(signed, `"a":true`, `"a":false`, 1), hubtoken.ErrFrameSignature},
+		{"signature changed", string(flipped), hubtoken.ErrFrameSignature},
+		{"signature truncated", signed[:sigAt+10] + `"}`, hubtoken.ErrFrameSignature},
+		{"signature not base64", signed[:sigAt] + `!!"}`, hubtoken.ErrFrameSignature},
+		{"unsigned", frame, hubtoken.ErrUnsignedFrame},
+		{"sig not last", signed[:len(signed)-1] + `,"x":1}`, hubtoken.ErrUnsignedFrame},
+		{"empty", "", hubtoken.ErrUnsignedFrame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := hubtoken.VerifyFrame([]byte(tt.frame), key); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// The returned frame is a copy; the input is left as received.
+	in := []byte(signed)
+	if _, err := hubtoken.VerifyFrame(in, key); err != nil || !bytes.Equal(in, []byte(signed)) {
+		t.Errorf("VerifyFrame modified its input: %v", err)
+	}
+}
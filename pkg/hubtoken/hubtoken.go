@@ -0,0 +1,312 @@
+// Package hubtoken verifies tokens issued by cgb-io-hub without calling back
+// into the hub. Games embed it to check the token a controller presents over
+// any channel, not only the hub's own WebSocket.
+//
+// The hub must run with TOKEN_FORMAT=jwt. Its tokens are then compact JWS
+// strings signed with Ed25519, and the public keys are served as a JWK set at
+// /.well-known/jwks.json. Verification is:
+//
+//  1. Split the token into header, payload and signature at the two dots and
+//     base64url-decode (no padding) each part.
+//  2. The header must be {"alg":"EdDSA","typ":"JWT","kid":...}. Look up the
+//     key whose "kid" matches in the JWK set; keys are {"kty":"OKP",
+//     "crv":"Ed25519","x":<base64url public key>}. Refetch the set once when
+//     the kid is unknown, since the hub may have restarted or rotated keys.
+//  3. Verify the Ed25519 signature over the ASCII bytes
+//     "<header part>.<payload part>" exactly as received.
+//  4. Check the payload: "iss" is "cgb-io-hub", "exp" (unix seconds) has
+//     not passed, "scope" is the expected one (controller tokens have
+//     "sub" set to the slot id and carry "userId"), and "aud" matches when
+//     the verifier expects an audience.
+//
+// A signature check only proves the hub issued the token. The hub can still
+// revoke a token before it expires; verifiers that must honour revocation
+// should keep expiries short (SESSION_TOKEN_TTL) or ask the hub.
+//
+// VerifyFrame checks the HMAC signature the hub adds to relay envelopes when
+// RELAY_SIGNING_KEY is set; see its documentation for the scheme.
+package hubtoken
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issuer is the iss claim of hub tokens.
+const Issuer = "cgb-io-hub"
+
+// Scopes a hub token may carry.
+const (
+	ScopeController = "controller"
+	ScopeAdmin      = "admin"
+	ScopeSpectator  = "spectator"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMalformed  = errors.New("hubtoken: malformed token")
+	ErrUnknownKey = errors.New("hubtoken: unknown signing key")
+	ErrSignature  = errors.New("hubtoken: invalid signature")
+	ErrExpired    = errors.New("hubtoken: token expired")
+	ErrIssuer     = errors.New("hubtoken: unexpected issuer")
+	ErrScope      = errors.New("hubtoken: unexpected scope")
+	ErrAudience   = errors.New("hubtoken: audience mismatch")
+)
+
+// Claims is the verified content of a hub token.
+type Claims struct {
+	Issuer    string
+	Subject   string // slot id for controller tokens
+	Audience  string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	ID        string
+	Scope     string
+	UserID    string
+	Name      string
+	Cohort    string
+	Extra     map[string]string
+}
+
+// Options narrows what Verify accepts. The zero value accepts any live
+// controller token.
+type Options struct {
+	// Scope defaults to ScopeController.
+	Scope string
+	// Audience, when set, must equal the token's audience if it has one.
+	Audience string
+	// Leeway tolerates clock skew between the hub and the verifier.
+	Leeway time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// KeySet holds the hub's public keys by kid.
+type KeySet struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// ParseKeySet reads a JWK set document as served by the hub. Keys other than
+// Ed25519 signing keys are skipped.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("hubtoken: parse key set: %w", err)
+	}
+	set := &KeySet{keys: make(map[string]ed25519.PublicKey)}
+	for _, key := range doc.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" || key.Kid == "" {
+			continue
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("hubtoken: key %s: invalid x", key.Kid)
+		}
+		set.keys[key.Kid] = ed25519.PublicKey(pub)
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("hubtoken: key set has no Ed25519 keys")
+	}
+	return set, nil
+}
+
+// Verify checks token against the keys in the set and opts.
+func (s *KeySet) Verify(token string, opts Options) (Claims, error) {
+	header, payload, signature, signed, err := split(token)
+	if err != nil {
+		return Claims{}, err
+	}
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(header, &head); err != nil || head.Alg != "EdDSA" {
+		return Claims{}, ErrMalformed
+	}
+	key, ok := s.keys[head.Kid]
+	if !ok {
+		return Claims{}, ErrUnknownKey
+	}
+	if !ed25519.Verify(key, signed, signature) {
+		return Claims{}, ErrSignature
+	}
+	return checkClaims(payload, opts)
+}
+
+func (s *KeySet) has(kid string) bool {
+	_, ok := s.keys[kid]
+	return ok
+}
+
+func split(token string) (header, payload, signature, signed []byte, err error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, nil, ErrMalformed
+	}
+	decoded := make([][]byte, 3)
+	for i, part := range parts {
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, nil, nil, nil, ErrMalformed
+		}
+	}
+	return decoded[0], decoded[1], decoded[2], []byte(parts[0] + "." + parts[1]), nil
+}
+
+func checkClaims(payload []byte, opts Options) (Claims, error) {
+	var raw struct {
+		Iss    string            `json:"iss"`
+		Sub    string            `json:"sub"`
+		Aud    string            `json:"aud"`
+		Iat    int64             `json:"iat"`
+		Exp    int64             `json:"exp"`
+		Jti    string            `json:"jti"`
+		Scope  string            `json:"scope"`
+		UserID string            `json:"userId"`
+		Name   string            `json:"name"`
+		Cohort string            `json:"cohort"`
+		Claims map[string]string `json:"claims"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	scope := opts.Scope
+	if scope == "" {
+		scope = ScopeController
+	}
+	expiresAt := time.Unix(raw.Exp, 0)
+	switch {
+	case raw.Iss != Issuer:
+		return Claims{}, ErrIssuer
+	case raw.Exp == 0 || now().After(expiresAt.Add(opts.Leeway)):
+		return Claims{}, ErrExpired
+	case raw.Scope != scope:
+		return Claims{}, ErrScope
+	case opts.Audience != "" && raw.Aud != "" && raw.Aud != opts.Audience:
+		return Claims{}, ErrAudience
+	}
+	return Claims{
+		Issuer:    raw.Iss,
+		Subject:   raw.Sub,
+		Audience:  raw.Aud,
+		IssuedAt:  time.Unix(raw.Iat, 0),
+		ExpiresAt: expiresAt,
+		ID:        raw.Jti,
+		Scope:     raw.Scope,
+		UserID:    raw.UserID,
+		Name:      raw.Name,
+		Cohort:    raw.Cohort,
+		Extra:     raw.Claims,
+	}, nil
+}
+
+// RemoteKeySet fetches the hub's key set from URL and refetches it when a
+// token names an unknown key, at most once per MinRefresh.
+type RemoteKeySet struct {
+	URL        string
+	Client     *http.Client
+	MinRefresh time.Duration
+
+	mu        sync.Mutex
+	set       *KeySet
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet returns a key set backed by the hub's
+// /.well-known/jwks.json, e.g. "http://hub:8765/.well-known/jwks.json".
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:        url,
+		Client:     &http.Client{Timeout: 5 * time.Second},
+		MinRefresh: 30 * time.Second,
+	}
+}
+
+// Verify checks token, fetching the key set first if needed.
+func (r *RemoteKeySet) Verify(ctx context.Context, token string, opts Options) (Claims, error) {
+	set, err := r.keys(ctx, kidOf(token))
+	if err != nil {
+		return Claims{}, err
+	}
+	return set.Verify(token, opts)
+}
+
+func (r *RemoteKeySet) keys(ctx context.Context, kid string) (*KeySet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.set != nil && (r.set.has(kid) || time.Since(r.fetchedAt) < r.MinRefresh) {
+		return r.set, nil
+	}
+	set, err := r.fetch(ctx)
+	if err != nil {
+		if r.set != nil {
+			return r.set, nil
+		}
+		return nil, err
+	}
+	r.set, r.fetchedAt = set, time.Now()
+	return set, nil
+}
+
+func (r *RemoteKeySet) fetch(ctx context.Context) (*KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("hubtoken: fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hubtoken: fetch key set: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("hubtoken: fetch key set: %w", err)
+	}
+	return ParseKeySet(data)
+}
+
+// kidOf returns the kid from the token header, or "" when it cannot be read;
+// Verify reports the malformed token.
+func kidOf(token string) string {
+	header, _, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return ""
+	}
+	var head struct {
+		Kid string `json:"kid"`
+	}
+	_ = json.Unmarshal(data, &head)
+	return head.Kid
+}
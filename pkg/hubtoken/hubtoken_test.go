@@ -0,0 +1,287 @@
+package hubtoken_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aritumn2025/cgb-io-hub/internal/hub"
+	"github.com/aritumn2025/cgb-io-hub/pkg/hubtoken"
+)
+
+// newHub returns a hub signing with signer. The tests verify tokens minted
+// by the hub itself, so they check the contract between the two sides
+// rather than a copy of the signing code.
+func newHub(t *testing.T, signer *hub.TokenSigner) *hub.Hub {
+	t.Helper()
+	cfg := hub.Config{
+		Signer:  signer,
+		Cohorts: map[string]hub.Cohort{"beta": {Name: "beta"}},
+	}
+	return hub.New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func newSigner(t *testing.T, verifyOnly ...ed25519.PublicKey) (*hub.TokenSigner, ed25519.PrivateKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hub.NewTokenSigner(key, verifyOnly...), key
+}
+
+func keySetOf(t *testing.T, h *hub.Hub) *hubtoken.KeySet {
+	t.Helper()
+	jwks, ok := h.JWKS()
+	if !ok {
+		t.Fatal("hub publishes no key set")
+	}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := hubtoken.ParseKeySet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestVerifyControllerToken(t *testing.T) {
+	signer, _ := newSigner(t)
+	h := newHub(t, signer)
+	token, expiresAt, err := h.IssueControllerToken("p1", "user-1", "Alice", "brave", "beta", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := keySetOf(t, h).Verify(token, hubtoken.Options{})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Issuer != hubtoken.Issuer || claims.Scope != hubtoken.ScopeController {
+		t.Errorf("issuer, scope = %q, %q", claims.Issuer, claims.Scope)
+	}
+	if claims.Subject != "p1" || claims.UserID != "user-1" || claims.Name != "Alice" || claims.Cohort != "beta" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.ID == "" {
+		t.Error("token has no jti")
+	}
+	if !claims.ExpiresAt.Equal(expiresAt.Truncate(time.Second)) {
+		t.Errorf("ExpiresAt = %v, want %v", claims.ExpiresAt, expiresAt)
+	}
+}
+
+func TestVerifyScopeAndAudience(t *testing.T) {
+	signer, _ := newSigner(t)
+	h := newHub(t, signer)
+	token, _, err := h.IssueToken(hub.TokenRequest{
+		Scope:    hub.ScopeAdmin,
+		Subject:  "ops",
+		Audience: "dashboard",
+		Claims:   map[string]string{"role": "lead"},
+		TTL:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := keySetOf(t, h)
+
+	claims, err := set.Verify(token, hubtoken.Options{Scope: hubtoken.ScopeAdmin, Audience: "dashboard"})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "ops" || claims.Audience != "dashboard" || claims.Extra["role"] != "lead" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := set.Verify(token, hubtoken.Options{Scope: hubtoken.ScopeAdmin}); err != nil {
+		t.Errorf("Verify without audience: %v", err)
+	}
+	if _, err := set.Verify(token, hubtoken.Options{Scope: hubtoken.ScopeAdmin, Audience: "game"}); !errors.Is(err, hubtoken.ErrAudience) {
+		t.Errorf("other audience: err = %v, want ErrAudience", err)
+	}
+	if _, err := set.Verify(token, hubtoken.Options{}); !errors.Is(err, hubtoken.ErrScope) {
+		t.Errorf("controller scope: err = %v, want ErrScope", err)
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	signer, _ := newSigner(t)
+	h := newHub(t, signer)
+	token, expiresAt, err := h.IssueControllerToken("p2", "user-2", "Bob", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := keySetOf(t, h)
+	exp := expiresAt.Truncate(time.Second)
+	at := func(t time.Time) func() time.Time { return func() time.Time { return t } }
+
+	tests := []struct {
+		name   string
+		opts   hubtoken.Options
+		expire bool
+	}{
+		{"at expiry", hubtoken.Options{Now: at(exp)}, false},
+		{"just after", hubtoken.Options{Now: at(exp.Add(time.Second))}, true},
+		{"within leeway", hubtoken.Options{Now: at(exp.Add(time.Second)), Leeway: 5 * time.Second}, false},
+		{"past leeway", hubtoken.Options{Now: at(exp.Add(6 * time.Second)), Leeway: 5 * time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := set.Verify(token, tt.opts)
+			if tt.expire && !errors.Is(err, hubtoken.ErrExpired) {
+				t.Errorf("err = %v, want ErrExpired", err)
+			}
+			if !tt.expire && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// reencode replaces part i of token with the base64url of data.
+func reencode(token string, i int, data []byte) string {
+	parts := strings.Split(token, ".")
+	parts[i] = base64.RawURLEncoding.EncodeToString(data)
+	return strings.Join(parts, ".")
+}
+
+func decodePart(t *testing.T, token string, i int) []byte {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[i])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyTampered(t *testing.T) {
+	signer, _ := newSigner(t)
+	h := newHub(t, signer)
+	token, _, err := h.IssueControllerToken("p1", "user-1", "Alice", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := keySetOf(t, h)
+
+	payload := decodePart(t, token, 1)
+	header := decodePart(t, token, 0)
+	signature := decodePart(t, token, 2)
+	signature[0] ^= 0xff
+
+	other, _ := newSigner(t)
+	otherToken, _, err := newHub(t, other).IssueControllerToken("p1", "user-1", "Alice", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"slot changed", reencode(token, 1, []byte(strings.Replace(string(payload), `"sub":"p1"`, `"sub":"p2"`, 1))), hubtoken.ErrSignature},
+		{"expiry extended", reencode(token, 1, []byte(strings.Replace(string(payload), `"exp":`, `"exp":9`, 1))), hubtoken.ErrSignature},
+		{"signature flipped", reencode(token, 2, signature), hubtoken.ErrSignature},
+		{"alg none", reencode(token, 0, []byte(strings.Replace(string(header), `"EdDSA"`, `"none"`, 1))), hubtoken.ErrMalformed},
+		{"kid changed", reencode(token, 0, []byte(strings.Replace(string(header), `"kid":"`, `"kid":"x`, 1))), hubtoken.ErrUnknownKey},
+		{"other hub", otherToken, hubtoken.ErrUnknownKey},
+		{"two parts", token[:strings.LastIndex(token, ".")], hubtoken.ErrMalformed},
+		{"not base64", token + "!", hubtoken.ErrMalformed},
+		{"empty", "", hubtoken.ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := set.Verify(tt.token, hubtoken.Options{}); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRotatedKey(t *testing.T) {
+	oldSigner, oldKey := newSigner(t)
+	token, _, err := newHub(t, oldSigner).IssueControllerToken("p1", "user-1", "Alice", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSigner, _ := newSigner(t, oldKey.Public().(ed25519.PublicKey))
+	set := keySetOf(t, newHub(t, newSigner))
+	if _, err := set.Verify(token, hubtoken.Options{}); err != nil {
+		t.Errorf("token of the previous key: %v", err)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"not json", `{`},
+		{"no keys", `{"keys":[]}`},
+		{"only rsa", `{"keys":[{"kty":"RSA","kid":"a","n":"AQAB","e":"AQAB"}]}`},
+		{"short x", `{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"a","x":"AAAA"}]}`},
+	}
+	for _, tt := range tests {
+		if _, err := hubtoken.ParseKeySet([]byte(tt.doc)); err == nil {
+			t.Errorf("%s: ParseKeySet succeeded", tt.name)
+		}
+	}
+}
+
+func TestRemoteKeySetRefetchesUnknownKid(t *testing.T) {
+	first, _ := newSigner(t)
+	second, _ := newSigner(t)
+	var current atomic.Pointer[hub.Hub]
+	current.Store(newHub(t, first))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		jwks, _ := current.Load().JWKS()
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	remote := hubtoken.NewRemoteKeySet(server.URL)
+	ctx := context.Background()
+	token, _, err := current.Load().IssueControllerToken("p1", "user-1", "Alice", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := remote.Verify(ctx, token, hubtoken.Options{}); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1 for a known kid", n)
+	}
+
+	// The hub restarted with a new key: the unknown kid triggers a refetch
+	// once MinRefresh has passed.
+	current.Store(newHub(t, second))
+	remote.MinRefresh = 0
+	token, _, err = current.Load().IssueControllerToken("p1", "user-1", "Alice", "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Verify(ctx, token, hubtoken.Options{}); err != nil {
+		t.Fatalf("Verify after rotation: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}